@@ -345,7 +345,7 @@ func (a *API) getACTDecryptionKey(ctx context.Context, actManifestAddress storag
 	hasher.Reset()
 
 	lk := hex.EncodeToString(lookupKey)
-	list, err := a.GetManifestList(ctx, NOOPDecrypt, actManifestAddress, lk)
+	list, err := a.GetManifestList(ctx, NOOPDecrypt, actManifestAddress, lk, false)
 	if err != nil {
 		return false, nil, nil, err
 	}
@@ -194,6 +194,8 @@ type API struct {
 	rns       Resolver //provides access to rns resolvers
 	Tags      *chunk.Tags
 	Decryptor func(context.Context, string) DecryptFunc
+	// Timestamper, if set, timestamps the root hashes of uploaded content
+	Timestamper *Timestamper
 }
 
 // NewAPI the api constructor initialises a new API instance.
@@ -216,6 +218,12 @@ func (a *API) Retrieve(ctx context.Context, addr storage.Address) (reader storag
 	return a.fileStore.Retrieve(ctx, addr)
 }
 
+// RetrieveChunk retrieves a single chunk, from the network if it is not
+// stored locally
+func (a *API) RetrieveChunk(ctx context.Context, addr storage.Address) (storage.Chunk, error) {
+	return a.fileStore.ChunkStore.Get(ctx, chunk.ModeGetRequest, addr)
+}
+
 func (a *API) RetrieveFeedUpdate(ctx context.Context, addr storage.Address) ([]byte, error) {
 	chunk, err := a.fileStore.ChunkStore.Get(ctx, chunk.ModeGetRequest, addr)
 	if err != nil {
@@ -339,13 +347,20 @@ func (a *API) ResolveURI(ctx context.Context, uri *URI, credentials string) (sto
 // to resolve basePath to content using FileStore retrieve
 // it returns a section reader, mimeType, status, the key of the actual content and an error
 func (a *API) Get(ctx context.Context, decrypt DecryptFunc, manifestAddr storage.Address, path string) (reader storage.LazySectionReader, mimeType string, status int, contentAddr storage.Address, err error) {
+	reader, mimeType, status, contentAddr, _, err = a.GetMutable(ctx, decrypt, manifestAddr, path)
+	return
+}
+
+// GetMutable is like Get, but also reports whether the content was resolved
+// through a feed, in which case it can change while manifestAddr stays the same
+func (a *API) GetMutable(ctx context.Context, decrypt DecryptFunc, manifestAddr storage.Address, path string) (reader storage.LazySectionReader, mimeType string, status int, contentAddr storage.Address, mutable bool, err error) {
 	log.Debug("api.get", "key", manifestAddr, "path", path)
 	apiGetCount.Inc(1)
 	trie, err := loadManifest(ctx, a.fileStore, manifestAddr, nil, decrypt)
 	if err != nil {
 		apiGetNotFound.Inc(1)
 		status = http.StatusNotFound
-		return nil, "", http.StatusNotFound, nil, err
+		return nil, "", http.StatusNotFound, nil, false, err
 	}
 
 	log.Debug("trie getting entry", "key", manifestAddr, "path", path)
@@ -358,22 +373,23 @@ func (a *API) Get(ctx context.Context, decrypt DecryptFunc, manifestAddr storage
 			log.Debug("entry is manifest", "key", manifestAddr, "new key", entry.Hash)
 			adr, err := hex.DecodeString(entry.Hash)
 			if err != nil {
-				return nil, "", 0, nil, err
+				return nil, "", 0, nil, false, err
 			}
-			return a.Get(ctx, decrypt, adr, entry.Path)
+			return a.GetMutable(ctx, decrypt, adr, entry.Path)
 		}
 
 		// we need to do some extra work if this is a Swarm feed manifest
 		if entry.ContentType == FeedContentType {
 			if entry.Feed == nil {
-				return reader, mimeType, status, nil, fmt.Errorf("Cannot decode Feed in manifest")
+				return reader, mimeType, status, nil, false, fmt.Errorf("Cannot decode Feed in manifest")
 			}
+			mutable = true
 			_, err := a.feed.Lookup(ctx, feed.NewQueryLatest(entry.Feed, lookup.NoClue))
 			if err != nil {
 				apiGetNotFound.Inc(1)
 				status = http.StatusNotFound
 				log.Debug(fmt.Sprintf("get feed update content error: %v", err))
-				return reader, mimeType, status, nil, err
+				return reader, mimeType, status, nil, false, err
 			}
 			// get the data of the update
 			_, contentAddr, err := a.feed.GetContent(entry.Feed)
@@ -381,7 +397,7 @@ func (a *API) Get(ctx context.Context, decrypt DecryptFunc, manifestAddr storage
 				apiGetNotFound.Inc(1)
 				status = http.StatusNotFound
 				log.Warn(fmt.Sprintf("get feed update content error: %v", err))
-				return reader, mimeType, status, nil, err
+				return reader, mimeType, status, nil, false, err
 			}
 
 			// extract content hash
@@ -390,7 +406,7 @@ func (a *API) Get(ctx context.Context, decrypt DecryptFunc, manifestAddr storage
 				status = http.StatusUnprocessableEntity
 				errorMessage := fmt.Sprintf("invalid swarm hash in feed update. Expected %d bytes. Got %d", storage.AddressLength, len(contentAddr))
 				log.Warn(errorMessage)
-				return reader, mimeType, status, nil, errors.New(errorMessage)
+				return reader, mimeType, status, nil, false, errors.New(errorMessage)
 			}
 			manifestAddr = storage.Address(contentAddr)
 			log.Trace("feed update contains swarm hash", "key", manifestAddr)
@@ -401,7 +417,7 @@ func (a *API) Get(ctx context.Context, decrypt DecryptFunc, manifestAddr storage
 				apiGetNotFound.Inc(1)
 				status = http.StatusNotFound
 				log.Warn(fmt.Sprintf("loadManifestTrie (feed update) error: %v", err))
-				return reader, mimeType, status, nil, err
+				return reader, mimeType, status, nil, false, err
 			}
 
 			// finally, get the manifest entry
@@ -412,7 +428,7 @@ func (a *API) Get(ctx context.Context, decrypt DecryptFunc, manifestAddr storage
 				apiGetNotFound.Inc(1)
 				err = fmt.Errorf("manifest (feed update) entry for '%s' not found", path)
 				log.Trace("manifest (feed update) entry not found", "key", manifestAddr, "path", path)
-				return reader, mimeType, status, nil, err
+				return reader, mimeType, status, nil, false, err
 			}
 		}
 
@@ -422,7 +438,7 @@ func (a *API) Get(ctx context.Context, decrypt DecryptFunc, manifestAddr storage
 		status = entry.Status
 		if status == http.StatusMultipleChoices {
 			apiGetHTTP300.Inc(1)
-			return nil, entry.ContentType, status, contentAddr, err
+			return nil, entry.ContentType, status, contentAddr, mutable, err
 		}
 		mimeType = entry.ContentType
 		log.Debug("content lookup key", "key", contentAddr, "mimetype", mimeType)
@@ -536,8 +552,10 @@ func (a *API) GetDirectoryTar(ctx context.Context, decrypt DecryptFunc, uri *URI
 }
 
 // GetManifestList lists the manifest entries for the specified address and prefix
-// and returns it as a ManifestList
-func (a *API) GetManifestList(ctx context.Context, decryptor DecryptFunc, addr storage.Address, prefix string) (list ManifestList, err error) {
+// and returns it as a ManifestList. Files below a common prefix are not listed.
+// If summarise is set, they are summed up in the ManifestPrefix of the common
+// prefix, which requires walking all manifests below the prefix.
+func (a *API) GetManifestList(ctx context.Context, decryptor DecryptFunc, addr storage.Address, prefix string, summarise bool) (list ManifestList, err error) {
 	apiManifestListCount.Inc(1)
 	walker, err := a.NewManifestWalker(ctx, addr, decryptor, nil)
 	if err != nil {
@@ -545,6 +563,20 @@ func (a *API) GetManifestList(ctx context.Context, decryptor DecryptFunc, addr s
 		return ManifestList{}, err
 	}
 
+	prefixes := make(map[string]*ManifestPrefix)
+	commonPrefix := func(path string) *ManifestPrefix {
+		if p, ok := prefixes[path]; ok {
+			return p
+		}
+		p := &ManifestPrefix{Path: path}
+		prefixes[path] = p
+		list.CommonPrefixes = append(list.CommonPrefixes, path)
+		if summarise {
+			list.Prefixes = append(list.Prefixes, p)
+		}
+		return p
+	}
+
 	err = walker.Walk(func(entry *ManifestEntry) error {
 		// handle non-manifest files
 		if entry.ContentType != ManifestType {
@@ -553,11 +585,16 @@ func (a *API) GetManifestList(ctx context.Context, decryptor DecryptFunc, addr s
 				return nil
 			}
 
-			// if the path after the prefix contains a slash, add a
-			// common prefix to the list, otherwise add the entry
+			// if the path after the prefix contains a slash, add the
+			// file to a common prefix, otherwise add the entry
 			suffix := strings.TrimPrefix(entry.Path, prefix)
 			if index := strings.Index(suffix, "/"); index > -1 {
-				list.CommonPrefixes = append(list.CommonPrefixes, prefix+suffix[:index+1])
+				p := commonPrefix(prefix + suffix[:index+1])
+				p.Size += entry.Size
+				p.Files++
+				if entry.ModTime.After(p.ModTime) {
+					p.ModTime = entry.ModTime
+				}
 				return nil
 			}
 			if entry.Path == "" {
@@ -576,13 +613,16 @@ func (a *API) GetManifestList(ctx context.Context, decryptor DecryptFunc, addr s
 
 		// if the manifest's path has the specified prefix, then if the
 		// path after the prefix contains a slash, add a common prefix
-		// to the list and skip the manifest, otherwise recurse into
-		// the manifest by returning nil and continuing the walk
+		// to the list and skip the manifest, unless its files are summed
+		// up, otherwise recurse into the manifest by returning nil and
+		// continuing the walk
 		if strings.HasPrefix(entry.Path, prefix) {
 			suffix := strings.TrimPrefix(entry.Path, prefix)
 			if index := strings.Index(suffix, "/"); index > -1 {
-				list.CommonPrefixes = append(list.CommonPrefixes, prefix+suffix[:index+1])
-				return ErrSkipManifest
+				commonPrefix(prefix + suffix[:index+1])
+				if !summarise {
+					return ErrSkipManifest
+				}
 			}
 			return nil
 		}
@@ -0,0 +1,71 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/log"
+)
+
+// chunkEventsBuffer is the number of chunk events buffered for a
+// single RPC subscriber before events start to be dropped
+const chunkEventsBuffer = 1000
+
+// ChunkEventsAPI exposes chunk lifecycle events over RPC
+type ChunkEventsAPI struct {
+	events *chunk.Events
+}
+
+// NewChunkEventsAPI creates a new ChunkEventsAPI
+func NewChunkEventsAPI(events *chunk.Events) *ChunkEventsAPI {
+	return &ChunkEventsAPI{events: events}
+}
+
+// ChunkEvents streams received, stored, pushed, synced and removed chunk
+// events to the subscriber, invoked as bzz_subscribe("chunkEvents")
+func (a *ChunkEventsAPI) ChunkEvents(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, fmt.Errorf("Subscribe not supported")
+	}
+
+	rpcSub := notifier.CreateSubscription()
+	events, unsubscribe := a.events.Subscribe(chunkEventsBuffer)
+
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case ev := <-events:
+				if err := notifier.Notify(rpcSub.ID, ev); err != nil {
+					log.Warn("chunk event notification failed", "sub", rpcSub.ID, "err", err)
+				}
+			case err := <-rpcSub.Err():
+				log.Debug("chunk events subscription error", "sub", rpcSub.ID, "err", err)
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
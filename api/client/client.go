@@ -215,6 +215,28 @@ func (c *Client) UploadDirectory(dir, defaultPath, manifest string, toEncrypt, t
 	return c.TarUpload(manifest, &DirectoryUploader{dir}, defaultPath, toEncrypt, toPin, anonymous)
 }
 
+// Delete removes the entry with the given path from the swarm manifest with
+// the given hash, returning the resulting manifest hash
+func (c *Client) Delete(hash, path string) (string, error) {
+	req, err := http.NewRequest(http.MethodDelete, c.Gateway+"/bzz:/"+hash+"/"+path, nil)
+	if err != nil {
+		return "", err
+	}
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
 // DownloadDirectory downloads the files contained in a swarm manifest under
 // the given path into a local directory (existing files will be overwritten)
 func (c *Client) DownloadDirectory(hash, path, destDir, credentials string) error {
@@ -411,7 +433,22 @@ func (c *Client) DownloadManifest(hash string) (*api.Manifest, bool, error) {
 //
 // where entries ending with "/" are common prefixes.
 func (c *Client) List(hash, prefix, credentials string) (*api.ManifestList, error) {
-	req, err := http.NewRequest(http.MethodGet, c.Gateway+"/bzz-list:/"+hash+"/"+prefix, nil)
+	return c.list(hash, prefix, credentials, false)
+}
+
+// ListSummary lists files in a swarm manifest as List does, along with the
+// size and number of the files under each common prefix, which requires the
+// node to walk all manifests below the prefix
+func (c *Client) ListSummary(hash, prefix, credentials string) (*api.ManifestList, error) {
+	return c.list(hash, prefix, credentials, true)
+}
+
+func (c *Client) list(hash, prefix, credentials string, summary bool) (*api.ManifestList, error) {
+	uri := c.Gateway + "/bzz-list:/" + hash + "/" + prefix
+	if summary {
+		uri += "?summary=true"
+	}
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
 	if err != nil {
 		return nil, err
 	}
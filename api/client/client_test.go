@@ -272,6 +272,85 @@ func TestClientUploadDownloadDirectory(t *testing.T) {
 	}
 }
 
+// TestClientSyncDirectory tests that syncing a directory against a manifest
+// only uploads changed files and removes deleted ones
+func TestClientSyncDirectory(t *testing.T) {
+	srv := swarmhttp.NewTestSwarmServer(t, serverFunc, nil, nil)
+	defer srv.Close()
+
+	dir := newTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	client := NewClient(srv.URL)
+	defaultPath := testDirFiles[0]
+	initial, err := client.SyncDirectory(dir, defaultPath, "", false, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(initial.Uploaded) != len(testDirFiles) {
+		t.Fatalf("expected %d files to be uploaded, got %d", len(testDirFiles), len(initial.Uploaded))
+	}
+
+	// nothing changed, the manifest is reused as it is
+	result, err := client.SyncDirectory(dir, defaultPath, initial.Manifest, false, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Manifest != initial.Manifest || len(result.Uploaded) != 0 || len(result.Removed) != 0 {
+		t.Fatalf("expected no changes, got %+v", result)
+	}
+
+	// change, add and remove a file
+	changed, added, removed := testDirFiles[2], "dir1/file9.txt", testDirFiles[5]
+	if err := ioutil.WriteFile(filepath.Join(dir, changed), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, added), []byte("added"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, removed)); err != nil {
+		t.Fatal(err)
+	}
+	result, err = client.SyncDirectory(dir, defaultPath, initial.Manifest, false, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Uploaded) != 2 || len(result.Removed) != 1 || result.Removed[0] != removed {
+		t.Fatalf("expected 2 uploaded and %s removed, got %+v", removed, result)
+	}
+	if result.Unchanged != len(testDirFiles)-2 {
+		t.Fatalf("expected %d unchanged files, got %d", len(testDirFiles)-2, result.Unchanged)
+	}
+
+	expected := map[string]string{
+		changed: "changed",
+		added:   "added",
+		"":      defaultPath,
+	}
+	for _, file := range testDirFiles {
+		if file != changed && file != removed {
+			expected[file] = file
+		}
+	}
+	for path, content := range expected {
+		file, err := client.Download(result.Manifest, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(file)
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Fatalf("expected %s to be %q, got %q", path, content, data)
+		}
+	}
+	if _, err := client.Download(result.Manifest, removed); err == nil {
+		t.Fatalf("expected %s to be removed", removed)
+	}
+}
+
 // TestClientFileList tests listing files in a swarm manifest
 func TestClientFileList(t *testing.T) {
 	testClientFileList(false, t)
@@ -337,6 +416,61 @@ func testClientFileList(toEncrypt bool, t *testing.T) {
 	}
 }
 
+// TestClientFileListPrefixes tests that common prefixes of a list carry the
+// cumulative size and number of the files under them only if requested
+func TestClientFileListPrefixes(t *testing.T) {
+	srv := swarmhttp.NewTestSwarmServer(t, serverFunc, nil, nil)
+	defer srv.Close()
+
+	dir := newTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	client := NewClient(srv.URL)
+	hash, err := client.UploadDirectory(dir, "", "", false, false, true)
+	if err != nil {
+		t.Fatalf("error uploading directory: %s", err)
+	}
+
+	for prefix, expected := range map[string]map[string][]string{
+		"":      {"dir1/": testDirFiles[2:4], "dir2/": testDirFiles[4:]},
+		"dir2/": {"dir2/dir3/": testDirFiles[5:6], "dir2/dir4/": testDirFiles[6:]},
+	} {
+		list, err := client.List(hash, prefix, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list.CommonPrefixes) != len(expected) || len(list.Prefixes) != 0 {
+			t.Fatalf("prefix %q: expected %d common prefixes without summaries, got %v and %d summaries", prefix, len(expected), list.CommonPrefixes, len(list.Prefixes))
+		}
+		list, err = client.ListSummary(hash, prefix, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list.Prefixes) != len(expected) {
+			t.Fatalf("prefix %q: expected %d common prefixes, got %d", prefix, len(expected), len(list.Prefixes))
+		}
+		for i, p := range list.Prefixes {
+			if p.Path != list.CommonPrefixes[i] {
+				t.Fatalf("prefix %q: expected summary of %s at %d, got %s", prefix, list.CommonPrefixes[i], i, p.Path)
+			}
+			files, ok := expected[p.Path]
+			if !ok {
+				t.Fatalf("prefix %q: unexpected common prefix %s", prefix, p.Path)
+			}
+			var size int64
+			for _, file := range files {
+				size += int64(len(file))
+			}
+			if p.Files != len(files) || p.Size != size {
+				t.Fatalf("prefix %q: expected %s to have %d files of %d bytes, got %d files of %d bytes", prefix, p.Path, len(files), size, p.Files, p.Size)
+			}
+			if p.ModTime.IsZero() {
+				t.Fatalf("prefix %q: expected modification time of %s", prefix, p.Path)
+			}
+		}
+	}
+}
+
 // TestClientMultipartUpload tests uploading files to swarm using a multipart
 // upload
 func TestClientMultipartUpload(t *testing.T) {
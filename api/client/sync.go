@@ -0,0 +1,194 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ethersphere/swarm/api"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/storage"
+)
+
+// SyncResult describes the changes made by SyncDirectory
+type SyncResult struct {
+	Manifest  string   // hash of the resulting manifest
+	Uploaded  []string // paths of the files that were added or changed
+	Removed   []string // paths of the entries that no longer exist locally
+	Unchanged int      // number of entries reused from the previous manifest
+}
+
+// SyncDirectory brings the swarm manifest with the given hash in line with a
+// local directory tree, in the manner of rsync: only files that are new or
+// differ from their manifest entry are uploaded, entries of files that no
+// longer exist are removed, and all other entries are reused as they are.
+// If manifest is empty the whole directory is uploaded into a new manifest.
+//
+// Unencrypted entries are compared by their swarm hash, which is computed
+// locally without uploading anything. Encrypted entries do not have a stable
+// hash, so they are compared by size and modification time instead.
+func (c *Client) SyncDirectory(dir, defaultPath, manifest string, toEncrypt, toPin, anonymous bool) (*SyncResult, error) {
+	if manifest == "" {
+		hash, err := c.UploadDirectory(dir, defaultPath, "", toEncrypt, toPin, anonymous)
+		if err != nil {
+			return nil, err
+		}
+		result := &SyncResult{Manifest: hash}
+		err = (&DirectoryUploader{dir}).Upload(func(file *File) error {
+			file.Close()
+			result.Uploaded = append(result.Uploaded, file.Path)
+			return nil
+		})
+		return result, err
+	}
+
+	entries, err := c.listAll(manifest, "")
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SyncResult{Manifest: manifest}
+	local := make(map[string]bool)
+	var changed []string
+	err = filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if f.IsDir() {
+			return nil
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		local[relPath] = true
+		entry, ok := entries[relPath]
+		if ok {
+			same, err := sameContent(path, f, entry)
+			if err != nil {
+				return err
+			}
+			if same {
+				result.Unchanged++
+				return nil
+			}
+		}
+		changed = append(changed, relPath)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for path := range entries {
+		if !local[path] {
+			result.Removed = append(result.Removed, path)
+		}
+	}
+	sort.Strings(result.Removed)
+
+	if len(changed) > 0 {
+		uploader := UploaderFunc(func(upload UploadFn) error {
+			for _, path := range changed {
+				file, err := Open(filepath.Join(dir, filepath.FromSlash(path)))
+				if err != nil {
+					return err
+				}
+				file.Path = path
+				if err := upload(file); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		// the default path is only set again if its file changed
+		var dp string
+		if defaultPath != "" {
+			for _, path := range changed {
+				if path == filepath.ToSlash(defaultPath) {
+					dp = defaultPath
+				}
+			}
+		}
+		result.Manifest, err = c.TarUpload(result.Manifest, uploader, dp, toEncrypt, toPin, anonymous)
+		if err != nil {
+			return nil, err
+		}
+		result.Uploaded = changed
+	}
+
+	for _, path := range result.Removed {
+		result.Manifest, err = c.Delete(result.Manifest, path)
+		if err != nil {
+			return nil, fmt.Errorf("remove %s: %v", path, err)
+		}
+	}
+	return result, nil
+}
+
+// listAll returns all file entries of a manifest under the given prefix keyed
+// by their path, leaving out the default entry
+func (c *Client) listAll(hash, prefix string) (map[string]*api.ManifestEntry, error) {
+	list, err := c.List(hash, prefix, "")
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]*api.ManifestEntry)
+	for _, entry := range list.Entries {
+		if entry.Path == "/" {
+			continue
+		}
+		entries[entry.Path] = entry
+	}
+	for _, p := range list.CommonPrefixes {
+		sub, err := c.listAll(hash, p)
+		if err != nil {
+			return nil, err
+		}
+		for path, entry := range sub {
+			entries[path] = entry
+		}
+	}
+	return entries, nil
+}
+
+// sameContent reports whether a local file matches its manifest entry
+func sameContent(path string, info os.FileInfo, entry *api.ManifestEntry) (bool, error) {
+	if info.Size() != entry.Size {
+		return false, nil
+	}
+	if len(strings.TrimPrefix(entry.Hash, "0x")) != 2*storage.AddressLength {
+		return info.ModTime().Unix() == entry.ModTime.Unix(), nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	fileStore := storage.NewFileStore(&storage.FakeChunkStore{}, &storage.FakeChunkStore{}, storage.NewFileStoreParams(), chunk.NewTags())
+	addr, _, err := fileStore.Store(context.TODO(), f, info.Size(), false)
+	if err != nil {
+		return false, err
+	}
+	return addr.Hex() == entry.Hash, nil
+}
@@ -21,7 +21,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -29,7 +31,10 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethersphere/swarm/contracts/ens"
 	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/network/retrieval"
+	"github.com/ethersphere/swarm/p2p/protocols"
 	"github.com/ethersphere/swarm/pss"
+	"github.com/ethersphere/swarm/pss/bridge"
 	"github.com/ethersphere/swarm/storage"
 	"github.com/ethersphere/swarm/swap"
 )
@@ -46,70 +51,166 @@ type Config struct {
 	*storage.FileStoreParams
 
 	// LocalStore
-	ChunkDbPath   string
-	DbCapacity    uint64
-	CacheCapacity uint
-	BaseKey       []byte
+	ChunkDbPath string
+	DbCapacity  uint64
+	DbEngine    string // shed storage engine of a new chunk database
+	DbReadOnly  bool   // serve chunks from an existing chunk database without writing to it
+	// SyncBatchDelay enables batching of chunks received by pull syncing,
+	// SyncBatchSize is the maximal number of chunks in a batch
+	SyncBatchDelay time.Duration
+	SyncBatchSize  int
+	// SyncOnlyWithinDepth limits pull syncing to the bins
+	// within the neighbourhood depth
+	SyncOnlyWithinDepth bool
+	CacheCapacity       uint
+	// EncryptStateStore encrypts the state and swap stores at rest
+	// with a key derived from the account key
+	EncryptStateStore bool
+	BaseKey           []byte
 
 	// Swap configs
-	SwapBackendURL          string         // Ethereum API endpoint
-	SwapEnabled             bool           // whether SWAP incentives are enabled
-	SwapPaymentThreshold    uint64         // honey amount at which a payment is triggered
-	SwapDisconnectThreshold uint64         // honey amount at which a peer disconnects
-	SwapSkipDeposit         bool           // do not ask the user to deposit during boot sequence
-	SwapDepositAmount       uint64         // deposit amount to the chequebook
-	SwapLogPath             string         // dir to swap related audit logs
-	Contract                common.Address // address of the chequebook contract
-	SwapChequebookFactory   common.Address // address of the chequebook factory contract
+	SwapBackendURL              string         // Ethereum API endpoint
+	SwapEnabled                 bool           // whether SWAP incentives are enabled
+	SwapPaymentThreshold        uint64         // honey amount at which a payment is triggered
+	SwapDisconnectThreshold     uint64         // honey amount at which a peer disconnects
+	SwapGraceAllowance          uint64         // honey amount within which balances with peers decay towards zero instead of triggering a payment
+	SwapGraceDecayRate          uint64         // honey per second by which balances within the grace allowance decay
+	SwapPriceOracle             string         // URL of an HTTP oracle or address of an oracle contract resolving the price of honey, the fixed SwapHoneyPrice if empty
+	SwapHoneyPrice              uint64         // fixed price of honey in wei without a price oracle, the default price if 0
+	SwapPriceTolerance          uint64         // percentage by which the amount of a received cheque may differ from the local price of its honey
+	SwapSkipDeposit             bool           // do not ask the user to deposit during boot sequence
+	SwapDepositAmount           uint64         // deposit amount to the chequebook
+	SwapLogPath                 string         // dir the swap execution logs are also written to
+	SwapBalanceLog              string         // file to append every balance mutation to, no balance audit if empty
+	SwapBalanceLogSize          uint64         // size in bytes at which the balance audit log is rotated
+	SwapBalanceLogFiles         uint64         // number of rotated balance audit log files kept
+	SwapThrottleFraction        float64        // fraction of SwapDisconnectThreshold above which serving a peer is delayed, no throttling if 0
+	SwapThrottleMaxDelay        time.Duration  // delay of serving a peer at SwapDisconnectThreshold
+	SwapReconcileTolerance      uint64         // largest balance disagreement with a reconnecting peer which is converged, balances are not reconciled if 0
+	SwapPaymentRequestThreshold uint64         // honey amount a peer owes at which it is asked to pay, no payment requests if 0
+	SwapOwnerLocked             bool           // lock the chequebook owner key in the keystore once the chequebook is started
+	SwapPayOnly                 bool           // pay for services with cheques but extend no credit to peers
+	SwapInterestDebtLevel       uint64         // honey amount a peer owes above which interest accrues
+	SwapInterestGracePeriod     time.Duration  // time a peer may owe more than SwapInterestDebtLevel before interest accrues
+	SwapInterestRate            uint64         // interest in parts per million of the debt per hour, no interest if 0
+	SwapCashingInterval         time.Duration  // interval at which the latest received cheques are cashed in a batch, cheques are cashed on receipt if 0
+	SwapCashingThreshold        uint64         // amount a queued cheque pays out at which it is cashed without waiting for SwapCashingInterval
+	SwapCashoutMinAmount        uint64         // amount a received cheque must pay out to be cashed, no minimum if 0
+	SwapCashoutMaxDelay         time.Duration  // time after which a received cheque is cashed regardless of SwapCashoutMinAmount and SwapCashoutMaxGasPrice, no limit if 0
+	SwapCashoutMaxGasPrice      uint64         // gas price in wei above which received cheques are not cashed, no limit if 0
+	SwapExemptPeers             []string       // enode URLs, node IDs or overlay addresses of peers which are not accounted with
+	SwapAssets                  []string       // settlement assets in order of preference, eth or ERC20 token addresses, only eth if empty
+	SwapTokenChequebooks        []string       // chequebooks of the ERC20 settlement assets as <token>:<chequebook>[:<honey price>]
+	Contract                    common.Address // address of the chequebook contract
+	SwapChequebookFactory       common.Address // address of the chequebook factory contract
 	// end of Swap configs
 
 	*network.HiveParams
+	Role               string // node role preset the settings were derived from, empty if none
 	Pss                *pss.Params
+	PssBridge          *bridge.Config // relay pss messages to an external HTTP service
 	EnsRoot            common.Address
 	EnsAPIs            []string
 	RnsAPI             string
 	Path               string
 	ListenAddr         string
 	Port               string
+	Underlays          []string // alternative underlays the node is reachable at, like wss://gateway.example.org:443
+	WSUnderlayAddr     string   // listen address of the websocket underlay, disabled if empty
+	WSUnderlayCert     string   // TLS certificate file of the websocket underlay, serves wss instead of ws if set
+	WSUnderlayKey      string   // TLS key file of the websocket underlay
+	WSUnderlayDial     bool     // dial peers over the ws and wss underlays they advertise
 	PublicKey          string
 	BzzKey             string
 	Enode              *enode.Node `toml:"-"`
 	NetworkID          uint64
+	NetworkForkID      uint64 // bitmap of forks/features peers must share, partitions networks with the same NetworkID
+	NetworkKey         string // pre-shared secret of a private network that peers must prove to know, empty for a public network
+	HandshakeRateLimit uint64 // inbound handshakes per minute above which unseen peers must solve the handshake puzzle, 0 if they never must
+	HandshakePuzzle    uint64 // leading zero bits of the handshake puzzle hash, handshake puzzles are disabled if 0
 	SyncEnabled        bool
 	PushSyncEnabled    bool
 	LightNodeEnabled   bool
 	BootnodeMode       bool
 	DisableAutoConnect bool
+	PeerUptimeBias     bool // prefer connecting to peers that stayed connected longer in the past
 	EnablePinning      bool
+	ChunkEventsEnabled bool   // emit chunk lifecycle events and expose them over RPC
+	BandwidthDailyCap  uint64 // bytes sent to a peer per day after which sending to it is throttled, 0 for no cap
+	// upstream bandwidth caps in bytes per second, 0 for no cap
+	BandwidthUpstream  uint64        // all protocols
+	BandwidthSync      uint64        // syncing
+	BandwidthRetrieval uint64        // serving retrieve requests
+	BandwidthPss       uint64        // pss forwarding
+	HandoffOnShutdown  bool          // push chunks in the area of responsibility to the neighbours on shutdown
+	HandoffTimeout     time.Duration // maximum time spent handing off chunks on shutdown
+	ObfuscateRetrieval bool          // forward retrieve requests of light clients as the node's own to hide their origin
+	Obfuscation        *retrieval.ObfuscationParams
+	RetryCorruptChunks bool          // request a chunk from another peer as soon as a peer delivers it with invalid content
+	ProvenanceWindow   time.Duration // sliding window over which the most requested chunks and their requesters are tracked, 0 disables tracking
+	StandbyPrimary     string        // enode URL of the primary node mirrored by this warm standby
+	StandbyPeers       []string      // enode URLs or public keys of the standby nodes allowed to mirror this node
+	AllowPeers         []string      // node IDs, enode URLs, IPs or CIDR ranges of the only peers allowed to connect, empty allows all
+	DenyPeers          []string      // node IDs, enode URLs, IPs or CIDR ranges of peers not allowed to connect
+	LogVerbosity       string        // log level ceiling (crit, error, warn, info, debug or trace), empty keeps the current level
+	LogVmodule         string        // per source file log levels, empty keeps the current pattern
 	Cors               string
 	BzzAccount         string
 	GlobalStoreAPI     string
+	RetrievalWorkers   *protocols.WorkerPoolParams // limits of the goroutines handling retrieve requests and chunk deliveries
+	SyncWorkers        *protocols.WorkerPoolParams // limits of the goroutines handling syncing messages
+	SyncFragmentSize   uint32                      // length of the fragments longer syncing messages are split into, 0 disables fragmentation, all nodes of the network must use the same setting
+	FeedDeltaInterval  int                         // store feed updates as deltas with a full update every FeedDeltaInterval updates, 0 disables deltas
+	TimestampInterval  time.Duration               // interval between anchorings of the root hashes of uploaded content, 0 disables timestamping
+	TimestampBackend   string                      // Ethereum API endpoint the timestamp anchoring transactions are sent to
+	ClockTolerance     time.Duration               // offset of the host clock to peers up to which timestamps are accepted, above which it is reported as skewed
+	NTPServer          string                      // NTP server the host clock is checked against on startup, empty disables the check
+	UploadMaxSize      int64                       // largest upload accepted by the http api in bytes, 0 disables the limit
+	UploadContentTypes []string                    // media types of uploads accepted by the http api, empty allows all
+	UploadScanURL      string                      // external service every upload to the http api is posted to for approval
+	UploadHealthy      bool                        // refuse uploads to the http api while the node is not healthy
+	UploadMaxSyncLag   uint64                      // number of chunks pull syncing may lag behind peers before the node is not healthy
 	privateKey         *ecdsa.PrivateKey
+	keystore           *keystore.KeyStore
 }
 
 //NewConfig creates a default config with all parameters to set to defaults
 func NewConfig() *Config {
 	return &Config{
-		FileStoreParams:         storage.NewFileStoreParams(),
-		SwapBackendURL:          "",
-		SwapEnabled:             false,
-		SwapSkipDeposit:         false,
-		SwapDepositAmount:       swap.DefaultDepositAmount,
-		SwapPaymentThreshold:    swap.DefaultPaymentThreshold,
-		SwapDisconnectThreshold: swap.DefaultDisconnectThreshold,
-		SwapLogPath:             "",
-		HiveParams:              network.NewHiveParams(),
-		Pss:                     pss.NewParams(),
-		EnsRoot:                 ens.TestNetAddress,
-		EnsAPIs:                 nil,
-		RnsAPI:                  "",
-		Path:                    node.DefaultDataDir(),
-		ListenAddr:              DefaultHTTPListenAddr,
-		Port:                    DefaultHTTPPort,
-		NetworkID:               network.DefaultNetworkID,
-		SyncEnabled:             true,
-		PushSyncEnabled:         true,
-		EnablePinning:           false,
+		FileStoreParams:             storage.NewFileStoreParams(),
+		SwapBackendURL:              "",
+		SwapEnabled:                 false,
+		SwapSkipDeposit:             false,
+		SwapDepositAmount:           swap.DefaultDepositAmount,
+		SwapPaymentThreshold:        swap.DefaultPaymentThreshold,
+		SwapDisconnectThreshold:     swap.DefaultDisconnectThreshold,
+		SwapThrottleMaxDelay:        swap.DefaultThrottleMaxDelay,
+		SwapReconcileTolerance:      swap.DefaultReconcileTolerance,
+		SwapPaymentRequestThreshold: swap.DefaultPaymentRequestThreshold,
+		SwapInterestDebtLevel:       swap.DefaultInterestDebtLevel,
+		SwapInterestGracePeriod:     swap.DefaultInterestGracePeriod,
+		SwapLogPath:                 "",
+		HiveParams:                  network.NewHiveParams(),
+		Pss:                         pss.NewParams(),
+		PssBridge:                   bridge.NewConfig(),
+		EnsRoot:                     ens.TestNetAddress,
+		EnsAPIs:                     nil,
+		RnsAPI:                      "",
+		Path:                        node.DefaultDataDir(),
+		ListenAddr:                  DefaultHTTPListenAddr,
+		Port:                        DefaultHTTPPort,
+		WSUnderlayDial:              true,
+		NetworkID:                   network.DefaultNetworkID,
+		SyncEnabled:                 true,
+		PushSyncEnabled:             true,
+		EnablePinning:               false,
+		HandoffTimeout:              5 * time.Minute,
+		Obfuscation:                 retrieval.NewObfuscationParams(),
+		RetrievalWorkers:            protocols.NewWorkerPoolParams(),
+		SyncWorkers:                 protocols.NewWorkerPoolParams(),
+		ClockTolerance:              network.DefaultClockTolerance,
+		HandshakePuzzle:             network.DefaultHandshakePuzzleDifficulty,
+		UploadMaxSyncLag:            DefaultUploadMaxSyncLag,
 	}
 }
 
@@ -153,6 +254,17 @@ func (c *Config) ShiftPrivateKey() (privKey *ecdsa.PrivateKey) {
 	return privKey
 }
 
+// SetKeystore sets the node keystore, through which the swarm account key is
+// used as the chequebook owner key if the keystore holds it
+func (c *Config) SetKeystore(ks *keystore.KeyStore) {
+	c.keystore = ks
+}
+
+// Keystore returns the node keystore, nil if it is not set
+func (c *Config) Keystore() *keystore.KeyStore {
+	return c.keystore
+}
+
 func (c *Config) setKey(prvKey *ecdsa.PrivateKey) {
 	bzzkeybytes := network.PrivateKeyToBzzKey(prvKey)
 	pubkey := crypto.FromECDSAPub(&prvKey.PublicKey)
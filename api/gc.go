@@ -0,0 +1,78 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/storage/localstore"
+)
+
+// GCAPI exposes localstore garbage collection to node operators,
+// invoked as debug_gcRun and debug_gcStats.
+type GCAPI struct {
+	ls *localstore.DB
+}
+
+// NewGCAPI creates a new GCAPI
+func NewGCAPI(ls *localstore.DB) *GCAPI {
+	return &GCAPI{ls: ls}
+}
+
+// GCRunResult is the outcome of a garbage collection run forced by GcRun.
+type GCRunResult struct {
+	Collected uint64        // number of removed chunks
+	Duration  time.Duration // time the run took
+}
+
+// GcRun removes chunks from the localstore garbage collection index
+// regardless of its capacity, until either the chunks count or the
+// bytes budget is exhausted. A zero value means no limit for that
+// budget, but at least one of them must be set. Bytes are accounted
+// as full size chunks, so less than the budget may be reclaimed, and
+// a bytes budget smaller than a chunk is rejected.
+// Pinned chunks are not removed.
+func (g *GCAPI) GcRun(chunks, bytes uint64) (*GCRunResult, error) {
+	if chunks == 0 && bytes == 0 {
+		return nil, errors.New("chunks or bytes budget required")
+	}
+	limit := chunks
+	if l := bytes / chunk.DefaultSize; bytes > 0 && (limit == 0 || l < limit) {
+		limit = l
+	}
+	if limit == 0 {
+		return nil, fmt.Errorf("bytes budget %d is smaller than a chunk", bytes)
+	}
+	start := time.Now()
+	collected, err := g.ls.CollectGarbage(limit)
+	if err != nil {
+		return nil, err
+	}
+	return &GCRunResult{
+		Collected: collected,
+		Duration:  time.Since(start),
+	}, nil
+}
+
+// GcStats returns garbage collection counters and the actual
+// and target sizes of the garbage collection index.
+func (g *GCAPI) GcStats() (localstore.GCStats, error) {
+	return g.ls.GCStats()
+}
@@ -0,0 +1,90 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/storage"
+	"github.com/ethersphere/swarm/storage/localstore"
+)
+
+// TestGCAPI validates that debug_gcRun removes chunks within
+// the chunks and bytes budgets and that debug_gcStats reports them.
+func TestGCAPI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swarm-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	localStore, err := localstore.New(dir, make([]byte, 32), &localstore.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer localStore.Close()
+
+	for _, ch := range storage.GenerateRandomChunks(chunk.DefaultSize, 10) {
+		if _, err := localStore.Put(context.Background(), chunk.ModePutRequest, ch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("debug", NewGCAPI(localStore)); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+
+	for _, tc := range []struct {
+		chunks, bytes uint64
+		want          uint64
+	}{
+		{chunks: 3, want: 3},
+		{bytes: 2*chunk.DefaultSize + 100, want: 2},
+		{chunks: 4, bytes: 1 * chunk.DefaultSize, want: 1},
+	} {
+		var result GCRunResult
+		if err := client.Call(&result, "debug_gcRun", tc.chunks, tc.bytes); err != nil {
+			t.Fatal(err)
+		}
+		if result.Collected != tc.want {
+			t.Errorf("chunks %v, bytes %v: got collected %v, want %v", tc.chunks, tc.bytes, result.Collected, tc.want)
+		}
+	}
+
+	var result GCRunResult
+	if err := client.Call(&result, "debug_gcRun", 0, 0); err == nil {
+		t.Error("expected error without a budget")
+	}
+	if err := client.Call(&result, "debug_gcRun", 4, chunk.DefaultSize-1); err == nil {
+		t.Error("expected error with a bytes budget smaller than a chunk")
+	}
+
+	var stats localstore.GCStats
+	if err := client.Call(&stats, "debug_gcStats"); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Collected != 6 || stats.Size != 4 {
+		t.Errorf("got collected %v, size %v, want 6, 4", stats.Collected, stats.Size)
+	}
+}
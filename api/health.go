@@ -0,0 +1,121 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/network/stream"
+	"github.com/ethersphere/swarm/storage/localstore"
+)
+
+// DefaultUploadMaxSyncLag is the number of chunks the node may lag behind
+// its peers in pull syncing before it is considered unhealthy for uploads
+const DefaultUploadMaxSyncLag = 100000
+
+// ErrNoPeers is returned by the health check when the node has no connected peers
+var ErrNoPeers = errors.New("no connected peers")
+
+// healthKademlia is the part of the kademlia the health check inspects
+type healthKademlia interface {
+	EachConn(base []byte, o int, f func(*network.Peer, int) bool)
+	Saturation() int
+	NeighbourhoodDepth() int
+}
+
+// healthStore is the part of the local store the health check inspects
+type healthStore interface {
+	GCStats() (localstore.GCStats, error)
+}
+
+// healthSyncer is the part of the pull syncer the health check inspects
+type healthSyncer interface {
+	Subscriptions() []stream.SubscriptionStats
+}
+
+// HealthCheck checks whether content uploaded to the node can propagate to
+// the network, so that uploads can be refused while it can not
+type HealthCheck struct {
+	kad        healthKademlia
+	store      healthStore  // no store check if nil
+	syncer     healthSyncer // no sync check if nil
+	maxSyncLag uint64
+}
+
+// NewHealthCheck creates a health check of the connectivity in the kademlia,
+// the size of the local store and the pull syncing lag, which must not
+// exceed maxSyncLag chunks
+func NewHealthCheck(kad *network.Kademlia, ls *localstore.DB, syncer *stream.Registry, maxSyncLag uint64) *HealthCheck {
+	return &HealthCheck{
+		kad:        kad,
+		store:      ls,
+		syncer:     syncer,
+		maxSyncLag: maxSyncLag,
+	}
+}
+
+// Check returns an error with the reason content uploaded to the node can
+// not propagate to the network, nil if the node is healthy
+func (h *HealthCheck) Check() error {
+	connected := false
+	h.kad.EachConn(nil, 255, func(*network.Peer, int) bool {
+		connected = true
+		return false
+	})
+	if !connected {
+		return ErrNoPeers
+	}
+	if saturation, depth := h.kad.Saturation(), h.kad.NeighbourhoodDepth(); saturation < depth {
+		return fmt.Errorf("kademlia not saturated: bin %d has too few peers below depth %d", saturation, depth)
+	}
+	if h.store != nil {
+		stats, err := h.store.GCStats()
+		if err != nil {
+			return fmt.Errorf("local store: %v", err)
+		}
+		if stats.Size > stats.Capacity {
+			return fmt.Errorf("local store over capacity: %d chunks of %d", stats.Size, stats.Capacity)
+		}
+	}
+	if h.syncer != nil {
+		var lag uint64
+		for _, s := range h.syncer.Subscriptions() {
+			lag += s.Lag
+		}
+		if lag > h.maxSyncLag {
+			return fmt.Errorf("pull syncing lagging: %d chunks behind peers, at most %d", lag, h.maxSyncLag)
+		}
+	}
+	return nil
+}
+
+// HealthStatus is the result of the health check
+type HealthStatus struct {
+	Healthy bool   `json:"healthy"`
+	Reason  string `json:"reason,omitempty"` // reason the node is not healthy
+}
+
+// UploadHealth returns whether content uploaded to the node can propagate
+// to the network, and the reason if it can not
+func (h *HealthCheck) UploadHealth() HealthStatus {
+	if err := h.Check(); err != nil {
+		return HealthStatus{Reason: err.Error()}
+	}
+	return HealthStatus{Healthy: true}
+}
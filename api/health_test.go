@@ -0,0 +1,128 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/network/stream"
+	"github.com/ethersphere/swarm/storage/localstore"
+)
+
+type testHealthKademlia struct {
+	peers      int
+	saturation int
+	depth      int
+}
+
+func (k *testHealthKademlia) EachConn(base []byte, o int, f func(*network.Peer, int) bool) {
+	for i := 0; i < k.peers; i++ {
+		if !f(nil, 0) {
+			return
+		}
+	}
+}
+
+func (k *testHealthKademlia) Saturation() int {
+	return k.saturation
+}
+
+func (k *testHealthKademlia) NeighbourhoodDepth() int {
+	return k.depth
+}
+
+type testHealthStore localstore.GCStats
+
+func (s *testHealthStore) GCStats() (localstore.GCStats, error) {
+	return localstore.GCStats(*s), nil
+}
+
+type testHealthSyncer []stream.SubscriptionStats
+
+func (s testHealthSyncer) Subscriptions() []stream.SubscriptionStats {
+	return s
+}
+
+// TestHealthCheck tests that the health check reports the reason
+// uploaded content can not propagate to the network
+func TestHealthCheck(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		kad    testHealthKademlia
+		store  testHealthStore
+		syncer testHealthSyncer
+		reason string
+	}{
+		{
+			name:  "healthy",
+			kad:   testHealthKademlia{peers: 4, saturation: 2, depth: 2},
+			store: testHealthStore{Size: 100, Capacity: 100},
+			syncer: testHealthSyncer{
+				{Stream: "SYNC|0", Lag: 5},
+				{Stream: "SYNC|1", Lag: 5},
+			},
+		},
+		{
+			name:   "no peers",
+			kad:    testHealthKademlia{},
+			reason: ErrNoPeers.Error(),
+		},
+		{
+			name:   "not saturated",
+			kad:    testHealthKademlia{peers: 4, saturation: 1, depth: 2},
+			reason: "kademlia not saturated",
+		},
+		{
+			name:   "over capacity",
+			kad:    testHealthKademlia{peers: 4, saturation: 2, depth: 2},
+			store:  testHealthStore{Size: 101, Capacity: 100},
+			reason: "local store over capacity",
+		},
+		{
+			name:  "sync lagging",
+			kad:   testHealthKademlia{peers: 4, saturation: 2, depth: 2},
+			store: testHealthStore{Size: 100, Capacity: 100},
+			syncer: testHealthSyncer{
+				{Stream: "SYNC|0", Lag: 5},
+				{Stream: "SYNC|1", Lag: 6},
+			},
+			reason: "pull syncing lagging",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			h := &HealthCheck{
+				kad:        &tc.kad,
+				store:      &tc.store,
+				syncer:     tc.syncer,
+				maxSyncLag: 10,
+			}
+			status := h.UploadHealth()
+			if tc.reason == "" {
+				if !status.Healthy {
+					t.Fatalf("expected the node to be healthy, got %q", status.Reason)
+				}
+				return
+			}
+			if status.Healthy || !strings.Contains(status.Reason, tc.reason) {
+				t.Fatalf("expected the node to be unhealthy with %q, got %+v", tc.reason, status)
+			}
+		})
+	}
+}
@@ -102,6 +102,8 @@ func InitLoggingResponseWriter(h http.Handler) http.Handler {
 // when the Content-Length header is set, an ETA on chunking will be available since the
 // number of chunks to be split is known in advance (not including enclosing manifest chunks)
 // the tag can later be accessed using the appropriate identifier in the request context
+// a time to live hint in seconds for the uploaded chunks can be set using the TTLHeaderName
+// the storage used by the uploaded chunks is accounted to the uploader set using the OwnerHeaderName
 func InitUploadTag(h http.Handler, tags *chunk.Tags) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var (
@@ -111,7 +113,23 @@ func InitUploadTag(h http.Handler, tags *chunk.Tags) http.Handler {
 			contentType          = r.Header.Get("Content-Type")
 			headerTag            = r.Header.Get(TagHeaderName)
 			anonTag              = r.Header.Get(AnonymousHeaderName)
+			ttlHeader            = r.Header.Get(TTLHeaderName)
+			owner                = r.Header.Get(OwnerHeaderName)
+			expiry         time.Time
 		)
+		if ttlHeader != "" {
+			ttl, err := strconv.ParseUint(ttlHeader, 10, 32)
+			if err != nil {
+				respondError(w, r, fmt.Sprintf("invalid %s header %q", TTLHeaderName, ttlHeader), http.StatusBadRequest)
+				return
+			}
+			expiry = time.Now().Add(time.Duration(ttl) * time.Second)
+		}
+		if len(owner) > maxOwnerLength {
+			respondError(w, r, fmt.Sprintf("%s header longer than %d bytes", OwnerHeaderName, maxOwnerLength), http.StatusBadRequest)
+			return
+		}
+
 		if headerTag != "" {
 			tagName = headerTag
 			log.Trace("got tag name from http header", "tagName", tagName)
@@ -139,6 +157,15 @@ func InitUploadTag(h http.Handler, tags *chunk.Tags) http.Handler {
 			log.Error("error creating tag", "err", err, "tagName", tagName)
 		}
 
+		if !expiry.IsZero() {
+			log.Trace("setting expiry on tag", "uid", t.Uid, "expiry", expiry)
+			t.Expiry = expiry
+		}
+		if owner != "" {
+			log.Trace("setting owner on tag", "uid", t.Uid, "owner", owner)
+			t.Owner = owner
+		}
+
 		log.Trace("setting tag id to context", "uid", t.Uid)
 		ctx := sctx.SetTag(r.Context(), t.Uid)
 
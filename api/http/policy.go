@@ -0,0 +1,235 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/log"
+)
+
+var postRejected = metrics.NewRegisteredCounter("api.http.post.rejected", nil)
+
+// DefaultMaxScanSize is the largest upload posted to an upload scanning
+// service if no limit is given
+const DefaultMaxScanSize = 100 << 20
+
+// UploadPolicy decides whether the node accepts an upload, so that operators
+// of public gateways can enforce acceptable use policies
+type UploadPolicy interface {
+	// Check returns nil to accept the upload or an error with the reason
+	// it is rejected. A *PolicyError sets the status code of the response.
+	Check(u *Upload) error
+}
+
+// UploadPolicyFunc is a function implementing UploadPolicy
+type UploadPolicyFunc func(u *Upload) error
+
+// Check calls f(u)
+func (f UploadPolicyFunc) Check(u *Upload) error {
+	return f(u)
+}
+
+// PolicyError is returned by upload policies rejecting an upload
+type PolicyError struct {
+	Status int    // http status code of the response, 403 if zero
+	Reason string // explanation sent to the client
+}
+
+func (e *PolicyError) Error() string {
+	return e.Reason
+}
+
+// Upload describes a request uploading content, checked by upload policies
+type Upload struct {
+	Request     *http.Request
+	ContentType string // media type of the request body, without parameters
+	Size        int64  // length of the request body, -1 if unknown
+	data        []byte
+}
+
+// Data reads the whole request body and keeps it to be stored if the upload
+// is accepted. Policies calling it should limit the size of uploads first.
+func (u *Upload) Data() ([]byte, error) {
+	if u.data != nil {
+		return u.data, nil
+	}
+	data, err := ioutil.ReadAll(u.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+	u.setData(data)
+	return data, nil
+}
+
+// setData replaces the request body with the data read from it
+func (u *Upload) setData(data []byte) {
+	u.Request.Body.Close()
+	u.data = data
+	u.Request.Body = ioutil.NopCloser(bytes.NewReader(data))
+}
+
+// MaxUploadSize rejects uploads larger than max bytes. Requests without
+// a content length are accepted, but reading more than max bytes of their
+// body fails.
+func MaxUploadSize(max int64) UploadPolicy {
+	return UploadPolicyFunc(func(u *Upload) error {
+		if u.Size > max {
+			return &PolicyError{
+				Status: http.StatusRequestEntityTooLarge,
+				Reason: fmt.Sprintf("upload of %d bytes exceeds the limit of %d bytes", u.Size, max),
+			}
+		}
+		if u.Size < 0 {
+			u.Request.Body = http.MaxBytesReader(nil, u.Request.Body, max)
+		}
+		return nil
+	})
+}
+
+// AllowContentTypes rejects uploads with a media type which is not in types.
+// A type ending with "/*", like "image/*", allows all of its subtypes. Note that
+// collections are uploaded as application/x-tar or multipart/form-data.
+func AllowContentTypes(types ...string) UploadPolicy {
+	return UploadPolicyFunc(func(u *Upload) error {
+		for _, t := range types {
+			if t == u.ContentType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(u.ContentType, t[:len(t)-1])) {
+				return nil
+			}
+		}
+		return &PolicyError{
+			Status: http.StatusUnsupportedMediaType,
+			Reason: fmt.Sprintf("content type %q is not allowed", u.ContentType),
+		}
+	})
+}
+
+// ScanUploads streams the body of every upload to an external scanning service
+// at url, with the content type of the upload. The upload is accepted if the
+// service responds with a 2xx status code and rejected with the response body
+// as the reason otherwise. Uploads are rejected if the service fails or if they
+// are larger than max bytes, DefaultMaxScanSize if max is not positive, as
+// the scanned body is kept in memory to be stored once accepted.
+func ScanUploads(url string, client *http.Client, max int64) UploadPolicy {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if max <= 0 {
+		max = DefaultMaxScanSize
+	}
+	tooLarge := func(size int64) error {
+		return &PolicyError{
+			Status: http.StatusRequestEntityTooLarge,
+			Reason: fmt.Sprintf("upload of %d bytes exceeds the scanning limit of %d bytes", size, max),
+		}
+	}
+	return UploadPolicyFunc(func(u *Upload) error {
+		if u.Size > max {
+			return tooLarge(u.Size)
+		}
+		// the body is sent to the service while it is read from the client
+		body := &io.LimitedReader{R: u.Request.Body, N: max + 1}
+		var data bytes.Buffer
+		req, err := http.NewRequest(http.MethodPost, url, io.TeeReader(body, &data))
+		if err != nil {
+			return err
+		}
+		req = req.WithContext(u.Request.Context())
+		req.ContentLength = u.Size
+		req.Header.Set("Content-Type", u.Request.Header.Get("Content-Type"))
+		res, err := client.Do(req)
+		if body.N <= 0 {
+			if err == nil {
+				res.Body.Close()
+			}
+			return tooLarge(int64(data.Len()))
+		}
+		if err != nil {
+			log.Error("upload scanning service failed", "url", url, "err", err)
+			return &PolicyError{Status: http.StatusServiceUnavailable, Reason: "upload scanning is not available"}
+		}
+		defer res.Body.Close()
+		if res.StatusCode >= 200 && res.StatusCode < 300 {
+			// a service accepting the upload must have scanned all of it
+			if n, err := io.Copy(ioutil.Discard, body); err != nil || n > 0 {
+				return &PolicyError{Status: http.StatusServiceUnavailable, Reason: "upload scanning did not receive the whole upload"}
+			}
+			u.setData(data.Bytes())
+			return nil
+		}
+		reason, _ := ioutil.ReadAll(http.MaxBytesReader(nil, res.Body, 1024))
+		if len(reason) == 0 {
+			reason = []byte(fmt.Sprintf("rejected by upload scanning with status %d", res.StatusCode))
+		}
+		return &PolicyError{Reason: strings.TrimSpace(string(reason))}
+	})
+}
+
+// RequireHealthy rejects uploads with 503 Service Unavailable while check
+// returns an error, the reason content uploaded to the node can not propagate
+// to the network
+func RequireHealthy(check func() error) UploadPolicy {
+	return UploadPolicyFunc(func(u *Upload) error {
+		if err := check(); err != nil {
+			return &PolicyError{
+				Status: http.StatusServiceUnavailable,
+				Reason: fmt.Sprintf("node is not healthy: %v", err),
+			}
+		}
+		return nil
+	})
+}
+
+// CheckUploadPolicies is a middleware that responds with an error to uploads
+// which are rejected by any of the policies, checked in order
+func CheckUploadPolicies(h http.Handler, policies func() []UploadPolicy) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := policies()
+		if len(p) == 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+		contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		u := &Upload{
+			Request:     r,
+			ContentType: contentType,
+			Size:        r.ContentLength,
+		}
+		for _, policy := range p {
+			err := policy.Check(u)
+			if err == nil {
+				continue
+			}
+			postRejected.Inc(1)
+			status := http.StatusForbidden
+			if e, ok := err.(*PolicyError); ok && e.Status != 0 {
+				status = e.Status
+			}
+			log.Info("upload rejected by policy", "ruid", GetRUID(r.Context()), "err", err)
+			respondError(w, r, fmt.Sprintf("upload rejected: %v", err), status)
+			return
+		}
+		h.ServeHTTP(w, u.Request)
+	})
+}
@@ -0,0 +1,181 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethersphere/swarm/api"
+	"github.com/ethersphere/swarm/storage/pin"
+)
+
+// TestUploadPolicies checks that uploads are stored only
+// if they are accepted by all upload policies
+func TestUploadPolicies(t *testing.T) {
+	var scanned []byte
+	scanner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scanned, _ = ioutil.ReadAll(r.Body)
+		if bytes.Contains(scanned, []byte("virus")) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("virus found"))
+		}
+	}))
+	defer scanner.Close()
+
+	srv := NewTestSwarmServer(t, func(api *api.API, pinAPI *pin.API) TestServer {
+		server := NewServer(api, pinAPI, "")
+		server.SetUploadPolicies(
+			MaxUploadSize(100),
+			AllowContentTypes("text/plain", "image/*"),
+			ScanUploads(scanner.URL, nil, 0),
+		)
+		return server
+	}, nil, nil)
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		name        string
+		contentType string
+		data        []byte
+		status      int
+	}{
+		{"accepted", "text/plain; charset=utf-8", []byte("hello"), http.StatusOK},
+		{"wildcard type", "image/png", []byte("png"), http.StatusOK},
+		{"too large", "text/plain", make([]byte, 101), http.StatusRequestEntityTooLarge},
+		{"type not allowed", "application/octet-stream", []byte("hello"), http.StatusUnsupportedMediaType},
+		{"rejected by scanning", "text/plain", []byte("a virus"), http.StatusForbidden},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			scanned = nil
+			res, err := http.Post(srv.URL+"/bzz-raw:/", tc.contentType, bytes.NewReader(tc.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := ioutil.ReadAll(res.Body)
+			res.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tc.status {
+				t.Fatalf("got status %v, want %v: %s", res.StatusCode, tc.status, body)
+			}
+			if res.StatusCode != http.StatusOK {
+				return
+			}
+			if !bytes.Equal(scanned, tc.data) {
+				t.Fatalf("got scanned data %q, want %q", scanned, tc.data)
+			}
+			res, err = http.Get(srv.URL + "/bzz-raw:/" + string(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			stored, err := ioutil.ReadAll(res.Body)
+			res.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(stored, tc.data) {
+				t.Fatalf("got stored data %q, want %q", stored, tc.data)
+			}
+		})
+	}
+}
+
+// TestScanUploadsLimit checks that uploads larger than the scanning limit
+// are rejected, also if their size is not known in advance
+func TestScanUploadsLimit(t *testing.T) {
+	scanner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+	}))
+	defer scanner.Close()
+
+	srv := NewTestSwarmServer(t, func(api *api.API, pinAPI *pin.API) TestServer {
+		server := NewServer(api, pinAPI, "")
+		server.SetUploadPolicies(ScanUploads(scanner.URL, nil, 10))
+		return server
+	}, nil, nil)
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		name   string
+		body   io.Reader
+		status int
+	}{
+		{"within limit", bytes.NewReader(make([]byte, 10)), http.StatusOK},
+		{"too large", bytes.NewReader(make([]byte, 11)), http.StatusRequestEntityTooLarge},
+		{"too large without length", io.MultiReader(bytes.NewReader(make([]byte, 11))), http.StatusRequestEntityTooLarge},
+	} {
+		res, err := http.Post(srv.URL+"/bzz-raw:/", "text/plain", tc.body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != tc.status {
+			t.Fatalf("%s: got status %v, want %v", tc.name, res.StatusCode, tc.status)
+		}
+	}
+}
+
+// TestRequireHealthy checks that uploads are refused with the reason
+// while the node is not healthy
+func TestRequireHealthy(t *testing.T) {
+	var unhealthy error
+	srv := NewTestSwarmServer(t, func(api *api.API, pinAPI *pin.API) TestServer {
+		server := NewServer(api, pinAPI, "")
+		server.SetUploadPolicies(RequireHealthy(func() error {
+			return unhealthy
+		}))
+		return server
+	}, nil, nil)
+	defer srv.Close()
+
+	post := func(path string) (int, string) {
+		t.Helper()
+		res, err := http.Post(srv.URL+path, "text/plain", bytes.NewReader([]byte("hello")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.StatusCode, string(body)
+	}
+
+	unhealthy = api.ErrNoPeers
+	for _, path := range []string{"/bzz-raw:/", "/bzz:/", "/bzz-feed:/"} {
+		status, body := post(path)
+		if status != http.StatusServiceUnavailable {
+			t.Fatalf("%s: got status %v, want %v: %s", path, status, http.StatusServiceUnavailable, body)
+		}
+		if !strings.Contains(body, api.ErrNoPeers.Error()) {
+			t.Fatalf("%s: expected the reason %q in the response, got %s", path, api.ErrNoPeers, body)
+		}
+	}
+
+	unhealthy = nil
+	if status, body := post("/bzz-raw:/"); status != http.StatusOK {
+		t.Fatalf("got status %v, want %v: %s", status, http.StatusOK, body)
+	}
+}
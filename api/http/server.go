@@ -33,6 +33,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -79,6 +80,10 @@ const (
 	TagHeaderName       = "x-swarm-tag"       // Presence of this in header indicates the tag
 	AnonymousHeaderName = "x-swarm-anonymous" // Presence of this in header indicates only pull sync should be used for upload
 	PinHeaderName       = "x-swarm-pin"       // Presence of this in header indicates pinning required
+	TTLHeaderName       = "x-swarm-ttl"       // Time to live hint in seconds for the uploaded chunks, they may be garbage collected first after it passes
+	OwnerHeaderName     = "x-swarm-owner"     // Uploader the storage used by the uploaded chunks is accounted to
+
+	maxOwnerLength = 64 // longest accepted value of the OwnerHeaderName header
 
 	encryptAddr    = "encrypt"
 	tarContentType = "application/x-tar"
@@ -102,7 +107,7 @@ func NewServer(api *api.API, pinAPI *pin.API, corsString string) *Server {
 	}
 	c := cors.New(cors.Options{
 		AllowedOrigins: allowedOrigins,
-		AllowedMethods: []string{http.MethodPost, http.MethodGet, http.MethodDelete, http.MethodPatch, http.MethodPut},
+		AllowedMethods: []string{http.MethodPost, http.MethodGet, http.MethodHead, http.MethodDelete, http.MethodPatch, http.MethodPut},
 		MaxAge:         600,
 		AllowedHeaders: []string{"*"},
 	})
@@ -128,7 +133,11 @@ func NewServer(api *api.API, pinAPI *pin.API, corsString string) *Server {
 		})
 	}
 
-	defaultPostMiddlewares := append(defaultMiddlewares, tagAdapter)
+	policyAdapter := Adapter(func(h http.Handler) http.Handler {
+		return CheckUploadPolicies(h, server.getUploadPolicies)
+	})
+
+	defaultPostMiddlewares := append(defaultMiddlewares, policyAdapter, tagAdapter)
 
 	mux := http.NewServeMux()
 	mux.Handle("/bzz:/", methodHandler{
@@ -136,6 +145,10 @@ func NewServer(api *api.API, pinAPI *pin.API, corsString string) *Server {
 			http.HandlerFunc(server.HandleBzzGet),
 			defaultMiddlewares...,
 		),
+		"HEAD": Adapt(
+			http.HandlerFunc(server.HandleBzzGet),
+			defaultMiddlewares...,
+		),
 		"POST": Adapt(
 			http.HandlerFunc(server.HandlePostFiles),
 			append(defaultPostMiddlewares, pinAdapter(true))...,
@@ -150,6 +163,10 @@ func NewServer(api *api.API, pinAPI *pin.API, corsString string) *Server {
 			http.HandlerFunc(server.HandleGet),
 			defaultMiddlewares...,
 		),
+		"HEAD": Adapt(
+			http.HandlerFunc(server.HandleGet),
+			defaultMiddlewares...,
+		),
 		"POST": Adapt(
 			http.HandlerFunc(server.HandlePostRaw),
 			append(defaultPostMiddlewares, pinAdapter(true))...,
@@ -160,6 +177,10 @@ func NewServer(api *api.API, pinAPI *pin.API, corsString string) *Server {
 			http.HandlerFunc(server.HandleBzzGet),
 			defaultMiddlewares...,
 		),
+		"HEAD": Adapt(
+			http.HandlerFunc(server.HandleBzzGet),
+			defaultMiddlewares...,
+		),
 	})
 	mux.Handle("/bzz-hash:/", methodHandler{
 		"GET": Adapt(
@@ -178,9 +199,13 @@ func NewServer(api *api.API, pinAPI *pin.API, corsString string) *Server {
 			http.HandlerFunc(server.HandleGetFeed),
 			defaultMiddlewares...,
 		),
+		"HEAD": Adapt(
+			http.HandlerFunc(server.HandleGetFeed),
+			defaultMiddlewares...,
+		),
 		"POST": Adapt(
 			http.HandlerFunc(server.HandlePostFeed),
-			defaultMiddlewares...,
+			append(defaultMiddlewares, policyAdapter)...,
 		),
 	})
 	mux.Handle("/bzz-tag:/", methodHandler{
@@ -194,6 +219,10 @@ func NewServer(api *api.API, pinAPI *pin.API, corsString string) *Server {
 			http.HandlerFunc(server.HandleGetFeedRaw),
 			defaultMiddlewares...,
 		),
+		"HEAD": Adapt(
+			http.HandlerFunc(server.HandleGetFeedRaw),
+			defaultMiddlewares...,
+		),
 	})
 	mux.Handle("/bzz-pin:/", methodHandler{
 		"GET": Adapt(
@@ -232,9 +261,25 @@ func (s *Server) ListenAndServe(addr string) error {
 // https://github.com/atom/electron/blob/master/docs/api/protocol.md
 type Server struct {
 	http.Handler
-	api        *api.API
-	pinAPI     *pin.API
-	listenAddr string
+	api            *api.API
+	pinAPI         *pin.API
+	listenAddr     string
+	uploadPolicies []UploadPolicy
+	policiesMu     sync.RWMutex // protects uploadPolicies
+}
+
+// SetUploadPolicies replaces the policies which every upload
+// must be accepted by in order to be stored
+func (s *Server) SetUploadPolicies(policies ...UploadPolicy) {
+	s.policiesMu.Lock()
+	defer s.policiesMu.Unlock()
+	s.uploadPolicies = policies
+}
+
+func (s *Server) getUploadPolicies() []UploadPolicy {
+	s.policiesMu.RLock()
+	defer s.policiesMu.RUnlock()
+	return s.uploadPolicies
 }
 
 func (s *Server) HandleBzzGet(w http.ResponseWriter, r *http.Request) {
@@ -263,7 +308,10 @@ func (s *Server) HandleBzzGet(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s.tar\"", fileName))
 
 		w.WriteHeader(http.StatusOK)
-		io.Copy(w, reader)
+		// the size of the tarball is not known before it is built
+		if r.Method != http.MethodHead {
+			io.Copy(w, reader)
+		}
 		return
 	}
 
@@ -336,6 +384,11 @@ func (s *Server) HandlePostRaw(w http.ResponseWriter, r *http.Request) {
 
 	wait(r.Context())
 	tag.DoneSplit(addr)
+	if s.api.Timestamper != nil {
+		if err := s.api.Timestamper.Add(addr); err != nil {
+			log.Error("error queueing content for timestamping", "ruid", ruid, "addr", addr, "err", err)
+		}
+	}
 
 	log.Debug("stored content", "ruid", ruid, "key", addr)
 
@@ -443,6 +496,11 @@ func (s *Server) HandlePostFiles(w http.ResponseWriter, r *http.Request) {
 
 	log.Debug("done splitting, setting tag total", "SPLIT", tag.Get(chunk.StateSplit), "TOTAL", tag.TotalCounter())
 	tag.DoneSplit(newAddr)
+	if s.api.Timestamper != nil {
+		if err := s.api.Timestamper.Add(newAddr); err != nil {
+			log.Error("error queueing content for timestamping", "ruid", ruid, "addr", newAddr, "err", err)
+		}
+	}
 
 	// Pin the file
 	if strings.ToLower(headerPin) == "true" {
@@ -694,6 +752,7 @@ func (s *Server) HandleGetFeed(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		w.Header().Add("Content-type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(rawResponse)))
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprint(w, string(rawResponse))
 		return
@@ -739,7 +798,6 @@ func (s *Server) HandleGetFeedRaw(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	w.Header().Set("Content-Type", api.MimeOctetStream)
-	w.WriteHeader(http.StatusOK)
 	http.ServeContent(w, r, "", time.Now(), bytes.NewReader(data))
 }
 
@@ -764,6 +822,43 @@ func (s *Server) translateFeedError(w http.ResponseWriter, r *http.Request, supE
 	return http.StatusInternalServerError, defaultErr
 }
 
+// setCacheControl lets clients cache content addressed by hash forever, while
+// content under an ENS name or resolved through a feed has to be revalidated
+// on every request, which is cheap thanks to the ETag
+func setCacheControl(w http.ResponseWriter, uri *api.URI, mutable bool) {
+	if uri.Address() != nil && !mutable {
+		w.Header().Set("Cache-Control", "max-age=2147483648, immutable") // url was of type bzz://<hex key>/path, so we are sure it is immutable.
+		return
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+}
+
+// notModified sets the ETag of the response to the given content key and
+// responds with 304 Not Modified if the request's If-None-Match header matches it
+func notModified(w http.ResponseWriter, r *http.Request, key storage.Address) bool {
+	etag := fmt.Sprintf("%q", common.Bytes2Hex(key))
+	w.Header().Set("ETag", etag)
+	if !etagMatch(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatch reports whether an If-None-Match header value matches the etag.
+// The header may list several, possibly weak, entity tags and the weak
+// comparison is used as required for If-None-Match. Unquoted tags are
+// accepted for clients that strip the quotes.
+func etagMatch(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag || strconv.Quote(tag) == etag {
+			return true
+		}
+	}
+	return false
+}
+
 // HandleGet handles a GET request to
 // - bzz-raw://<key> and responds with the raw content stored at the
 //   given storage key
@@ -782,20 +877,14 @@ func (s *Server) HandleGet(w http.ResponseWriter, r *http.Request) {
 		respondError(w, r, fmt.Sprintf("cannot resolve %s: %s", uri.Addr, err), http.StatusNotFound)
 		return
 	}
-	w.Header().Set("Cache-Control", "max-age=2147483648, immutable") // url was of type bzz://<hex key>/path, so we are sure it is immutable.
+	setCacheControl(w, uri, false)
 
 	log.Debug("handle.get: resolved", "ruid", ruid, "key", addr)
 
 	// if path is set, interpret <key> as a manifest and return the
 	// raw entry at the given path
-	etag := common.Bytes2Hex(addr)
-	noneMatchEtag := r.Header.Get("If-None-Match")
-	w.Header().Set("ETag", fmt.Sprintf("%q", etag)) // set etag to manifest key or raw entry key.
-	if noneMatchEtag != "" {
-		if bytes.Equal(storage.Address(common.Hex2Bytes(noneMatchEtag)), addr) {
-			w.WriteHeader(http.StatusNotModified)
-			return
-		}
+	if notModified(w, r, addr) { // set etag to manifest key or raw entry key.
+		return
 	}
 
 	switch {
@@ -834,7 +923,8 @@ func (s *Server) HandleGet(w http.ResponseWriter, r *http.Request) {
 
 // HandleGetList handles a GET request to bzz-list:/<manifest>/<path> and returns
 // a list of all files contained in <manifest> under <path> grouped into
-// common prefixes using "/" as a delimiter
+// common prefixes using "/" as a delimiter. With the summary=true query
+// parameter the size and number of files under each common prefix are included
 func (s *Server) HandleGetList(w http.ResponseWriter, r *http.Request) {
 	ruid := GetRUID(r.Context())
 	uri := GetURI(r.Context())
@@ -856,7 +946,9 @@ func (s *Server) HandleGetList(w http.ResponseWriter, r *http.Request) {
 	}
 	log.Debug("handle.get.list: resolved", "ruid", ruid, "key", addr)
 
-	list, err := s.api.GetManifestList(r.Context(), s.api.Decryptor(r.Context(), credentials), addr, uri.Path)
+	// the common prefixes are summarised only on request, as it walks all manifests below them
+	summarise := strings.ToLower(r.URL.Query().Get("summary")) == "true"
+	list, err := s.api.GetManifestList(r.Context(), s.api.Decryptor(r.Context(), credentials), addr, uri.Path, summarise)
 	if err != nil {
 		getListFail.Inc(1)
 		if isDecryptError(err) {
@@ -915,23 +1007,12 @@ func (s *Server) HandleGetFile(w http.ResponseWriter, r *http.Request) {
 			respondError(w, r, fmt.Sprintf("cannot resolve %s: %s", uri.Addr, err), http.StatusNotFound)
 			return
 		}
-	} else {
-		w.Header().Set("Cache-Control", "max-age=2147483648, immutable") // url was of type bzz://<hex key>/path, so we are sure it is immutable.
 	}
 
 	log.Debug("handle.get.file: resolved", "ruid", ruid, "key", manifestAddr)
 
-	reader, contentType, status, contentKey, err := s.api.Get(r.Context(), s.api.Decryptor(r.Context(), credentials), manifestAddr, uri.Path)
-
-	etag := common.Bytes2Hex(contentKey)
-	noneMatchEtag := r.Header.Get("If-None-Match")
-	w.Header().Set("ETag", fmt.Sprintf("%q", etag)) // set etag to actual content key.
-	if noneMatchEtag != "" {
-		if bytes.Equal(storage.Address(common.Hex2Bytes(noneMatchEtag)), contentKey) {
-			w.WriteHeader(http.StatusNotModified)
-			return
-		}
-	}
+	reader, contentType, status, contentKey, mutable, err := s.api.GetMutable(r.Context(), s.api.Decryptor(r.Context(), credentials), manifestAddr, uri.Path)
+	setCacheControl(w, uri, mutable)
 
 	if err != nil {
 		if isDecryptError(err) {
@@ -954,7 +1035,7 @@ func (s *Server) HandleGetFile(w http.ResponseWriter, r *http.Request) {
 	//the request results in ambiguous files
 	//e.g. /read with readme.md and readinglist.txt available in manifest
 	if status == http.StatusMultipleChoices {
-		list, err := s.api.GetManifestList(r.Context(), s.api.Decryptor(r.Context(), credentials), manifestAddr, uri.Path)
+		list, err := s.api.GetManifestList(r.Context(), s.api.Decryptor(r.Context(), credentials), manifestAddr, uri.Path, false)
 		if err != nil {
 			getFileFail.Inc(1)
 			if isDecryptError(err) {
@@ -972,6 +1053,12 @@ func (s *Server) HandleGetFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the etag is the actual content key, which stays the same for unchanged
+	// content even if the root was resolved through ENS or a feed
+	if notModified(w, r, contentKey) {
+		return
+	}
+
 	// check the root chunk exists by retrieving the file's size
 	if _, err := reader.Size(r.Context(), nil); err != nil {
 		getFileNotFound.Inc(1)
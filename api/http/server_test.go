@@ -139,6 +139,97 @@ func TestGetTagUsingHash(t *testing.T) {
 
 }
 
+// TestTTLHeader uploads a file with a time to live hint and checks
+// that the expiry is set on the upload tag and that invalid hints are rejected
+func TestTTLHeader(t *testing.T) {
+	srv := NewTestSwarmServer(t, serverFunc, nil, nil)
+	defer srv.Close()
+
+	upload := func(ttl string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest("POST", srv.URL+"/bzz-raw:/", bytes.NewReader(testutil.RandomBytes(1, 10000)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Add(TTLHeaderName, ttl)
+		req.Header.Add("Content-Type", "text/plain")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := upload("-1")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("got status %s, want %d", resp.Status, http.StatusBadRequest)
+	}
+
+	start := time.Now()
+	resp = upload("3600")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("err %s", resp.Status)
+	}
+	uid, err := strconv.ParseUint(resp.Header.Get(TagHeaderName), 10, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := srv.Tags.Get(uint32(uid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.Expiry.Before(start.Add(time.Hour)) || tag.Expiry.After(time.Now().Add(time.Hour)) {
+		t.Fatalf("got tag expiry %v, want an hour after %v", tag.Expiry, start)
+	}
+}
+
+// TestOwnerHeader uploads a file with an owner and checks that the owner
+// is set on the upload tag and that too long owners are rejected
+func TestOwnerHeader(t *testing.T) {
+	srv := NewTestSwarmServer(t, serverFunc, nil, nil)
+	defer srv.Close()
+
+	upload := func(owner string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest("POST", srv.URL+"/bzz-raw:/", bytes.NewReader(testutil.RandomBytes(1, 10000)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Add(OwnerHeaderName, owner)
+		req.Header.Add("Content-Type", "text/plain")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := upload(strings.Repeat("a", maxOwnerLength+1))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("got status %s, want %d", resp.Status, http.StatusBadRequest)
+	}
+
+	resp = upload("alice")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("err %s", resp.Status)
+	}
+	uid, err := strconv.ParseUint(resp.Header.Get(TagHeaderName), 10, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := srv.Tags.Get(uint32(uid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.Owner != "alice" {
+		t.Fatalf("got tag owner %q, want alice", tag.Owner)
+	}
+}
+
 // TestGetTag uploads a file, retrieves the tag using http GET and check if it matches
 func TestGetTagUsingTagId(t *testing.T) {
 	srv := NewTestSwarmServer(t, serverFunc, nil, nil)
@@ -472,6 +563,23 @@ func TestBzzWithFeed(t *testing.T) {
 	if !bytes.Equal(retrievedData, dataBytes) {
 		t.Fatalf("retrieved data mismatch, expected %x, got %x", dataBytes, retrievedData)
 	}
+	// the feed can be updated, so the content must not be cached as immutable
+	if cc := resp.Header.Get("Cache-Control"); cc != "no-cache" {
+		t.Fatalf("expected feed resolved content to require revalidation, got Cache-Control %q", cc)
+	}
+
+	// the size of feed resolved content is available without the data
+	resp, err = http.Head(getBzzURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("err %s", resp.Status)
+	}
+	if cl := resp.Header.Get("Content-Length"); cl != strconv.Itoa(len(dataBytes)) {
+		t.Fatalf("expected Content-Length %d, got %q", len(dataBytes), cl)
+	}
 }
 
 // Test Swarm feeds using the raw update methods
@@ -1247,6 +1355,95 @@ func testBzzRootRedirect(toEncrypt bool, t *testing.T) {
 	}
 }
 
+// TestHead tests that HEAD requests are answered with the headers of the
+// corresponding GET request, including the Content-Length and Content-Type,
+// but without a body
+func TestHead(t *testing.T) {
+	srv := NewTestSwarmServer(t, serverFunc, nil, nil)
+	defer srv.Close()
+
+	data := testutil.RandomBytes(1, 10000)
+	res, err := http.Post(srv.URL+"/bzz-raw:/", "application/octet-stream", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawHash, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("POST", srv.URL+"/bzz:/", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifestHash, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		url         string
+		contentType string
+	}{
+		{
+			url:         fmt.Sprintf("%s/bzz-raw:/%s", srv.URL, rawHash),
+			contentType: "application/octet-stream",
+		},
+		{
+			url:         fmt.Sprintf("%s/bzz-raw:/%s?content_type=text/plain", srv.URL, rawHash),
+			contentType: "text/plain",
+		},
+		{
+			url:         fmt.Sprintf("%s/bzz:/%s/", srv.URL, manifestHash),
+			contentType: "text/plain",
+		},
+		{
+			url:         fmt.Sprintf("%s/bzz-immutable:/%s/", srv.URL, manifestHash),
+			contentType: "text/plain",
+		},
+	} {
+		res, err := http.Head(tc.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("%s: expected status %d, got %d", tc.url, http.StatusOK, res.StatusCode)
+		}
+		if len(body) != 0 {
+			t.Fatalf("%s: expected no body, got %d bytes", tc.url, len(body))
+		}
+		if cl := res.Header.Get("Content-Length"); cl != strconv.Itoa(len(data)) {
+			t.Fatalf("%s: expected Content-Length %d, got %q", tc.url, len(data), cl)
+		}
+		if ct := res.Header.Get("Content-Type"); ct != tc.contentType {
+			t.Fatalf("%s: expected Content-Type %q, got %q", tc.url, tc.contentType, ct)
+		}
+		get, err := http.Get(tc.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		get.Body.Close()
+		if ct := get.Header.Get("Content-Type"); ct != tc.contentType {
+			t.Fatalf("%s: expected the Content-Type %q of the HEAD request for GET, got %q", tc.url, tc.contentType, ct)
+		}
+		if res.Header.Get("ETag") == "" {
+			t.Fatalf("%s: expected an ETag", tc.url)
+		}
+	}
+}
+
 func TestMethodsNotAllowed(t *testing.T) {
 	srv := NewTestSwarmServer(t, serverFunc, nil, nil)
 	defer srv.Close()
@@ -1617,6 +1814,101 @@ func TestBzzGetFileWithResolver(t *testing.T) {
 	}
 }
 
+// TestBzzGetFileETag tests that files resolved through ENS are served with
+// an ETag of their content and that conditional requests are answered with
+// 304 Not Modified as long as the content did not change
+func TestBzzGetFileETag(t *testing.T) {
+	resolver := newTestResolveValidator("")
+	srv := NewTestSwarmServer(t, serverFunc, resolver, nil)
+	defer srv.Close()
+
+	upload := func(files map[string]string) common.Hash {
+		buf := &bytes.Buffer{}
+		tw := tar.NewWriter(buf)
+		for name, content := range files {
+			hdr := &tar.Header{
+				Name:    name,
+				Mode:    0644,
+				Size:    int64(len(content)),
+				ModTime: time.Now(),
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := io.WriteString(tw, content); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		res, err := http.Post(srv.URL+"/bzz:/", "application/x-tar", buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("err %s", res.Status)
+		}
+		hash, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return common.HexToHash(string(hash))
+	}
+	get := func(path, ifNoneMatch string) *http.Response {
+		req, err := http.NewRequest("GET", srv.URL+"/bzz:/dapp.eth/"+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+
+	v1 := upload(map[string]string{"index.html": "index", "app.js": "app v1"})
+	v2 := upload(map[string]string{"index.html": "index", "app.js": "app v2"})
+
+	resolver.hash = &v1
+	res := get("index.html", "")
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, res.StatusCode)
+	}
+	if cc := res.Header.Get("Cache-Control"); cc != "no-cache" {
+		t.Fatalf("expected resolved content to require revalidation, got Cache-Control %q", cc)
+	}
+	indexETag := res.Header.Get("ETag")
+	if indexETag == "" {
+		t.Fatal("expected an ETag")
+	}
+	appETag := get("app.js", "").Header.Get("ETag")
+
+	for _, ifNoneMatch := range []string{indexETag, "W/" + indexETag, appETag + ", " + indexETag} {
+		if res := get("index.html", ifNoneMatch); res.StatusCode != http.StatusNotModified {
+			t.Fatalf("If-None-Match %s: expected %d, got %d", ifNoneMatch, http.StatusNotModified, res.StatusCode)
+		}
+	}
+
+	// the name now resolves to a new version in which only app.js changed
+	resolver.hash = &v2
+	if res := get("index.html", indexETag); res.StatusCode != http.StatusNotModified {
+		t.Fatalf("expected unchanged file to be %d, got %d", http.StatusNotModified, res.StatusCode)
+	}
+	res = get("app.js", appETag)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected changed file to be %d, got %d", http.StatusOK, res.StatusCode)
+	}
+	if etag := res.Header.Get("ETag"); etag == appETag {
+		t.Fatalf("expected the ETag of a changed file to change, got %s", etag)
+	}
+}
+
 // TestCalculateNumberOfChunks is a unit test for the chunk-number-according-to-content-length
 // calculation
 func TestCalculateNumberOfChunks(t *testing.T) {
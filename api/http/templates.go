@@ -83,13 +83,13 @@ const bzzList = `{{ define "content" }}
 	</thead>
 
 	<tbody>
-		{{ range .List.CommonPrefixes }}
+		{{ range $i, $prefix := .List.CommonPrefixes }}
 		<tr>
 			<td>
-				<a class="normal-link" href="{{ basename . }}/">{{ basename . }}/</a>
+				<a class="normal-link" href="{{ basename $prefix }}/">{{ basename $prefix }}/</a>
 			</td>
 			<td>DIR</td>
-			<td>-</td>
+			<td>{{ if $.List.Prefixes }}{{ (index $.List.Prefixes $i).Size }}{{ else }}-{{ end }}</td>
 		</tr>
 		{{ end }}
 		{{ range .List.Entries }}
@@ -131,3 +131,8 @@ func (i *Inspector) PeerStreams() (string, error) {
 func (i *Inspector) StorageIndices() (map[string]int, error) {
 	return i.ls.DebugIndices()
 }
+
+// ChunkInfo returns the localstore metadata of the chunk with the given address
+func (i *Inspector) ChunkInfo(addr storage.Address) (*localstore.ChunkInfo, error) {
+	return i.ls.ChunkInfo(addr)
+}
@@ -61,8 +61,17 @@ type ManifestEntry struct {
 
 // ManifestList represents the result of listing files in a manifest
 type ManifestList struct {
-	CommonPrefixes []string         `json:"common_prefixes,omitempty"`
-	Entries        []*ManifestEntry `json:"entries,omitempty"`
+	CommonPrefixes []string          `json:"common_prefixes,omitempty"`
+	Prefixes       []*ManifestPrefix `json:"prefixes,omitempty"` // summaries of the common prefixes, in the same order
+	Entries        []*ManifestEntry  `json:"entries,omitempty"`
+}
+
+// ManifestPrefix summarises the files under a common prefix of a ManifestList
+type ManifestPrefix struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`               // cumulative size of all files under the prefix
+	Files   int       `json:"files"`              // number of files under the prefix
+	ModTime time.Time `json:"mod_time,omitempty"` // latest modification time of the files under the prefix
 }
 
 // NewManifest creates and stores a new, empty manifest
@@ -230,15 +239,38 @@ type manifestTrieEntry struct {
 
 func loadManifest(ctx context.Context, fileStore *storage.FileStore, addr storage.Address, quitC chan bool, decrypt DecryptFunc) (trie *manifestTrie, err error) { // non-recursive, subtrees are downloaded on-demand
 	log.Trace("manifest lookup", "addr", addr)
+	if cached, ok := manifests.get(addr); ok {
+		entries := make([]*manifestTrieEntry, len(cached.entries))
+		for i := range cached.entries {
+			entries[i] = newManifestTrieEntry(&cached.entries[i], nil)
+		}
+		return newManifestTrie(entries, fileStore, cached.encrypted, quitC, decrypt)
+	}
 	// retrieve manifest via FileStore
 	manifestReader, isEncrypted := fileStore.Retrieve(ctx, addr)
 	log.Trace("reader retrieved", "addr", addr)
-	return readManifest(manifestReader, addr, fileStore, isEncrypted, quitC, decrypt)
+	entries, size, err := readManifestEntries(manifestReader, addr, quitC)
+	if err != nil {
+		return nil, err
+	}
+	if size <= manifestCacheMaxSize {
+		manifests.put(addr, entries, isEncrypted)
+	}
+	return newManifestTrie(entries, fileStore, isEncrypted, quitC, decrypt)
 }
 
 func readManifest(mr storage.LazySectionReader, addr storage.Address, fileStore *storage.FileStore, isEncrypted bool, quitC chan bool, decrypt DecryptFunc) (trie *manifestTrie, err error) { // non-recursive, subtrees are downloaded on-demand
+	entries, _, err := readManifestEntries(mr, addr, quitC)
+	if err != nil {
+		return nil, err
+	}
+	return newManifestTrie(entries, fileStore, isEncrypted, quitC, decrypt)
+}
+
+// readManifestEntries reads and decodes the entries of a manifest
+func readManifestEntries(mr storage.LazySectionReader, addr storage.Address, quitC chan bool) (entries []*manifestTrieEntry, size int64, err error) {
 	// TODO check size for oversized manifests
-	size, err := mr.Size(mr.Context(), quitC)
+	size, err = mr.Size(mr.Context(), quitC)
 	if err != nil { // size == 0
 		// can't determine size means we don't have the root chunk
 		log.Trace("manifest not found", "addr", addr)
@@ -272,19 +304,22 @@ func readManifest(mr storage.LazySectionReader, addr storage.Address, fileStore
 	}
 
 	log.Trace("manifest entries", "addr", addr, "len", len(man.Entries))
+	return man.Entries, size, nil
+}
 
-	trie = &manifestTrie{
+// newManifestTrie returns a trie of the decoded entries of a manifest
+func newManifestTrie(entries []*manifestTrieEntry, fileStore *storage.FileStore, isEncrypted bool, quitC chan bool, decrypt DecryptFunc) (*manifestTrie, error) {
+	trie := &manifestTrie{
 		fileStore: fileStore,
 		encrypted: isEncrypted,
 		decrypt:   decrypt,
 	}
-	for _, entry := range man.Entries {
-		err = trie.addEntry(entry, quitC)
-		if err != nil {
-			return
+	for _, entry := range entries {
+		if err := trie.addEntry(entry, quitC); err != nil {
+			return nil, err
 		}
 	}
-	return
+	return trie, nil
 }
 
 func (mt *manifestTrie) addEntry(entry *manifestTrieEntry, quitC chan bool) error {
@@ -462,6 +497,26 @@ func (mt *manifestTrie) listWithPrefixInt(prefix, rp string, quitC chan bool, cb
 		stop = start
 	}
 
+	// fetch the sub-manifests under the prefix concurrently
+	// before descending into them in order
+	var forks []*manifestTrieEntry
+	for i := start; i <= stop; i++ {
+		entry := mt.entries[i]
+		if entry == nil || entry.ContentType != ManifestType || entry.subtrie != nil {
+			continue
+		}
+		l := plen
+		if epl := len(entry.Path); epl < l {
+			l = epl
+		}
+		if prefix[:l] == entry.Path[:l] {
+			forks = append(forks, entry)
+		}
+	}
+	if err := mt.loadSubTries(forks, quitC); err != nil {
+		return err
+	}
+
 	for i := start; i <= stop; i++ {
 		select {
 		case <-quitC:
@@ -0,0 +1,125 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/storage"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	// manifestCacheCapacity is the number of manifests kept in memory
+	manifestCacheCapacity = 512
+	// manifestCacheMaxSize is the size of the largest manifest kept in memory,
+	// which bounds the memory used by the cache
+	manifestCacheMaxSize = 128 * 1024
+	// manifestFetchWorkers is the maximal number of sub-manifests fetched concurrently
+	manifestFetchWorkers = 8
+)
+
+// manifests caches the entries of recently loaded manifests
+var manifests = newManifestCache(manifestCacheCapacity)
+
+// cachedManifest is a decoded manifest
+type cachedManifest struct {
+	entries   []ManifestEntry
+	encrypted bool
+}
+
+// manifestCache keeps the entries of recently loaded manifests by their
+// address, so that resolving paths through the same manifests does not fetch
+// them again. Manifests are content addressed, so cached entries never go stale.
+type manifestCache struct {
+	items *lru.Cache
+}
+
+func newManifestCache(capacity int) *manifestCache {
+	items, err := lru.New(capacity)
+	if err != nil {
+		panic(err)
+	}
+	return &manifestCache{
+		items: items,
+	}
+}
+
+// get returns the cached manifest with the address
+func (c *manifestCache) get(addr storage.Address) (*cachedManifest, bool) {
+	v, ok := c.items.Get(string(addr))
+	if !ok {
+		metrics.GetOrRegisterCounter("api.manifest.cache.miss", nil).Inc(1)
+		return nil, false
+	}
+	metrics.GetOrRegisterCounter("api.manifest.cache.hit", nil).Inc(1)
+	return v.(*cachedManifest), true
+}
+
+// put caches the entries of the manifest with the address. The entries are
+// copied, as the entries of a loaded trie are modified when its sub-manifests
+// are loaded.
+func (c *manifestCache) put(addr storage.Address, entries []*manifestTrieEntry, encrypted bool) {
+	m := &cachedManifest{
+		entries:   make([]ManifestEntry, len(entries)),
+		encrypted: encrypted,
+	}
+	for i, e := range entries {
+		m.entries[i] = e.ManifestEntry
+	}
+	c.items.Add(string(addr), m)
+}
+
+// purge empties the cache
+func (c *manifestCache) purge() {
+	c.items.Purge()
+}
+
+// loadSubTries loads the sub-manifests of the entries concurrently
+func (mt *manifestTrie) loadSubTries(entries []*manifestTrieEntry, quitC chan bool) error {
+	if len(entries) == 1 {
+		return mt.loadSubTrie(entries[0], quitC)
+	}
+	var (
+		wg   sync.WaitGroup
+		once sync.Once
+		err  error
+		sem  = make(chan struct{}, manifestFetchWorkers)
+	)
+	for _, entry := range entries {
+		select {
+		case sem <- struct{}{}:
+		case <-quitC:
+			wg.Wait()
+			return fmt.Errorf("aborted")
+		}
+		wg.Add(1)
+		go func(entry *manifestTrieEntry) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if e := mt.loadSubTrie(entry, quitC); e != nil {
+				once.Do(func() { err = e })
+			}
+		}(entry)
+	}
+	wg.Wait()
+	return err
+}
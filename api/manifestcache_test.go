@@ -0,0 +1,230 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/storage"
+)
+
+// newTestFileStore returns a file store in a temporary directory
+func newTestFileStore(tb testing.TB) (fileStore *storage.FileStore, cleanup func()) {
+	tb.Helper()
+	datadir, err := ioutil.TempDir("", "bzz-manifest-test")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	fileStore, clean, err := storage.NewLocalFileStore(datadir, make([]byte, 32), chunk.NewTags())
+	if err != nil {
+		os.RemoveAll(datadir)
+		tb.Fatal(err)
+	}
+	return fileStore, func() {
+		clean()
+		os.RemoveAll(datadir)
+	}
+}
+
+// storeTestManifest stores a manifest with the entries
+func storeTestManifest(tb testing.TB, fileStore *storage.FileStore, entries ...ManifestEntry) storage.Address {
+	tb.Helper()
+	data, err := json.Marshal(&Manifest{Entries: entries})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	ctx := context.Background()
+	addr, wait, err := fileStore.Store(ctx, bytes.NewReader(data), int64(len(data)), false)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if err := wait(ctx); err != nil {
+		tb.Fatal(err)
+	}
+	return addr
+}
+
+// storeDeepManifest stores a chain of depth nested manifests with a file at
+// the end and returns the root manifest and the path of the file
+func storeDeepManifest(tb testing.TB, fileStore *storage.FileStore, depth int) (storage.Address, string) {
+	addr := storeTestManifest(tb, fileStore, ManifestEntry{Hash: strings.Repeat("ab", 32), Path: "index.html", ContentType: "text/html"})
+	var path []string
+	for i := depth - 1; i >= 0; i-- {
+		dir := fmt.Sprintf("dir%d/", i)
+		addr = storeTestManifest(tb, fileStore, ManifestEntry{Hash: addr.Hex(), Path: dir, ContentType: ManifestType})
+		path = append([]string{dir}, path...)
+	}
+	return addr, strings.Join(path, "") + "index.html"
+}
+
+// storeWideManifest stores a manifest of width sub-manifests with a file each
+func storeWideManifest(tb testing.TB, fileStore *storage.FileStore, width int) storage.Address {
+	var forks []ManifestEntry
+	for i := 0; i < width; i++ {
+		// the sub-manifests must start with distinct characters to be forks of the root
+		sub := storeTestManifest(tb, fileStore, ManifestEntry{Hash: strings.Repeat("ab", 32), Path: fmt.Sprintf("file%d", i), ContentType: "text/plain"})
+		forks = append(forks, ManifestEntry{Hash: sub.Hex(), Path: fmt.Sprintf("%c/", 'A'+i), ContentType: ManifestType})
+	}
+	return storeTestManifest(tb, fileStore, forks...)
+}
+
+// TestManifestCache tests that paths are resolved through cached manifests
+// without fetching them again
+func TestManifestCache(t *testing.T) {
+	fileStore, cleanup := newTestFileStore(t)
+	defer cleanup()
+	manifests.purge()
+	defer manifests.purge()
+
+	root, path := storeDeepManifest(t, fileStore, 8)
+	trie, err := loadManifest(context.Background(), fileStore, root, nil, NOOPDecrypt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry, fullpath := trie.getEntry(path); entry == nil || fullpath != path {
+		t.Fatalf("expected entry %s, got %s", path, fullpath)
+	}
+
+	// resolving the path against a store without the manifests only succeeds from the cache
+	emptyStore, emptyCleanup := newTestFileStore(t)
+	defer emptyCleanup()
+	trie, err = loadManifest(context.Background(), emptyStore, root, nil, NOOPDecrypt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, fullpath := trie.getEntry(path)
+	if entry == nil || fullpath != path || entry.Hash != strings.Repeat("ab", 32) {
+		t.Fatalf("expected entry %s from the cache, got %s", path, fullpath)
+	}
+
+	// loading sub-manifests must not modify the cached entries
+	cached, ok := manifests.get(root)
+	if !ok || len(cached.entries) != 1 || cached.entries[0].Hash == "" {
+		t.Fatalf("expected the cached root manifest to be unmodified, got %v", cached)
+	}
+
+	manifests.purge()
+	if _, err := loadManifest(context.Background(), emptyStore, root, nil, NOOPDecrypt); err == nil {
+		t.Fatal("expected an error loading a manifest which is neither stored nor cached")
+	}
+}
+
+// TestListConcurrentForks tests that listing a manifest whose forks are
+// fetched concurrently returns all entries in order
+func TestListConcurrentForks(t *testing.T) {
+	fileStore, cleanup := newTestFileStore(t)
+	defer cleanup()
+	manifests.purge()
+	defer manifests.purge()
+
+	width := 3 * manifestFetchWorkers
+	root := storeWideManifest(t, fileStore, width)
+	trie, err := loadManifest(context.Background(), fileStore, root, nil, NOOPDecrypt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var listed []string
+	if err := trie.listWithPrefix("", nil, func(entry *manifestTrieEntry, suffix string) {
+		listed = append(listed, suffix)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != width {
+		t.Fatalf("expected %d entries, got %d", width, len(listed))
+	}
+	for i, suffix := range listed {
+		if expected := fmt.Sprintf("%c/file%d", 'A'+i, i); suffix != expected {
+			t.Fatalf("expected entry %d to be %s, got %s", i, expected, suffix)
+		}
+	}
+}
+
+func BenchmarkManifestResolve(b *testing.B) {
+	for _, depth := range []int{4, 16, 64} {
+		for _, cached := range []bool{false, true} {
+			b.Run(fmt.Sprintf("depth=%d/cached=%v", depth, cached), func(b *testing.B) {
+				benchmarkManifestResolve(b, depth, cached)
+			})
+		}
+	}
+}
+
+func benchmarkManifestResolve(b *testing.B, depth int, cached bool) {
+	fileStore, cleanup := newTestFileStore(b)
+	defer cleanup()
+	defer manifests.purge()
+	root, path := storeDeepManifest(b, fileStore, depth)
+
+	manifests.purge()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !cached {
+			manifests.purge()
+		}
+		trie, err := loadManifest(context.Background(), fileStore, root, nil, NOOPDecrypt)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if entry, _ := trie.getEntry(path); entry == nil {
+			b.Fatalf("path %s not resolved", path)
+		}
+	}
+}
+
+func BenchmarkManifestList(b *testing.B) {
+	for _, width := range []int{8, 32, 64} {
+		for _, cached := range []bool{false, true} {
+			b.Run(fmt.Sprintf("width=%d/cached=%v", width, cached), func(b *testing.B) {
+				benchmarkManifestList(b, width, cached)
+			})
+		}
+	}
+}
+
+func benchmarkManifestList(b *testing.B, width int, cached bool) {
+	fileStore, cleanup := newTestFileStore(b)
+	defer cleanup()
+	defer manifests.purge()
+	root := storeWideManifest(b, fileStore, width)
+
+	manifests.purge()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !cached {
+			manifests.purge()
+		}
+		trie, err := loadManifest(context.Background(), fileStore, root, nil, NOOPDecrypt)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var n int
+		if err := trie.listWithPrefix("", nil, func(*manifestTrieEntry, string) { n++ }); err != nil {
+			b.Fatal(err)
+		}
+		if n != width {
+			b.Fatalf("expected %d entries, got %d", width, n)
+		}
+	}
+}
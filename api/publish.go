@@ -0,0 +1,98 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethersphere/swarm/contracts/ens"
+)
+
+// Publisher updates the content hash record of a name
+type Publisher interface {
+	Publish(name string, hash common.Hash, opts ens.PublishOptions) (*types.Transaction, error)
+}
+
+// Publish updates the content hash record of the name with the first resolver
+// for its TLD which is able to publish
+func (m *MultiResolver) Publish(name string, hash common.Hash, opts ens.PublishOptions) (*types.Transaction, error) {
+	rs, err := m.getResolveValidator(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rs {
+		if p, ok := r.(Publisher); ok {
+			return p.Publish(name, hash, opts)
+		}
+	}
+	return nil, fmt.Errorf("no ENS resolver is able to publish %s", name)
+}
+
+// PublishAPI exposes publishing uploaded content to ENS names owned
+// by the node account, invoked as ens_publish.
+type PublishAPI struct {
+	publisher Publisher
+}
+
+// NewPublishAPI creates a new PublishAPI
+func NewPublishAPI(publisher Publisher) *PublishAPI {
+	return &PublishAPI{publisher: publisher}
+}
+
+// PublishResult describes the transaction updating the content hash record
+type PublishResult struct {
+	Name     string
+	Hash     common.Hash // swarm hash of the content
+	TxHash   common.Hash
+	Nonce    uint64
+	GasPrice *big.Int
+	GasLimit uint64
+	Cost     *big.Int // maximum cost of the transaction in wei
+	DryRun   bool     // if true, the transaction was signed but not sent
+}
+
+// Publish points the name to the swarm hash. gasPrice and gasLimit are
+// optional. With dryRun the transaction is only signed and described.
+func (p *PublishAPI) Publish(name string, hash common.Hash, gasPrice *hexutil.Big, gasLimit hexutil.Uint64, dryRun bool) (*PublishResult, error) {
+	if p.publisher == nil {
+		return nil, errors.New("no ENS endpoint configured")
+	}
+	opts := ens.PublishOptions{
+		GasPrice: (*big.Int)(gasPrice),
+		GasLimit: uint64(gasLimit),
+		DryRun:   dryRun,
+	}
+	tx, err := p.publisher.Publish(name, hash, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &PublishResult{
+		Name:     name,
+		Hash:     hash,
+		TxHash:   tx.Hash(),
+		Nonce:    tx.Nonce(),
+		GasPrice: tx.GasPrice(),
+		GasLimit: tx.Gas(),
+		Cost:     tx.Cost(),
+		DryRun:   dryRun,
+	}, nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Node roles name presets of settings for common kinds of nodes
+const (
+	RoleGateway = "gateway" // serves content over HTTP to the public
+	RoleStorer  = "storer"  // stores and syncs its area of responsibility
+	RoleRelay   = "relay"   // forwards requests and messages, stores little
+	RoleLight   = "light"   // consumes content without serving other nodes
+)
+
+// roles maps role names to the functions setting their presets
+var roles = map[string]func(*Config){
+	RoleGateway: func(c *Config) {
+		c.ListenAddr = "0.0.0.0"
+		c.Cors = "*"
+		c.SyncEnabled = true
+		c.PushSyncEnabled = true
+		c.SyncOnlyWithinDepth = false
+		c.LightNodeEnabled = false
+		c.AnnouncePrices = true
+		c.DbCapacity = 5000000
+		c.CacheCapacity = 100000
+		c.HandoffOnShutdown = false
+	},
+	RoleStorer: func(c *Config) {
+		c.ListenAddr = DefaultHTTPListenAddr
+		c.Cors = ""
+		c.SyncEnabled = true
+		c.PushSyncEnabled = true
+		c.SyncOnlyWithinDepth = false
+		c.LightNodeEnabled = false
+		c.AnnouncePrices = true
+		c.DbCapacity = 20000000
+		c.CacheCapacity = 0
+		c.HandoffOnShutdown = true
+	},
+	RoleRelay: func(c *Config) {
+		c.ListenAddr = DefaultHTTPListenAddr
+		c.Cors = ""
+		c.SyncEnabled = true
+		c.PushSyncEnabled = true
+		c.SyncOnlyWithinDepth = true
+		c.LightNodeEnabled = false
+		c.AnnouncePrices = true
+		c.DbCapacity = 500000
+		c.CacheCapacity = 10000
+		c.HandoffOnShutdown = true
+	},
+	RoleLight: func(c *Config) {
+		c.ListenAddr = DefaultHTTPListenAddr
+		c.Cors = ""
+		c.SyncEnabled = false
+		c.PushSyncEnabled = true
+		c.SyncOnlyWithinDepth = false
+		c.LightNodeEnabled = true
+		c.AnnouncePrices = false
+		c.DbCapacity = 100000
+		c.CacheCapacity = 0
+		c.HandoffOnShutdown = false
+	},
+}
+
+// Roles returns the names of the node roles in alphabetical order
+func Roles() []string {
+	names := make([]string, 0, len(roles))
+	for name := range roles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyRole sets the syncing, capability, HTTP exposure, swap and garbage
+// collection settings of the named role and records the role in the config.
+// Settings applied afterwards, like the ones of the config file and flags,
+// override the preset.
+func (c *Config) ApplyRole(role string) error {
+	preset, ok := roles[role]
+	if !ok {
+		return fmt.Errorf("unknown node role %q, available roles: %s", role, strings.Join(Roles(), ", "))
+	}
+	preset(c)
+	c.Role = role
+	return nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+
+package api
+
+import (
+	"testing"
+)
+
+// TestApplyRole checks that role presets set their settings
+// and that unknown roles are rejected
+func TestApplyRole(t *testing.T) {
+	for _, role := range Roles() {
+		c := NewConfig()
+		if err := c.ApplyRole(role); err != nil {
+			t.Fatalf("role %s: %v", role, err)
+		}
+		if c.Role != role {
+			t.Errorf("role %s: got role %q in config", role, c.Role)
+		}
+		if c.DbCapacity == 0 {
+			t.Errorf("role %s: expected storage capacity to be set", role)
+		}
+	}
+
+	c := NewConfig()
+	if err := c.ApplyRole(RoleLight); err != nil {
+		t.Fatal(err)
+	}
+	if !c.LightNodeEnabled || c.SyncEnabled || c.AnnouncePrices {
+		t.Errorf("expected light node without syncing and price announcements, got light %v, sync %v, prices %v", c.LightNodeEnabled, c.SyncEnabled, c.AnnouncePrices)
+	}
+	if err := c.ApplyRole(RoleGateway); err != nil {
+		t.Fatal(err)
+	}
+	if c.LightNodeEnabled || !c.SyncEnabled || c.ListenAddr != "0.0.0.0" {
+		t.Errorf("expected gateway preset to replace light node settings, got light %v, sync %v, listen address %s", c.LightNodeEnabled, c.SyncEnabled, c.ListenAddr)
+	}
+
+	if err := NewConfig().ApplyRole("archive"); err == nil {
+		t.Fatal("expected unknown role to be rejected")
+	}
+}
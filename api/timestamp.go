@@ -0,0 +1,419 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/state"
+	"github.com/ethersphere/swarm/storage"
+)
+
+const (
+	// timestampKeyPrefix prefixes the state store keys of inclusion proofs
+	timestampKeyPrefix = "timestamp_"
+	// timestampPendingKey is the state store key of the root hashes waiting to be anchored
+	timestampPendingKey = "timestamp_pending"
+	// anchorGasLimit covers a plain transaction carrying a 32 byte tree root as data
+	anchorGasLimit = 21000 + 68*common.HashLength
+	// anchorTimeout is the maximum time spent sending an anchoring transaction and waiting for it to be mined
+	anchorTimeout = 10 * time.Minute
+	// timestampLeafPrefix and timestampNodePrefix prefix the hashed leaves and inner
+	// nodes of the timestamp tree, so an inner node can not be passed off as a leaf
+	timestampLeafPrefix = 0x00
+	timestampNodePrefix = 0x01
+)
+
+var (
+	// ErrNoTimestamp is returned when no anchored inclusion proof is known for a root hash
+	ErrNoTimestamp = errors.New("no timestamp for address")
+	// ErrAnchorReverted is returned when an anchoring transaction is reverted
+	ErrAnchorReverted = errors.New("anchoring transaction reverted")
+)
+
+// Anchorer anchors a tree root on a blockchain
+type Anchorer interface {
+	// Anchor anchors the root and returns the transaction hash and the time
+	// of the block including it once the transaction is mined
+	Anchor(ctx context.Context, root common.Hash) (common.Hash, time.Time, error)
+	// Anchored returns whether the mined transaction with the hash anchors the root
+	// and the time of the block including it
+	Anchored(ctx context.Context, txHash common.Hash, root common.Hash) (time.Time, bool, error)
+}
+
+// AnchorBackend wraps the methods TxAnchorer needs to send anchoring
+// transactions and to look them up once they are mined
+type AnchorBackend interface {
+	bind.ContractBackend
+	TransactionByHash(ctx context.Context, txHash common.Hash) (*types.Transaction, bool, error)
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+	HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error)
+}
+
+// TxAnchorer anchors a tree root as the data of a transaction
+// sent by the key owner to itself
+type TxAnchorer struct {
+	backend AnchorBackend
+	key     *ecdsa.PrivateKey
+	signer  types.Signer
+}
+
+// NewTxAnchorer creates a new TxAnchorer sending transactions
+// signed with the key for the chain through the backend
+func NewTxAnchorer(backend AnchorBackend, key *ecdsa.PrivateKey, chainID *big.Int) *TxAnchorer {
+	return &TxAnchorer{
+		backend: backend,
+		key:     key,
+		signer:  types.NewEIP155Signer(chainID),
+	}
+}
+
+// Anchor sends a transaction with the root as data and waits for it to be mined
+func (a *TxAnchorer) Anchor(ctx context.Context, root common.Hash) (common.Hash, time.Time, error) {
+	from := crypto.PubkeyToAddress(a.key.PublicKey)
+	nonce, err := a.backend.PendingNonceAt(ctx, from)
+	if err != nil {
+		return common.Hash{}, time.Time{}, err
+	}
+	gasPrice, err := a.backend.SuggestGasPrice(ctx)
+	if err != nil {
+		return common.Hash{}, time.Time{}, err
+	}
+	tx, err := types.SignTx(types.NewTransaction(nonce, from, new(big.Int), anchorGasLimit, gasPrice, root[:]), a.signer, a.key)
+	if err != nil {
+		return common.Hash{}, time.Time{}, err
+	}
+	if err := a.backend.SendTransaction(ctx, tx); err != nil {
+		return common.Hash{}, time.Time{}, err
+	}
+	receipt, err := bind.WaitMined(ctx, a.backend, tx)
+	if err != nil {
+		return common.Hash{}, time.Time{}, err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return common.Hash{}, time.Time{}, ErrAnchorReverted
+	}
+	anchoredAt, err := a.blockTime(ctx, receipt)
+	if err != nil {
+		return common.Hash{}, time.Time{}, err
+	}
+	return tx.Hash(), anchoredAt, nil
+}
+
+// Anchored returns whether the transaction with the hash is mined,
+// did not revert and carries the root as data
+func (a *TxAnchorer) Anchored(ctx context.Context, txHash common.Hash, root common.Hash) (time.Time, bool, error) {
+	tx, pending, err := a.backend.TransactionByHash(ctx, txHash)
+	if err == ethereum.NotFound {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if pending || !bytes.Equal(tx.Data(), root[:]) {
+		return time.Time{}, false, nil
+	}
+	receipt, err := a.backend.TransactionReceipt(ctx, txHash)
+	if err == ethereum.NotFound || (err == nil && receipt == nil) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return time.Time{}, false, nil
+	}
+	anchoredAt, err := a.blockTime(ctx, receipt)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return anchoredAt, true, nil
+}
+
+// blockTime returns the time of the block including the receipt
+func (a *TxAnchorer) blockTime(ctx context.Context, receipt *types.Receipt) (time.Time, error) {
+	header, err := a.backend.HeaderByHash(ctx, receipt.BlockHash)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(header.Time), 0), nil
+}
+
+// TimestampProof proves that a root hash was included in a tree whose root
+// was anchored in a transaction at a point in time. AnchoredAt is the time of
+// the block including the transaction.
+type TimestampProof struct {
+	Address    storage.Address `json:"address"`
+	Root       common.Hash     `json:"root"`
+	Index      int             `json:"index"`
+	Proof      []common.Hash   `json:"proof"`
+	TxHash     common.Hash     `json:"txHash"`
+	AnchoredAt time.Time       `json:"anchoredAt"`
+}
+
+// Verify checks that the proof leads from the address to the root. It does not
+// check that the root is anchored, see Timestamper.Verify.
+func (p *TimestampProof) Verify() bool {
+	h := timestampLeaf(p.Address)
+	index := p.Index
+	for _, sibling := range p.Proof {
+		if index%2 == 0 {
+			h = timestampNode(h, sibling)
+		} else {
+			h = timestampNode(sibling, h)
+		}
+		index /= 2
+	}
+	return h == p.Root
+}
+
+func timestampLeaf(addr storage.Address) common.Hash {
+	return crypto.Keccak256Hash([]byte{timestampLeafPrefix}, addr)
+}
+
+func timestampNode(left, right common.Hash) common.Hash {
+	return crypto.Keccak256Hash([]byte{timestampNodePrefix}, left[:], right[:])
+}
+
+// timestampTree builds a Merkle tree over the addresses and returns its root and
+// the inclusion proof of every address. The last node of a level with an odd
+// number of nodes is paired with itself.
+func timestampTree(addrs []storage.Address) (common.Hash, [][]common.Hash) {
+	level := make([]common.Hash, len(addrs))
+	for i, addr := range addrs {
+		level[i] = timestampLeaf(addr)
+	}
+	proofs := make([][]common.Hash, len(addrs))
+	for width := 1; len(level) > 1; width *= 2 {
+		if len(level)%2 == 1 {
+			level = append(level, level[len(level)-1])
+		}
+		for i := range addrs {
+			node := i / width
+			proofs[i] = append(proofs[i], level[node^1])
+		}
+		next := make([]common.Hash, len(level)/2)
+		for i := range next {
+			next[i] = timestampNode(level[2*i], level[2*i+1])
+		}
+		level = next
+	}
+	return level[0], proofs
+}
+
+// Timestamper batches the root hashes of uploaded content into a Merkle tree
+// and anchors the tree root on a schedule. The inclusion proofs are kept in the
+// state store, so the existence of the content at the anchoring time can be proven.
+type Timestamper struct {
+	anchorer Anchorer
+	store    state.Store
+	interval time.Duration
+	mtx      sync.Mutex        // protects pending
+	pending  []storage.Address // root hashes waiting to be anchored
+	anchorMu sync.Mutex        // serializes anchoring
+	quit     chan struct{}
+	quitOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewTimestamper creates a new Timestamper anchoring every interval
+// and restores the root hashes that were waiting to be anchored
+func NewTimestamper(anchorer Anchorer, store state.Store, interval time.Duration) (*Timestamper, error) {
+	t := &Timestamper{
+		anchorer: anchorer,
+		store:    store,
+		interval: interval,
+		quit:     make(chan struct{}),
+	}
+	if err := store.Get(timestampPendingKey, &t.pending); err != nil && err != state.ErrNotFound {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Start starts anchoring the pending root hashes every interval
+func (t *Timestamper) Start() {
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), anchorTimeout)
+				if _, err := t.Anchor(ctx); err != nil {
+					log.Warn("timestamp anchoring failed", "err", err)
+				}
+				cancel()
+			case <-t.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops anchoring
+func (t *Timestamper) Stop() error {
+	t.quitOnce.Do(func() { close(t.quit) })
+	t.wg.Wait()
+	return nil
+}
+
+// Add queues a root hash to be anchored, unless it is already timestamped or queued.
+// The queue is persisted, so queued root hashes survive a crash.
+func (t *Timestamper) Add(addr storage.Address) error {
+	if _, err := t.Proof(addr); err == nil {
+		return nil
+	}
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	for _, a := range t.pending {
+		if bytes.Equal(a, addr) {
+			return nil
+		}
+	}
+	pending := append(t.pending, append(storage.Address{}, addr...))
+	if err := t.store.Put(timestampPendingKey, pending); err != nil {
+		return err
+	}
+	t.pending = pending
+	return nil
+}
+
+// Pending returns the number of root hashes waiting to be anchored
+func (t *Timestamper) Pending() int {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return len(t.pending)
+}
+
+// Anchor anchors the pending root hashes right away and stores their inclusion
+// proofs once the anchoring transaction is mined.
+// It returns the anchored tree root, or the zero hash if nothing was pending.
+// Root hashes remain pending if anchoring fails or their proof can not be stored.
+func (t *Timestamper) Anchor(ctx context.Context) (common.Hash, error) {
+	t.anchorMu.Lock()
+	defer t.anchorMu.Unlock()
+
+	t.mtx.Lock()
+	batch := t.pending
+	t.mtx.Unlock()
+	if len(batch) == 0 {
+		return common.Hash{}, nil
+	}
+
+	root, proofs := timestampTree(batch)
+	txHash, anchoredAt, err := t.anchorer.Anchor(ctx, root)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	log.Info("anchored timestamp root", "root", root.Hex(), "tx", txHash.Hex(), "count", len(batch))
+	var failed []storage.Address
+	var storeErr error
+	for i, addr := range batch {
+		proof := &TimestampProof{
+			Address:    addr,
+			Root:       root,
+			Index:      i,
+			Proof:      proofs[i],
+			TxHash:     txHash,
+			AnchoredAt: anchoredAt,
+		}
+		if err := t.store.Put(timestampKeyPrefix+addr.Hex(), proof); err != nil {
+			log.Error("storing timestamp proof", "addr", addr, "err", err)
+			failed = append(failed, addr)
+			storeErr = err
+		}
+	}
+
+	// root hashes added while anchoring and those without a stored proof stay pending
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.pending = append(failed, t.pending[len(batch):]...)
+	if err := t.store.Put(timestampPendingKey, t.pending); err != nil {
+		return root, err
+	}
+	return root, storeErr
+}
+
+// Verify checks that the proof leads from its address to its root, that the root
+// is anchored in the proof transaction and that the block including it has the proof time
+func (t *Timestamper) Verify(ctx context.Context, proof *TimestampProof) (bool, error) {
+	if !proof.Verify() {
+		return false, nil
+	}
+	anchoredAt, ok, err := t.anchorer.Anchored(ctx, proof.TxHash, proof.Root)
+	if err != nil || !ok {
+		return false, err
+	}
+	return anchoredAt.Equal(proof.AnchoredAt), nil
+}
+
+// Proof returns the inclusion proof of an anchored root hash
+func (t *Timestamper) Proof(addr storage.Address) (*TimestampProof, error) {
+	var proof TimestampProof
+	if err := t.store.Get(timestampKeyPrefix+addr.Hex(), &proof); err != nil {
+		if err == state.ErrNotFound {
+			return nil, ErrNoTimestamp
+		}
+		return nil, err
+	}
+	return &proof, nil
+}
+
+// TimestampAPI exposes content timestamping over RPC
+type TimestampAPI struct {
+	timestamper *Timestamper
+}
+
+// NewTimestampAPI creates a new TimestampAPI
+func NewTimestampAPI(timestamper *Timestamper) *TimestampAPI {
+	return &TimestampAPI{timestamper: timestamper}
+}
+
+// Add queues a root hash to be timestamped with the next anchoring
+func (a *TimestampAPI) Add(addr storage.Address) error {
+	return a.timestamper.Add(addr)
+}
+
+// Proof returns the inclusion proof of a timestamped root hash
+func (a *TimestampAPI) Proof(addr storage.Address) (*TimestampProof, error) {
+	return a.timestamper.Proof(addr)
+}
+
+// Verify checks an inclusion proof and that its root is anchored on-chain
+func (a *TimestampAPI) Verify(ctx context.Context, proof TimestampProof) (bool, error) {
+	return a.timestamper.Verify(ctx, &proof)
+}
+
+// Pending returns the number of root hashes waiting to be anchored
+func (a *TimestampAPI) Pending() int {
+	return a.timestamper.Pending()
+}
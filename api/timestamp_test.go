@@ -0,0 +1,275 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethersphere/swarm/state"
+	"github.com/ethersphere/swarm/storage"
+)
+
+func testTimestampAddrs(n int) []storage.Address {
+	addrs := make([]storage.Address, n)
+	for i := range addrs {
+		addrs[i] = storage.Address(crypto.Keccak256([]byte(fmt.Sprintf("content %d", i))))
+	}
+	return addrs
+}
+
+// TestTimestampTree checks that the inclusion proofs of every leaf verify against the tree root
+func TestTimestampTree(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		addrs := testTimestampAddrs(n)
+		root, proofs := timestampTree(addrs)
+		for i, addr := range addrs {
+			proof := &TimestampProof{Address: addr, Root: root, Index: i, Proof: proofs[i]}
+			if !proof.Verify() {
+				t.Fatalf("%d leaves: proof of leaf %d does not verify", n, i)
+			}
+			// the last leaf of an odd level is paired with itself, so only distinct siblings are checked
+			proof.Index ^= 1
+			if proof.Index < n && proof.Verify() {
+				t.Fatalf("%d leaves: proof of leaf %d verifies with a wrong index", n, i)
+			}
+		}
+		other := &TimestampProof{Address: testTimestampAddrs(n + 1)[n], Root: root, Proof: proofs[0]}
+		if other.Verify() {
+			t.Fatalf("%d leaves: proof of a foreign address verifies", n)
+		}
+		// an inner node can not be passed off as a leaf
+		if n > 1 {
+			node := timestampNode(timestampLeaf(addrs[0]), proofs[0][0])
+			inner := &TimestampProof{Address: node[:], Root: root, Proof: proofs[0][1:]}
+			if inner.Verify() {
+				t.Fatalf("%d leaves: proof of an inner node verifies", n)
+			}
+		}
+	}
+}
+
+// minedBackend is a simulated backend mining every transaction right away
+type minedBackend struct {
+	*backends.SimulatedBackend
+}
+
+func (b minedBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if err := b.SimulatedBackend.SendTransaction(ctx, tx); err != nil {
+		return err
+	}
+	b.Commit()
+	return nil
+}
+
+func (b minedBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	header := b.Blockchain().GetHeaderByHash(hash)
+	if header == nil {
+		return nil, ethereum.NotFound
+	}
+	return header, nil
+}
+
+// failingStore is a state store failing to store the proof of one root hash
+type failingStore struct {
+	state.Store
+	failKey string
+}
+
+func (s failingStore) Put(key string, i interface{}) error {
+	if key == s.failKey {
+		return errors.New("put failed")
+	}
+	return s.Store.Put(key, i)
+}
+
+// TestTimestamper checks that pending root hashes are anchored in a transaction carrying the
+// tree root, that their proofs are stored, and that pending root hashes survive a restart
+func TestTimestamper(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	backend := minedBackend{backends.NewSimulatedBackend(core.GenesisAlloc{
+		crypto.PubkeyToAddress(key.PublicKey): {Balance: big.NewInt(1000000000000000000)},
+	}, 8000000)}
+	defer backend.Close()
+
+	store := state.NewInmemoryStore()
+	defer store.Close()
+	ts, err := NewTimestamper(NewTxAnchorer(backend, key, params.AllEthashProtocolChanges.ChainID), store, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addrs := testTimestampAddrs(3)
+	for _, addr := range append(addrs, addrs[0]) {
+		if err := ts.Add(addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ts.Pending() != 3 {
+		t.Fatalf("expected 3 pending root hashes, got %d", ts.Pending())
+	}
+
+	ctx := context.Background()
+	root, err := ts.Anchor(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts.Pending() != 0 {
+		t.Fatalf("expected no pending root hashes after anchoring, got %d", ts.Pending())
+	}
+
+	for _, addr := range addrs {
+		proof, err := ts.Proof(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if proof.Root != root || !proof.Verify() {
+			t.Fatalf("invalid proof for %v", addr)
+		}
+		tx, pending, err := backend.TransactionByHash(ctx, proof.TxHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pending || common.BytesToHash(tx.Data()) != root {
+			t.Fatalf("expected mined transaction anchoring root %v, got data %x", root.Hex(), tx.Data())
+		}
+		receipt, err := backend.TransactionReceipt(ctx, proof.TxHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header := backend.Blockchain().GetHeaderByHash(receipt.BlockHash)
+		if !proof.AnchoredAt.Equal(time.Unix(int64(header.Time), 0)) {
+			t.Fatalf("expected proof time to be the block time %v, got %v", header.Time, proof.AnchoredAt)
+		}
+		if ok, err := ts.Verify(ctx, proof); err != nil || !ok {
+			t.Fatalf("expected proof for %v to verify on-chain, got %v, %v", addr, ok, err)
+		}
+	}
+
+	// proofs whose root is not anchored in their transaction do not verify
+	proof, err := ts.Proof(addrs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	unanchored := &TimestampProof{Address: addrs[0], Root: timestampLeaf(addrs[0]), TxHash: proof.TxHash}
+	if ok, err := ts.Verify(ctx, unanchored); err != nil || ok {
+		t.Fatalf("expected proof with an unanchored root not to verify, got %v, %v", ok, err)
+	}
+	backdated := *proof
+	backdated.AnchoredAt = proof.AnchoredAt.Add(-time.Hour)
+	if ok, err := ts.Verify(ctx, &backdated); err != nil || ok {
+		t.Fatalf("expected proof with a wrong anchoring time not to verify, got %v, %v", ok, err)
+	}
+	proof.TxHash = common.Hash{1}
+	if ok, err := ts.Verify(ctx, proof); err != nil || ok {
+		t.Fatalf("expected proof with an unknown transaction not to verify, got %v, %v", ok, err)
+	}
+
+	// already timestamped content is not queued again
+	if err := ts.Add(addrs[1]); err != nil {
+		t.Fatal(err)
+	}
+	if ts.Pending() != 0 {
+		t.Fatalf("expected timestamped root hash not to be queued, got %d pending", ts.Pending())
+	}
+	if _, err := ts.Proof(testTimestampAddrs(4)[3]); err != ErrNoTimestamp {
+		t.Fatalf("expected ErrNoTimestamp, got %v", err)
+	}
+
+	// queued root hashes are persisted right away and survive a restart
+	if err := ts.Add(testTimestampAddrs(4)[3]); err != nil {
+		t.Fatal(err)
+	}
+	ts, err = NewTimestamper(NewTxAnchorer(backend, key, params.AllEthashProtocolChanges.ChainID), store, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts.Pending() != 1 {
+		t.Fatalf("expected pending root hash to be restored, got %d pending", ts.Pending())
+	}
+
+	// stopping twice does not panic
+	ts.Start()
+	ts.Stop()
+	ts.Stop()
+}
+
+// TestTimestamperStoreFailure checks that a root hash whose proof can not be
+// stored stays pending and is anchored again
+func TestTimestamperStoreFailure(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	backend := minedBackend{backends.NewSimulatedBackend(core.GenesisAlloc{
+		crypto.PubkeyToAddress(key.PublicKey): {Balance: big.NewInt(1000000000000000000)},
+	}, 8000000)}
+	defer backend.Close()
+
+	addrs := testTimestampAddrs(3)
+	store := failingStore{Store: state.NewInmemoryStore(), failKey: timestampKeyPrefix + addrs[1].Hex()}
+	defer store.Close()
+	ts, err := NewTimestamper(NewTxAnchorer(backend, key, params.AllEthashProtocolChanges.ChainID), store, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, addr := range addrs {
+		if err := ts.Add(addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	if _, err := ts.Anchor(ctx); err == nil {
+		t.Fatal("expected anchoring to report the failure to store a proof")
+	}
+	if ts.Pending() != 1 {
+		t.Fatalf("expected the root hash without a stored proof to stay pending, got %d pending", ts.Pending())
+	}
+	if _, err := ts.Proof(addrs[1]); err != ErrNoTimestamp {
+		t.Fatalf("expected ErrNoTimestamp, got %v", err)
+	}
+	for _, addr := range []storage.Address{addrs[0], addrs[2]} {
+		if _, err := ts.Proof(addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store.failKey = ""
+	ts.store = store
+	if _, err := ts.Anchor(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ts.Pending() != 0 {
+		t.Fatalf("expected no pending root hashes, got %d", ts.Pending())
+	}
+	proof, err := ts.Proof(addrs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := ts.Verify(ctx, proof); err != nil || !ok {
+		t.Fatalf("expected proof to verify on-chain, got %v, %v", ok, err)
+	}
+}
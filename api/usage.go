@@ -0,0 +1,45 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"github.com/ethersphere/swarm/storage/localstore"
+)
+
+// UsageAPI exposes the local storage used by every uploader to node
+// operators, invoked as usage_owners and usage_owner. Uploaders are
+// identified by the owner set on their upload, which is not
+// authenticated, so the usage can only be attributed, not enforced,
+// until uploads carry a verifiable identity.
+type UsageAPI struct {
+	ls *localstore.DB
+}
+
+// NewUsageAPI creates a new UsageAPI
+func NewUsageAPI(ls *localstore.DB) *UsageAPI {
+	return &UsageAPI{ls: ls}
+}
+
+// Owners returns the number of chunks and bytes stored for every owner
+func (u *UsageAPI) Owners() map[string]localstore.OwnerUsage {
+	return u.ls.Usage()
+}
+
+// Owner returns the number of chunks and bytes stored for the owner
+func (u *UsageAPI) Owner(owner string) localstore.OwnerUsage {
+	return u.ls.OwnerUsage(owner)
+}
@@ -294,17 +294,17 @@ func NewValidatorStore(store Store, validators ...Validator) (s *ValidatorStore)
 // all provided chunks must be validated with true by one of the validators.
 func (s *ValidatorStore) Put(ctx context.Context, mode ModePut, chs ...Chunk) (exist []bool, err error) {
 	for _, ch := range chs {
-		if !s.validate(ch) {
+		if !s.Validate(ch) {
 			return nil, ErrChunkInvalid
 		}
 	}
 	return s.Store.Put(ctx, mode, chs...)
 }
 
-// validate returns true if one of the validators
+// Validate returns true if one of the validators
 // return true. If all validators return false,
 // the chunk is considered invalid.
-func (s *ValidatorStore) validate(ch Chunk) bool {
+func (s *ValidatorStore) Validate(ch Chunk) bool {
 	for _, v := range s.validators {
 		if v.Validate(ch) {
 			return true
@@ -0,0 +1,119 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package chunk
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// EventType is the lifecycle stage of a chunk reported by an Event
+type EventType string
+
+const (
+	EventReceived EventType = "received" // chunk received from the network and stored for the first time
+	EventStored   EventType = "stored"   // chunk created by local upload and stored for the first time
+	EventPushed   EventType = "pushed"   // chunk sent to its neighbourhood by push syncing
+	EventSynced   EventType = "synced"   // chunk receipt or sync confirmation received
+	EventRemoved  EventType = "removed"  // chunk removed from the local store
+)
+
+// Event describes a single step in the lifecycle of a chunk
+type Event struct {
+	Type    EventType
+	Address Address
+	Time    time.Time
+	Reason  string // mode or subsystem that caused the event, e.g. "Sync", "SyncPush", "gc"
+	Count   int    // for synced events, the number of confirmations received so far
+}
+
+// Events is an in-process publish/subscribe hub for chunk lifecycle events.
+// Publishing never blocks: events are dropped for subscribers that are not
+// keeping up. A nil *Events is valid and discards all events, so components
+// do not need to check whether instrumentation is enabled.
+type Events struct {
+	mu   sync.RWMutex
+	subs map[chan Event]struct{}
+}
+
+// NewEvents creates a new chunk lifecycle event hub
+func NewEvents() *Events {
+	return &Events{
+		subs: make(map[chan Event]struct{}),
+	}
+}
+
+// Emit publishes an event of the given type for every address
+func (e *Events) Emit(typ EventType, reason string, addrs ...Address) {
+	e.emit(typ, reason, 0, addrs...)
+}
+
+// EmitSynced publishes a synced event with the number of confirmations received for the address
+func (e *Events) EmitSynced(reason string, addr Address, count int) {
+	e.emit(EventSynced, reason, count, addr)
+}
+
+func (e *Events) emit(typ EventType, reason string, count int, addrs ...Address) {
+	if e == nil {
+		return
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if len(e.subs) == 0 {
+		return
+	}
+	now := time.Now()
+	for _, addr := range addrs {
+		ev := Event{
+			Type:    typ,
+			Address: addr,
+			Time:    now,
+			Reason:  reason,
+			Count:   count,
+		}
+		for c := range e.subs {
+			select {
+			case c <- ev:
+			default:
+				metrics.GetOrRegisterCounter("chunk.events.dropped", nil).Inc(1)
+			}
+		}
+	}
+}
+
+// Subscribe returns a channel receiving chunk lifecycle events and a function
+// to cancel the subscription. The buffer argument sets the channel capacity.
+func (e *Events) Subscribe(buffer int) (c <-chan Event, unsubscribe func()) {
+	ch := make(chan Event, buffer)
+
+	e.mu.Lock()
+	e.subs[ch] = struct{}{}
+	e.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			e.mu.Lock()
+			delete(e.subs, ch)
+			e.mu.Unlock()
+			close(ch)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package chunk
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestEvents checks that emitted events are delivered to all subscribers,
+// that slow subscribers do not block the publisher and that
+// unsubscribed channels are closed.
+func TestEvents(t *testing.T) {
+	e := NewEvents()
+
+	c1, unsubscribe1 := e.Subscribe(10)
+	c2, unsubscribe2 := e.Subscribe(1)
+	defer unsubscribe2()
+
+	addr1 := Address([]byte{1})
+	addr2 := Address([]byte{2})
+	e.Emit(EventStored, "Upload", addr1, addr2)
+	e.EmitSynced("receipt", addr1, 3)
+
+	for i, want := range []Event{
+		{Type: EventStored, Address: addr1, Reason: "Upload"},
+		{Type: EventStored, Address: addr2, Reason: "Upload"},
+		{Type: EventSynced, Address: addr1, Reason: "receipt", Count: 3},
+	} {
+		got := <-c1
+		if got.Type != want.Type || !bytes.Equal(got.Address, want.Address) || got.Reason != want.Reason || got.Count != want.Count {
+			t.Fatalf("event %d: got %+v, want %+v", i, got, want)
+		}
+		if got.Time.IsZero() {
+			t.Fatalf("event %d: time not set", i)
+		}
+	}
+
+	// the second subscriber buffers only the first event
+	if got := <-c2; !bytes.Equal(got.Address, addr1) {
+		t.Fatalf("got address %v, want %v", got.Address, addr1)
+	}
+	select {
+	case ev := <-c2:
+		t.Fatalf("unexpected event %+v", ev)
+	default:
+	}
+
+	unsubscribe1()
+	unsubscribe1()
+	if _, ok := <-c1; ok {
+		t.Fatal("expected closed channel after unsubscribe")
+	}
+	e.Emit(EventRemoved, "gc", addr1)
+	if got := <-c2; got.Type != EventRemoved {
+		t.Fatalf("got event type %q, want %q", got.Type, EventRemoved)
+	}
+}
+
+// TestEventsNil checks that emitting on a nil hub is a noop.
+func TestEventsNil(t *testing.T) {
+	var e *Events
+	e.Emit(EventStored, "Upload", Address([]byte{1}))
+	e.EmitSynced("receipt", Address([]byte{1}), 1)
+}
@@ -39,11 +39,12 @@ var (
 type State = uint32
 
 const (
-	StateSplit  State = iota // chunk has been processed by filehasher/swarm safe call
-	StateStored              // chunk stored locally
-	StateSeen                // chunk previously seen
-	StateSent                // chunk sent to neighbourhood
-	StateSynced              // proof is received; chunk removed from sync db; chunk is available everywhere
+	StateSplit     State = iota // chunk has been processed by filehasher/swarm safe call
+	StateStored                 // chunk stored locally
+	StateSeen                   // chunk previously seen
+	StateSent                   // chunk sent to neighbourhood
+	StateSynced                 // proof is received; chunk removed from sync db; chunk is available everywhere
+	StateReceipted              // statement of custody receipt received from a storer node in the neighbourhood
 )
 
 // Tag represents info on the status of new chunks
@@ -54,12 +55,17 @@ type Tag struct {
 	Stored int64 // number of chunks already stored locally
 	Sent   int64 // number of chunks sent for push syncing
 	Synced int64 // number of chunks synced with proof
+	// number of chunks with a push-sync receipt from a storer node,
+	// chunks stored by the uploader as the closest node are not receipted
+	Receipted int64
 
 	Uid       uint32    // a unique identifier for this tag
 	Anonymous bool      // indicates if the tag is anonymous (i.e. if only pull sync should be used)
 	Name      string    // a name tag for this tag
 	Address   Address   // the associated swarm hash for this tag
 	StartedAt time.Time // tag started to calculate ETA
+	Expiry    time.Time // time to live hint for the uploaded chunks, zero if they should persist
+	Owner     string    // uploader the stored chunks are accounted to, empty if they are not accounted
 
 	// end-to-end tag tracing
 	ctx      context.Context  // tracing context
@@ -109,6 +115,8 @@ func (t *Tag) IncN(state State, n int) {
 		v = &t.Sent
 	case StateSynced:
 		v = &t.Synced
+	case StateReceipted:
+		v = &t.Receipted
 	}
 	atomic.AddInt64(v, int64(n))
 }
@@ -132,6 +140,8 @@ func (t *Tag) Get(state State) int64 {
 		v = &t.Sent
 	case StateSynced:
 		v = &t.Synced
+	case StateReceipted:
+		v = &t.Receipted
 	}
 	return atomic.LoadInt64(v)
 }
@@ -185,7 +195,7 @@ func (t *Tag) Status(state State) (int64, int64, error) {
 	switch state {
 	case StateSplit, StateStored, StateSeen:
 		return count, total, nil
-	case StateSent, StateSynced:
+	case StateSent, StateSynced, StateReceipted:
 		stored := atomic.LoadInt64(&t.Stored)
 		if stored < total {
 			return count, total - seen, errNA
@@ -219,6 +229,7 @@ func (tag *Tag) MarshalBinary() (data []byte, err error) {
 	encodeInt64Append(&buffer, tag.Stored)
 	encodeInt64Append(&buffer, tag.Sent)
 	encodeInt64Append(&buffer, tag.Synced)
+	encodeInt64Append(&buffer, tag.Receipted)
 
 	intBuffer := make([]byte, 8)
 
@@ -247,6 +258,7 @@ func (tag *Tag) UnmarshalBinary(buffer []byte) error {
 	tag.Stored = decodeInt64Splice(&buffer)
 	tag.Sent = decodeInt64Splice(&buffer)
 	tag.Synced = decodeInt64Splice(&buffer)
+	tag.Receipted = decodeInt64Splice(&buffer)
 
 	t, n := binary.Varint(buffer)
 	tag.StartedAt = time.Unix(t, 0)
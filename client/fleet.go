@@ -0,0 +1,295 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/storage/localstore"
+)
+
+// Fleet is a client of the RPC APIs of multiple Swarm nodes run by the same operator.
+// It combines the views of the nodes and sends commands to all of them.
+//
+// Views are combined from the nodes that responded. If some of the nodes fail,
+// the combined view is returned together with a *FleetError listing the failures.
+type Fleet struct {
+	nodes []*FleetNode
+}
+
+// FleetNode is a named RPC client of a node in the fleet
+type FleetNode struct {
+	Name   string
+	Client *rpc.Client
+}
+
+// NewFleet is a constructor for a Fleet of already connected nodes
+func NewFleet(nodes ...*FleetNode) *Fleet {
+	return &Fleet{
+		nodes: nodes,
+	}
+}
+
+// DialFleet connects to the RPC endpoints of the nodes, which are named by their endpoints
+func DialFleet(ctx context.Context, endpoints ...string) (*Fleet, error) {
+	f := NewFleet()
+	for _, endpoint := range endpoints {
+		client, err := rpc.DialContext(ctx, endpoint)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("dial %s: %v", endpoint, err)
+		}
+		f.nodes = append(f.nodes, &FleetNode{Name: endpoint, Client: client})
+	}
+	return f, nil
+}
+
+// Nodes returns the nodes of the fleet
+func (f *Fleet) Nodes() []*FleetNode {
+	return f.nodes
+}
+
+// Close closes the RPC clients of all nodes
+func (f *Fleet) Close() {
+	for _, n := range f.nodes {
+		n.Client.Close()
+	}
+}
+
+// NodeError is an error returned by a node of the fleet
+type NodeError struct {
+	Node string
+	Err  error
+}
+
+func (e *NodeError) Error() string {
+	return e.Node + ": " + e.Err.Error()
+}
+
+// FleetError lists the errors of the nodes that failed a fleet call
+type FleetError struct {
+	Errors []*NodeError
+	Nodes  int // number of nodes called
+}
+
+func (e *FleetError) Error() string {
+	errs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d of %d nodes failed: %s", len(e.Errors), e.Nodes, strings.Join(errs, "; "))
+}
+
+// each calls fn concurrently for every node with the index of the node
+// and returns a *FleetError if any of the calls failed
+func (f *Fleet) each(ctx context.Context, fn func(ctx context.Context, i int, n *FleetNode) error) error {
+	errs := make([]error, len(f.nodes))
+	var wg sync.WaitGroup
+	for i, n := range f.nodes {
+		wg.Add(1)
+		go func(i int, n *FleetNode) {
+			defer wg.Done()
+			errs[i] = fn(ctx, i, n)
+		}(i, n)
+	}
+	wg.Wait()
+
+	var nodeErrs []*NodeError
+	for i, err := range errs {
+		if err != nil {
+			nodeErrs = append(nodeErrs, &NodeError{Node: f.nodes[i].Name, Err: err})
+		}
+	}
+	if nodeErrs != nil {
+		return &FleetError{Errors: nodeErrs, Nodes: len(f.nodes)}
+	}
+	return nil
+}
+
+// NodeBalances are the SWAP balances of a node
+type NodeBalances struct {
+	Available uint64             // chequebook balance available for new cheques
+	Total     int64              // sum of the balances with peers
+	Peers     map[enode.ID]int64 // balances with peers
+}
+
+// FleetBalances are the SWAP balances of the fleet
+type FleetBalances struct {
+	Available uint64 // sum of the available chequebook balances
+	Total     int64  // sum of the balances with peers of all nodes
+	Nodes     map[string]*NodeBalances
+}
+
+// Balances returns the SWAP balances of all nodes and their sums
+func (f *Fleet) Balances(ctx context.Context) (*FleetBalances, error) {
+	nodes := make([]*NodeBalances, len(f.nodes))
+	err := f.each(ctx, func(ctx context.Context, i int, n *FleetNode) error {
+		b := new(NodeBalances)
+		if err := n.Client.CallContext(ctx, &b.Available, "swap_availableBalance"); err != nil {
+			return err
+		}
+		if err := n.Client.CallContext(ctx, &b.Peers, "swap_balances"); err != nil {
+			return err
+		}
+		for _, balance := range b.Peers {
+			b.Total += balance
+		}
+		nodes[i] = b
+		return nil
+	})
+	balances := &FleetBalances{
+		Nodes: make(map[string]*NodeBalances),
+	}
+	for i, b := range nodes {
+		if b == nil {
+			continue
+		}
+		balances.Available += b.Available
+		balances.Total += b.Total
+		balances.Nodes[f.nodes[i].Name] = b
+	}
+	return balances, err
+}
+
+// NodeStorage is the local storage usage of a node in number of chunks
+type NodeStorage struct {
+	Chunks   uint64 // stored chunks
+	Pinned   uint64 // pinned chunks
+	GCSize   uint64 // chunks subject to garbage collection
+	Capacity uint64 // gc index size that triggers garbage collection
+}
+
+// FleetStorage is the local storage usage of the fleet
+type FleetStorage struct {
+	Total NodeStorage // sums of the storage usage of all nodes
+	Nodes map[string]*NodeStorage
+}
+
+// Storage returns the local storage usage of all nodes and their sums
+func (f *Fleet) Storage(ctx context.Context) (*FleetStorage, error) {
+	nodes := make([]*NodeStorage, len(f.nodes))
+	err := f.each(ctx, func(ctx context.Context, i int, n *FleetNode) error {
+		var indices map[string]int
+		if err := n.Client.CallContext(ctx, &indices, "bzz_storageIndices"); err != nil {
+			return err
+		}
+		var stats localstore.GCStats
+		if err := n.Client.CallContext(ctx, &stats, "debug_gcStats"); err != nil {
+			return err
+		}
+		nodes[i] = &NodeStorage{
+			Chunks:   uint64(indices["retrievalDataIndex"]),
+			Pinned:   uint64(indices["pinIndex"]),
+			GCSize:   uint64(indices["gcSize"]),
+			Capacity: stats.Capacity,
+		}
+		return nil
+	})
+	storage := &FleetStorage{
+		Nodes: make(map[string]*NodeStorage),
+	}
+	for i, s := range nodes {
+		if s == nil {
+			continue
+		}
+		storage.Total.Chunks += s.Chunks
+		storage.Total.Pinned += s.Pinned
+		storage.Total.GCSize += s.GCSize
+		storage.Total.Capacity += s.Capacity
+		storage.Nodes[f.nodes[i].Name] = s
+	}
+	return storage, err
+}
+
+// FleetPeer is a peer connected to nodes of the fleet
+type FleetPeer struct {
+	Address string   // hex encoded overlay address
+	Nodes   []string // names of the nodes connected to the peer
+	Member  bool     // true if the peer is a node of the fleet itself
+}
+
+// Peers returns the union of the connected peers of all nodes ordered by address
+func (f *Fleet) Peers(ctx context.Context) ([]*FleetPeer, error) {
+	infos := make([]*network.KademliaInfo, len(f.nodes))
+	err := f.each(ctx, func(ctx context.Context, i int, n *FleetNode) error {
+		info := new(network.KademliaInfo)
+		if err := n.Client.CallContext(ctx, info, "bzz_kademliaInfo"); err != nil {
+			return err
+		}
+		infos[i] = info
+		return nil
+	})
+
+	members := make(map[string]bool)
+	for _, info := range infos {
+		if info != nil {
+			members[info.Self] = true
+		}
+	}
+	peers := make(map[string]*FleetPeer)
+	for i, info := range infos {
+		if info == nil {
+			continue
+		}
+		for _, bin := range info.Connections {
+			for _, addr := range bin {
+				p, ok := peers[addr]
+				if !ok {
+					p = &FleetPeer{Address: addr, Member: members[addr]}
+					peers[addr] = p
+				}
+				p.Nodes = append(p.Nodes, f.nodes[i].Name)
+			}
+		}
+	}
+	result := make([]*FleetPeer, 0, len(peers))
+	for _, p := range peers {
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Address < result[j].Address
+	})
+	return result, err
+}
+
+// PauseSync stops syncing on all nodes
+func (f *Fleet) PauseSync(ctx context.Context) error {
+	return f.each(ctx, func(ctx context.Context, _ int, n *FleetNode) error {
+		return n.Client.CallContext(ctx, nil, "stream_pauseSync")
+	})
+}
+
+// ResumeSync resumes syncing on all nodes
+func (f *Fleet) ResumeSync(ctx context.Context) error {
+	return f.each(ctx, func(ctx context.Context, _ int, n *FleetNode) error {
+		return n.Client.CallContext(ctx, nil, "stream_resumeSync")
+	})
+}
+
+// SetThresholds changes the SWAP payment and disconnect thresholds on all nodes
+func (f *Fleet) SetThresholds(ctx context.Context, paymentThreshold, disconnectThreshold int64) error {
+	return f.each(ctx, func(ctx context.Context, _ int, n *FleetNode) error {
+		return n.Client.CallContext(ctx, nil, "swap_setThresholds", paymentThreshold, disconnectThreshold)
+	})
+}
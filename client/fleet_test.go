@@ -0,0 +1,170 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/storage/localstore"
+)
+
+// testFleetNode implements the RPC methods used by the fleet client
+type testFleetNode struct {
+	self        string
+	connections [][]string
+	available   uint64
+	balances    map[enode.ID]int64
+	chunks      int
+	capacity    uint64
+	paused      bool
+	thresholds  [2]int64
+	err         error
+}
+
+func (n *testFleetNode) AvailableBalance() (uint64, error) { return n.available, n.err }
+
+func (n *testFleetNode) Balances() (map[enode.ID]int64, error) { return n.balances, n.err }
+
+func (n *testFleetNode) SetThresholds(payment, disconnect int64) error {
+	n.thresholds = [2]int64{payment, disconnect}
+	return n.err
+}
+
+func (n *testFleetNode) StorageIndices() (map[string]int, error) {
+	return map[string]int{"retrievalDataIndex": n.chunks, "pinIndex": 1, "gcSize": n.chunks - 1}, n.err
+}
+
+func (n *testFleetNode) KademliaInfo() network.KademliaInfo {
+	return network.KademliaInfo{Self: n.self, Connections: n.connections}
+}
+
+func (n *testFleetNode) GcStats() (localstore.GCStats, error) {
+	return localstore.GCStats{Capacity: n.capacity}, n.err
+}
+
+func (n *testFleetNode) PauseSync() { n.paused = true }
+
+func (n *testFleetNode) ResumeSync() { n.paused = false }
+
+func newTestFleet(t *testing.T, nodes map[string]*testFleetNode) *Fleet {
+	t.Helper()
+	f := NewFleet()
+	for _, name := range []string{"a", "b"} {
+		server := rpc.NewServer()
+		for _, namespace := range []string{"swap", "bzz", "debug", "stream"} {
+			if err := server.RegisterName(namespace, nodes[name]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		f.nodes = append(f.nodes, &FleetNode{Name: name, Client: rpc.DialInProc(server)})
+	}
+	return f
+}
+
+// TestFleet tests the combined views and commands of a fleet of two nodes
+func TestFleet(t *testing.T) {
+	peer1 := enode.HexID("1000000000000000000000000000000000000000000000000000000000000000")
+	peer2 := enode.HexID("2000000000000000000000000000000000000000000000000000000000000000")
+	nodes := map[string]*testFleetNode{
+		"a": {
+			self:        "aa",
+			connections: [][]string{{"bb", "cc"}},
+			available:   100,
+			balances:    map[enode.ID]int64{peer1: 10, peer2: -3},
+			chunks:      50,
+			capacity:    1000,
+		},
+		"b": {
+			self:        "bb",
+			connections: [][]string{{"aa"}, {"cc"}},
+			available:   200,
+			balances:    map[enode.ID]int64{peer1: 5},
+			chunks:      70,
+			capacity:    2000,
+		},
+	}
+	f := newTestFleet(t, nodes)
+	defer f.Close()
+	ctx := context.Background()
+
+	balances, err := f.Balances(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balances.Available != 300 || balances.Total != 12 || balances.Nodes["a"].Total != 7 {
+		t.Errorf("got balances %+v", balances)
+	}
+
+	storage, err := f.Storage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := NodeStorage{Chunks: 120, Pinned: 2, GCSize: 118, Capacity: 3000}
+	if storage.Total != want {
+		t.Errorf("got total storage %+v, want %+v", storage.Total, want)
+	}
+
+	peers, err := f.Peers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPeers := []*FleetPeer{
+		{Address: "aa", Nodes: []string{"b"}, Member: true},
+		{Address: "bb", Nodes: []string{"a"}, Member: true},
+		{Address: "cc", Nodes: []string{"a", "b"}},
+	}
+	if !reflect.DeepEqual(peers, wantPeers) {
+		t.Errorf("got peers %+v, want %+v", peers, wantPeers)
+	}
+
+	if err := f.PauseSync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !nodes["a"].paused || !nodes["b"].paused {
+		t.Error("expected syncing to be paused on all nodes")
+	}
+	if err := f.ResumeSync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if nodes["a"].paused || nodes["b"].paused {
+		t.Error("expected syncing to be resumed on all nodes")
+	}
+
+	// a failing node does not prevent the view of the others
+	nodes["b"].err = errors.New("swap disabled")
+	if err := f.SetThresholds(ctx, 10, 20); err == nil {
+		t.Fatal("expected error")
+	} else if fe, ok := err.(*FleetError); !ok || len(fe.Errors) != 1 || fe.Errors[0].Node != "b" {
+		t.Fatalf("got error %v", err)
+	}
+	if nodes["a"].thresholds != [2]int64{10, 20} {
+		t.Errorf("got thresholds %v on node a", nodes["a"].thresholds)
+	}
+	balances, err = f.Balances(ctx)
+	if _, ok := err.(*FleetError); !ok {
+		t.Fatalf("got error %v, want fleet error", err)
+	}
+	if balances.Available != 100 || len(balances.Nodes) != 1 {
+		t.Errorf("got balances %+v", balances)
+	}
+}
@@ -18,12 +18,14 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"math/rand"
 	"time"
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/smoke"
 	"github.com/ethersphere/swarm/testutil"
 	"github.com/pborman/uuid"
 
@@ -50,6 +52,7 @@ func slidingWindowCmd(ctx *cli.Context) error {
 }
 
 func slidingWindow(ctx *cli.Context) error {
+	c := cluster()
 	var hashes []uploadResult //swarm hashes of the uploads
 	nodes := len(hosts)
 	log.Info("sliding window test started", "nodes", nodes, "filesize(kb)", filesize, "timeout", timeout)
@@ -60,13 +63,13 @@ func slidingWindow(ctx *cli.Context) error {
 outer:
 	for {
 		seed = int(time.Now().UTC().UnixNano())
-		log.Info("uploading to "+httpEndpoint(hosts[0])+" and syncing", "seed", seed)
+		log.Info("uploading to "+c.HTTPEndpoint(hosts[0])+" and syncing", "seed", seed)
 
 		t1 := time.Now()
 
 		randomBytes := testutil.RandomBytes(seed, filesize*1000)
 
-		hash, err := upload(randomBytes, httpEndpoint(hosts[0]))
+		hash, err := smoke.Upload(randomBytes, c.HTTPEndpoint(hosts[0]))
 		if err != nil {
 			log.Error(err.Error())
 			return err
@@ -75,7 +78,7 @@ outer:
 		metrics.GetOrRegisterResettingTimer("sliding-window.upload-time", nil).UpdateSince(t1)
 		metrics.GetOrRegisterGauge("sliding-window.upload-depth", nil).Update(int64(len(hashes)))
 
-		fhash, err := digest(bytes.NewReader(randomBytes))
+		fhash, err := smoke.Digest(bytes.NewReader(randomBytes))
 		if err != nil {
 			log.Error(err.Error())
 			return err
@@ -85,7 +88,10 @@ outer:
 		hashes = append(hashes, uploadResult{hash: hash, digest: fhash})
 
 		if syncDelay {
-			waitToSync()
+			if err := c.WaitPullSynced(context.Background()); err != nil {
+				log.Error(err.Error())
+				return err
+			}
 		}
 
 		uploadedBytes += filesize * 1000
@@ -111,7 +117,7 @@ outer:
 							start = time.Now()
 							// fetch hangs when swarm dies out, so we have to jump through a bit more hoops to actually
 							// catch the timeout, but also allow this retry logic
-							err := fetch(v.hash, httpEndpoint(hosts[idx]), v.digest, ruid)
+							err := c.Fetch(context.Background(), v.hash, c.HTTPEndpoint(hosts[idx]), v.digest, ruid)
 							if err != nil {
 								log.Error("error fetching hash", "err", err)
 								continue
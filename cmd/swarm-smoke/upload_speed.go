@@ -23,6 +23,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/smoke"
 	"github.com/ethersphere/swarm/testutil"
 
 	cli "gopkg.in/urfave/cli.v1"
@@ -55,14 +56,14 @@ func uploadSpeedCmd(ctx *cli.Context) error {
 
 func uploadSpeed(c *cli.Context, data []byte) error {
 	t1 := time.Now()
-	hash, err := upload(data, hosts[0])
+	hash, err := smoke.Upload(data, cluster().HTTPEndpoint(hosts[0]))
 	if err != nil {
 		log.Error(err.Error())
 		return err
 	}
 	metrics.GetOrRegisterCounter("upload-speed.upload-time", nil).Inc(int64(time.Since(t1)))
 
-	fhash, err := digest(bytes.NewReader(data))
+	fhash, err := smoke.Digest(bytes.NewReader(data))
 	if err != nil {
 		log.Error(err.Error())
 		return err
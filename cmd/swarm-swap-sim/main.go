@@ -0,0 +1,115 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of Swarm.
+//
+// Swarm is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Swarm is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Swarm. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethersphere/swarm/simulation/swapeconomics"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+var gitCommit string // Git SHA1 commit hash of the release (set via linker flags)
+var gitDate string
+
+func main() {
+	err := newApp().Run(os.Args)
+	if err != nil {
+		log.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+// newApp construct a new instance of Swarm Swap Simulator.
+func newApp() (app *cli.App) {
+	app = utils.NewApp(gitCommit, gitDate, "Swarm Swap Simulator")
+
+	app.Name = "swarm-swap-sim"
+	app.Usage = "estimate cheque volumes, cashing frequency and gas costs of swap parameters"
+
+	defaults := swapeconomics.NewParams()
+	app.Flags = []cli.Flag{
+		cli.IntFlag{Name: "nodes", Value: defaults.Nodes, Usage: "number of nodes"},
+		cli.IntFlag{Name: "peers", Value: defaults.Peers, Usage: "number of peers of a joining node"},
+		cli.IntFlag{Name: "steps", Value: defaults.Steps, Usage: "number of simulated steps"},
+		cli.DurationFlag{Name: "step", Value: defaults.Step, Usage: "simulated time of a step"},
+		cli.IntFlag{Name: "requests", Value: defaults.Requests, Usage: "mean number of chunks a node retrieves in a step"},
+		cli.Float64Flag{Name: "gateway-ratio", Value: defaults.GatewayRatio, Usage: "fraction of nodes acting as gateways"},
+		cli.IntFlag{Name: "gateway-factor", Value: defaults.GatewayFactor, Usage: "traffic multiplier of gateways"},
+		cli.Float64Flag{Name: "churn", Value: defaults.Churn, Usage: "probability of a node being replaced in a step"},
+		cli.Uint64Flag{Name: "chunk-size", Value: defaults.ChunkSize, Usage: "size of delivered chunks in bytes"},
+		cli.Uint64Flag{Name: "retrieve-price", Value: defaults.RetrievePricing.Base, Usage: "honey price of a retrieve request at proximity order 0"},
+		cli.Uint64Flag{Name: "delivery-price", Value: defaults.ChunkDeliveryPrice, Usage: "honey price of a delivered byte"},
+		cli.Int64Flag{Name: "payment-threshold", Value: defaults.PaymentThreshold, Usage: "honey debt at which a cheque is sent"},
+		cli.Int64Flag{Name: "disconnect-threshold", Value: defaults.DisconnectThreshold, Usage: "honey credit at which requests are refused"},
+		cli.Int64Flag{Name: "grace-allowance", Value: defaults.GraceAllowance, Usage: "honey balance within which debts of peers decay"},
+		cli.Int64Flag{Name: "grace-decay-rate", Value: defaults.GraceDecayRate, Usage: "honey per second by which balances within the grace allowance decay"},
+		cli.Uint64Flag{Name: "honey-price", Value: defaults.HoneyPrice, Usage: "wei per honey"},
+		cli.Uint64Flag{Name: "gas-price", Value: defaults.GasPrice, Usage: "wei per gas"},
+		cli.Uint64Flag{Name: "cash-gas", Value: defaults.CashGas, Usage: "gas of a cheque cashing transaction"},
+		cli.Int64Flag{Name: "seed", Value: defaults.Seed, Usage: "seed of the random traffic, topology and churn"},
+		cli.BoolFlag{Name: "json", Usage: "print the report as JSON"},
+	}
+	app.Action = run
+
+	return app
+}
+
+// run simulates the network with the parameters given by the flags and prints the report
+func run(ctx *cli.Context) error {
+	params := swapeconomics.NewParams()
+	params.Nodes = ctx.Int("nodes")
+	params.Peers = ctx.Int("peers")
+	params.Steps = ctx.Int("steps")
+	params.Step = ctx.Duration("step")
+	params.Requests = ctx.Int("requests")
+	params.GatewayRatio = ctx.Float64("gateway-ratio")
+	params.GatewayFactor = ctx.Int("gateway-factor")
+	params.Churn = ctx.Float64("churn")
+	params.ChunkSize = ctx.Uint64("chunk-size")
+	if ctx.IsSet("retrieve-price") {
+		base := ctx.Uint64("retrieve-price")
+		params.RetrievePricing.Base = base
+		params.RetrievePricing.Discount = base / 16
+		params.RetrievePricing.Min = base / 4
+	}
+	params.ChunkDeliveryPrice = ctx.Uint64("delivery-price")
+	params.PaymentThreshold = ctx.Int64("payment-threshold")
+	params.DisconnectThreshold = ctx.Int64("disconnect-threshold")
+	params.GraceAllowance = ctx.Int64("grace-allowance")
+	params.GraceDecayRate = ctx.Int64("grace-decay-rate")
+	params.HoneyPrice = ctx.Uint64("honey-price")
+	params.GasPrice = ctx.Uint64("gas-price")
+	params.CashGas = ctx.Uint64("cash-gas")
+	params.Seed = ctx.Int64("seed")
+
+	report, err := swapeconomics.Run(params)
+	if err != nil {
+		return err
+	}
+	if ctx.Bool("json") {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	fmt.Print(report)
+	return nil
+}
@@ -0,0 +1,473 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	bzzapi "github.com/ethersphere/swarm/api"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+	"golang.org/x/crypto/scrypt"
+	"gopkg.in/urfave/cli.v1"
+)
+
+const (
+	backupVersion  = 1
+	backupManifest = "manifest.json"
+	backupKeystore = "keystore"
+	backupBzzDir   = "bzz"
+	backupConfig   = "config.toml"
+)
+
+// backupDatabases are the databases of the swarm account data directory in a
+// backup, the local chunk database is not backed up as chunks can be synced again
+var backupDatabases = []string{
+	"swap.db",        // swap balances, cheques and the chequebook address
+	"state-store.db", // pins, feed owner sets, tags, address book and stream intervals
+}
+
+var errBackupPassword = errors.New("wrong password or corrupted backup")
+
+var backupCommand = cli.Command{
+	Name:               "backup",
+	CustomHelpTemplate: helpTemplate,
+	Usage:              "back up or restore the identity and the state of the node",
+	ArgsUsage:          "backup COMMAND",
+	Description:        "Back up or restore the identity and the state of the node. Stop the node before backing it up or restoring it.",
+	Subcommands: []cli.Command{
+		{
+			Action:             backupCreate,
+			CustomHelpTemplate: helpTemplate,
+			Name:               "create",
+			Usage:              "write an encrypted backup of the identity and the state of the node",
+			ArgsUsage:          "<file>",
+			Description: `Write an encrypted backup of the identity and the state of the node to a file.
+
+    swarm --datadir ~/.ethereum --bzzaccount ADDR backup create swarm.bak
+
+The backup holds the key file of the swarm account, the node key, the swap
+balances and cheques, pins, feed owner sets and the other node state, and
+the configuration. The local chunk database is not backed up. The backup is
+encrypted with a password, which is asked for unless it is given with
+--password.`,
+		},
+		{
+			Action:             backupRestore,
+			CustomHelpTemplate: helpTemplate,
+			Name:               "restore",
+			Usage:              "restore the identity and the state of a node from a backup",
+			ArgsUsage:          "<file>",
+			Description: `Restore the identity and the state of a node from a backup.
+
+    swarm --datadir ~/.ethereum backup restore swarm.bak
+
+Nothing is overwritten, the node key and the state of the swarm account must
+not exist in the data directory. The local chunk database is empty until
+chunks are synced again, pinned content is fetched again with swarm pin add.`,
+		},
+	},
+}
+
+// backupManifestFile describes the content of a backup
+type backupManifestFile struct {
+	Version uint8          `json:"version"`
+	Created time.Time      `json:"created"`
+	Account common.Address `json:"account"`
+	Files   []string       `json:"files"`
+}
+
+// backupHeader precedes the encrypted archive in a backup file
+type backupHeader struct {
+	Version   uint8             `json:"version"`
+	KdfParams *bzzapi.KdfParams `json:"kdf"`
+	Salt      []byte            `json:"salt"`
+	Nonce     []byte            `json:"nonce"`
+}
+
+func backupCreate(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) != 1 {
+		utils.Fatalf("need the file to write the backup to")
+	}
+	bzzconfig, cfg, stack := newIdentityNode(ctx)
+	defer stack.Close()
+
+	if cfg.DataDir == "" {
+		utils.Fatalf("the data directory does not exist, there is nothing to back up")
+	}
+	if !common.IsHexAddress(bzzconfig.BzzAccount) {
+		utils.Fatalf(SwarmErrNoBZZAccount)
+	}
+	address := common.HexToAddress(bzzconfig.BzzAccount)
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	account, err := ks.Find(accounts.Account{Address: address})
+	if err != nil {
+		utils.Fatalf("can't find the swarm account %s in the keystore: %v", address.Hex(), err)
+	}
+	bzzDir := filepath.Join(stack.InstanceDir(), "bzz-"+common.Bytes2Hex(address.Bytes()))
+
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	manifest := &backupManifestFile{
+		Version: backupVersion,
+		Created: time.Now().UTC(),
+		Account: address,
+	}
+	add := func(name, file string) {
+		if err := addBackupFile(tw, name, file); err != nil {
+			utils.Fatalf("error backing up %s: %v", file, err)
+		}
+		manifest.Files = append(manifest.Files, name)
+	}
+
+	add(path.Join(backupKeystore, filepath.Base(account.URL.Path)), account.URL.Path)
+	if keyfile := cfg.ResolvePath(nodeKeyFile); fileExists(keyfile) {
+		add(nodeKeyFile, keyfile)
+	}
+	for _, db := range backupDatabases {
+		dir := filepath.Join(bzzDir, db)
+		if !fileExists(dir) {
+			continue
+		}
+		names, err := addBackupDB(tw, path.Join(backupBzzDir, db), dir)
+		if err != nil {
+			utils.Fatalf("error backing up %s, is the node stopped? %v", dir, err)
+		}
+		manifest.Files = append(manifest.Files, names...)
+	}
+	config, err := tomlSettings.Marshal(bzzconfig)
+	if err != nil {
+		utils.Fatalf("error backing up the configuration: %v", err)
+	}
+	if err := addBackupData(tw, backupConfig, config); err != nil {
+		utils.Fatalf("error backing up the configuration: %v", err)
+	}
+	manifest.Files = append(manifest.Files, backupConfig)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		utils.Fatalf("error writing backup manifest: %v", err)
+	}
+	if err := addBackupData(tw, backupManifest, data); err != nil {
+		utils.Fatalf("error writing backup manifest: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		utils.Fatalf("error writing backup: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		utils.Fatalf("error writing backup: %v", err)
+	}
+
+	password := getPassPhrase("Your backup is encrypted with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
+	f, err := os.OpenFile(args[0], os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		utils.Fatalf("error creating backup file: %v", err)
+	}
+	if err := sealBackup(f, archive.Bytes(), password); err != nil {
+		f.Close()
+		os.Remove(args[0])
+		utils.Fatalf("error writing backup: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		utils.Fatalf("error writing backup: %v", err)
+	}
+
+	w := os.Stdout
+	fmt.Fprintf(w, "account:  %s\n", address.Hex())
+	fmt.Fprintf(w, "files:    %d\n", len(manifest.Files))
+	fmt.Fprintf(w, "backup written to %s\n", args[0])
+}
+
+func backupRestore(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) != 1 {
+		utils.Fatalf("need the backup file to restore")
+	}
+	sealed, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		utils.Fatalf("error reading backup: %v", err)
+	}
+	password := getPassPhrase("", false, 0, utils.MakePasswordList(ctx))
+	archive, err := openBackup(sealed, password)
+	if err != nil {
+		utils.Fatalf("error opening backup: %v", err)
+	}
+	manifest, files, err := readBackup(archive)
+	if err != nil {
+		utils.Fatalf("error reading backup: %v", err)
+	}
+
+	_, cfg, stack := newIdentityNode(ctx)
+	defer stack.Close()
+	if cfg.DataDir == "" {
+		utils.Fatalf("the data directory does not exist, create it before restoring")
+	}
+	_, _, keydir, err := cfg.AccountConfig()
+	if err != nil {
+		utils.Fatalf("can't resolve the keystore directory: %v", err)
+	}
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	bzzDir := filepath.Join(stack.InstanceDir(), "bzz-"+common.Bytes2Hex(manifest.Account.Bytes()))
+
+	// all targets are checked before anything is written
+	targets := make(map[string]string)
+	for _, name := range manifest.Files {
+		var target string
+		switch {
+		case strings.HasPrefix(name, backupKeystore+"/"):
+			if ks.HasAddress(manifest.Account) {
+				continue
+			}
+			target = filepath.Join(keydir, path.Base(name))
+		case name == nodeKeyFile:
+			target = cfg.ResolvePath(nodeKeyFile)
+		case strings.HasPrefix(name, backupBzzDir+"/"):
+			target = filepath.Join(bzzDir, filepath.FromSlash(strings.TrimPrefix(name, backupBzzDir+"/")))
+		case name == backupConfig:
+			target = filepath.Join(bzzDir, backupConfig)
+		default:
+			utils.Fatalf("unknown file %s in backup", name)
+		}
+		if fileExists(target) {
+			utils.Fatalf("%s exists, restore into a data directory without the node key and the state of account %s", target, manifest.Account.Hex())
+		}
+		targets[name] = target
+	}
+	for _, db := range backupDatabases {
+		if dir := filepath.Join(bzzDir, db); fileExists(dir) {
+			utils.Fatalf("%s exists, restore into a data directory without the state of account %s", dir, manifest.Account.Hex())
+		}
+	}
+
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		target := targets[name]
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			utils.Fatalf("error restoring %s: %v", target, err)
+		}
+		if err := ioutil.WriteFile(target, files[name], 0600); err != nil {
+			utils.Fatalf("error restoring %s: %v", target, err)
+		}
+	}
+
+	w := os.Stdout
+	fmt.Fprintf(w, "account:  %s\n", manifest.Account.Hex())
+	fmt.Fprintf(w, "created:  %s\n", manifest.Created.Format(time.RFC3339))
+	fmt.Fprintf(w, "restored: %d files\n", len(targets))
+	fmt.Fprintf(w, "config:   %s\n", filepath.Join(bzzDir, backupConfig))
+	fmt.Fprintln(w, "consequences:")
+	fmt.Fprintf(w, "  - start the node with --%s %s\n", SwarmAccountFlag.Name, manifest.Account.Hex())
+	fmt.Fprintln(w, "  - the local chunk database is empty, pinned content is fetched again with swarm pin add")
+	fmt.Fprintln(w, "  - balances changed since the backup are reconciled with peers when they connect")
+}
+
+// fileExists returns whether a file or directory exists at the path
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// addBackupFile adds the file to the archive with the name
+func addBackupFile(tw *tar.Writer, name, file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return addBackupData(tw, name, data)
+}
+
+// addBackupData adds the data to the archive as a file with the name
+func addBackupData(tw *tar.Writer, name string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// addBackupDB adds the files of the leveldb database in the directory to the
+// archive under the name and returns their names. The database is locked while
+// it is added, which fails if a node has it open.
+func addBackupDB(tw *tar.Writer, name, dir string) (names []string, err error) {
+	stor, err := storage.OpenFile(dir, true)
+	if err != nil {
+		return nil, err
+	}
+	defer stor.Close()
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		// the lock and the info logs are not part of the database
+		if info.IsDir() || info.Name() == "LOCK" || strings.HasPrefix(info.Name(), "LOG") {
+			continue
+		}
+		n := path.Join(name, info.Name())
+		if err := addBackupFile(tw, n, filepath.Join(dir, info.Name())); err != nil {
+			return nil, err
+		}
+		names = append(names, n)
+	}
+	return names, nil
+}
+
+// readBackup returns the manifest and the files of the archive
+func readBackup(archive []byte) (*backupManifestFile, map[string][]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, nil, err
+	}
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if path.Clean(hdr.Name) != hdr.Name || path.IsAbs(hdr.Name) || strings.HasPrefix(hdr.Name, "..") {
+			return nil, nil, fmt.Errorf("invalid file name %q", hdr.Name)
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, nil, err
+		}
+		files[hdr.Name] = data
+	}
+	data, ok := files[backupManifest]
+	if !ok {
+		return nil, nil, errors.New("no manifest")
+	}
+	var manifest backupManifestFile
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+	if manifest.Version != backupVersion {
+		return nil, nil, fmt.Errorf("unsupported backup version %d", manifest.Version)
+	}
+	for _, name := range manifest.Files {
+		if _, ok := files[name]; !ok {
+			return nil, nil, fmt.Errorf("file %s of the manifest is missing", name)
+		}
+	}
+	return &manifest, files, nil
+}
+
+// sealBackup writes the archive encrypted with a key derived from the password
+func sealBackup(w io.Writer, archive []byte, password string) error {
+	header := &backupHeader{
+		Version:   backupVersion,
+		KdfParams: bzzapi.DefaultKdfParams,
+		Salt:      make([]byte, 32),
+	}
+	if _, err := io.ReadFull(rand.Reader, header.Salt); err != nil {
+		return err
+	}
+	aead, err := backupCipher(password, header)
+	if err != nil {
+		return err
+	}
+	header.Nonce = make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, header.Nonce); err != nil {
+		return err
+	}
+	data, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	// the header is authenticated, so that the kdf parameters can not be changed
+	sealed := aead.Seal(nil, header.Nonce, archive, data)
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	_, err = w.Write(sealed)
+	return err
+}
+
+// openBackup returns the archive of the backup decrypted with the password
+func openBackup(sealed []byte, password string) ([]byte, error) {
+	data, err := bufio.NewReader(bytes.NewReader(sealed)).ReadBytes('\n')
+	if err != nil {
+		return nil, errors.New("not a swarm backup")
+	}
+	var header backupHeader
+	if err := json.Unmarshal(data[:len(data)-1], &header); err != nil || header.KdfParams == nil {
+		return nil, errors.New("not a swarm backup")
+	}
+	if header.Version != backupVersion {
+		return nil, fmt.Errorf("unsupported backup version %d", header.Version)
+	}
+	aead, err := backupCipher(password, &header)
+	if err != nil {
+		return nil, err
+	}
+	if len(header.Nonce) != aead.NonceSize() {
+		return nil, errors.New("not a swarm backup")
+	}
+	archive, err := aead.Open(nil, header.Nonce, sealed[len(data):], data[:len(data)-1])
+	if err != nil {
+		return nil, errBackupPassword
+	}
+	return archive, nil
+}
+
+// backupCipher returns the cipher of a backup with the key derived from the password
+func backupCipher(password string, header *backupHeader) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(password), header.Salt, header.KdfParams.N, header.KdfParams.R, header.KdfParams.P, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
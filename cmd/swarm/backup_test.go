@@ -0,0 +1,156 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethersphere/swarm/state"
+	"github.com/ethersphere/swarm/testutil"
+)
+
+// TestBackupCreateRestore tests that the swarm account, the node key and the
+// state of a node are restored from a backup into an empty data directory
+func TestBackupCreateRestore(t *testing.T) {
+	if runtime.GOOS == goosWindows {
+		t.Skip()
+	}
+	dir, err := ioutil.TempDir("", "swarm-backup-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	srcDir := filepath.Join(dir, "src")
+	dstDir := filepath.Join(dir, "dst")
+	if err := os.Mkdir(dstDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	backupFile := filepath.Join(dir, "swarm.bak")
+
+	stack, err := node.New(&node.Config{
+		DataDir: srcDir,
+		NoUSB:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := ks.ImportECDSA(key, testPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stack.Close()
+
+	nodeKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(srcDir, clientIdentifier), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := crypto.SaveECDSA(filepath.Join(srcDir, clientIdentifier, nodeKeyFile), nodeKey); err != nil {
+		t.Fatal(err)
+	}
+	bzzDir := func(datadir string) string {
+		return filepath.Join(datadir, clientIdentifier, "bzz-"+common.Bytes2Hex(account.Address.Bytes()))
+	}
+	for _, db := range backupDatabases {
+		store, err := state.NewDBStore(filepath.Join(bzzDir(srcDir), db))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Put("backup_test", db); err != nil {
+			t.Fatal(err)
+		}
+		store.Close()
+	}
+	if err := os.MkdirAll(filepath.Join(bzzDir(srcDir), "chunks"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	passwordFile := testutil.TempFileWithContent(t, testPassphrase)
+	defer os.Remove(passwordFile)
+	wrongPasswordFile := testutil.TempFileWithContent(t, "wrong")
+	defer os.Remove(wrongPasswordFile)
+
+	create := runSwarm(t, "--datadir", srcDir, "--bzzaccount", account.Address.Hex(), "--password", passwordFile, "backup", "create", backupFile)
+	create.ExpectRegexp(`(?s)account:\s+` + account.Address.Hex() + `.*backup written to`)
+	create.ExpectExit()
+
+	wrong := runSwarm(t, "--datadir", dstDir, "--password", wrongPasswordFile, "backup", "restore", backupFile)
+	wrong.ExpectRegexp(errBackupPassword.Error())
+	wrong.ExpectExit()
+	if fileExists(filepath.Join(dstDir, clientIdentifier)) {
+		t.Fatal("restored with the wrong password")
+	}
+
+	restore := runSwarm(t, "--datadir", dstDir, "--password", passwordFile, "backup", "restore", backupFile)
+	restore.ExpectRegexp(`(?s)account:\s+` + account.Address.Hex() + `.*restored:`)
+	restore.ExpectExit()
+
+	keyfiles, err := filepath.Glob(filepath.Join(dstDir, "keystore", "*"+common.Bytes2Hex(account.Address.Bytes())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keyfiles) != 1 {
+		t.Fatalf("got %d key files of the account, want 1", len(keyfiles))
+	}
+	restoredKey, err := crypto.LoadECDSA(filepath.Join(dstDir, clientIdentifier, nodeKeyFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crypto.PubkeyToAddress(restoredKey.PublicKey) != crypto.PubkeyToAddress(nodeKey.PublicKey) {
+		t.Fatal("node key not restored")
+	}
+	for _, db := range backupDatabases {
+		store, err := state.NewDBStore(filepath.Join(bzzDir(dstDir), db))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var value string
+		err = store.Get("backup_test", &value)
+		store.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != db {
+			t.Fatalf("got %q from %s, want %q", value, db, db)
+		}
+	}
+	if fileExists(filepath.Join(bzzDir(dstDir), "chunks")) {
+		t.Fatal("local chunk database backed up")
+	}
+	if !fileExists(filepath.Join(bzzDir(dstDir), backupConfig)) {
+		t.Fatal("configuration not restored")
+	}
+
+	again := runSwarm(t, "--datadir", dstDir, "--password", passwordFile, "backup", "restore", backupFile)
+	again.ExpectRegexp(`exists, restore into a data directory without the node key and the state of account 0x[0-9a-fA-F]{40}\n`)
+	again.ExpectExit()
+}
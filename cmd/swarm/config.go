@@ -18,15 +18,19 @@ package main
 
 import (
 	"crypto/ecdsa"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"net/url"
 	"os"
 	"reflect"
 	"strings"
 	"unicode"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	cli "gopkg.in/urfave/cli.v1"
 
 	"github.com/ethereum/go-ethereum/cmd/utils"
@@ -36,6 +40,11 @@ import (
 
 	bzzapi "github.com/ethersphere/swarm/api"
 	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/p2p/protocols"
+	"github.com/ethersphere/swarm/pss/bridge"
+	"github.com/ethersphere/swarm/pss/message"
+	"github.com/ethersphere/swarm/shed"
+	"github.com/ethersphere/swarm/swap"
 )
 
 var (
@@ -50,46 +59,163 @@ var (
 		Description: `The dumpconfig command shows configuration values.`,
 	}
 
+	configCommand = cli.Command{
+		Name:               "config",
+		CustomHelpTemplate: helpTemplate,
+		Usage:              "inspect and check swarm configuration",
+		ArgsUsage:          "config COMMAND",
+		Description:        "Inspect and check swarm configuration",
+		Subcommands: []cli.Command{
+			{
+				Action:             utils.MigrateFlags(configDump),
+				CustomHelpTemplate: helpTemplate,
+				Name:               "dump",
+				Flags:              []cli.Flag{SwarmConfigFormatFlag},
+				Usage:              "print the effective configuration",
+				ArgsUsage:          "",
+				Description: `
+Print the effective configuration, built from the defaults, the --config file,
+command line flags and environment variables, in the same way as the node does
+on startup.
+
+    swarm --config bzz.toml --bzznetworkid 5 config dump --format json
+`,
+			},
+			{
+				Action:             configValidate,
+				CustomHelpTemplate: helpTemplate,
+				Name:               "validate",
+				Usage:              "check a configuration file",
+				ArgsUsage:          "<file>",
+				Description: `
+Check that a TOML configuration file only sets known fields with values of the
+right type, and that the resulting configuration is valid. All problems found
+are reported, the command exits with an error if there are any.
+
+    swarm config validate bzz.toml
+`,
+			},
+		},
+	}
+
 	//flag definition for the config file command
 	SwarmTomlConfigPathFlag = cli.StringFlag{
 		Name:  "config",
 		Usage: "TOML configuration file",
 	}
+
+	//flag definition for the output format of the config dump command
+	SwarmConfigFormatFlag = cli.StringFlag{
+		Name:  "format",
+		Usage: "Output format of the configuration, toml or json",
+		Value: "toml",
+	}
 )
 
 //constants for environment variables
 const (
-	SwarmEnvAccount                 = "SWARM_ACCOUNT"
-	SwarmEnvBzzKeyHex               = "SWARM_BZZ_KEY_HEX"
-	SwarmEnvListenAddr              = "SWARM_LISTEN_ADDR"
-	SwarmEnvPort                    = "SWARM_PORT"
-	SwarmEnvNetworkID               = "SWARM_NETWORK_ID"
-	SwarmEnvChequebookAddr          = "SWARM_CHEQUEBOOK_ADDR"
-	SwarmEnvChequebookFactoryAddr   = "SWARM_SWAP_CHEQUEBOOK_FACTORY_ADDR"
-	SwarmEnvSwapSkipDeposit         = "SWARM_SWAP_SKIP_DEPOSIT"
-	SwarmEnvSwapDepositAmount       = "SWARM_SWAP_DEPOSIT_AMOUNT"
-	SwarmEnvSwapEnable              = "SWARM_SWAP_ENABLE"
-	SwarmEnvSwapBackendURL          = "SWARM_SWAP_BACKEND_URL"
-	SwarmEnvSwapPaymentThreshold    = "SWARM_SWAP_PAYMENT_THRESHOLD"
-	SwarmEnvSwapDisconnectThreshold = "SWARM_SWAP_DISCONNECT_THRESHOLD"
-	SwarmNoSync                     = "SWARM_NO_SYNC"
-	SwarmEnvSwapLogPath             = "SWARM_SWAP_LOG_PATH"
-	SwarmEnvLightNodeEnable         = "SWARM_LIGHT_NODE_ENABLE"
-	SwarmEnvENSAPI                  = "SWARM_ENS_API"
-	SwarmEnvRNSAPI                  = "SWARM_RNS_API"
-	SwarmEnvENSAddr                 = "SWARM_ENS_ADDR"
-	SwarmEnvCORS                    = "SWARM_CORS"
-	SwarmEnvBootnodes               = "SWARM_BOOTNODES"
-	SwarmEnvPSSEnable               = "SWARM_PSS_ENABLE"
-	SwarmEnvStorePath               = "SWARM_STORE_PATH"
-	SwarmEnvStoreCapacity           = "SWARM_STORE_CAPACITY"
-	SwarmEnvStoreCacheCapacity      = "SWARM_STORE_CACHE_CAPACITY"
-	SwarmEnvBootnodeMode            = "SWARM_BOOTNODE_MODE"
-	SwarmEnvNATInterface            = "SWARM_NAT_INTERFACE"
-	SwarmAccessPassword             = "SWARM_ACCESS_PASSWORD"
-	SwarmAutoDefaultPath            = "SWARM_AUTO_DEFAULTPATH"
-	SwarmGlobalstoreAPI             = "SWARM_GLOBALSTORE_API"
-	GethEnvDataDir                  = "GETH_DATADIR"
+	SwarmEnvAccount                     = "SWARM_ACCOUNT"
+	SwarmEnvBzzKeyHex                   = "SWARM_BZZ_KEY_HEX"
+	SwarmEnvListenAddr                  = "SWARM_LISTEN_ADDR"
+	SwarmEnvPort                        = "SWARM_PORT"
+	SwarmEnvUnderlays                   = "SWARM_UNDERLAYS"
+	SwarmEnvWSUnderlayAddr              = "SWARM_WS_UNDERLAY_ADDR"
+	SwarmEnvWSUnderlayCert              = "SWARM_WS_UNDERLAY_CERT"
+	SwarmEnvWSUnderlayKey               = "SWARM_WS_UNDERLAY_KEY"
+	SwarmEnvWSUnderlayNoDial            = "SWARM_WS_UNDERLAY_NO_DIAL"
+	SwarmEnvNetworkID                   = "SWARM_NETWORK_ID"
+	SwarmEnvNetworkForkID               = "SWARM_NETWORK_FORK_ID"
+	SwarmEnvNetworkKey                  = "SWARM_NETWORK_KEY"
+	SwarmEnvHandshakeRateLimit          = "SWARM_HANDSHAKE_RATE_LIMIT"
+	SwarmEnvHandshakePuzzle             = "SWARM_HANDSHAKE_PUZZLE"
+	SwarmEnvChunkEvents                 = "SWARM_CHUNK_EVENTS"
+	SwarmEnvChequebookAddr              = "SWARM_CHEQUEBOOK_ADDR"
+	SwarmEnvChequebookFactoryAddr       = "SWARM_SWAP_CHEQUEBOOK_FACTORY_ADDR"
+	SwarmEnvSwapSkipDeposit             = "SWARM_SWAP_SKIP_DEPOSIT"
+	SwarmEnvSwapDepositAmount           = "SWARM_SWAP_DEPOSIT_AMOUNT"
+	SwarmEnvSwapEnable                  = "SWARM_SWAP_ENABLE"
+	SwarmEnvSwapBackendURL              = "SWARM_SWAP_BACKEND_URL"
+	SwarmEnvSwapPaymentThreshold        = "SWARM_SWAP_PAYMENT_THRESHOLD"
+	SwarmEnvSwapGraceAllowance          = "SWARM_SWAP_GRACE_ALLOWANCE"
+	SwarmEnvSwapGraceDecayRate          = "SWARM_SWAP_GRACE_DECAY_RATE"
+	SwarmEnvSwapPriceOracle             = "SWARM_SWAP_PRICE_ORACLE"
+	SwarmEnvSwapHoneyPrice              = "SWARM_SWAP_HONEY_PRICE"
+	SwarmEnvSwapPriceTolerance          = "SWARM_SWAP_PRICE_TOLERANCE"
+	SwarmEnvSwapDisconnectThreshold     = "SWARM_SWAP_DISCONNECT_THRESHOLD"
+	SwarmNoSync                         = "SWARM_NO_SYNC"
+	SwarmEnvSwapLogPath                 = "SWARM_SWAP_LOG_PATH"
+	SwarmEnvSwapBalanceLog              = "SWARM_SWAP_BALANCE_LOG"
+	SwarmEnvSwapBalanceLogSize          = "SWARM_SWAP_BALANCE_LOG_SIZE"
+	SwarmEnvSwapBalanceLogFiles         = "SWARM_SWAP_BALANCE_LOG_FILES"
+	SwarmEnvSwapThrottleFraction        = "SWARM_SWAP_THROTTLE_FRACTION"
+	SwarmEnvSwapThrottleMaxDelay        = "SWARM_SWAP_THROTTLE_MAX_DELAY"
+	SwarmEnvSwapReconcileTolerance      = "SWARM_SWAP_RECONCILE_TOLERANCE"
+	SwarmEnvSwapPaymentRequestThreshold = "SWARM_SWAP_PAYMENT_REQUEST_THRESHOLD"
+	SwarmEnvSwapOwnerLocked             = "SWARM_SWAP_OWNER_LOCKED"
+	SwarmEnvSwapPayOnly                 = "SWARM_SWAP_PAY_ONLY"
+	SwarmEnvSwapInterestDebtLevel       = "SWARM_SWAP_INTEREST_DEBT_LEVEL"
+	SwarmEnvSwapInterestGracePeriod     = "SWARM_SWAP_INTEREST_GRACE_PERIOD"
+	SwarmEnvSwapInterestRate            = "SWARM_SWAP_INTEREST_RATE"
+	SwarmEnvSwapCashingInterval         = "SWARM_SWAP_CASHING_INTERVAL"
+	SwarmEnvSwapCashingThreshold        = "SWARM_SWAP_CASHING_THRESHOLD"
+	SwarmEnvSwapCashoutMinAmount        = "SWARM_SWAP_CASHOUT_MIN_AMOUNT"
+	SwarmEnvSwapCashoutMaxDelay         = "SWARM_SWAP_CASHOUT_MAX_DELAY"
+	SwarmEnvSwapCashoutMaxGasPrice      = "SWARM_SWAP_CASHOUT_MAX_GAS_PRICE"
+	SwarmEnvSwapExemptPeers             = "SWARM_SWAP_EXEMPT_PEERS"
+	SwarmEnvSwapAssets                  = "SWARM_SWAP_ASSETS"
+	SwarmEnvSwapTokenChequebooks        = "SWARM_SWAP_TOKEN_CHEQUEBOOKS"
+	SwarmEnvLightNodeEnable             = "SWARM_LIGHT_NODE_ENABLE"
+	SwarmEnvENSAPI                      = "SWARM_ENS_API"
+	SwarmEnvRNSAPI                      = "SWARM_RNS_API"
+	SwarmEnvENSAddr                     = "SWARM_ENS_ADDR"
+	SwarmEnvCORS                        = "SWARM_CORS"
+	SwarmEnvBootnodes                   = "SWARM_BOOTNODES"
+	SwarmEnvPSSEnable                   = "SWARM_PSS_ENABLE"
+	SwarmEnvPssBridgeWebhook            = "SWARM_PSS_BRIDGE_WEBHOOK"
+	SwarmEnvPssBridgeTopics             = "SWARM_PSS_BRIDGE_TOPICS"
+	SwarmEnvPssBridgeAddr               = "SWARM_PSS_BRIDGE_ADDR"
+	SwarmEnvPssBridgeSecret             = "SWARM_PSS_BRIDGE_SECRET"
+	SwarmEnvStorePath                   = "SWARM_STORE_PATH"
+	SwarmEnvStoreCapacity               = "SWARM_STORE_CAPACITY"
+	SwarmEnvStoreCacheCapacity          = "SWARM_STORE_CACHE_CAPACITY"
+	SwarmEnvStoreEngine                 = "SWARM_STORE_ENGINE"
+	SwarmEnvStoreReadOnly               = "SWARM_STORE_READONLY"
+	SwarmEnvStoreEncryptState           = "SWARM_STORE_ENCRYPT_STATE"
+	SwarmEnvBootnodeMode                = "SWARM_BOOTNODE_MODE"
+	SwarmEnvAnnouncePrices              = "SWARM_ANNOUNCE_PRICES"
+	SwarmEnvBandwidthDailyCap           = "SWARM_BANDWIDTH_DAILY_CAP"
+	SwarmEnvBandwidthUpstream           = "SWARM_BANDWIDTH_UPSTREAM"
+	SwarmEnvBandwidthSync               = "SWARM_BANDWIDTH_SYNC"
+	SwarmEnvBandwidthRetrieval          = "SWARM_BANDWIDTH_RETRIEVAL"
+	SwarmEnvBandwidthPss                = "SWARM_BANDWIDTH_PSS"
+	SwarmEnvHandoffOnShutdown           = "SWARM_HANDOFF_ON_SHUTDOWN"
+	SwarmEnvObfuscateRetrieval          = "SWARM_OBFUSCATE_RETRIEVAL"
+	SwarmEnvRetryCorruptChunks          = "SWARM_RETRY_CORRUPT_CHUNKS"
+	SwarmEnvProvenanceWindow            = "SWARM_RETRIEVAL_PROVENANCE_WINDOW"
+	SwarmEnvRetrievalWorkers            = "SWARM_RETRIEVAL_WORKERS"
+	SwarmEnvSyncWorkers                 = "SWARM_SYNC_WORKERS"
+	SwarmEnvSyncFragmentSize            = "SWARM_SYNC_FRAGMENT_SIZE"
+	SwarmEnvFeedDeltaInterval           = "SWARM_FEED_DELTA_INTERVAL"
+	SwarmEnvTimestampInterval           = "SWARM_TIMESTAMP_INTERVAL"
+	SwarmEnvTimestampBackend            = "SWARM_TIMESTAMP_BACKEND"
+	SwarmEnvClockTolerance              = "SWARM_CLOCK_TOLERANCE"
+	SwarmEnvNTPServer                   = "SWARM_NTP_SERVER"
+	SwarmEnvUploadMaxSize               = "SWARM_UPLOAD_MAX_SIZE"
+	SwarmEnvUploadContentTypes          = "SWARM_UPLOAD_CONTENT_TYPES"
+	SwarmEnvUploadScanURL               = "SWARM_UPLOAD_SCAN_URL"
+	SwarmEnvUploadHealthy               = "SWARM_UPLOAD_HEALTHY"
+	SwarmEnvUploadMaxSyncLag            = "SWARM_UPLOAD_MAX_SYNC_LAG"
+	SwarmEnvStandbyPrimary              = "SWARM_STANDBY_PRIMARY"
+	SwarmEnvStandbyPeers                = "SWARM_STANDBY_PEERS"
+	SwarmEnvAllowPeers                  = "SWARM_ALLOW_PEERS"
+	SwarmEnvDenyPeers                   = "SWARM_DENY_PEERS"
+	SwarmEnvPeerUptimeBias              = "SWARM_PEER_UPTIME_BIAS"
+	SwarmEnvRole                        = "SWARM_ROLE"
+	SwarmEnvNATInterface                = "SWARM_NAT_INTERFACE"
+	SwarmAccessPassword                 = "SWARM_ACCESS_PASSWORD"
+	SwarmAutoDefaultPath                = "SWARM_AUTO_DEFAULTPATH"
+	SwarmGlobalstoreAPI                 = "SWARM_GLOBALSTORE_API"
+	GethEnvDataDir                      = "GETH_DATADIR"
 )
 
 // These settings ensure that TOML keys use the same names as Go struct fields.
@@ -113,11 +239,28 @@ var tomlSettings = toml.Config{
 func buildConfig(ctx *cli.Context) (config *bzzapi.Config, err error) {
 	//start by creating a default config
 	config = bzzapi.NewConfig()
-	//first load settings from config file (if provided)
+	//the role is set by flag or env var, else by the config file
+	role := ctx.GlobalString(SwarmRoleFlag.Name)
+	if role == "" {
+		fileConfig, err := configFileOverride(bzzapi.NewConfig(), ctx)
+		if err != nil {
+			return nil, err
+		}
+		role = fileConfig.Role
+	}
+	//apply the role preset so that the config file, flags and env vars override it
+	if role != "" {
+		if err = config.ApplyRole(role); err != nil {
+			return nil, err
+		}
+	}
+	//load settings from config file (if provided)
 	config, err = configFileOverride(config, ctx)
 	if err != nil {
 		return nil, err
 	}
+	//the role given by flag or env var overrides the one of the config file
+	config.Role = role
 	//override settings provided by flags
 	config = flagsOverride(config, ctx)
 	//validate configuration parameters
@@ -190,6 +333,18 @@ func flagsOverride(currentConfig *bzzapi.Config, ctx *cli.Context) *bzzapi.Confi
 	if networkid != 0 && networkid != network.DefaultNetworkID {
 		currentConfig.NetworkID = networkid
 	}
+	if ctx.GlobalIsSet(SwarmNetworkForkIdFlag.Name) {
+		currentConfig.NetworkForkID = ctx.GlobalUint64(SwarmNetworkForkIdFlag.Name)
+	}
+	if key := ctx.GlobalString(SwarmNetworkKeyFlag.Name); key != "" {
+		currentConfig.NetworkKey = key
+	}
+	if ctx.GlobalIsSet(SwarmHandshakeRateLimitFlag.Name) {
+		currentConfig.HandshakeRateLimit = ctx.GlobalUint64(SwarmHandshakeRateLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(SwarmHandshakePuzzleFlag.Name) {
+		currentConfig.HandshakePuzzle = ctx.GlobalUint64(SwarmHandshakePuzzleFlag.Name)
+	}
 	if ctx.GlobalIsSet(utils.DataDirFlag.Name) {
 		if datadir := ctx.GlobalString(utils.DataDirFlag.Name); datadir != "" {
 			currentConfig.Path = expandPath(datadir)
@@ -202,6 +357,21 @@ func flagsOverride(currentConfig *bzzapi.Config, ctx *cli.Context) *bzzapi.Confi
 	if bzzaddr := ctx.GlobalString(SwarmListenAddrFlag.Name); bzzaddr != "" {
 		currentConfig.ListenAddr = bzzaddr
 	}
+	if underlays := ctx.GlobalString(SwarmUnderlaysFlag.Name); underlays != "" {
+		currentConfig.Underlays = strings.Split(underlays, ",")
+	}
+	if addr := ctx.GlobalString(SwarmWSUnderlayAddrFlag.Name); addr != "" {
+		currentConfig.WSUnderlayAddr = addr
+	}
+	if cert := ctx.GlobalString(SwarmWSUnderlayCertFlag.Name); cert != "" {
+		currentConfig.WSUnderlayCert = cert
+	}
+	if key := ctx.GlobalString(SwarmWSUnderlayKeyFlag.Name); key != "" {
+		currentConfig.WSUnderlayKey = key
+	}
+	if ctx.GlobalIsSet(SwarmWSUnderlayNoDialFlag.Name) {
+		currentConfig.WSUnderlayDial = false
+	}
 	if ctx.GlobalIsSet(SwarmSwapEnabledFlag.Name) {
 		currentConfig.SwapEnabled = true
 	}
@@ -211,6 +381,66 @@ func flagsOverride(currentConfig *bzzapi.Config, ctx *cli.Context) *bzzapi.Confi
 	if swapLogPath := ctx.GlobalString(SwarmSwapLogPathFlag.Name); currentConfig.SwapEnabled && swapLogPath != "" {
 		currentConfig.SwapLogPath = swapLogPath
 	}
+	if balanceLog := ctx.GlobalString(SwarmSwapBalanceLogFlag.Name); balanceLog != "" {
+		currentConfig.SwapBalanceLog = balanceLog
+	}
+	if size := ctx.GlobalUint64(SwarmSwapBalanceLogSizeFlag.Name); size != 0 {
+		currentConfig.SwapBalanceLogSize = size
+	}
+	if files := ctx.GlobalUint64(SwarmSwapBalanceLogFilesFlag.Name); files != 0 {
+		currentConfig.SwapBalanceLogFiles = files
+	}
+	if fraction := ctx.GlobalFloat64(SwarmSwapThrottleFractionFlag.Name); fraction != 0 {
+		currentConfig.SwapThrottleFraction = fraction
+	}
+	if delay := ctx.GlobalDuration(SwarmSwapThrottleMaxDelayFlag.Name); delay != 0 {
+		currentConfig.SwapThrottleMaxDelay = delay
+	}
+	if ctx.GlobalIsSet(SwarmSwapReconcileToleranceFlag.Name) {
+		currentConfig.SwapReconcileTolerance = ctx.GlobalUint64(SwarmSwapReconcileToleranceFlag.Name)
+	}
+	if debtLevel := ctx.GlobalUint64(SwarmSwapInterestDebtLevelFlag.Name); debtLevel != 0 {
+		currentConfig.SwapInterestDebtLevel = debtLevel
+	}
+	if ctx.GlobalIsSet(SwarmSwapInterestGracePeriodFlag.Name) {
+		currentConfig.SwapInterestGracePeriod = ctx.GlobalDuration(SwarmSwapInterestGracePeriodFlag.Name)
+	}
+	if rate := ctx.GlobalUint64(SwarmSwapInterestRateFlag.Name); rate != 0 {
+		currentConfig.SwapInterestRate = rate
+	}
+	if ctx.GlobalIsSet(SwarmSwapCashingIntervalFlag.Name) {
+		currentConfig.SwapCashingInterval = ctx.GlobalDuration(SwarmSwapCashingIntervalFlag.Name)
+	}
+	if ctx.GlobalIsSet(SwarmSwapCashingThresholdFlag.Name) {
+		currentConfig.SwapCashingThreshold = ctx.GlobalUint64(SwarmSwapCashingThresholdFlag.Name)
+	}
+	if ctx.GlobalIsSet(SwarmSwapCashoutMinAmountFlag.Name) {
+		currentConfig.SwapCashoutMinAmount = ctx.GlobalUint64(SwarmSwapCashoutMinAmountFlag.Name)
+	}
+	if ctx.GlobalIsSet(SwarmSwapCashoutMaxDelayFlag.Name) {
+		currentConfig.SwapCashoutMaxDelay = ctx.GlobalDuration(SwarmSwapCashoutMaxDelayFlag.Name)
+	}
+	if ctx.GlobalIsSet(SwarmSwapCashoutMaxGasPriceFlag.Name) {
+		currentConfig.SwapCashoutMaxGasPrice = ctx.GlobalUint64(SwarmSwapCashoutMaxGasPriceFlag.Name)
+	}
+	if ctx.GlobalIsSet(SwarmSwapOwnerLockedFlag.Name) {
+		currentConfig.SwapOwnerLocked = true
+	}
+	if ctx.GlobalIsSet(SwarmSwapPayOnlyFlag.Name) {
+		currentConfig.SwapPayOnly = true
+	}
+	if ctx.GlobalIsSet(SwarmSwapPaymentRequestThresholdFlag.Name) {
+		currentConfig.SwapPaymentRequestThreshold = ctx.GlobalUint64(SwarmSwapPaymentRequestThresholdFlag.Name)
+	}
+	if exempt := ctx.GlobalString(SwarmSwapExemptPeersFlag.Name); exempt != "" {
+		currentConfig.SwapExemptPeers = strings.Split(exempt, ",")
+	}
+	if assets := ctx.GlobalString(SwarmSwapAssetsFlag.Name); assets != "" {
+		currentConfig.SwapAssets = strings.Split(assets, ",")
+	}
+	if chequebooks := ctx.GlobalString(SwarmSwapTokenChequebooksFlag.Name); chequebooks != "" {
+		currentConfig.SwapTokenChequebooks = strings.Split(chequebooks, ",")
+	}
 
 	if skipDeposit := ctx.GlobalBool(SwarmSwapSkipDepositFlag.Name); skipDeposit {
 		currentConfig.SwapSkipDeposit = true
@@ -224,6 +454,21 @@ func flagsOverride(currentConfig *bzzapi.Config, ctx *cli.Context) *bzzapi.Confi
 	if disconnectThreshold := ctx.GlobalUint64(SwarmSwapDisconnectThresholdFlag.Name); disconnectThreshold != 0 {
 		currentConfig.SwapDisconnectThreshold = disconnectThreshold
 	}
+	if allowance := ctx.GlobalUint64(SwarmSwapGraceAllowanceFlag.Name); allowance != 0 {
+		currentConfig.SwapGraceAllowance = allowance
+	}
+	if rate := ctx.GlobalUint64(SwarmSwapGraceDecayRateFlag.Name); rate != 0 {
+		currentConfig.SwapGraceDecayRate = rate
+	}
+	if oracle := ctx.GlobalString(SwarmSwapPriceOracleFlag.Name); oracle != "" {
+		currentConfig.SwapPriceOracle = oracle
+	}
+	if price := ctx.GlobalUint64(SwarmSwapHoneyPriceFlag.Name); price != 0 {
+		currentConfig.SwapHoneyPrice = price
+	}
+	if tolerance := ctx.GlobalUint64(SwarmSwapPriceToleranceFlag.Name); tolerance != 0 {
+		currentConfig.SwapPriceTolerance = tolerance
+	}
 	if ctx.GlobalIsSet(SwarmNoSyncFlag.Name) {
 		val := !ctx.GlobalBool(SwarmNoSyncFlag.Name)
 		currentConfig.SyncEnabled, currentConfig.PushSyncEnabled = val, val // if the flag is set (true) - push and pull sync should be disabled
@@ -254,6 +499,15 @@ func flagsOverride(currentConfig *bzzapi.Config, ctx *cli.Context) *bzzapi.Confi
 	if storeCapacity := ctx.GlobalUint64(SwarmStoreCapacity.Name); storeCapacity != 0 {
 		currentConfig.DbCapacity = storeCapacity
 	}
+	if storeEngine := ctx.GlobalString(SwarmStoreEngine.Name); storeEngine != "" {
+		currentConfig.DbEngine = storeEngine
+	}
+	if ctx.GlobalIsSet(SwarmStoreReadOnly.Name) {
+		currentConfig.DbReadOnly = ctx.GlobalBool(SwarmStoreReadOnly.Name)
+	}
+	if ctx.GlobalIsSet(SwarmStoreEncryptState.Name) {
+		currentConfig.EncryptStateStore = ctx.GlobalBool(SwarmStoreEncryptState.Name)
+	}
 	if ctx.GlobalIsSet(SwarmStoreCacheCapacity.Name) {
 		currentConfig.CacheCapacity = ctx.GlobalUint(SwarmStoreCacheCapacity.Name)
 	}
@@ -263,15 +517,153 @@ func flagsOverride(currentConfig *bzzapi.Config, ctx *cli.Context) *bzzapi.Confi
 	if ctx.GlobalIsSet(SwarmDisableAutoConnectFlag.Name) {
 		currentConfig.DisableAutoConnect = ctx.GlobalBool(SwarmDisableAutoConnectFlag.Name)
 	}
+	if ctx.GlobalIsSet(SwarmPeerUptimeBiasFlag.Name) {
+		currentConfig.PeerUptimeBias = ctx.GlobalBool(SwarmPeerUptimeBiasFlag.Name)
+	}
+	if ctx.GlobalIsSet(SwarmAnnouncePricesFlag.Name) {
+		currentConfig.AnnouncePrices = ctx.GlobalBool(SwarmAnnouncePricesFlag.Name)
+	}
 	if ctx.GlobalIsSet(SwarmGlobalStoreAPIFlag.Name) {
 		currentConfig.GlobalStoreAPI = ctx.GlobalString(SwarmGlobalStoreAPIFlag.Name)
 	}
 	if ctx.GlobalBool(SwarmEnablePinningFlag.Name) {
 		currentConfig.EnablePinning = true
 	}
+	if ctx.GlobalBool(SwarmChunkEventsFlag.Name) {
+		currentConfig.ChunkEventsEnabled = true
+	}
+	if ctx.GlobalIsSet(SwarmBandwidthDailyCapFlag.Name) {
+		currentConfig.BandwidthDailyCap = ctx.GlobalUint64(SwarmBandwidthDailyCapFlag.Name)
+	}
+	if ctx.GlobalIsSet(SwarmBandwidthUpstreamFlag.Name) {
+		currentConfig.BandwidthUpstream = ctx.GlobalUint64(SwarmBandwidthUpstreamFlag.Name)
+	}
+	if ctx.GlobalIsSet(SwarmBandwidthSyncFlag.Name) {
+		currentConfig.BandwidthSync = ctx.GlobalUint64(SwarmBandwidthSyncFlag.Name)
+	}
+	if ctx.GlobalIsSet(SwarmBandwidthRetrievalFlag.Name) {
+		currentConfig.BandwidthRetrieval = ctx.GlobalUint64(SwarmBandwidthRetrievalFlag.Name)
+	}
+	if ctx.GlobalIsSet(SwarmBandwidthPssFlag.Name) {
+		currentConfig.BandwidthPss = ctx.GlobalUint64(SwarmBandwidthPssFlag.Name)
+	}
+	if ctx.GlobalIsSet(SwarmHandoffOnShutdownFlag.Name) {
+		currentConfig.HandoffOnShutdown = ctx.GlobalBool(SwarmHandoffOnShutdownFlag.Name)
+	}
+	if ctx.GlobalIsSet(SwarmHandoffTimeoutFlag.Name) {
+		currentConfig.HandoffTimeout = ctx.GlobalDuration(SwarmHandoffTimeoutFlag.Name)
+	}
+	if ctx.GlobalIsSet(SwarmObfuscateRetrievalFlag.Name) {
+		currentConfig.ObfuscateRetrieval = ctx.GlobalBool(SwarmObfuscateRetrievalFlag.Name)
+	}
+	if ctx.GlobalIsSet(SwarmObfuscationMaxDelayFlag.Name) {
+		currentConfig.Obfuscation.MaxDelay = ctx.GlobalDuration(SwarmObfuscationMaxDelayFlag.Name)
+	}
+	if ctx.GlobalIsSet(SwarmObfuscationCoverIntervalFlag.Name) {
+		currentConfig.Obfuscation.CoverInterval = ctx.GlobalDuration(SwarmObfuscationCoverIntervalFlag.Name)
+	}
+	if ctx.GlobalIsSet(SwarmRetryCorruptChunksFlag.Name) {
+		currentConfig.RetryCorruptChunks = ctx.GlobalBool(SwarmRetryCorruptChunksFlag.Name)
+	}
+	if ctx.GlobalIsSet(SwarmProvenanceWindowFlag.Name) {
+		currentConfig.ProvenanceWindow = ctx.GlobalDuration(SwarmProvenanceWindowFlag.Name)
+	}
+	if ctx.GlobalIsSet(SwarmRetrievalWorkersFlag.Name) {
+		currentConfig.RetrievalWorkers.Workers = ctx.GlobalInt(SwarmRetrievalWorkersFlag.Name)
+	}
+	if ctx.GlobalIsSet(SwarmRetrievalQueueFlag.Name) {
+		currentConfig.RetrievalWorkers.Queue = ctx.GlobalInt(SwarmRetrievalQueueFlag.Name)
+	}
+	if ctx.GlobalIsSet(SwarmSyncWorkersFlag.Name) {
+		currentConfig.SyncWorkers.Workers = ctx.GlobalInt(SwarmSyncWorkersFlag.Name)
+	}
+	if ctx.GlobalIsSet(SwarmSyncQueueFlag.Name) {
+		currentConfig.SyncWorkers.Queue = ctx.GlobalInt(SwarmSyncQueueFlag.Name)
+	}
+	if ctx.GlobalIsSet(SwarmSyncFragmentSizeFlag.Name) {
+		currentConfig.SyncFragmentSize = uint32(ctx.GlobalUint(SwarmSyncFragmentSizeFlag.Name))
+	}
+	if ctx.GlobalIsSet(SwarmFeedDeltaIntervalFlag.Name) {
+		currentConfig.FeedDeltaInterval = ctx.GlobalInt(SwarmFeedDeltaIntervalFlag.Name)
+	}
+	if ctx.GlobalIsSet(SwarmTimestampIntervalFlag.Name) {
+		currentConfig.TimestampInterval = ctx.GlobalDuration(SwarmTimestampIntervalFlag.Name)
+	}
+	if backend := ctx.GlobalString(SwarmTimestampBackendFlag.Name); backend != "" {
+		currentConfig.TimestampBackend = backend
+	}
+	if ctx.GlobalIsSet(SwarmClockToleranceFlag.Name) {
+		currentConfig.ClockTolerance = ctx.GlobalDuration(SwarmClockToleranceFlag.Name)
+	}
+	if server := ctx.GlobalString(SwarmNTPServerFlag.Name); server != "" {
+		currentConfig.NTPServer = server
+	}
+	if ctx.GlobalIsSet(SwarmUploadMaxSizeFlag.Name) {
+		currentConfig.UploadMaxSize = ctx.GlobalInt64(SwarmUploadMaxSizeFlag.Name)
+	}
+	if types := ctx.GlobalString(SwarmUploadContentTypesFlag.Name); types != "" {
+		currentConfig.UploadContentTypes = strings.Split(types, ",")
+	}
+	if scanURL := ctx.GlobalString(SwarmUploadScanURLFlag.Name); scanURL != "" {
+		currentConfig.UploadScanURL = scanURL
+	}
+	if ctx.GlobalIsSet(SwarmUploadHealthyFlag.Name) {
+		currentConfig.UploadHealthy = true
+	}
+	if ctx.GlobalIsSet(SwarmUploadMaxSyncLagFlag.Name) {
+		currentConfig.UploadMaxSyncLag = ctx.GlobalUint64(SwarmUploadMaxSyncLagFlag.Name)
+	}
+	if primary := ctx.GlobalString(SwarmStandbyPrimaryFlag.Name); primary != "" {
+		currentConfig.StandbyPrimary = primary
+	}
+	if peers := ctx.GlobalString(SwarmStandbyPeersFlag.Name); peers != "" {
+		currentConfig.StandbyPeers = strings.Split(peers, ",")
+	}
+	if peers := ctx.GlobalString(SwarmAllowPeersFlag.Name); peers != "" {
+		currentConfig.AllowPeers = strings.Split(peers, ",")
+	}
+	if peers := ctx.GlobalString(SwarmDenyPeersFlag.Name); peers != "" {
+		currentConfig.DenyPeers = strings.Split(peers, ",")
+	}
+	pssBridgeOverride(ctx, currentConfig)
 	return currentConfig
 }
 
+// pssBridgeOverride overrides the pss bridge config with the bridge flags
+func pssBridgeOverride(ctx *cli.Context, currentConfig *bzzapi.Config) {
+	if currentConfig.PssBridge == nil {
+		currentConfig.PssBridge = bridge.NewConfig()
+	}
+	if webhook := ctx.GlobalString(SwarmPssBridgeWebhookFlag.Name); webhook != "" {
+		currentConfig.PssBridge.WebhookURL = webhook
+	}
+	if addr := ctx.GlobalString(SwarmPssBridgeAddrFlag.Name); addr != "" {
+		currentConfig.PssBridge.ListenAddr = addr
+	}
+	if secret := ctx.GlobalString(SwarmPssBridgeSecretFlag.Name); secret != "" {
+		currentConfig.PssBridge.Secret = secret
+	}
+	if ctx.GlobalIsSet(SwarmPssBridgeRawFlag.Name) {
+		currentConfig.PssBridge.Raw = ctx.GlobalBool(SwarmPssBridgeRawFlag.Name)
+	}
+	if topics := ctx.GlobalString(SwarmPssBridgeTopicsFlag.Name); topics != "" {
+		currentConfig.PssBridge.Topics = nil
+		for _, t := range splitList(topics) {
+			currentConfig.PssBridge.Topics = append(currentConfig.PssBridge.Topics, parseTopic(t))
+		}
+	}
+}
+
+// parseTopic parses a hex encoded topic, or derives the topic from its name
+func parseTopic(s string) message.Topic {
+	if b, err := hexutil.Decode(s); err == nil && len(b) == message.TopicLength {
+		var topic message.Topic
+		copy(topic[:], b)
+		return topic
+	}
+	return message.NewTopic([]byte(s))
+}
+
 // dumpConfig is the dumpconfig command.
 // writes a default config to STDOUT
 func dumpConfig(ctx *cli.Context) error {
@@ -289,16 +681,218 @@ func dumpConfig(ctx *cli.Context) error {
 	return nil
 }
 
+// configDump is the config dump command.
+// writes the effective config to STDOUT as TOML or JSON
+func configDump(ctx *cli.Context) error {
+	cfg, err := buildConfig(ctx)
+	if err != nil {
+		utils.Fatalf("Invalid configuration: %v", err)
+	}
+	var out []byte
+	switch format := ctx.String(SwarmConfigFormatFlag.Name); format {
+	case "toml":
+		out, err = tomlSettings.Marshal(cfg)
+	case "json":
+		out, err = json.MarshalIndent(cfg, "", "  ")
+		out = append(out, '\n')
+	default:
+		utils.Fatalf("Unknown config format %q, use toml or json", format)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
+
+// configValidate is the config validate command.
+// checks a config file and prints all problems found
+func configValidate(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) != 1 {
+		utils.Fatalf("Usage: swarm config validate <file>")
+	}
+	problems, err := validateConfigFile(args[0])
+	if err != nil {
+		utils.Fatalf("Invalid config file %s: %v", args[0], err)
+	}
+	if len(problems) > 0 {
+		for _, p := range problems {
+			fmt.Fprintln(os.Stderr, p)
+		}
+		utils.Fatalf("Invalid config file %s: %d problems found", args[0], len(problems))
+	}
+	fmt.Printf("Config file %s is valid\n", args[0])
+}
+
+// validateConfigFile decodes the config file at path over the default config
+// and returns the problems of the resulting config. An error is returned if
+// the file cannot be decoded, it names the offending field or line.
+func validateConfigFile(path string) (problems []string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cfg := bzzapi.NewConfig()
+	if err := tomlSettings.NewDecoder(f).Decode(cfg); err != nil {
+		return nil, err
+	}
+	return configProblems(cfg), nil
+}
+
 //validate configuration parameters
 func validateConfig(cfg *bzzapi.Config) (err error) {
+	if problems := configProblems(cfg); len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// configProblems returns a description of every invalid configuration parameter
+func configProblems(cfg *bzzapi.Config) (problems []string) {
 	for _, ensAPI := range cfg.EnsAPIs {
 		if ensAPI != "" {
 			if err := validateEnsAPIs(ensAPI); err != nil {
-				return fmt.Errorf("invalid format [tld:][contract-addr@]url for ENS API endpoint configuration %q: %v", ensAPI, err)
+				problems = append(problems, fmt.Sprintf("invalid format [tld:][contract-addr@]url for ENS API endpoint configuration %q: %v", ensAPI, err))
 			}
 		}
 	}
-	return nil
+	if cfg.DbEngine != "" && !isStorageEngine(cfg.DbEngine) {
+		problems = append(problems, fmt.Sprintf("unknown storage engine %q in DbEngine, available engines: %s", cfg.DbEngine, strings.Join(shed.Engines(), ", ")))
+	}
+	if cfg.Role != "" {
+		if err := bzzapi.NewConfig().ApplyRole(cfg.Role); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid Role: %v", err))
+		}
+	}
+	if cfg.LogVerbosity != "" {
+		if _, err := log.LvlFromString(cfg.LogVerbosity); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid LogVerbosity %q: %v", cfg.LogVerbosity, err))
+		}
+	}
+	for _, w := range []struct {
+		name   string
+		params *protocols.WorkerPoolParams
+	}{
+		{"RetrievalWorkers", cfg.RetrievalWorkers},
+		{"SyncWorkers", cfg.SyncWorkers},
+	} {
+		if w.params != nil && (w.params.Workers < 0 || w.params.Queue < 0) {
+			problems = append(problems, fmt.Sprintf("%s.Workers and %s.Queue must not be negative", w.name, w.name))
+		}
+	}
+	for _, u := range cfg.Underlays {
+		if _, err := network.ParseUnderlay(u); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid underlay: %v", err))
+		}
+	}
+	if (cfg.WSUnderlayCert == "") != (cfg.WSUnderlayKey == "") {
+		problems = append(problems, "WSUnderlayCert and WSUnderlayKey must be set together")
+	}
+	if cfg.WSUnderlayCert != "" && cfg.WSUnderlayAddr == "" {
+		problems = append(problems, "WSUnderlayCert requires a WSUnderlayAddr to listen on")
+	}
+	if _, err := swap.NewExemptPeers(cfg.SwapExemptPeers); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if cfg.ProvenanceWindow < 0 {
+		problems = append(problems, fmt.Sprintf("ProvenanceWindow %v must not be negative", cfg.ProvenanceWindow))
+	}
+	if cfg.FeedDeltaInterval < 0 {
+		problems = append(problems, fmt.Sprintf("FeedDeltaInterval %d must not be negative", cfg.FeedDeltaInterval))
+	}
+	if cfg.TimestampInterval < 0 {
+		problems = append(problems, fmt.Sprintf("TimestampInterval %v must not be negative", cfg.TimestampInterval))
+	}
+	if cfg.TimestampInterval > 0 && cfg.TimestampBackend == "" {
+		problems = append(problems, "TimestampInterval requires a TimestampBackend to send anchoring transactions to")
+	}
+	if cfg.HandshakePuzzle > network.MaxHandshakePuzzleDifficulty {
+		problems = append(problems, fmt.Sprintf("HandshakePuzzle %d must not be higher than %d", cfg.HandshakePuzzle, network.MaxHandshakePuzzleDifficulty))
+	}
+	if cfg.ClockTolerance < 0 {
+		problems = append(problems, fmt.Sprintf("ClockTolerance %v must not be negative", cfg.ClockTolerance))
+	}
+	if cfg.UploadMaxSize < 0 {
+		problems = append(problems, fmt.Sprintf("UploadMaxSize %d must not be negative", cfg.UploadMaxSize))
+	}
+	if cfg.UploadScanURL != "" {
+		if u, err := url.Parse(cfg.UploadScanURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			problems = append(problems, fmt.Sprintf("UploadScanURL %q must be an http or https URL", cfg.UploadScanURL))
+		}
+	}
+	if _, err := network.NewPeerFilter(cfg.AllowPeers, cfg.DenyPeers); err != nil {
+		problems = append(problems, fmt.Sprintf("invalid peer rule in AllowPeers or DenyPeers: %v", err))
+	}
+	if cfg.SwapEnabled {
+		if cfg.NetworkID != swap.AllowedNetworkID {
+			problems = append(problems, fmt.Sprintf("SwapEnabled requires NetworkID %d, found NetworkID %d", swap.AllowedNetworkID, cfg.NetworkID))
+		}
+		if cfg.SwapDisconnectThreshold <= cfg.SwapPaymentThreshold {
+			problems = append(problems, fmt.Sprintf("SwapDisconnectThreshold %d must be higher than SwapPaymentThreshold %d", cfg.SwapDisconnectThreshold, cfg.SwapPaymentThreshold))
+		}
+		if cfg.SwapGraceAllowance >= cfg.SwapPaymentThreshold {
+			problems = append(problems, fmt.Sprintf("SwapGraceAllowance %d must be lower than SwapPaymentThreshold %d", cfg.SwapGraceAllowance, cfg.SwapPaymentThreshold))
+		}
+		if cfg.SwapGraceAllowance > 0 && cfg.SwapGraceDecayRate == 0 {
+			problems = append(problems, "SwapGraceAllowance requires a SwapGraceDecayRate")
+		}
+		if err := swap.ValidatePriceOracleSource(cfg.SwapPriceOracle); err != nil {
+			problems = append(problems, err.Error())
+		}
+		if cfg.SwapPriceTolerance > 100 {
+			problems = append(problems, fmt.Sprintf("SwapPriceTolerance %d must not be higher than 100 percent", cfg.SwapPriceTolerance))
+		}
+		if cfg.SwapPriceOracle != "" && cfg.SwapPriceTolerance == 0 {
+			problems = append(problems, "SwapPriceOracle requires a SwapPriceTolerance")
+		}
+		if cfg.SwapThrottleFraction < 0 || cfg.SwapThrottleFraction >= 1 {
+			problems = append(problems, fmt.Sprintf("SwapThrottleFraction %v must be at least 0 and lower than 1", cfg.SwapThrottleFraction))
+		}
+		if cfg.SwapThrottleMaxDelay < 0 {
+			problems = append(problems, fmt.Sprintf("SwapThrottleMaxDelay %v must not be negative", cfg.SwapThrottleMaxDelay))
+		}
+		if cfg.SwapReconcileTolerance >= cfg.SwapDisconnectThreshold {
+			problems = append(problems, fmt.Sprintf("SwapReconcileTolerance %d must be lower than SwapDisconnectThreshold %d", cfg.SwapReconcileTolerance, cfg.SwapDisconnectThreshold))
+		}
+		if t := cfg.SwapPaymentRequestThreshold; t != 0 && (t <= cfg.SwapPaymentThreshold || t >= cfg.SwapDisconnectThreshold) {
+			problems = append(problems, fmt.Sprintf("SwapPaymentRequestThreshold %d must be 0 or higher than SwapPaymentThreshold %d and lower than SwapDisconnectThreshold %d", t, cfg.SwapPaymentThreshold, cfg.SwapDisconnectThreshold))
+		}
+		if cfg.SwapInterestRate != 0 && (cfg.SwapInterestDebtLevel == 0 || cfg.SwapInterestDebtLevel >= cfg.SwapDisconnectThreshold) {
+			problems = append(problems, fmt.Sprintf("SwapInterestDebtLevel %d must be positive and lower than SwapDisconnectThreshold %d", cfg.SwapInterestDebtLevel, cfg.SwapDisconnectThreshold))
+		}
+		if cfg.SwapInterestGracePeriod < 0 {
+			problems = append(problems, fmt.Sprintf("SwapInterestGracePeriod %v must not be negative", cfg.SwapInterestGracePeriod))
+		}
+		if cfg.SwapCashingInterval < 0 {
+			problems = append(problems, fmt.Sprintf("SwapCashingInterval %v must not be negative", cfg.SwapCashingInterval))
+		}
+		if cfg.SwapCashoutMaxDelay < 0 {
+			problems = append(problems, fmt.Sprintf("SwapCashoutMaxDelay %v must not be negative", cfg.SwapCashoutMaxDelay))
+		}
+		if cfg.SwapBalanceLogSize > math.MaxInt64 {
+			problems = append(problems, fmt.Sprintf("SwapBalanceLogSize %d is too large", cfg.SwapBalanceLogSize))
+		}
+		if cfg.SwapBalanceLogFiles > math.MaxInt32 {
+			problems = append(problems, fmt.Sprintf("SwapBalanceLogFiles %d is too large", cfg.SwapBalanceLogFiles))
+		}
+		if _, _, err := swap.ParseAssets(cfg.SwapAssets, cfg.SwapTokenChequebooks); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	return problems
+}
+
+// isStorageEngine returns whether name is an available shed storage engine
+func isStorageEngine(name string) bool {
+	for _, e := range shed.Engines() {
+		if e == name {
+			return true
+		}
+	}
+	return false
 }
 
 //validate EnsAPIs configuration parameter
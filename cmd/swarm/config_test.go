@@ -24,6 +24,7 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"testing"
 	"time"
@@ -35,6 +36,7 @@ import (
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethersphere/swarm"
 	"github.com/ethersphere/swarm/api"
+	"github.com/ethersphere/swarm/p2p/protocols"
 	"github.com/ethersphere/swarm/swap"
 	"github.com/ethersphere/swarm/testutil"
 )
@@ -50,6 +52,49 @@ func TestConfigDump(t *testing.T) {
 	swarm.ExpectExit()
 }
 
+// TestConfigDumpRole checks that the values set by a role preset
+// are visible in the dumped config
+func TestConfigDumpRole(t *testing.T) {
+	swarm := runSwarm(t, "--verbosity", fmt.Sprintf("%d", *testutil.Loglevel), "--role", api.RoleStorer, "config", "dump")
+	conf := api.NewConfig()
+	if err := conf.ApplyRole(api.RoleStorer); err != nil {
+		t.Fatal(err)
+	}
+	out, err := tomlSettings.Marshal(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	swarm.Expect(string(out))
+	swarm.ExpectExit()
+}
+
+// TestConfigDumpFileRole checks that the role set in the config file
+// presets the config, and that the file overrides the preset
+func TestConfigDumpFileRole(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bzztest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bzz.toml")
+	if err := ioutil.WriteFile(path, []byte("Role = \"relay\"\nDbCapacity = 1234\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	swarm := runSwarm(t, "--verbosity", fmt.Sprintf("%d", *testutil.Loglevel), "--config", path, "config", "dump")
+	conf := api.NewConfig()
+	if err := conf.ApplyRole(api.RoleRelay); err != nil {
+		t.Fatal(err)
+	}
+	conf.DbCapacity = 1234
+	out, err := tomlSettings.Marshal(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	swarm.Expect(string(out))
+	swarm.ExpectExit()
+}
+
 func TestBzzKeyFlag(t *testing.T) {
 	key, err := crypto.GenerateKey()
 	if err != nil {
@@ -695,6 +740,61 @@ func TestConfigValidate(t *testing.T) {
 			}},
 			err: "invalid format [tld:][contract-addr@]url for ENS API endpoint configuration \"@/data/testnet/geth.ipc\": missing contract address",
 		},
+		{
+			cfg: &api.Config{DbEngine: "leveldb"},
+		},
+		{
+			cfg: &api.Config{DbEngine: "rocksdb"},
+			err: "unknown storage engine \"rocksdb\" in DbEngine, available engines: leveldb, shardedfile",
+		},
+		{
+			cfg: &api.Config{Role: api.RoleRelay},
+		},
+		{
+			cfg: &api.Config{Role: "archive"},
+			err: "invalid Role: unknown node role \"archive\", available roles: gateway, light, relay, storer",
+		},
+		{
+			cfg: &api.Config{LogVerbosity: "debug"},
+		},
+		{
+			cfg: &api.Config{LogVerbosity: "loud"},
+			err: "invalid LogVerbosity \"loud\": Unknown level: loud",
+		},
+		{
+			cfg: &api.Config{SyncWorkers: &protocols.WorkerPoolParams{Workers: 16, Queue: 64}},
+		},
+		{
+			cfg: &api.Config{SyncWorkers: &protocols.WorkerPoolParams{Workers: -1}},
+			err: "SyncWorkers.Workers and SyncWorkers.Queue must not be negative",
+		},
+		{
+			cfg: &api.Config{FeedDeltaInterval: 10},
+		},
+		{
+			cfg: &api.Config{FeedDeltaInterval: -1},
+			err: "FeedDeltaInterval -1 must not be negative",
+		},
+		{
+			cfg: &api.Config{TimestampInterval: time.Hour, TimestampBackend: "http://localhost:8545"},
+		},
+		{
+			cfg: &api.Config{TimestampInterval: time.Hour},
+			err: "TimestampInterval requires a TimestampBackend to send anchoring transactions to",
+		},
+		{
+			cfg: &api.Config{ClockTolerance: -time.Second},
+			err: "ClockTolerance -1s must not be negative",
+		},
+		{
+			cfg: &api.Config{
+				SwapEnabled:             true,
+				NetworkID:               3,
+				SwapPaymentThreshold:    2,
+				SwapDisconnectThreshold: 1,
+			},
+			err: "SwapEnabled requires NetworkID 5, found NetworkID 3; SwapDisconnectThreshold 1 must be higher than SwapPaymentThreshold 2",
+		},
 	} {
 		err := validateConfig(c.cfg)
 		if c.err != "" && err.Error() != c.err {
@@ -706,6 +806,39 @@ func TestConfigValidate(t *testing.T) {
 	}
 }
 
+// TestConfigValidateFile tests that config files with unknown fields
+// or invalid values are reported
+func TestConfigValidateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bzztest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, c := range []struct {
+		file     string
+		problems int
+		err      bool
+	}{
+		{file: "NetworkID = 5\nDbEngine = \"leveldb\"\n"},
+		{file: "NetworkId = 5\n", err: true},
+		{file: "NetworkID = \"five\"\n", err: true},
+		{file: "NetworkID = 3\nSwapEnabled = true\nDenyPeers = [\"not-a-peer\"]\n", problems: 2},
+	} {
+		path := filepath.Join(dir, "bzz.toml")
+		if err := ioutil.WriteFile(path, []byte(c.file), 0600); err != nil {
+			t.Fatal(err)
+		}
+		problems, err := validateConfigFile(path)
+		if (err != nil) != c.err {
+			t.Errorf("%q: got error %v, want error %v", c.file, err, c.err)
+		}
+		if len(problems) != c.problems {
+			t.Errorf("%q: got problems %q, want %v problems", c.file, problems, c.problems)
+		}
+	}
+}
+
 func assignTCPPort() (string, error) {
 	l, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
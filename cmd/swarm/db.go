@@ -25,12 +25,16 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/shed"
 	"github.com/ethersphere/swarm/storage/localstore"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/opt"
@@ -87,6 +91,32 @@ pv(1) tool to get a progress bar:
 				SwarmLegacyFlag,
 			},
 		},
+		{
+			Action:             dbMigrateEngine,
+			CustomHelpTemplate: helpTemplate,
+			Name:               "migrate-engine",
+			Usage:              "copy a local chunk database to a new database using a different storage engine",
+			ArgsUsage:          "<chunkdb> <newchunkdb> <engine>",
+			Description: `Copy a local chunk database to a new database using a different storage engine.
+
+    swarm db migrate-engine ~/.ethereum/swarm/bzz-KEY/chunks ~/.ethereum/swarm/bzz-KEY/chunks.new shardedfile
+
+The original database is not changed. Stop the node before migrating, then
+replace the original database directory with the new one.`,
+		},
+		{
+			Action:             dbInspect,
+			CustomHelpTemplate: helpTemplate,
+			Name:               "inspect",
+			Usage:              "print the metadata of a chunk in a local chunk database",
+			ArgsUsage:          "<chunkdb> <address> <basekey>",
+			Description: `Print the metadata of a chunk in a local chunk database.
+
+    swarm db inspect ~/.ethereum/swarm/bzz-KEY/chunks CHUNK-ADDRESS KEY
+
+The database is opened read-only. The same information is available
+from a running node with the bzz_chunkInfo RPC method.`,
+		},
 	},
 }
 
@@ -167,6 +197,78 @@ func dbImport(ctx *cli.Context) {
 	log.Info(fmt.Sprintf("successfully imported %d chunks", count))
 }
 
+func dbMigrateEngine(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) != 3 {
+		utils.Fatalf("invalid arguments, please specify <chunkdb> (path to a local chunk database), <newchunkdb> (path to the new database) and <engine> (one of %s)", strings.Join(shed.Engines(), ", "))
+	}
+	if _, err := os.Stat(filepath.Join(args[0], "CURRENT")); err != nil {
+		utils.Fatalf("invalid chunkdb path: %s", err)
+	}
+
+	count, err := shed.MigrateEngine(args[0], args[1], args[2])
+	if err != nil {
+		utils.Fatalf("error migrating local chunk database: %s", err)
+	}
+
+	log.Info(fmt.Sprintf("successfully migrated %d records to %s database", count, args[2]))
+}
+
+func dbInspect(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) != 3 {
+		utils.Fatalf("invalid arguments, please specify <chunkdb> (path to a local chunk database), <address> (hex encoded chunk address) and the base key")
+	}
+	if _, err := os.Stat(filepath.Join(args[0], "CURRENT")); err != nil {
+		utils.Fatalf("invalid chunkdb path: %s", err)
+	}
+	addr, err := hex.DecodeString(strings.TrimPrefix(args[1], "0x"))
+	if err != nil || len(addr) != chunk.AddressLength {
+		utils.Fatalf("invalid chunk address: %s", args[1])
+	}
+
+	store, err := localstore.New(args[0], common.Hex2Bytes(args[2]), &localstore.Options{
+		ReadOnly: true,
+	})
+	if err != nil {
+		utils.Fatalf("error opening local chunk database: %s", err)
+	}
+	defer store.Close()
+
+	info, err := store.ChunkInfo(addr)
+	if err != nil {
+		utils.Fatalf("error inspecting chunk %x: %s", addr, err)
+	}
+	printChunkInfo(os.Stdout, info)
+}
+
+// printChunkInfo writes the chunk metadata in a human readable form.
+func printChunkInfo(w io.Writer, info *localstore.ChunkInfo) {
+	timestamp := func(t int64) string {
+		if t == 0 {
+			return "-"
+		}
+		return time.Unix(0, t).UTC().Format(time.RFC3339Nano)
+	}
+	gcPosition := "not in gc index"
+	if info.GCPosition >= 0 {
+		gcPosition = strconv.Itoa(info.GCPosition)
+	}
+	fmt.Fprintf(w, "address:          %s\n", info.Address.Hex())
+	fmt.Fprintf(w, "size:             %d\n", info.Size)
+	fmt.Fprintf(w, "bin:              %d\n", info.Bin)
+	fmt.Fprintf(w, "bin id:           %d\n", info.BinID)
+	fmt.Fprintf(w, "store timestamp:  %s\n", timestamp(info.StoreTimestamp))
+	fmt.Fprintf(w, "access timestamp: %s\n", timestamp(info.AccessTimestamp))
+	fmt.Fprintf(w, "source:           %s\n", info.Source)
+	fmt.Fprintf(w, "tag:              %d\n", info.Tag)
+	fmt.Fprintf(w, "push pending:     %t\n", info.PushPending)
+	fmt.Fprintf(w, "pin count:        %d\n", info.PinCounter)
+	fmt.Fprintf(w, "expiry:           %s\n", timestamp(info.Expiry))
+	fmt.Fprintf(w, "gc position:      %s\n", gcPosition)
+	fmt.Fprintf(w, "gc excluded:      %t\n", info.GCExcluded)
+}
+
 func openLDBStore(path string, basekey []byte) (*localstore.DB, error) {
 	if _, err := os.Stat(filepath.Join(path, "CURRENT")); err != nil {
 		return nil, fmt.Errorf("invalid chunkdb path: %s", err)
@@ -0,0 +1,101 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethersphere/swarm/api"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var ensCommand = cli.Command{
+	Name:               "ens",
+	CustomHelpTemplate: helpTemplate,
+	Usage:              "manage ENS names owned by the node",
+	ArgsUsage:          "ens COMMAND",
+	Description:        "Updates ENS names owned by the bzzaccount through the ENS endpoint of a Swarm node running locally. For all operations you must reference the correct path to bzzd.ipc in order to communicate with the node",
+	Subcommands: []cli.Command{
+		{
+			Action:             ensPublish,
+			CustomHelpTemplate: helpTemplate,
+			Name:               "publish",
+			Usage:              "point an ENS name to a swarm hash",
+			ArgsUsage:          "<name> <hash>",
+			Description: `Sets the content hash record of the ENS name to the swarm hash. The name must be owned
+by the bzzaccount of the node and have a resolver set. With --dry-run the transaction is
+signed and its cost printed, but it is not sent.`,
+			Flags: []cli.Flag{SwarmEnsGasPriceFlag, SwarmEnsGasLimitFlag, SwarmDryRunFlag},
+		},
+	},
+}
+
+func ensPublish(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) != 2 {
+		utils.Fatalf("Need the ENS name and the swarm hash as arguments")
+	}
+	publish(ctx, args[0], args[1])
+}
+
+// publish points the ENS name to the swarm hash with the ENS RPC API
+// of the local node and prints the transaction
+func publish(ctx *cli.Context, name, hash string) {
+	h, err := hexutil.Decode("0x" + strings.TrimPrefix(hash, "0x"))
+	if err != nil || len(h) != common.HashLength {
+		utils.Fatalf("Invalid swarm hash %q", hash)
+	}
+	var gasPrice *hexutil.Big
+	if s := ctx.String(SwarmEnsGasPriceFlag.Name); s != "" {
+		p, ok := new(big.Int).SetString(s, 10)
+		if !ok || p.Sign() < 0 {
+			utils.Fatalf("Invalid gas price %q", s)
+		}
+		gasPrice = (*hexutil.Big)(p)
+	}
+	gasLimit := hexutil.Uint64(ctx.Uint64(SwarmEnsGasLimitFlag.Name))
+	dryRun := ctx.Bool(SwarmDryRunFlag.Name)
+
+	client, err := dialRPC(ctx)
+	if err != nil {
+		utils.Fatalf("had an error dailing to RPC endpoint: %v", err)
+	}
+	defer client.Close()
+
+	rpcCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var result api.PublishResult
+	if err := client.CallContext(rpcCtx, &result, "ens_publish", name, common.BytesToHash(h), gasPrice, gasLimit, dryRun); err != nil {
+		utils.Fatalf("Could not publish %s: %v", name, err)
+	}
+	if result.DryRun {
+		fmt.Println("Dry run, the transaction was not sent")
+	}
+	fmt.Println("Transaction:", result.TxHash.Hex())
+	fmt.Println("Nonce:", result.Nonce)
+	fmt.Println("Gas price:", result.GasPrice)
+	fmt.Println("Gas limit:", result.GasLimit)
+	fmt.Println("Maximum cost:", result.Cost)
+}
@@ -72,8 +72,13 @@ var feedCommand = cli.Command{
 					
 					If you have a manifest, you can specify it with --manifest to refer to the feed,
 					instead of using --topic / --name
+
+					The update is signed with your local account (--bzzaccount) unless one of the following is used:
+					* use --signer with --user to sign with the user's account through an external signer such as clef.
+					* use --signature with --user to publish an update the user signed beforehand.
+					    Use --digest with --user to print the digest the user has to sign.
 					`,
-			Flags: []cli.Flag{SwarmFeedManifestFlag, SwarmFeedNameFlag, SwarmFeedTopicFlag},
+			Flags: []cli.Flag{SwarmFeedManifestFlag, SwarmFeedNameFlag, SwarmFeedTopicFlag, SwarmFeedUserFlag, SwarmFeedSignerFlag, SwarmFeedSignatureFlag, SwarmFeedDigestFlag},
 		},
 		{
 			Action:             feedInfo,
@@ -97,6 +102,39 @@ func NewGenericSigner(ctx *cli.Context) feed.Signer {
 	return feed.NewGenericSigner(getPrivKey(ctx))
 }
 
+// feedSigner returns the signer of a feed update, which is the local account
+// unless an external signer or a pre-signed signature is specified
+func feedSigner(ctx *cli.Context) feed.Signer {
+	var (
+		endpoint  = ctx.String(SwarmFeedSignerFlag.Name)
+		signature = ctx.String(SwarmFeedSignatureFlag.Name)
+	)
+	if endpoint == "" && signature == "" {
+		return NewGenericSigner(ctx)
+	}
+	if ctx.String(SwarmFeedUserFlag.Name) == "" {
+		utils.Fatalf("--%s is required with --%s and --%s", SwarmFeedUserFlag.Name, SwarmFeedSignerFlag.Name, SwarmFeedSignatureFlag.Name)
+	}
+	user := feedGetUser(ctx)
+	if signature != "" {
+		sigBytes, err := hexutil.Decode(signature)
+		if err != nil {
+			utils.Fatalf("Error parsing signature: %s", err)
+		}
+		var sig feed.Signature
+		if len(sigBytes) != len(sig) {
+			utils.Fatalf("Invalid signature length %d, expected %d", len(sigBytes), len(sig))
+		}
+		copy(sig[:], sigBytes)
+		return feed.NewPresignedSigner(user, sig)
+	}
+	signer, err := feed.NewExternalSigner(endpoint, user)
+	if err != nil {
+		utils.Fatalf("Error connecting to external signer: %s", err)
+	}
+	return signer
+}
+
 func getTopic(ctx *cli.Context) (topic feed.Topic) {
 	var name = ctx.String(SwarmFeedNameFlag.Name)
 	var relatedTopic = ctx.String(SwarmFeedTopicFlag.Name)
@@ -154,7 +192,13 @@ func feedUpdate(ctx *cli.Context) {
 		return
 	}
 
-	signer := NewGenericSigner(ctx)
+	var signer feed.Signer
+	if !ctx.Bool(SwarmFeedDigestFlag.Name) {
+		signer = feedSigner(ctx)
+		if s, ok := signer.(*feed.ExternalSigner); ok {
+			defer s.Close()
+		}
+	}
 
 	data, err := hexutil.Decode(args[0])
 	if err != nil {
@@ -167,7 +211,11 @@ func feedUpdate(ctx *cli.Context) {
 
 	if manifestAddressOrDomain == "" {
 		query = new(feed.Query)
-		query.User = signer.Address()
+		if signer != nil {
+			query.User = signer.Address()
+		} else {
+			query.User = feedGetUser(ctx)
+		}
 		query.Topic = getTopic(ctx)
 	}
 
@@ -177,14 +225,24 @@ func feedUpdate(ctx *cli.Context) {
 		utils.Fatalf("Error retrieving feed status: %s", err.Error())
 	}
 
+	// set the new data
+	updateRequest.SetData(data)
+
+	// print the digest to be signed by the user
+	if signer == nil {
+		digest, err := updateRequest.GetDigest()
+		if err != nil {
+			utils.Fatalf("Error computing feed update digest: %s", err.Error())
+		}
+		fmt.Println(digest.Hex())
+		return
+	}
+
 	// Check that the provided signer matches the request to sign
 	if updateRequest.User != signer.Address() {
 		utils.Fatalf("Signer address does not match the update request")
 	}
 
-	// set the new data
-	updateRequest.SetData(data)
-
 	// sign update
 	if err = updateRequest.Sign(signer); err != nil {
 		utils.Fatalf("Error signing feed update: %s", err.Error())
@@ -18,6 +18,10 @@
 package main
 
 import (
+	"strings"
+
+	"github.com/ethersphere/swarm/api"
+	"github.com/ethersphere/swarm/contracts/ens"
 	"github.com/ethersphere/swarm/network"
 	cli "gopkg.in/urfave/cli.v1"
 )
@@ -54,6 +58,27 @@ var (
 		Value:  network.DefaultNetworkID,
 		EnvVar: SwarmEnvNetworkID,
 	}
+	SwarmNetworkForkIdFlag = cli.Uint64Flag{
+		Name:   "bzzforkid",
+		Usage:  "Numerical fork/feature bitmap that peers must share in the bzz handshake (separates test and staging networks)",
+		EnvVar: SwarmEnvNetworkForkID,
+	}
+	SwarmNetworkKeyFlag = cli.StringFlag{
+		Name:   "bzznetworkkey",
+		Usage:  "Pre-shared secret of a private network, only peers proving to know it in the bzz handshake can connect",
+		EnvVar: SwarmEnvNetworkKey,
+	}
+	SwarmHandshakeRateLimitFlag = cli.Uint64Flag{
+		Name:   "handshake-rate-limit",
+		Usage:  "Inbound handshakes per minute above which peers not seen recently must solve the handshake puzzle (0 never requires it)",
+		EnvVar: SwarmEnvHandshakeRateLimit,
+	}
+	SwarmHandshakePuzzleFlag = cli.Uint64Flag{
+		Name:   "handshake-puzzle",
+		Usage:  "Leading zero bits of the proof of work puzzle required from peers not seen recently under a handshake flood (0 disables puzzles)",
+		Value:  network.DefaultHandshakePuzzleDifficulty,
+		EnvVar: SwarmEnvHandshakePuzzle,
+	}
 	SwarmSwapDepositAmountFlag = cli.StringFlag{
 		Name:   "swap-deposit-amount",
 		Usage:  "Deposit amount for swap chequebook",
@@ -89,6 +114,31 @@ var (
 		Usage:  "honey amount at which payment is triggered",
 		EnvVar: SwarmEnvSwapPaymentThreshold,
 	}
+	SwarmSwapGraceAllowanceFlag = cli.Uint64Flag{
+		Name:   "swap-grace-allowance",
+		Usage:  "honey amount within which balances with peers decay towards zero instead of triggering a payment, the lower allowance of the node and the peer applies",
+		EnvVar: SwarmEnvSwapGraceAllowance,
+	}
+	SwarmSwapGraceDecayRateFlag = cli.Uint64Flag{
+		Name:   "swap-grace-decay-rate",
+		Usage:  "honey per second by which balances within the grace allowance decay, the lower rate of the node and the peer applies",
+		EnvVar: SwarmEnvSwapGraceDecayRate,
+	}
+	SwarmSwapPriceOracleFlag = cli.StringFlag{
+		Name:   "swap-price-oracle",
+		Usage:  "URL of an HTTP oracle or address of an oracle contract resolving the price of honey in wei (default: fixed price)",
+		EnvVar: SwarmEnvSwapPriceOracle,
+	}
+	SwarmSwapHoneyPriceFlag = cli.Uint64Flag{
+		Name:   "swap-honey-price",
+		Usage:  "fixed price of honey in wei, used without a price oracle",
+		EnvVar: SwarmEnvSwapHoneyPrice,
+	}
+	SwarmSwapPriceToleranceFlag = cli.Uint64Flag{
+		Name:   "swap-price-tolerance",
+		Usage:  "percentage by which the amount of a received cheque may differ from the local price of its honey, required with a price oracle",
+		EnvVar: SwarmEnvSwapPriceTolerance,
+	}
 	SwarmSwapDisconnectThresholdFlag = cli.Uint64Flag{
 		Name:   "swap-disconnect-threshold",
 		Usage:  "honey amount at which a peer disconnects",
@@ -101,14 +151,119 @@ var (
 	}
 	SwarmSwapLogPathFlag = cli.StringFlag{
 		Name:   "swap-audit-logpath",
-		Usage:  "Write execution logs of swap audit to the given directory",
+		Usage:  "Also write the swap execution logs to the given directory (see --swap-balance-log for the balance audit log)",
 		EnvVar: SwarmEnvSwapLogPath,
 	}
+	SwarmSwapBalanceLogFlag = cli.StringFlag{
+		Name:   "swap-balance-log",
+		Usage:  "Append every balance mutation with the peer, the accounted message and the resulting balance to the given file",
+		EnvVar: SwarmEnvSwapBalanceLog,
+	}
+	SwarmSwapBalanceLogSizeFlag = cli.Uint64Flag{
+		Name:   "swap-balance-log-size",
+		Usage:  "Size in bytes at which the balance log is rotated (default 64MiB)",
+		EnvVar: SwarmEnvSwapBalanceLogSize,
+	}
+	SwarmSwapBalanceLogFilesFlag = cli.Uint64Flag{
+		Name:   "swap-balance-log-files",
+		Usage:  "Number of rotated balance log files which are kept, older ones are removed (default 10)",
+		EnvVar: SwarmEnvSwapBalanceLogFiles,
+	}
+	SwarmSwapThrottleFractionFlag = cli.Float64Flag{
+		Name:   "swap-throttle-fraction",
+		Usage:  "Fraction of the disconnect threshold above which serving a peer is delayed, growing with its debt (0 disables throttling)",
+		EnvVar: SwarmEnvSwapThrottleFraction,
+	}
+	SwarmSwapThrottleMaxDelayFlag = cli.DurationFlag{
+		Name:   "swap-throttle-max-delay",
+		Usage:  "Delay of serving a peer at the disconnect threshold",
+		EnvVar: SwarmEnvSwapThrottleMaxDelay,
+	}
+	SwarmSwapReconcileToleranceFlag = cli.Uint64Flag{
+		Name:   "swap-reconcile-tolerance",
+		Usage:  "Largest balance disagreement with a reconnecting peer which is converged, to recover from crashes (0 disables reconciliation)",
+		EnvVar: SwarmEnvSwapReconcileTolerance,
+	}
+	SwarmSwapPaymentRequestThresholdFlag = cli.Uint64Flag{
+		Name:   "swap-payment-request-threshold",
+		Usage:  "Honey amount a peer owes at which it is asked to pay, between the payment and the disconnect thresholds (0 disables payment requests)",
+		EnvVar: SwarmEnvSwapPaymentRequestThreshold,
+	}
+	SwarmSwapInterestDebtLevelFlag = cli.Uint64Flag{
+		Name:   "swap-interest-debt-level",
+		Usage:  "honey amount a peer owes above which interest accrues",
+		EnvVar: SwarmEnvSwapInterestDebtLevel,
+	}
+	SwarmSwapInterestGracePeriodFlag = cli.DurationFlag{
+		Name:   "swap-interest-grace-period",
+		Usage:  "time a peer may owe more than the interest debt level before interest accrues",
+		EnvVar: SwarmEnvSwapInterestGracePeriod,
+	}
+	SwarmSwapInterestRateFlag = cli.Uint64Flag{
+		Name:   "swap-interest-rate",
+		Usage:  "interest on the debt of peers in parts per million per hour, no interest if 0",
+		EnvVar: SwarmEnvSwapInterestRate,
+	}
+	SwarmSwapCashingIntervalFlag = cli.DurationFlag{
+		Name:   "swap-cashing-interval",
+		Usage:  "Interval at which the latest cheque received from every peer is cashed in a batch, cheques are cashed on receipt if 0",
+		EnvVar: SwarmEnvSwapCashingInterval,
+	}
+	SwarmSwapCashingThresholdFlag = cli.Uint64Flag{
+		Name:   "swap-cashing-threshold",
+		Usage:  "Amount a queued cheque pays out at which it is cashed without waiting for the cashing interval (0 waits for the interval)",
+		EnvVar: SwarmEnvSwapCashingThreshold,
+	}
+	SwarmSwapCashoutMinAmountFlag = cli.Uint64Flag{
+		Name:   "swap-cashout-min-amount",
+		Usage:  "Amount a received cheque must pay out to be cashed (0 for no minimum)",
+		EnvVar: SwarmEnvSwapCashoutMinAmount,
+	}
+	SwarmSwapCashoutMaxDelayFlag = cli.DurationFlag{
+		Name:   "swap-cashout-max-delay",
+		Usage:  "Time after which a received cheque is cashed regardless of the minimum amount and the maximum gas price (0 for no limit)",
+		EnvVar: SwarmEnvSwapCashoutMaxDelay,
+	}
+	SwarmSwapCashoutMaxGasPriceFlag = cli.Uint64Flag{
+		Name:   "swap-cashout-max-gas-price",
+		Usage:  "Gas price in wei above which received cheques are not cashed (0 for no limit)",
+		EnvVar: SwarmEnvSwapCashoutMaxGasPrice,
+	}
+	SwarmSwapOwnerLockedFlag = cli.BoolFlag{
+		Name:   "swap-owner-locked",
+		Usage:  "Lock the chequebook owner key in the keystore once the chequebook is started, cheques are signed only while it is unlocked with swap_unlockOwner",
+		EnvVar: SwarmEnvSwapOwnerLocked,
+	}
+	SwarmSwapPayOnlyFlag = cli.BoolFlag{
+		Name:   "swap-pay-only",
+		Usage:  "Pay for services with cheques but extend no credit to peers, for nodes without a chequebook to receive cheques into",
+		EnvVar: SwarmEnvSwapPayOnly,
+	}
+	SwarmSwapExemptPeersFlag = cli.StringFlag{
+		Name:   "swap-exempt-peers",
+		Usage:  "Comma separated enode URLs, node IDs or overlay addresses of peers which are not accounted with, like the other nodes of the same operator",
+		EnvVar: SwarmEnvSwapExemptPeers,
+	}
+	SwarmSwapAssetsFlag = cli.StringFlag{
+		Name:   "swap-assets",
+		Usage:  "Comma separated settlement assets in order of preference, eth or ERC20 token addresses (default eth)",
+		EnvVar: SwarmEnvSwapAssets,
+	}
+	SwarmSwapTokenChequebooksFlag = cli.StringFlag{
+		Name:   "swap-token-chequebooks",
+		Usage:  "Comma separated chequebooks of the ERC20 settlement assets as <token>:<chequebook>[:<honey price>]",
+		EnvVar: SwarmEnvSwapTokenChequebooks,
+	}
 	SwarmLightNodeEnabled = cli.BoolFlag{
 		Name:   "lightnode",
 		Usage:  "Enable Swarm LightNode (default false)",
 		EnvVar: SwarmEnvLightNodeEnable,
 	}
+	SwarmRoleFlag = cli.StringFlag{
+		Name:   "role",
+		Usage:  "Node role presetting the syncing, HTTP exposure, swap and storage settings: " + strings.Join(api.Roles(), ", "),
+		EnvVar: SwarmEnvRole,
+	}
 	EnsAPIFlag = cli.StringSliceFlag{
 		Name:   "ens-api",
 		Usage:  "ENS API endpoint for a TLD and with contract address, can be repeated, format [tld:][contract-addr@]url",
@@ -180,6 +335,21 @@ var (
 		Usage:  "Number of chunks (5M is roughly 20-25GB) (default 5000000)",
 		EnvVar: SwarmEnvStoreCapacity,
 	}
+	SwarmStoreEngine = cli.StringFlag{
+		Name:   "store.engine",
+		Usage:  "Storage engine of a new chunk DB, leveldb or shardedfile (existing DBs keep their engine, see 'swarm db migrate-engine')",
+		EnvVar: SwarmEnvStoreEngine,
+	}
+	SwarmStoreReadOnly = cli.BoolFlag{
+		Name:   "store.readonly",
+		Usage:  "Open an existing chunk DB read-only, serving chunks without storing new ones or running garbage collection",
+		EnvVar: SwarmEnvStoreReadOnly,
+	}
+	SwarmStoreEncryptState = cli.BoolFlag{
+		Name:   "store.encrypt-state",
+		Usage:  "Encrypt the state store holding balances, cheques and sync intervals with a key derived from the account key",
+		EnvVar: SwarmEnvStoreEncryptState,
+	}
 	SwarmStoreCacheCapacity = cli.UintFlag{
 		Name:   "store.cache.size",
 		Usage:  "Number of recent chunks cached in memory",
@@ -198,6 +368,216 @@ var (
 		Name:  "disable-auto-connect",
 		Usage: "Disables the peer discovery mechanism in the hive protocol as well as the auto connect loop (manual peer addition)",
 	}
+	SwarmPeerUptimeBiasFlag = cli.BoolFlag{
+		Name:   "peer-uptime-bias",
+		Usage:  "Prefer connecting to known peers with a longer history of stable connections",
+		EnvVar: SwarmEnvPeerUptimeBias,
+	}
+	SwarmAnnouncePricesFlag = cli.BoolFlag{
+		Name:   "announce-prices",
+		Usage:  "Announce the node's service prices to its peers (requires --swap)",
+		EnvVar: SwarmEnvAnnouncePrices,
+	}
+	SwarmPssBridgeWebhookFlag = cli.StringFlag{
+		Name:   "pss.bridge.webhook",
+		Usage:  "URL of a webhook incoming pss messages on the bridged topics are delivered to",
+		EnvVar: SwarmEnvPssBridgeWebhook,
+	}
+	SwarmPssBridgeTopicsFlag = cli.StringFlag{
+		Name:   "pss.bridge.topics",
+		Usage:  "Comma separated list of pss topics delivered to the webhook, as hex or topic names",
+		EnvVar: SwarmEnvPssBridgeTopics,
+	}
+	SwarmPssBridgeRawFlag = cli.BoolFlag{
+		Name:  "pss.bridge.raw",
+		Usage: "Also deliver raw (unencrypted) pss messages to the webhook",
+	}
+	SwarmPssBridgeAddrFlag = cli.StringFlag{
+		Name:   "pss.bridge.addr",
+		Usage:  "Listen address of the HTTP endpoint accepting pss messages to send",
+		EnvVar: SwarmEnvPssBridgeAddr,
+	}
+	SwarmPssBridgeSecretFlag = cli.StringFlag{
+		Name:   "pss.bridge.secret",
+		Usage:  "Shared secret used to sign webhook requests and authenticate send requests",
+		EnvVar: SwarmEnvPssBridgeSecret,
+	}
+	SwarmBandwidthDailyCapFlag = cli.Uint64Flag{
+		Name:   "bandwidth-daily-cap",
+		Usage:  "Bytes sent to a peer per day after which sending to the peer is throttled until the next day (0 = no cap)",
+		EnvVar: SwarmEnvBandwidthDailyCap,
+	}
+	SwarmBandwidthUpstreamFlag = cli.Uint64Flag{
+		Name:   "bandwidth.upstream",
+		Usage:  "Upstream bandwidth cap of all protocols in bytes per second (0 = no cap)",
+		EnvVar: SwarmEnvBandwidthUpstream,
+	}
+	SwarmBandwidthSyncFlag = cli.Uint64Flag{
+		Name:   "bandwidth.sync",
+		Usage:  "Upstream bandwidth cap of syncing in bytes per second (0 = no cap)",
+		EnvVar: SwarmEnvBandwidthSync,
+	}
+	SwarmBandwidthRetrievalFlag = cli.Uint64Flag{
+		Name:   "bandwidth.retrieval",
+		Usage:  "Upstream bandwidth cap of serving retrieve requests in bytes per second (0 = no cap)",
+		EnvVar: SwarmEnvBandwidthRetrieval,
+	}
+	SwarmBandwidthPssFlag = cli.Uint64Flag{
+		Name:   "bandwidth.pss",
+		Usage:  "Upstream bandwidth cap of pss forwarding in bytes per second (0 = no cap)",
+		EnvVar: SwarmEnvBandwidthPss,
+	}
+	SwarmHandoffOnShutdownFlag = cli.BoolFlag{
+		Name:   "handoff-on-shutdown",
+		Usage:  "Push the chunks in the node's area of responsibility to its nearest neighbours before shutting down",
+		EnvVar: SwarmEnvHandoffOnShutdown,
+	}
+	SwarmHandoffTimeoutFlag = cli.DurationFlag{
+		Name:  "handoff-timeout",
+		Usage: "Maximum time spent handing off chunks on shutdown (default: 5m)",
+	}
+	SwarmObfuscateRetrievalFlag = cli.BoolFlag{
+		Name:   "retrieval.obfuscate",
+		Usage:  "Forward retrieve requests of light clients as the node's own after a random delay to hide their origin",
+		EnvVar: SwarmEnvObfuscateRetrieval,
+	}
+	SwarmObfuscationMaxDelayFlag = cli.DurationFlag{
+		Name:  "retrieval.obfuscate.maxdelay",
+		Usage: "Maximum random delay before a retrieve request of a light client is forwarded (default: 500ms)",
+	}
+	SwarmObfuscationCoverIntervalFlag = cli.DurationFlag{
+		Name:  "retrieval.obfuscate.cover",
+		Usage: "Mean interval between cover retrieve requests for random chunks, paid for by the node (default: disabled)",
+	}
+	SwarmRetryCorruptChunksFlag = cli.BoolFlag{
+		Name:   "retrieval.retry-corrupt",
+		Usage:  "Immediately request a chunk from another peer when a peer delivers it with invalid content",
+		EnvVar: SwarmEnvRetryCorruptChunks,
+	}
+	SwarmProvenanceWindowFlag = cli.DurationFlag{
+		Name:   "retrieval.provenance-window",
+		Usage:  "Track the most requested chunks and their requesting peers over this sliding window, exposed by retrieval_hotChunks (default: disabled)",
+		EnvVar: SwarmEnvProvenanceWindow,
+	}
+	SwarmRetrievalWorkersFlag = cli.IntFlag{
+		Name:   "retrieval.workers",
+		Usage:  "Maximum number of retrieve requests, and of chunk deliveries, handled concurrently (default: 512)",
+		EnvVar: SwarmEnvRetrievalWorkers,
+	}
+	SwarmRetrievalQueueFlag = cli.IntFlag{
+		Name:  "retrieval.queue",
+		Usage: "Maximum number of retrieval messages of a peer waiting or being handled, after which its retrieve requests are dropped and reading its chunk deliveries is paused (default: 64)",
+	}
+	SwarmSyncWorkersFlag = cli.IntFlag{
+		Name:   "sync.workers",
+		Usage:  "Maximum number of syncing messages of each kind handled concurrently (default: 512)",
+		EnvVar: SwarmEnvSyncWorkers,
+	}
+	SwarmSyncQueueFlag = cli.IntFlag{
+		Name:  "sync.queue",
+		Usage: "Maximum number of syncing messages of each kind of a peer waiting or being handled, after which reading from the peer is paused (default: 64)",
+	}
+	SwarmSyncFragmentSizeFlag = cli.UintFlag{
+		Name:   "sync.fragment-size",
+		Usage:  "Split syncing messages longer than this many bytes into fragments, all nodes of the network must use the same setting (default: disabled)",
+		EnvVar: SwarmEnvSyncFragmentSize,
+	}
+	SwarmFeedDeltaIntervalFlag = cli.IntFlag{
+		Name:   "feeds.delta-interval",
+		Usage:  "Store feed updates created by this node as deltas against the previous update, with a full update every this many updates (default: disabled)",
+		EnvVar: SwarmEnvFeedDeltaInterval,
+	}
+	SwarmTimestampIntervalFlag = cli.DurationFlag{
+		Name:   "timestamp.interval",
+		Usage:  "Anchor the Merkle root of the root hashes of recently uploaded content in an Ethereum transaction at this interval (default: disabled)",
+		EnvVar: SwarmEnvTimestampInterval,
+	}
+	SwarmTimestampBackendFlag = cli.StringFlag{
+		Name:   "timestamp.backend",
+		Usage:  "Ethereum API endpoint the timestamp anchoring transactions are sent to, paid for by the bzz account",
+		EnvVar: SwarmEnvTimestampBackend,
+	}
+	SwarmClockToleranceFlag = cli.DurationFlag{
+		Name:   "clock.tolerance",
+		Usage:  "Offset of the host clock to peers up to which feed updates and chunk expiry hints are tolerated, above which a warning is logged (default: 30s)",
+		EnvVar: SwarmEnvClockTolerance,
+	}
+	SwarmNTPServerFlag = cli.StringFlag{
+		Name:   "clock.ntp-server",
+		Usage:  "NTP server the host clock is checked against on startup (default: disabled)",
+		EnvVar: SwarmEnvNTPServer,
+	}
+	SwarmUploadMaxSizeFlag = cli.Int64Flag{
+		Name:   "upload.max-size",
+		Usage:  "Largest upload in bytes accepted by the HTTP API (default: unlimited)",
+		EnvVar: SwarmEnvUploadMaxSize,
+	}
+	SwarmUploadContentTypesFlag = cli.StringFlag{
+		Name:   "upload.content-types",
+		Usage:  "Comma separated list of media types, like image/png or image/*, of uploads accepted by the HTTP API; collections are uploaded as application/x-tar or multipart/form-data (default: all)",
+		EnvVar: SwarmEnvUploadContentTypes,
+	}
+	SwarmUnderlaysFlag = cli.StringFlag{
+		Name:   "underlays",
+		Usage:  "Comma separated alternative underlays the node is reachable at and advertises to peers, like wss://gateway.example.org:443",
+		EnvVar: SwarmEnvUnderlays,
+	}
+	SwarmWSUnderlayAddrFlag = cli.StringFlag{
+		Name:   "ws-underlay.addr",
+		Usage:  "Listen address of the websocket underlay, for peers behind firewalls which only allow web traffic. Advertise it with --underlays",
+		EnvVar: SwarmEnvWSUnderlayAddr,
+	}
+	SwarmWSUnderlayCertFlag = cli.StringFlag{
+		Name:   "ws-underlay.cert",
+		Usage:  "TLS certificate file to serve the websocket underlay as wss",
+		EnvVar: SwarmEnvWSUnderlayCert,
+	}
+	SwarmWSUnderlayKeyFlag = cli.StringFlag{
+		Name:   "ws-underlay.key",
+		Usage:  "TLS key file to serve the websocket underlay as wss",
+		EnvVar: SwarmEnvWSUnderlayKey,
+	}
+	SwarmWSUnderlayNoDialFlag = cli.BoolFlag{
+		Name:   "ws-underlay.no-dial",
+		Usage:  "Do not dial peers over the ws and wss underlays they advertise",
+		EnvVar: SwarmEnvWSUnderlayNoDial,
+	}
+	SwarmUploadScanURLFlag = cli.StringFlag{
+		Name:   "upload.scan-url",
+		Usage:  "URL of a scanning service every upload to the HTTP API is posted to, accepted only if it responds with a 2xx status code",
+		EnvVar: SwarmEnvUploadScanURL,
+	}
+	SwarmUploadHealthyFlag = cli.BoolFlag{
+		Name:   "upload.healthy",
+		Usage:  "Refuse uploads to the HTTP API with 503 while the node has no peers, its kademlia is not saturated, its local store is over capacity or pull syncing lags behind",
+		EnvVar: SwarmEnvUploadHealthy,
+	}
+	SwarmUploadMaxSyncLagFlag = cli.Uint64Flag{
+		Name:   "upload.max-sync-lag",
+		Usage:  "Number of chunks pull syncing may lag behind peers before uploads are refused with --upload.healthy",
+		Value:  api.DefaultUploadMaxSyncLag,
+		EnvVar: SwarmEnvUploadMaxSyncLag,
+	}
+	SwarmStandbyPrimaryFlag = cli.StringFlag{
+		Name:   "standby.primary",
+		Usage:  "Run as a warm standby continuously mirroring the localstore and pins of the primary node with this enode URL",
+		EnvVar: SwarmEnvStandbyPrimary,
+	}
+	SwarmStandbyPeersFlag = cli.StringFlag{
+		Name:   "standby.peers",
+		Usage:  "Comma separated list of enode URLs or public keys of the standby nodes allowed to mirror this node",
+		EnvVar: SwarmEnvStandbyPeers,
+	}
+	SwarmAllowPeersFlag = cli.StringFlag{
+		Name:   "peers.allow",
+		Usage:  "Comma separated list of node IDs, enode URLs, IP addresses or CIDR ranges of the only peers allowed to connect",
+		EnvVar: SwarmEnvAllowPeers,
+	}
+	SwarmDenyPeersFlag = cli.StringFlag{
+		Name:   "peers.deny",
+		Usage:  "Comma separated list of node IDs, enode URLs, IP addresses or CIDR ranges of peers not allowed to connect",
+		EnvVar: SwarmEnvDenyPeers,
+	}
 	SwarmFeedNameFlag = cli.StringFlag{
 		Name:  "name",
 		Usage: "User-defined name for the new feed, limited to 32 characters. If combined with topic, it will refer to a subtopic with this name",
@@ -214,6 +594,18 @@ var (
 		Name:  "user",
 		Usage: "Indicates the user who updates the feed",
 	}
+	SwarmFeedSignerFlag = cli.StringFlag{
+		Name:  "signer",
+		Usage: "External signer (clef) endpoint used to sign the update with the --user account",
+	}
+	SwarmFeedSignatureFlag = cli.StringFlag{
+		Name:  "signature",
+		Usage: "Hex encoded signature of the update made beforehand by the --user account",
+	}
+	SwarmFeedDigestFlag = cli.BoolFlag{
+		Name:  "digest",
+		Usage: "Print the digest of the update the --user account has to sign instead of publishing it",
+	}
 	SwarmGlobalStoreAPIFlag = cli.StringFlag{
 		Name:   "globalstore-api",
 		Usage:  "URL of the Global Store API provider (only for testing)",
@@ -227,14 +619,53 @@ var (
 		Name:  "pin",
 		Usage: "Use this flag to pin the file after upload is complete. This flag is used when uploading a file.",
 	}
+	SwarmPinRawFlag = cli.BoolFlag{
+		Name:  "raw",
+		Usage: "Pin the hash as a raw file instead of a collection",
+	}
+	SwarmPinFetchFlag = cli.BoolFlag{
+		Name:  "fetch",
+		Usage: "Retrieve the missing chunks from the network and pin them again",
+	}
+	SwarmEnsNameFlag = cli.StringFlag{
+		Name:  "ens-name",
+		Usage: "ENS name owned by the bzzaccount to point to the uploaded content",
+	}
+	SwarmEnsGasPriceFlag = cli.StringFlag{
+		Name:  "gasprice",
+		Usage: "Gas price in wei of the ENS transaction, suggested by the ENS endpoint if not set",
+	}
+	SwarmEnsGasLimitFlag = cli.Uint64Flag{
+		Name:  "gaslimit",
+		Usage: "Gas limit of the ENS transaction",
+		Value: ens.DefaultPublishGasLimit,
+	}
+	SwarmPssTopicFlag = cli.StringFlag{
+		Name:  "topic",
+		Usage: "Topic of the pss messages, as hex or topic name",
+		Value: "chat",
+	}
+	SwarmPssContactsFlag = cli.StringFlag{
+		Name:  "contacts",
+		Usage: "Path to the pss contacts file, pss-contacts.json in the data directory by default",
+	}
 	SwarmEnablePinningFlag = cli.BoolFlag{
 		Name:  "enable-pinning",
 		Usage: "Use this flag to enable the pinning feature",
 	}
+	SwarmChunkEventsFlag = cli.BoolFlag{
+		Name:   "chunk-events",
+		Usage:  "Emit chunk lifecycle events and expose them with the bzz_subscribe(\"chunkEvents\") RPC subscription",
+		EnvVar: SwarmEnvChunkEvents,
+	}
 	SwarmProgressFlag = cli.BoolFlag{
 		Name:  "progress",
 		Usage: "Use this flag to enable tracking of the upload progress through the CLI",
 	}
+	SwarmSyncFlag = cli.BoolFlag{
+		Name:  "sync",
+		Usage: "Use this flag to upload only the changes of a directory against the manifest a feed points to and update the feed",
+	}
 	SwarmAnonymousUploadFlag = cli.BoolFlag{
 		Name:  "anonymous",
 		Usage: "use this flag to upload anonymously",
@@ -243,6 +674,18 @@ var (
 		Name:  "verbose",
 		Usage: "Display more verbose output",
 	}
+	SwarmReplayLogFlag = cli.StringFlag{
+		Name:  "replay.log",
+		Usage: "Record protocol messages to a replay log at the given path",
+	}
+	SwarmReplayProtocolsFlag = cli.StringFlag{
+		Name:  "replay.protocols",
+		Usage: "Comma separated list of protocols to record in the replay log (default: all)",
+	}
+	SwarmReplayPeersFlag = cli.StringFlag{
+		Name:  "replay.peers",
+		Usage: "Comma separated list of enode ids of peers to record in the replay log (default: all)",
+	}
 	SwarmMutexProfileFlag = cli.BoolFlag{
 		Name:  "mutex-profile",
 		Usage: "Enable pprof mutex profile",
@@ -251,4 +694,12 @@ var (
 		Name:  "block-profile",
 		Usage: "Enable pprof block profile",
 	}
+	SwarmNewAccountFlag = cli.StringFlag{
+		Name:  "new-bzzaccount",
+		Usage: "Swarm account of the new overlay address, a new account is created if empty",
+	}
+	SwarmNeighbourhoodDepthFlag = cli.UintFlag{
+		Name:  "depth",
+		Usage: "Proximity order to the new overlay address below which chunks are discarded, unless pinned or not yet push synced (0 keeps all chunks)",
+	}
 )
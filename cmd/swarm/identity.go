@@ -0,0 +1,253 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	bzzapi "github.com/ethersphere/swarm/api"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/storage/localstore"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// nodeKeyFile is the name of the node key file in the instance directory
+const nodeKeyFile = "nodekey"
+
+var identityCommand = cli.Command{
+	Name:               "identity",
+	CustomHelpTemplate: helpTemplate,
+	Usage:              "rotate the node key or the overlay address of the node",
+	ArgsUsage:          "identity COMMAND",
+	Description:        "Rotate the node key or the overlay address of the node. Stop the node before rotating.",
+	Subcommands: []cli.Command{
+		{
+			Action:             identityRotateNodeKey,
+			CustomHelpTemplate: helpTemplate,
+			Name:               "rotate-nodekey",
+			Usage:              "replace the node key which identifies the node in the p2p network",
+			Flags:              []cli.Flag{SwarmDryRunFlag},
+			Description: `Replace the node key which identifies the node in the p2p network.
+
+    swarm --datadir ~/.ethereum identity rotate-nodekey
+
+The enode id of the node changes, its overlay address and local chunks do
+not. The old node key is kept next to the new one. With --dry-run the
+consequences are reported without replacing the key.`,
+		},
+		{
+			Action:             identityRotateOverlay,
+			CustomHelpTemplate: helpTemplate,
+			Name:               "rotate-overlay",
+			Usage:              "move the node to the overlay address of a new swarm account",
+			Flags:              []cli.Flag{SwarmNewAccountFlag, SwarmNeighbourhoodDepthFlag, SwarmDryRunFlag},
+			Description: `Move the node to the overlay address of a new swarm account.
+
+    swarm --datadir ~/.ethereum --bzzaccount OLD identity rotate-overlay --depth 2
+
+The overlay address is derived from the swarm account, so a new account
+is created in the keystore, unless an existing one is given with
+--new-bzzaccount. The chunks of the local chunk database are moved to the
+data directory of the new account, as they are indexed by their proximity
+to the overlay address. Chunks with a proximity to the new overlay address
+lower than --depth are outside of the new neighbourhood and are discarded,
+unless they are pinned or not yet push synced. The data directory of the
+old account is not changed. With --dry-run the consequences are reported
+without creating an account or moving chunks.`,
+		},
+	},
+}
+
+// newIdentityNode returns the swarm and node configuration and a node which
+// is not started, to access the keystore and the data directory
+func newIdentityNode(ctx *cli.Context) (*bzzapi.Config, *node.Config, *node.Node) {
+	bzzconfig, err := buildConfig(ctx)
+	if err != nil {
+		utils.Fatalf("unable to configure swarm: %v", err)
+	}
+	cfg := defaultNodeConfig
+	if _, err := os.Stat(bzzconfig.Path); err == nil {
+		cfg.DataDir = bzzconfig.Path
+	}
+	cfg.NoUSB = true
+	utils.SetNodeConfig(ctx, &cfg)
+	stack, err := node.New(&cfg)
+	if err != nil {
+		utils.Fatalf("can't create node: %v", err)
+	}
+	return bzzconfig, &cfg, stack
+}
+
+func identityRotateNodeKey(ctx *cli.Context) {
+	_, cfg, stack := newIdentityNode(ctx)
+	defer stack.Close()
+
+	if cfg.P2P.PrivateKey != nil {
+		utils.Fatalf("the node key is given with --%s or --%s, replace it there", utils.NodeKeyFileFlag.Name, utils.NodeKeyHexFlag.Name)
+	}
+	if cfg.DataDir == "" {
+		utils.Fatalf("the data directory does not exist, the node has no node key yet")
+	}
+	keyfile := cfg.ResolvePath(nodeKeyFile)
+	oldKey, err := crypto.LoadECDSA(keyfile)
+	if err != nil {
+		utils.Fatalf("can't load node key: %v", err)
+	}
+	newKey, err := crypto.GenerateKey()
+	if err != nil {
+		utils.Fatalf("can't generate node key: %v", err)
+	}
+
+	w := os.Stdout
+	fmt.Fprintf(w, "node key:     %s\n", keyfile)
+	fmt.Fprintf(w, "old enode id: %s\n", enode.PubkeyToIDV4(&oldKey.PublicKey))
+	fmt.Fprintf(w, "new enode id: %s\n", enode.PubkeyToIDV4(&newKey.PublicKey))
+	fmt.Fprintln(w, "consequences:")
+	fmt.Fprintln(w, "  - the overlay address and the local chunks do not change")
+	fmt.Fprintln(w, "  - static and trusted node lists of other nodes need the new enode URL")
+	fmt.Fprintln(w, "  - peers account swap balances and cheques by enode id, settle them before rotating")
+
+	if ctx.Bool(SwarmDryRunFlag.Name) {
+		fmt.Fprintln(w, "dry run, the node key is not replaced")
+		return
+	}
+	backup := keyfile + "." + time.Now().UTC().Format("20060102T150405Z")
+	if err := os.Rename(keyfile, backup); err != nil {
+		utils.Fatalf("can't back up node key: %v", err)
+	}
+	if err := crypto.SaveECDSA(keyfile, newKey); err != nil {
+		utils.Fatalf("can't save node key, restore it from %s: %v", backup, err)
+	}
+	fmt.Fprintf(w, "node key replaced, the old one is kept in %s\n", backup)
+}
+
+func identityRotateOverlay(ctx *cli.Context) {
+	bzzconfig, _, stack := newIdentityNode(ctx)
+	defer stack.Close()
+
+	dryRun := ctx.Bool(SwarmDryRunFlag.Name)
+	depth := ctx.Uint(SwarmNeighbourhoodDepthFlag.Name)
+	if depth > 255 {
+		utils.Fatalf("invalid depth %d", depth)
+	}
+	passwords := utils.MakePasswordList(ctx)
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+
+	_, oldKey := getOrCreateAccount(ctx, stack)
+	var newKey *ecdsa.PrivateKey
+	var password string
+	if account := ctx.String(SwarmNewAccountFlag.Name); account != "" {
+		newKey = decryptStoreAccount(ks, account, passwords)
+	} else {
+		var err error
+		if newKey, err = crypto.GenerateKey(); err != nil {
+			utils.Fatalf("can't generate swarm account key: %v", err)
+		}
+		if !dryRun {
+			password = getPassPhrase("Your new account is locked with a password. Please give a password. Do not forget this password.", true, 0, passwords)
+		}
+	}
+	oldAddress := crypto.PubkeyToAddress(oldKey.PublicKey)
+	newAddress := crypto.PubkeyToAddress(newKey.PublicKey)
+	if oldAddress == newAddress {
+		utils.Fatalf("the new swarm account is the current one")
+	}
+
+	oldDir := filepath.Join(stack.InstanceDir(), "bzz-"+common.Bytes2Hex(oldAddress.Bytes()))
+	newDir := filepath.Join(stack.InstanceDir(), "bzz-"+common.Bytes2Hex(newAddress.Bytes()))
+	oldChunks := filepath.Join(oldDir, "chunks")
+	newChunks := filepath.Join(newDir, "chunks")
+	newBaseKey := network.PrivateKeyToBzzKey(newKey)
+
+	var report localstore.RekeyReport
+	if _, err := os.Stat(filepath.Join(oldChunks, "CURRENT")); err == nil {
+		if localstore.IsLegacyDatabase(oldChunks) {
+			utils.Fatalf("the local chunk database %s has a legacy schema, start the node once to migrate it", oldChunks)
+		}
+		if _, err := os.Stat(newChunks); err == nil {
+			utils.Fatalf("the new swarm account already has a local chunk database %s", newChunks)
+		}
+		// the database is locked while a node is running
+		store, err := localstore.New(oldChunks, network.PrivateKeyToBzzKey(oldKey), &localstore.Options{
+			ReadOnly: true,
+		})
+		if err != nil {
+			utils.Fatalf("error opening local chunk database, is the node stopped? %v", err)
+		}
+		defer store.Close()
+
+		var to *localstore.DB
+		if !dryRun {
+			to, err = localstore.New(newChunks, newBaseKey, &localstore.Options{
+				Capacity: bzzconfig.DbCapacity,
+				Engine:   bzzconfig.DbEngine,
+			})
+			if err != nil {
+				utils.Fatalf("error creating local chunk database: %v", err)
+			}
+			defer to.Close()
+		}
+		if report, err = store.Rekey(to, newBaseKey, uint8(depth)); err != nil {
+			utils.Fatalf("error moving chunks, remove %s before retrying: %v", newChunks, err)
+		}
+	}
+
+	if !dryRun && password != "" {
+		if _, err := ks.ImportECDSA(newKey, password); err != nil {
+			utils.Fatalf("error creating swarm account: %v", err)
+		}
+	}
+
+	w := os.Stdout
+	fmt.Fprintf(w, "old account:      %s\n", oldAddress.Hex())
+	fmt.Fprintf(w, "old overlay:      %x\n", network.PrivateKeyToBzzKey(oldKey))
+	fmt.Fprintf(w, "new account:      %s\n", newAddress.Hex())
+	fmt.Fprintf(w, "new overlay:      %x\n", newBaseKey)
+	printRekeyReport(w, report, uint8(depth))
+	fmt.Fprintln(w, "consequences:")
+	fmt.Fprintf(w, "  - start the node with --%s %s to use the new overlay address\n", SwarmAccountFlag.Name, newAddress.Hex())
+	fmt.Fprintf(w, "  - %s is not changed, it keeps the discarded chunks until it is removed\n", oldDir)
+	fmt.Fprintln(w, "  - the address book, swap balances, cheques and the chequebook stay with the old account")
+	fmt.Fprintln(w, "  - peers learn the new overlay address only after reconnecting")
+	if dryRun {
+		if ctx.String(SwarmNewAccountFlag.Name) == "" {
+			fmt.Fprintln(w, "dry run, no account is created and the new overlay address is an example")
+		} else {
+			fmt.Fprintln(w, "dry run, no chunks are moved")
+		}
+	}
+}
+
+// printRekeyReport writes how the chunks are moved to the new overlay address in a human readable form.
+func printRekeyReport(w io.Writer, report localstore.RekeyReport, depth uint8) {
+	fmt.Fprintf(w, "chunks:           %d\n", report.Chunks)
+	fmt.Fprintf(w, "kept:             %d within depth %d\n", report.Kept, depth)
+	fmt.Fprintf(w, "kept pinned:      %d\n", report.Pinned)
+	fmt.Fprintf(w, "kept to push:     %d\n", report.PushPending)
+	fmt.Fprintf(w, "discarded:        %d\n", report.Discarded)
+}
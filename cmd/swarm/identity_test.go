@@ -0,0 +1,164 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/storage"
+	"github.com/ethersphere/swarm/storage/localstore"
+	"github.com/ethersphere/swarm/testutil"
+)
+
+// TestIdentityRotateNodeKey tests that the node key is replaced
+// and the old one is kept, unless it is a dry run
+func TestIdentityRotateNodeKey(t *testing.T) {
+	if runtime.GOOS == goosWindows {
+		t.Skip()
+	}
+	dir, err := ioutil.TempDir("", "swarm-identity-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyfile := filepath.Join(dir, clientIdentifier, nodeKeyFile)
+	if err := os.MkdirAll(filepath.Dir(keyfile), 0700); err != nil {
+		t.Fatal(err)
+	}
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crypto.SaveECDSA(keyfile, key); err != nil {
+		t.Fatal(err)
+	}
+	keyAddress := crypto.PubkeyToAddress(key.PublicKey)
+
+	dryRun := runSwarm(t, "--datadir", dir, "identity", "rotate-nodekey", "--dry-run")
+	dryRun.ExpectRegexp(`(?s)old enode id:.*dry run, the node key is not replaced\n`)
+	dryRun.ExpectExit()
+	loaded, err := crypto.LoadECDSA(keyfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crypto.PubkeyToAddress(loaded.PublicKey) != keyAddress {
+		t.Fatal("node key replaced in a dry run")
+	}
+
+	rotate := runSwarm(t, "--datadir", dir, "identity", "rotate-nodekey")
+	_, matches := rotate.ExpectRegexp(`(?s)old enode id:.*the old one is kept in (\S+)\n`)
+	rotate.ExpectExit()
+	loaded, err = crypto.LoadECDSA(keyfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crypto.PubkeyToAddress(loaded.PublicKey) == keyAddress {
+		t.Fatal("node key not replaced")
+	}
+	backup, err := crypto.LoadECDSA(matches[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crypto.PubkeyToAddress(backup.PublicKey) != keyAddress {
+		t.Fatal("old node key not kept")
+	}
+}
+
+// TestIdentityRotateOverlay tests that a new account is created
+// and the chunks are moved to its data directory
+func TestIdentityRotateOverlay(t *testing.T) {
+	if runtime.GOOS == goosWindows {
+		t.Skip()
+	}
+	dir, err := ioutil.TempDir("", "swarm-identity-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stack, err := node.New(&node.Config{
+		DataDir: dir,
+		NoUSB:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := ks.ImportECDSA(key, testPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stack.Close()
+
+	bzzDir := func(address common.Address) string {
+		return filepath.Join(dir, clientIdentifier, "bzz-"+common.Bytes2Hex(address.Bytes()))
+	}
+	store, err := localstore.New(filepath.Join(bzzDir(account.Address), "chunks"), network.PrivateKeyToBzzKey(key), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := storage.GenerateRandomChunk(chunk.DefaultSize)
+	if _, err := store.Put(context.Background(), chunk.ModePutUpload, ch); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	passwordFile := testutil.TempFileWithContent(t, testPassphrase)
+	defer os.Remove(passwordFile)
+
+	rotate := runSwarm(t, "--datadir", dir, "--bzzaccount", account.Address.Hex(), "--password", passwordFile, "identity", "rotate-overlay", "--depth", "255")
+	_, matches := rotate.ExpectRegexp(`(?s)new account:\s+(0x[0-9a-fA-F]{40}).*chunks:\s+1\n.*kept to push:\s+1\n`)
+	rotate.ExpectExit()
+
+	newAddress := common.HexToAddress(matches[1])
+	keyfiles, err := filepath.Glob(filepath.Join(dir, "keystore", "*"+common.Bytes2Hex(newAddress.Bytes())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keyfiles) != 1 {
+		t.Fatalf("got %d key files of the new account, want 1", len(keyfiles))
+	}
+	// the base key is not needed to look up chunks
+	moved, err := localstore.New(filepath.Join(bzzDir(newAddress), "chunks"), make([]byte, 32), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer moved.Close()
+	has, err := moved.Has(context.Background(), ch.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !has {
+		t.Fatal("chunk not moved to the data directory of the new account")
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
@@ -56,6 +57,9 @@ import (
 )
 
 const clientIdentifier = "swarm"
+
+// underlayDialTimeout is the timeout of dialing a single underlay of a peer
+const underlayDialTimeout = 15 * time.Second
 const helpTemplate = `NAME:
 {{.HelpName}} - {{.Usage}}
 
@@ -144,10 +148,22 @@ func init() {
 		manifestCommand,
 		// See fs.go
 		fsCommand,
+		// See pin.go
+		pinCommand,
+		ensCommand,
+		// See pss.go
+		pssCommand,
 		// See db.go
 		dbCommand,
+		// See identity.go
+		identityCommand,
+		// See backup.go
+		backupCommand,
+		// See replay.go
+		replayCommand,
 		// See config.go
 		DumpConfigCommand,
+		configCommand,
 		// hashesCommand
 		hashesCommand,
 	}
@@ -184,20 +200,68 @@ func init() {
 		SwarmSwapBackendURLFlag,
 		SwarmSwapDisconnectThresholdFlag,
 		SwarmSwapPaymentThresholdFlag,
+		SwarmSwapGraceAllowanceFlag,
+		SwarmSwapGraceDecayRateFlag,
+		SwarmSwapPriceOracleFlag,
+		SwarmSwapHoneyPriceFlag,
+		SwarmSwapPriceToleranceFlag,
 		SwarmSwapLogPathFlag,
+		SwarmSwapBalanceLogFlag,
+		SwarmSwapBalanceLogSizeFlag,
+		SwarmSwapBalanceLogFilesFlag,
+		SwarmSwapThrottleFractionFlag,
+		SwarmSwapThrottleMaxDelayFlag,
+		SwarmSwapReconcileToleranceFlag,
+		SwarmSwapPaymentRequestThresholdFlag,
+		SwarmSwapOwnerLockedFlag,
+		SwarmSwapInterestDebtLevelFlag,
+		SwarmSwapInterestGracePeriodFlag,
+		SwarmSwapInterestRateFlag,
+		SwarmSwapPayOnlyFlag,
+		SwarmSwapCashingIntervalFlag,
+		SwarmSwapCashingThresholdFlag,
+		SwarmSwapCashoutMinAmountFlag,
+		SwarmSwapCashoutMaxDelayFlag,
+		SwarmSwapCashoutMaxGasPriceFlag,
+		SwarmSwapExemptPeersFlag,
+		SwarmSwapAssetsFlag,
+		SwarmSwapTokenChequebooksFlag,
 		SwarmSwapChequebookAddrFlag,
 		SwarmSwapChequebookFactoryFlag,
 		SwarmSwapSkipDepositFlag,
 		SwarmSwapDepositAmountFlag,
+		SwarmAnnouncePricesFlag,
 		// end of swap flags
+		SwarmRoleFlag,
 		SwarmNoSyncFlag,
 		SwarmLightNodeEnabled,
 		SwarmListenAddrFlag,
+		SwarmUnderlaysFlag,
+		SwarmWSUnderlayAddrFlag,
+		SwarmWSUnderlayCertFlag,
+		SwarmWSUnderlayKeyFlag,
+		SwarmWSUnderlayNoDialFlag,
 		SwarmPortFlag,
 		SwarmAccountFlag,
 		SwarmBzzKeyHexFlag,
 		SwarmNetworkIdFlag,
+		SwarmNetworkForkIdFlag,
+		SwarmNetworkKeyFlag,
+		SwarmHandshakeRateLimitFlag,
+		SwarmHandshakePuzzleFlag,
 		SwarmEnablePinningFlag,
+		SwarmChunkEventsFlag,
+		SwarmBandwidthDailyCapFlag,
+		SwarmBandwidthUpstreamFlag,
+		SwarmBandwidthSyncFlag,
+		SwarmBandwidthRetrievalFlag,
+		SwarmBandwidthPssFlag,
+		// pss bridge flags
+		SwarmPssBridgeWebhookFlag,
+		SwarmPssBridgeTopicsFlag,
+		SwarmPssBridgeRawFlag,
+		SwarmPssBridgeAddrFlag,
+		SwarmPssBridgeSecretFlag,
 		// upload flags
 		SwarmApiFlag,
 		SwarmRecursiveFlag,
@@ -205,17 +269,50 @@ func init() {
 		SwarmUploadDefaultPath,
 		SwarmUpFromStdinFlag,
 		SwarmUploadMimeType,
+		SwarmHandoffOnShutdownFlag,
+		SwarmHandoffTimeoutFlag,
+		SwarmObfuscateRetrievalFlag,
+		SwarmObfuscationMaxDelayFlag,
+		SwarmObfuscationCoverIntervalFlag,
+		SwarmRetryCorruptChunksFlag,
+		SwarmProvenanceWindowFlag,
+		SwarmRetrievalWorkersFlag,
+		SwarmRetrievalQueueFlag,
+		SwarmSyncWorkersFlag,
+		SwarmSyncQueueFlag,
+		SwarmSyncFragmentSizeFlag,
+		SwarmFeedDeltaIntervalFlag,
+		SwarmTimestampIntervalFlag,
+		SwarmTimestampBackendFlag,
+		SwarmClockToleranceFlag,
+		SwarmNTPServerFlag,
+		SwarmUploadMaxSizeFlag,
+		SwarmUploadContentTypesFlag,
+		SwarmUploadScanURLFlag,
+		SwarmUploadHealthyFlag,
+		SwarmUploadMaxSyncLagFlag,
+		SwarmStandbyPrimaryFlag,
+		SwarmStandbyPeersFlag,
+		SwarmAllowPeersFlag,
+		SwarmDenyPeersFlag,
 		// bootnode mode
 		SwarmBootnodeModeFlag,
 		SwarmDisableAutoConnectFlag,
+		SwarmPeerUptimeBiasFlag,
 		// storage flags
 		SwarmStorePath,
 		SwarmStoreCapacity,
+		SwarmStoreEngine,
+		SwarmStoreReadOnly,
+		SwarmStoreEncryptState,
 		SwarmStoreCacheCapacity,
 		SwarmGlobalStoreAPIFlag,
 		// debugging
 		SwarmMutexProfileFlag,
 		SwarmBlockProfileFlag,
+		SwarmReplayLogFlag,
+		SwarmReplayProtocolsFlag,
+		SwarmReplayPeersFlag,
 	}
 	rpcFlags := []cli.Flag{
 		utils.WSEnabledFlag,
@@ -328,6 +425,10 @@ func bzzd(ctx *cli.Context) error {
 	// start any custom pprof profiles
 	pprofProfiles(ctx)
 
+	// optionally record protocol messages to a replay log
+	stopReplayLog := setupReplayLog(ctx)
+	defer stopReplayLog()
+
 	//optionally set the bootnodes before configuring the node
 	setSwarmBootstrapNodes(ctx, &cfg)
 	//setup the ethereum node
@@ -336,6 +437,16 @@ func bzzd(ctx *cli.Context) error {
 	//disable dynamic dialing from p2p/discovery
 	cfg.P2P.NoDial = true
 
+	//dial peers over the best of the underlays they advertise
+	dialer := network.NewUnderlayDialer(underlayDialTimeout)
+	if bzzconfig.WSUnderlayDial {
+		dialWebSocket := network.DialWebSocket(underlayDialTimeout)
+		dialer.RegisterTransport(network.TransportWSS, dialWebSocket)
+		dialer.RegisterTransport(network.TransportWS, dialWebSocket)
+	}
+	cfg.P2P.Dialer = dialer
+	bzzconfig.HiveParams.Dialer = dialer
+
 	//optionally set the NAT IP from a network interface
 	setSwarmNATFromInterface(ctx, &cfg)
 
@@ -351,6 +462,8 @@ func bzzd(ctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	//the chequebook owner key is used through the keystore
+	bzzconfig.SetKeystore(stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore))
 	//register BZZ as node.Service in the ethereum node
 	registerBzzService(bzzconfig, stack)
 	//start the node
@@ -365,6 +478,17 @@ func bzzd(ctx *cli.Context) error {
 		stack.Stop()
 	}()
 
+	// reload the configuration values that are safe to change on sighup
+	// or on the bzz_reloadConfig rpc call
+	var s *swarm.Swarm
+	if err := stack.Service(&s); err != nil {
+		utils.Fatalf("Failed to retrieve the Swarm service: %v", err)
+	}
+	s.SetConfigLoader(func() (*bzzapi.Config, error) {
+		return buildConfig(ctx)
+	})
+	go reloadConfigOnSighup(s, ctx)
+
 	// add swarm bootnodes, because swarm doesn't use p2p package's discovery discv5
 	go func() {
 		s := stack.Server()
@@ -378,6 +502,30 @@ func bzzd(ctx *cli.Context) error {
 	return nil
 }
 
+// reloadConfigOnSighup builds the configuration again and applies the values
+// that are safe to change to the running node every time sighup is received
+func reloadConfigOnSighup(s *swarm.Swarm, ctx *cli.Context) {
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGHUP)
+	defer signal.Stop(sigc)
+	for range sigc {
+		log.Info("Got sighup, reloading swarm configuration...")
+		config, err := buildConfig(ctx)
+		if err != nil {
+			log.Error("Unable to reload swarm configuration", "err", err)
+			continue
+		}
+		reload, err := s.ReloadConfig(config)
+		if err != nil {
+			log.Error("Unable to reload swarm configuration", "err", err)
+			continue
+		}
+		if len(reload.RequiresRestart) > 0 {
+			log.Warn("Changed swarm configuration values require a restart", "values", reload.RequiresRestart)
+		}
+	}
+}
+
 func registerBzzService(bzzconfig *bzzapi.Config, stack *node.Node) {
 	//define the swarm service boot function
 	boot := func(_ *node.ServiceContext) (node.Service, error) {
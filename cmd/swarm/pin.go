@@ -0,0 +1,160 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethersphere/swarm/storage/pin"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var pinCommand = cli.Command{
+	Name:               "pin",
+	CustomHelpTemplate: helpTemplate,
+	Usage:              "manage pinned files and collections",
+	ArgsUsage:          "pin COMMAND",
+	Description:        "Pins, unpins, lists and verifies files and collections on the local node. This assumes you already have a Swarm node with pinning enabled running locally. For all operations you must reference the correct path to bzzd.ipc in order to communicate with the node",
+	Subcommands: []cli.Command{
+		{
+			Action:             pinAdd,
+			CustomHelpTemplate: helpTemplate,
+			Name:               "add",
+			Usage:              "pin a file or collection",
+			ArgsUsage:          "<hash>",
+			Description:        "Pins the file or collection with the given root hash, which must already be stored on the local node",
+			Flags:              []cli.Flag{SwarmPinRawFlag, SwarmAccessPasswordFlag},
+		},
+		{
+			Action:             pinRemove,
+			CustomHelpTemplate: helpTemplate,
+			Name:               "rm",
+			Usage:              "unpin a file or collection",
+			ArgsUsage:          "<hash>",
+			Description:        "Unpins the file or collection with the given root hash",
+			Flags:              []cli.Flag{SwarmAccessPasswordFlag},
+		},
+		{
+			Action:             pinList,
+			CustomHelpTemplate: helpTemplate,
+			Name:               "list",
+			Usage:              "list pinned files and collections",
+			ArgsUsage:          " ",
+			Description:        "Lists the root hashes of all pinned files and collections with their size and pin counter",
+		},
+		{
+			Action:             pinVerify,
+			CustomHelpTemplate: helpTemplate,
+			Name:               "verify",
+			Usage:              "verify that all chunks of a pinned file or collection are stored locally",
+			ArgsUsage:          "<hash>",
+			Description: `Walks the chunk tree of a pinned file or collection and prints the chunks missing
+from the local store. With --fetch the missing chunks are retrieved from the network and
+pinned again.`,
+			Flags: []cli.Flag{SwarmPinFetchFlag, SwarmAccessPasswordFlag},
+		},
+	},
+}
+
+// pinInfo is the JSON form of pin.PinInfo as returned by the RPC API
+type pinInfo struct {
+	Address    string
+	IsRaw      bool
+	FileSize   uint64
+	PinCounter uint64
+}
+
+func pinAdd(ctx *cli.Context) {
+	addr := pinHashArg(ctx)
+	callPin(ctx, nil, "pin_pin", addr, ctx.Bool(SwarmPinRawFlag.Name), ctx.String(SwarmAccessPasswordFlag.Name))
+}
+
+func pinRemove(ctx *cli.Context) {
+	addr := pinHashArg(ctx)
+	callPin(ctx, nil, "pin_unpin", addr, ctx.String(SwarmAccessPasswordFlag.Name))
+}
+
+func pinList(ctx *cli.Context) {
+	var pins []pinInfo
+	callPin(ctx, &pins, "pin_list")
+
+	w := tabwriter.NewWriter(os.Stdout, 1, 2, 2, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintln(w, "HASH\tRAW\tSIZE\tPINS")
+	for _, p := range pins {
+		fmt.Fprintf(w, "%s\t%t\t%d\t%d\n", p.Address, p.IsRaw, p.FileSize, p.PinCounter)
+	}
+}
+
+func pinVerify(ctx *cli.Context) {
+	addr := pinHashArg(ctx)
+	fetch := ctx.Bool(SwarmPinFetchFlag.Name)
+	var result pin.VerifyResult
+	callPin(ctx, &result, "pin_verify", addr, ctx.String(SwarmAccessPasswordFlag.Name), fetch)
+
+	for _, missing := range result.Missing {
+		fmt.Println(missing)
+	}
+	switch {
+	case len(result.Missing) == 0:
+		fmt.Fprintln(os.Stderr, "all chunks are stored locally")
+	case fetch:
+		fmt.Fprintf(os.Stderr, "%d chunks were missing, %d fetched and pinned again\n", len(result.Missing), result.Fetched)
+		if result.Fetched < len(result.Missing) {
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "%d chunks are missing\n", len(result.Missing))
+		os.Exit(1)
+	}
+}
+
+func pinHashArg(ctx *cli.Context) hexutil.Bytes {
+	args := ctx.Args()
+	if len(args) != 1 {
+		utils.Fatalf("Need the root hash as the first and only argument")
+	}
+	addr, err := hexutil.Decode("0x" + strings.TrimPrefix(args[0], "0x"))
+	if err != nil {
+		utils.Fatalf("Invalid hash %q: %v", args[0], err)
+	}
+	return addr
+}
+
+// callPin calls a method of the pinning RPC API of the local node
+func callPin(ctx *cli.Context, result interface{}, method string, args ...interface{}) {
+	client, err := dialRPC(ctx)
+	if err != nil {
+		utils.Fatalf("had an error dailing to RPC endpoint: %v", err)
+	}
+	defer client.Close()
+
+	// verifying and pinning large collections can take a while
+	rpcCtx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	if err := client.CallContext(rpcCtx, result, method, args...); err != nil {
+		utils.Fatalf("had an error calling %s: %v", method, err)
+	}
+}
@@ -0,0 +1,374 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethersphere/swarm/pss"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var pssCommand = cli.Command{
+	Name:               "pss",
+	CustomHelpTemplate: helpTemplate,
+	Usage:              "send and receive pss messages",
+	ArgsUsage:          "pss COMMAND",
+	Description: `Sends and receives asymmetrically encrypted pss messages through the local node,
+addressed to named contacts. Exchange the output of "swarm pss id" with a contact and add
+it with "swarm pss contact add", then run "swarm pss listen" and "swarm pss send" to chat.
+This assumes you already have a Swarm node running locally. For all operations you must
+reference the correct path to bzzd.ipc in order to communicate with the node`,
+	Subcommands: []cli.Command{
+		{
+			Action:             pssID,
+			CustomHelpTemplate: helpTemplate,
+			Name:               "id",
+			Usage:              "print the public key and the address of the local node",
+			ArgsUsage:          " ",
+			Description:        "Prints the pss public key and the overlay address of the local node, which contacts need to send messages to it",
+		},
+		{
+			CustomHelpTemplate: helpTemplate,
+			Name:               "contact",
+			Usage:              "manage pss contacts",
+			ArgsUsage:          "contact COMMAND",
+			Description:        "Manages the named contacts messages are sent to and received from",
+			Subcommands: []cli.Command{
+				{
+					Action:             pssContactAdd,
+					CustomHelpTemplate: helpTemplate,
+					Name:               "add",
+					Usage:              "add or replace a contact",
+					ArgsUsage:          "<name> <public key> [<address>]",
+					Description:        "Adds a contact with the public key and optionally the overlay address printed by \"swarm pss id\" on its node. Without an address messages are sent to all nodes, which is slower and costlier",
+					Flags:              []cli.Flag{SwarmPssContactsFlag},
+				},
+				{
+					Action:             pssContactRemove,
+					CustomHelpTemplate: helpTemplate,
+					Name:               "rm",
+					Usage:              "remove a contact",
+					ArgsUsage:          "<name>",
+					Description:        "Removes the named contact",
+					Flags:              []cli.Flag{SwarmPssContactsFlag},
+				},
+				{
+					Action:             pssContactList,
+					CustomHelpTemplate: helpTemplate,
+					Name:               "list",
+					Usage:              "list contacts",
+					ArgsUsage:          " ",
+					Description:        "Lists the names, public keys and addresses of all contacts",
+					Flags:              []cli.Flag{SwarmPssContactsFlag},
+				},
+			},
+		},
+		{
+			Action:             pssSend,
+			CustomHelpTemplate: helpTemplate,
+			Name:               "send",
+			Usage:              "send a message to a contact",
+			ArgsUsage:          "<name> [<message>]",
+			Description:        "Sends the message to the named contact on the topic. Without a message every line read from stdin is sent as a message",
+			Flags:              []cli.Flag{SwarmPssTopicFlag, SwarmPssContactsFlag},
+		},
+		{
+			Action:             pssListen,
+			CustomHelpTemplate: helpTemplate,
+			Name:               "listen",
+			Usage:              "print messages received on a topic",
+			ArgsUsage:          " ",
+			Description:        "Prints the messages received on the topic until interrupted, prefixed with the name of the sending contact or its public key if it is not a contact",
+			Flags:              []cli.Flag{SwarmPssTopicFlag, SwarmPssContactsFlag},
+		},
+	},
+}
+
+// pssContact is a named pss peer messages are sent to and received from
+type pssContact struct {
+	Name      string        `json:"name"`
+	PublicKey hexutil.Bytes `json:"publicKey"`
+	Address   hexutil.Bytes `json:"address,omitempty"`
+}
+
+// pssContacts are the contacts stored in a file, by name
+type pssContacts map[string]*pssContact
+
+// loadPssContacts reads the contacts from the file,
+// a missing file holds no contacts
+func loadPssContacts(path string) (pssContacts, error) {
+	contacts := make(pssContacts)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return contacts, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []*pssContact
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("invalid contacts file %s: %v", path, err)
+	}
+	for _, c := range list {
+		contacts[c.Name] = c
+	}
+	return contacts, nil
+}
+
+// save writes the contacts to the file ordered by name
+func (contacts pssContacts) save(path string) error {
+	list := contacts.list()
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// list returns the contacts ordered by name
+func (contacts pssContacts) list() []*pssContact {
+	list := make([]*pssContact, 0, len(contacts))
+	for _, c := range contacts {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// add validates the public key and the address of the contact and adds it,
+// replacing a contact with the same name
+func (contacts pssContacts) add(name, pubkey, address string) error {
+	if name == "" {
+		return fmt.Errorf("empty contact name")
+	}
+	key, err := hexutil.Decode(pubkey)
+	if err != nil {
+		return fmt.Errorf("invalid public key %q: %v", pubkey, err)
+	}
+	if _, err := crypto.UnmarshalPubkey(key); err != nil {
+		return fmt.Errorf("invalid public key %q: %v", pubkey, err)
+	}
+	var addr hexutil.Bytes
+	if address != "" {
+		if addr, err = hexutil.Decode(address); err != nil {
+			return fmt.Errorf("invalid address %q: %v", address, err)
+		}
+	}
+	contacts[name] = &pssContact{
+		Name:      name,
+		PublicKey: key,
+		Address:   addr,
+	}
+	return nil
+}
+
+// name returns the name of the contact with the public key
+// as reported by pss for received messages, or the key itself
+func (contacts pssContacts) name(keyid string) string {
+	for _, c := range contacts {
+		if c.PublicKey.String() == keyid {
+			return c.Name
+		}
+	}
+	return keyid
+}
+
+// pssContactsPath returns the path of the contacts file, by default in the data directory
+func pssContactsPath(ctx *cli.Context) string {
+	if path := ctx.String(SwarmPssContactsFlag.Name); path != "" {
+		return expandPath(path)
+	}
+	cfg := defaultNodeConfig
+	utils.SetNodeConfig(ctx, &cfg)
+	return filepath.Join(cfg.DataDir, "pss-contacts.json")
+}
+
+func mustLoadPssContacts(ctx *cli.Context) (pssContacts, string) {
+	path := pssContactsPath(ctx)
+	contacts, err := loadPssContacts(path)
+	if err != nil {
+		utils.Fatalf("Unable to load pss contacts: %v", err)
+	}
+	return contacts, path
+}
+
+func pssID(ctx *cli.Context) {
+	client := dialPss(ctx)
+	defer client.Close()
+
+	var pubkey hexutil.Bytes
+	if err := client.Call(&pubkey, "pss_getPublicKey"); err != nil {
+		utils.Fatalf("Unable to get the pss public key: %v", err)
+	}
+	var addr pss.PssAddress
+	if err := client.Call(&addr, "pss_baseAddr"); err != nil {
+		utils.Fatalf("Unable to get the pss address: %v", err)
+	}
+	fmt.Printf("public key: %s\naddress:    %s\n", pubkey, hexutil.Bytes(addr))
+}
+
+func pssContactAdd(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) < 2 || len(args) > 3 {
+		utils.Fatalf("Need the contact name, its public key and optionally its address as arguments")
+	}
+	contacts, path := mustLoadPssContacts(ctx)
+	var address string
+	if len(args) == 3 {
+		address = args[2]
+	}
+	if err := contacts.add(args[0], args[1], address); err != nil {
+		utils.Fatalf("Unable to add contact: %v", err)
+	}
+	if err := contacts.save(path); err != nil {
+		utils.Fatalf("Unable to save pss contacts: %v", err)
+	}
+}
+
+func pssContactRemove(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) != 1 {
+		utils.Fatalf("Need the contact name as the first and only argument")
+	}
+	contacts, path := mustLoadPssContacts(ctx)
+	if _, ok := contacts[args[0]]; !ok {
+		utils.Fatalf("Unknown contact %q", args[0])
+	}
+	delete(contacts, args[0])
+	if err := contacts.save(path); err != nil {
+		utils.Fatalf("Unable to save pss contacts: %v", err)
+	}
+}
+
+func pssContactList(ctx *cli.Context) {
+	contacts, _ := mustLoadPssContacts(ctx)
+
+	w := tabwriter.NewWriter(os.Stdout, 1, 2, 2, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintln(w, "NAME\tPUBLIC KEY\tADDRESS")
+	for _, c := range contacts.list() {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", c.Name, c.PublicKey, c.Address)
+	}
+}
+
+func pssSend(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) < 1 {
+		utils.Fatalf("Need the contact name as the first argument")
+	}
+	contacts, _ := mustLoadPssContacts(ctx)
+	contact, ok := contacts[args[0]]
+	if !ok {
+		utils.Fatalf("Unknown contact %q, add it with \"swarm pss contact add\"", args[0])
+	}
+	topic := parseTopic(ctx.String(SwarmPssTopicFlag.Name))
+
+	client := dialPss(ctx)
+	defer client.Close()
+
+	// the node encrypts messages to the contact only for topics its key is set for
+	if err := client.Call(nil, "pss_setPeerPublicKey", contact.PublicKey, topic, contact.Address); err != nil {
+		utils.Fatalf("Unable to set the public key of %s: %v", contact.Name, err)
+	}
+	send := func(msg string) {
+		if err := client.Call(nil, "pss_sendAsym", contact.PublicKey.String(), topic, hexutil.Bytes(msg)); err != nil {
+			utils.Fatalf("Unable to send message to %s: %v", contact.Name, err)
+		}
+	}
+
+	if len(args) > 1 {
+		send(strings.Join(args[1:], " "))
+		return
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			send(line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		utils.Fatalf("Unable to read messages: %v", err)
+	}
+}
+
+func pssListen(ctx *cli.Context) {
+	contacts, _ := mustLoadPssContacts(ctx)
+	topic := parseTopic(ctx.String(SwarmPssTopicFlag.Name))
+
+	client := dialPss(ctx)
+	defer client.Close()
+
+	// set the keys of all contacts so that replies can be sent on the topic
+	for _, c := range contacts {
+		if err := client.Call(nil, "pss_setPeerPublicKey", c.PublicKey, topic, c.Address); err != nil {
+			utils.Fatalf("Unable to set the public key of %s: %v", c.Name, err)
+		}
+	}
+
+	msgs := make(chan pss.APIMsg)
+	sub, err := client.Subscribe(context.Background(), "pss", msgs, "receive", topic, false, false)
+	if err != nil {
+		utils.Fatalf("Unable to subscribe to topic %s: %v", topic, err)
+	}
+	defer sub.Unsubscribe()
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigc)
+
+	for {
+		select {
+		case msg := <-msgs:
+			fmt.Printf("%s [%s] %s\n", time.Now().Format("15:04:05"), contacts.name(msg.Key), string(msg.Msg))
+		case err := <-sub.Err():
+			utils.Fatalf("Subscription to topic %s failed: %v", topic, err)
+		case <-sigc:
+			return
+		}
+	}
+}
+
+// dialPss connects to the RPC endpoint of the local node
+func dialPss(ctx *cli.Context) *rpc.Client {
+	client, err := dialRPC(ctx)
+	if err != nil {
+		utils.Fatalf("had an error dailing to RPC endpoint: %v", err)
+	}
+	return client
+}
@@ -0,0 +1,126 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// TestPssContacts checks that contacts are validated, stored and
+// resolved by the public keys pss reports for received messages
+func TestPssContacts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swarm-pss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "contacts", "pss-contacts.json")
+
+	contacts, err := loadPssContacts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contacts) != 0 {
+		t.Fatalf("expected no contacts without a file, got %d", len(contacts))
+	}
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubkey := hexutil.Encode(crypto.FromECDSAPub(&key.PublicKey))
+
+	if err := contacts.add("bob", "0x1234", ""); err == nil {
+		t.Fatal("expected invalid public key to be rejected")
+	}
+	if err := contacts.add("bob", pubkey, "0xzz"); err == nil {
+		t.Fatal("expected invalid address to be rejected")
+	}
+	if err := contacts.add("bob", pubkey, "0xabcd"); err != nil {
+		t.Fatal(err)
+	}
+	if err := contacts.save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	contacts, err = loadPssContacts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, ok := contacts["bob"]
+	if !ok {
+		t.Fatal("expected saved contact to be loaded")
+	}
+	if bob.PublicKey.String() != pubkey || bob.Address.String() != "0xabcd" {
+		t.Fatalf("got contact with public key %s and address %s, want %s and 0xabcd", bob.PublicKey, bob.Address, pubkey)
+	}
+	if name := contacts.name(pubkey); name != "bob" {
+		t.Fatalf("got name %q for the key of a contact, want bob", name)
+	}
+	if name := contacts.name("0x04ff"); name != "0x04ff" {
+		t.Fatalf("got name %q for an unknown key, want the key", name)
+	}
+}
+
+// TestCLIPss sends a message between two nodes with the pss send and listen commands
+func TestCLIPss(t *testing.T) {
+	cluster := newTestCluster(t, 2)
+	defer cluster.Shutdown()
+
+	alice, bob := cluster.Nodes[0], cluster.Nodes[1]
+	aliceContacts := filepath.Join(cluster.TmpDir, "alice-contacts.json")
+	bobContacts := filepath.Join(cluster.TmpDir, "bob-contacts.json")
+
+	// id prints the public key and the address of a node
+	id := func(node *testNode) (pubkey, addr string) {
+		cmd := runSwarm(t, "--datadir", node.Dir, "pss", "id")
+		_, matches := cmd.ExpectRegexp(`public key: (0x[0-9a-f]+)\naddress:    (0x[0-9a-f]+)\n`)
+		cmd.ExpectExit()
+		return matches[1], matches[2]
+	}
+	addContact := func(node *testNode, contacts, name, pubkey, addr string) {
+		cmd := runSwarm(t, "--datadir", node.Dir, "pss", "contact", "add", "--contacts", contacts, name, pubkey, addr)
+		cmd.ExpectExit()
+		if cmd.ExitStatus() != 0 {
+			t.Fatalf("adding contact %s failed: %s", name, cmd.StderrText())
+		}
+	}
+	alicePubkey, aliceAddr := id(alice)
+	bobPubkey, bobAddr := id(bob)
+	addContact(alice, aliceContacts, "bob", bobPubkey, bobAddr)
+	addContact(bob, bobContacts, "alice", alicePubkey, aliceAddr)
+
+	listen := runSwarm(t, "--datadir", alice.Dir, "pss", "listen", "--contacts", aliceContacts)
+	defer listen.Kill()
+	// give the listener time to subscribe
+	time.Sleep(time.Second)
+
+	send := runSwarm(t, "--datadir", bob.Dir, "pss", "send", "--contacts", bobContacts, "alice", "hello", "alice")
+	send.ExpectExit()
+	if send.ExitStatus() != 0 {
+		t.Fatalf("sending message failed: %s", send.StderrText())
+	}
+
+	listen.ExpectRegexp(`\d\d:\d\d:\d\d \[bob\] hello alice\n`)
+}
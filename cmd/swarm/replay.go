@@ -0,0 +1,137 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/p2p/protocols"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var replayCommand = cli.Command{
+	Action:             replayDump,
+	CustomHelpTemplate: helpTemplate,
+	Name:               "replay",
+	Usage:              "print the protocol messages recorded in a replay log",
+	ArgsUsage:          "<file>",
+	Description: `Print the protocol messages recorded in a replay log.
+
+    swarm replay ~/.ethereum/swarm/replay.log
+
+A replay log is recorded by a node started with --replay.log, optionally
+limited to some protocols and peers with --replay.protocols and --replay.peers.
+The same flags filter the printed messages. The incoming messages of a
+protocol can be replayed against its handler in unit tests with
+protocols.Replay.`,
+	Flags: []cli.Flag{
+		SwarmReplayProtocolsFlag,
+		SwarmReplayPeersFlag,
+		SwarmVerboseFlag,
+	},
+}
+
+func replayDump(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) != 1 {
+		utils.Fatalf("invalid arguments, please specify the replay log <file>")
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		utils.Fatalf("error opening replay log: %v", err)
+	}
+	defer f.Close()
+
+	protos := splitList(ctx.String(SwarmReplayProtocolsFlag.Name))
+	peers, err := parsePeerIDs(ctx.String(SwarmReplayPeersFlag.Name))
+	if err != nil {
+		utils.Fatalf("invalid peers: %v", err)
+	}
+	filter := protocols.NewReplayRecorder(nil, protos, peers)
+
+	r := protocols.NewReplayReader(f)
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			utils.Fatalf("error reading replay log: %v", err)
+		}
+		if !filter.Records(rec.Protocol, rec.Peer) {
+			continue
+		}
+		fmt.Println(rec)
+		if ctx.Bool(SwarmVerboseFlag.Name) {
+			fmt.Println(hex.EncodeToString(rec.Data))
+		}
+	}
+}
+
+// setupReplayLog starts recording protocol messages if a replay log is
+// configured and returns a function that stops recording
+func setupReplayLog(ctx *cli.Context) func() {
+	path := ctx.GlobalString(SwarmReplayLogFlag.Name)
+	if path == "" {
+		return func() {}
+	}
+	peers, err := parsePeerIDs(ctx.GlobalString(SwarmReplayPeersFlag.Name))
+	if err != nil {
+		utils.Fatalf("invalid %s: %v", SwarmReplayPeersFlag.Name, err)
+	}
+	r, err := protocols.OpenReplayRecorder(path, splitList(ctx.GlobalString(SwarmReplayProtocolsFlag.Name)), peers)
+	if err != nil {
+		utils.Fatalf("can't open replay log: %v", err)
+	}
+	log.Info("recording protocol messages", "replay.log", path)
+	protocols.SetReplayRecorder(r)
+	return func() {
+		protocols.SetReplayRecorder(nil)
+		if err := r.Close(); err != nil {
+			log.Error("closing replay log", "err", err)
+		}
+	}
+}
+
+// splitList splits a comma separated list, ignoring empty elements
+func splitList(s string) (list []string) {
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+// parsePeerIDs parses a comma separated list of hex encoded enode ids
+func parsePeerIDs(s string) (ids []enode.ID, err error) {
+	for _, e := range splitList(s) {
+		var id enode.ID
+		if err := id.UnmarshalText([]byte(e)); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -18,6 +18,7 @@
 package main
 
 import (
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -47,9 +48,17 @@ var (
 		CustomHelpTemplate: helpTemplate,
 		Name:               "up",
 		Usage:              "uploads a file or directory to swarm using the HTTP API",
-		ArgsUsage:          "<file>",
-		Flags:              []cli.Flag{SwarmEncryptedFlag, SwarmPinFlag, SwarmProgressFlag, SwarmVerboseFlag},
-		Description:        "uploads a file or directory to swarm using the HTTP API and prints the root hash",
+		ArgsUsage:          "<file> | --sync <dir> <feed>",
+		Flags:              []cli.Flag{SwarmEncryptedFlag, SwarmPinFlag, SwarmProgressFlag, SwarmVerboseFlag, SwarmSyncFlag, SwarmEnsNameFlag, SwarmEnsGasPriceFlag, SwarmEnsGasLimitFlag, SwarmDryRunFlag},
+		Description: `uploads a file or directory to swarm using the HTTP API and prints the root hash
+
+With --sync, the directory is compared against the manifest the feed with the given
+feed manifest address or ENS name currently points to. Only new and changed files are
+uploaded, removed files are dropped from the manifest and the feed is updated with the
+resulting manifest, which is signed with the bzzaccount.
+
+With --ens-name, the content hash record of the ENS name is set to the root hash once
+the upload is done. The name must be owned by the bzzaccount of the node.`,
 	}
 
 	pollDelay   = 200 * time.Millisecond
@@ -79,6 +88,7 @@ func upload(ctx *cli.Context) {
 		toPin           = ctx.Bool(SwarmPinFlag.Name)
 		progress        = ctx.Bool(SwarmProgressFlag.Name)
 		anon            = ctx.Bool(SwarmAnonymousUploadFlag.Name)
+		ensName         = ctx.String(SwarmEnsNameFlag.Name)
 		autoDefaultPath = false
 		file            string
 	)
@@ -92,6 +102,19 @@ func upload(ctx *cli.Context) {
 		}
 		autoDefaultPath = b
 	}
+	if ensName != "" && (toEncrypt || ctx.Bool(SwarmSyncFlag.Name)) {
+		utils.Fatalf("--%s can not be used with --%s or --%s", SwarmEnsNameFlag.Name, SwarmEncryptedFlag.Name, SwarmSyncFlag.Name)
+	}
+	if ctx.Bool(SwarmSyncFlag.Name) {
+		if len(args) != 2 {
+			utils.Fatalf("Need a directory and a feed as arguments to --%s", SwarmSyncFlag.Name)
+		}
+		if toEncrypt {
+			utils.Fatalf("Feeds can only point to unencrypted manifests")
+		}
+		syncUpload(ctx, client, expandPath(args[0]), args[1], defaultPath, toPin, anon)
+		return
+	}
 	if len(args) != 1 {
 		if fromStdin {
 			tmp, err := ioutil.TempFile("", "swarm-stdin")
@@ -124,6 +147,9 @@ func upload(ctx *cli.Context) {
 			utils.Fatalf("Upload failed: %s", err)
 		}
 		fmt.Println(hash)
+		if ensName != "" {
+			publish(ctx, ensName, hash)
+		}
 		return
 	}
 
@@ -185,6 +211,9 @@ func upload(ctx *cli.Context) {
 	// dont show the progress bar if `progress` flag is not set
 	if !progress {
 		fmt.Println(hash)
+		if ensName != "" {
+			publish(ctx, ensName, hash)
+		}
 		return
 	}
 
@@ -209,6 +238,67 @@ func upload(ctx *cli.Context) {
 
 	fmt.Println("Done! took", time.Since(start))
 	fmt.Println("Your Swarm hash should now be retrievable from other nodes!")
+	if ensName != "" {
+		publish(ctx, ensName, hash)
+	}
+}
+
+// syncUpload uploads the changes of a directory against the manifest the feed
+// currently points to and updates the feed with the new manifest
+func syncUpload(ctx *cli.Context, client *client.Client, dir, feedManifest, defaultPath string, toPin, anon bool) {
+	request, err := client.GetFeedRequest(nil, feedManifest)
+	if err != nil {
+		utils.Fatalf("Error retrieving feed status: %s", err)
+	}
+
+	var current string
+	r, err := client.QueryFeed(nil, feedManifest)
+	switch err {
+	case nil:
+		data, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			utils.Fatalf("Error reading feed content: %s", err)
+		}
+		current = hex.EncodeToString(data)
+	case swarm.ErrNoFeedUpdatesFound:
+	default:
+		utils.Fatalf("Error retrieving feed content: %s", err)
+	}
+
+	if defaultPath != "" {
+		absDefaultPath, _ := filepath.Abs(defaultPath)
+		absDir, _ := filepath.Abs(dir)
+		if rel, err := filepath.Rel(absDir, absDefaultPath); err == nil && !strings.HasPrefix(rel, "..") {
+			defaultPath = rel
+		}
+	}
+	result, err := client.SyncDirectory(dir, defaultPath, current, false, toPin, anon)
+	if err != nil {
+		utils.Fatalf("Sync failed: %s", err)
+	}
+	log.Info("synced directory", "uploaded", len(result.Uploaded), "removed", len(result.Removed), "unchanged", result.Unchanged)
+	if result.Manifest == current {
+		fmt.Println(current)
+		return
+	}
+
+	addr, err := hex.DecodeString(result.Manifest)
+	if err != nil {
+		utils.Fatalf("Invalid manifest hash %q: %s", result.Manifest, err)
+	}
+	request.SetData(addr)
+	signer := NewGenericSigner(ctx)
+	if request.User != signer.Address() {
+		utils.Fatalf("Signer address does not match the feed owner")
+	}
+	if err := request.Sign(signer); err != nil {
+		utils.Fatalf("Error signing feed update: %s", err)
+	}
+	if err := client.UpdateFeed(request); err != nil {
+		utils.Fatalf("Error updating feed: %s", err)
+	}
+	fmt.Println(result.Manifest)
 }
 
 func pollTag(client *client.Client, hash string, tag *chunk.Tag, bars map[string]*mpb.Bar) {
@@ -124,3 +124,71 @@ func TestENS(t *testing.T) {
 		t.Fatalf("resolve error, expected %v, got %v", hash.Hex(), resolvedHash.Hex())
 	}
 }
+
+func TestPublish(t *testing.T) {
+	contractBackend := backends.NewSimulatedBackend(core.GenesisAlloc{addr: {Balance: big.NewInt(1000000000)}}, 10000000)
+	transactOpts := bind.NewKeyedTransactor(key)
+
+	ensAddr, ens, err := DeployENS(transactOpts, contractBackend)
+	if err != nil {
+		t.Fatalf("can't deploy root registry: %v", err)
+	}
+	contractBackend.Commit()
+
+	// a name owned by another account can not be published
+	if _, err := ens.Publish(name, hash, PublishOptions{}); err != ErrNotOwner {
+		t.Fatalf("expected error %v, got %v", ErrNotOwner, err)
+	}
+
+	if _, err := ens.Register(name); err != nil {
+		t.Fatalf("can't register: %v", err)
+	}
+	contractBackend.Commit()
+
+	if _, err := ens.Publish(name, hash, PublishOptions{}); err != ErrNoResolver {
+		t.Fatalf("expected error %v, got %v", ErrNoResolver, err)
+	}
+
+	resolverAddr, _, _, err := contract.DeployPublicResolver(transactOpts, contractBackend, ensAddr)
+	if err != nil {
+		t.Fatalf("can't deploy resolver: %v", err)
+	}
+	if _, err := ens.SetResolver(EnsNode(name), resolverAddr); err != nil {
+		t.Fatalf("can't set resolver: %v", err)
+	}
+	contractBackend.Commit()
+
+	// a dry run signs the transaction with the given gas controls without sending it
+	gasPrice := big.NewInt(3)
+	tx, err := ens.Publish(name, hash, PublishOptions{GasPrice: gasPrice, GasLimit: 100000, DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if tx.Gas() != 100000 {
+		t.Fatalf("expected gas limit 100000, got %d", tx.Gas())
+	}
+	if tx.GasPrice().Cmp(gasPrice) != 0 {
+		t.Fatalf("expected gas price %v, got %v", gasPrice, tx.GasPrice())
+	}
+	contractBackend.Commit()
+	if _, err := ens.Resolve(name); err == nil {
+		t.Fatal("expected no content hash after a dry run")
+	}
+
+	tx, err = ens.Publish(name, hash, PublishOptions{})
+	if err != nil {
+		t.Fatalf("can't publish: %v", err)
+	}
+	if tx.Gas() != DefaultPublishGasLimit {
+		t.Fatalf("expected gas limit %d, got %d", DefaultPublishGasLimit, tx.Gas())
+	}
+	contractBackend.Commit()
+
+	resolvedHash, err := ens.Resolve(name)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if resolvedHash != hash {
+		t.Fatalf("resolve error, expected %v, got %v", hash.Hex(), resolvedHash.Hex())
+	}
+}
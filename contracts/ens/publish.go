@@ -0,0 +1,119 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package ens
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// DefaultPublishGasLimit is the gas limit of content hash updates if none is given
+const DefaultPublishGasLimit = 200000
+
+var (
+	// ErrNotOwner is returned by Publish if the name is not owned by the account of the transactor
+	ErrNotOwner = errors.New("name is not owned by the account")
+	// ErrNoResolver is returned by Publish if no resolver is set for the name
+	ErrNoResolver = errors.New("no resolver set for the name")
+
+	errDryRun = errors.New("dry run")
+)
+
+// PublishOptions controls the transaction sent by Publish
+type PublishOptions struct {
+	GasPrice *big.Int // gas price, suggested by the backend if nil
+	GasLimit uint64   // gas limit, DefaultPublishGasLimit if zero
+	DryRun   bool     // sign the transaction without sending it
+}
+
+// Publish points the content hash record of a name owned by the account of the
+// transactor to the swarm hash. It returns the signed transaction, which is only
+// sent if opts.DryRun is false.
+func (ens *ENS) Publish(name string, hash common.Hash, opts PublishOptions) (*types.Transaction, error) {
+	node := EnsNode(name)
+
+	owner, err := ens.Owner(node)
+	if err != nil {
+		return nil, err
+	}
+	if owner != ens.TransactOpts.From {
+		return nil, ErrNotOwner
+	}
+	resolverAddr, err := ens.Resolver(node)
+	if err != nil {
+		return nil, err
+	}
+	if resolverAddr == (common.Address{}) {
+		return nil, ErrNoResolver
+	}
+	resolver, err := ens.getResolver(node)
+	if err != nil {
+		return nil, err
+	}
+
+	transactOpts := ens.TransactOpts
+	transactOpts.GasPrice = opts.GasPrice
+	transactOpts.GasLimit = opts.GasLimit
+	if transactOpts.GasLimit == 0 {
+		transactOpts.GasLimit = DefaultPublishGasLimit
+	}
+
+	// the signer is the last step before the transaction is sent,
+	// so failing it after signing keeps the transaction local
+	var signed *types.Transaction
+	if opts.DryRun {
+		sign := transactOpts.Signer
+		transactOpts.Signer = func(signer types.Signer, addr common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			tx, err := sign(signer, addr, tx)
+			if err != nil {
+				return nil, err
+			}
+			signed = tx
+			return nil, errDryRun
+		}
+	}
+
+	// IMPORTANT: The old contract is deprecated. This code should be removed latest on June 1st 2019
+	supported, err := resolver.SupportsInterface(contentHash_Interface_Id)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx *types.Transaction
+	if !supported {
+		fallback, ferr := ens.getFallbackResolver(node)
+		if ferr != nil {
+			return nil, ferr
+		}
+		tx, err = fallback.Contract.SetContent(&transactOpts, node, hash)
+	} else {
+		// END DEPRECATED CODE
+		var cid []byte
+		cid, err = EncodeSwarmHash(hash)
+		if err != nil {
+			return nil, err
+		}
+		tx, err = resolver.Contract.SetContenthash(&transactOpts, node, cid)
+	}
+	if err == errDryRun {
+		return signed, nil
+	}
+	return tx, err
+}
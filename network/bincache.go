@@ -0,0 +1,161 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/pot"
+)
+
+// binCache keeps the most recently advertised known but unconnected peers
+// of every proximity order bin, up to a size per bin, as candidates to
+// replace dropped connections without waiting for new discovery rounds.
+// It is not safe for concurrent use and is protected by the kademlia lock.
+type binCache struct {
+	size int                     // maximum number of peers per bin
+	ttl  time.Duration           // time after which a peer not advertised again is removed
+	bins map[int][]binCacheEntry // peers per bin, the most recently advertised last
+}
+
+// binCacheEntry is a cached peer and the time it was last advertised
+type binCacheEntry struct {
+	addr *BzzAddr
+	seen time.Time
+}
+
+func newBinCache(size int, ttl time.Duration) *binCache {
+	return &binCache{
+		size: size,
+		ttl:  ttl,
+		bins: make(map[int][]binCacheEntry),
+	}
+}
+
+// add adds or refreshes the peer in bin po, evicting
+// the least recently advertised peer if the bin is full
+func (c *binCache) add(po int, a *BzzAddr, now time.Time) {
+	if c.size <= 0 {
+		return
+	}
+	bin := c.without(po, a.Address())
+	if len(bin) >= c.size {
+		bin = bin[len(bin)-c.size+1:]
+	}
+	c.bins[po] = append(bin, binCacheEntry{addr: a, seen: now})
+}
+
+// remove removes the peer with overlay address addr from bin po
+func (c *binCache) remove(po int, addr []byte) {
+	if bin := c.without(po, addr); len(bin) > 0 {
+		c.bins[po] = bin
+	} else {
+		delete(c.bins, po)
+	}
+}
+
+// without returns the peers in bin po except the one with overlay address addr
+func (c *binCache) without(po int, addr []byte) []binCacheEntry {
+	bin := c.bins[po]
+	for i, e := range bin {
+		if bytes.Equal(e.addr.Address(), addr) {
+			return append(bin[:i:i], bin[i+1:]...)
+		}
+	}
+	return bin
+}
+
+// take removes and returns the most recently advertised peer in bin po
+// accepted by the accept function, or nil if there is none. Peers not
+// accepted and peers not advertised within ttl are removed.
+func (c *binCache) take(po int, now time.Time, accept func(*BzzAddr) bool) (a *BzzAddr) {
+	bin := c.bins[po]
+	for len(bin) > 0 {
+		e := bin[len(bin)-1]
+		if now.Sub(e.seen) > c.ttl {
+			// all less recently advertised peers are stale too
+			bin = nil
+			break
+		}
+		bin = bin[:len(bin)-1]
+		if accept(e.addr) {
+			a = e.addr
+			break
+		}
+	}
+	if len(bin) > 0 {
+		c.bins[po] = bin
+	} else {
+		delete(c.bins, po)
+	}
+	return a
+}
+
+// len returns the number of cached peers in bin po
+func (c *binCache) len(po int) int {
+	return len(c.bins[po])
+}
+
+// Replacement returns a known but unconnected peer from the bin cache to
+// dial in place of the dropped peer at addr, if the bin of the dropped peer
+// has less connections than expected or is within the neighbourhood depth.
+// It returns nil if there is no need for a replacement or no cached peer
+// can be dialed.
+func (k *Kademlia) Replacement(addr *BzzAddr) *BzzAddr {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	po := chunk.Proximity(k.base, addr.Address())
+	var size int
+	k.defaultIndex.conns.EachBin(k.base, Pof, po, func(bin *pot.Bin) bool {
+		if bin.ProximityOrder == po {
+			size = bin.Size
+		}
+		return false
+	}, true)
+	if size >= k.expectedMinBinSize(po) && po < depthForPot(k.defaultIndex.conns, k.NeighbourhoodSize, k.base) {
+		return nil
+	}
+
+	replacement := k.binCache.take(po, k.clock.Time(), func(a *BzzAddr) bool {
+		var e *entry
+		k.defaultIndex.addrs.EachNeighbour(a, Pof, func(v pot.Val, _ int) bool {
+			if bytes.Equal(v.(*entry).Address(), a.Address()) {
+				e = v.(*entry)
+			}
+			return false
+		})
+		if e == nil || e.conn != nil || e.retries > k.MaxRetries {
+			return false
+		}
+		if k.errorBudget.banned(e.Address(), k.clock.Time()) {
+			return false
+		}
+		if k.Reachable != nil && !k.Reachable(e.BzzAddr) {
+			return false
+		}
+		e.retries++
+		return true
+	})
+	if replacement != nil {
+		metrics.GetOrRegisterCounter("kad.bincache.replacement", nil).Inc(1)
+	}
+	return replacement
+}
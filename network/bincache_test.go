@@ -0,0 +1,88 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"testing"
+	"time"
+)
+
+// TestBinCache checks that the bin cache keeps the most recently
+// advertised peers up to its size and skips stale ones
+func TestBinCache(t *testing.T) {
+	c := newBinCache(2, time.Minute)
+	now := time.Now()
+	a := testKadPeerAddr("10000000")
+	b := testKadPeerAddr("11000000")
+	d := testKadPeerAddr("10100000")
+	acceptAll := func(*BzzAddr) bool { return true }
+
+	c.add(0, a, now)
+	c.add(0, b, now)
+	// refreshing a makes b the least recently advertised one
+	c.add(0, a, now.Add(time.Second))
+	c.add(0, d, now.Add(2*time.Second))
+	if got := c.len(0); got != 2 {
+		t.Fatalf("got %v cached peers, want 2", got)
+	}
+	if got := c.take(0, now, acceptAll); got != d {
+		t.Fatalf("got %v, want %v", got, d)
+	}
+	if got := c.take(0, now, acceptAll); got != a {
+		t.Fatalf("got %v, want %v", got, a)
+	}
+	if got := c.take(0, now, acceptAll); got != nil {
+		t.Fatalf("got %v from an empty bin", got)
+	}
+
+	// not accepted and stale peers are removed
+	c.add(1, a, now)
+	c.add(1, b, now.Add(2*time.Minute))
+	if got := c.take(1, now.Add(2*time.Minute), func(*BzzAddr) bool { return false }); got != nil {
+		t.Fatalf("got %v, want none accepted", got)
+	}
+	if got := c.len(1); got != 0 {
+		t.Fatalf("got %v cached peers, want stale and not accepted peers removed", got)
+	}
+}
+
+// TestKademliaReplacement checks that a dropped connection is replaced by
+// the most recently advertised known peer in the same bin which is not connected
+func TestKademliaReplacement(t *testing.T) {
+	tk := newTestKademlia(t, "00000000")
+	tk.Register("10000000", "11000000", "10100000", "10010000")
+	tk.On("10000000", "11000000")
+	// advertising a connected peer does not cache it
+	tk.Register("10000000")
+	if got := tk.binCache.len(0); got != 2 {
+		t.Fatalf("got %v cached peers, want 2", got)
+	}
+
+	tk.Reachable = func(a *BzzAddr) bool {
+		return binStr(a) != "10010000"
+	}
+	tk.Off("11000000")
+	for _, want := range []string{"10100000", "<nil>"} {
+		got := "<nil>"
+		if a := tk.Replacement(testKadPeerAddr("11000000")); a != nil {
+			got = binStr(a)
+		}
+		if got != want {
+			t.Fatalf("got replacement %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+)
+
+// Clock is the source of time of network protocols. Time is measured by a
+// monotonic mclock.Clock, which tests and simulations can replace with an
+// mclock.Simulated to fast-forward redial backoffs, timeouts and intervals
+// deterministically instead of sleeping. Wall clock time is derived from
+// the time elapsed since the clock was created.
+type Clock struct {
+	mclock.Clock
+	start mclock.AbsTime // monotonic time at creation
+	epoch time.Time      // wall clock time at creation
+}
+
+// NewClock returns a clock measuring time with c, whose wall clock time
+// starts at epoch. If c is nil, the system clock is used.
+func NewClock(c mclock.Clock, epoch time.Time) *Clock {
+	if c == nil {
+		c = mclock.System{}
+	}
+	return &Clock{
+		Clock: c,
+		start: c.Now(),
+		epoch: epoch,
+	}
+}
+
+// NewSystemClock returns a clock measuring time with the system clock
+func NewSystemClock() *Clock {
+	return NewClock(nil, time.Now())
+}
+
+// Time returns the current wall clock time
+func (c *Clock) Time() time.Time {
+	return c.epoch.Add(time.Duration(c.Now() - c.start))
+}
+
+// Since returns the time elapsed since t
+func (c *Clock) Since(t time.Time) time.Duration {
+	return c.Time().Sub(t)
+}
+
+// Ticker delivers the time on its channel at intervals of the clock, like
+// time.Ticker. Ticks are dropped if the receiver is not ready.
+type Ticker struct {
+	C <-chan time.Time
+
+	c        chan time.Time
+	clock    *Clock
+	interval time.Duration
+	mtx      sync.Mutex
+	event    mclock.Event
+	stopped  bool
+}
+
+// NewTicker returns a ticker delivering the time at every interval d
+func (c *Clock) NewTicker(d time.Duration) *Ticker {
+	ch := make(chan time.Time, 1)
+	t := &Ticker{
+		C:        ch,
+		c:        ch,
+		clock:    c,
+		interval: d,
+	}
+	t.mtx.Lock()
+	t.schedule()
+	t.mtx.Unlock()
+	return t
+}
+
+// schedule sets up the next tick
+// the caller is expected to hold t.mtx
+func (t *Ticker) schedule() {
+	t.event = t.clock.AfterFunc(t.interval, t.tick)
+}
+
+func (t *Ticker) tick() {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.stopped {
+		return
+	}
+	select {
+	case t.c <- t.clock.Time():
+	default:
+	}
+	t.schedule()
+}
+
+// Stop turns off the ticker, no more ticks are delivered after it returns
+func (t *Ticker) Stop() {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.stopped = true
+	t.event.Cancel()
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+)
+
+// TestClock tests that a clock with a simulated source of time only
+// advances and fires timers when the simulated clock is run
+func TestClock(t *testing.T) {
+	epoch := time.Date(2019, 12, 1, 10, 30, 0, 0, time.UTC)
+	sim := new(mclock.Simulated)
+	c := NewClock(sim, epoch)
+
+	if got := c.Time(); !got.Equal(epoch) {
+		t.Fatalf("got time %v, want %v", got, epoch)
+	}
+	timeout := c.After(time.Minute)
+	sim.Run(59 * time.Second)
+	select {
+	case <-timeout:
+		t.Fatal("timer fired early")
+	default:
+	}
+	sim.Run(time.Second)
+	select {
+	case <-timeout:
+	default:
+		t.Fatal("timer did not fire")
+	}
+	if got, want := c.Time(), epoch.Add(time.Minute); !got.Equal(want) {
+		t.Fatalf("got time %v, want %v", got, want)
+	}
+	if got := c.Since(epoch); got != time.Minute {
+		t.Fatalf("got %v since epoch, want %v", got, time.Minute)
+	}
+}
+
+// TestClockTicker tests that a ticker ticks at every interval of the
+// simulated clock and stops ticking when stopped
+func TestClockTicker(t *testing.T) {
+	sim := new(mclock.Simulated)
+	c := NewClock(sim, time.Unix(0, 0))
+	ticker := c.NewTicker(time.Second)
+
+	for i := 1; i <= 3; i++ {
+		sim.Run(time.Second)
+		select {
+		case tick := <-ticker.C:
+			if want := time.Unix(int64(i), 0); !tick.Equal(want) {
+				t.Fatalf("got tick at %v, want %v", tick, want)
+			}
+		default:
+			t.Fatalf("no tick %v", i)
+		}
+	}
+	ticker.Stop()
+	sim.Run(time.Minute)
+	select {
+	case <-ticker.C:
+		t.Fatal("tick after stop")
+	default:
+	}
+}
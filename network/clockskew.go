@@ -0,0 +1,191 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/log"
+)
+
+const (
+	// DefaultClockTolerance is the default clock offset up to which time sensitive
+	// subsystems accept timestamps that are ahead of or behind the host clock
+	DefaultClockTolerance = 30 * time.Second
+	// maxClockSkewSamples is the number of peers with the latest handshakes
+	// from which the offset of the host clock is estimated
+	maxClockSkewSamples = 64
+	// minClockSkewSamples is the number of peers needed to estimate the offset
+	minClockSkewSamples = 3
+	// ntpTimeout is the time to wait for the response of an NTP server
+	ntpTimeout = 5 * time.Second
+)
+
+// ClockSkewInfo is the estimated offset of the host clock
+type ClockSkewInfo struct {
+	Offset     time.Duration // median offset of the peer clocks to the host clock, positive if the host clock is behind
+	Samples    int           // number of peers the offset is estimated from
+	NTPServer  string        // NTP server of the last check, empty if never checked
+	NTPOffset  time.Duration // offset of the NTP server clock to the host clock
+	NTPChecked time.Time     // time of the last NTP check
+	NTPError   string        // error of the last NTP check, if any
+	Tolerance  time.Duration // offset up to which timestamps are accepted
+	Skewed     bool          // whether the host clock is off by more than the tolerance
+}
+
+// clockSample is the clock offset of a peer measured in the handshake
+type clockSample struct {
+	offset time.Duration
+	seen   time.Time
+}
+
+// ClockSkew estimates the offset of the host clock from the timestamps
+// that peers send in the bzz handshake and from optional NTP checks.
+// It warns when the offset exceeds the tolerance.
+type ClockSkew struct {
+	mtx     sync.RWMutex
+	info    ClockSkewInfo
+	samples map[enode.ID]clockSample
+	now     func() time.Time
+}
+
+// NewClockSkew creates a new clock skew detector, if tolerance is zero
+// DefaultClockTolerance is used
+func NewClockSkew(tolerance time.Duration) *ClockSkew {
+	if tolerance == 0 {
+		tolerance = DefaultClockTolerance
+	}
+	return &ClockSkew{
+		info:    ClockSkewInfo{Tolerance: tolerance},
+		samples: make(map[enode.ID]clockSample),
+		now:     time.Now,
+	}
+}
+
+// Now returns the time of the host clock
+func (c *ClockSkew) Now() time.Time {
+	return c.now()
+}
+
+// Tolerance returns the offset up to which timestamps are accepted
+func (c *ClockSkew) Tolerance() time.Duration {
+	return c.info.Tolerance
+}
+
+// Observe records the time sent by the peer in the handshake. Only the peers
+// with the latest handshakes are kept.
+func (c *ClockSkew) Observe(peer enode.ID, remote time.Time) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	now := c.now()
+	c.samples[peer] = clockSample{offset: remote.Sub(now), seen: now}
+	if len(c.samples) > maxClockSkewSamples {
+		var oldest enode.ID
+		var oldestSeen time.Time
+		for id, s := range c.samples {
+			if oldestSeen.IsZero() || s.seen.Before(oldestSeen) {
+				oldest, oldestSeen = id, s.seen
+			}
+		}
+		delete(c.samples, oldest)
+	}
+
+	offsets := make([]time.Duration, 0, len(c.samples))
+	for _, s := range c.samples {
+		offsets = append(offsets, s.offset)
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	c.info.Offset = offsets[len(offsets)/2]
+	c.info.Samples = len(offsets)
+	c.update()
+}
+
+// CheckNTP queries the NTP server for the offset of the host clock.
+// The server address may omit the port, which defaults to 123.
+func (c *ClockSkew) CheckNTP(server string) (time.Duration, error) {
+	offset, err := sntpOffset(server, ntpTimeout)
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.info.NTPServer = server
+	c.info.NTPChecked = c.now()
+	c.info.NTPOffset = offset
+	c.info.NTPError = ""
+	if err != nil {
+		c.info.NTPError = err.Error()
+		log.Warn("NTP clock check failed", "server", server, "err", err)
+	}
+	c.update()
+	return offset, err
+}
+
+// update decides whether the host clock is skewed, preferring a successful
+// NTP check over the offset estimated from peers, and logs changes
+// the caller is expected to hold c.mtx
+func (c *ClockSkew) update() {
+	offset, source := c.info.Offset, "peers"
+	known := c.info.Samples >= minClockSkewSamples
+	if c.info.NTPServer != "" && c.info.NTPError == "" {
+		offset, source, known = c.info.NTPOffset, "ntp", true
+	}
+	if !known {
+		return
+	}
+	skew, direction := offset, "behind"
+	if skew < 0 {
+		skew, direction = -skew, "ahead"
+	}
+	skewed := skew > c.info.Tolerance
+	if skewed && !c.info.Skewed {
+		log.Warn(fmt.Sprintf("host clock is %v %s according to %s, time sensitive operations may fail", skew, direction, source), "tolerance", c.info.Tolerance)
+	} else if !skewed && c.info.Skewed {
+		log.Info("host clock is within tolerance again", "offset", offset, "source", source)
+	}
+	c.info.Skewed = skewed
+}
+
+// Info returns the current clock offset estimate
+func (c *ClockSkew) Info() ClockSkewInfo {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return c.info
+}
+
+// ClockSkewAPI exposes the clock offset estimate over RPC
+type ClockSkewAPI struct {
+	clockSkew *ClockSkew
+}
+
+// NewClockSkewAPI creates a new ClockSkewAPI
+func NewClockSkewAPI(c *ClockSkew) *ClockSkewAPI {
+	return &ClockSkewAPI{clockSkew: c}
+}
+
+// ClockSkew returns the current clock offset estimate
+func (a *ClockSkewAPI) ClockSkew() ClockSkewInfo {
+	return a.clockSkew.Info()
+}
+
+// CheckNTP queries the NTP server for the offset of the host clock
+// and returns the updated estimate
+func (a *ClockSkewAPI) CheckNTP(server string) (ClockSkewInfo, error) {
+	_, err := a.clockSkew.CheckNTP(server)
+	return a.clockSkew.Info(), err
+}
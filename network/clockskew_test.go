@@ -0,0 +1,134 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// TestClockSkew tests the estimation of the host clock offset from peer clocks
+func TestClockSkew(t *testing.T) {
+	now := time.Date(2019, 12, 1, 10, 0, 0, 0, time.UTC)
+	c := NewClockSkew(10 * time.Second)
+	c.now = func() time.Time { return now }
+
+	observe := func(i byte, offset time.Duration) {
+		var id enode.ID
+		id[0] = i
+		c.Observe(id, now.Add(offset))
+	}
+	observe(1, time.Minute)
+	observe(2, time.Minute)
+	if info := c.Info(); info.Skewed {
+		t.Fatalf("got skewed clock from %v samples, want at least %v", info.Samples, minClockSkewSamples)
+	}
+	observe(3, -time.Second)
+	info := c.Info()
+	if info.Samples != 3 || info.Offset != time.Minute || !info.Skewed {
+		t.Fatalf("got %+v, want offset of a minute from 3 samples", info)
+	}
+	// a new handshake of a known peer replaces its sample
+	observe(1, 0)
+	observe(2, time.Second)
+	info = c.Info()
+	if info.Samples != 3 || info.Offset != 0 || info.Skewed {
+		t.Fatalf("got %+v, want no offset from 3 samples", info)
+	}
+
+	// only the latest samples are kept
+	for i := 0; i < maxClockSkewSamples; i++ {
+		now = now.Add(time.Second)
+		observe(byte(10+i), -time.Hour)
+	}
+	info = c.Info()
+	if info.Samples != maxClockSkewSamples || info.Offset != -time.Hour || !info.Skewed {
+		t.Fatalf("got %+v, want offset of minus an hour from %v samples", info, maxClockSkewSamples)
+	}
+}
+
+// TestClockSkewNTP tests that the offset to an NTP server is measured
+// and preferred over the offset to peers
+func TestClockSkewNTP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	serverOffset := 2 * time.Minute
+	go func() {
+		buf := make([]byte, 48)
+		for {
+			_, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			resp := make([]byte, 48)
+			resp[0] = 4<<3 | 4
+			ts := time.Now().Add(serverOffset)
+			sec := uint32(ts.Unix() + ntpEpochOffset)
+			frac := uint32((uint64(ts.Nanosecond()) << 32) / 1e9)
+			for _, b := range [][]byte{resp[32:40], resp[40:48]} {
+				binary.BigEndian.PutUint32(b[:4], sec)
+				binary.BigEndian.PutUint32(b[4:], frac)
+			}
+			conn.WriteTo(resp, addr)
+		}
+	}()
+
+	c := NewClockSkew(time.Minute)
+	offset, err := c.CheckNTP(conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset < serverOffset-time.Second || offset > serverOffset+time.Second {
+		t.Fatalf("got offset %v, want %v", offset, serverOffset)
+	}
+	info := c.Info()
+	if !info.Skewed || info.NTPOffset != offset || info.Samples != 0 {
+		t.Fatalf("got %+v, want skewed clock by ntp", info)
+	}
+}
+
+// TestBzzHandshakeClockSkew tests that the time sent in the handshake is observed
+func TestBzzHandshakeClockSkew(t *testing.T) {
+	prvkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := newBzzHandshakeTester(1, prvkey, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	node := s.Nodes[0]
+
+	rhs := newBzzHandshakeMsg(TestProtocolVersion, TestProtocolNetworkID, NewBzzAddrFromEnode(node), false)
+	rhs.Time += uint64(time.Minute / time.Millisecond)
+	if err := s.testHandshake(correctBzzHandshake(s.addr, false), rhs); err != nil {
+		t.Fatal(err)
+	}
+	info := s.bzz.ClockSkew().Info()
+	if info.Samples != 1 || info.Offset != time.Minute {
+		t.Fatalf("got %+v, want offset of a minute from 1 sample", info)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/p2p/protocols"
+)
+
+// maxDemotions is the number of most recent demotions kept for inspection
+const maxDemotions = 100
+
+// PeerErrorKind classifies a protocol error charged to a peer
+type PeerErrorKind string
+
+const (
+	PeerErrorDecode  PeerErrorKind = "decode"  // message could not be decoded
+	PeerErrorInvalid PeerErrorKind = "invalid" // message was invalid or unsolicited
+	PeerErrorCorrupt PeerErrorKind = "corrupt" // peer delivered a chunk that failed validation
+)
+
+// PeerDemotion records a peer that was dropped and banned from dialing
+// for exceeding its error budget
+type PeerDemotion struct {
+	Peer   string    // hex encoded overlay address of the peer
+	Reason string    // the error that exhausted the budget
+	Time   time.Time // time of the demotion
+	Until  time.Time // time the dial ban expires
+}
+
+// errorBudget keeps the protocol errors of every peer within a sliding window
+type errorBudget struct {
+	mtx       sync.Mutex
+	peers     map[string]*peerErrors
+	demotions []PeerDemotion
+	prunedAt  time.Time // time peers without errors in the window and bans were last removed
+}
+
+// peerErrors are the errors charged to a peer and its dial ban
+type peerErrors struct {
+	errors      []time.Time // times of the errors within the window
+	bannedUntil time.Time
+}
+
+func newErrorBudget() *errorBudget {
+	return &errorBudget{
+		peers: make(map[string]*peerErrors),
+	}
+}
+
+// add charges an error to the peer at addr and returns true if the
+// errors within window exceed budget, in which case the peer is banned
+// until now+ban and its error record is reset
+func (b *errorBudget) add(addr []byte, now time.Time, budget int, window, ban time.Duration, reason string) bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if now.Sub(b.prunedAt) >= window {
+		b.prune(now, window)
+	}
+	key := string(addr)
+	pe, ok := b.peers[key]
+	if !ok {
+		pe = &peerErrors{}
+		b.peers[key] = pe
+	}
+	// drop errors which left the window
+	var i int
+	for i < len(pe.errors) && now.Sub(pe.errors[i]) > window {
+		i++
+	}
+	pe.errors = append(pe.errors[i:], now)
+	if len(pe.errors) <= budget {
+		return false
+	}
+	pe.errors = nil
+	pe.bannedUntil = now.Add(ban)
+	b.demotions = append(b.demotions, PeerDemotion{
+		Peer:   hex.EncodeToString(addr),
+		Reason: reason,
+		Time:   now,
+		Until:  pe.bannedUntil,
+	})
+	if len(b.demotions) > maxDemotions {
+		b.demotions = b.demotions[len(b.demotions)-maxDemotions:]
+	}
+	return true
+}
+
+// prune removes the peers whose errors all left the window and whose ban
+// expired, so peers charged once are not kept forever
+func (b *errorBudget) prune(now time.Time, window time.Duration) {
+	for key, pe := range b.peers {
+		if now.Before(pe.bannedUntil) {
+			continue
+		}
+		if n := len(pe.errors); n > 0 && now.Sub(pe.errors[n-1]) <= window {
+			continue
+		}
+		delete(b.peers, key)
+	}
+	b.prunedAt = now
+}
+
+// banned returns true if the peer at addr is banned at time now
+func (b *errorBudget) banned(addr []byte, now time.Time) bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	pe, ok := b.peers[string(addr)]
+	if !ok {
+		return false
+	}
+	if pe.bannedUntil.IsZero() || now.After(pe.bannedUntil) {
+		if len(pe.errors) == 0 {
+			delete(b.peers, string(addr))
+		}
+		return false
+	}
+	return true
+}
+
+// ReportPeerError charges a protocol error to the error budget of the peer.
+// A peer with more than ErrorBudget errors within ErrorBudgetWindow is
+// demoted: it is dropped and not dialed again for DemotionBanDuration.
+// It returns true if the peer was demoted.
+func (k *Kademlia) ReportPeerError(p *BzzPeer, kind PeerErrorKind, err error) bool {
+	if k.ErrorBudget <= 0 {
+		return false
+	}
+	reason := fmt.Sprintf("%s: %v", kind, err)
+	if !k.errorBudget.add(p.Address(), k.clock.Time(), k.ErrorBudget, k.ErrorBudgetWindow, k.DemotionBanDuration, reason) {
+		return false
+	}
+	log.Warn("peer exceeded error budget, demoting", "peer", p.ShortString(), "reason", reason, "ban", k.DemotionBanDuration)
+	if p.Peer != nil {
+		p.Drop("error budget exceeded: " + reason)
+	}
+	return true
+}
+
+// Demotions returns the most recent peer demotions
+func (k *Kademlia) Demotions() []PeerDemotion {
+	k.errorBudget.mtx.Lock()
+	defer k.errorBudget.mtx.Unlock()
+	return append([]PeerDemotion(nil), k.errorBudget.demotions...)
+}
+
+// reportProtocolError charges the error ending a protocol session to the
+// error budget of the peer if the error was caused by a malformed message
+func (k *Kademlia) reportProtocolError(p *BzzPeer, err error) {
+	perr, ok := err.(*protocols.Error)
+	if !ok {
+		return
+	}
+	switch perr.Code {
+	case protocols.ErrMsgTooLong, protocols.ErrDecode:
+		k.ReportPeerError(p, PeerErrorDecode, err)
+	case protocols.ErrInvalidMsgCode, protocols.ErrInvalidMsgType:
+		k.ReportPeerError(p, PeerErrorInvalid, err)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethersphere/swarm/p2p/protocols"
+)
+
+// TestErrorBudget checks that errors are counted within the sliding window only
+// and that exceeding the budget bans the peer for the ban duration
+func TestErrorBudget(t *testing.T) {
+	b := newErrorBudget()
+	addr := []byte{1}
+	now := time.Now()
+	window := time.Minute
+	ban := time.Hour
+
+	if b.add(addr, now, 2, window, ban, "1") || b.add(addr, now.Add(time.Second), 2, window, ban, "2") {
+		t.Fatal("expected errors within budget")
+	}
+	// the first two errors left the window
+	now = now.Add(2 * window)
+	if b.add(addr, now, 2, window, ban, "3") || b.add(addr, now, 2, window, ban, "4") {
+		t.Fatal("expected errors outside of the window not to be counted")
+	}
+	if b.banned(addr, now) {
+		t.Fatal("expected peer not to be banned")
+	}
+	if !b.add(addr, now, 2, window, ban, "5") {
+		t.Fatal("expected budget to be exceeded")
+	}
+	if !b.banned(addr, now.Add(ban-time.Second)) {
+		t.Fatal("expected peer to be banned")
+	}
+	if b.banned(addr, now.Add(ban+time.Second)) {
+		t.Fatal("expected ban to expire")
+	}
+	if len(b.demotions) != 1 || b.demotions[0].Reason != "5" {
+		t.Fatalf("expected one demotion with reason 5, got %v", b.demotions)
+	}
+
+	// peers are forgotten once their errors left the window and their ban expired
+	other := []byte{2}
+	b.add(other, now.Add(ban-time.Second), 2, window, ban, "6")
+	b.add(other, now.Add(ban+2*window), 2, window, ban, "7")
+	if _, ok := b.peers[string(addr)]; ok {
+		t.Fatal("expected peer with expired ban to be removed")
+	}
+	if len(b.peers) != 1 {
+		t.Fatalf("expected only the peer with errors in the window to be kept, got %d peers", len(b.peers))
+	}
+}
+
+// TestKademliaReportPeerError checks that a peer exceeding its error budget
+// is demoted and not suggested for dialing while banned
+func TestKademliaReportPeerError(t *testing.T) {
+	tk := newTestKademlia(t, "00000000")
+	tk.ErrorBudget = 2
+	p := tk.newTestKadPeer("01000000")
+	e := tk.newEntryFromBzzAddress(p.BzzAddr)
+	if !tk.callable(e) {
+		t.Fatal("expected peer to be callable")
+	}
+
+	err := errors.New("test")
+	for i := 0; i < tk.ErrorBudget; i++ {
+		if tk.ReportPeerError(p.BzzPeer, PeerErrorInvalid, err) {
+			t.Fatalf("expected error %v within budget", i)
+		}
+	}
+	tk.reportProtocolError(p.BzzPeer, errors.New("not a protocol error"))
+	if !tk.ReportPeerError(p.BzzPeer, PeerErrorCorrupt, err) {
+		t.Fatal("expected peer to be demoted")
+	}
+
+	demotions := tk.Demotions()
+	if len(demotions) != 1 {
+		t.Fatalf("expected 1 demotion, got %v", len(demotions))
+	}
+	if demotions[0].Reason != "corrupt: test" {
+		t.Fatalf("expected demotion reason %q, got %q", "corrupt: test", demotions[0].Reason)
+	}
+	e = tk.newEntryFromBzzAddress(p.BzzAddr)
+	if tk.callable(e) {
+		t.Fatal("expected demoted peer not to be callable")
+	}
+
+	// decode errors of protocol sessions are charged as well
+	other := tk.newTestKadPeer("01000001")
+	for i := 0; i <= tk.ErrorBudget; i++ {
+		tk.reportProtocolError(other.BzzPeer, &protocols.Error{Code: protocols.ErrDecode})
+	}
+	if len(tk.Demotions()) != 2 {
+		t.Fatal("expected peer with decode errors to be demoted")
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+const (
+	// DefaultHandshakePuzzleDifficulty is the default number of leading zero
+	// bits of the handshake puzzle hash, about 65000 hashes to solve
+	DefaultHandshakePuzzleDifficulty = 16
+	// MaxHandshakePuzzleDifficulty is the highest difficulty of handshake
+	// puzzles peers solve, about a million hashes, well within the handshake timeout
+	MaxHandshakePuzzleDifficulty = 20
+	// handshakeRateWindow is the window in which inbound handshakes are
+	// counted against the handshake rate limit
+	handshakeRateWindow = time.Minute
+	// maxSeenHandshakePeers is the number of peers with the latest completed
+	// handshakes which are exempt from the handshake puzzle
+	maxSeenHandshakePeers = 4096
+)
+
+var (
+	errHandshakePuzzleInvalid     = errors.New("invalid handshake puzzle solution")
+	errHandshakePuzzleTooHard     = errors.New("handshake puzzle too hard")
+	errHandshakePuzzleUnsupported = errors.New("handshake puzzle not supported by peer")
+)
+
+// handshakePuzzleDomain separates handshake puzzle hashes from other hashes
+const handshakePuzzleDomain = "swarm bzz handshake puzzle"
+
+// handshakePuzzleHash returns the hash of the nonce solving the puzzle of the
+// bzz handshake sent by the node with sender id to the node with receiver id,
+// which stated the time of its handshake. As the underlay connection
+// authenticates both node ids and the receiver checks the solution against
+// its own time, a solution can not be reused by other nodes or later.
+func handshakePuzzleHash(networkID uint64, sender, receiver enode.ID, time uint64, nonce uint64) [sha256.Size]byte {
+	buf := make([]byte, 0, len(handshakePuzzleDomain)+3*8+2*len(sender))
+	buf = append(buf, handshakePuzzleDomain...)
+	buf = appendUint64(buf, networkID)
+	buf = append(buf, sender[:]...)
+	buf = append(buf, receiver[:]...)
+	buf = appendUint64(buf, time)
+	buf = appendUint64(buf, nonce)
+	return sha256.Sum256(buf)
+}
+
+func appendUint64(buf []byte, v uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	return append(buf, b[:]...)
+}
+
+// leadingZeroBits returns the number of leading zero bits of h
+func leadingZeroBits(h []byte) int {
+	for i, b := range h {
+		if b != 0 {
+			return i*8 + bits.LeadingZeros8(b)
+		}
+	}
+	return len(h) * 8
+}
+
+// solveHandshakePuzzle returns the lowest nonce whose handshake puzzle hash
+// has at least difficulty leading zero bits
+func solveHandshakePuzzle(difficulty uint8, networkID uint64, sender, receiver enode.ID, time uint64) uint64 {
+	var nonce uint64
+	for {
+		h := handshakePuzzleHash(networkID, sender, receiver, time, nonce)
+		if leadingZeroBits(h[:]) >= int(difficulty) {
+			return nonce
+		}
+		nonce++
+	}
+}
+
+// HandshakeGuard protects the node from floods of inbound handshakes.
+// When more inbound handshakes than the rate limit arrive within a minute,
+// peers which did not complete a handshake recently must send the solution
+// of a proof of work puzzle. The receiver states the puzzle difficulty in its
+// handshake, and the dialing peer solves the puzzle only when asked to.
+type HandshakeGuard struct {
+	mtx         sync.Mutex
+	rateLimit   int                   // inbound handshakes per minute above which unseen peers must solve the puzzle, 0 if they never must
+	difficulty  uint8                 // leading zero bits of the puzzle hash, puzzles are not required if 0
+	windowStart time.Time             // start of the current rate window
+	inbound     int                   // number of inbound handshakes in the current rate window
+	seen        map[enode.ID]struct{} // peers which completed a handshake recently
+	seenOrder   []enode.ID            // seen peers, oldest first
+}
+
+// NewHandshakeGuard creates a handshake guard requiring puzzles with
+// difficulty leading zero bits from unseen peers when more than rateLimit
+// inbound handshakes arrive within a minute
+func NewHandshakeGuard(rateLimit int, difficulty uint8) *HandshakeGuard {
+	return &HandshakeGuard{
+		rateLimit:  rateLimit,
+		difficulty: difficulty,
+		seen:       make(map[enode.ID]struct{}),
+	}
+}
+
+// observeInbound counts an inbound handshake from the peer with id and
+// returns the difficulty of the puzzle the peer must solve, 0 if it need not
+func (g *HandshakeGuard) observeInbound(id enode.ID, now time.Time) uint8 {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	if now.Sub(g.windowStart) >= handshakeRateWindow {
+		g.windowStart = now
+		g.inbound = 0
+	}
+	g.inbound++
+	if g.difficulty == 0 || g.rateLimit == 0 || g.inbound <= g.rateLimit {
+		return 0
+	}
+	if _, ok := g.seen[id]; ok {
+		return 0
+	}
+	metrics.GetOrRegisterCounter("network.bzz.handshake.puzzle.required", nil).Inc(1)
+	return g.difficulty
+}
+
+// solveHandshake returns the solution of the puzzle with the difficulty the
+// peer with receiver id stated in its handshake at time, which must not be
+// higher than MaxHandshakePuzzleDifficulty
+func solveHandshake(difficulty uint8, networkID uint64, sender, receiver enode.ID, time uint64) (uint64, error) {
+	if difficulty > MaxHandshakePuzzleDifficulty {
+		return 0, errHandshakePuzzleTooHard
+	}
+	metrics.GetOrRegisterCounter("network.bzz.handshake.puzzle.solved", nil).Inc(1)
+	return solveHandshakePuzzle(difficulty, networkID, sender, receiver, time), nil
+}
+
+// checkHandshakePuzzle validates the solution of the puzzle with difficulty
+// sent by the peer with sender id in response to the handshake sent at time
+func checkHandshakePuzzle(nonce uint64, difficulty uint8, networkID uint64, sender, receiver enode.ID, time uint64) error {
+	h := handshakePuzzleHash(networkID, sender, receiver, time, nonce)
+	if leadingZeroBits(h[:]) < int(difficulty) {
+		metrics.GetOrRegisterCounter("network.bzz.handshake.puzzle.rejected", nil).Inc(1)
+		return errHandshakePuzzleInvalid
+	}
+	return nil
+}
+
+// observeSeen records that the peer with id completed a handshake,
+// forgetting the peer seen first if too many peers were seen
+func (g *HandshakeGuard) observeSeen(id enode.ID) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	if _, ok := g.seen[id]; ok {
+		return
+	}
+	g.seen[id] = struct{}{}
+	g.seenOrder = append(g.seenOrder, id)
+	if len(g.seenOrder) > maxSeenHandshakePeers {
+		delete(g.seen, g.seenOrder[0])
+		g.seenOrder = g.seenOrder[1:]
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	p2ptest "github.com/ethersphere/swarm/p2p/testing"
+)
+
+// TestHandshakePuzzle tests that only solutions of the puzzle of
+// the handshake between the same nodes at the same time are accepted
+func TestHandshakePuzzle(t *testing.T) {
+	const difficulty = 8
+	sender, receiver := enode.ID{1}, enode.ID{2}
+	msTime := uint64(testHandshakeTime.UnixNano() / int64(time.Millisecond))
+
+	nonce, err := solveHandshake(difficulty, TestProtocolNetworkID, sender, receiver, msTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkHandshakePuzzle(nonce, difficulty, TestProtocolNetworkID, sender, receiver, msTime); err != nil {
+		t.Fatalf("expected the solution to be accepted, got %v", err)
+	}
+	if err := checkHandshakePuzzle(nonce, difficulty, TestProtocolNetworkID, receiver, sender, msTime); err != errHandshakePuzzleInvalid {
+		t.Fatalf("expected the solution to be rejected for other nodes, got %v", err)
+	}
+	if err := checkHandshakePuzzle(nonce, difficulty, TestProtocolNetworkID, sender, receiver, msTime+1); err != errHandshakePuzzleInvalid {
+		t.Fatalf("expected the solution to be rejected for another handshake, got %v", err)
+	}
+	if _, err := solveHandshake(MaxHandshakePuzzleDifficulty+1, TestProtocolNetworkID, sender, receiver, msTime); err != errHandshakePuzzleTooHard {
+		t.Fatalf("expected puzzles above the maximum difficulty not to be solved, got %v", err)
+	}
+}
+
+// TestHandshakeGuard tests that puzzles are required from unseen peers only
+// while the inbound handshake rate exceeds the limit
+func TestHandshakeGuard(t *testing.T) {
+	g := NewHandshakeGuard(2, 8)
+	seen, unseen := enode.ID{1}, enode.ID{2}
+	g.observeSeen(seen)
+	now := testHandshakeTime
+
+	for i := 0; i < 2; i++ {
+		if d := g.observeInbound(unseen, now); d != 0 {
+			t.Fatalf("handshake %d: expected no puzzle within the rate limit, got difficulty %d", i, d)
+		}
+	}
+	if d := g.observeInbound(unseen, now); d != 8 {
+		t.Fatalf("expected a puzzle of difficulty 8 from an unseen peer above the rate limit, got %d", d)
+	}
+	if d := g.observeInbound(seen, now); d != 0 {
+		t.Fatalf("expected no puzzle from a seen peer above the rate limit, got difficulty %d", d)
+	}
+	if d := g.observeInbound(unseen, now.Add(handshakeRateWindow)); d != 0 {
+		t.Fatalf("expected no puzzle in the next rate window, got difficulty %d", d)
+	}
+
+	if d := NewHandshakeGuard(2, 0).observeInbound(unseen, now); d != 0 {
+		t.Fatalf("expected no puzzle without a difficulty, got %d", d)
+	}
+}
+
+// newPuzzleBzzTester creates a bzz tester whose node dials the test peer
+func newPuzzleBzzTester(t *testing.T, run func(*Bzz) func(*p2p.Peer, p2p.MsgReadWriter) error) (*bzzTester, enode.ID) {
+	t.Helper()
+	prvkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record enr.Record
+	record.Set(NewENRAddrEntry(PrivateKeyToBzzKey(prvkey)))
+	if err := enode.SignV4(&record, prvkey); err != nil {
+		t.Fatal(err)
+	}
+	nod, err := enode.New(enode.V4ID{}, &record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := getENRBzzAddr(nod)
+	bzz := newBzz(addr, false)
+	return &bzzTester{
+		addr:           addr,
+		ProtocolTester: p2ptest.NewProtocolTester(prvkey, 1, run(bzz)),
+		bzz:            bzz,
+	}, enode.PubkeyToIDV4(&prvkey.PublicKey)
+}
+
+// TestBzzHandshakePuzzle tests that the dialing node solves the
+// puzzle the receiver states in its handshake, and only if it is asked to
+func TestBzzHandshakePuzzle(t *testing.T) {
+	const difficulty = 8
+	s, local := newPuzzleBzzTester(t, func(b *Bzz) func(*p2p.Peer, p2p.MsgReadWriter) error { return b.runBzz })
+	defer s.Stop()
+	node := s.Nodes[0]
+
+	lhs := correctBzzHandshake(s.addr, false)
+	rhs := newBzzHandshakeMsg(TestProtocolVersion, TestProtocolNetworkID, NewBzzAddrFromEnode(node), false)
+	rhs.PuzzleDifficulty = difficulty
+	nonce := solveHandshakePuzzle(difficulty, TestProtocolNetworkID, local, node.ID(), rhs.Time)
+	exchanges := append(HandshakeMsgExchange(lhs, rhs, node.ID()),
+		p2ptest.Exchange{Expects: []p2ptest.Expect{{Code: 1, Msg: &HandshakePuzzleMsg{Nonce: nonce}, Peer: node.ID()}}},
+		p2ptest.Exchange{Triggers: []p2ptest.Trigger{{Code: 1, Msg: &HandshakePuzzleMsg{}, Peer: node.ID()}}},
+	)
+	if err := s.TestExchanges(exchanges...); err != nil {
+		t.Fatal(err)
+	}
+	err := s.TestDisconnected(&p2ptest.Disconnect{Peer: node.ID(), Error: nil})
+	if err == nil || err.Error() != "timed out waiting for peers to disconnect" {
+		t.Fatalf("expected the peer to stay connected, got %v", err)
+	}
+}
+
+// TestBzzHandshakePuzzleTooHard tests that the dialing node does not
+// solve puzzles above the maximum difficulty
+func TestBzzHandshakePuzzleTooHard(t *testing.T) {
+	s, _ := newPuzzleBzzTester(t, func(b *Bzz) func(*p2p.Peer, p2p.MsgReadWriter) error { return b.runBzz })
+	defer s.Stop()
+	node := s.Nodes[0]
+
+	rhs := newBzzHandshakeMsg(TestProtocolVersion, TestProtocolNetworkID, NewBzzAddrFromEnode(node), false)
+	rhs.PuzzleDifficulty = MaxHandshakePuzzleDifficulty + 1
+	err := s.testHandshake(correctBzzHandshake(s.addr, false), rhs, &p2ptest.Disconnect{
+		Peer:  node.ID(),
+		Error: errHandshakePuzzleTooHard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestBzzLegacyHandshake tests that peers of the previous release are
+// accepted, without a fork id and without observing their clock
+func TestBzzLegacyHandshake(t *testing.T) {
+	s, _ := newPuzzleBzzTester(t, func(b *Bzz) func(*p2p.Peer, p2p.MsgReadWriter) error { return b.runLegacyBzz })
+	defer s.Stop()
+	node := s.Nodes[0]
+
+	lhs := correctBzzHandshake(s.addr, false).downgrade()
+	rhs := newBzzHandshakeMsg(uint64(legacyBzzSpec.Version), TestProtocolNetworkID, NewBzzAddrFromEnode(node), false).downgrade()
+	err := s.TestExchanges(
+		p2ptest.Exchange{Expects: []p2ptest.Expect{{Code: 0, Msg: lhs, Peer: node.ID()}}},
+		p2ptest.Exchange{Triggers: []p2ptest.Trigger{{Code: 0, Msg: rhs, Peer: node.ID()}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.TestDisconnected(&p2ptest.Disconnect{Peer: node.ID(), Error: nil})
+	if err == nil || err.Error() != "timed out waiting for peers to disconnect" {
+		t.Fatalf("expected the legacy peer to stay connected, got %v", err)
+	}
+	if samples := s.bzz.ClockSkew().Info().Samples; samples != 0 {
+		t.Fatalf("expected no clock offset observed, got %d samples", samples)
+	}
+}
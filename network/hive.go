@@ -27,11 +27,46 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethersphere/swarm/log"
 	"github.com/ethersphere/swarm/network/capability"
+	"github.com/ethersphere/swarm/network/pubsubchannel"
 	"github.com/ethersphere/swarm/state"
 )
 
 const connectionsKey = "conns"
 const addressesKey = "peers"
+const uptimesKey = "uptimes"
+
+// KademliaSchema is the schema of the kademlia records persisted by the hive
+var KademliaSchema = state.Schema{
+	Component: "kademlia",
+	Migrations: []state.Migration{
+		migrateAddressCapabilities,
+	},
+}
+
+// migrateAddressCapabilities adds the full capability to persisted addresses
+// of stores written before peer capabilities were introduced
+func migrateAddressCapabilities(s state.Store) error {
+	for _, key := range []string{addressesKey, connectionsKey} {
+		var as []*BzzAddr
+		if err := s.Get(key, &as); err != nil {
+			if err == state.ErrNotFound {
+				continue
+			}
+			return err
+		}
+		for i := range as {
+			if as[i].Capabilities == nil {
+				caps := capability.NewCapabilities()
+				caps.Add(fullCapability)
+				as[i] = as[i].WithCapabilities(caps)
+			}
+		}
+		if err := s.Put(key, as); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
 /*
 Hive is the logistic manager of the swarm
@@ -48,6 +83,8 @@ type HiveParams struct {
 	PeersBroadcastSetSize uint8 // how many peers to use when relaying
 	MaxPeersPerRequest    uint8 // max size for peer address batches
 	KeepAliveInterval     time.Duration
+	AnnouncePrices        bool            // if the node announces its service prices to peers
+	Dialer                *UnderlayDialer `toml:"-"` // if set, learns the underlays of the peers hive connects to
 }
 
 // NewHiveParams returns hive config with only the
@@ -69,9 +106,17 @@ type Hive struct {
 	// bookkeeping
 	lock    sync.Mutex
 	peers   map[enode.ID]*BzzPeer
-	ticker  *time.Ticker
+	ticker  *Ticker
 	done    chan struct{}
 	started bool
+
+	peerEvents     *pubsubchannel.PubSubChannel // overlay level peer events
+	peerEventsQuit chan struct{}                // closed when the peer events are closed on shutdown
+	peerEventsOnce sync.Once
+
+	pricesMtx     sync.RWMutex
+	prices        *ServicePrices // service prices announced to peers, nil if not set
+	defaultPrices *ServicePrices // service prices of peers which can not announce theirs, nil if not set
 }
 
 // NewHive constructs a new hive
@@ -80,10 +125,12 @@ type Hive struct {
 // StateStore: to save peers across sessions
 func NewHive(params *HiveParams, kad *Kademlia, store state.Store) *Hive {
 	return &Hive{
-		HiveParams: params,
-		Kademlia:   kad,
-		Store:      store,
-		peers:      make(map[enode.ID]*BzzPeer),
+		HiveParams:     params,
+		Kademlia:       kad,
+		Store:          store,
+		peers:          make(map[enode.ID]*BzzPeer),
+		peerEvents:     pubsubchannel.New(100),
+		peerEventsQuit: make(chan struct{}),
 	}
 }
 
@@ -110,7 +157,7 @@ func (h *Hive) start(server *p2p.Server, addPeerFunc func(*enode.Node)) error {
 		}
 	}
 	// ticker to keep the hive alive
-	h.ticker = time.NewTicker(h.KeepAliveInterval)
+	h.ticker = h.clock.NewTicker(h.KeepAliveInterval)
 	// done channel to signal the connect goroutine to return after Stop
 	h.done = make(chan struct{})
 	// this loop is doing bootstrapping and maintains a healthy table
@@ -172,25 +219,81 @@ func (h *Hive) tickHive() {
 	}
 	if addr != nil {
 		log.Trace(fmt.Sprintf("%08x hive connect() suggested %08x", h.BaseAddr()[:4], addr.Address()[:4]))
-		underA := addr.Under()
-		s := string(underA)
-		under, err := enode.ParseV4(s)
-		if err != nil {
-			log.Warn(fmt.Sprintf("%08x unable to connect to bee %08x: invalid node URL: %v", h.BaseAddr()[:4], addr.Address()[:4], err))
-			return
-		}
-		log.Trace(fmt.Sprintf("%08x attempt to connect to bee %08x", h.BaseAddr()[:4], addr.Address()[:4]))
-		h.addPeer(under)
+		h.dial(addr)
+	}
+}
+
+// dial connects to the peer at addr
+func (h *Hive) dial(addr *BzzAddr) {
+	underA := addr.Under()
+	s := string(underA)
+	under, err := enode.ParseV4(s)
+	if err != nil {
+		log.Warn(fmt.Sprintf("%08x unable to connect to bee %08x: invalid node URL: %v", h.BaseAddr()[:4], addr.Address()[:4], err))
+		return
+	}
+	log.Trace(fmt.Sprintf("%08x attempt to connect to bee %08x", h.BaseAddr()[:4], addr.Address()[:4]))
+	h.setUnderlays(under, addr)
+	h.addPeer(under)
+}
+
+// setUnderlays lets the dialer pick one of the underlays the peer advertises
+func (h *Hive) setUnderlays(under *enode.Node, addr *BzzAddr) {
+	if h.Dialer != nil {
+		h.Dialer.SetUnderlays(under.ID(), addr.Underlays())
+	}
+}
+
+// removeUnderlays lets the dialer forget the underlays of a removed peer,
+// they are set again when the peer is dialed
+func (h *Hive) removeUnderlays(id enode.ID) {
+	if h.Dialer != nil {
+		h.Dialer.RemoveNode(id)
+	}
+}
+
+// replace dials a known peer from the kademlia bin cache in place of
+// the dropped peer at addr, without waiting for the next hive tick
+func (h *Hive) replace(addr *BzzAddr) {
+	if h.DisableAutoConnect || h.addPeer == nil {
+		return
+	}
+	select {
+	case <-h.done:
+		// do not replace peers dropped on stop
+		return
+	default:
+	}
+	if r := h.Replacement(addr); r != nil {
+		log.Trace(fmt.Sprintf("%08x hive replacing dropped peer %08x with %08x", h.BaseAddr()[:4], addr.Address()[:4], r.Address()[:4]))
+		h.dial(r)
 	}
 }
 
 // Run protocol run function
 func (h *Hive) Run(p *BzzPeer) error {
+	return h.run(p, false)
+}
+
+// RunLegacy is the protocol run function of peers
+// speaking the previous version of the protocol
+func (h *Hive) RunLegacy(p *BzzPeer) error {
+	return h.run(p, true)
+}
+
+// run runs the protocol with a peer, which speaks the previous version
+// of the protocol if legacy, taking the default prices as its prices
+func (h *Hive) run(p *BzzPeer, legacy bool) error {
 	h.trackPeer(p)
 	defer h.untrackPeer(p)
 
 	dp := NewPeer(p, h.Kademlia)
+	dp.legacy = legacy
+	if prices, ok := h.DefaultPrices(); ok && legacy {
+		dp.setPrices(prices)
+	}
 	depth, changed := h.On(dp)
+	h.publishPeerEvent(newPeerEvent(PeerEventConnect, h.BaseAddr(), p.ID(), p.BzzAddr, nil))
 	// if we want discovery, advertise change of depth
 	if h.Discovery {
 		if changed {
@@ -202,8 +305,17 @@ func (h *Hive) Run(p *BzzPeer) error {
 		}
 		h.NotifyPeer(p.BzzAddr)
 	}
-	defer h.Off(dp)
-	return dp.Run(h.handleMsg(dp))
+	if prices, ok := h.Prices(); ok && h.AnnouncePrices {
+		dp.NotifyPrices(prices)
+	}
+	defer func() {
+		h.Off(dp)
+		h.removeUnderlays(p.ID())
+		h.replace(p.BzzAddr)
+	}()
+	err := dp.Run(h.handleMsg(dp))
+	h.publishPeerEvent(newPeerEvent(PeerEventDisconnect, h.BaseAddr(), p.ID(), p.BzzAddr, err))
+	return err
 }
 
 func (h *Hive) trackPeer(p *BzzPeer) {
@@ -263,16 +375,17 @@ func (h *Hive) loadPeers() error {
 		}
 		return err
 	}
-	// workaround for old node stores not containing capabilities
-	for i := range as {
-		if as[i].Capabilities == nil {
-			caps := capability.NewCapabilities()
-			caps.Add(fullCapability)
-			as[i] = as[i].WithCapabilities(caps)
-		}
-	}
 	log.Info(fmt.Sprintf("hive %08x: peers loaded", h.BaseAddr()[:4]))
 	errRegistering := h.Register(as...)
+	var uptimes []PeerUptime
+	err = h.Store.Get(uptimesKey, &uptimes)
+	if err != nil {
+		if err != state.ErrNotFound {
+			log.Warn(fmt.Sprintf("hive %08x: error loading peer uptimes: %v", h.BaseAddr()[:4], err))
+		}
+	} else {
+		h.Kademlia.SetUptimes(uptimes)
+	}
 	var conns []*BzzAddr
 	err = h.Store.Get(connectionsKey, &conns)
 	if err != nil {
@@ -298,6 +411,7 @@ func (h *Hive) connectInitialPeers(conns []*BzzAddr) {
 			continue
 		}
 		log.Trace(fmt.Sprintf("%08x attempt to connect to bee %08x", h.BaseAddr()[:4], addr.Address()[:4]))
+		h.setUnderlays(under, addr)
 		h.addPeer(under)
 	}
 }
@@ -328,6 +442,10 @@ func (h *Hive) savePeers() error {
 	if err := h.Store.Put(connectionsKey, conns); err != nil {
 		return fmt.Errorf("could not save peer connections: %v", err)
 	}
+
+	if err := h.Store.Put(uptimesKey, h.Kademlia.Uptimes()); err != nil {
+		return fmt.Errorf("could not save peer uptimes: %v", err)
+	}
 	return nil
 }
 
@@ -341,6 +459,8 @@ func (h *Hive) handleMsg(p *Peer) func(context.Context, interface{}) error {
 			return h.handlePeersMsg(p, msg)
 		case *subPeersMsg:
 			return h.handleSubPeersMsg(ctx, p, msg)
+		case *pricesMsg:
+			return h.handlePricesMsg(p, msg)
 		}
 
 		return fmt.Errorf("unknown message type: %T", msg)
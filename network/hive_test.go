@@ -17,6 +17,8 @@
 package network
 
 import (
+	"bytes"
+	"context"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -27,6 +29,8 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/log"
 	p2ptest "github.com/ethersphere/swarm/p2p/testing"
 	"github.com/ethersphere/swarm/pot"
@@ -105,6 +109,95 @@ func TestRegisterAndConnect(t *testing.T) {
 	}
 }
 
+// TestHivePeerEvents verifies that a connect event carrying the peer
+// overlay metadata is published when a peer is added to the hive
+func TestHivePeerEvents(t *testing.T) {
+	prvkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseAddr := PrivateKeyToBzzKey(prvkey)
+	pp := NewHive(NewHiveParams(), NewKademlia(baseAddr, NewKadParams()), nil)
+
+	sub := pp.peerEvents.Subscribe()
+	defer sub.Unsubscribe()
+
+	addr := pot.RandomAddress()
+	s, _, err := newBzzBaseTesterWithAddrs(prvkey, [][]byte{addr[:]}, DiscoverySpec, pp.Run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	select {
+	case msg := <-sub.ReceiveChannel():
+		ev := msg.(PeerEvent)
+		if ev.Type != PeerEventConnect {
+			t.Fatalf("expected event type %v, got %v", PeerEventConnect, ev.Type)
+		}
+		if !bytes.Equal(ev.OAddr, addr[:]) {
+			t.Fatalf("expected overlay address %x, got %x", addr[:], ev.OAddr)
+		}
+		if expected := chunk.Proximity(baseAddr, addr[:]); ev.Bin != expected {
+			t.Fatalf("expected bin %d, got %d", expected, ev.Bin)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for peer connect event")
+	}
+}
+
+// TestPeerEventsAPISubscriptions verifies that the peer events subscription
+// is closed when the subscriber unsubscribes and when the node shuts down
+func TestPeerEventsAPISubscriptions(t *testing.T) {
+	h := NewHive(NewHiveParams(), NewKademlia(pot.RandomAddress().Bytes(), NewKadParams()), nil)
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("bzz", NewPeerEventsAPI(h)); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	waitSubscriptions := func(count int) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for h.peerEvents.NumSubscriptions() != count {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %d peer events subscriptions, got %d", count, h.peerEvents.NumSubscriptions())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	events := make(chan PeerEvent)
+	sub, err := client.Subscribe(context.Background(), "bzz", events, "peerEvents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitSubscriptions(1)
+	sub.Unsubscribe()
+	waitSubscriptions(0)
+
+	sub, err = client.Subscribe(context.Background(), "bzz", events, "peerEvents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+	waitSubscriptions(1)
+	pubsub := h.peerEvents.Subscribe()
+	h.closePeerEvents()
+	if !pubsub.IsClosed() {
+		t.Fatal("expected peer events subscription to be closed on shutdown")
+	}
+	// events are not published after shutdown
+	h.publishPeerEvent(newPeerEvent(PeerEventConnect, h.BaseAddr(), enode.ID{}, nil, nil))
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected peer event %v after shutdown", ev)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
 // TestHiveStatePersistence creates a protocol simulation with n peers for a node
 // After protocols complete, the node is shut down and the state is stored.
 // Another simulation is created, where 0 nodes are created, but where the stored state is passed
@@ -181,6 +274,38 @@ func TestHiveStatePersistence(t *testing.T) {
 	}
 }
 
+// TestKademliaSchemaMigration checks that persisted addresses of stores written
+// before peer capabilities were introduced get the full capability
+func TestKademliaSchemaMigration(t *testing.T) {
+	store := state.NewInmemoryStore()
+	defer store.Close()
+
+	addr := RandomBzzAddr()
+	if err := store.Put(addressesKey, []*BzzAddr{{OAddr: addr.OAddr, UAddr: addr.UAddr}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.Migrate(store, KademliaSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	var as []*BzzAddr
+	if err := store.Get(addressesKey, &as); err != nil {
+		t.Fatal(err)
+	}
+	if len(as) != 1 {
+		t.Fatalf("got %v addresses, want 1", len(as))
+	}
+	if !bytes.Equal(as[0].OAddr, addr.OAddr) {
+		t.Fatalf("got address %x, want %x", as[0].OAddr, addr.OAddr)
+	}
+	if caps := as[0].Capabilities; caps == nil || len(caps.Caps) != 1 || !caps.Caps[0].IsSameAs(fullCapability) {
+		t.Fatalf("got capabilities %v, want full capability", as[0].Capabilities)
+	}
+	if err := store.Get(connectionsKey, &as); err != state.ErrNotFound {
+		t.Fatalf("got error %v, want %v", err, state.ErrNotFound)
+	}
+}
+
 // TestHiveStateConnections connect the node to some peers and then after cleanup/save in store those peers
 // are retrieved and used as suggested peer initially.
 func TestHiveStateConnections(t *testing.T) {
@@ -28,6 +28,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/log"
@@ -59,6 +60,12 @@ node from the other.
 
 var Pof = pot.DefaultPof(256)
 
+// peers added to or removed from the table recently are replayed to late subscribers of peer changes
+const (
+	peerChangesHistorySize = 256
+	peerChangesHistoryAge  = time.Minute
+)
+
 // KadParams holds the config params for Kademlia
 type KadParams struct {
 	// adjustable parameters
@@ -69,6 +76,17 @@ type KadParams struct {
 	RetryInterval     int64 // initial interval before a peer is first redialed
 	RetryExponent     int   // exponent to multiply retry intervals with
 	MaxRetries        int   // maximum number of redial attempts
+	// error budget of peers, a peer exceeding it is dropped and not dialed for a while
+	ErrorBudget         int           // number of protocol errors tolerated within ErrorBudgetWindow, 0 disables
+	ErrorBudgetWindow   time.Duration // sliding window in which protocol errors are counted
+	DemotionBanDuration time.Duration // how long a demoted peer is not dialed
+	// known but unconnected peers kept per bin to replace dropped connections
+	BinCacheSize int           // maximum number of cached peers per bin, 0 disables
+	BinCacheTTL  time.Duration // time after which a cached peer not advertised again is not dialed
+	// prefer suggesting peers that stayed connected longer in the past to reduce connection churn
+	UptimeBias bool
+	// source of time, the system clock if nil
+	Clock mclock.Clock `json:"-"`
 	// function to sanction or prevent suggesting a peer
 	Reachable    func(*BzzAddr) bool      `json:"-"`
 	Capabilities *capability.Capabilities `json:"-"`
@@ -85,6 +103,13 @@ func NewKadParams() *KadParams {
 		MaxRetries:        42,
 		RetryExponent:     2,
 		Capabilities:      capability.NewCapabilities(),
+
+		ErrorBudget:         20,
+		ErrorBudgetWindow:   10 * time.Minute,
+		DemotionBanDuration: time.Hour,
+
+		BinCacheSize: 16,
+		BinCacheTTL:  30 * time.Minute,
 	}
 }
 
@@ -101,6 +126,10 @@ type Kademlia struct {
 	nDepthSig       []chan struct{}             // signals when neighbourhood depth nDepth is changed
 
 	onOffPeerPubSub *pubsubchannel.PubSubChannel // signals on and off peers in the table
+	errorBudget     *errorBudget                 // protocol errors and dial bans of peers
+	binCache        *binCache                    // recently advertised unconnected peers per bin
+	uptimes         map[string]*PeerUptime       // connection history of peers by overlay address
+	clock           *Clock                       // source of time for redial backoffs and expiries
 }
 
 type KademliaInfo struct {
@@ -127,7 +156,11 @@ func NewKademlia(addr []byte, params *KadParams) *Kademlia {
 		KadParams:       params,
 		capabilityIndex: make(map[string]*capabilityIndex),
 		defaultIndex:    NewDefaultIndex(),
-		onOffPeerPubSub: pubsubchannel.New(100),
+		onOffPeerPubSub: pubsubchannel.NewWithHistory(100, peerChangesHistorySize, peerChangesHistoryAge),
+		errorBudget:     newErrorBudget(),
+		binCache:        newBinCache(params.BinCacheSize, params.BinCacheTTL),
+		uptimes:         make(map[string]*PeerUptime),
+		clock:           NewClock(params.Clock, time.Now()),
 	}
 	k.RegisterCapabilityIndex("full", *fullCapability)
 	k.RegisterCapabilityIndex("light", *lightCapability)
@@ -173,9 +206,9 @@ func (k *Kademlia) addToCapabilityIndex(p interface{}) {
 			if vCap.Match(idxItem.Capability) {
 				log.Trace("Added peer to capability index", "conn", ok, "s", s, "v", vCap, "p", p)
 				if ok {
-					k.capabilityIndex[s].conns, _, _ = pot.Add(idxItem.conns, newEntryFromPeer(ePeer), Pof)
+					k.capabilityIndex[s].conns, _, _ = pot.Add(idxItem.conns, k.newEntryFromPeer(ePeer), Pof)
 				} else {
-					k.capabilityIndex[s].addrs, _, _ = pot.Add(idxItem.addrs, newEntryFromBzzAddress(eAddr), Pof)
+					k.capabilityIndex[s].addrs, _, _ = pot.Add(idxItem.addrs, k.newEntryFromBzzAddress(eAddr), Pof)
 				}
 			}
 		}
@@ -197,7 +230,7 @@ func (k *Kademlia) removeFromCapabilityIndex(p interface{}, disconnectOnly bool)
 	}
 	for s, idxItem := range k.capabilityIndex {
 		if ok {
-			peerEntry := newEntryFromPeer(ePeer)
+			peerEntry := k.newEntryFromPeer(ePeer)
 			conns, _, found, _ := pot.Swap(idxItem.conns, peerEntry, Pof, func(_ pot.Val) pot.Val {
 				return nil
 			})
@@ -227,19 +260,19 @@ type entry struct {
 }
 
 // newEntryFromBzzAddress creates a kademlia entry from a *BzzAddr
-func newEntryFromBzzAddress(p *BzzAddr) *entry {
+func (k *Kademlia) newEntryFromBzzAddress(p *BzzAddr) *entry {
 	return &entry{
 		BzzAddr: p,
-		seenAt:  time.Now(),
+		seenAt:  k.clock.Time(),
 	}
 }
 
 // newEntryFromPeer creates a kademlia entry from a *Peer
-func newEntryFromPeer(p *Peer) *entry {
+func (k *Kademlia) newEntryFromPeer(p *Peer) *entry {
 	return &entry{
 		BzzAddr: p.BzzAddr,
 		conn:    p,
-		seenAt:  time.Now(),
+		seenAt:  k.clock.Time(),
 	}
 }
 
@@ -296,26 +329,31 @@ func (k *Kademlia) Register(peers ...*BzzAddr) error {
 			return fmt.Errorf("add peers: %x is self", k.base)
 		}
 		index := k.defaultIndex
+		var connected bool
 		index.addrs, _, _, _ = pot.Swap(index.addrs, p, Pof, func(v pot.Val) pot.Val {
 			// if not found
 			if v == nil {
 				log.Trace("registering new peer", "addr", p)
 				// insert new offline peer into addrs
-				return newEntryFromBzzAddress(p)
+				return k.newEntryFromBzzAddress(p)
 			}
 
 			e := v.(*entry)
+			connected = e.conn != nil
 
 			// if underlay address is different, still add
 			if !bytes.Equal(e.BzzAddr.UAddr, p.UAddr) {
 				log.Trace("underlay addr is different, so add again", "new", p, "old", e.BzzAddr)
 				// insert new offline peer into addrs
-				return newEntryFromBzzAddress(p)
+				return k.newEntryFromBzzAddress(p)
 			}
 
 			return v
 		})
-		k.addToCapabilityIndex(newEntryFromBzzAddress(p))
+		k.addToCapabilityIndex(k.newEntryFromBzzAddress(p))
+		if !connected {
+			k.binCache.add(chunk.Proximity(k.base, p.Address()), p, k.clock.Time())
+		}
 		size++
 	}
 
@@ -430,6 +468,10 @@ func (k *Kademlia) SuggestPeer() (suggestedPeer *BzzAddr, saturationDepth int, c
 			// curPO found
 			// find a callable peer out of the addresses in the unsaturated bin
 			// stop if found
+			if k.UptimeBias {
+				suggestedPeer = k.suggestByUptime(bin)
+				return cur < len(bins) && suggestedPeer == nil
+			}
 			bin.ValIterator(func(val pot.Val) bool {
 				e := val.(*entry)
 				if k.callable(e) {
@@ -457,7 +499,7 @@ func (k *Kademlia) On(p *Peer) (uint8, bool) {
 
 	var ins bool
 	index := k.defaultIndex
-	peerEntry := newEntryFromPeer(p)
+	peerEntry := k.newEntryFromPeer(p)
 	var po int
 	index.conns, po, _, _ = pot.Swap(index.conns, peerEntry, Pof, func(v pot.Val) pot.Val {
 		// if not found live
@@ -474,7 +516,9 @@ func (k *Kademlia) On(p *Peer) (uint8, bool) {
 	k.onOffPeerPubSub.Publish(onOffPeerSignal{peer: p, po: po, on: true})
 
 	if ins {
-		a := newEntryFromBzzAddress(p.BzzAddr)
+		k.binCache.remove(chunk.Proximity(k.base, p.Address()), p.Address())
+		k.connectedUptime(p.Address(), k.clock.Time())
+		a := k.newEntryFromBzzAddress(p.BzzAddr)
 		a.conn = p
 		// insert new online peer into addrs
 		index.addrs, _, _, _ = pot.Swap(index.addrs, a, Pof, func(v pot.Val) pot.Val {
@@ -579,8 +623,10 @@ func (k *Kademlia) SubscribeToNeighbourhoodDepthChange() (c <-chan struct{}, uns
 // when a new Peer is added or removed from the table. Returned function unsubscribes
 // the channel from signaling and releases the resources. Returned function is safe
 // to be called multiple times.
+// Changes that happened in the last minute are signalled first, so a subscriber
+// started after peers connected does not miss them.
 func (k *Kademlia) SubscribeToPeerChanges() *pubsubchannel.Subscription {
-	return k.onOffPeerPubSub.Subscribe()
+	return k.onOffPeerPubSub.SubscribeWithReplay()
 }
 
 // Off removes a peer from among live peers
@@ -593,7 +639,7 @@ func (k *Kademlia) Off(p *Peer) {
 		if v == nil {
 			panic(fmt.Sprintf("connected peer not found %v", p))
 		}
-		return newEntryFromBzzAddress(p.BzzAddr)
+		return k.newEntryFromBzzAddress(p.BzzAddr)
 	})
 	// note the following only ran if the peer was a lightnode
 	index.conns, _, _, _ = pot.Swap(index.conns, p, Pof, func(_ pot.Val) pot.Val {
@@ -601,6 +647,7 @@ func (k *Kademlia) Off(p *Peer) {
 		return nil
 	})
 	k.removeFromCapabilityIndex(p, true)
+	k.disconnectedUptime(p.Address(), k.clock.Time())
 	k.setNeighbourhoodDepth()
 	k.onOffPeerPubSub.Publish(onOffPeerSignal{peer: p, po: -1, on: false})
 }
@@ -811,7 +858,7 @@ func (k *Kademlia) callable(e *entry) bool {
 		return false
 	}
 	// calculate the allowed number of retries based on time lapsed since last seen
-	timeAgo := int64(time.Since(e.seenAt))
+	timeAgo := int64(k.clock.Since(e.seenAt))
 	div := int64(k.RetryExponent)
 	div += (150000 - rand.Int63n(300000)) * div / 1000000
 	var retries int
@@ -824,6 +871,11 @@ func (k *Kademlia) callable(e *entry) bool {
 		log.Trace(fmt.Sprintf("%08x: %v long time since last try (at %v) needed before retry %v, wait only warrants %v", k.BaseAddr()[:4], e, timeAgo, e.retries, retries))
 		return false
 	}
+	// peers demoted for exceeding their error budget are not dialed until the ban expires
+	if k.errorBudget.banned(e.Address(), k.clock.Time()) {
+		log.Trace(fmt.Sprintf("%08x: peer %v is banned", k.BaseAddr()[:4], e))
+		return false
+	}
 	// function to sanction or prevent suggesting a peer
 	if k.Reachable != nil && !k.Reachable(e.BzzAddr) {
 		log.Trace(fmt.Sprintf("%08x: peer %v is temporarily not callable", k.BaseAddr()[:4], e))
@@ -946,7 +998,7 @@ func (k *Kademlia) string() string {
 	if len(sv.GitCommit) > 0 {
 		rows = append(rows, fmt.Sprintf("commit hash: %s", sv.GitCommit))
 	}
-	rows = append(rows, fmt.Sprintf("%v KΛÐΞMLIΛ hive: queen's address: %x", time.Now().UTC().Format(time.UnixDate), k.BaseAddr()))
+	rows = append(rows, fmt.Sprintf("%v KΛÐΞMLIΛ hive: queen's address: %x", k.clock.Time().UTC().Format(time.UnixDate), k.BaseAddr()))
 	rows = append(rows, fmt.Sprintf("population: %d (%d), NeighbourhoodSize: %d, MinBinSize: %d, MaxBinSize: %d", k.defaultIndex.conns.Size(), k.defaultIndex.addrs.Size(), k.NeighbourhoodSize, k.MinBinSize, k.MaxBinSize))
 
 	liverows := make([]string, k.MaxProxDisplay)
@@ -17,6 +17,8 @@ package network
 
 import (
 	"bytes"
+	"sort"
+	"sync"
 
 	"github.com/ethersphere/swarm/log"
 	"github.com/ethersphere/swarm/network/pubsubchannel"
@@ -92,6 +94,21 @@ type KademliaLoadBalancer struct {
 	quitC            chan struct{}
 
 	initCountFunc func(peer *Peer, po int) int //Function to use for initializing a new peer count
+
+	priceMtx  sync.RWMutex
+	priceFunc PeerPriceFunc // if set, peers in a bin are sorted by price before use count
+}
+
+// PeerPriceFunc returns the price a peer charges for a service and whether the price is known.
+type PeerPriceFunc func(peer *Peer) (uint64, bool)
+
+// SetPeerPriceFunc sets a price-aware selection hook. When set, the peers of every bin are provided
+// cheapest first, peers with the same price in least used order, and peers with unknown price last.
+// A nil priceFunc restores plain least used ordering.
+func (klb *KademliaLoadBalancer) SetPeerPriceFunc(priceFunc PeerPriceFunc) {
+	klb.priceMtx.Lock()
+	defer klb.priceMtx.Unlock()
+	klb.priceFunc = priceFunc
 }
 
 // Stop unsubscribe from notifiers
@@ -100,6 +117,33 @@ func (klb *KademliaLoadBalancer) Stop() {
 	close(klb.quitC)
 }
 
+// LoadBalancerAPI exposes the peer use stats of a KademliaLoadBalancer, so that the load distribution
+// can be evaluated over time windows.
+type LoadBalancerAPI struct {
+	klb *KademliaLoadBalancer
+}
+
+// NewLoadBalancerAPI creates a new LoadBalancerAPI for the load balancer.
+func NewLoadBalancerAPI(klb *KademliaLoadBalancer) *LoadBalancerAPI {
+	return &LoadBalancerAPI{klb: klb}
+}
+
+// UseStats returns the uses of every peer, and their rate per minute, in the current interval.
+func (api *LoadBalancerAPI) UseStats() resourceusestats.UseSnapshot {
+	return api.klb.resourceUseStats.Snapshot()
+}
+
+// UseStatsHistory returns the use stats of the last rotated intervals, oldest first.
+func (api *LoadBalancerAPI) UseStatsHistory() []resourceusestats.UseSnapshot {
+	return api.klb.resourceUseStats.Snapshots()
+}
+
+// RotateUseStats closes the current interval and starts a new one, returning the stats of the closed interval.
+// The total use counts the peers are balanced by are not reset.
+func (api *LoadBalancerAPI) RotateUseStats() resourceusestats.UseSnapshot {
+	return api.klb.resourceUseStats.Rotate()
+}
+
 // EachBinNodeAddress calls EachBinDesc with the base address of kademlia (the node address)
 func (klb *KademliaLoadBalancer) EachBinNodeAddress(consumeBin LBBinConsumer) {
 	klb.EachBinDesc(klb.kademlia.BaseAddr(), consumeBin)
@@ -109,27 +153,58 @@ func (klb *KademliaLoadBalancer) EachBinNodeAddress(consumeBin LBBinConsumer) {
 // Only peers with the provided capabilities capKey are considered.
 // All peers in that bin will be provided to the LBBinConsumer sorted by least used first.
 func (klb *KademliaLoadBalancer) EachBinFiltered(base []byte, capKey string, consumeBin LBBinConsumer) error {
+	return klb.EachBinFilteredExcluding(base, capKey, nil, consumeBin)
+}
+
+// EachBinFilteredExcluding is as EachBinFiltered, but peers whose key is in the exclude set are left out.
+// Bins left without peers are skipped.
+func (klb *KademliaLoadBalancer) EachBinFilteredExcluding(base []byte, capKey string, exclude map[string]struct{}, consumeBin LBBinConsumer) error {
 	return klb.kademlia.EachBinDescFiltered(base, capKey, 0, func(peerBin *PeerBin) bool {
-		peers := klb.peerBinToPeerList(peerBin)
-		return consumeBin(LBBin{LBPeers: peers, ProximityOrder: peerBin.ProximityOrder})
+		return klb.consumePeerBin(peerBin, exclude, consumeBin)
 	})
 }
 
 // EachBinDesc returns all bins in descending order from the perspective of base address.
 // All peers in that bin will be provided to the LBBinConsumer sorted by least used first.
 func (klb *KademliaLoadBalancer) EachBinDesc(base []byte, consumeBin LBBinConsumer) {
+	klb.EachBinDescExcluding(base, nil, consumeBin)
+}
+
+// EachBinDescExcluding is as EachBinDesc, but peers whose key is in the exclude set are left out, so that
+// retries can ask for the next best peers without the ones that already failed. Bins left without peers are skipped.
+func (klb *KademliaLoadBalancer) EachBinDescExcluding(base []byte, exclude map[string]struct{}, consumeBin LBBinConsumer) {
 	klb.kademlia.EachBinDesc(base, 0, func(peerBin *PeerBin) bool {
-		peers := klb.peerBinToPeerList(peerBin)
-		return consumeBin(LBBin{LBPeers: peers, ProximityOrder: peerBin.ProximityOrder})
+		return klb.consumePeerBin(peerBin, exclude, consumeBin)
+	})
+}
+
+// SuggestPeer returns the least used peer in the closest bin to base address whose key is not in
+// the exclude set, or false if there is none. The caller should call AddUseCount() if the peer is used.
+func (klb *KademliaLoadBalancer) SuggestPeer(base []byte, exclude map[string]struct{}) (LBPeer, bool) {
+	var suggested LBPeer
+	var found bool
+	klb.EachBinDescExcluding(base, exclude, func(bin LBBin) bool {
+		suggested = bin.LBPeers[0]
+		found = true
+		return false
 	})
+	return suggested, found
+}
+
+func (klb *KademliaLoadBalancer) consumePeerBin(peerBin *PeerBin, exclude map[string]struct{}, consumeBin LBBinConsumer) bool {
+	peers := klb.peerBinToPeerList(peerBin, exclude)
+	if len(peers) == 0 {
+		return true
+	}
+	return consumeBin(LBBin{LBPeers: peers, ProximityOrder: peerBin.ProximityOrder})
 }
 
-func (klb *KademliaLoadBalancer) peerBinToPeerList(bin *PeerBin) []LBPeer {
-	resources := make([]resourceusestats.Resource, bin.Size)
-	var i int
+func (klb *KademliaLoadBalancer) peerBinToPeerList(bin *PeerBin, exclude map[string]struct{}) []LBPeer {
+	resources := make([]resourceusestats.Resource, 0, bin.Size)
 	bin.PeerIterator(func(entry *entry) bool {
-		resources[i] = entry.conn
-		i++
+		if _, excluded := exclude[entry.conn.Key()]; !excluded {
+			resources = append(resources, entry.conn)
+		}
 		return true
 	})
 	return klb.resourcesToLbPeers(resources)
@@ -138,9 +213,35 @@ func (klb *KademliaLoadBalancer) peerBinToPeerList(bin *PeerBin) []LBPeer {
 func (klb *KademliaLoadBalancer) resourcesToLbPeers(resources []resourceusestats.Resource) []LBPeer {
 	sorted := klb.resourceUseStats.SortResources(resources)
 	peers := klb.toLBPeers(sorted)
+	klb.sortByPrice(peers)
 	return peers
 }
 
+// sortByPrice sorts the peers by the price returned by the price hook, keeping the
+// least used ordering among peers with the same price. Peers with unknown price go last.
+func (klb *KademliaLoadBalancer) sortByPrice(peers []LBPeer) {
+	klb.priceMtx.RLock()
+	priceFunc := klb.priceFunc
+	klb.priceMtx.RUnlock()
+	if priceFunc == nil {
+		return
+	}
+	prices := make(map[*Peer]uint64, len(peers))
+	for _, lbPeer := range peers {
+		if price, ok := priceFunc(lbPeer.Peer); ok {
+			prices[lbPeer.Peer] = price
+		}
+	}
+	sort.SliceStable(peers, func(i, j int) bool {
+		pi, iok := prices[peers[i].Peer]
+		pj, jok := prices[peers[j].Peer]
+		if iok != jok {
+			return iok
+		}
+		return pi < pj
+	})
+}
+
 func (klb *KademliaLoadBalancer) listenOnOffPeers() {
 	for {
 		select {
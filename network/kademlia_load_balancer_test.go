@@ -38,6 +38,9 @@ func TestAddedNodes(t *testing.T) {
 	klb := NewKademliaLoadBalancer(kademlia, false)
 
 	defer klb.Stop()
+	// peers added before start are initialized from the replayed peer changes
+	klb.resourceUseStats.WaitKey(first.Key())
+	klb.resourceUseStats.WaitKey(second.Key())
 	firstUses := klb.resourceUseStats.GetUses(first)
 	if firstUses != 0 {
 		t.Errorf("Expected 0 uses for new peer at start")
@@ -67,6 +70,33 @@ func TestAddedNodes(t *testing.T) {
 
 // TestAddedNodesNearestNeighbour checks that when adding a node it is assigned the correct number of uses.
 // This number of uses will be the most similar peer uses.
+
+// TestAddedNodesBeforeStart checks that peers connected shortly before the load balancer was created are
+// still initialized from the replayed peer changes, and removed peers are not left behind.
+func TestAddedNodesBeforeStart(t *testing.T) {
+	kademlia := newTestKademlia(t, "11110000")
+	first := newTestKadPeer("010101010")
+	kademlia.Kademlia.On(first)
+	second := newTestKadPeer("010101011")
+	kademlia.Kademlia.On(second)
+	kademlia.Kademlia.Off(second)
+	klb := NewKademliaLoadBalancer(kademlia, false)
+	defer klb.Stop()
+
+	// a peer added afterwards is signalled after the replayed ones
+	third := newTestKadPeer("011101011")
+	kademlia.Kademlia.On(third)
+	klb.resourceUseStats.WaitKey(third.Key())
+
+	uses := klb.resourceUseStats.DumpAllUses()
+	if _, ok := uses[first.Key()]; !ok {
+		t.Errorf("Expected peer added before start to be initialized")
+	}
+	if _, ok := uses[second.Key()]; ok {
+		t.Errorf("Expected peer removed before start not to be tracked")
+	}
+}
+
 func TestAddedNodesNearestNeighbour(t *testing.T) {
 	kademlia := newTestKademlia(t, "11110000")
 	first := newTestKadPeer("01010101")
@@ -76,6 +106,9 @@ func TestAddedNodesNearestNeighbour(t *testing.T) {
 	klb := NewKademliaLoadBalancer(kademlia, true)
 
 	defer klb.Stop()
+	// peers added before start are initialized from the replayed peer changes
+	klb.resourceUseStats.WaitKey(first.Key())
+	klb.resourceUseStats.WaitKey(second.Key())
 	firstUses := klb.resourceUseStats.GetUses(first)
 	if firstUses != 0 {
 		t.Errorf("Expected 0 uses for new peer at start")
@@ -341,3 +374,98 @@ func byteToBitString(b byte) string {
 	}
 	return binary
 }
+
+// TestPeerPriceFunc checks that with a price hook set peers in a bin are returned cheapest first,
+// with peers of unknown price last, and that removing the hook restores least used ordering.
+func TestPeerPriceFunc(t *testing.T) {
+	tk := newTestKademlia(t, "00000000")
+	klb := NewKademliaLoadBalancer(tk, false)
+	defer klb.Stop()
+
+	expensive := tk.newTestKadPeer("10000000")
+	cheap := tk.newTestKadPeer("10000001")
+	unknown := tk.newTestKadPeer("10000010")
+	for _, p := range []*Peer{expensive, cheap, unknown} {
+		tk.Kademlia.On(p)
+		klb.resourceUseStats.WaitKey(p.Key())
+	}
+	expensive.setPrices(ServicePrices{Retrieve: 20})
+	cheap.setPrices(ServicePrices{Retrieve: 10})
+
+	// make the cheap peer the most used one
+	for _, lbPeer := range klb.getPeersForPo(tk.base, 0) {
+		if lbPeer.Peer == cheap {
+			lbPeer.AddUseCount()
+		}
+	}
+
+	klb.SetPeerPriceFunc(RetrievePrice)
+	peers := klb.getPeersForPo(tk.base, 0)
+	if len(peers) != 3 {
+		t.Fatalf("expected 3 peers in bin, got %v", len(peers))
+	}
+	for i, want := range []*Peer{cheap, expensive, unknown} {
+		if peers[i].Peer != want {
+			t.Errorf("expected peer %v at position %v, got %v", want.Label(), i, peers[i].Peer.Label())
+		}
+	}
+
+	klb.SetPeerPriceFunc(nil)
+	peers = klb.getPeersForPo(tk.base, 0)
+	if peers[len(peers)-1].Peer != cheap {
+		t.Errorf("expected most used peer %v last without price hook, got %v", cheap.Label(), peers[len(peers)-1].Peer.Label())
+	}
+}
+
+// TestSuggestPeerExcluding checks that excluded peers are never suggested nor have their uses counted,
+// and that bins left empty by the exclusion are skipped.
+func TestSuggestPeerExcluding(t *testing.T) {
+	tk := newTestKademlia(t, "00000000")
+	klb := NewKademliaLoadBalancer(tk, false)
+	defer klb.Stop()
+
+	near1 := tk.newTestKadPeer("00010000")
+	near2 := tk.newTestKadPeer("00010001")
+	far := tk.newTestKadPeer("10000000")
+	for _, p := range []*Peer{near1, near2, far} {
+		tk.Kademlia.On(p)
+		klb.resourceUseStats.WaitKey(p.Key())
+	}
+	pivot := pot.NewAddressFromString("00010000")
+
+	exclude := map[string]struct{}{near1.Key(): {}}
+	for i := 0; i < 3; i++ {
+		lbPeer, ok := klb.SuggestPeer(pivot, exclude)
+		if !ok || lbPeer.Peer != near2 {
+			t.Fatalf("expected suggested peer %v, got %v", near2.Label(), lbPeer.Peer)
+		}
+		lbPeer.AddUseCount()
+	}
+	if uses := klb.resourceUseStats.GetUses(near1); uses != 0 {
+		t.Errorf("expected no uses of excluded peer, got %v", uses)
+	}
+
+	exclude[near2.Key()] = struct{}{}
+	lbPeer, ok := klb.SuggestPeer(pivot, exclude)
+	if !ok || lbPeer.Peer != far {
+		t.Fatalf("expected suggested peer %v from the next bin, got %v", far.Label(), lbPeer.Peer)
+	}
+	var bins int
+	klb.EachBinDescExcluding(pivot, exclude, func(bin LBBin) bool {
+		for _, p := range bin.LBPeers {
+			if _, excluded := exclude[p.Peer.Key()]; excluded {
+				t.Errorf("excluded peer %v in bin %v", p.Peer.Label(), bin.ProximityOrder)
+			}
+		}
+		bins++
+		return true
+	})
+	if bins != 1 {
+		t.Errorf("expected 1 bin with peers left, got %v", bins)
+	}
+
+	exclude[far.Key()] = struct{}{}
+	if _, ok := klb.SuggestPeer(pivot, exclude); ok {
+		t.Errorf("expected no peer suggested when all are excluded")
+	}
+}
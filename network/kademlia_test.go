@@ -23,6 +23,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
@@ -546,7 +547,13 @@ func TestOffEffectingAddressBookNormalNode(t *testing.T) {
 }
 
 func TestSuggestPeerRetries(t *testing.T) {
-	tk := newTestKademlia(t, "00000000")
+	sim := new(mclock.Simulated)
+	params := newTestKademliaParams()
+	params.Clock = sim
+	tk := &testKademlia{
+		Kademlia: NewKademlia(pot.NewAddressFromString("00000000"), params),
+		t:        t,
+	}
 	tk.RetryInterval = int64(300 * time.Millisecond) // cycle
 	tk.MaxRetries = 50
 	tk.RetryExponent = 2
@@ -555,7 +562,7 @@ func TestSuggestPeerRetries(t *testing.T) {
 		for i := 1; i < n; i++ {
 			ts *= int64(tk.RetryExponent)
 		}
-		time.Sleep(time.Duration(ts))
+		sim.Run(time.Duration(ts) + time.Millisecond)
 	}
 
 	tk.Register("01000000")
@@ -0,0 +1,81 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+var (
+	errNetworkKeyProofMissing = errors.New("missing network key proof")
+	errNetworkKeyProofInvalid = errors.New("invalid network key proof")
+)
+
+// networkKeyProofDomain separates network key proofs from other uses of the key
+const networkKeyProofDomain = "swarm bzz network key proof"
+
+// NetworkKey derives the key of a private network from a pre-shared secret.
+func NetworkKey(secret string) []byte {
+	key := sha256.Sum256([]byte(secret))
+	return key[:]
+}
+
+// networkKeyProof returns the proof of knowing the network key sent in the
+// bzz handshake by the node with sender id to the node with receiver id.
+// As the underlay connection authenticates both node ids, a proof can not
+// be replayed by a node that does not know the key.
+func networkKeyProof(key []byte, networkID uint64, sender, receiver enode.ID) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(networkKeyProofDomain))
+	var id [8]byte
+	binary.BigEndian.PutUint64(id[:], networkID)
+	mac.Write(id[:])
+	mac.Write(sender[:])
+	mac.Write(receiver[:])
+	return mac.Sum(nil)
+}
+
+// checkNetworkKeyProof validates the network key proof in the handshake
+// received from the peer with id, if the node is in a private network
+func (b *Bzz) checkNetworkKeyProof(rhs *HandshakeMsg, id enode.ID) error {
+	if b.networkKey == nil {
+		return nil
+	}
+	if len(rhs.Proof) == 0 {
+		return errNetworkKeyProofMissing
+	}
+	want := networkKeyProof(b.networkKey, b.NetworkID, id, b.localID())
+	if !hmac.Equal(rhs.Proof[0], want) {
+		return errNetworkKeyProofInvalid
+	}
+	return nil
+}
+
+// localID returns the underlay node id of the local node,
+// parsed from either an enode URL or an ENR underlay address
+func (b *Bzz) localID() enode.ID {
+	n, err := enode.Parse(enode.ValidSchemes, string(b.localAddr.UAddr))
+	if err != nil {
+		return enode.ID{}
+	}
+	return n.ID()
+}
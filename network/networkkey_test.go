@@ -0,0 +1,120 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	p2ptest "github.com/ethersphere/swarm/p2p/testing"
+)
+
+// newBzzNetworkKeyTester creates a bzz handshake tester
+// of a private network with the key
+func newBzzNetworkKeyTester(t *testing.T, prvkey *ecdsa.PrivateKey, key []byte) *bzzTester {
+	t.Helper()
+
+	var record enr.Record
+	record.Set(NewENRAddrEntry(PrivateKeyToBzzKey(prvkey)))
+	if err := enode.SignV4(&record, prvkey); err != nil {
+		t.Fatal(err)
+	}
+	nod, err := enode.New(enode.V4ID{}, &record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := getENRBzzAddr(nod)
+	bzz := newBzz(addr, false)
+	bzz.networkKey = key
+
+	return &bzzTester{
+		addr:           addr,
+		ProtocolTester: p2ptest.NewProtocolTester(prvkey, 1, bzz.runBzz),
+		bzz:            bzz,
+	}
+}
+
+// TestBzzHandshakeNetworkKey validates that in a private network
+// only peers with a valid network key proof complete the handshake
+func TestBzzHandshakeNetworkKey(t *testing.T) {
+	key := NetworkKey("secret")
+
+	for _, tc := range []struct {
+		name  string
+		proof func(sender, receiver enode.ID) [][]byte
+		err   error
+	}{
+		{
+			name: "valid",
+			proof: func(sender, receiver enode.ID) [][]byte {
+				return [][]byte{networkKeyProof(key, TestProtocolNetworkID, sender, receiver)}
+			},
+		},
+		{
+			name: "missing",
+			proof: func(sender, receiver enode.ID) [][]byte {
+				return nil
+			},
+			err: errNetworkKeyProofMissing,
+		},
+		{
+			name: "wrong key",
+			proof: func(sender, receiver enode.ID) [][]byte {
+				return [][]byte{networkKeyProof(NetworkKey("other"), TestProtocolNetworkID, sender, receiver)}
+			},
+			err: errNetworkKeyProofInvalid,
+		},
+		{
+			name: "replayed",
+			proof: func(sender, receiver enode.ID) [][]byte {
+				return [][]byte{networkKeyProof(key, TestProtocolNetworkID, receiver, sender)}
+			},
+			err: errNetworkKeyProofInvalid,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			prvkey, err := crypto.GenerateKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := newBzzNetworkKeyTester(t, prvkey, key)
+			defer s.Stop()
+			node := s.Nodes[0]
+			localID := enode.PubkeyToIDV4(&prvkey.PublicKey)
+
+			lhs := correctBzzHandshake(s.addr, false)
+			lhs.Proof = [][]byte{networkKeyProof(key, TestProtocolNetworkID, localID, node.ID())}
+			rhs := newBzzHandshakeMsg(TestProtocolVersion, TestProtocolNetworkID, NewBzzAddrFromEnode(node), false)
+			rhs.Proof = tc.proof(node.ID(), localID)
+
+			var disconnects []*p2ptest.Disconnect
+			if tc.err != nil {
+				disconnects = append(disconnects, &p2ptest.Disconnect{
+					Peer:  node.ID(),
+					Error: fmt.Errorf("Handshake error: Message handler error: (msg code 0): %v", tc.err),
+				})
+			}
+			if err := s.testHandshake(lhs, rhs, disconnects...); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
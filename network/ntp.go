@@ -0,0 +1,72 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+)
+
+// ntpEpochOffset is the number of seconds between the NTP epoch in 1900 and the Unix epoch
+const ntpEpochOffset = 2208988800
+
+// sntpOffset queries the server with a single SNTP request and returns the offset
+// of the server clock to the host clock, positive if the host clock is behind
+func sntpOffset(server string, timeout time.Duration) (time.Duration, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+	conn, err := net.DialTimeout("udp", server, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return 0, err
+	}
+
+	// leap indicator 0, version 4, client mode
+	req := make([]byte, 48)
+	req[0] = 0<<6 | 4<<3 | 3
+	sent := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		return 0, err
+	}
+	resp := make([]byte, 48)
+	n, err := conn.Read(resp)
+	if err != nil {
+		return 0, err
+	}
+	received := time.Now()
+	if n < 48 || resp[0]&0x7 != 4 {
+		return 0, errors.New("invalid NTP server response")
+	}
+	// the times the server received the request and sent the response
+	serverReceived := ntpTime(resp[32:40])
+	serverSent := ntpTime(resp[40:48])
+	return (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2, nil
+}
+
+// ntpTime decodes an NTP timestamp of seconds and a binary fraction of a second
+func ntpTime(b []byte) time.Time {
+	sec := binary.BigEndian.Uint32(b[:4])
+	frac := binary.BigEndian.Uint32(b[4:8])
+	nsec := (uint64(frac) * 1e9) >> 32
+	return time.Unix(int64(sec)-ntpEpochOffset, int64(nsec))
+}
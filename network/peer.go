@@ -35,6 +35,8 @@ type Peer struct {
 	peers     map[string]bool // tracks node records sent to the peer
 	depth     uint8           // the proximity order advertised by remote as depth of saturation
 	key       string          // peer key. Hex form of Address()
+	prices    *ServicePrices  // service prices announced by remote, nil if none
+	legacy    bool            // the peer speaks the previous version of the protocol, without prices
 }
 
 // NewPeer constructs a discovery peer
@@ -0,0 +1,146 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/log"
+)
+
+// PeerEventType is the type of an overlay level peer event
+type PeerEventType string
+
+const (
+	PeerEventConnect          PeerEventType = "connect"          // peer completed the handshake and was added to kademlia
+	PeerEventDisconnect       PeerEventType = "disconnect"       // peer was removed from kademlia
+	PeerEventHandshakeFailure PeerEventType = "handshakeFailure" // bzz handshake with the peer failed
+)
+
+// PeerEvent is an overlay level peer event, carrying the peer metadata
+// known to the node at the time of the event
+type PeerEvent struct {
+	Type         PeerEventType
+	Time         time.Time
+	Peer         enode.ID
+	OAddr        hexutil.Bytes // overlay address, empty if the handshake failed
+	UAddr        string        // underlay address
+	Bin          int           // proximity order of the peer to the base address, -1 if unknown
+	Capabilities string        // advertised capabilities, empty if unknown
+	Reason       string        // disconnect or handshake failure reason
+}
+
+// newPeerEvent creates a peer event for a peer with a known overlay address
+func newPeerEvent(typ PeerEventType, base []byte, id enode.ID, addr *BzzAddr, reason error) PeerEvent {
+	ev := PeerEvent{
+		Type: typ,
+		Time: time.Now(),
+		Peer: id,
+		Bin:  -1,
+	}
+	if addr != nil {
+		ev.OAddr = addr.Over()
+		ev.UAddr = string(addr.Under())
+		if len(addr.Over()) == len(base) {
+			ev.Bin = chunk.Proximity(base, addr.Over())
+		}
+		if addr.Capabilities != nil {
+			ev.Capabilities = addr.Capabilities.String()
+		}
+	}
+	if reason != nil {
+		ev.Reason = reason.Error()
+	}
+	return ev
+}
+
+// publishPeerEvent notifies all peer event subscribers
+func (h *Hive) publishPeerEvent(ev PeerEvent) {
+	select {
+	case <-h.peerEventsQuit:
+		return
+	default:
+	}
+	h.peerEvents.Publish(ev)
+}
+
+// closePeerEvents closes all peer event subscriptions
+// it is called once the node shuts down, as the hive may be restarted
+func (h *Hive) closePeerEvents() {
+	h.peerEventsOnce.Do(func() {
+		// subscriptions are closed before quitting, so that
+		// subscribers do not unsubscribe them in the meantime
+		h.peerEvents.Close()
+		close(h.peerEventsQuit)
+	})
+}
+
+// PeerEventsAPI exposes overlay level peer events over RPC
+type PeerEventsAPI struct {
+	hive *Hive
+}
+
+// NewPeerEventsAPI creates a new PeerEventsAPI
+func NewPeerEventsAPI(h *Hive) *PeerEventsAPI {
+	return &PeerEventsAPI{hive: h}
+}
+
+// PeerEvents streams connect, disconnect and handshake failure events
+// to the subscriber, invoked as bzz_subscribe("peerEvents")
+func (a *PeerEventsAPI) PeerEvents(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, fmt.Errorf("Subscribe not supported")
+	}
+
+	rpcSub := notifier.CreateSubscription()
+	sub := a.hive.peerEvents.Subscribe()
+
+	go func() {
+		defer func() {
+			if !sub.IsClosed() {
+				sub.Unsubscribe()
+			}
+		}()
+		for {
+			select {
+			case ev, ok := <-sub.ReceiveChannel():
+				if !ok {
+					return
+				}
+				if err := notifier.Notify(rpcSub.ID, ev); err != nil {
+					log.Warn("peer event notification failed", "sub", rpcSub.ID, "err", err)
+				}
+			case err := <-rpcSub.Err():
+				log.Debug("peer events subscription error", "sub", rpcSub.ID, "err", err)
+				return
+			case <-notifier.Closed():
+				return
+			case <-a.hive.peerEventsQuit:
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
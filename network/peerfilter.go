@@ -0,0 +1,267 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// PeerFilter decides which peers may connect by their node IDs and
+// IP addresses. It is enforced on the bzz handshake of both inbound
+// and outbound connections. Deny rules take precedence over allow rules
+// and, if there is at least one allow rule, only peers matching an allow
+// rule can connect.
+//
+// A rule is a node ID in hex, an enode URL, an IP address or a CIDR range.
+type PeerFilter struct {
+	mtx   sync.RWMutex
+	allow *peerRules
+	deny  *peerRules
+}
+
+// peerRules is a set of node IDs and IP ranges
+type peerRules struct {
+	ids  map[enode.ID]struct{}
+	nets map[string]*net.IPNet // keyed by the range in CIDR notation
+}
+
+func newPeerRules() *peerRules {
+	return &peerRules{
+		ids:  make(map[enode.ID]struct{}),
+		nets: make(map[string]*net.IPNet),
+	}
+}
+
+// NewPeerFilter creates a PeerFilter with allow and deny rules.
+func NewPeerFilter(allow, deny []string) (*PeerFilter, error) {
+	f := &PeerFilter{
+		allow: newPeerRules(),
+		deny:  newPeerRules(),
+	}
+	for _, rule := range allow {
+		if err := f.Allow(rule); err != nil {
+			return nil, err
+		}
+	}
+	for _, rule := range deny {
+		if err := f.Deny(rule); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Allow adds an allow rule.
+func (f *PeerFilter) Allow(rule string) error {
+	return f.add(f.allow, rule)
+}
+
+// Deny adds a deny rule.
+func (f *PeerFilter) Deny(rule string) error {
+	return f.add(f.deny, rule)
+}
+
+func (f *PeerFilter) add(rules *peerRules, rule string) error {
+	id, ipnet, err := parsePeerRule(rule)
+	if err != nil {
+		return err
+	}
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if ipnet != nil {
+		rules.nets[ipnet.String()] = ipnet
+	} else {
+		rules.ids[id] = struct{}{}
+	}
+	return nil
+}
+
+// Remove removes the rule from both allow and deny rules and
+// returns false if there was no such rule.
+func (f *PeerFilter) Remove(rule string) (bool, error) {
+	id, ipnet, err := parsePeerRule(rule)
+	if err != nil {
+		return false, err
+	}
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	var found bool
+	for _, rules := range []*peerRules{f.allow, f.deny} {
+		if ipnet != nil {
+			if _, ok := rules.nets[ipnet.String()]; ok {
+				delete(rules.nets, ipnet.String())
+				found = true
+			}
+			continue
+		}
+		if _, ok := rules.ids[id]; ok {
+			delete(rules.ids, id)
+			found = true
+		}
+	}
+	return found, nil
+}
+
+// Check returns an error if the peer with node id connecting
+// from ip is not allowed to connect. The ip may be nil if unknown,
+// in which case only the node ID rules apply.
+func (f *PeerFilter) Check(id enode.ID, ip net.IP) error {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+	if f.deny.match(id, ip) {
+		return fmt.Errorf("peer %s denied", id.TerminalString())
+	}
+	if !f.allow.empty() && !f.allow.match(id, ip) {
+		return fmt.Errorf("peer %s not allowed", id.TerminalString())
+	}
+	return nil
+}
+
+// Rules returns the allow and deny rules.
+func (f *PeerFilter) Rules() PeerFilterRules {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+	return PeerFilterRules{
+		Allow: f.allow.list(),
+		Deny:  f.deny.list(),
+	}
+}
+
+// PeerFilterRules lists the rules of a PeerFilter.
+type PeerFilterRules struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+func (r *peerRules) empty() bool {
+	return len(r.ids) == 0 && len(r.nets) == 0
+}
+
+func (r *peerRules) match(id enode.ID, ip net.IP) bool {
+	if _, ok := r.ids[id]; ok {
+		return true
+	}
+	if ip == nil {
+		return false
+	}
+	for _, n := range r.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// list returns node IDs followed by IP ranges, both sorted
+func (r *peerRules) list() []string {
+	var ids, nets []string
+	for id := range r.ids {
+		ids = append(ids, id.String())
+	}
+	for n := range r.nets {
+		nets = append(nets, n)
+	}
+	sort.Strings(ids)
+	sort.Strings(nets)
+	return append(ids, nets...)
+}
+
+// parsePeerRule parses a node ID, enode URL, IP address or CIDR range.
+// The returned ipnet is nil for node ID rules.
+func parsePeerRule(rule string) (id enode.ID, ipnet *net.IPNet, err error) {
+	rule = strings.TrimSpace(rule)
+	if strings.HasPrefix(rule, "enode://") {
+		n, err := enode.ParseV4(rule)
+		if err != nil {
+			return id, nil, fmt.Errorf("invalid peer rule %q: %v", rule, err)
+		}
+		return n.ID(), nil, nil
+	}
+	if strings.Contains(rule, "/") {
+		_, ipnet, err := net.ParseCIDR(rule)
+		if err != nil {
+			return id, nil, fmt.Errorf("invalid peer rule %q: %v", rule, err)
+		}
+		return id, ipnet, nil
+	}
+	if ip := net.ParseIP(rule); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		return id, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	if err := id.UnmarshalText([]byte(rule)); err != nil {
+		return id, nil, fmt.Errorf("invalid peer rule %q: not a node ID, enode URL, IP address or CIDR range", rule)
+	}
+	return id, nil, nil
+}
+
+// remoteIP returns the IP address of the peer connection,
+// or nil if the connection is not over TCP
+func remoteIP(p *p2p.Peer) net.IP {
+	if addr, ok := p.RemoteAddr().(*net.TCPAddr); ok {
+		return addr.IP
+	}
+	return nil
+}
+
+// PeerFilterAPI modifies the peer filter at runtime, invoked
+// as bzz_allowPeer, bzz_denyPeer, bzz_removePeerRule and bzz_peerRules.
+// Changes apply to new connections and are not persisted.
+type PeerFilterAPI struct {
+	filter *PeerFilter
+}
+
+// NewPeerFilterAPI creates a new PeerFilterAPI
+func NewPeerFilterAPI(filter *PeerFilter) *PeerFilterAPI {
+	return &PeerFilterAPI{filter: filter}
+}
+
+// AllowPeer adds an allow rule.
+func (a *PeerFilterAPI) AllowPeer(rule string) error {
+	return a.filter.Allow(rule)
+}
+
+// DenyPeer adds a deny rule.
+func (a *PeerFilterAPI) DenyPeer(rule string) error {
+	return a.filter.Deny(rule)
+}
+
+// RemovePeerRule removes an allow or deny rule.
+func (a *PeerFilterAPI) RemovePeerRule(rule string) error {
+	found, err := a.filter.Remove(rule)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("no peer rule %q", rule)
+	}
+	return nil
+}
+
+// PeerRules returns the allow and deny rules.
+func (a *PeerFilterAPI) PeerRules() PeerFilterRules {
+	return a.filter.Rules()
+}
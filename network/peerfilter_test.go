@@ -0,0 +1,140 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	p2ptest "github.com/ethersphere/swarm/p2p/testing"
+)
+
+// TestPeerFilter validates allow and deny rules matching of node IDs and IP ranges
+func TestPeerFilter(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	enodeURL := enode.NewV4(&key.PublicKey, net.ParseIP("10.0.0.1"), 30399, 30399).URLv4()
+	enodeID := enode.PubkeyToIDV4(&key.PublicKey)
+	id := enode.HexID("0x1111111111111111111111111111111111111111111111111111111111111111")
+	other := enode.HexID("0x2222222222222222222222222222222222222222222222222222222222222222")
+
+	if _, err := NewPeerFilter([]string{"not a rule"}, nil); err == nil {
+		t.Fatal("expected error for an invalid rule")
+	}
+
+	f, err := NewPeerFilter(nil, []string{"192.168.0.0/16", id.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		id      enode.ID
+		ip      string
+		allowed bool
+	}{
+		{id: id, ip: "10.0.0.1", allowed: false},
+		{id: other, ip: "192.168.1.2", allowed: false},
+		{id: other, ip: "10.0.0.1", allowed: true},
+		{id: other, allowed: true},
+	} {
+		if err := f.Check(tc.id, net.ParseIP(tc.ip)); (err == nil) != tc.allowed {
+			t.Errorf("peer %s at %q: got error %v, want allowed %v", tc.id.TerminalString(), tc.ip, err, tc.allowed)
+		}
+	}
+
+	// with allow rules only matching peers can connect and deny rules take precedence
+	if err := f.Allow(enodeURL); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Allow("192.168.1.2"); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		id      enode.ID
+		ip      string
+		allowed bool
+	}{
+		{id: enodeID, ip: "10.0.0.2", allowed: true},
+		{id: other, ip: "10.0.0.1", allowed: false},
+		{id: other, ip: "192.168.1.2", allowed: false},
+	} {
+		if err := f.Check(tc.id, net.ParseIP(tc.ip)); (err == nil) != tc.allowed {
+			t.Errorf("peer %s at %q: got error %v, want allowed %v", tc.id.TerminalString(), tc.ip, err, tc.allowed)
+		}
+	}
+
+	want := PeerFilterRules{
+		Allow: []string{enodeID.String(), "192.168.1.2/32"},
+		Deny:  []string{id.String(), "192.168.0.0/16"},
+	}
+	if got := f.Rules(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got rules %+v, want %+v", got, want)
+	}
+
+	found, err := f.Remove("192.168.0.0/16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found {
+		t.Error("expected rule to be found")
+	}
+	if err := f.Check(other, net.ParseIP("192.168.1.2")); err != nil {
+		t.Errorf("expected peer to be allowed after removing the deny rule: %v", err)
+	}
+	found, err = f.Remove("192.168.0.0/16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found {
+		t.Error("expected removed rule not to be found")
+	}
+}
+
+// TestBzzHandshakePeerFilter validates that a peer not allowed
+// by the peer filter is disconnected before the handshake
+func TestBzzHandshakePeerFilter(t *testing.T) {
+	prvkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := RandomBzzAddr()
+	bzz := newBzz(addr, false)
+	// allow only an unknown peer
+	if err := bzz.peerFilter.Allow(enode.HexID("0x1111111111111111111111111111111111111111111111111111111111111111").String()); err != nil {
+		t.Fatal(err)
+	}
+	s := &bzzTester{
+		addr:           addr,
+		ProtocolTester: p2ptest.NewProtocolTester(prvkey, 1, bzz.runBzz),
+		bzz:            bzz,
+	}
+	defer s.Stop()
+	node := s.Nodes[0]
+
+	err = s.TestDisconnected(&p2ptest.Disconnect{
+		Peer:  node.ID(),
+		Error: fmt.Errorf("peer %s not allowed", node.ID().TerminalString()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"context"
+	"fmt"
+)
+
+// ServicePrices are the prices of the services a node offers to its peers,
+// in honey. A zero price means the node does not charge for the service.
+type ServicePrices struct {
+	Retrieve uint64 // price of a retrieve request
+	Push     uint64 // price of a pushed chunk
+}
+
+// pricesMsg is the message through which a node announces its current
+// service prices to its peers. It is only sent by nodes announcing prices,
+// and the last one received from a peer replaces any previous one.
+type pricesMsg struct {
+	Retrieve uint64
+	Push     uint64
+}
+
+// String pretty prints a pricesMsg
+func (msg pricesMsg) String() string {
+	return fmt.Sprintf("%T: retrieve %d, push %d", msg, msg.Retrieve, msg.Push)
+}
+
+// NotifyPrices sends a prices msg to the receiver announcing the
+// current service prices of the node, unless it can not receive them
+func (d *Peer) NotifyPrices(prices ServicePrices) {
+	if d.legacy {
+		return
+	}
+	go d.Send(context.TODO(), &pricesMsg{Retrieve: prices.Retrieve, Push: prices.Push})
+}
+
+// Prices returns the service prices last announced by the peer and
+// whether the peer announced any
+func (d *Peer) Prices() (ServicePrices, bool) {
+	d.mtx.RLock()
+	defer d.mtx.RUnlock()
+	if d.prices == nil {
+		return ServicePrices{}, false
+	}
+	return *d.prices, true
+}
+
+func (d *Peer) setPrices(prices ServicePrices) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.prices = &prices
+}
+
+// RetrievePrice is a PeerPriceFunc returning the retrieve request price
+// announced by the peer
+func RetrievePrice(peer *Peer) (uint64, bool) {
+	prices, ok := peer.Prices()
+	return prices.Retrieve, ok
+}
+
+// PushPrice is a PeerPriceFunc returning the push price announced by the peer
+func PushPrice(peer *Peer) (uint64, bool) {
+	prices, ok := peer.Prices()
+	return prices.Push, ok
+}
+
+// SetPrices sets the service prices of the node. If AnnouncePrices is enabled
+// the prices are sent to all connected peers, and to every new peer on connect.
+func (h *Hive) SetPrices(prices ServicePrices) {
+	h.pricesMtx.Lock()
+	h.prices = &prices
+	h.pricesMtx.Unlock()
+	if !h.AnnouncePrices {
+		return
+	}
+	h.EachConn(nil, 255, func(p *Peer, _ int) bool {
+		p.NotifyPrices(prices)
+		return true
+	})
+}
+
+// Prices returns the service prices of the node and whether they are set
+func (h *Hive) Prices() (ServicePrices, bool) {
+	h.pricesMtx.RLock()
+	defer h.pricesMtx.RUnlock()
+	if h.prices == nil {
+		return ServicePrices{}, false
+	}
+	return *h.prices, true
+}
+
+// SetDefaultPrices sets the service prices taken for peers speaking the
+// previous version of the protocol, which can not announce their prices.
+// It must be called before peers connect.
+func (h *Hive) SetDefaultPrices(prices ServicePrices) {
+	h.pricesMtx.Lock()
+	defer h.pricesMtx.Unlock()
+	h.defaultPrices = &prices
+}
+
+// DefaultPrices returns the service prices taken for peers which
+// can not announce their prices and whether they are set
+func (h *Hive) DefaultPrices() (ServicePrices, bool) {
+	h.pricesMtx.RLock()
+	defer h.pricesMtx.RUnlock()
+	if h.defaultPrices == nil {
+		return ServicePrices{}, false
+	}
+	return *h.defaultPrices, true
+}
+
+// handlePricesMsg caches the service prices announced by the peer
+func (h *Hive) handlePricesMsg(d *Peer, msg *pricesMsg) error {
+	d.setPrices(ServicePrices{Retrieve: msg.Retrieve, Push: msg.Push})
+	return nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	p2ptest "github.com/ethersphere/swarm/p2p/testing"
+)
+
+// TestHivePrices verifies that a hive announcing prices sends them to a
+// connecting peer and that the prices announced by the peer are cached
+func TestHivePrices(t *testing.T) {
+	params := NewHiveParams()
+	params.Discovery = false
+	params.AnnouncePrices = true
+	s, pp, err := newHiveTester(params, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	prices := ServicePrices{Retrieve: 100, Push: 10}
+	pp.SetPrices(prices)
+
+	peerID := s.Nodes[0].ID()
+	err = s.TestExchanges(p2ptest.Exchange{
+		Label: "outgoing pricesMsg",
+		Expects: []p2ptest.Expect{
+			{
+				Code: 2,
+				Msg:  &pricesMsg{Retrieve: prices.Retrieve, Push: prices.Push},
+				Peer: peerID,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var peer *Peer
+	pp.EachConn(nil, 255, func(p *Peer, _ int) bool {
+		peer = p
+		return false
+	})
+	if peer == nil {
+		t.Fatal("expected connected peer")
+	}
+	if _, ok := peer.Prices(); ok {
+		t.Fatal("expected no prices before the peer announces them")
+	}
+
+	peerPrices := ServicePrices{Retrieve: 200}
+	err = s.TestExchanges(p2ptest.Exchange{
+		Label: "incoming pricesMsg",
+		Triggers: []p2ptest.Trigger{
+			{
+				Code: 2,
+				Msg:  &pricesMsg{Retrieve: peerPrices.Retrieve, Push: peerPrices.Push},
+				Peer: peerID,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for attempts := 0; attempts < 1000; attempts++ {
+		if got, ok := peer.Prices(); ok {
+			if got != peerPrices {
+				t.Fatalf("got peer prices %+v, want %+v", got, peerPrices)
+			}
+			if price, _ := RetrievePrice(peer); price != peerPrices.Retrieve {
+				t.Fatalf("got retrieve price %v, want %v", price, peerPrices.Retrieve)
+			}
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("timeout waiting for peer prices")
+}
+
+// TestHiveLegacyPrices verifies that peers of the previous protocol version
+// are connected and taken to charge the default prices
+func TestHiveLegacyPrices(t *testing.T) {
+	prvkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := NewHiveParams()
+	params.Discovery = false
+	params.AnnouncePrices = true
+	pp := NewHive(params, NewKademlia(PrivateKeyToBzzKey(prvkey), NewKadParams()), nil)
+	defaultPrices := ServicePrices{Retrieve: 300}
+	pp.SetDefaultPrices(defaultPrices)
+
+	s, err := newBzzBaseTester(1, prvkey, legacyDiscoverySpec, pp.RunLegacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	// legacy peers are not sent the prices
+	pp.SetPrices(ServicePrices{Retrieve: 100})
+
+	for attempts := 0; attempts < 1000; attempts++ {
+		var peer *Peer
+		pp.EachConn(nil, 255, func(p *Peer, _ int) bool {
+			peer = p
+			return false
+		})
+		if peer != nil {
+			if got, ok := peer.Prices(); !ok || got != defaultPrices {
+				t.Fatalf("got peer prices %+v, want %+v", got, defaultPrices)
+			}
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("timeout waiting for the legacy peer to connect")
+}
@@ -57,15 +57,45 @@ var DefaultTestNetworkID = rand.Uint64()
 // BzzSpec is the spec of the generic swarm handshake
 var BzzSpec = &protocols.Spec{
 	Name:       "bzz",
-	Version:    14,
+	Version:    15,
 	MaxMsgSize: 10 * 1024 * 1024,
 	Messages: []interface{}{
 		HandshakeMsg{},
+		HandshakePuzzleMsg{},
+	},
+}
+
+// legacyBzzSpec is the spec of the bzz handshake of the previous release,
+// which has no fork id, time, puzzle or network key proof. It is still
+// served so that peers which did not upgrade can connect. They are taken
+// to be on fork 0, and as they can not solve puzzles they are rejected
+// while puzzles are required from unseen peers.
+var legacyBzzSpec = &protocols.Spec{
+	Name:       "bzz",
+	Version:    14,
+	MaxMsgSize: 10 * 1024 * 1024,
+	Messages: []interface{}{
+		legacyHandshakeMsg{},
 	},
 }
 
 // DiscoverySpec is the spec for the bzz discovery subprotocols
 var DiscoverySpec = &protocols.Spec{
+	Name:       "hive",
+	Version:    12,
+	MaxMsgSize: 10 * 1024 * 1024,
+	Messages: []interface{}{
+		peersMsg{},
+		subPeersMsg{},
+		pricesMsg{},
+	},
+}
+
+// legacyDiscoverySpec is the spec of the discovery subprotocol of the
+// previous release, which has no prices message. It is still served so
+// that peers which did not upgrade enter kademlia, they are taken to
+// charge the default prices set on the hive.
+var legacyDiscoverySpec = &protocols.Spec{
 	Name:       "hive",
 	Version:    11,
 	MaxMsgSize: 10 * 1024 * 1024,
@@ -92,6 +122,11 @@ func isLightCapability(c *capability.Capability) bool {
 	return lightCapability.IsSameAs(c)
 }
 
+// IsLightNode returns true if the address advertises the capabilities of a light node
+func IsLightNode(a *BzzAddr) bool {
+	return a.Capabilities != nil && isLightCapability(a.Capabilities.Get(CapabilityID))
+}
+
 // temporary convenience functions for legacy "full node"
 func newFullCapability() *capability.Capability {
 	c := capability.NewCapability(CapabilityID, 16)
@@ -109,18 +144,26 @@ func isFullCapability(c *capability.Capability) bool {
 
 // BzzConfig captures the config params used by the hive
 type BzzConfig struct {
-	Address      *BzzAddr
-	HiveParams   *HiveParams
-	NetworkID    uint64
-	LightNode    bool // temporarily kept as we still only define light/full on operational level
-	BootnodeMode bool
-	SyncEnabled  bool
+	Address        *BzzAddr
+	HiveParams     *HiveParams
+	NetworkID      uint64
+	ForkID         uint64 // bitmap of network forks/features a peer must share to connect
+	LightNode      bool   // temporarily kept as we still only define light/full on operational level
+	BootnodeMode   bool
+	SyncEnabled    bool
+	PeerFilter     *PeerFilter   // allow and deny rules of connecting peers, nil allows all
+	NetworkKey     []byte        // pre-shared key peers must prove to know in the handshake, nil for a public network
+	ClockTolerance time.Duration // clock offset to peers above which the host clock is reported as skewed, DefaultClockTolerance if zero
+
+	HandshakeRateLimit        int   // inbound handshakes per minute above which unseen peers must solve the handshake puzzle, 0 if they never must
+	HandshakePuzzleDifficulty uint8 // leading zero bits of the handshake puzzle hash required from unseen peers, handshake puzzles are disabled if 0
 }
 
 // Bzz is the swarm protocol bundle
 type Bzz struct {
 	*Hive
 	NetworkID     uint64
+	ForkID        uint64
 	localAddr     *BzzAddr
 	mtx           sync.Mutex
 	handshakes    map[enode.ID]*HandshakeMsg
@@ -128,6 +171,14 @@ type Bzz struct {
 	streamerRun   func(*BzzPeer) error
 	retrievalSpec *protocols.Spec
 	retrievalRun  func(*BzzPeer) error
+	reachability  *Reachability
+	peerFilter    *PeerFilter
+	networkKey    []byte
+	clockSkew     *ClockSkew
+	guard         *HandshakeGuard
+
+	legacyStreamerSpec *protocols.Spec      // previous version of the streamer protocol, nil if not served
+	legacyStreamerRun  func(*BzzPeer) error // runs legacyStreamerSpec with peers not supporting streamerSpec
 }
 
 // NewBzz is the swarm protocol constructor
@@ -139,12 +190,21 @@ func NewBzz(config *BzzConfig, kad *Kademlia, store state.Store, streamerSpec, r
 	bzz := &Bzz{
 		Hive:          NewHive(config.HiveParams, kad, store),
 		NetworkID:     config.NetworkID,
+		ForkID:        config.ForkID,
 		localAddr:     config.Address,
 		handshakes:    make(map[enode.ID]*HandshakeMsg),
 		streamerRun:   streamerRun,
 		streamerSpec:  streamerSpec,
 		retrievalRun:  retrievalRun,
 		retrievalSpec: retrievalSpec,
+		reachability:  NewReachability(),
+		peerFilter:    config.PeerFilter,
+		networkKey:    config.NetworkKey,
+		clockSkew:     NewClockSkew(config.ClockTolerance),
+		guard:         NewHandshakeGuard(config.HandshakeRateLimit, config.HandshakePuzzleDifficulty),
+	}
+	if bzz.peerFilter == nil {
+		bzz.peerFilter, _ = NewPeerFilter(nil, nil)
 	}
 
 	if config.BootnodeMode {
@@ -170,11 +230,38 @@ func NewBzz(config *BzzConfig, kad *Kademlia, store state.Store, streamerSpec, r
 	return bzz
 }
 
+// SetLegacyStreamer serves the previous version of the streamer protocol
+// along with the current one, so that peers which did not upgrade keep
+// syncing. It has no effect if syncing is disabled and must be called
+// before the node is started.
+func (b *Bzz) SetLegacyStreamer(spec *protocols.Spec, run func(*BzzPeer) error) {
+	b.legacyStreamerSpec = spec
+	b.legacyStreamerRun = run
+}
+
+// Start starts the hive and the reachability tracker
+func (b *Bzz) Start(server *p2p.Server) error {
+	b.reachability.Start(server)
+	return b.Hive.Start(server)
+}
+
 // Stop Implements node.Service
 func (b *Bzz) Stop() error {
+	b.reachability.Stop()
+	b.Hive.closePeerEvents()
 	return b.Hive.Stop()
 }
 
+// Reachability returns the tracker of the node's underlay reachability
+func (b *Bzz) Reachability() *Reachability {
+	return b.reachability
+}
+
+// ClockSkew returns the estimator of the host clock offset to the peers
+func (b *Bzz) ClockSkew() *ClockSkew {
+	return b.clockSkew
+}
+
 // UpdateLocalAddr updates underlayaddress of the running node
 func (b *Bzz) UpdateLocalAddr(byteaddr []byte) *BzzAddr {
 	b.localAddr = b.localAddr.Update(&BzzAddr{
@@ -204,6 +291,13 @@ func (b *Bzz) Protocols() []p2p.Protocol {
 			Run:      b.runBzz,
 			NodeInfo: b.NodeInfo,
 		},
+		{
+			Name:     legacyBzzSpec.Name,
+			Version:  legacyBzzSpec.Version,
+			Length:   legacyBzzSpec.Length(),
+			Run:      b.runLegacyBzz,
+			NodeInfo: b.NodeInfo,
+		},
 		{
 			Name:     DiscoverySpec.Name,
 			Version:  DiscoverySpec.Version,
@@ -212,6 +306,14 @@ func (b *Bzz) Protocols() []p2p.Protocol {
 			NodeInfo: b.Hive.NodeInfo,
 			PeerInfo: b.Hive.PeerInfo,
 		},
+		{
+			Name:     legacyDiscoverySpec.Name,
+			Version:  legacyDiscoverySpec.Version,
+			Length:   legacyDiscoverySpec.Length(),
+			Run:      b.RunProtocol(legacyDiscoverySpec, b.Hive.RunLegacy),
+			NodeInfo: b.Hive.NodeInfo,
+			PeerInfo: b.Hive.PeerInfo,
+		},
 	}
 	if b.streamerSpec != nil && b.streamerRun != nil {
 		protocol = append(protocol, p2p.Protocol{
@@ -220,6 +322,14 @@ func (b *Bzz) Protocols() []p2p.Protocol {
 			Length:  b.streamerSpec.Length(),
 			Run:     b.RunProtocol(b.streamerSpec, b.streamerRun),
 		})
+		if b.legacyStreamerSpec != nil && b.legacyStreamerRun != nil {
+			protocol = append(protocol, p2p.Protocol{
+				Name:    b.legacyStreamerSpec.Name,
+				Version: b.legacyStreamerSpec.Version,
+				Length:  b.legacyStreamerSpec.Length(),
+				Run:     b.RunProtocol(b.legacyStreamerSpec, b.legacyStreamerRun),
+			})
+		}
 	}
 	if b.retrievalSpec != nil && b.retrievalRun != nil {
 		protocol = append(protocol, p2p.Protocol{
@@ -247,6 +357,26 @@ func (b *Bzz) APIs() []rpc.API {
 			Version:   "4.0",
 			Service:   capability.NewAPI(b.Kademlia.Capabilities),
 		},
+		{
+			Namespace: "bzz",
+			Version:   "4.0",
+			Service:   NewReachabilityAPI(b.reachability),
+		},
+		{
+			Namespace: "bzz",
+			Version:   "4.0",
+			Service:   NewPeerEventsAPI(b.Hive),
+		},
+		{
+			Namespace: "bzz",
+			Version:   "4.0",
+			Service:   NewPeerFilterAPI(b.peerFilter),
+		},
+		{
+			Namespace: "bzz",
+			Version:   "4.0",
+			Service:   NewClockSkewAPI(b.clockSkew),
+		},
 	}
 }
 
@@ -282,43 +412,125 @@ func (b *Bzz) RunProtocol(spec *protocols.Spec, run func(*BzzPeer) error) func(*
 
 		log.Debug("peer created", "addr", handshake.peerAddr.String())
 
-		return run(peer)
+		err := run(peer)
+		if err != nil {
+			b.reportProtocolError(peer, err)
+		}
+		return err
 	}
 }
 
 // performHandshake implements the negotiation of the bzz handshake
-// shared among swarm subprotocols
-func (b *Bzz) performHandshake(p *protocols.Peer, handshake *HandshakeMsg) error {
+// shared among swarm subprotocols, in the version of the given spec
+func (b *Bzz) performHandshake(p *protocols.Peer, handshake *HandshakeMsg, spec *protocols.Spec) error {
 	ctx, cancel := context.WithTimeout(context.Background(), bzzHandshakeTimeout)
 	defer func() {
 		close(handshake.done)
 		cancel()
 	}()
-	rsh, err := p.Handshake(ctx, handshake, b.checkHandshake)
+	if err := b.peerFilter.Check(p.ID(), remoteIP(p.Peer)); err != nil {
+		handshake.err = err
+		return err
+	}
+	now := b.clockSkew.Now()
+	// under a flood of inbound handshakes unseen peers must solve a puzzle,
+	// whose difficulty is stated in the handshake sent to them
+	var difficulty uint8
+	if p.Inbound() {
+		difficulty = b.guard.observeInbound(p.ID(), now)
+	}
+	if difficulty > 0 && spec != BzzSpec {
+		handshake.err = errHandshakePuzzleUnsupported
+		return handshake.err
+	}
+	handshake.Time = uint64(now.UnixNano() / int64(time.Millisecond))
+	handshake.PuzzleDifficulty = difficulty
+	var lhs interface{} = handshake
+	if spec == legacyBzzSpec {
+		lhs = handshake.downgrade()
+	}
+	rsh, err := p.Handshake(ctx, lhs, func(hs interface{}) error {
+		rhs := receivedHandshake(hs)
+		if err := b.checkHandshake(rhs, spec); err != nil {
+			return err
+		}
+		return b.checkNetworkKeyProof(rhs, p.ID())
+	})
 	if err != nil {
 		handshake.err = err
 		return err
 	}
-	handshake.peerAddr = rsh.(*HandshakeMsg).Addr
+	rhs := receivedHandshake(rsh)
+	if err := b.exchangeHandshakePuzzle(ctx, p, handshake, rhs); err != nil {
+		handshake.err = err
+		return err
+	}
+	b.guard.observeSeen(p.ID())
+	handshake.peerAddr = rhs.Addr
+	// peers of legacyBzzSpec do not send their time
+	if rhs.Time > 0 {
+		b.clockSkew.Observe(p.ID(), time.Unix(0, int64(rhs.Time)*int64(time.Millisecond)))
+	}
+	return nil
+}
+
+// exchangeHandshakePuzzle makes the dialing peer solve the puzzle the
+// receiver stated in its handshake, and the receiver check the solution.
+// Handshakes without a puzzle difficulty are not followed by an exchange.
+func (b *Bzz) exchangeHandshakePuzzle(ctx context.Context, p *protocols.Peer, lhs, rhs *HandshakeMsg) error {
+	if p.Inbound() && lhs.PuzzleDifficulty > 0 {
+		_, err := p.Handshake(ctx, &HandshakePuzzleMsg{}, func(msg interface{}) error {
+			solution, ok := msg.(*HandshakePuzzleMsg)
+			if !ok {
+				return errHandshakePuzzleInvalid
+			}
+			return checkHandshakePuzzle(solution.Nonce, lhs.PuzzleDifficulty, b.NetworkID, p.ID(), b.localID(), lhs.Time)
+		})
+		return err
+	}
+	if !p.Inbound() && rhs.PuzzleDifficulty > 0 {
+		nonce, err := solveHandshake(rhs.PuzzleDifficulty, b.NetworkID, b.localID(), p.ID(), rhs.Time)
+		if err != nil {
+			return err
+		}
+		_, err = p.Handshake(ctx, &HandshakePuzzleMsg{Nonce: nonce}, nil)
+		return err
+	}
 	return nil
 }
 
 // runBzz is the p2p protocol run function for the bzz base protocol
 // that negotiates the bzz handshake
 func (b *Bzz) runBzz(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+	return b.runBzzSpec(p, rw, BzzSpec)
+}
+
+// runLegacyBzz negotiates the bzz handshake with peers
+// supporting only the version of the previous release
+func (b *Bzz) runLegacyBzz(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+	return b.runBzzSpec(p, rw, legacyBzzSpec)
+}
+
+func (b *Bzz) runBzzSpec(p *p2p.Peer, rw p2p.MsgReadWriter, spec *protocols.Spec) error {
 	handshake, _ := b.GetOrCreateHandshake(p.ID())
 	if !<-handshake.init {
 		return fmt.Errorf("%08x: bzz already started on peer %08x", b.localAddr.Over()[:4], p.ID().Bytes()[:4])
 	}
 	close(handshake.init)
 	defer b.removeHandshake(p.ID())
-	peer := protocols.NewPeer(p, rw, BzzSpec)
-	err := b.performHandshake(peer, handshake)
+	peer := protocols.NewPeer(p, rw, spec)
+	err := b.performHandshake(peer, handshake, spec)
 	if err != nil {
 		log.Warn(fmt.Sprintf("%08x: handshake failed with remote peer %08x: %v", b.localAddr.Over()[:4], p.ID().Bytes()[:4], err))
+		ev := newPeerEvent(PeerEventHandshakeFailure, b.BaseAddr(), p.ID(), nil, err)
+		ev.UAddr = p.Node().URLv4()
+		b.publishPeerEvent(ev)
 
 		return err
 	}
+	if p.Inbound() {
+		b.reachability.ObserveInbound()
+	}
 	// fail if we get another handshake
 	msg, err := rw.ReadMsg()
 	if err != nil {
@@ -354,13 +566,21 @@ func (p *BzzPeer) ID() enode.ID {
 
 * Version: 8 byte integer version of the protocol
 * NetworkID: 8 byte integer network identifier
+* ForkID: 8 byte bitmap of network forks/features, must match exactly
+* Time: 8 byte Unix time in milliseconds of the sender's clock, used to detect clock skew
 * Addr: the address advertised by the node including underlay and overlay connecctions
 * Capabilities: the capabilities bitvector
+* PuzzleDifficulty: leading zero bits of the puzzle the dialing peer must solve after the handshake, stated by the receiver to unseen peers under a flood of handshakes, 0 if none
+* Proof: the proof of knowing the network key in a private network, empty otherwise
 */
 type HandshakeMsg struct {
-	Version   uint64
-	NetworkID uint64
-	Addr      *BzzAddr
+	Version          uint64
+	NetworkID        uint64
+	ForkID           uint64
+	Time             uint64
+	Addr             *BzzAddr
+	PuzzleDifficulty uint8
+	Proof            [][]byte `rlp:"tail"`
 
 	// peerAddr is the address received in the peer handshake
 	peerAddr *BzzAddr
@@ -370,19 +590,59 @@ type HandshakeMsg struct {
 	err  error
 }
 
+// HandshakePuzzleMsg follows the handshake if the receiver of a dialed handshake
+// required a puzzle. The dialing peer sends the solution, the receiver an empty one.
+type HandshakePuzzleMsg struct {
+	Nonce uint64
+}
+
+// legacyHandshakeMsg is the handshake of the previous release
+type legacyHandshakeMsg struct {
+	Version   uint64
+	NetworkID uint64
+	Addr      *BzzAddr
+}
+
+// downgrade returns the handshake in the version of the previous release
+func (bh *HandshakeMsg) downgrade() *legacyHandshakeMsg {
+	return &legacyHandshakeMsg{
+		Version:   uint64(legacyBzzSpec.Version),
+		NetworkID: bh.NetworkID,
+		Addr:      bh.Addr,
+	}
+}
+
+// receivedHandshake returns the received handshake hs as a handshake
+// of the current version, peers of the previous release are on fork 0
+func receivedHandshake(hs interface{}) *HandshakeMsg {
+	if legacy, ok := hs.(*legacyHandshakeMsg); ok {
+		return &HandshakeMsg{
+			Version:   legacy.Version,
+			NetworkID: legacy.NetworkID,
+			Addr:      legacy.Addr,
+		}
+	}
+	return hs.(*HandshakeMsg)
+}
+
 // String pretty prints the handshake
 func (bh *HandshakeMsg) String() string {
-	return fmt.Sprintf("Handshake: Version: %v, NetworkID: %v, Addr: %v, peerAddr: %v", bh.Version, bh.NetworkID, bh.Addr, bh.peerAddr)
+	return fmt.Sprintf("Handshake: Version: %v, NetworkID: %v, ForkID: %x, Time: %v, Addr: %v, peerAddr: %v", bh.Version, bh.NetworkID, bh.ForkID, bh.Time, bh.Addr, bh.peerAddr)
 }
 
 // Perform initiates the handshake and validates the remote handshake message
-func (b *Bzz) checkHandshake(hs interface{}) error {
-	rhs := hs.(*HandshakeMsg)
+// received in the version of the given spec
+func (b *Bzz) checkHandshake(rhs *HandshakeMsg, spec *protocols.Spec) error {
 	if rhs.NetworkID != b.NetworkID {
 		return fmt.Errorf("network id mismatch %d (!= %d)", rhs.NetworkID, b.NetworkID)
 	}
-	if rhs.Version != uint64(BzzSpec.Version) {
-		return fmt.Errorf("version mismatch %d (!= %d)", rhs.Version, BzzSpec.Version)
+	// the fork id partitions networks sharing the same network id,
+	// e.g. staging and test networks running incompatible features
+	if rhs.ForkID != b.ForkID {
+		return fmt.Errorf("fork id mismatch %x (!= %x)", rhs.ForkID, b.ForkID)
+	}
+	if rhs.Version != uint64(spec.Version) {
+		return fmt.Errorf("version mismatch %d (!= %d)", rhs.Version, spec.Version)
 	}
 	// temporary check for valid capability settings, legacy full/light
 	if !isFullCapability(rhs.Addr.Capabilities.Get(0)) && !isLightCapability(rhs.Addr.Capabilities.Get(0)) {
@@ -408,10 +668,14 @@ func (b *Bzz) GetOrCreateHandshake(peerID enode.ID) (*HandshakeMsg, bool) {
 		handshake = &HandshakeMsg{
 			Version:   uint64(BzzSpec.Version),
 			NetworkID: b.NetworkID,
+			ForkID:    b.ForkID,
 			Addr:      b.localAddr,
 			init:      make(chan bool, 1),
 			done:      make(chan struct{}),
 		}
+		if b.networkKey != nil {
+			handshake.Proof = [][]byte{networkKeyProof(b.networkKey, b.NetworkID, b.localID(), peerID)}
+		}
 		// when handhsake is first created for a remote peer
 		// it is initialised with the init
 		handshake.init <- true
@@ -37,9 +37,12 @@ import (
 )
 
 const (
-	TestProtocolVersion = 14
+	TestProtocolVersion = 15
 )
 
+// testHandshakeTime is the clock of the nodes in handshake tests
+var testHandshakeTime = time.Date(2019, 12, 1, 10, 0, 0, 0, time.UTC)
+
 var TestProtocolNetworkID = DefaultTestNetworkID
 
 func init() {
@@ -82,6 +85,7 @@ func newBzzHandshakeMsg(version uint64, networkId uint64, addr *BzzAddr, lightNo
 	msg := &HandshakeMsg{
 		Version:   version,
 		NetworkID: networkId,
+		Time:      uint64(testHandshakeTime.UnixNano() / int64(time.Millisecond)),
 		Addr:      addr,
 	}
 
@@ -176,6 +180,7 @@ func newBzz(addr *BzzAddr, lightNode bool) *Bzz {
 	}
 	kad := NewKademlia(addr.OAddr, NewKadParams())
 	bzz := NewBzz(config, kad, nil, nil, nil, nil, nil)
+	bzz.clockSkew.now = func() time.Time { return testHandshakeTime }
 	return bzz
 }
 
@@ -241,6 +246,8 @@ func TestBzzHandshakeRLPSerialization(t *testing.T) {
 	msg := &HandshakeMsg{
 		Version:   42,
 		NetworkID: 666,
+		ForkID:    0x5,
+		Time:      1575194400000,
 		Addr:      addr,
 	}
 	b, err := rlp.EncodeToBytes(msg)
@@ -258,6 +265,12 @@ func TestBzzHandshakeRLPSerialization(t *testing.T) {
 	if msg.NetworkID != msgRecovered.NetworkID {
 		t.Fatalf("networkid mismatch, expected %v, got %v", msg.NetworkID, msgRecovered.NetworkID)
 	}
+	if msg.ForkID != msgRecovered.ForkID {
+		t.Fatalf("forkid mismatch, expected %v, got %v", msg.ForkID, msgRecovered.ForkID)
+	}
+	if msg.Time != msgRecovered.Time {
+		t.Fatalf("time mismatch, expected %v, got %v", msg.Time, msgRecovered.Time)
+	}
 	if !msg.Addr.Match(msgRecovered.Addr) {
 		t.Fatalf("bzzaddr mismatch, expected %v, got %v", msg.Addr, msgRecovered.Addr)
 	}
@@ -287,6 +300,33 @@ func TestBzzHandshakeNetworkIDMismatch(t *testing.T) {
 	}
 }
 
+func TestBzzHandshakeForkIDMismatch(t *testing.T) {
+	lightNode := false
+	prvkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := newBzzHandshakeTester(1, prvkey, lightNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	node := s.Nodes[0]
+
+	rhs := newBzzHandshakeMsg(TestProtocolVersion, TestProtocolNetworkID, NewBzzAddrFromEnode(node), false)
+	rhs.ForkID = 0x3
+
+	err = s.testHandshake(
+		correctBzzHandshake(s.addr, lightNode),
+		rhs,
+		&p2ptest.Disconnect{Peer: node.ID(), Error: fmt.Errorf("Handshake error: Message handler error: (msg code 0): fork id mismatch 3 (!= 0)")},
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestBzzHandshakeVersionMismatch(t *testing.T) {
 	lightNode := false
 	prvkey, err := crypto.GenerateKey()
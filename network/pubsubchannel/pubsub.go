@@ -19,6 +19,7 @@ import (
 	"strconv"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/ethersphere/swarm/log"
 )
@@ -31,7 +32,22 @@ type PubSubChannel struct {
 	subsMutex     sync.RWMutex
 	nextId        int
 	quitC         chan struct{}
-	inboxSize     int // size of the inbox channels in subscriptions. Depends on the number of pseudo-simultaneous messages expected to be published.
+	inboxSize     int      // size of the inbox channels in subscriptions. Depends on the number of pseudo-simultaneous messages expected to be published.
+	history       *history // recently published messages replayed to late subscribers, nil if disabled
+}
+
+// history is a bounded buffer of recently published messages. Messages older than maxAge
+// or beyond the last size published ones are dropped.
+type history struct {
+	mtx     sync.Mutex
+	entries []historyEntry
+	size    int
+	maxAge  time.Duration
+}
+
+type historyEntry struct {
+	msg interface{}
+	at  time.Time
 }
 
 // Subscription is created in PubSubChannel using pubSub.Subscribe(). Subscribers can receive using .ReceiveChannel().
@@ -58,11 +74,41 @@ func New(inboxSize int) *PubSubChannel {
 	}
 }
 
+// NewWithHistory creates a new PubSubChannel that remembers up to historySize messages published
+// in the last maxAge, so that subscriptions created with SubscribeWithReplay() receive them first.
+func NewWithHistory(inboxSize, historySize int, maxAge time.Duration) *PubSubChannel {
+	psc := New(inboxSize)
+	if historySize > 0 && maxAge > 0 {
+		psc.history = &history{
+			size:   historySize,
+			maxAge: maxAge,
+		}
+	}
+	return psc
+}
+
 // Subscribe creates a subscription to a channel, each subscriber should keep its own Subscription instance.
 func (psc *PubSubChannel) Subscribe() *Subscription {
+	return psc.subscribe(false)
+}
+
+// SubscribeWithReplay creates a subscription that first receives the recent messages kept in the
+// channel history, in publishing order, and then every message published afterwards. No message is
+// lost or duplicated between the replay and the live ones. Without history it behaves as Subscribe().
+func (psc *PubSubChannel) SubscribeWithReplay() *Subscription {
+	return psc.subscribe(true)
+}
+
+func (psc *PubSubChannel) subscribe(replay bool) *Subscription {
+	// publishers hold the read lock while recording and delivering a message, so the history
+	// snapshot and the registration of the new subscription are atomic with respect to Publish
 	psc.subsMutex.Lock()
 	defer psc.subsMutex.Unlock()
-	newSubscription := newSubscription(strconv.Itoa(psc.nextId), psc, psc.inboxSize)
+	var msgs []interface{}
+	if replay && psc.history != nil {
+		msgs = psc.history.recent(time.Now())
+	}
+	newSubscription := newSubscription(strconv.Itoa(psc.nextId), psc, psc.inboxSize, msgs)
 	psc.nextId++
 	psc.subscriptions = append(psc.subscriptions, newSubscription)
 
@@ -88,11 +134,48 @@ func (psc *PubSubChannel) removeSub(s *Subscription) {
 func (psc *PubSubChannel) Publish(msg interface{}) {
 	psc.subsMutex.RLock()
 	defer psc.subsMutex.RUnlock()
+	if psc.history != nil {
+		psc.history.add(msg, time.Now())
+	}
 	for _, sub := range psc.subscriptions {
 		psc.publishToSub(sub, msg)
 	}
 }
 
+// add records a published message, evicting the oldest ones over the size limit.
+func (h *history) add(msg interface{}, now time.Time) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.entries = append(h.entries, historyEntry{msg: msg, at: now})
+	h.prune(now)
+}
+
+// recent returns the messages in the history that have not expired, oldest first.
+func (h *history) recent(now time.Time) []interface{} {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.prune(now)
+	msgs := make([]interface{}, len(h.entries))
+	for i, e := range h.entries {
+		msgs[i] = e.msg
+	}
+	return msgs
+}
+
+// prune drops expired entries and the oldest ones exceeding the size. Must be called with the lock held.
+func (h *history) prune(now time.Time) {
+	i := 0
+	if len(h.entries) > h.size {
+		i = len(h.entries) - h.size
+	}
+	for i < len(h.entries) && now.Sub(h.entries[i].at) > h.maxAge {
+		i++
+	}
+	if i > 0 {
+		h.entries = append(h.entries[:0:0], h.entries[i:]...)
+	}
+}
+
 // publishToSub will block on the subscription inbox if there are more than inboxSize messages accumulated
 func (psc *PubSubChannel) publishToSub(sub *Subscription, msg interface{}) {
 	atomic.AddInt64(sub.pending, 1)
@@ -156,7 +239,7 @@ func (sub *Subscription) Pending() int64 {
 	return *sub.pending
 }
 
-func newSubscription(id string, psc *PubSubChannel, inboxSize int) *Subscription {
+func newSubscription(id string, psc *PubSubChannel, inboxSize int, replay []interface{}) *Subscription {
 	var pending int64
 	subscription := &Subscription{
 		closed:    false,
@@ -169,8 +252,21 @@ func newSubscription(id string, psc *PubSubChannel, inboxSize int) *Subscription
 		msgCount:  0,
 		pending:   &pending,
 	}
-	// publishing goroutine. It closes the signal channel whenever it receives the quitC signal
+	// publishing goroutine. It closes the signal channel whenever it receives the quitC signal.
+	// Replayed messages are delivered before anything in the inbox, which only holds messages
+	// published after the subscription was created.
 	go func(sub *Subscription) {
+		for _, msg := range replay {
+			select {
+			case <-psc.quitC:
+				return
+			case <-sub.quitC:
+				close(sub.signal)
+				return
+			case sub.signal <- msg:
+				sub.msgCount++
+			}
+		}
 		for {
 			select {
 			case <-sub.quitC:
@@ -211,3 +211,62 @@ func TestMessagesInOrder(t *testing.T) {
 	}
 
 }
+
+// TestSubscribeWithReplay checks that a late subscriber receives the messages kept in the history followed by the
+// live ones, in order and without duplicates, while a plain subscriber only receives the live ones.
+func TestSubscribeWithReplay(t *testing.T) {
+	ps := pubsubchannel.NewWithHistory(10, 5, time.Minute)
+	defer ps.Close()
+
+	for i := 0; i < 8; i++ {
+		ps.Publish(i)
+	}
+	replayed := ps.SubscribeWithReplay()
+	plain := ps.Subscribe()
+	for i := 8; i < 10; i++ {
+		ps.Publish(i)
+	}
+
+	// only the last 5 messages fit in the history
+	expectMessages(t, replayed, []int{3, 4, 5, 6, 7, 8, 9})
+	expectMessages(t, plain, []int{8, 9})
+}
+
+// TestSubscribeWithReplayExpiry checks that messages older than the history age are not replayed.
+func TestSubscribeWithReplayExpiry(t *testing.T) {
+	ps := pubsubchannel.NewWithHistory(10, 5, 50*time.Millisecond)
+	defer ps.Close()
+
+	ps.Publish(0)
+	time.Sleep(100 * time.Millisecond)
+	ps.Publish(1)
+
+	expectMessages(t, ps.SubscribeWithReplay(), []int{1})
+
+	// without history a replaying subscription only gets live messages
+	noHistory := pubsubchannel.New(10)
+	defer noHistory.Close()
+	noHistory.Publish(0)
+	s := noHistory.SubscribeWithReplay()
+	noHistory.Publish(1)
+	expectMessages(t, s, []int{1})
+}
+
+func expectMessages(t *testing.T, s *pubsubchannel.Subscription, want []int) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case msg := <-s.ReceiveChannel():
+			if msg.(int) != w {
+				t.Fatalf("subscription %v: got message %v, want %v", s.ID(), msg, w)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("subscription %v: timeout waiting for message %v", s.ID(), w)
+		}
+	}
+	select {
+	case msg := <-s.ReceiveChannel():
+		t.Fatalf("subscription %v: got unexpected message %v", s.ID(), msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
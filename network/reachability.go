@@ -0,0 +1,194 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/network/pubsubchannel"
+)
+
+// ReachabilityInfo is a snapshot of how the node can be reached by other nodes
+type ReachabilityInfo struct {
+	ListenAddr      string    // actual local listening address of the underlay
+	Underlays       []string  // externally advertised underlay addresses
+	NAT             string    // description of the configured NAT port mapper, empty if none
+	ExternalIP      string    // external IP as reported by the NAT port mapper
+	NATError        string    // error returned by the NAT port mapper, if any
+	InboundObserved bool      // whether any inbound connection completed the bzz handshake
+	InboundCount    uint64    // number of inbound connections that completed the bzz handshake
+	LastInbound     time.Time // time of the last inbound connection
+}
+
+// Reachability keeps track of the underlay reachability of the node
+// and notifies subscribers whenever the reachability status changes
+type Reachability struct {
+	mtx       sync.RWMutex
+	info      ReachabilityInfo
+	pubSub    *pubsubchannel.PubSubChannel
+	quit      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewReachability creates a new reachability tracker
+func NewReachability() *Reachability {
+	return &Reachability{
+		pubSub: pubsubchannel.New(10),
+		quit:   make(chan struct{}),
+	}
+}
+
+// Start records the underlay addresses of the running server
+// and asynchronously queries the NAT port mapper for the external address
+func (r *Reachability) Start(server *p2p.Server) {
+	r.mtx.Lock()
+	r.info.ListenAddr = server.ListenAddr
+	r.info.Underlays = []string{server.Self().URLv4()}
+	nat := server.NAT
+	if nat != nil {
+		r.info.NAT = nat.String()
+	}
+	r.mtx.Unlock()
+	r.publish()
+
+	if nat == nil {
+		return
+	}
+	// ExternalIP may block for a long time while discovering UPnP/NAT-PMP gateways
+	go func() {
+		ip, err := nat.ExternalIP()
+		select {
+		case <-r.quit:
+			return
+		default:
+		}
+		r.mtx.Lock()
+		if err != nil {
+			r.info.NATError = err.Error()
+			log.Warn("NAT port mapper external ip lookup failed", "nat", r.info.NAT, "err", err)
+		} else {
+			r.info.ExternalIP = ip.String()
+			r.info.NATError = ""
+		}
+		r.mtx.Unlock()
+		r.publish()
+	}()
+}
+
+// Stop closes all reachability subscriptions
+func (r *Reachability) Stop() {
+	r.closeOnce.Do(func() {
+		close(r.quit)
+		r.pubSub.Close()
+	})
+}
+
+// ObserveInbound registers an inbound connection that completed the bzz handshake
+// subscribers are only notified the first time an inbound connection is observed
+func (r *Reachability) ObserveInbound() {
+	r.mtx.Lock()
+	first := !r.info.InboundObserved
+	r.info.InboundObserved = true
+	r.info.InboundCount++
+	r.info.LastInbound = time.Now()
+	r.mtx.Unlock()
+	if first {
+		log.Info("inbound connection observed, node is reachable")
+		r.publish()
+	}
+}
+
+// Info returns a snapshot of the current reachability status
+func (r *Reachability) Info() ReachabilityInfo {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	info := r.info
+	info.Underlays = append([]string(nil), r.info.Underlays...)
+	return info
+}
+
+// Subscribe returns a subscription receiving a ReachabilityInfo on each status change
+func (r *Reachability) Subscribe() *pubsubchannel.Subscription {
+	return r.pubSub.Subscribe()
+}
+
+func (r *Reachability) publish() {
+	select {
+	case <-r.quit:
+		return
+	default:
+	}
+	go r.pubSub.Publish(r.Info())
+}
+
+// ReachabilityAPI exposes the reachability status of the node over RPC
+type ReachabilityAPI struct {
+	reachability *Reachability
+}
+
+// NewReachabilityAPI creates a new ReachabilityAPI
+func NewReachabilityAPI(r *Reachability) *ReachabilityAPI {
+	return &ReachabilityAPI{reachability: r}
+}
+
+// Reachability returns the current reachability status
+func (a *ReachabilityAPI) Reachability() ReachabilityInfo {
+	return a.reachability.Info()
+}
+
+// ReachabilityChanges creates a subscription notified on every reachability status change
+func (a *ReachabilityAPI) ReachabilityChanges(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, fmt.Errorf("Subscribe not supported")
+	}
+
+	rpcSub := notifier.CreateSubscription()
+	sub := a.reachability.Subscribe()
+
+	go func() {
+		defer func() {
+			if !sub.IsClosed() {
+				sub.Unsubscribe()
+			}
+		}()
+		for {
+			select {
+			case info, ok := <-sub.ReceiveChannel():
+				if !ok {
+					return
+				}
+				if err := notifier.Notify(rpcSub.ID, info); err != nil {
+					log.Warn("reachability notification failed", "sub", rpcSub.ID, "err", err)
+				}
+			case err := <-rpcSub.Err():
+				log.Debug("reachability subscription error", "sub", rpcSub.ID, "err", err)
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"testing"
+	"time"
+)
+
+// TestReachabilityObserveInbound checks that the first inbound connection
+// is reported to subscribers and all of them are counted
+func TestReachabilityObserveInbound(t *testing.T) {
+	r := NewReachability()
+	defer r.Stop()
+
+	sub := r.Subscribe()
+	defer sub.Unsubscribe()
+
+	if r.Info().InboundObserved {
+		t.Fatal("expected no inbound connection observed")
+	}
+
+	r.ObserveInbound()
+	r.ObserveInbound()
+
+	select {
+	case msg := <-sub.ReceiveChannel():
+		info := msg.(ReachabilityInfo)
+		if !info.InboundObserved {
+			t.Fatal("expected inbound connection observed in notification")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for reachability notification")
+	}
+
+	info := r.Info()
+	if !info.InboundObserved {
+		t.Fatal("expected inbound connection observed")
+	}
+	if info.InboundCount != 2 {
+		t.Fatalf("expected inbound count 2, got %d", info.InboundCount)
+	}
+}
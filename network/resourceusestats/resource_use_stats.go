@@ -19,14 +19,32 @@ import (
 	"sort"
 	"strconv"
 	"sync"
+	"time"
 )
 
+// MaxUseSnapshots is the number of rotated interval snapshots kept.
+const MaxUseSnapshots = 60
+
 // ResourceUseStats can be used to count uses of resources. A Resource is anything with a Key()
+// Besides the total uses, which never decrease while a resource is present, uses are also counted
+// per interval. An interval is closed with Rotate() and kept as a snapshot.
 type ResourceUseStats struct {
-	resourceUses map[string]int
-	waiting      map[string]chan struct{}
-	lock         sync.RWMutex
-	quitC        <-chan struct{}
+	resourceUses  map[string]int
+	waiting       map[string]chan struct{}
+	lock          sync.RWMutex
+	quitC         <-chan struct{}
+	intervalUses  map[string]int   // uses per key since intervalStart
+	intervalStart time.Time        // start of the current interval
+	snapshots     []UseSnapshot    // last rotated intervals, oldest first
+	now           func() time.Time // source of time for the intervals
+}
+
+// UseSnapshot holds the uses of every resource counted in an interval and the resulting rate in uses per minute.
+type UseSnapshot struct {
+	Start time.Time          `json:"start"`
+	End   time.Time          `json:"end"`
+	Uses  map[string]int     `json:"uses"`
+	Rates map[string]float64 `json:"rates"`
 }
 
 // Resource represents anything with a Key that can be accounted with some stat.
@@ -42,9 +60,12 @@ type ResourceCount struct {
 
 func NewResourceUseStats(quitC <-chan struct{}) *ResourceUseStats {
 	return &ResourceUseStats{
-		resourceUses: make(map[string]int),
-		waiting:      make(map[string]chan struct{}),
-		quitC:        quitC,
+		resourceUses:  make(map[string]int),
+		waiting:       make(map[string]chan struct{}),
+		quitC:         quitC,
+		intervalUses:  make(map[string]int),
+		intervalStart: time.Now(),
+		now:           time.Now,
 	}
 }
 
@@ -109,9 +130,60 @@ func (lb *ResourceUseStats) AddUse(resource Resource) int {
 	key := resource.Key()
 	prevCount := lb.resourceUses[key]
 	lb.resourceUses[key] = prevCount + 1
+	lb.intervalUses[key]++
 	return lb.resourceUses[key]
 }
 
+// Snapshot returns the uses counted in the current interval up to now.
+func (lb *ResourceUseStats) Snapshot() UseSnapshot {
+	lb.lock.RLock()
+	defer lb.lock.RUnlock()
+	return lb.snapshot(lb.now())
+}
+
+// Rotate closes the current interval, keeping its snapshot, and starts a new one.
+// Total uses are not affected. The snapshot of the closed interval is returned.
+func (lb *ResourceUseStats) Rotate() UseSnapshot {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	now := lb.now()
+	snapshot := lb.snapshot(now)
+	lb.snapshots = append(lb.snapshots, snapshot)
+	if len(lb.snapshots) > MaxUseSnapshots {
+		lb.snapshots = lb.snapshots[len(lb.snapshots)-MaxUseSnapshots:]
+	}
+	lb.intervalUses = make(map[string]int)
+	lb.intervalStart = now
+	return snapshot
+}
+
+// Snapshots returns the snapshots of the last rotated intervals, oldest first.
+func (lb *ResourceUseStats) Snapshots() []UseSnapshot {
+	lb.lock.RLock()
+	defer lb.lock.RUnlock()
+	snapshots := make([]UseSnapshot, len(lb.snapshots))
+	copy(snapshots, lb.snapshots)
+	return snapshots
+}
+
+// snapshot must be called with the lock held.
+func (lb *ResourceUseStats) snapshot(now time.Time) UseSnapshot {
+	snapshot := UseSnapshot{
+		Start: lb.intervalStart,
+		End:   now,
+		Uses:  make(map[string]int, len(lb.intervalUses)),
+		Rates: make(map[string]float64, len(lb.intervalUses)),
+	}
+	minutes := now.Sub(lb.intervalStart).Minutes()
+	for key, uses := range lb.intervalUses {
+		snapshot.Uses[key] = uses
+		if minutes > 0 {
+			snapshot.Rates[key] = float64(uses) / minutes
+		}
+	}
+	return snapshot
+}
+
 // WaitKey blocks until some key is added to the load balancer stats.
 // As peer resource initialization is asynchronous we need a way to know that the initial uses has been initialized.
 func (lb *ResourceUseStats) WaitKey(key string) {
@@ -0,0 +1,79 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package resourceusestats
+
+import (
+	"testing"
+	"time"
+)
+
+type testResource string
+
+func (r testResource) Key() string   { return string(r) }
+func (r testResource) Label() string { return string(r) }
+
+// TestRotate checks that uses are counted per interval with their rate per minute, that rotating
+// keeps the closed interval as a snapshot and that total uses are not reset.
+func TestRotate(t *testing.T) {
+	quitC := make(chan struct{})
+	defer close(quitC)
+	stats := NewResourceUseStats(quitC)
+	now := time.Unix(0, 0)
+	stats.now = func() time.Time { return now }
+	stats.intervalStart = now
+
+	a, b := testResource("a"), testResource("b")
+	for i := 0; i < 4; i++ {
+		stats.AddUse(a)
+	}
+	stats.AddUse(b)
+	now = now.Add(2 * time.Minute)
+
+	current := stats.Snapshot()
+	if current.Uses["a"] != 4 || current.Uses["b"] != 1 {
+		t.Fatalf("unexpected interval uses %v", current.Uses)
+	}
+	if current.Rates["a"] != 2 || current.Rates["b"] != 0.5 {
+		t.Fatalf("unexpected interval rates %v", current.Rates)
+	}
+
+	closed := stats.Rotate()
+	if closed.Uses["a"] != 4 || !closed.End.Equal(now) {
+		t.Fatalf("unexpected rotated snapshot %v", closed)
+	}
+	if len(stats.Snapshot().Uses) != 0 {
+		t.Fatalf("expected no uses in new interval, got %v", stats.Snapshot().Uses)
+	}
+	if stats.GetUses(a) != 4 {
+		t.Fatalf("expected total uses not to be reset, got %v", stats.GetUses(a))
+	}
+
+	stats.AddUse(b)
+	now = now.Add(time.Minute)
+	stats.Rotate()
+	snapshots := stats.Snapshots()
+	if len(snapshots) != 2 || snapshots[1].Uses["b"] != 1 || snapshots[1].Rates["b"] != 1 {
+		t.Fatalf("unexpected snapshots %v", snapshots)
+	}
+
+	for i := 0; i < MaxUseSnapshots; i++ {
+		stats.Rotate()
+	}
+	if len(stats.Snapshots()) != MaxUseSnapshots {
+		t.Fatalf("expected %v snapshots kept, got %v", MaxUseSnapshots, len(stats.Snapshots()))
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/chunk"
+)
+
+var corruptChunkDelivery = metrics.NewRegisteredCounter("network.retrieve.corrupt_delivery", nil)
+
+// PeerCorruption counts the chunks delivered by a peer
+// that failed validation
+type PeerCorruption struct {
+	Peer      string    `json:"peer"`      // hex encoded overlay address of the peer
+	Count     uint64    `json:"count"`     // number of invalid chunks delivered
+	LastChunk string    `json:"lastChunk"` // address of the last invalid chunk delivered
+	LastTime  time.Time `json:"lastTime"`  // time of the last invalid chunk delivery
+}
+
+// corruptionStats keeps the invalid chunk deliveries of peers,
+// including peers that are no longer connected
+type corruptionStats struct {
+	mtx   sync.Mutex
+	peers map[string]*PeerCorruption
+}
+
+func newCorruptionStats() *corruptionStats {
+	return &corruptionStats{
+		peers: make(map[string]*PeerCorruption),
+	}
+}
+
+// add records an invalid chunk delivered by the peer with the overlay address
+func (c *corruptionStats) add(peer []byte, addr chunk.Address, now time.Time) {
+	corruptChunkDelivery.Inc(1)
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	key := hex.EncodeToString(peer)
+	pc, ok := c.peers[key]
+	if !ok {
+		pc = &PeerCorruption{Peer: key}
+		c.peers[key] = pc
+	}
+	pc.Count++
+	pc.LastChunk = addr.Hex()
+	pc.LastTime = now
+}
+
+// list returns the corruption counters of all peers, most corrupt first
+func (c *corruptionStats) list() []PeerCorruption {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	list := make([]PeerCorruption, 0, len(c.peers))
+	for _, pc := range c.peers {
+		list = append(list, *pc)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Peer < list[j].Peer
+	})
+	return list
+}
+
+// API exposes statistics of the retrieval protocol over RPC
+type API struct {
+	retrieval *Retrieval
+}
+
+// NewAPI creates a new API for the retrieval protocol
+func NewAPI(r *Retrieval) *API {
+	return &API{retrieval: r}
+}
+
+// CorruptionStats returns the number of chunks that failed validation
+// per peer that delivered them, most corrupt peers first
+func (a *API) CorruptionStats() []PeerCorruption {
+	return a.retrieval.corruption.list()
+}
+
+// EnableCorruptionRetry makes the node request a chunk from another peer
+// as soon as a peer delivers it with invalid content, instead of waiting
+// for the search timeout. It must be called before the service is started.
+func (r *Retrieval) EnableCorruptionRetry() {
+	r.retryCorrupt = true
+}
@@ -0,0 +1,186 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/network"
+	p2ptest "github.com/ethersphere/swarm/p2p/testing"
+	"github.com/ethersphere/swarm/storage"
+	"github.com/ethersphere/swarm/storage/localstore"
+)
+
+// TestCorruptChunkDelivery tests that a peer delivering a chunk that fails
+// validation is counted in the corruption stats and disconnected, and that
+// with retry enabled the chunk is requested from the next peer right away
+func TestCorruptChunkDelivery(t *testing.T) {
+	prvkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "localstore-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	bzzAddr := network.PrivateKeyToBzzKey(prvkey)
+	localStore, err := localstore.New(dir, bzzAddr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns := storage.NewNetStore(chunk.NewValidatorStore(
+		localStore,
+		storage.NewContentAddressValidator(storage.MakeHashFunc(storage.DefaultHash)),
+	), network.NewBzzAddr(bzzAddr, nil))
+	defer ns.Close()
+
+	kad := network.NewKademlia(bzzAddr, network.NewKadParams())
+	tester, r, teardown, err := newRetrievalTester(t, prvkey, ns, kad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+	r.EnableCorruptionRetry()
+
+	node := tester.Nodes[0]
+
+	// the first request goes to the tester node, the second one signals the retry
+	var mu sync.Mutex
+	var requests int
+	retried := make(chan struct{})
+	ns.RemoteGet = func(ctx context.Context, req *storage.Request, localID enode.ID) (*enode.ID, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if requests == 2 {
+			close(retried)
+		}
+		if requests > 1 {
+			return nil, errors.New("no more peers")
+		}
+		id := node.ID()
+		return &id, nil
+	}
+	// make sure that the retry is not caused by the search timeout
+	ns.SearchTimeout = func(enode.ID) time.Duration {
+		return time.Minute
+	}
+
+	// this exchange is needed so that the protocol peer gets created
+	err = tester.TestExchanges(p2ptest.Exchange{
+		Label: "A bogus retrieve request",
+		Triggers: []p2ptest.Trigger{
+			{
+				Code: 1,
+				Msg: &RetrieveRequest{
+					Ruid: 9876,
+					Addr: []byte{5, 4, 3, 2},
+				},
+				Peer: node.ID(),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p *Peer
+	for i := 0; i < 1000; i++ {
+		if p = r.getPeer(node.ID()); p != nil {
+			break
+		}
+		time.Sleep(1 * time.Millisecond)
+	}
+	if p == nil {
+		t.Fatal("peer not registered")
+	}
+
+	ch := storage.GenerateRandomChunk(chunk.DefaultSize)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	go ns.Get(ctx, chunk.ModeGetRequest, storage.NewRequest(ch.Address()))
+
+	for i := 0; i < 1000; i++ {
+		if has, _ := ns.Has(ctx, ch.Address()); has {
+			t.Fatal("chunk unexpectedly stored")
+		}
+		if _, loaded, _ := ns.GetOrCreateFetcher(ctx, ch.Address(), "test"); loaded {
+			break
+		}
+		time.Sleep(1 * time.Millisecond)
+	}
+	p.addRetrieval(1234, ch.Address())
+
+	corrupt := make([]byte, len(ch.Data()))
+	copy(corrupt, ch.Data())
+	corrupt[len(corrupt)-1]++
+
+	err = tester.TestExchanges(p2ptest.Exchange{
+		Label: "Chunk delivery with invalid data",
+		Triggers: []p2ptest.Trigger{
+			{
+				Code: 0,
+				Msg: &ChunkDelivery{
+					Ruid:  1234,
+					Addr:  ch.Address(),
+					SData: corrupt,
+				},
+				Peer: node.ID(),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tester.TestDisconnected(&p2ptest.Disconnect{Peer: node.ID(), Error: errors.New("subprotocol error")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-retried:
+	case <-time.After(5 * time.Second):
+		t.Fatal("chunk not requested from the next peer")
+	}
+
+	stats := NewAPI(r).CorruptionStats()
+	if len(stats) != 1 {
+		t.Fatalf("got %v peers in corruption stats, want 1", len(stats))
+	}
+	if want := hex.EncodeToString(p.Over()); stats[0].Peer != want {
+		t.Errorf("got peer %v, want %v", stats[0].Peer, want)
+	}
+	if stats[0].Count != 1 {
+		t.Errorf("got count %v, want 1", stats[0].Count)
+	}
+	if stats[0].LastChunk != ch.Address().Hex() {
+		t.Errorf("got last chunk %v, want %v", stats[0].LastChunk, ch.Address().Hex())
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// seenRequestsCapacity is the maximal number of request ids kept to detect forwarding loops
+const seenRequestsCapacity = 10000
+
+var loopedRequestCount = metrics.NewRegisteredCounter("network.retrieve.looped_requests", nil)
+
+// seenRequests keeps the ids of recently handled retrieve requests. Forwarding nodes
+// keep the id of a request, so a request arriving again with a known id went in a loop,
+// caused by nodes with inconsistent views of kademlia forwarding it to each other.
+type seenRequests struct {
+	mtx      sync.Mutex
+	ttl      time.Duration        // how long an id is kept
+	capacity int                  // maximal number of ids, the oldest are dropped first
+	times    map[uint64]time.Time // time an id was seen
+	ids      []uint64             // ids in the order they were seen
+}
+
+func newSeenRequests(capacity int, ttl time.Duration) *seenRequests {
+	return &seenRequests{
+		ttl:      ttl,
+		capacity: capacity,
+		times:    make(map[uint64]time.Time),
+	}
+}
+
+// seen records the request id and reports whether it was already seen
+func (s *seenRequests) seen(id uint64, now time.Time) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for len(s.ids) > 0 && now.Sub(s.times[s.ids[0]]) > s.ttl {
+		s.drop()
+	}
+	if _, ok := s.times[id]; ok {
+		return true
+	}
+	if len(s.ids) >= s.capacity {
+		s.drop()
+	}
+	s.times[id] = now
+	s.ids = append(s.ids, id)
+	return false
+}
+
+// drop removes the oldest id
+func (s *seenRequests) drop() {
+	delete(s.times, s.ids[0])
+	s.ids = s.ids[1:]
+}
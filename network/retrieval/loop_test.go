@@ -0,0 +1,223 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/network"
+	p2ptest "github.com/ethersphere/swarm/p2p/testing"
+	"github.com/ethersphere/swarm/storage"
+)
+
+// TestSeenRequests tests that request ids are remembered
+// until they expire or the capacity is exceeded
+func TestSeenRequests(t *testing.T) {
+	s := newSeenRequests(3, time.Minute)
+	now := time.Now()
+
+	for id := uint64(1); id <= 3; id++ {
+		if s.seen(id, now) {
+			t.Fatalf("id %v seen before it was recorded", id)
+		}
+	}
+	if !s.seen(2, now) {
+		t.Fatal("expected id 2 to be seen")
+	}
+	// exceeding the capacity drops the oldest id
+	if s.seen(4, now) {
+		t.Fatal("id 4 seen before it was recorded")
+	}
+	if s.seen(1, now) {
+		t.Fatal("expected id 1 to be dropped")
+	}
+
+	// expired ids are dropped
+	now = now.Add(2 * time.Minute)
+	if s.seen(3, now) {
+		t.Fatal("expected id 3 to expire")
+	}
+	if len(s.ids) != 1 || len(s.times) != 1 {
+		t.Fatalf("expected 1 id, got %v %v", len(s.ids), len(s.times))
+	}
+}
+
+// TestForwardingLoop tests that a retrieve request keeps its id when it is forwarded
+// and that a request arriving again with a seen id is not forwarded
+func TestForwardingLoop(t *testing.T) {
+	pk, ns, cleanup := newTestNetstore(t)
+	defer cleanup()
+	bzzAddr := network.PrivateKeyToBzzKey(pk)
+	kad := network.NewKademlia(bzzAddr, network.NewKadParams())
+
+	tester, _, teardown, err := newRetrievalTester(t, pk, ns, kad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	forwarded := make(chan *storage.Request, 10)
+	ns.RemoteGet = func(ctx context.Context, req *storage.Request, localID enode.ID) (*enode.ID, error) {
+		forwarded <- req
+		return nil, errors.New("no peer")
+	}
+	node := tester.Nodes[0]
+
+	request := func(addr []byte) {
+		t.Helper()
+		err := tester.TestExchanges(p2ptest.Exchange{
+			Label: "retrieve request",
+			Triggers: []p2ptest.Trigger{
+				{
+					Code: 1,
+					Msg: &RetrieveRequest{
+						Ruid: 1,
+						Addr: addr,
+						ID:   42,
+					},
+					Peer: node.ID(),
+				},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	request([]byte{1, 2, 3, 4})
+	select {
+	case req := <-forwarded:
+		if req.ID != 42 {
+			t.Fatalf("expected forwarded request id 42, got %v", req.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request not forwarded")
+	}
+
+	// the same request for another chunk is not coalesced by the netstore,
+	// so it would be forwarded if the loop was not detected
+	request([]byte{4, 3, 2, 1})
+	select {
+	case req := <-forwarded:
+		t.Fatalf("looped request forwarded for chunk %v", req.Addr)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	// a looped request for a chunk in the local store is served
+	ch := storage.GenerateRandomChunk(chunk.DefaultSize)
+	if _, err := ns.Store.Put(context.Background(), chunk.ModePutUpload, ch); err != nil {
+		t.Fatal(err)
+	}
+	err = tester.TestExchanges(p2ptest.Exchange{
+		Label: "looped request for a local chunk",
+		Triggers: []p2ptest.Trigger{
+			{
+				Code: 1,
+				Msg: &RetrieveRequest{
+					Ruid: 2,
+					Addr: ch.Address(),
+					ID:   42,
+				},
+				Peer: node.ID(),
+			},
+		},
+		Expects: []p2ptest.Expect{
+			{
+				Code: 0,
+				Msg: &ChunkDelivery{
+					Ruid:  2,
+					Addr:  ch.Address(),
+					SData: ch.Data(),
+				},
+				Peer: node.ID(),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestLegacyRetrieval tests that peers of the previous protocol version
+// are served and sent retrieve requests without ids
+func TestLegacyRetrieval(t *testing.T) {
+	pk, ns, cleanup := newTestNetstore(t)
+	defer cleanup()
+	bzzAddr := network.PrivateKeyToBzzKey(pk)
+	kad := network.NewKademlia(bzzAddr, network.NewKadParams())
+	r := New(kad, ns, network.NewBzzAddr(kad.BaseAddr(), nil), nil)
+	tester := p2ptest.NewProtocolTester(pk, 1, r.runLegacyProtocol)
+	defer tester.Stop()
+	node := tester.Nodes[0]
+
+	ch := storage.GenerateRandomChunk(chunk.DefaultSize)
+	if _, err := ns.Store.Put(context.Background(), chunk.ModePutUpload, ch); err != nil {
+		t.Fatal(err)
+	}
+	err := tester.TestExchanges(p2ptest.Exchange{
+		Label: "legacy retrieve request",
+		Triggers: []p2ptest.Trigger{
+			{
+				Code: 1,
+				Msg:  &legacyRetrieveRequest{Ruid: 1, Addr: ch.Address()},
+				Peer: node.ID(),
+			},
+		},
+		Expects: []p2ptest.Expect{
+			{
+				Code: 0,
+				Msg: &ChunkDelivery{
+					Ruid:  1,
+					Addr:  ch.Address(),
+					SData: ch.Data(),
+				},
+				Peer: node.ID(),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var p *Peer
+	for i := 0; i < 100 && p == nil; i++ {
+		p = r.getPeer(node.ID())
+		time.Sleep(10 * time.Millisecond)
+	}
+	if p == nil || !p.legacy {
+		t.Fatal("expected legacy peer to be registered")
+	}
+	go p.sendRetrieveRequest(context.Background(), &RetrieveRequest{Ruid: 2, Addr: ch.Address(), ID: 42})
+	err = tester.TestExchanges(p2ptest.Exchange{
+		Label: "retrieve request to a legacy peer",
+		Expects: []p2ptest.Expect{
+			{
+				Code: 1,
+				Msg:  &legacyRetrieveRequest{Ruid: 2, Addr: ch.Address()},
+				Peer: node.ID(),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/network/timeouts"
+	"github.com/ethersphere/swarm/storage"
+)
+
+var (
+	obfuscatedRequestCount = metrics.NewRegisteredCounter("network.retrieve.obfuscated_requests", nil)
+	coverRequestCount      = metrics.NewRegisteredCounter("network.retrieve.cover_requests", nil)
+)
+
+// ObfuscationParams configures the obfuscation of the origin of retrieve
+// requests of light clients.
+//
+// Retrieve requests carry no originator, but a forwarding node can still
+// tell the requester of a chunk from the timing of requests and from
+// routing decisions which depend on the proximity of the peer that sent
+// the request. With obfuscation, requests of light clients are forwarded
+// as if the node requested the chunks itself, after a random delay, and
+// cover requests for random chunks are mixed in with them.
+//
+// The tradeoffs are:
+//   - latency: every request of a light client is delayed by up to MaxDelay,
+//     on top of the retrieval round trip time
+//   - accounting: cover requests are priced as any other retrieve request,
+//     so with swap the node pays for one request every CoverInterval on
+//     average and gets no chunk for it; they are not charged to the light
+//     clients. Downstream nodes also hold a fetcher for every cover request
+//     until it times out.
+type ObfuscationParams struct {
+	MaxDelay      time.Duration // maximum random delay before a request of a light client is forwarded
+	CoverInterval time.Duration // mean interval between cover requests, 0 disables cover requests
+}
+
+// NewObfuscationParams returns the default obfuscation parameters,
+// which delay requests of light clients but send no cover requests.
+func NewObfuscationParams() *ObfuscationParams {
+	return &ObfuscationParams{
+		MaxDelay: 500 * time.Millisecond,
+	}
+}
+
+// obfuscation holds the state of the retrieve request origin obfuscation
+type obfuscation struct {
+	params *ObfuscationParams
+	mtx    sync.Mutex // protects rand, which is not safe for concurrent use
+	rand   *rand.Rand
+}
+
+// EnableObfuscation enables the obfuscation of the origin of retrieve
+// requests of light clients. It must be called before the service is started.
+func (r *Retrieval) EnableObfuscation(params *ObfuscationParams) {
+	r.obfuscation = &obfuscation{
+		params: params,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// obfuscateRequest prepares the request received from the peer to be
+// forwarded on behalf of the node, if the peer is a light client and
+// obfuscation is enabled
+func (r *Retrieval) obfuscateRequest(p *Peer, req *storage.Request) {
+	if r.obfuscation == nil || !network.IsLightNode(p.BzzAddr) {
+		return
+	}
+	obfuscatedRequestCount.Inc(1)
+	// as a request without origin, it is routed as the node's own request,
+	// but still must not be sent back to the light client
+	req.Origin = enode.ID{}
+	req.PeersToSkip.Store(p.ID().String(), time.Now().Add(timeouts.FetcherGlobalTimeout))
+}
+
+// obfuscationDelay returns the delay before a request received from the
+// peer is handled, if the peer is a light client and obfuscation is enabled
+func (r *Retrieval) obfuscationDelay(p *Peer) time.Duration {
+	if r.obfuscation == nil || !network.IsLightNode(p.BzzAddr) || r.obfuscation.params.MaxDelay <= 0 {
+		return 0
+	}
+	r.obfuscation.mtx.Lock()
+	defer r.obfuscation.mtx.Unlock()
+	return time.Duration(r.obfuscation.rand.Int63n(int64(r.obfuscation.params.MaxDelay)))
+}
+
+// sendCoverRequests sends cover requests at random intervals
+// with CoverInterval mean until the service is stopped
+func (r *Retrieval) sendCoverRequests() {
+	for {
+		r.obfuscation.mtx.Lock()
+		interval := time.Duration(r.obfuscation.rand.ExpFloat64() * float64(r.obfuscation.params.CoverInterval))
+		r.obfuscation.mtx.Unlock()
+
+		select {
+		case <-time.After(interval):
+		case <-r.quit:
+			return
+		}
+		if err := r.sendCoverRequest(); err != nil {
+			r.logger.Trace("cover request not sent", "err", err)
+		}
+	}
+}
+
+// sendCoverRequest sends a retrieve request for a random chunk to a random
+// peer. The request is not tracked, as no chunk is expected to be delivered.
+func (r *Retrieval) sendCoverRequest() error {
+	r.mtx.RLock()
+	peers := make([]*Peer, 0, len(r.peers))
+	for _, p := range r.peers {
+		peers = append(peers, p)
+	}
+	r.mtx.RUnlock()
+	if len(peers) == 0 {
+		return ErrNoPeerFound
+	}
+
+	r.obfuscation.mtx.Lock()
+	p := peers[r.obfuscation.rand.Intn(len(peers))]
+	req := &RetrieveRequest{
+		Ruid: uint(r.obfuscation.rand.Uint32()),
+		Addr: make(storage.Address, 32),
+		ID:   r.obfuscation.rand.Uint64(),
+	}
+	r.obfuscation.rand.Read(req.Addr)
+	r.obfuscation.mtx.Unlock()
+
+	coverRequestCount.Inc(1)
+	return p.sendRetrieveRequest(context.Background(), req)
+}
@@ -0,0 +1,168 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/network/capability"
+	"github.com/ethersphere/swarm/p2p/protocols"
+	p2ptest "github.com/ethersphere/swarm/p2p/testing"
+	"github.com/ethersphere/swarm/storage"
+	"github.com/ethersphere/swarm/swap"
+)
+
+// newTestObfuscationPeer returns a peer with light or full node capabilities
+func newTestObfuscationPeer(lightNode bool, base *network.BzzAddr) *Peer {
+	c := capability.NewCapability(network.CapabilityID, 16)
+	c.Set(0)
+	c.Set(1)
+	if !lightNode {
+		c.Set(4)
+		c.Set(5)
+		c.Set(15)
+	}
+	addr := network.RandomBzzAddr()
+	addr.Capabilities = capability.NewCapabilities()
+	addr.Capabilities.Add(c)
+	return NewPeer(&network.BzzPeer{
+		BzzAddr: addr,
+		Peer:    protocols.NewPeer(p2p.NewPeer(adapters.RandomNodeConfig().ID, "peer", nil), nil, nil),
+	}, base)
+}
+
+// TestObfuscateRequest checks that only requests of light clients are
+// forwarded as the node's own requests and that they are delayed by
+// less than the maximal delay
+func TestObfuscateRequest(t *testing.T) {
+	r := New(nil, nil, network.RandomBzzAddr(), nil)
+	light := newTestObfuscationPeer(true, r.baseAddress)
+	full := newTestObfuscationPeer(false, r.baseAddress)
+
+	req := &storage.Request{Addr: hash0[:], Origin: light.ID()}
+	r.obfuscateRequest(light, req)
+	if delay := r.obfuscationDelay(light); delay != 0 || req.Origin != light.ID() {
+		t.Fatalf("got delay %v and origin %v with obfuscation disabled", delay, req.Origin)
+	}
+
+	maxDelay := 100 * time.Millisecond
+	r.EnableObfuscation(&ObfuscationParams{MaxDelay: maxDelay})
+
+	for i := 0; i < 10; i++ {
+		req := &storage.Request{Addr: hash0[:], Origin: light.ID()}
+		r.obfuscateRequest(light, req)
+		delay := r.obfuscationDelay(light)
+		if delay < 0 || delay >= maxDelay {
+			t.Errorf("got delay %v, want less than %v", delay, maxDelay)
+		}
+		if req.Origin != (enode.ID{}) {
+			t.Errorf("got origin %v, want none", req.Origin)
+		}
+		if !req.SkipPeer(light.ID().String()) {
+			t.Error("expected the request not to be sent back to the light client")
+		}
+	}
+
+	req = &storage.Request{Addr: hash0[:], Origin: full.ID()}
+	r.obfuscateRequest(full, req)
+	if delay := r.obfuscationDelay(full); delay != 0 || req.Origin != full.ID() {
+		t.Fatalf("got delay %v and origin %v for a full node request", delay, req.Origin)
+	}
+}
+
+// TestCoverRequest checks that a cover request for a random chunk is
+// sent to a peer, paid for by the node and not tracked as a retrieval
+func TestCoverRequest(t *testing.T) {
+	prvkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := network.RandomBzzAddr()
+	kad := network.NewKademlia(addr.OAddr, network.NewKadParams())
+	pricing := swap.RetrievePricing{
+		Base:     1000,
+		Discount: 100,
+		Min:      200,
+	}
+	balance := &pricingBalance{
+		local: swap.DefaultRetrievePricing,
+		peers: make(map[enode.ID]swap.RetrievePricing),
+	}
+	r := New(kad, nil, addr, balance)
+	r.EnableObfuscation(&ObfuscationParams{CoverInterval: time.Second})
+	if err := r.sendCoverRequest(); err != ErrNoPeerFound {
+		t.Fatalf("got error %v without peers, want %v", err, ErrNoPeerFound)
+	}
+
+	tester := p2ptest.NewProtocolTester(prvkey, 1, r.runProtocol)
+	defer tester.Stop()
+	node := tester.Nodes[0]
+	balance.peers[node.ID()] = pricing
+
+	var peer *Peer
+	for i := 0; peer == nil; i++ {
+		if i == 100 {
+			t.Fatal("peer not added")
+		}
+		time.Sleep(10 * time.Millisecond)
+		peer = r.getPeer(node.ID())
+	}
+
+	// replay the random source to know the cover request
+	r.obfuscation.rand = rand.New(rand.NewSource(1))
+	expected := rand.New(rand.NewSource(1))
+	expected.Intn(1)
+	want := &RetrieveRequest{
+		Ruid: uint(expected.Uint32()),
+		Addr: make(storage.Address, 32),
+		ID:   expected.Uint64(),
+	}
+	expected.Read(want.Addr)
+
+	if err := r.sendCoverRequest(); err != nil {
+		t.Fatal(err)
+	}
+	err = tester.TestExchanges(p2ptest.Exchange{
+		Label: "cover request",
+		Expects: []p2ptest.Expect{
+			{
+				Code: 1,
+				Msg:  want,
+				Peer: node.ID(),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	price := int64(pricing.Price(chunk.Proximity(peer.Over(), want.Addr)))
+	if balance.amount != -price {
+		t.Errorf("got accounted amount %v, want %v", balance.amount, -price)
+	}
+	if err := peer.checkRequest(want.Ruid, want.Addr); err == nil {
+		t.Errorf("expected cover request %v not to be tracked", want.Ruid)
+	}
+}
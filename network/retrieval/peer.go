@@ -18,12 +18,15 @@ package retrieval
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/log"
 	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/p2p/protocols"
 	"github.com/ethersphere/swarm/storage"
 )
 
@@ -31,9 +34,20 @@ import (
 // retrievals for that peer
 type Peer struct {
 	*network.BzzPeer
-	logger     log.Logger             // logger with base and peer address
-	mtx        sync.Mutex             // synchronize retrievals
-	retrievals map[uint]chunk.Address // current ongoing retrievals
+	logger     log.Logger              // logger with base and peer address
+	mtx        sync.Mutex              // synchronize retrievals
+	retrievals map[uint]retrievalEntry // current ongoing retrievals
+	rtt        *rttEstimator           // round trip time statistics of retrieve requests
+	legacy     bool                    // the peer speaks the previous protocol version, without request ids
+
+	requests   *protocols.PeerWorkers // submits the handlers of retrieve requests
+	deliveries *protocols.PeerWorkers // submits the handlers of chunk deliveries
+}
+
+// retrievalEntry is an ongoing retrieval of a chunk
+type retrievalEntry struct {
+	addr chunk.Address // requested chunk address
+	sent time.Time     // when the retrieve request was sent
 }
 
 // NewPeer is the constructor for Peer
@@ -41,20 +55,34 @@ func NewPeer(peer *network.BzzPeer, baseKey *network.BzzAddr) *Peer {
 	return &Peer{
 		BzzPeer:    peer,
 		logger:     log.NewBaseAddressLogger("base", baseKey.ShortString(), "peer", peer.BzzAddr.ShortString()),
-		retrievals: make(map[uint]chunk.Address),
+		retrievals: make(map[uint]retrievalEntry),
+		rtt:        newRTTEstimator(),
 	}
 }
 
+// sendRetrieveRequest sends a retrieve request to the peer,
+// without its id if the peer speaks the previous protocol version
+func (p *Peer) sendRetrieveRequest(ctx context.Context, req *RetrieveRequest) error {
+	if p.legacy {
+		return p.Send(ctx, &legacyRetrieveRequest{Ruid: req.Ruid, Addr: req.Addr})
+	}
+	return p.Send(ctx, req)
+}
+
 // chunkRequested adds a new retrieval to the retrievals map
 // this is in order to identify unsolicited chunk deliveries
 func (p *Peer) addRetrieval(ruid uint, addr storage.Address) {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
-	p.retrievals[ruid] = addr
+	p.retrievals[ruid] = retrievalEntry{
+		addr: addr,
+		sent: time.Now(),
+	}
 }
 
 // chunkReceived is called upon ChunkDelivery message reception
 // it is meant to idenfify unsolicited chunk deliveries
+// the round trip time of solicited deliveries is added to the peer's statistics
 func (p *Peer) checkRequest(ruid uint, addr storage.Address) error {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
@@ -63,9 +91,17 @@ func (p *Peer) checkRequest(ruid uint, addr storage.Address) error {
 		return errors.New("cannot find ruid")
 	}
 	delete(p.retrievals, ruid) // since we got the delivery we wanted - it is safe to delete the retrieve request
-	if !bytes.Equal(v, addr) {
+	if !bytes.Equal(v.addr, addr) {
 		return errors.New("retrieve request found but address does not match")
 	}
+	p.rtt.add(time.Since(v.sent))
 
 	return nil
 }
+
+// SearchTimeout returns how long to wait for a chunk delivery from the peer
+// before another peer is tried, based on the round trip times of the previous
+// retrieve requests to the peer
+func (p *Peer) SearchTimeout() time.Duration {
+	return p.rtt.timeout()
+}
@@ -0,0 +1,196 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/chunk"
+)
+
+const (
+	// provenanceBuckets is the number of buckets the sliding window is divided into
+	provenanceBuckets = 60
+	// maxProvenanceChunks is the maximal number of chunks tracked in a bucket,
+	// requests for further chunks are not tracked to bound the memory used
+	maxProvenanceChunks = 10000
+	// maxProvenancePeers is the maximal number of requesting peers tracked
+	// for a chunk in a bucket
+	maxProvenancePeers = 64
+	// maxHotChunkPeers is the maximal number of requesting peers reported for a chunk
+	maxHotChunkPeers = 16
+)
+
+var (
+	untrackedRequestCount = metrics.NewRegisteredCounter("network.retrieve.provenance.untracked", nil)
+
+	errProvenanceDisabled = errors.New("request provenance tracking is disabled")
+)
+
+// HotChunk is a chunk requested from the node within the provenance window
+type HotChunk struct {
+	Chunk    string           `json:"chunk"`    // address of the chunk
+	Requests uint64           `json:"requests"` // number of requests for the chunk
+	Peers    []RequestingPeer `json:"peers"`    // peers requesting the chunk, most requests first
+}
+
+// RequestingPeer counts the requests of a peer for a chunk
+type RequestingPeer struct {
+	Peer     string `json:"peer"`     // hex encoded overlay address of the peer
+	Requests uint64 `json:"requests"` // number of requests of the peer
+}
+
+// chunkRequests counts the requests for a chunk
+type chunkRequests struct {
+	count uint64
+	peers map[string]uint64
+}
+
+// provenanceBucket counts the requests for chunks in a slot of time
+type provenanceBucket struct {
+	slot   int64 // start of the bucket in multiples of the bucket width since the epoch
+	chunks map[string]*chunkRequests
+}
+
+// provenance tracks the chunks requested from the node and the peers requesting
+// them over a sliding window, so that operators can identify hot content and
+// abusive requesters. The window is divided into buckets which are reused as
+// time passes, so old requests are forgotten at the granularity of a bucket.
+type provenance struct {
+	mtx     sync.Mutex
+	window  time.Duration
+	width   time.Duration // width of a bucket
+	buckets []provenanceBucket
+}
+
+func newProvenance(window time.Duration) *provenance {
+	width := window / provenanceBuckets
+	if width <= 0 {
+		width = 1
+	}
+	return &provenance{
+		window:  window,
+		width:   width,
+		buckets: make([]provenanceBucket, provenanceBuckets),
+	}
+}
+
+// add records a request for the chunk by the peer with the overlay address
+func (pv *provenance) add(addr chunk.Address, peer []byte, now time.Time) {
+	slot := now.UnixNano() / int64(pv.width)
+
+	pv.mtx.Lock()
+	defer pv.mtx.Unlock()
+	b := &pv.buckets[slot%provenanceBuckets]
+	if b.slot != slot || b.chunks == nil {
+		b.slot = slot
+		b.chunks = make(map[string]*chunkRequests)
+	}
+	key := addr.Hex()
+	cr, ok := b.chunks[key]
+	if !ok {
+		if len(b.chunks) >= maxProvenanceChunks {
+			untrackedRequestCount.Inc(1)
+			return
+		}
+		cr = &chunkRequests{peers: make(map[string]uint64)}
+		b.chunks[key] = cr
+	}
+	cr.count++
+	p := hex.EncodeToString(peer)
+	if _, ok := cr.peers[p]; ok || len(cr.peers) < maxProvenancePeers {
+		cr.peers[p]++
+	}
+}
+
+// top returns the n most requested chunks within the window, with their
+// most requesting peers
+func (pv *provenance) top(n int, now time.Time) []HotChunk {
+	slot := now.UnixNano() / int64(pv.width)
+
+	pv.mtx.Lock()
+	merged := make(map[string]*chunkRequests)
+	for _, b := range pv.buckets {
+		if b.chunks == nil || b.slot > slot || b.slot <= slot-provenanceBuckets {
+			continue
+		}
+		for key, cr := range b.chunks {
+			m, ok := merged[key]
+			if !ok {
+				m = &chunkRequests{peers: make(map[string]uint64)}
+				merged[key] = m
+			}
+			m.count += cr.count
+			for p, c := range cr.peers {
+				m.peers[p] += c
+			}
+		}
+	}
+	pv.mtx.Unlock()
+
+	hot := make([]HotChunk, 0, len(merged))
+	for key, cr := range merged {
+		hot = append(hot, HotChunk{Chunk: key, Requests: cr.count})
+	}
+	sort.Slice(hot, func(i, j int) bool {
+		if hot[i].Requests != hot[j].Requests {
+			return hot[i].Requests > hot[j].Requests
+		}
+		return hot[i].Chunk < hot[j].Chunk
+	})
+	if n >= 0 && len(hot) > n {
+		hot = hot[:n]
+	}
+	for i := range hot {
+		peers := make([]RequestingPeer, 0, len(merged[hot[i].Chunk].peers))
+		for p, c := range merged[hot[i].Chunk].peers {
+			peers = append(peers, RequestingPeer{Peer: p, Requests: c})
+		}
+		sort.Slice(peers, func(i, j int) bool {
+			if peers[i].Requests != peers[j].Requests {
+				return peers[i].Requests > peers[j].Requests
+			}
+			return peers[i].Peer < peers[j].Peer
+		})
+		if len(peers) > maxHotChunkPeers {
+			peers = peers[:maxHotChunkPeers]
+		}
+		hot[i].Peers = peers
+	}
+	return hot
+}
+
+// EnableProvenance makes the node track the chunks requested from it and the
+// requesting peers over the sliding window. It must be called before the
+// service is started.
+func (r *Retrieval) EnableProvenance(window time.Duration) {
+	r.provenance = newProvenance(window)
+}
+
+// HotChunks returns the n most requested chunks within the provenance window
+// and the peers requesting them
+func (a *API) HotChunks(n int) ([]HotChunk, error) {
+	if a.retrieval.provenance == nil {
+		return nil, errProvenanceDisabled
+	}
+	return a.retrieval.provenance.top(n, time.Now()), nil
+}
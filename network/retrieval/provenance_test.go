@@ -0,0 +1,77 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/ethersphere/swarm/chunk"
+)
+
+// TestProvenance tests that the most requested chunks and their requesting
+// peers are reported in order and that requests leave the sliding window
+func TestProvenance(t *testing.T) {
+	window := time.Minute
+	pv := newProvenance(window)
+	now := time.Unix(1000, 0)
+
+	hot, cold := chunk.Address(make([]byte, 32)), chunk.Address(make([]byte, 32))
+	hot[0], cold[0] = 1, 2
+	peerA, peerB := []byte{0xaa}, []byte{0xbb}
+
+	for i := 0; i < 3; i++ {
+		pv.add(hot, peerA, now)
+	}
+	pv.add(hot, peerB, now.Add(time.Second))
+	pv.add(cold, peerB, now.Add(2*time.Second))
+
+	top := pv.top(10, now.Add(3*time.Second))
+	if len(top) != 2 {
+		t.Fatalf("expected 2 hot chunks, got %d", len(top))
+	}
+	if top[0].Chunk != hot.Hex() || top[0].Requests != 4 {
+		t.Fatalf("expected chunk %s with 4 requests first, got %s with %d", hot.Hex(), top[0].Chunk, top[0].Requests)
+	}
+	peers := top[0].Peers
+	if len(peers) != 2 || peers[0].Peer != hex.EncodeToString(peerA) || peers[0].Requests != 3 || peers[1].Requests != 1 {
+		t.Fatalf("unexpected requesting peers %v", peers)
+	}
+	if top[1].Chunk != cold.Hex() || top[1].Requests != 1 {
+		t.Fatalf("expected chunk %s with 1 request second, got %s with %d", cold.Hex(), top[1].Chunk, top[1].Requests)
+	}
+
+	if top := pv.top(1, now.Add(3*time.Second)); len(top) != 1 {
+		t.Fatalf("expected 1 hot chunk, got %d", len(top))
+	}
+
+	// the first requests leave the window, the later ones stay in it
+	later := now.Add(window + 500*time.Millisecond)
+	pv.add(cold, peerA, later)
+	top = pv.top(10, later)
+	if len(top) != 2 || top[0].Chunk != cold.Hex() || top[0].Requests != 2 {
+		t.Fatalf("expected chunk %s with 2 requests first after the window moved, got %v", cold.Hex(), top)
+	}
+	if top[1].Requests != 1 {
+		t.Fatalf("expected 1 request for chunk %s in the window, got %d", hot.Hex(), top[1].Requests)
+	}
+
+	if top := pv.top(10, now.Add(3*window)); len(top) != 0 {
+		t.Fatalf("expected no hot chunks after the window, got %v", top)
+	}
+}
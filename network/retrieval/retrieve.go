@@ -53,12 +53,13 @@ var (
 	handleRetrieveRequestMsgCount = metrics.NewRegisteredCounter("network.retrieve.handle_retrieve_request_msg", nil)
 	retrieveChunkFail             = metrics.NewRegisteredCounter("network.retrieve.retrieve_chunks_fail", nil)
 	unsolicitedChunkDelivery      = metrics.NewRegisteredCounter("network.retrieve.unsolicited_delivery", nil)
+	throttledRequestCount         = metrics.NewRegisteredCounter("network.retrieve.throttled_requests", nil)
 
 	retrievalPeers = metrics.GetOrRegisterGauge("network.retrieve.peers", nil)
 
 	spec = &protocols.Spec{
 		Name:       "bzz-retrieve",
-		Version:    2,
+		Version:    3,
 		MaxMsgSize: 10 * 1024 * 1024,
 		Messages: []interface{}{
 			ChunkDelivery{},
@@ -66,6 +67,18 @@ var (
 		},
 	}
 
+	// legacySpec is the previous protocol version, whose retrieve requests
+	// have no id. It is still served, so nodes can be upgraded one by one.
+	legacySpec = &protocols.Spec{
+		Name:       spec.Name,
+		Version:    2,
+		MaxMsgSize: spec.MaxMsgSize,
+		Messages: []interface{}{
+			ChunkDelivery{},
+			legacyRetrieveRequest{},
+		},
+	}
+
 	ErrNoPeerFound = errors.New("no peer found")
 )
 
@@ -80,6 +93,11 @@ func (rr *RetrieveRequest) Price() *protocols.Price {
 	}
 }
 
+// Price prices retrieve requests of the previous protocol version as RetrieveRequest
+func (rr *legacyRetrieveRequest) Price() *protocols.Price {
+	return (&RetrieveRequest{}).Price()
+}
+
 // Price is the method through which a message type marks itself
 // as implementing the protocols.Price protocol and thus
 // as swap-enabled message
@@ -87,20 +105,37 @@ func (cd *ChunkDelivery) Price() *protocols.Price {
 	return &protocols.Price{
 		Value:   swap.ChunkDeliveryPrice,
 		PerByte: true,
+		Min:     swap.ChunkDeliveryMinPrice,
 		Payer:   protocols.Receiver,
 	}
 }
 
+// retrievePricer is implemented by balances, such as swap, which
+// price retrieve requests by the proximity of chunks to the serving node
+type retrievePricer interface {
+	RetrievePricing() swap.RetrievePricing
+	PeerRetrievePricing(id enode.ID) (swap.RetrievePricing, bool)
+}
+
 // Retrieval holds state and handles protocol messages for the `bzz-retrieve` protocol
 type Retrieval struct {
-	netStore    *storage.NetStore
-	baseAddress *network.BzzAddr
-	kad         *network.Kademlia
-	mtx         sync.RWMutex       // protect peer map
-	peers       map[enode.ID]*Peer // compatible peers
-	spec        *protocols.Spec    // protocol spec
-	logger      log.Logger         // custom logger to append a basekey
-	quit        chan struct{}      // shutdown channel
+	netStore     *storage.NetStore
+	baseAddress  *network.BzzAddr
+	kad          *network.Kademlia
+	mtx          sync.RWMutex          // protect peer map
+	peers        map[enode.ID]*Peer    // compatible peers
+	spec         *protocols.Spec       // protocol spec
+	legacySpec   *protocols.Spec       // spec of the previous protocol version
+	logger       log.Logger            // custom logger to append a basekey
+	quit         chan struct{}         // shutdown channel
+	obfuscation  *obfuscation          // obfuscation of light client requests origin, nil if disabled
+	corruption   *corruptionStats      // invalid chunk deliveries per peer
+	retryCorrupt bool                  // request a chunk from another peer when an invalid one is delivered
+	requests     *protocols.WorkerPool // runs the handlers of retrieve requests
+	deliveries   *protocols.WorkerPool // runs the handlers of chunk deliveries, which requests being handled wait for
+	seen         *seenRequests         // ids of recently handled requests to detect forwarding loops
+	provenance   *provenance           // requested chunks and requesting peers, nil if disabled
+	throttler    protocols.Throttler   // delays serving peers with debts close to the disconnect threshold, nil if disabled
 }
 
 // New returns a new instance of the retrieval protocol handler
@@ -113,14 +148,82 @@ func New(kad *network.Kademlia, ns *storage.NetStore, baseKey *network.BzzAddr,
 		logger:      log.NewBaseAddressLogger("base", baseKey.ShortString()),
 		baseAddress: baseKey,
 		quit:        make(chan struct{}),
+		corruption:  newCorruptionStats(),
+		seen:        newSeenRequests(seenRequestsCapacity, timeouts.FetcherGlobalTimeout),
 	}
+	r.SetWorkers(protocols.NewWorkerPoolParams())
 	if balance != nil && !reflect.ValueOf(balance).IsNil() {
+		if throttler, ok := balance.(protocols.Throttler); ok {
+			r.throttler = throttler
+		}
 		// swap is enabled, so setup the hook
-		r.spec.Hook = protocols.NewAccounting(balance)
+		if pricer, ok := balance.(retrievePricer); ok {
+			// the hook prices requests with this node's base address,
+			// so it needs a spec of its own
+			r.spec = &protocols.Spec{
+				Name:       spec.Name,
+				Version:    spec.Version,
+				MaxMsgSize: spec.MaxMsgSize,
+				Messages:   spec.Messages,
+				Hook: protocols.NewAccountingWithPricer(balance, func(p *protocols.Peer, msg interface{}, local protocols.Payer) *protocols.Price {
+					return r.retrieveRequestPrice(pricer, p, msg, local)
+				}),
+			}
+		} else {
+			r.spec.Hook = protocols.NewAccounting(balance)
+		}
+	}
+	r.legacySpec = &protocols.Spec{
+		Name:       legacySpec.Name,
+		Version:    legacySpec.Version,
+		MaxMsgSize: legacySpec.MaxMsgSize,
+		Messages:   legacySpec.Messages,
+		Hook:       r.spec.Hook,
 	}
 	return r
 }
 
+// retrieveRequestPrice prices a retrieve request by the pricing of the node
+// serving it and the proximity between the requested chunk and that node.
+// Requests sent by the local node are served by the peer, and requests
+// received by the local node are served by it. Both nodes of an exchange
+// calculate the same price, as the pricing of the serving node is known
+// to the requesting one from the swap handshake.
+func (r *Retrieval) retrieveRequestPrice(pricer retrievePricer, p *protocols.Peer, msg interface{}, local protocols.Payer) *protocols.Price {
+	var addr storage.Address
+	switch req := msg.(type) {
+	case *RetrieveRequest:
+		addr = req.Addr
+	case *legacyRetrieveRequest:
+		addr = req.Addr
+	default:
+		return nil
+	}
+	var (
+		pricing swap.RetrievePricing
+		server  []byte
+		ok      bool
+	)
+	if local == protocols.Sender {
+		peer := r.getPeer(p.ID())
+		if peer == nil {
+			return nil
+		}
+		if pricing, ok = pricer.PeerRetrievePricing(p.ID()); !ok {
+			return nil
+		}
+		server = peer.Over()
+	} else {
+		pricing = pricer.RetrievePricing()
+		server = r.baseAddress.Over()
+	}
+	return &protocols.Price{
+		Value:   pricing.Price(chunk.Proximity(server, addr)),
+		PerByte: false,
+		Payer:   protocols.Sender,
+	}
+}
+
 func (r *Retrieval) addPeer(p *Peer) {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
@@ -144,7 +247,15 @@ func (r *Retrieval) getPeer(id enode.ID) *Peer {
 
 // Run is being dispatched when 2 nodes connect
 func (r *Retrieval) Run(bp *network.BzzPeer) error {
+	return r.run(bp, false)
+}
+
+// run runs the protocol with a peer, which speaks the previous protocol version if legacy
+func (r *Retrieval) run(bp *network.BzzPeer, legacy bool) error {
 	sp := NewPeer(bp, r.baseAddress)
+	sp.legacy = legacy
+	sp.requests = r.requests.NewPeerWorkers(r.quit)
+	sp.deliveries = r.deliveries.NewPeerWorkers(r.quit)
 	r.addPeer(sp)
 	defer r.removePeer(sp)
 
@@ -153,18 +264,61 @@ func (r *Retrieval) Run(bp *network.BzzPeer) error {
 
 func (r *Retrieval) handleMsg(p *Peer) func(context.Context, interface{}) error {
 	return func(ctx context.Context, msg interface{}) error {
+		var err error
 		switch msg := msg.(type) {
+		case *legacyRetrieveRequest:
+			err = r.submitRetrieveRequest(ctx, p, &RetrieveRequest{Ruid: msg.Ruid, Addr: msg.Addr})
 		case *RetrieveRequest:
 			// we must handle them in a different goroutine otherwise parallel requests
 			// for other chunks from the same peer will get stuck in the queue
-			go r.handleRetrieveRequest(ctx, p, msg)
+			err = r.submitRetrieveRequest(ctx, p, msg)
 		case *ChunkDelivery:
-			go r.handleChunkDelivery(ctx, p, msg)
+			// deliveries are not dropped, submitting blocks reading from
+			// the peer while it has too many of them waiting or running
+			err = p.deliveries.Submit(func() {
+				r.handleChunkDelivery(ctx, p, msg)
+			})
+		}
+		if err != nil {
+			// the node is shutting down, or the peer has too many retrieve
+			// requests waiting or running and times out and retries
+			p.logger.Debug("retrieval message not handled", "err", err)
 		}
 		return nil
 	}
 }
 
+// submitRetrieveRequest submits the handling of a retrieve request to the
+// worker pool, after the delay of requests of light clients if obfuscation
+// is enabled and of peers with debts close to the disconnect threshold, so
+// that delayed requests do not take a worker while waiting.
+// As retrieve requests are idempotent, they are dropped instead of blocking
+// reading from the peer while it has too many of them waiting or running.
+func (r *Retrieval) submitRetrieveRequest(ctx context.Context, p *Peer, msg *RetrieveRequest) error {
+	submit := func() error {
+		return p.requests.TrySubmit(func() {
+			r.handleRetrieveRequest(ctx, p, msg)
+		})
+	}
+	delay := r.obfuscationDelay(p)
+	// peers with debts close to the disconnect threshold are served later
+	if r.throttler != nil {
+		if throttle := r.throttler.Throttle(p.ID()); throttle > 0 {
+			throttledRequestCount.Inc(1)
+			delay += throttle
+		}
+	}
+	if delay <= 0 {
+		return submit()
+	}
+	time.AfterFunc(delay, func() {
+		if err := submit(); err != nil {
+			p.logger.Debug("retrieval message not handled", "err", err)
+		}
+	})
+	return nil
+}
+
 // getOriginPo returns the originPo if the incoming Request has an Origin
 // if our node is the first node that requests this chunk, then we don't have an Origin,
 // and return -1
@@ -315,26 +469,45 @@ func (r *Retrieval) handleRetrieveRequest(ctx context.Context, p *Peer, msg *Ret
 
 	defer osp.Finish()
 
+	if r.provenance != nil {
+		r.provenance.add(msg.Addr, p.Over(), time.Now())
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, timeouts.FetcherGlobalTimeout)
 	defer cancel()
 
 	req := &storage.Request{
 		Addr:   msg.Addr,
 		Origin: p.ID(),
+		ID:     msg.ID,
 	}
-	chunk, err := r.netStore.Get(ctx, chunk.ModeGetRequest, req)
+	r.obfuscateRequest(p, req)
+	// chunks in the local store are served even if the request came back
+	// through a forwarding loop, as the loop only matters for forwarding
+	ch, err := r.netStore.Store.Get(ctx, chunk.ModeGetRequest, msg.Addr)
 	if err != nil {
-		retrieveChunkFail.Inc(1)
-		p.logger.Trace("netstore.Get can not retrieve chunk", "ref", msg.Addr, "err", err)
-		return
+		// a request seen before came back through a forwarding loop, drop it
+		// so that the previous node tries another peer instead of waiting
+		if msg.ID != 0 && r.seen.seen(msg.ID, time.Now()) {
+			loopedRequestCount.Inc(1)
+			p.logger.Debug("retrieval.handleRetrieveRequest - forwarding loop", "ref", msg.Addr, "id", msg.ID)
+			osp.LogFields(olog.Bool("loop", true))
+			return
+		}
+		ch, err = r.netStore.Get(ctx, chunk.ModeGetRequest, req)
+		if err != nil {
+			retrieveChunkFail.Inc(1)
+			p.logger.Trace("netstore.Get can not retrieve chunk", "ref", msg.Addr, "err", err)
+			return
+		}
 	}
 
 	p.logger.Trace("retrieval.handleRetrieveRequest - delivery", "ref", msg.Addr)
 
 	deliveryMsg := &ChunkDelivery{
 		Ruid:  msg.Ruid,
-		Addr:  chunk.Address(),
-		SData: chunk.Data(),
+		Addr:  ch.Address(),
+		SData: ch.Data(),
 	}
 
 	err = p.Send(ctx, deliveryMsg)
@@ -355,6 +528,7 @@ func (r *Retrieval) handleChunkDelivery(ctx context.Context, p *Peer, msg *Chunk
 	if err != nil {
 		unsolicitedChunkDelivery.Inc(1)
 		p.logger.Error("unsolicited chunk delivery from peer", "ruid", msg.Ruid, "addr", msg.Addr, "err", err)
+		r.kad.ReportPeerError(p.BzzPeer, network.PeerErrorInvalid, err)
 		p.Drop("unsolicited chunk delivery")
 		return
 	}
@@ -387,6 +561,12 @@ func (r *Retrieval) handleChunkDelivery(ctx context.Context, p *Peer, msg *Chunk
 	if err != nil {
 		p.logger.Error("netstore error putting chunk to localstore", "err", err)
 		if err == storage.ErrChunkInvalid {
+			p.logger.Warn("peer delivered invalid chunk", "ref", msg.Addr)
+			r.corruption.add(p.Over(), msg.Addr, time.Now())
+			r.kad.ReportPeerError(p.BzzPeer, network.PeerErrorCorrupt, err)
+			if r.retryCorrupt {
+				r.netStore.Rerequest(msg.Addr)
+			}
 			p.Drop("invalid chunk in netstore put")
 		}
 	}
@@ -420,13 +600,19 @@ FINDPEER:
 		goto FINDPEER
 	}
 
+	if req.ID == 0 {
+		// the request originates from this node, forwarded requests keep their id
+		req.ID = rand.Uint64()
+		r.seen.seen(req.ID, time.Now())
+	}
 	ret := &RetrieveRequest{
 		Ruid: uint(rand.Uint32()),
 		Addr: req.Addr,
+		ID:   req.ID,
 	}
 	protoPeer.logger.Trace("sending retrieve request", "ref", ret.Addr, "origin", localID, "ruid", ret.Ruid)
 	protoPeer.addRetrieval(ret.Ruid, ret.Addr)
-	err = protoPeer.Send(ctx, ret)
+	err = protoPeer.sendRetrieveRequest(ctx, ret)
 	if err != nil {
 		protoPeer.logger.Error("error sending retrieve request to peer", "ruid", ret.Ruid, "err", err)
 		return nil, err
@@ -436,17 +622,40 @@ FINDPEER:
 	return &spID, nil
 }
 
+// SearchTimeout returns how long to wait for a chunk delivery from the peer
+// with the given id before another peer is tried
+func (r *Retrieval) SearchTimeout(id enode.ID) time.Duration {
+	p := r.getPeer(id)
+	if p == nil {
+		return timeouts.SearchTimeout
+	}
+	return p.SearchTimeout()
+}
+
 func (r *Retrieval) Start(server *p2p.Server) error {
 	r.logger.Info("starting bzz-retrieve")
+	if r.obfuscation != nil && r.obfuscation.params.CoverInterval > 0 {
+		go r.sendCoverRequests()
+	}
 	return nil
 }
 
 func (r *Retrieval) Stop() error {
 	r.logger.Info("shutting down bzz-retrieve")
 	close(r.quit)
+	r.requests.Stop()
+	r.deliveries.Stop()
 	return nil
 }
 
+// SetWorkers sets the limits of the worker pools handling retrieve requests
+// and chunk deliveries, each of them has a pool of its own.
+// It must be called before the service is started.
+func (r *Retrieval) SetWorkers(params *protocols.WorkerPoolParams) {
+	r.requests = protocols.NewWorkerPool("retrieval.requests", params)
+	r.deliveries = protocols.NewWorkerPool("retrieval.deliveries", params)
+}
+
 func (r *Retrieval) Protocols() []p2p.Protocol {
 	return []p2p.Protocol{
 		{
@@ -455,6 +664,12 @@ func (r *Retrieval) Protocols() []p2p.Protocol {
 			Length:  r.spec.Length(),
 			Run:     r.runProtocol,
 		},
+		{
+			Name:    r.legacySpec.Name,
+			Version: r.legacySpec.Version,
+			Length:  r.legacySpec.Length(),
+			Run:     r.runLegacyProtocol,
+		},
 	}
 }
 
@@ -465,8 +680,23 @@ func (r *Retrieval) runProtocol(p *p2p.Peer, rw p2p.MsgReadWriter) error {
 	return r.Run(bp)
 }
 
+// runLegacyProtocol runs the previous protocol version with peers not supporting the current one
+func (r *Retrieval) runLegacyProtocol(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+	peer := protocols.NewPeer(p, rw, r.legacySpec)
+	bp := network.NewBzzPeer(peer)
+
+	return r.run(bp, true)
+}
+
 func (r *Retrieval) APIs() []rpc.API {
-	return nil
+	return []rpc.API{
+		{
+			Namespace: "retrieval",
+			Version:   "1.0",
+			Service:   NewAPI(r),
+			Public:    false,
+		},
+	}
 }
 
 func (r *Retrieval) Spec() *protocols.Spec {
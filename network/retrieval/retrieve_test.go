@@ -43,12 +43,14 @@ import (
 	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/network"
 	"github.com/ethersphere/swarm/network/simulation"
+	"github.com/ethersphere/swarm/network/timeouts"
 	"github.com/ethersphere/swarm/p2p/protocols"
 	p2ptest "github.com/ethersphere/swarm/p2p/testing"
 	"github.com/ethersphere/swarm/state"
 	"github.com/ethersphere/swarm/storage"
 	"github.com/ethersphere/swarm/storage/localstore"
 	"github.com/ethersphere/swarm/storage/mock"
+	"github.com/ethersphere/swarm/swap"
 	"github.com/ethersphere/swarm/testutil"
 	"golang.org/x/crypto/sha3"
 )
@@ -462,6 +464,138 @@ func TestRequestFromPeers(t *testing.T) {
 	}
 }
 
+// TestSearchTimeout checks that the per-peer search timeout adapts to the
+// observed round trip times and stays within the configured bounds
+func TestSearchTimeout(t *testing.T) {
+	e := newRTTEstimator()
+	if got := e.timeout(); got != timeouts.SearchTimeout {
+		t.Fatalf("got timeout %v without samples, want %v", got, timeouts.SearchTimeout)
+	}
+
+	// stable round trip times converge to the minimum timeout
+	for i := 0; i < 50; i++ {
+		e.add(10 * time.Millisecond)
+	}
+	if got := e.timeout(); got != timeouts.MinSearchTimeout {
+		t.Fatalf("got timeout %v for fast peer, want %v", got, timeouts.MinSearchTimeout)
+	}
+
+	// a slow but working peer gets a longer timeout than the default
+	e = newRTTEstimator()
+	for i := 0; i < 50; i++ {
+		e.add(time.Second)
+	}
+	slow := e.timeout()
+	if slow <= time.Second || slow > timeouts.MaxSearchTimeout {
+		t.Fatalf("got timeout %v for slow peer", slow)
+	}
+
+	// jitter increases the timeout
+	for i := 0; i < 10; i++ {
+		e.add(500 * time.Millisecond)
+		e.add(1500 * time.Millisecond)
+	}
+	if got := e.timeout(); got <= slow {
+		t.Fatalf("got timeout %v for jittery peer, want more than %v", got, slow)
+	}
+
+	// very slow peers are bounded
+	for i := 0; i < 50; i++ {
+		e.add(time.Minute)
+	}
+	if got := e.timeout(); got != timeouts.MaxSearchTimeout {
+		t.Fatalf("got timeout %v for very slow peer, want %v", got, timeouts.MaxSearchTimeout)
+	}
+}
+
+// pricingBalance is a balance which prices retrieve requests
+// by proximity and records the last accounted amount
+type pricingBalance struct {
+	amount int64
+	local  swap.RetrievePricing
+	peers  map[enode.ID]swap.RetrievePricing
+}
+
+func (b *pricingBalance) Add(amount int64, peer *protocols.Peer) error {
+	b.amount = amount
+	return nil
+}
+
+func (b *pricingBalance) RetrievePricing() swap.RetrievePricing {
+	return b.local
+}
+
+func (b *pricingBalance) PeerRetrievePricing(id enode.ID) (swap.RetrievePricing, bool) {
+	pricing, ok := b.peers[id]
+	return pricing, ok
+}
+
+// TestRetrieveRequestPrice checks that the requesting and the serving node
+// account the same retrieve request price, which depends on the proximity
+// of the requested chunk to the serving node
+func TestRetrieveRequestPrice(t *testing.T) {
+	pricing := swap.RetrievePricing{
+		Base:     1000,
+		Discount: 100,
+		Min:      200,
+	}
+	requesterAddr := network.RandomBzzAddr()
+	serverAddr := network.RandomBzzAddr()
+	requesterID := adapters.RandomNodeConfig().ID
+	serverID := adapters.RandomNodeConfig().ID
+
+	requesterBalance := &pricingBalance{
+		local: swap.DefaultRetrievePricing,
+		peers: map[enode.ID]swap.RetrievePricing{serverID: pricing},
+	}
+	requester := New(nil, nil, requesterAddr, requesterBalance)
+	serverBalance := &pricingBalance{
+		local: pricing,
+		peers: map[enode.ID]swap.RetrievePricing{requesterID: swap.DefaultRetrievePricing},
+	}
+	server := New(nil, nil, serverAddr, serverBalance)
+	if spec.Hook != nil {
+		t.Fatal("accounting hook set on the shared protocol spec")
+	}
+
+	serverPeer := protocols.NewPeer(p2p.NewPeer(serverID, "server", nil), nil, nil)
+	requester.addPeer(NewPeer(&network.BzzPeer{
+		BzzAddr: serverAddr,
+		Peer:    serverPeer,
+	}, requesterAddr))
+	requesterPeer := protocols.NewPeer(p2p.NewPeer(requesterID, "requester", nil), nil, nil)
+
+	for _, po := range []int{0, 3, 8, 20} {
+		addr := make([]byte, len(serverAddr.Over()))
+		copy(addr, serverAddr.Over())
+		addr[po/8] ^= 0x80 >> uint(po%8)
+		req := &RetrieveRequest{Addr: addr}
+		want := int64(pricing.Price(po))
+
+		if err := requester.spec.Hook.Send(serverPeer, 0, req); err != nil {
+			t.Fatal(err)
+		}
+		if requesterBalance.amount != -want {
+			t.Errorf("po %v: got requester amount %v, want %v", po, requesterBalance.amount, -want)
+		}
+		if err := server.spec.Hook.Receive(requesterPeer, 0, req); err != nil {
+			t.Fatal(err)
+		}
+		if serverBalance.amount != want {
+			t.Errorf("po %v: got server amount %v, want %v", po, serverBalance.amount, want)
+		}
+	}
+
+	// chunk deliveries are not priced by proximity
+	delivery := &ChunkDelivery{Addr: serverAddr.Over(), SData: make([]byte, 10)}
+	if err := server.spec.Hook.Send(requesterPeer, 10, delivery); err != nil {
+		t.Fatal(err)
+	}
+	if want := delivery.Price().For(protocols.Sender, 10); serverBalance.amount != want {
+		t.Errorf("got chunk delivery amount %v, want %v", serverBalance.amount, want)
+	}
+}
+
 //TestHasPriceImplementation is to check that Retrieval provides priced messages
 func TestHasPriceImplementation(t *testing.T) {
 	price := (&ChunkDelivery{}).Price()
@@ -660,3 +794,57 @@ func newTestNetstore(t *testing.T) (prvkey *ecdsa.PrivateKey, netStore *storage.
 	}
 	return prvkey, netStore, cleanup
 }
+
+// testThrottler delays serving every peer by the same duration
+type testThrottler time.Duration
+
+func (t testThrottler) Throttle(enode.ID) time.Duration {
+	return time.Duration(t)
+}
+
+// TestThrottledRetrieveRequest tests that requests of throttled peers are served with a delay
+func TestThrottledRetrieveRequest(t *testing.T) {
+	pk, ns, cleanup := newTestNetstore(t)
+	defer cleanup()
+	bzzAddr := network.PrivateKeyToBzzKey(pk)
+	kad := network.NewKademlia(bzzAddr, network.NewKadParams())
+
+	tester, r, teardown, err := newRetrievalTester(t, pk, ns, kad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+	delay := 300 * time.Millisecond
+	r.throttler = testThrottler(delay)
+
+	forwarded := make(chan time.Time, 1)
+	ns.RemoteGet = func(ctx context.Context, req *storage.Request, localID enode.ID) (*enode.ID, error) {
+		forwarded <- time.Now()
+		return nil, errors.New("no peer")
+	}
+	start := time.Now()
+	err = tester.TestExchanges(p2ptest.Exchange{
+		Label: "retrieve request",
+		Triggers: []p2ptest.Trigger{
+			{
+				Code: 1,
+				Msg: &RetrieveRequest{
+					Ruid: 1,
+					Addr: []byte{1, 2, 3, 4},
+				},
+				Peer: tester.Nodes[0].ID(),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case served := <-forwarded:
+		if served.Sub(start) < delay {
+			t.Fatalf("expected the request to be served after %v, got %v", delay, served.Sub(start))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request not served")
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/network/timeouts"
+)
+
+const (
+	// rttAlpha is the weight of a new sample in the smoothed round trip time
+	rttAlpha = 0.125
+	// rttBeta is the weight of a new sample in the round trip time deviation
+	rttBeta = 0.25
+	// rttK is the number of deviations added to the smoothed round trip time
+	rttK = 4
+)
+
+var rttTimer = metrics.GetOrRegisterResettingTimer("network.retrieve.rtt", nil)
+
+// rttEstimator keeps an exponentially weighted moving average and mean
+// deviation of the round trip times of retrieve requests to a peer, in the
+// same way TCP estimates its retransmission timeout
+type rttEstimator struct {
+	mtx     sync.Mutex
+	srtt    float64 // smoothed round trip time in nanoseconds
+	rttvar  float64 // round trip time mean deviation in nanoseconds
+	samples int     // number of samples added
+}
+
+func newRTTEstimator() *rttEstimator {
+	return &rttEstimator{}
+}
+
+// add updates the statistics with a round trip time sample
+func (e *rttEstimator) add(rtt time.Duration) {
+	rttTimer.Update(rtt)
+
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	r := float64(rtt)
+	if e.samples == 0 {
+		e.srtt = r
+		e.rttvar = r / 2
+	} else {
+		diff := e.srtt - r
+		if diff < 0 {
+			diff = -diff
+		}
+		e.rttvar = (1-rttBeta)*e.rttvar + rttBeta*diff
+		e.srtt = (1-rttAlpha)*e.srtt + rttAlpha*r
+	}
+	e.samples++
+}
+
+// timeout returns the smoothed round trip time increased by rttK deviations,
+// bounded by timeouts.MinSearchTimeout and timeouts.MaxSearchTimeout
+// if there are no samples yet, timeouts.SearchTimeout is returned
+func (e *rttEstimator) timeout() time.Duration {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	if e.samples == 0 {
+		return timeouts.SearchTimeout
+	}
+	t := time.Duration(e.srtt + rttK*e.rttvar)
+	if t < timeouts.MinSearchTimeout {
+		return timeouts.MinSearchTimeout
+	}
+	if t > timeouts.MaxSearchTimeout {
+		return timeouts.MaxSearchTimeout
+	}
+	return t
+}
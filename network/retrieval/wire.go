@@ -22,6 +22,14 @@ import "github.com/ethersphere/swarm/storage"
 type RetrieveRequest struct {
 	Ruid uint
 	Addr storage.Address
+	ID   uint64 // request id kept by forwarding nodes to detect loops, 0 if unknown
+}
+
+// legacyRetrieveRequest is the retrieve request of the previous protocol
+// version, which has no request id
+type legacyRetrieveRequest struct {
+	Ruid uint
+	Addr storage.Address
 }
 
 // ChunkDelivery is the protocol msg for delivering a solicited chunk to a peer
@@ -0,0 +1,150 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"github.com/ethersphere/swarm/network/stream/intervals"
+	"github.com/ethersphere/swarm/state"
+)
+
+// Every session of syncing a stream with a peer is an epoch, counted per peer
+// and stream by the upstream peer, which records the cursor of the stream when
+// the epoch started. Once the downstream peer synced the stream up to that
+// cursor it completed the epoch. When it reconnects, it sends the last epoch
+// it completed in the StreamInfoReq, and the upstream peer answers with the
+// cursor the epoch started at, so that only the chunks since that epoch are
+// requested instead of all the ranges missing in the intervals.
+
+// maxServedEpochs is the number of the latest epochs of a stream
+// the upstream peer keeps for every downstream peer
+const maxServedEpochs = 16
+
+// servedEpoch is an epoch of syncing a stream to a downstream peer
+type servedEpoch struct {
+	Epoch  uint64 // number of the epoch, starting from 1
+	Cursor uint64 // cursor of the stream when the epoch started
+}
+
+// startServedEpoch starts a new epoch of syncing the stream with the
+// downstream peer, at the current cursor of the stream. It returns the
+// number of the new epoch and the cursor at which the epoch completed by the
+// peer started, 0 if it is not known. Epochs are counted from 1 again if the
+// cursor went back, as then the stream is not the one the peer synced before.
+func (p *Peer) startServedEpoch(stream ID, completed, cursor uint64) (epoch, since uint64, err error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	key := p.peerStreamServedEpochsKey(stream)
+	var served []servedEpoch
+	if err := p.intervalsStore.Get(key, &served); err != nil && err != state.ErrNotFound {
+		return 0, 0, err
+	}
+	if l := len(served); l > 0 && cursor < served[l-1].Cursor {
+		served = nil
+	}
+	for _, e := range served {
+		if completed != 0 && e.Epoch == completed {
+			since = e.Cursor
+		}
+	}
+	epoch = 1
+	if l := len(served); l > 0 {
+		epoch = served[l-1].Epoch + 1
+	}
+	served = append(served, servedEpoch{Epoch: epoch, Cursor: cursor})
+	if len(served) > maxServedEpochs {
+		served = served[len(served)-maxServedEpochs:]
+	}
+	return epoch, since, p.intervalsStore.Put(key, served)
+}
+
+// completedEpoch returns the last epoch of the stream which was synced
+// completely from the upstream peer, 0 if none
+func (p *Peer) completedEpoch(stream ID) (uint64, error) {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	var epoch uint64
+	err := p.intervalsStore.Get(p.peerStreamEpochKey(stream), &epoch)
+	if err == state.ErrNotFound {
+		return 0, nil
+	}
+	return epoch, err
+}
+
+// startEpoch starts the epoch of the session with the upstream peer. All
+// chunks up to since are synced, as they were in the last completed epoch.
+// If the epoch is not higher than the completed one, the upstream peer
+// counts epochs anew and its stream is not the one synced before, so the
+// intervals synced before are discarded and reset is true.
+// An epoch of 0 means that the upstream peer does not count epochs.
+func (p *Peer) startEpoch(stream ID, epoch, since uint64) (reset bool, err error) {
+	if epoch == 0 {
+		return false, nil
+	}
+	completed, err := p.completedEpoch(stream)
+	if err != nil {
+		return false, err
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	key := p.peerStreamIntervalKey(stream)
+	if completed != 0 && epoch <= completed {
+		if err := p.intervalsStore.Delete(p.peerStreamEpochKey(stream)); err != nil {
+			return false, err
+		}
+		if err := p.intervalsStore.Put(key, intervals.NewIntervals(1)); err != nil {
+			return false, err
+		}
+		reset = true
+	} else if since > 0 {
+		i := intervals.NewIntervals(1)
+		if err := p.intervalsStore.Get(key, i); err != nil && err != state.ErrNotFound {
+			return false, err
+		}
+		i.Add(1, since)
+		if err := p.intervalsStore.Put(key, i); err != nil {
+			return false, err
+		}
+	}
+	p.epochs[stream.String()] = epoch
+	return reset, nil
+}
+
+// completeEpoch records that the epoch of the session with the upstream
+// peer is completed, as the stream was synced up to its cursor
+func (p *Peer) completeEpoch(stream ID) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	epoch, ok := p.epochs[stream.String()]
+	if !ok {
+		return nil
+	}
+	delete(p.epochs, stream.String())
+	return p.intervalsStore.Put(p.peerStreamEpochKey(stream), epoch)
+}
+
+func (p *Peer) peerStreamEpochKey(stream ID) string {
+	return p.peerStreamIntervalKey(stream) + "|epoch"
+}
+
+func (p *Peer) peerStreamServedEpochsKey(stream ID) string {
+	return p.peerStreamIntervalKey(stream) + "|served-epochs"
+}
@@ -0,0 +1,114 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"testing"
+
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/state"
+)
+
+// TestPeerEpochs validates that a peer reconnecting after completing an epoch
+// syncs only the chunks since that epoch, and that all synced intervals are
+// discarded once the upstream peer counts epochs anew
+func TestPeerEpochs(t *testing.T) {
+	serverStore := state.NewInmemoryStore()
+	defer serverStore.Close()
+	clientStore := state.NewInmemoryStore()
+	defer clientStore.Close()
+
+	serverAddr, clientAddr := network.RandomBzzAddr(), network.RandomBzzAddr()
+	// upstream is the upstream peer as seen by the downstream node, and downstream the downstream peer as seen by the upstream node
+	newSession := func() (upstream, downstream *Peer) {
+		upstream = newPeer(&network.BzzPeer{BzzAddr: serverAddr}, clientAddr, clientStore, nil, network.NewSystemClock())
+		downstream = newPeer(&network.BzzPeer{BzzAddr: clientAddr}, serverAddr, serverStore, nil, network.NewSystemClock())
+		return upstream, downstream
+	}
+	stream := NewID("SYNC", "1")
+
+	// session starts with the request of the downstream peer and the response of the upstream peer
+	startSession := func(upstream, downstream *Peer, cursor uint64) (epoch, since uint64, reset bool) {
+		t.Helper()
+		completed, err := upstream.completedEpoch(stream)
+		if err != nil {
+			t.Fatal(err)
+		}
+		epoch, since, err = downstream.startServedEpoch(stream, completed, cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := upstream.getOrCreateInterval(upstream.peerStreamIntervalKey(stream)); err != nil {
+			t.Fatal(err)
+		}
+		reset, err = upstream.startEpoch(stream, epoch, since)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return epoch, since, reset
+	}
+	nextStart := func(upstream *Peer) uint64 {
+		t.Helper()
+		start, _, _, err := upstream.nextInterval(stream, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return start
+	}
+
+	upstream, downstream := newSession()
+	if epoch, since, reset := startSession(upstream, downstream, 100); epoch != 1 || since != 0 || reset {
+		t.Fatalf("first session: got epoch %v since %v reset %v, want epoch 1 since 0", epoch, since, reset)
+	}
+	// the history is synced up to the cursor, completing the epoch
+	if err := upstream.addInterval(stream, 1, 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := upstream.completeEpoch(stream); err != nil {
+		t.Fatal(err)
+	}
+
+	// the intervals are lost, but the chunks until the completed epoch need not be synced again
+	if err := clientStore.Delete(upstream.peerStreamIntervalKey(stream)); err != nil {
+		t.Fatal(err)
+	}
+	upstream, downstream = newSession()
+	if epoch, since, reset := startSession(upstream, downstream, 150); epoch != 2 || since != 100 || reset {
+		t.Fatalf("second session: got epoch %v since %v reset %v, want epoch 2 since 100", epoch, since, reset)
+	}
+	if start := nextStart(upstream); start != 101 {
+		t.Fatalf("second session: got next interval start %v, want 101", start)
+	}
+	if err := upstream.addInterval(stream, 101, 150); err != nil {
+		t.Fatal(err)
+	}
+	if err := upstream.completeEpoch(stream); err != nil {
+		t.Fatal(err)
+	}
+
+	// the cursor of the upstream peer went back, so the stream is not the one synced before
+	upstream, downstream = newSession()
+	if epoch, since, reset := startSession(upstream, downstream, 10); epoch != 1 || since != 0 || !reset {
+		t.Fatalf("third session: got epoch %v since %v reset %v, want epoch 1 since 0 and reset", epoch, since, reset)
+	}
+	if start := nextStart(upstream); start != 1 {
+		t.Fatalf("third session: got next interval start %v, want 1", start)
+	}
+	if completed, err := upstream.completedEpoch(stream); err != nil || completed != 0 {
+		t.Fatalf("third session: got completed epoch %v, %v, want 0", completed, err)
+	}
+}
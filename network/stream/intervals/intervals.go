@@ -167,6 +167,29 @@ func (i *Intervals) Last() (end uint64) {
 	return i.ranges[l-1][1]
 }
 
+// Missing returns the number of values from the start bound up to and
+// including the ceiling which are not in the intervals.
+func (i *Intervals) Missing(ceiling uint64) (missing uint64) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if ceiling < i.start {
+		return 0
+	}
+	missing = ceiling - i.start + 1
+	for _, r := range i.ranges {
+		if r[0] > ceiling {
+			break
+		}
+		end := r[1]
+		if end > ceiling {
+			end = ceiling
+		}
+		missing -= end - r[0] + 1
+	}
+	return missing
+}
+
 // String returns a descriptive representation of range intervals
 // in [] notation, as a list of two element vectors.
 func (i *Intervals) String() string {
@@ -471,3 +471,58 @@ func TestMerge(t *testing.T) {
 		}
 	}
 }
+
+// TestMissing tests that Missing counts the values up to the ceiling
+// which are not in the intervals
+func TestMissing(t *testing.T) {
+	for i, tc := range []struct {
+		start   uint64
+		initial [][2]uint64
+		ceiling uint64
+		missing uint64
+	}{
+		{
+			start:   1,
+			initial: nil,
+			ceiling: 0,
+			missing: 0,
+		},
+		{
+			start:   1,
+			initial: nil,
+			ceiling: 10,
+			missing: 10,
+		},
+		{
+			start:   1,
+			initial: [][2]uint64{{1, 10}},
+			ceiling: 10,
+			missing: 0,
+		},
+		{
+			start:   1,
+			initial: [][2]uint64{{1, 5}, {8, 20}},
+			ceiling: 10,
+			missing: 2,
+		},
+		{
+			start:   1,
+			initial: [][2]uint64{{3, 5}},
+			ceiling: 4,
+			missing: 2,
+		},
+		{
+			start:   0,
+			initial: [][2]uint64{{0, 10}, {20, 30}},
+			ceiling: 40,
+			missing: 19,
+		},
+	} {
+		intervals := NewIntervals(tc.start)
+		intervals.ranges = tc.initial
+
+		if got := intervals.Missing(tc.ceiling); got != tc.missing {
+			t.Errorf("interval #%d: expected %d missing, got %d", i, tc.missing, got)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+// parkedRequest is a range request deferred while syncing is paused
+type parkedRequest struct {
+	peer    *Peer
+	request func()
+}
+
+// PauseSync stops requesting new ranges from peers. Ranges that are
+// already requested are still delivered, the requests that would follow
+// them are deferred until ResumeSync is called.
+func (r *Registry) PauseSync() {
+	r.pauseMu.Lock()
+	defer r.pauseMu.Unlock()
+	if !r.paused {
+		r.logger.Info("syncing paused")
+	}
+	r.paused = true
+}
+
+// ResumeSync sends the range requests deferred while syncing was paused
+// to the peers that are still connected.
+func (r *Registry) ResumeSync() {
+	r.pauseMu.Lock()
+	parked := r.parked
+	r.parked = nil
+	if r.paused {
+		r.logger.Info("syncing resumed", "deferred", len(parked))
+	}
+	r.paused = false
+	r.pauseMu.Unlock()
+
+	for _, pr := range parked {
+		if r.getPeer(pr.peer.ID()) != pr.peer {
+			continue
+		}
+		go pr.request()
+	}
+}
+
+// SyncPaused returns true if syncing is paused.
+func (r *Registry) SyncPaused() bool {
+	r.pauseMu.Lock()
+	defer r.pauseMu.Unlock()
+	return r.paused
+}
+
+// park defers the range request to the peer if syncing is paused
+// and returns true if it did
+func (r *Registry) park(p *Peer, request func()) bool {
+	r.pauseMu.Lock()
+	defer r.pauseMu.Unlock()
+	if !r.paused {
+		return false
+	}
+	r.parked = append(r.parked, parkedRequest{peer: p, request: request})
+	return true
+}
+
+// API exposes the control of syncing over RPC
+type API struct {
+	registry *Registry
+}
+
+// NewAPI creates a new API instance
+func NewAPI(r *Registry) *API {
+	return &API{registry: r}
+}
+
+// PauseSync stops requesting new ranges from peers
+func (a *API) PauseSync() {
+	a.registry.PauseSync()
+}
+
+// ResumeSync requests the ranges deferred while syncing was paused
+func (a *API) ResumeSync() {
+	a.registry.ResumeSync()
+}
+
+// SyncPaused returns true if syncing is paused
+func (a *API) SyncPaused() bool {
+	return a.registry.SyncPaused()
+}
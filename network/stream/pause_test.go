@@ -0,0 +1,79 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/network/simulation"
+)
+
+// TestPauseSync tests that no ranges are requested while syncing is paused
+// and that the deferred requests are sent when syncing is resumed
+func TestPauseSync(t *testing.T) {
+	sim := simulation.NewBzzInProc(map[string]simulation.ServiceFunc{
+		serviceNameStream: newSyncSimServiceFunc(&SyncSimServiceOptions{Autostart: true}),
+	}, false)
+	defer sim.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	uploadNode, err := sim.AddNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploadStore := sim.MustNodeItem(uploadNode, bucketKeyFileStore).(chunk.Store)
+	chunkCount := uint64(100)
+	mustUploadChunks(ctx, t, uploadStore, chunkCount)
+
+	syncNode, err := sim.AddNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := nodeRegistry(sim, syncNode)
+	registry.PauseSync()
+	if !registry.SyncPaused() {
+		t.Fatal("expected syncing to be paused")
+	}
+
+	if err := sim.Net.Connect(uploadNode, syncNode); err != nil {
+		t.Fatal(err)
+	}
+	syncStore := sim.MustNodeItem(syncNode, bucketKeyFileStore).(chunk.Store)
+
+	// wait for the stream requests to be parked
+	time.Sleep(time.Second)
+	count, err := getChunkCount(syncStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Fatalf("got %v synced chunks while syncing is paused, want 0", count)
+	}
+
+	registry.ResumeSync()
+	if registry.SyncPaused() {
+		t.Fatal("expected syncing to be resumed")
+	}
+	if err := waitChunks(syncStore, chunkCount, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -17,6 +17,7 @@
 package stream
 
 import (
+	"context"
 	"encoding/hex"
 	"fmt"
 	"sync"
@@ -26,6 +27,7 @@ import (
 	"github.com/ethersphere/swarm/log"
 	"github.com/ethersphere/swarm/network"
 	"github.com/ethersphere/swarm/network/stream/intervals"
+	"github.com/ethersphere/swarm/p2p/protocols"
 	"github.com/ethersphere/swarm/state"
 )
 
@@ -42,12 +44,22 @@ type Peer struct {
 	streamCursors   map[string]uint64 // key: Stream ID string representation, value: session cursor. Keeps cursors for all streams. when unset - we are not interested in that bin
 	openWants       map[uint]*want    // maintain open wants on the client side
 	openOffers      map[uint]offer    // maintain open offers on the server side
+	epochs          map[string]uint64 // key: Stream ID string representation, value: epoch of the session started by the server
 
-	quit chan struct{} // closed when peer is going offline
+	subscriptionsMu sync.Mutex                // synchronize access to subscriptions
+	subscriptions   map[ID]*SubscriptionStats // progress of syncing the streams with the peer
+
+	quit   chan struct{}  // closed when peer is going offline
+	clock  *network.Clock // source of time for timeouts and backoffs
+	legacy bool           // the peer speaks LegacySpec, whose wanted hashes can not request the next range
+
+	requests   *protocols.PeerWorkers // submits the handlers of stream info, range and wanted hashes messages
+	offers     *protocols.PeerWorkers // submits the handlers of offered hashes
+	deliveries *protocols.PeerWorkers // submits the handlers of chunk deliveries
 }
 
 // newPeer is the constructor for Peer
-func newPeer(peer *network.BzzPeer, baseAddress *network.BzzAddr, i state.Store, providers map[string]StreamProvider) *Peer {
+func newPeer(peer *network.BzzPeer, baseAddress *network.BzzAddr, i state.Store, providers map[string]StreamProvider, clock *network.Clock) *Peer {
 	p := &Peer{
 		BzzPeer:        peer,
 		providers:      providers,
@@ -55,7 +67,10 @@ func newPeer(peer *network.BzzPeer, baseAddress *network.BzzAddr, i state.Store,
 		streamCursors:  make(map[string]uint64),
 		openWants:      make(map[uint]*want),
 		openOffers:     make(map[uint]offer),
+		epochs:         make(map[string]uint64),
+		subscriptions:  make(map[ID]*SubscriptionStats),
 		quit:           make(chan struct{}),
+		clock:          clock,
 		logger:         log.NewBaseAddressLogger(baseAddress.ShortString(), "peer", peer.BzzAddr.ShortString()),
 	}
 	return p
@@ -100,6 +115,15 @@ func (p *Peer) deleteCursor(stream ID) {
 	delete(p.streamCursors, stream.String())
 }
 
+// Send sends the message to the peer, in its LegacySpec
+// version if the peer speaks the previous protocol version
+func (p *Peer) Send(ctx context.Context, msg interface{}) error {
+	if p.legacy {
+		msg = downgrade(msg)
+	}
+	return p.BzzPeer.Send(ctx, msg)
+}
+
 // InitProviders initializes a provider for a certain peer
 func (p *Peer) InitProviders() {
 	p.logger.Debug("peer.InitProviders")
@@ -196,6 +220,9 @@ func (p *Peer) sealWant(w *want) error {
 	p.mtx.Lock()
 	delete(p.openWants, w.ruid)
 	p.mtx.Unlock()
+	if err := p.updateLag(w.stream, *w.to); err != nil {
+		p.logger.Error("error updating stream lag", "stream", w.stream, "err", err)
+	}
 	return nil
 }
 
@@ -27,6 +27,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/node"
@@ -64,6 +65,8 @@ var (
 	streamBatchFail               = metrics.GetOrRegisterCounter("network.stream.batch_fail", nil)
 	streamChunkDeliveryFail       = metrics.GetOrRegisterCounter("network.stream.delivery_fail", nil)
 	streamRequestNextIntervalFail = metrics.GetOrRegisterCounter("network.stream.next_interval_fail", nil)
+	streamEpochReset              = metrics.GetOrRegisterCounter("network.stream.epoch_reset", nil)
+	streamEpochDelta              = metrics.GetOrRegisterCounter("network.stream.epoch_delta", nil)
 
 	headBatchSizeGauge = metrics.GetOrRegisterGauge("network.stream.batch_size_head", nil)
 	batchSizeGauge     = metrics.GetOrRegisterGauge("network.stream.batch_size", nil)
@@ -80,7 +83,7 @@ var (
 	// Protocol spec
 	Spec = &protocols.Spec{
 		Name:       "bzz-stream",
-		Version:    8,
+		Version:    10,
 		MaxMsgSize: 10 * 1024 * 1024,
 		Messages: []interface{}{
 			StreamInfoReq{},
@@ -91,8 +94,31 @@ var (
 			WantedHashes{},
 		},
 	}
+
+	// LegacySpec is the spec of the protocol version before the next range
+	// could be requested within wanted hashes. It is still served so that
+	// peers which did not upgrade keep syncing.
+	LegacySpec = &protocols.Spec{
+		Name:       Spec.Name,
+		Version:    8,
+		MaxMsgSize: Spec.MaxMsgSize,
+		Messages: []interface{}{
+			legacyStreamInfoReq{},
+			legacyStreamInfoRes{},
+			GetRange{},
+			OfferedHashes{},
+			ChunkDelivery{},
+			legacyWantedHashes{},
+		},
+	}
 )
 
+// IntervalsSchema is the schema of the stream intervals persisted in the state store,
+// it has no migrations yet
+var IntervalsSchema = state.Schema{
+	Component: "stream",
+}
+
 // Registry is the base type that handles all client/server operations on a node
 // it is instantiated once per stream protocol instance, that is, it should have
 // one instance per node
@@ -108,6 +134,14 @@ type Registry struct {
 	lastReceivedChunkTimeMu sync.RWMutex              // synchronize access to lastReceivedChunkTime
 	lastReceivedChunkTime   time.Time                 // last received chunk time
 	logger                  log.Logger                // the logger for the registry. appends base address to all logs
+	clock                   *network.Clock            // source of time for batch timeouts and sync backoffs
+	requests                *protocols.WorkerPool     // runs the handlers of stream info, range and wanted hashes messages
+	offers                  *protocols.WorkerPool     // runs the handlers of offered hashes, which wait for chunk deliveries
+	deliveries              *protocols.WorkerPool     // runs the handlers of chunk deliveries
+	pauseMu                 sync.Mutex                // synchronize access to paused and parked
+	paused                  bool                      // no new ranges are requested while syncing is paused
+	parked                  []parkedRequest           // range requests deferred while syncing is paused
+	throttler               protocols.Throttler       // delays serving peers with debts close to the disconnect threshold, nil if disabled
 }
 
 // New creates a new stream protocol handler
@@ -120,17 +154,72 @@ func New(intervalsStore state.Store, address *network.BzzAddr, providers ...Stre
 		address:        address,
 		logger:         swarmlog.NewBaseAddressLogger(address.ShortString()),
 		spec:           Spec,
+		clock:          network.NewSystemClock(),
 	}
 	for _, p := range providers {
 		r.providers[p.StreamName()] = p
 	}
+	r.SetWorkers(protocols.NewWorkerPoolParams())
 
 	return r
 }
 
+// SetWorkers sets the limits of the worker pools handling the messages,
+// requests, offered hashes and chunk deliveries have a pool each.
+// It must be called before peers connect.
+func (r *Registry) SetWorkers(params *protocols.WorkerPoolParams) {
+	r.requests = protocols.NewWorkerPool("stream.requests", params)
+	r.offers = protocols.NewWorkerPool("stream.offers", params)
+	r.deliveries = protocols.NewWorkerPool("stream.deliveries", params)
+}
+
+// SetFragmentSize enables splitting messages longer than size into fragments,
+// zero disables it. As fragmentation changes the protocol, all peers must use
+// the same size. It must be called before the protocol is registered.
+func (r *Registry) SetFragmentSize(size uint32) {
+	r.spec = &protocols.Spec{
+		Name:         Spec.Name,
+		Version:      Spec.Version,
+		MaxMsgSize:   Spec.MaxMsgSize,
+		FragmentSize: size,
+		Messages:     Spec.Messages,
+	}
+}
+
+// Spec returns the protocol spec of the registry
+func (r *Registry) Spec() *protocols.Spec {
+	return r.spec
+}
+
+// SetThrottler sets the throttler which delays delivering chunks to peers
+// with debts close to the disconnect threshold, it must be called before peers connect
+func (r *Registry) SetThrottler(throttler protocols.Throttler) {
+	r.throttler = throttler
+}
+
+// SetClock sets the source of time of the registry and its peers,
+// it must be called before peers connect
+func (r *Registry) SetClock(clock *network.Clock) {
+	r.clock = clock
+}
+
 // Run is being dispatched when 2 nodes connect
 func (r *Registry) Run(bp *network.BzzPeer) error {
-	sp := newPeer(bp, r.address, r.intervalsStore, r.providers)
+	return r.run(bp, false)
+}
+
+// RunLegacy is dispatched when 2 nodes connect over LegacySpec
+func (r *Registry) RunLegacy(bp *network.BzzPeer) error {
+	return r.run(bp, true)
+}
+
+// run runs the protocol with a peer, which speaks LegacySpec if legacy
+func (r *Registry) run(bp *network.BzzPeer, legacy bool) error {
+	sp := newPeer(bp, r.address, r.intervalsStore, r.providers, r.clock)
+	sp.legacy = legacy
+	sp.requests = r.requests.NewPeerWorkers(sp.quit)
+	sp.offers = r.offers.NewPeerWorkers(sp.quit)
+	sp.deliveries = r.deliveries.NewPeerWorkers(sp.quit)
 	r.addPeer(sp)
 	defer r.removePeer(sp)
 
@@ -143,14 +232,15 @@ func (r *Registry) Run(bp *network.BzzPeer) error {
 func (r *Registry) HandleMsg(p *Peer) func(context.Context, interface{}) error {
 	return func(ctx context.Context, msg interface{}) error {
 		r.mtx.Lock() // ensure that quit read and handlersWg add are locked together
-		defer r.mtx.Unlock()
 
 		select {
 		case <-r.quit:
 			// no message handling if we quit
+			r.mtx.Unlock()
 			return nil
 		case <-p.quit:
 			// peer has been removed, quit
+			r.mtx.Unlock()
 			return nil
 		default:
 		}
@@ -166,71 +256,121 @@ func (r *Registry) HandleMsg(p *Peer) func(context.Context, interface{}) error {
 		}
 
 		r.handlersWg.Add(1)
-		go func() {
+		r.mtx.Unlock()
+
+		// messages of peers speaking LegacySpec are handled as their current versions
+		msg = upgrade(msg)
+
+		// live ranges wait for new chunks without a timeout,
+		// so they are not limited by a pool
+		if requestsLiveRange(msg) {
+			go func() {
+				defer r.handlersWg.Done()
+				r.handleMsg(ctx, p, msg)
+			}()
+			return nil
+		}
+		// handlers waiting for other messages, like offered hashes waiting for
+		// chunk deliveries, have a pool of their own so that they cannot take
+		// all the workers needed by the handlers they wait for.
+		// None of the messages may be dropped, submitting blocks reading from
+		// the peer while it has too many of them waiting or running. Offered
+		// hashes are bounded by the ranges requested from the peer, so reading
+		// the deliveries their handlers wait for is not blocked by them.
+		workers := p.requests
+		switch msg.(type) {
+		case *OfferedHashes:
+			workers = p.offers
+		case *ChunkDelivery:
+			workers = p.deliveries
+		}
+		err := workers.Submit(func() {
 			defer r.handlersWg.Done()
+			r.handleMsg(ctx, p, msg)
+		})
+		if err != nil {
+			// the peer or the node is shutting down
+			r.handlersWg.Done()
+			p.logger.Debug("stream message not handled", "err", err)
+		}
+		return nil
+	}
+}
 
-			switch msg := msg.(type) {
-			case *StreamInfoReq:
-				r.serverHandleStreamInfoReq(ctx, p, msg)
-			case *StreamInfoRes:
-				if len(msg.Streams) == 0 {
-					p.logger.Error("StreamInfo response is empty")
-					p.Drop("StreamInfo response is empty")
-					return
-				}
+// requestsLiveRange returns true if handling the message
+// serves a live range to the peer
+func requestsLiveRange(msg interface{}) bool {
+	switch msg := msg.(type) {
+	case *GetRange:
+		return msg.To == nil
+	case *WantedHashes:
+		// the subsequent range may be requested together with the wanted hashes
+		return msg.Next != nil && msg.Next.To == nil
+	}
+	return false
+}
 
-				r.clientHandleStreamInfoRes(ctx, p, msg)
-			case *GetRange:
-				provider := r.getProvider(msg.Stream)
-				if provider == nil {
-					p.logger.Error("unsupported provider", "stream", msg.Stream)
-					p.Drop("unsupported provider")
-					return
-				}
-				r.serverHandleGetRange(ctx, p, msg, provider)
-			case *OfferedHashes:
-				// get the existing want for ruid from peer, otherwise drop
-				w, exit := p.getWantOrDrop(msg.Ruid)
-				if exit {
-					return
-				}
-				provider := r.getProvider(w.stream)
-				if provider == nil {
-					p.logger.Error("unsupported provider", "stream", w.stream)
-					p.Drop("unsupported provider")
-					return
-				}
-				r.clientHandleOfferedHashes(ctx, p, msg, w, provider)
-			case *WantedHashes:
-				// get the existing offer for ruid from peer, otherwise drop
-				o, exit := p.getOfferOrDrop(msg.Ruid)
-				if exit {
-					return
-				}
-				provider := r.getProvider(o.stream)
-				if provider == nil {
-					p.logger.Error("unsupported provider", "stream", o.stream)
-					p.Drop("unsupported provider")
-					return
-				}
-				r.serverHandleWantedHashes(ctx, p, msg, o, provider)
-			case *ChunkDelivery:
-				// get the existing want for ruid from peer, otherwise drop
-				w, exit := p.getWantOrDrop(msg.Ruid)
-				if exit {
-					streamChunkDeliveryFail.Inc(1)
-					return
-				}
-				provider := r.getProvider(w.stream)
-				if provider == nil {
-					p.logger.Error("unsupported provider", "stream", w.stream)
-					p.Drop("unsupported provider")
-					return
-				}
-				r.clientHandleChunkDelivery(ctx, p, msg, w, provider)
-			}
-		}()
-		return nil
+// handleMsg dispatches the message to its handler
+func (r *Registry) handleMsg(ctx context.Context, p *Peer, msg interface{}) {
+	switch msg := msg.(type) {
+	case *StreamInfoReq:
+		r.serverHandleStreamInfoReq(ctx, p, msg)
+	case *StreamInfoRes:
+		if len(msg.Streams) == 0 {
+			p.logger.Error("StreamInfo response is empty")
+			p.Drop("StreamInfo response is empty")
+			return
+		}
+
+		r.clientHandleStreamInfoRes(ctx, p, msg)
+	case *GetRange:
+		provider := r.getProvider(msg.Stream)
+		if provider == nil {
+			p.logger.Error("unsupported provider", "stream", msg.Stream)
+			p.Drop("unsupported provider")
+			return
+		}
+		r.serverHandleGetRange(ctx, p, msg, provider)
+	case *OfferedHashes:
+		// get the existing want for ruid from peer, otherwise drop
+		w, exit := p.getWantOrDrop(msg.Ruid)
+		if exit {
+			return
+		}
+		provider := r.getProvider(w.stream)
+		if provider == nil {
+			p.logger.Error("unsupported provider", "stream", w.stream)
+			p.Drop("unsupported provider")
+			return
+		}
+		r.clientHandleOfferedHashes(ctx, p, msg, w, provider)
+	case *WantedHashes:
+		// get the existing offer for ruid from peer, otherwise drop
+		o, exit := p.getOfferOrDrop(msg.Ruid)
+		if exit {
+			return
+		}
+		provider := r.getProvider(o.stream)
+		if provider == nil {
+			p.logger.Error("unsupported provider", "stream", o.stream)
+			p.Drop("unsupported provider")
+			return
+		}
+		r.serverHandleWantedHashes(ctx, p, msg, o, provider)
+	case *ChunkDelivery:
+		// get the existing want for ruid from peer, otherwise drop
+		w, exit := p.getWantOrDrop(msg.Ruid)
+		if exit {
+			streamChunkDeliveryFail.Inc(1)
+			return
+		}
+		provider := r.getProvider(w.stream)
+		if provider == nil {
+			p.logger.Error("unsupported provider", "stream", w.stream)
+			p.Drop("unsupported provider")
+			return
+		}
+		r.clientHandleChunkDelivery(ctx, p, msg, w, provider)
 	}
 }
 
@@ -254,7 +394,7 @@ func (r *Registry) serverHandleStreamInfoReq(ctx context.Context, p *Peer, msg *
 	}
 
 	streamRes := &StreamInfoRes{}
-	for _, v := range msg.Streams {
+	for i, v := range msg.Streams {
 		provider := r.getProvider(v)
 		if provider == nil {
 			p.logger.Error("unsupported provider", "stream", v)
@@ -270,9 +410,23 @@ func (r *Registry) serverHandleStreamInfoReq(ctx context.Context, p *Peer, msg *
 			p.Drop("error getting cursor")
 			return
 		}
+		// every request starts a new epoch of syncing the stream with the peer,
+		// only the chunks since the last epoch it completed need to be synced
+		var completed uint64
+		if i < len(msg.Epochs) {
+			completed = msg.Epochs[i]
+		}
+		epoch, since, err := p.startServedEpoch(v, completed, streamCursor)
+		if err != nil {
+			p.logger.Error("error starting stream epoch", "stream", v, "err", err)
+			p.Drop("error starting stream epoch")
+			return
+		}
 		descriptor := StreamDescriptor{
 			Stream:  v,
 			Cursor:  streamCursor,
+			Epoch:   epoch,
+			Since:   since,
 			Bounded: provider.Boundedness(),
 		}
 		streamRes.Streams = append(streamRes.Streams, descriptor)
@@ -325,8 +479,26 @@ func (r *Registry) clientHandleStreamInfoRes(ctx context.Context, p *Peer, msg *
 			continue
 		}
 
+		// only the chunks since the last completed epoch are requested
+		reset, err := p.startEpoch(s.Stream, s.Epoch, s.Since)
+		if err != nil {
+			p.logger.Error("error starting stream epoch", "stream", s.Stream, "epoch", s.Epoch, "err", err)
+			p.Drop("error starting stream epoch")
+			return
+		}
+		if reset {
+			streamEpochReset.Inc(1)
+			p.logger.Debug("stream epochs counted anew, synced intervals reset", "stream", s.Stream, "epoch", s.Epoch)
+		} else if s.Since > 0 {
+			streamEpochDelta.Inc(1)
+			p.logger.Debug("syncing stream since the last completed epoch", "stream", s.Stream, "epoch", s.Epoch, "since", s.Since)
+		}
+
 		p.logger.Debug("setting stream cursor", "stream", s.Stream, "cursor", s.Cursor)
 		p.setCursor(s.Stream, s.Cursor)
+		if err := p.updateLag(s.Stream, s.Cursor); err != nil {
+			p.logger.Error("error updating stream lag", "stream", s.Stream, "err", err)
+		}
 
 		if provider.Autostart() {
 			// don't request historical ranges for streams with cursor == 0
@@ -334,19 +506,22 @@ func (r *Registry) clientHandleStreamInfoRes(ctx context.Context, p *Peer, msg *
 				p.logger.Debug("requesting history stream", "stream", s.Stream, "cursor", s.Cursor)
 				// fetch everything from beginning till s.Cursor
 
-				go func() {
+				request := func() {
 					err := r.clientRequestStreamRange(ctx, p, provider, s.Stream, s.Cursor)
 					if err != nil {
 						p.logger.Error("had an error sending initial GetRange for historical stream", "stream", s.Stream, "err", err)
 						p.Drop("error sending initial GetRange for historical stream")
 					}
-				}()
+				}
+				if !r.park(p, request) {
+					go request()
+				}
 			}
 
 			// handle stream unboundedness
 			if !s.Bounded {
 				//constantly fetch the head of the stream
-				go func() {
+				request := func() {
 					p.logger.Debug("asking for live stream", "stream", s.Stream, "cursor", s.Cursor)
 
 					// ask the tip (cursor + 1)
@@ -356,7 +531,10 @@ func (r *Registry) clientHandleStreamInfoRes(ctx context.Context, p *Peer, msg *
 						p.logger.Error("had an error with initial stream head fetch", "stream", s.Stream, "cursor", s.Cursor+1, "err", err)
 						p.Drop("had an error with initial stream head fetch")
 					}
-				}()
+				}
+				if !r.park(p, request) {
+					go request()
+				}
 			}
 		}
 	}
@@ -384,13 +562,19 @@ func (r *Registry) clientRequestStreamRange(ctx context.Context, p *Peer, provid
 	// nothing to do - the next interval is bigger than the cursor or theinterval is empty
 	if from > cursor || empty {
 		p.logger.Debug("peer.requestStreamRange stream finished", "stream", stream, "cursor", cursor)
-		return nil
+		return p.completeEpoch(stream)
 	}
 	return r.clientCreateSendWant(ctx, p, stream, from, &cursor, false)
 }
 
 func (r *Registry) clientCreateSendWant(ctx context.Context, p *Peer, stream ID, from uint64, to *uint64, head bool) error {
-	g := GetRange{
+	return p.Send(ctx, r.clientCreateWant(p, stream, from, to, head))
+}
+
+// clientCreateWant registers an open want for the given range and returns
+// the GetRange message requesting it from the server
+func (r *Registry) clientCreateWant(p *Peer, stream ID, from uint64, to *uint64, head bool) *GetRange {
+	g := &GetRange{
 		Ruid:      uint(rand.Uint32()),
 		Stream:    stream,
 		From:      from,
@@ -413,7 +597,7 @@ func (r *Registry) clientCreateSendWant(ctx context.Context, p *Peer, stream ID,
 	}
 	p.mtx.Unlock()
 
-	return p.Send(ctx, g)
+	return g
 }
 
 // serverHandleGetRange is handled by the server and sends in response an OfferedHashes message
@@ -477,14 +661,12 @@ func (r *Registry) serverHandleGetRange(ctx context.Context, p *Peer, msg *GetRa
 	}
 
 	// store the offer for the peer
-	p.mtx.Lock()
-	p.openOffers[msg.Ruid] = offer{
+	p.addOffer(offer{
 		ruid:      msg.Ruid,
 		stream:    msg.Stream,
 		hashes:    h,
 		requested: time.Now(),
-	}
-	p.mtx.Unlock()
+	})
 
 	offered := OfferedHashes{
 		Ruid:      msg.Ruid,
@@ -499,9 +681,7 @@ func (r *Registry) serverHandleGetRange(ctx context.Context, p *Peer, msg *GetRa
 	}
 	if err := p.Send(ctx, offered); err != nil {
 		p.logger.Error("erroring sending offered hashes", "ruid", msg.Ruid, "err", err)
-		p.mtx.Lock()
-		delete(p.openOffers, msg.Ruid)
-		p.mtx.Unlock()
+		p.deleteOffer(msg.Ruid)
 		p.Drop("error sending offered hashes")
 	}
 }
@@ -539,7 +719,7 @@ func (r *Registry) clientHandleOfferedHashes(ctx context.Context, p *Peer, msg *
 			p.Drop("error persisting interval")
 			return
 		}
-		r.requestSubsequentRange(ctx, p, provider, w, msg.LastIndex)
+		r.requestSubsequentRange(ctx, p, w, msg.LastIndex)
 		return
 	}
 
@@ -596,6 +776,7 @@ func (r *Registry) clientHandleOfferedHashes(ctx context.Context, p *Peer, msg *
 
 	// this handles the case that there are no hashes we are interested in
 	// we then seal the current interval and request the next batch
+	// within the same message, saving a separate GetRange message
 	if ctr == 0 {
 		streamEmptyWantedHashes.Inc(1)
 		wantedHashesMsg.BitVector = []byte{} // set the bitvector value to an empty slice, this is to signal the server we dont want any hashes
@@ -604,6 +785,18 @@ func (r *Registry) clientHandleOfferedHashes(ctx context.Context, p *Peer, msg *
 			p.Drop("error persisting interval")
 			return
 		}
+		// while syncing is paused the next range is requested on resume,
+		// and peers speaking LegacySpec need a separate GetRange message
+		if !p.legacy && !r.park(p, func() { r.requestSubsequentRange(ctx, p, w, msg.LastIndex) }) {
+			next, err := r.subsequentRange(p, w, msg.LastIndex)
+			if err != nil {
+				streamRequestNextIntervalFail.Inc(1)
+				p.logger.Error("error requesting next interval from peer", "err", err)
+				p.Drop("error requesting next interval from peer")
+				return
+			}
+			wantedHashesMsg.Next = next
+		}
 	} else {
 		// we want some hashes
 		streamWantedHashes.Inc(1)
@@ -612,14 +805,17 @@ func (r *Registry) clientHandleOfferedHashes(ctx context.Context, p *Peer, msg *
 		errc = r.clientSealBatch(ctx, p, provider, w) // poll for the completion of the batch in a separate goroutine
 	}
 
+	wanted := time.Now()
 	if err := p.Send(ctx, wantedHashesMsg); err != nil {
 		p.logger.Error("error sending wanted hashes", "err", err)
 		p.Drop("error sending wanted hashes")
 		return
 	}
 	if ctr == 0 {
-		// request the next range in case no chunks wanted
-		r.requestSubsequentRange(ctx, p, provider, w, msg.LastIndex)
+		// the next range, if any, was requested with the wanted hashes message
+		if p.legacy {
+			r.requestSubsequentRange(ctx, p, w, msg.LastIndex)
+		}
 		return
 	}
 	select {
@@ -630,6 +826,7 @@ func (r *Registry) clientHandleOfferedHashes(ctx context.Context, p *Peer, msg *
 			p.Drop("error while sealing batch")
 			return
 		}
+		p.batchDelivered(w.stream, time.Since(wanted))
 
 		// seal the interval
 		if err := p.sealWant(w); err != nil {
@@ -637,7 +834,7 @@ func (r *Registry) clientHandleOfferedHashes(ctx context.Context, p *Peer, msg *
 			p.Drop("error persisting interval")
 			return
 		}
-	case <-time.After(timeouts.SyncBatchTimeout):
+	case <-p.clock.After(timeouts.SyncBatchTimeout):
 		p.logger.Error("batch has timed out", "ruid", w.ruid)
 		close(w.closeC) // signal the polling goroutine to terminate
 		p.mtx.Lock()
@@ -659,7 +856,7 @@ func (r *Registry) clientHandleOfferedHashes(ctx context.Context, p *Peer, msg *
 	case <-p.quit:
 		return
 	}
-	r.requestSubsequentRange(ctx, p, provider, w, msg.LastIndex)
+	r.requestSubsequentRange(ctx, p, w, msg.LastIndex)
 }
 
 // serverHandleWantedHashes is handled on the server side (Peer is the client) and is dependent on a preceding OfferedHashes message
@@ -671,11 +868,7 @@ func (r *Registry) serverHandleWantedHashes(ctx context.Context, p *Peer, msg *W
 		metrics.GetOrRegisterResettingTimer("network.stream.handle_wanted_hashes.total-time", nil).UpdateSince(start)
 	}(start)
 
-	defer func() {
-		p.mtx.Lock()
-		delete(p.openOffers, msg.Ruid)
-		p.mtx.Unlock()
-	}()
+	defer p.deleteOffer(msg.Ruid)
 
 	var (
 		l          = len(o.hashes) / HashSize
@@ -695,6 +888,16 @@ func (r *Registry) serverHandleWantedHashes(ctx context.Context, p *Peer, msg *W
 			p.Drop("error setting chunk as synced")
 			return
 		}
+		// the client might have requested the subsequent range in the same message
+		if msg.Next != nil {
+			if msg.Next.Stream != o.stream {
+				p.logger.Error("next range requested on a different stream", "stream", msg.Next.Stream, "offered", o.stream)
+				p.Drop("next range requested on a different stream")
+				return
+			}
+			p.deleteOffer(msg.Ruid)
+			r.serverHandleGetRange(ctx, p, msg.Next, provider)
+		}
 		return
 	}
 	want, err := bv.NewFromBytes(msg.BitVector, l)
@@ -718,6 +921,22 @@ func (r *Registry) serverHandleWantedHashes(ctx context.Context, p *Peer, msg *W
 		}
 		allHashes[i] = hash
 	}
+
+	// peers with debts close to the disconnect threshold are served later
+	if r.throttler != nil && len(wantHashes) > 0 {
+		if delay := r.throttler.Throttle(p.ID()); delay > 0 {
+			metrics.GetOrRegisterCounter("network.stream.handle_wanted.throttled", nil).Inc(1)
+			select {
+			case <-time.After(delay):
+			case <-p.quit:
+				return
+			case <-r.quit:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
 	startGet := time.Now()
 
 	// get the chunks from the provider
@@ -899,8 +1118,8 @@ func (r *Registry) serverCollectBatch(ctx context.Context, p *Peer, provider Str
 		batchSize    int
 		batchStartID *uint64
 		batchEndID   uint64
-		timer        *time.Timer
-		timerC       <-chan time.Time
+		timer        mclock.Event
+		timerC       chan struct{}
 	)
 
 	defer func(start time.Time) {
@@ -910,7 +1129,7 @@ func (r *Registry) serverCollectBatch(ctx context.Context, p *Peer, provider Str
 			collectBatchHistoryTimer.UpdateSince(start)
 		}
 		if timer != nil {
-			timer.Stop()
+			timer.Cancel()
 		}
 	}(time.Now())
 
@@ -936,15 +1155,14 @@ func (r *Registry) serverCollectBatch(ctx context.Context, p *Peer, provider Str
 				iterate = false
 				metrics.GetOrRegisterCounter("network.stream.server_collect_batch.full-batch", nil).Inc(1)
 			}
-			if timer == nil {
-				timer = time.NewTimer(timeouts.BatchTimeout)
-			} else {
-				if !timer.Stop() {
-					<-timer.C
-				}
-				timer.Reset(timeouts.BatchTimeout)
+			// restart the timer, a new channel guarantees that
+			// an expiry of the cancelled timer is not received
+			if timer != nil {
+				timer.Cancel()
 			}
-			timerC = timer.C
+			expired := make(chan struct{})
+			timer = p.clock.AfterFunc(timeouts.BatchTimeout, func() { close(expired) })
+			timerC = expired
 		case <-timerC:
 			// return batch if new chunks are not received after some time
 			iterate = false
@@ -963,7 +1181,24 @@ func (r *Registry) serverCollectBatch(ctx context.Context, p *Peer, provider Str
 }
 
 // requestSubsequentRange checks the cursor for the current stream, and in case needed - requests the next range
-func (r *Registry) requestSubsequentRange(ctx context.Context, p *Peer, provider StreamProvider, w *want, lastIndex uint64) {
+func (r *Registry) requestSubsequentRange(ctx context.Context, p *Peer, w *want, lastIndex uint64) {
+	if r.park(p, func() { r.requestSubsequentRange(ctx, p, w, lastIndex) }) {
+		return
+	}
+	g, err := r.subsequentRange(p, w, lastIndex)
+	if err == nil && g != nil {
+		err = p.Send(ctx, g)
+	}
+	if err != nil {
+		streamRequestNextIntervalFail.Inc(1)
+		p.logger.Error("error requesting next interval from peer", "err", err)
+		p.Drop("error requesting next interval from peer")
+	}
+}
+
+// subsequentRange checks the cursor for the current stream and creates the want for the next range.
+// it returns the GetRange message to be sent to the server, or nil if no range needs to be requested
+func (r *Registry) subsequentRange(p *Peer, w *want, lastIndex uint64) (*GetRange, error) {
 	cur, ok := p.getCursor(w.stream)
 	if !ok {
 		metrics.GetOrRegisterCounter("network.stream.quit_unwanted", nil).Inc(1)
@@ -971,23 +1206,24 @@ func (r *Registry) requestSubsequentRange(ctx context.Context, p *Peer, provider
 		p.mtx.Lock()
 		delete(p.openWants, w.ruid)
 		p.mtx.Unlock()
-		return
+		return nil, nil
 	}
 	if w.head {
-		if err := r.clientRequestStreamHead(ctx, p, w.stream, lastIndex+1); err != nil {
-			streamRequestNextIntervalFail.Inc(1)
-			p.logger.Error("error requesting next interval from peer", "err", err)
-			p.Drop("error requesting next interval from peer")
-			return
-		}
-	} else {
-		if err := r.clientRequestStreamRange(ctx, p, provider, w.stream, cur); err != nil {
-			streamRequestNextIntervalFail.Inc(1)
-			p.logger.Error("error requesting next interval from peer", "err", err)
-			p.Drop("error requesting next interval from peer")
-			return
-		}
+		p.logger.Debug("clientRequestStreamHead", "stream", w.stream, "from", lastIndex+1)
+		return r.clientCreateWant(p, w.stream, lastIndex+1, nil, true), nil
+	}
+
+	// get the next interval from the intervals store
+	from, _, empty, err := p.nextInterval(w.stream, 0)
+	if err != nil {
+		return nil, err
+	}
+	// nothing to do - the next interval is bigger than the cursor or the interval is empty
+	if from > cur || empty {
+		p.logger.Debug("peer.requestStreamRange stream finished", "stream", w.stream, "cursor", cur)
+		return nil, p.completeEpoch(w.stream)
 	}
+	return r.clientCreateWant(p, w.stream, from, &cur, false), nil
 }
 
 func (r *Registry) getProvider(stream ID) StreamProvider {
@@ -1019,6 +1255,7 @@ func (r *Registry) removePeer(p *Peer) {
 		p.logger.Error("removing peer")
 		delete(r.peers, p.ID())
 		close(p.quit)
+		p.closeSubscriptions()
 	}
 	streamPeersCount.Update(int64(len(r.peers)))
 }
@@ -1119,7 +1356,14 @@ func (r *Registry) runProtocol(p *p2p.Peer, rw p2p.MsgReadWriter) error {
 }
 
 func (r *Registry) APIs() []rpc.API {
-	return nil
+	return []rpc.API{
+		{
+			Namespace: "stream",
+			Version:   "1.0",
+			Service:   NewAPI(r),
+			Public:    false,
+		},
+	}
 }
 
 func (r *Registry) Start(server *p2p.Server) error {
@@ -1134,6 +1378,9 @@ func (r *Registry) Stop() error {
 	defer r.mtx.Unlock()
 
 	close(r.quit)
+	r.requests.Stop()
+	r.offers.Stop()
+	r.deliveries.Stop()
 	// wait for all handlers to finish
 	done := make(chan struct{})
 	go func() {
@@ -0,0 +1,182 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/network/stream/intervals"
+	"github.com/ethersphere/swarm/state"
+)
+
+var (
+	pendingOfferedCount = metrics.GetOrRegisterCounter("network.stream.pending_offered", nil)
+	batchRoundTripTimer = metrics.GetOrRegisterResettingTimer("network.stream.batch_round_trip", nil)
+)
+
+// SubscriptionStats is the progress of syncing a stream with a peer, the
+// pending offered hashes as the server of the stream, the round trip and the
+// lag as its client. For the sync streams, which are by bin, the lag is the
+// number of chunks in the bin of the peer which are not synced yet.
+type SubscriptionStats struct {
+	Peer           string        `json:"peer"`           // the peer address
+	Stream         string        `json:"stream"`         // the stream
+	PendingOffered int           `json:"pendingOffered"` // number of hashes offered to the peer which it did not answer yet
+	RoundTrip      time.Duration `json:"roundTrip"`      // time between asking the peer for the chunks of the last batch and receiving them all
+	Top            uint64        `json:"top"`            // highest index of the stream known on the peer
+	Lag            uint64        `json:"lag"`            // number of indexes of the stream up to top which are not synced
+}
+
+// subscriptionMetric returns the name of the metric of the stream with the peer
+func (p *Peer) subscriptionMetric(stream ID, name string) string {
+	return fmt.Sprintf("network.stream.subscription.%s.%s.%s.%s", p.BzzAddr.ShortOver(), stream.Name, stream.Key, name)
+}
+
+// subscription returns the stats of the stream with the peer, creating them if there are none
+// the caller is expected to hold p.subscriptionsMu
+func (p *Peer) subscription(stream ID) *SubscriptionStats {
+	s, ok := p.subscriptions[stream]
+	if !ok {
+		s = &SubscriptionStats{
+			Peer:   p.BzzAddr.ShortOver(),
+			Stream: stream.String(),
+		}
+		p.subscriptions[stream] = s
+	}
+	return s
+}
+
+// offered records that the number of hashes offered to the peer on the
+// stream changed by delta
+func (p *Peer) offered(stream ID, delta int) {
+	p.subscriptionsMu.Lock()
+	defer p.subscriptionsMu.Unlock()
+
+	s := p.subscription(stream)
+	s.PendingOffered += delta
+	pendingOfferedCount.Inc(int64(delta))
+	metrics.GetOrRegisterGauge(p.subscriptionMetric(stream, "pending_offered"), nil).Update(int64(s.PendingOffered))
+}
+
+// addOffer stores an open offer to the peer
+func (p *Peer) addOffer(o offer) {
+	p.mtx.Lock()
+	p.openOffers[o.ruid] = o
+	p.mtx.Unlock()
+	p.offered(o.stream, len(o.hashes)/HashSize)
+}
+
+// deleteOffer removes an open offer to the peer if it is still open
+func (p *Peer) deleteOffer(ruid uint) {
+	p.mtx.Lock()
+	o, ok := p.openOffers[ruid]
+	delete(p.openOffers, ruid)
+	p.mtx.Unlock()
+	if ok {
+		p.offered(o.stream, -len(o.hashes)/HashSize)
+	}
+}
+
+// batchDelivered records the time between asking the peer for the chunks of a batch on the stream and receiving them all
+func (p *Peer) batchDelivered(stream ID, roundTrip time.Duration) {
+	p.subscriptionsMu.Lock()
+	defer p.subscriptionsMu.Unlock()
+
+	p.subscription(stream).RoundTrip = roundTrip
+	batchRoundTripTimer.Update(roundTrip)
+	metrics.GetOrRegisterResettingTimer(p.subscriptionMetric(stream, "round_trip"), nil).Update(roundTrip)
+}
+
+// updateLag raises the highest index of the stream known on the peer to top
+// and updates the number of indexes up to it which are not synced
+func (p *Peer) updateLag(stream ID, top uint64) error {
+	i := intervals.NewIntervals(1)
+	p.mtx.RLock()
+	err := p.intervalsStore.Get(p.peerStreamIntervalKey(stream), i)
+	p.mtx.RUnlock()
+	if err != nil && err != state.ErrNotFound {
+		return err
+	}
+
+	p.subscriptionsMu.Lock()
+	defer p.subscriptionsMu.Unlock()
+
+	s := p.subscription(stream)
+	if top > s.Top {
+		s.Top = top
+	}
+	s.Lag = i.Missing(s.Top)
+	metrics.GetOrRegisterGauge(p.subscriptionMetric(stream, "lag"), nil).Update(int64(s.Lag))
+	return nil
+}
+
+// subscriptionStats returns the stats of the streams with the peer ordered by stream
+func (p *Peer) subscriptionStats() []SubscriptionStats {
+	p.subscriptionsMu.Lock()
+	defer p.subscriptionsMu.Unlock()
+
+	stats := make([]SubscriptionStats, 0, len(p.subscriptions))
+	for _, s := range p.subscriptions {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Stream < stats[j].Stream
+	})
+	return stats
+}
+
+// closeSubscriptions unregisters the metrics of the streams with the
+// peer, the hashes still offered to it are no longer pending
+func (p *Peer) closeSubscriptions() {
+	p.subscriptionsMu.Lock()
+	defer p.subscriptionsMu.Unlock()
+
+	for stream, s := range p.subscriptions {
+		pendingOfferedCount.Dec(int64(s.PendingOffered))
+		for _, name := range []string{"pending_offered", "round_trip", "lag"} {
+			metrics.DefaultRegistry.Unregister(p.subscriptionMetric(stream, name))
+		}
+	}
+	p.subscriptions = make(map[ID]*SubscriptionStats)
+}
+
+// Subscriptions returns the progress of syncing the streams with the connected peers
+func (r *Registry) Subscriptions() []SubscriptionStats {
+	r.mtx.RLock()
+	peers := make([]*Peer, 0, len(r.peers))
+	for _, p := range r.peers {
+		peers = append(peers, p)
+	}
+	r.mtx.RUnlock()
+
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].BzzAddr.ShortOver() < peers[j].BzzAddr.ShortOver()
+	})
+	var stats []SubscriptionStats
+	for _, p := range peers {
+		stats = append(stats, p.subscriptionStats()...)
+	}
+	return stats
+}
+
+// Subscriptions returns the progress of syncing the streams with the connected peers
+func (a *API) Subscriptions() []SubscriptionStats {
+	return a.registry.Subscriptions()
+}
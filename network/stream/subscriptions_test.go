@@ -0,0 +1,97 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/network/simulation"
+)
+
+// TestSubscriptions tests that the stats of the streams synced from a peer
+// report the chunks of the peer as known and no lag once they are synced
+func TestSubscriptions(t *testing.T) {
+	sim := simulation.NewBzzInProc(map[string]simulation.ServiceFunc{
+		serviceNameStream: newSyncSimServiceFunc(&SyncSimServiceOptions{Autostart: true}),
+	}, false)
+	defer sim.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	uploadNode, err := sim.AddNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploadStore := sim.MustNodeItem(uploadNode, bucketKeyFileStore).(chunk.Store)
+	chunkCount := uint64(100)
+	mustUploadChunks(ctx, t, uploadStore, chunkCount)
+
+	syncNode, err := sim.AddNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sim.Net.Connect(uploadNode, syncNode); err != nil {
+		t.Fatal(err)
+	}
+	syncStore := sim.MustNodeItem(syncNode, bucketKeyFileStore).(chunk.Store)
+	if err := waitChunks(syncStore, chunkCount, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := nodeRegistry(sim, syncNode)
+	check := func() error {
+		var top, lag uint64
+		var roundTrip time.Duration
+		for _, s := range registry.Subscriptions() {
+			top += s.Top
+			lag += s.Lag
+			roundTrip += s.RoundTrip
+		}
+		if top != chunkCount {
+			return fmt.Errorf("got %d chunks known on the peer, want %d", top, chunkCount)
+		}
+		if lag != 0 {
+			return fmt.Errorf("got a lag of %d chunks, want 0", lag)
+		}
+		if roundTrip == 0 {
+			return fmt.Errorf("got no batch round trip")
+		}
+		return nil
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := check()
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	for _, s := range nodeRegistry(sim, uploadNode).Subscriptions() {
+		if s.PendingOffered != 0 {
+			t.Errorf("got %d hashes pending on stream %s, want 0", s.PendingOffered, s.Stream)
+		}
+	}
+}
@@ -310,11 +310,8 @@ var (
 // peer connects and disconnects quickly
 func (s *syncProvider) InitPeer(p *Peer) {
 	p.logger.Debug("syncProvider.InitPeer")
-	timer := time.NewTimer(SyncInitBackoff)
-	defer timer.Stop()
-
 	select {
-	case <-timer.C:
+	case <-p.clock.After(SyncInitBackoff):
 	case <-p.quit:
 		return
 	case <-s.quit:
@@ -373,6 +370,7 @@ func (s *syncProvider) updateSyncSubscriptions(p *Peer, subBins, quitBins []int)
 	p.logger.Debug("syncProvider.updateSyncSubscriptions", "subBins", subBins, "quitBins", quitBins)
 	if l := len(subBins); l > 0 {
 		streams := make([]ID, l)
+		epochs := make([]uint64, l)
 		for i, po := range subBins {
 
 			stream := NewID(s.StreamName(), encodeSyncKey(uint8(po)))
@@ -382,10 +380,15 @@ func (s *syncProvider) updateSyncSubscriptions(p *Peer, subBins, quitBins []int)
 			}
 
 			streams[i] = stream
+			// the peer serves only the chunks since the last epoch synced completely
+			epochs[i], err = p.completedEpoch(stream)
+			if err != nil {
+				p.logger.Error("got an error while trying to get the completed epoch", "stream", stream, "err", err)
+			}
 		}
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
-		if err := p.Send(ctx, &StreamInfoReq{Streams: streams}); err != nil {
+		if err := p.Send(ctx, &StreamInfoReq{Streams: streams, Epochs: epochs}); err != nil {
 			p.logger.Error("error establishing subsequent subscription", "err", err)
 			p.Drop("error establishing subsequent subscription")
 			return
@@ -82,6 +82,7 @@ type StreamProvider interface {
 // StreamInfoReq is a request to get information about particular streams
 type StreamInfoReq struct {
 	Streams []ID
+	Epochs  []uint64 // last epoch of each of the streams synced completely from the peer, 0 if none
 }
 
 // StreamInfoRes is a response to StreamInfoReq with the corresponding stream descriptors
@@ -93,6 +94,8 @@ type StreamInfoRes struct {
 type StreamDescriptor struct {
 	Stream  ID
 	Cursor  uint64
+	Epoch   uint64 // epoch of syncing the stream started by the request, 0 if epochs are not counted
+	Since   uint64 // cursor at which the last completed epoch started, 0 if unknown
 	Bounded bool
 }
 
@@ -115,10 +118,75 @@ type OfferedHashes struct {
 }
 
 // WantedHashes is a message sent from the downstream peer to the upstream peer in response
-// to OfferedHashes in order to selectively ask for a particular chunks within an interval
+// to OfferedHashes in order to selectively ask for a particular chunks within an interval.
+// The wanted chunks are marked in a bitvector over the offered batch. When no chunks are
+// wanted, the bitvector is empty and Next may carry the request for the subsequent range,
+// sparing a separate GetRange message
 type WantedHashes struct {
 	Ruid      uint
 	BitVector []byte
+	Next      *GetRange `rlp:"nil"`
+}
+
+// legacyStreamInfoReq is the StreamInfoReq of LegacySpec, which has no epochs
+type legacyStreamInfoReq struct {
+	Streams []ID
+}
+
+// legacyStreamInfoRes is the StreamInfoRes of LegacySpec
+type legacyStreamInfoRes struct {
+	Streams []legacyStreamDescriptor
+}
+
+// legacyStreamDescriptor is the StreamDescriptor of LegacySpec, which has no epochs
+type legacyStreamDescriptor struct {
+	Stream  ID
+	Cursor  uint64
+	Bounded bool
+}
+
+// legacyWantedHashes is the WantedHashes of LegacySpec, which can not request the next range
+type legacyWantedHashes struct {
+	Ruid      uint
+	BitVector []byte
+}
+
+// downgrade returns the message in its LegacySpec version
+func downgrade(msg interface{}) interface{} {
+	switch msg := msg.(type) {
+	case *StreamInfoReq:
+		return &legacyStreamInfoReq{Streams: msg.Streams}
+	case *StreamInfoRes:
+		res := &legacyStreamInfoRes{Streams: make([]legacyStreamDescriptor, len(msg.Streams))}
+		for i, s := range msg.Streams {
+			res.Streams[i] = legacyStreamDescriptor{Stream: s.Stream, Cursor: s.Cursor, Bounded: s.Bounded}
+		}
+		return res
+	case StreamInfoRes:
+		return downgrade(&msg)
+	case *WantedHashes:
+		return &legacyWantedHashes{Ruid: msg.Ruid, BitVector: msg.BitVector}
+	case WantedHashes:
+		return downgrade(&msg)
+	}
+	return msg
+}
+
+// upgrade returns the received LegacySpec message in its current version
+func upgrade(msg interface{}) interface{} {
+	switch msg := msg.(type) {
+	case *legacyStreamInfoReq:
+		return &StreamInfoReq{Streams: msg.Streams}
+	case *legacyStreamInfoRes:
+		res := &StreamInfoRes{Streams: make([]StreamDescriptor, len(msg.Streams))}
+		for i, s := range msg.Streams {
+			res.Streams[i] = StreamDescriptor{Stream: s.Stream, Cursor: s.Cursor, Bounded: s.Bounded}
+		}
+		return res
+	case *legacyWantedHashes:
+		return &WantedHashes{Ruid: msg.Ruid, BitVector: msg.BitVector}
+	}
+	return msg
 }
 
 // ChunkDelivery delivers a frame of chunks in response to a WantedHashes message
@@ -0,0 +1,98 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// TestWantedHashesRLP checks that the optional next range request
+// in WantedHashes survives the RLP encoding round trip
+func TestWantedHashesRLP(t *testing.T) {
+	to := uint64(100)
+	for _, msg := range []WantedHashes{
+		{Ruid: 1, BitVector: []byte{0x3}},
+		{Ruid: 2, BitVector: []byte{}, Next: &GetRange{Ruid: 3, Stream: NewID("SYNC", "1"), From: 10, To: &to, BatchSize: BatchSize}},
+		{Ruid: 4, BitVector: []byte{}, Next: &GetRange{Ruid: 5, Stream: NewID("SYNC", "1"), From: 101}},
+	} {
+		b, err := rlp.EncodeToBytes(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got WantedHashes
+		if err := rlp.DecodeBytes(b, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Ruid != msg.Ruid || !bytes.Equal(got.BitVector, msg.BitVector) {
+			t.Fatalf("got %+v, want %+v", got, msg)
+		}
+		if (got.Next == nil) != (msg.Next == nil) {
+			t.Fatalf("got next range %v, want %v", got.Next, msg.Next)
+		}
+		if msg.Next == nil {
+			continue
+		}
+		if got.Next.Ruid != msg.Next.Ruid || got.Next.Stream != msg.Next.Stream || got.Next.From != msg.Next.From {
+			t.Fatalf("got next range %+v, want %+v", got.Next, msg.Next)
+		}
+		if (got.Next.To == nil) != (msg.Next.To == nil) || (msg.Next.To != nil && *got.Next.To != *msg.Next.To) {
+			t.Fatalf("got next range upper bound %v, want %v", got.Next.To, msg.Next.To)
+		}
+	}
+}
+
+// TestLegacyMessages checks that messages sent to peers speaking LegacySpec
+// are encoded in their previous version and converted back when received
+func TestLegacyMessages(t *testing.T) {
+	wanted := WantedHashes{Ruid: 1, BitVector: []byte{}, Next: &GetRange{Ruid: 2, Stream: NewID("SYNC", "1"), From: 10}}
+	b, err := rlp.EncodeToBytes(downgrade(wanted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var legacyWanted legacyWantedHashes
+	if err := rlp.DecodeBytes(b, &legacyWanted); err != nil {
+		t.Fatal(err)
+	}
+	got, ok := upgrade(&legacyWanted).(*WantedHashes)
+	if !ok {
+		t.Fatalf("got %T, want *WantedHashes", upgrade(&legacyWanted))
+	}
+	if got.Ruid != wanted.Ruid || !bytes.Equal(got.BitVector, wanted.BitVector) || got.Next != nil {
+		t.Fatalf("got %+v, want ruid %v without next range", got, wanted.Ruid)
+	}
+
+	info := &StreamInfoRes{Streams: []StreamDescriptor{{Stream: NewID("SYNC", "2"), Cursor: 42, Epoch: 7, Bounded: true}}}
+	b, err = rlp.EncodeToBytes(downgrade(info))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var legacyInfo legacyStreamInfoRes
+	if err := rlp.DecodeBytes(b, &legacyInfo); err != nil {
+		t.Fatal(err)
+	}
+	gotInfo, ok := upgrade(&legacyInfo).(*StreamInfoRes)
+	if !ok {
+		t.Fatalf("got %T, want *StreamInfoRes", upgrade(&legacyInfo))
+	}
+	want := StreamDescriptor{Stream: NewID("SYNC", "2"), Cursor: 42, Bounded: true}
+	if len(gotInfo.Streams) != 1 || gotInfo.Streams[0] != want {
+		t.Fatalf("got %+v, want %+v", gotInfo.Streams, want)
+	}
+}
@@ -14,8 +14,14 @@ var FetcherGlobalTimeout = 10 * time.Second
 var FetcherSlowChunkDeliveryThreshold = 5 * time.Second
 
 // SearchTimeout is the max time requests wait for a peer to deliver a chunk, after which another peer is tried
+// it is also used for peers that have no round trip time samples yet when the timeout is adaptive
 var SearchTimeout = 1500 * time.Millisecond
 
+// MinSearchTimeout and MaxSearchTimeout bound the adaptive per-peer search timeout
+// derived from the round trip times of previous retrieve requests to the peer
+var MinSearchTimeout = 250 * time.Millisecond
+var MaxSearchTimeout = 5 * time.Second
+
 // SyncerClientWaitTimeout is the max time a syncer client waits for a chunk to be delivered during syncing
 var SyncerClientWaitTimeout = 20 * time.Second
 
@@ -0,0 +1,244 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// Transport is the scheme of an underlay address
+type Transport string
+
+// Transports known in underlay addresses
+const (
+	TransportTCP Transport = "tcp"
+	TransportWS  Transport = "ws"
+	TransportWSS Transport = "wss"
+)
+
+// underlayQueryKey is the query parameter of enode URLs which holds
+// the alternative underlays of a node. Nodes which do not know about
+// it ignore it and dial the TCP endpoint of the enode URL.
+const underlayQueryKey = "underlay"
+
+var errUnknownTransport = errors.New("unknown transport")
+
+// Underlay is an address a node can be dialed at, in the form of
+// a URL whose scheme is the transport, like wss://gateway.example.org:443/bzz
+type Underlay struct {
+	Transport Transport
+	URL       string
+}
+
+// String returns the URL of the underlay
+func (u Underlay) String() string {
+	return u.URL
+}
+
+// Host returns the host:port part of the underlay URL
+func (u Underlay) Host() string {
+	v, err := url.Parse(u.URL)
+	if err != nil {
+		return ""
+	}
+	return v.Host
+}
+
+// ParseUnderlay parses an underlay URL with a known transport and a host
+func ParseUnderlay(s string) (Underlay, error) {
+	v, err := url.Parse(s)
+	if err != nil {
+		return Underlay{}, err
+	}
+	t := Transport(strings.ToLower(v.Scheme))
+	switch t {
+	case TransportTCP, TransportWS, TransportWSS:
+	default:
+		return Underlay{}, fmt.Errorf("%v %q in underlay %s", errUnknownTransport, v.Scheme, s)
+	}
+	if v.Hostname() == "" || v.Port() == "" {
+		return Underlay{}, fmt.Errorf("underlay %s needs a host and a port", s)
+	}
+	return Underlay{Transport: t, URL: s}, nil
+}
+
+// EncodeUnderlays adds alternative underlays to the enode URL uaddr
+func EncodeUnderlays(uaddr []byte, underlays []Underlay) []byte {
+	if len(underlays) == 0 {
+		return uaddr
+	}
+	v, err := url.Parse(string(uaddr))
+	if err != nil {
+		return uaddr
+	}
+	q := v.Query()
+	for _, u := range underlays {
+		q.Add(underlayQueryKey, u.URL)
+	}
+	v.RawQuery = q.Encode()
+	return []byte(v.String())
+}
+
+// Underlays returns the addresses the peer can be dialed at: the TCP endpoint
+// of its enode URL followed by the alternative underlays it advertises
+func (a *BzzAddr) Underlays() []Underlay {
+	n, err := enode.ParseV4(string(a.UAddr))
+	if err != nil {
+		return nil
+	}
+	var underlays []Underlay
+	if n.IP() != nil && n.TCP() != 0 {
+		host := net.JoinHostPort(n.IP().String(), strconv.Itoa(n.TCP()))
+		underlays = append(underlays, Underlay{Transport: TransportTCP, URL: "tcp://" + host})
+	}
+	v, err := url.Parse(string(a.UAddr))
+	if err != nil {
+		return underlays
+	}
+	for _, s := range v.Query()[underlayQueryKey] {
+		u, err := ParseUnderlay(s)
+		if err != nil {
+			log.Trace("ignoring underlay", "peer", a.ShortOver(), "err", err)
+			continue
+		}
+		underlays = append(underlays, u)
+	}
+	return underlays
+}
+
+// TransportDialer opens a connection to an underlay of a single transport
+type TransportDialer func(u Underlay) (net.Conn, error)
+
+// UnderlayDialer dials nodes over the best of their underlays this node
+// has a transport for. It implements p2p.NodeDialer and learns the
+// underlays of nodes from the addresses hive connects to.
+type UnderlayDialer struct {
+	mu         sync.RWMutex
+	dialers    map[Transport]TransportDialer
+	preference []Transport             // transports in order of registration
+	underlays  map[enode.ID][]Underlay // advertised underlays of nodes
+	last       map[enode.ID]Transport  // transport of the last successful dial
+}
+
+// NewUnderlayDialer creates an UnderlayDialer with the TCP transport
+func NewUnderlayDialer(timeout time.Duration) *UnderlayDialer {
+	d := &UnderlayDialer{
+		dialers:   make(map[Transport]TransportDialer),
+		underlays: make(map[enode.ID][]Underlay),
+		last:      make(map[enode.ID]Transport),
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	d.RegisterTransport(TransportTCP, func(u Underlay) (net.Conn, error) {
+		return dialer.Dial("tcp", u.Host())
+	})
+	return d
+}
+
+// RegisterTransport sets the dialer of a transport. Transports registered
+// earlier are preferred if a node is reachable over several of them.
+func (d *UnderlayDialer) RegisterTransport(t Transport, dial TransportDialer) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.dialers[t]; !ok {
+		d.preference = append(d.preference, t)
+	}
+	d.dialers[t] = dial
+}
+
+// SetUnderlays records the underlays the node advertises
+func (d *UnderlayDialer) SetUnderlays(id enode.ID, underlays []Underlay) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(underlays) == 0 {
+		delete(d.underlays, id)
+		return
+	}
+	d.underlays[id] = underlays
+}
+
+// RemoveNode forgets the underlays of the node and the transport
+// it was last dialed over
+func (d *UnderlayDialer) RemoveNode(id enode.ID) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.underlays, id)
+	delete(d.last, id)
+}
+
+// candidates returns the underlays of the node that can be dialed, with
+// the transport that worked last first and the others in order of preference
+func (d *UnderlayDialer) candidates(n *enode.Node) ([]Underlay, map[Transport]TransportDialer) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	underlays := d.underlays[n.ID()]
+	if len(underlays) == 0 && n.IP() != nil {
+		host := net.JoinHostPort(n.IP().String(), strconv.Itoa(n.TCP()))
+		underlays = []Underlay{{Transport: TransportTCP, URL: "tcp://" + host}}
+	}
+	order := d.preference
+	if last, ok := d.last[n.ID()]; ok {
+		order = append([]Transport{last}, d.preference...)
+	}
+	var candidates []Underlay
+	seen := make(map[string]bool)
+	for _, t := range order {
+		for _, u := range underlays {
+			if u.Transport == t && !seen[u.URL] {
+				seen[u.URL] = true
+				candidates = append(candidates, u)
+			}
+		}
+	}
+	dialers := make(map[Transport]TransportDialer, len(d.dialers))
+	for t, dial := range d.dialers {
+		dialers[t] = dial
+	}
+	return candidates, dialers
+}
+
+// Dial connects to the node over the first of its underlays that is reachable
+func (d *UnderlayDialer) Dial(n *enode.Node) (net.Conn, error) {
+	candidates, dialers := d.candidates(n)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no supported underlay for node %s", n.ID().TerminalString())
+	}
+	var err error
+	for _, u := range candidates {
+		var conn net.Conn
+		conn, err = dialers[u.Transport](u)
+		if err != nil {
+			log.Trace("underlay dial failed", "node", n.ID().TerminalString(), "underlay", u, "err", err)
+			continue
+		}
+		d.mu.Lock()
+		d.last[n.ID()] = u.Transport
+		d.mu.Unlock()
+		return conn, nil
+	}
+	return nil, err
+}
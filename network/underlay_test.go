@@ -0,0 +1,144 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// TestUnderlays checks that alternative underlays are advertised in the
+// enode URL without breaking the parsing of the enode URL itself
+func TestUnderlays(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := enode.NewV4(&key.PublicKey, net.IP{10, 0, 0, 1}, 30399, 30399)
+
+	var alternatives []Underlay
+	for _, s := range []string{"wss://gateway.example.org:443/bzz", "ws://10.0.0.1:8080"} {
+		u, err := ParseUnderlay(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		alternatives = append(alternatives, u)
+	}
+	for _, s := range []string{"http://gateway.example.org:80", "quic://10.0.0.1:30400", "ws://gateway.example.org", "wss://:443"} {
+		if _, err := ParseUnderlay(s); err == nil {
+			t.Fatalf("expected error parsing underlay %s", s)
+		}
+	}
+
+	addr := NewBzzAddr(node.ID().Bytes(), EncodeUnderlays([]byte(node.URLv4()), alternatives))
+
+	parsed, err := enode.ParseV4(string(addr.UAddr))
+	if err != nil {
+		t.Fatalf("enode URL with underlays does not parse: %v", err)
+	}
+	if parsed.ID() != node.ID() || parsed.TCP() != node.TCP() || !parsed.IP().Equal(node.IP()) {
+		t.Fatalf("expected node %v, got %v", node, parsed)
+	}
+	if addr.ID() != node.ID() {
+		t.Fatalf("expected id %v, got %v", node.ID(), addr.ID())
+	}
+
+	want := append([]Underlay{{Transport: TransportTCP, URL: "tcp://10.0.0.1:30399"}}, alternatives...)
+	if got := addr.Underlays(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected underlays %v, got %v", want, got)
+	}
+}
+
+// TestUnderlayDialer checks that the dialer uses the preferred transport
+// a node is reachable over and falls back to the others
+func TestUnderlayDialer(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := enode.NewV4(&key.PublicKey, net.IP{10, 0, 0, 1}, 30399, 30399)
+	underlays := []Underlay{
+		{Transport: TransportTCP, URL: "tcp://10.0.0.1:30399"},
+		{Transport: TransportWS, URL: "ws://10.0.0.1:8080"},
+		{Transport: TransportWSS, URL: "wss://gateway.example.org:443"},
+	}
+
+	var dialed []Underlay
+	reachable := make(map[Transport]bool)
+	dial := func(u Underlay) (net.Conn, error) {
+		dialed = append(dialed, u)
+		if !reachable[u.Transport] {
+			return nil, errors.New("unreachable")
+		}
+		c, _ := net.Pipe()
+		return c, nil
+	}
+
+	// tcp is registered first and preferred, wss has no dialer
+	d := NewUnderlayDialer(time.Second)
+	d.RegisterTransport(TransportTCP, dial)
+	d.RegisterTransport(TransportWS, dial)
+	d.SetUnderlays(node.ID(), underlays)
+
+	reachable[TransportWS] = true
+	conn, err := d.Dial(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if !reflect.DeepEqual(dialed, underlays[:2]) {
+		t.Fatalf("expected to dial %v, got %v", underlays[:2], dialed)
+	}
+
+	// the transport that worked is tried first next time
+	dialed = nil
+	reachable[TransportTCP] = true
+	conn, err = d.Dial(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if !reflect.DeepEqual(dialed, underlays[1:2]) {
+		t.Fatalf("expected to dial %v, got %v", underlays[1:2], dialed)
+	}
+
+	dialed = nil
+	reachable[TransportTCP] = false
+	reachable[TransportWS] = false
+	if _, err := d.Dial(node); err == nil {
+		t.Fatal("expected dial error")
+	}
+	if len(dialed) != 2 {
+		t.Fatalf("expected to dial 2 underlays, got %v", dialed)
+	}
+
+	// a removed node is dialed at the tcp endpoint of its enode URL
+	d.RemoveNode(node.ID())
+	dialed = nil
+	if _, err := d.Dial(node); err == nil {
+		t.Fatal("expected dial error")
+	}
+	if !reflect.DeepEqual(dialed, underlays[:1]) {
+		t.Fatalf("expected to dial %v, got %v", underlays[:1], dialed)
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/pot"
+)
+
+// PeerUptime is the connection history of a peer
+type PeerUptime struct {
+	Peer      string        `json:"peer"`      // hex encoded overlay address of the peer
+	Connected time.Duration `json:"connected"` // total time the peer was connected
+	Sessions  uint64        `json:"sessions"`  // number of connections to the peer
+
+	connectedAt time.Time // start of the current connection, zero if not connected
+}
+
+// meanSession returns the average connection duration of the peer
+// until now, including the current connection
+func (u *PeerUptime) meanSession(now time.Time) time.Duration {
+	connected, sessions := u.Connected, u.Sessions
+	if !u.connectedAt.IsZero() {
+		connected += now.Sub(u.connectedAt)
+	}
+	if sessions == 0 {
+		return 0
+	}
+	return connected / time.Duration(sessions)
+}
+
+// uptimeMetricsMode returns the suffix of the connection lifetime metrics, so
+// that they can be compared between nodes with and without the uptime bias
+func (k *Kademlia) uptimeMetricsMode() string {
+	if k.UptimeBias {
+		return "uptimebias"
+	}
+	return "default"
+}
+
+// connectedUptime starts a connection session of the peer at addr.
+// It must be called with the kademlia lock held.
+func (k *Kademlia) connectedUptime(addr []byte, now time.Time) {
+	key := string(addr)
+	u, ok := k.uptimes[key]
+	if !ok {
+		u = &PeerUptime{Peer: hex.EncodeToString(addr)}
+		k.uptimes[key] = u
+	}
+	u.Sessions++
+	u.connectedAt = now
+}
+
+// disconnectedUptime ends the connection session of the peer at addr
+// and records its lifetime. It must be called with the kademlia lock held.
+func (k *Kademlia) disconnectedUptime(addr []byte, now time.Time) {
+	u, ok := k.uptimes[string(addr)]
+	if !ok || u.connectedAt.IsZero() {
+		return
+	}
+	lifetime := now.Sub(u.connectedAt)
+	u.Connected += lifetime
+	u.connectedAt = time.Time{}
+	mode := k.uptimeMetricsMode()
+	metrics.GetOrRegisterResettingTimer("kad.conn.lifetime."+mode, nil).Update(lifetime)
+	metrics.GetOrRegisterCounter("kad.conn.sessions."+mode, nil).Inc(1)
+}
+
+// uptimeScore returns the stability score of the peer at addr used to
+// order peer suggestions, the mean duration of its past connections
+func (k *Kademlia) uptimeScore(addr []byte, now time.Time) time.Duration {
+	u, ok := k.uptimes[string(addr)]
+	if !ok {
+		return 0
+	}
+	return u.meanSession(now)
+}
+
+// sortByUptime orders entries by descending uptime score, keeping
+// the original order of entries with the same score
+func (k *Kademlia) sortByUptime(entries []*entry) {
+	now := k.clock.Time()
+	scores := make(map[*entry]time.Duration, len(entries))
+	for _, e := range entries {
+		scores[e] = k.uptimeScore(e.Address(), now)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return scores[entries[i]] > scores[entries[j]]
+	})
+}
+
+// Uptimes returns the connection history of all peers that were connected,
+// including the current connections up to now.
+func (k *Kademlia) Uptimes() []PeerUptime {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+
+	now := k.clock.Time()
+	uptimes := make([]PeerUptime, 0, len(k.uptimes))
+	for _, u := range k.uptimes {
+		c := *u
+		if !c.connectedAt.IsZero() {
+			c.Connected += now.Sub(c.connectedAt)
+			c.connectedAt = time.Time{}
+		}
+		uptimes = append(uptimes, c)
+	}
+	sort.Slice(uptimes, func(i, j int) bool {
+		return uptimes[i].Peer < uptimes[j].Peer
+	})
+	return uptimes
+}
+
+// SetUptimes loads the connection history of peers, such as persisted by
+// a previous session. History of peers already connected in this session
+// is added to.
+func (k *Kademlia) SetUptimes(uptimes []PeerUptime) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	for _, u := range uptimes {
+		addr, err := hex.DecodeString(u.Peer)
+		if err != nil {
+			continue
+		}
+		key := string(addr)
+		if c, ok := k.uptimes[key]; ok {
+			c.Connected += u.Connected
+			c.Sessions += u.Sessions
+			continue
+		}
+		u := u
+		k.uptimes[key] = &u
+	}
+}
+
+// suggestByUptime returns the callable peer in bin with the highest uptime score
+func (k *Kademlia) suggestByUptime(bin *pot.Bin) *BzzAddr {
+	var entries []*entry
+	bin.ValIterator(func(val pot.Val) bool {
+		entries = append(entries, val.(*entry))
+		return true
+	})
+	k.sortByUptime(entries)
+	for _, e := range entries {
+		if k.callable(e) {
+			return e.BzzAddr
+		}
+	}
+	return nil
+}
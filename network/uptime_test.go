@@ -0,0 +1,81 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestSuggestPeerUptimeBias checks that with the uptime bias enabled peers
+// are suggested in descending order of their mean connection duration
+func TestSuggestPeerUptimeBias(t *testing.T) {
+	tk := newTestKademlia(t, "00000000")
+	tk.UptimeBias = true
+	tk.Register("10000000", "11000000", "10100000")
+	tk.SetUptimes([]PeerUptime{
+		{Peer: testUptimePeer("11000000"), Connected: time.Hour, Sessions: 4},
+		{Peer: testUptimePeer("10100000"), Connected: 2 * time.Hour, Sessions: 2},
+	})
+	for _, want := range []string{"10100000", "11000000", "10000000", "<nil>"} {
+		a, _, _ := tk.SuggestPeer()
+		if got := binStr(a); got != want {
+			t.Fatalf("got suggested peer %v, want %v", got, want)
+		}
+	}
+}
+
+// TestKademliaUptimes checks that connections are recorded in the uptime
+// history of peers and that the history can be restored
+func TestKademliaUptimes(t *testing.T) {
+	tk := newTestKademlia(t, "00000000")
+	tk.Register("10000000", "11000000")
+	tk.On("10000000")
+	tk.Off("10000000")
+	tk.On("10000000", "11000000")
+
+	uptimes := tk.Uptimes()
+	if len(uptimes) != 2 {
+		t.Fatalf("got %v peer uptimes, want 2", len(uptimes))
+	}
+	for i, want := range []struct {
+		peer     string
+		sessions uint64
+	}{
+		{"10000000", 2},
+		{"11000000", 1},
+	} {
+		if uptimes[i].Peer != testUptimePeer(want.peer) {
+			t.Fatalf("got uptime of peer %v, want %v", uptimes[i].Peer, testUptimePeer(want.peer))
+		}
+		if uptimes[i].Sessions != want.sessions {
+			t.Fatalf("peer %v: got %v sessions, want %v", want.peer, uptimes[i].Sessions, want.sessions)
+		}
+	}
+
+	restored := newTestKademlia(t, "00000000")
+	restored.SetUptimes(uptimes)
+	if got := restored.Uptimes(); !reflect.DeepEqual(got, uptimes) {
+		t.Fatalf("got restored uptimes %v, want %v", got, uptimes)
+	}
+}
+
+func testUptimePeer(s string) string {
+	return hex.EncodeToString(testKadPeerAddr(s).Address())
+}
@@ -0,0 +1,185 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p"
+	"golang.org/x/net/websocket"
+)
+
+// inboundConn is the flag p2p.Server marks accepted connections with,
+// which the server does not export. TestWebSocketUnderlay checks that
+// peers connected through the listener are reported as inbound.
+const inboundConn = 1 << 2
+
+// defaultMaxPendingWebSocketConns is the number of websocket connections
+// in the p2p handshake at the same time if the server sets no MaxPendingPeers,
+// the default of p2p.Server
+const defaultMaxPendingWebSocketConns = 50
+
+// wsOrigin is sent as the origin of websocket handshakes, nodes are not browsers
+const wsOrigin = "http://localhost/"
+
+// DialWebSocket returns a TransportDialer for ws and wss underlays, so that
+// nodes behind firewalls which only allow web traffic can connect to peers.
+// The timeout applies to both connecting and the websocket handshake.
+func DialWebSocket(timeout time.Duration) TransportDialer {
+	return func(u Underlay) (net.Conn, error) {
+		config, err := websocket.NewConfig(u.URL, wsOrigin)
+		if err != nil {
+			return nil, err
+		}
+		dialer := &net.Dialer{Timeout: timeout}
+		var conn net.Conn
+		if u.Transport == TransportWSS {
+			conn, err = tls.DialWithDialer(dialer, "tcp", u.Host(), &tls.Config{ServerName: config.Location.Hostname()})
+		} else {
+			conn, err = dialer.Dial("tcp", u.Host())
+		}
+		if err != nil {
+			return nil, err
+		}
+		conn.SetDeadline(time.Now().Add(timeout))
+		ws, err := websocket.NewClient(config, conn)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn.SetDeadline(time.Time{})
+		ws.PayloadType = websocket.BinaryFrame
+		return ws, nil
+	}
+}
+
+// WebSocketListener accepts connections of peers dialing the ws or wss
+// underlay of the node and hands them to the p2p server. As many connections
+// as the server allows pending peers are in the p2p handshake at the same
+// time, further connections are closed right away.
+type WebSocketListener struct {
+	server   *http.Server
+	listener net.Listener
+	pending  chan struct{} // slots of the connections in the p2p handshake
+}
+
+// ListenWebSocket starts accepting websocket connections on addr for srv,
+// which must be running. If certFile and keyFile are set, the connections
+// are served over TLS for wss underlays.
+func ListenWebSocket(addr, certFile, keyFile string, srv *p2p.Server) (*WebSocketListener, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	maxPending := srv.MaxPendingPeers
+	if maxPending <= 0 {
+		maxPending = defaultMaxPendingWebSocketConns
+	}
+	l := &WebSocketListener{
+		listener: listener,
+		pending:  make(chan struct{}, maxPending),
+	}
+	l.server = &http.Server{
+		Handler: websocket.Server{
+			// nodes are not browsers, any origin is accepted
+			Handshake: func(*websocket.Config, *http.Request) error { return nil },
+			Handler: func(ws *websocket.Conn) {
+				ws.PayloadType = websocket.BinaryFrame
+				conn := newWSConn(ws)
+				select {
+				case l.pending <- struct{}{}:
+				default:
+					metrics.GetOrRegisterCounter("network.websocket.rejected", nil).Inc(1)
+					log.Debug("too many pending websocket underlay connections", "remote", conn.RemoteAddr())
+					conn.Close()
+					return
+				}
+				err := srv.SetupConn(conn, inboundConn, nil)
+				<-l.pending
+				if err != nil {
+					log.Debug("websocket underlay connection failed", "remote", conn.RemoteAddr(), "err", err)
+					conn.Close()
+					return
+				}
+				// the connection is closed when the handler returns
+				<-conn.closed
+			},
+		},
+	}
+	go func() {
+		var err error
+		if certFile != "" {
+			err = l.server.ServeTLS(listener, certFile, keyFile)
+		} else {
+			err = l.server.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Error("websocket underlay listener failed", "addr", addr, "err", err)
+		}
+	}()
+	log.Info("websocket underlay listener up", "addr", listener.Addr(), "tls", certFile != "")
+	return l, nil
+}
+
+// Addr returns the address the listener accepts connections on
+func (l *WebSocketListener) Addr() net.Addr {
+	return l.listener.Addr()
+}
+
+// Close stops accepting connections and closes the open ones
+func (l *WebSocketListener) Close() error {
+	return l.server.Close()
+}
+
+// wsConn is an accepted websocket connection with the TCP address of the
+// peer as its remote address, so that peer filters can check it
+type wsConn struct {
+	*websocket.Conn
+	remote    net.Addr
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newWSConn(ws *websocket.Conn) *wsConn {
+	c := &wsConn{
+		Conn:   ws,
+		remote: ws.RemoteAddr(),
+		closed: make(chan struct{}),
+	}
+	if addr, err := net.ResolveTCPAddr("tcp", ws.Request().RemoteAddr); err == nil {
+		c.remote = addr
+	}
+	return c
+}
+
+// RemoteAddr returns the TCP address of the peer
+func (c *wsConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// Close closes the websocket connection and releases its handler
+func (c *wsConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() { close(c.closed) })
+	return err
+}
@@ -0,0 +1,160 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+// TestWebSocketUnderlay checks that a node whose TCP underlay is not
+// reachable is connected to over its ws underlay
+func TestWebSocketUnderlay(t *testing.T) {
+	newServer := func(dialer p2p.NodeDialer) *p2p.Server {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv := &p2p.Server{
+			Config: p2p.Config{
+				PrivateKey:  key,
+				MaxPeers:    10,
+				NoDiscovery: true,
+				NoDial:      true,
+				ListenAddr:  "127.0.0.1:0",
+				Dialer:      dialer,
+			},
+		}
+		if err := srv.Start(); err != nil {
+			t.Fatal(err)
+		}
+		return srv
+	}
+
+	dialer := NewUnderlayDialer(time.Second)
+	// the TCP underlay is blocked by a firewall
+	dialer.RegisterTransport(TransportTCP, func(Underlay) (net.Conn, error) {
+		return nil, errors.New("blocked")
+	})
+	dialer.RegisterTransport(TransportWS, DialWebSocket(time.Second))
+
+	server := newServer(nil)
+	defer server.Stop()
+	client := newServer(dialer)
+	defer client.Stop()
+
+	l, err := ListenWebSocket("127.0.0.1:0", "", "", server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	ws, err := ParseUnderlay("ws://" + l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := NewBzzAddr(server.Self().ID().Bytes(), EncodeUnderlays([]byte(server.Self().URLv4()), []Underlay{ws}))
+	dialer.SetUnderlays(server.Self().ID(), addr.Underlays())
+
+	events := make(chan *p2p.PeerEvent, 10)
+	sub := server.SubscribeEvents(events)
+	defer sub.Unsubscribe()
+
+	client.AddPeer(server.Self())
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev := <-events:
+			if ev.Type != p2p.PeerEventTypeAdd {
+				continue
+			}
+			if ev.Peer != client.Self().ID() {
+				t.Fatalf("expected peer %v, got %v", client.Self().ID(), ev.Peer)
+			}
+			peers := server.PeersInfo()
+			if len(peers) != 1 {
+				t.Fatalf("expected 1 peer, got %d", len(peers))
+			}
+			if ip, _, err := net.SplitHostPort(peers[0].Network.RemoteAddress); err != nil || ip != "127.0.0.1" {
+				t.Fatalf("expected the TCP address of the peer, got %s", peers[0].Network.RemoteAddress)
+			}
+			// the server does not export its inbound flag, so check that the copied one matches
+			if !peers[0].Network.Inbound {
+				t.Fatal("expected the peer to be marked inbound")
+			}
+			return
+		case <-timeout:
+			t.Fatal("timeout waiting for the websocket connection")
+		}
+	}
+}
+
+// TestWebSocketPendingLimit checks that websocket connections exceeding
+// the pending peers of the server are closed without a p2p handshake
+func TestWebSocketPendingLimit(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &p2p.Server{
+		Config: p2p.Config{
+			PrivateKey:      key,
+			MaxPeers:        10,
+			MaxPendingPeers: 1,
+			NoDiscovery:     true,
+			NoDial:          true,
+			ListenAddr:      "127.0.0.1:0",
+		},
+	}
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	l, err := ListenWebSocket("127.0.0.1:0", "", "", server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if cap(l.pending) != 1 {
+		t.Fatalf("expected 1 pending connection slot, got %d", cap(l.pending))
+	}
+	// a connection is in the handshake
+	l.pending <- struct{}{}
+
+	ws, err := ParseUnderlay("ws://" + l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := DialWebSocket(time.Second)(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("expected the connection to be closed")
+	} else if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		t.Fatal("expected the connection to be closed before the read deadline")
+	}
+}
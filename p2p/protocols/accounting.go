@@ -17,9 +17,15 @@
 package protocols
 
 import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p/enode"
 )
 
 // define some metrics
@@ -63,7 +69,8 @@ const (
 // Price represents the costs of a message
 type Price struct {
 	Value   uint64
-	PerByte bool // True if the price is per byte or for unit
+	PerByte bool   // True if the price is per byte or for unit
+	Min     uint64 // lowest price of a message priced per byte, no floor if zero
 	Payer   Payer
 }
 
@@ -74,10 +81,23 @@ type Price struct {
 // `Send` will pass a `Sender` payer, `Receive` will pass the `Receiver` argument.
 // Thus: If Sending and sender pays, amount negative, otherwise positive
 // If Receiving, and receiver pays, amount negative, otherwise positive
+// Messages priced per byte cost at least Min, so that small payloads
+// are not accounted for less than the overhead of a message.
+// Prices which do not fit into the amount are capped.
 func (p *Price) For(payer Payer, size uint32) int64 {
 	price := p.Value
 	if p.PerByte {
-		price *= uint64(size)
+		if size != 0 && price > math.MaxUint64/uint64(size) {
+			price = math.MaxUint64
+		} else {
+			price *= uint64(size)
+		}
+		if price < p.Min {
+			price = p.Min
+		}
+	}
+	if price > math.MaxInt64 {
+		price = math.MaxInt64
 	}
 	if p.Payer == payer {
 		return 0 - int64(price)
@@ -85,6 +105,12 @@ func (p *Price) For(payer Payer, size uint32) int64 {
 	return int64(price)
 }
 
+// Pricer returns the price of a message exchanged with a peer, allowing
+// protocols to price messages depending on the peer. The local argument
+// is Sender for sent and Receiver for received messages. If it returns nil,
+// the price of the PricedMessage is used.
+type Pricer func(peer *Peer, msg interface{}, local Payer) *Price
+
 // Balance is the actual accounting instance
 // Balance defines the operations needed for accounting
 // Implementations internally maintain the balance for every peer
@@ -95,10 +121,36 @@ type Balance interface {
 	Add(amount int64, peer *Peer) error
 }
 
+// ServiceBalance is implemented by balances which attribute accounted
+// amounts to the services generating them, identified by protocol names
+type ServiceBalance interface {
+	Balance
+	// AddForService is Add for an amount generated by the named service
+	AddForService(amount int64, peer *Peer, service string) error
+}
+
+// MessageBalance is implemented by balances which record the types of the
+// messages for which amounts are accounted
+type MessageBalance interface {
+	ServiceBalance
+	// AddForMessage is AddForService for an amount accounted for a message of the given type
+	AddForMessage(amount int64, peer *Peer, service, msgType string) error
+}
+
+// Throttler is implemented by balances which ask protocols to delay serving
+// peers whose debt approaches the amount at which they are disconnected,
+// rather than refusing to serve them abruptly
+type Throttler interface {
+	// Throttle returns how long serving the peer should be delayed, 0 if not at all
+	Throttle(peer enode.ID) time.Duration
+}
+
 // Accounting implements the Hook interface
 // It interfaces to the balances through the Balance interface
 type Accounting struct {
-	Balance // interface to accounting logic
+	Balance           // interface to accounting logic
+	pricer   Pricer   // optional peer dependent pricing
+	msgTypes sync.Map // key: reflect.Type of an accounted message, value: its type name
 }
 
 // NewAccounting creates a new instance of Accounting
@@ -109,6 +161,15 @@ func NewAccounting(balance Balance) *Accounting {
 	return ah
 }
 
+// NewAccountingWithPricer creates a new instance of Accounting
+// which prices messages with the provided Pricer
+func NewAccountingWithPricer(balance Balance, pricer Pricer) *Accounting {
+	return &Accounting{
+		Balance: balance,
+		pricer:  pricer,
+	}
+}
+
 // SetupAccountingMetrics uses a separate registry for p2p accounting metrics;
 // this registry should be independent of any other metrics as it persists at different endpoints.
 // It also starts the persisting go-routine which
@@ -130,9 +191,9 @@ func (ah *Accounting) Send(peer *Peer, size uint32, msg interface{}) error {
 		return nil
 	}
 	// evaluate the price for sending messages
-	costToLocalNode := pricedMessage.Price().For(Sender, size)
+	costToLocalNode := ah.price(peer, pricedMessage, Sender).For(Sender, size)
 	// do the accounting
-	err := ah.Add(costToLocalNode, peer)
+	err := ah.add(costToLocalNode, peer, msg)
 	// record metrics: just increase counters for user-facing metrics
 	ah.doMetrics(costToLocalNode, size, err)
 	return err
@@ -150,14 +211,52 @@ func (ah *Accounting) Receive(peer *Peer, size uint32, msg interface{}) error {
 		return nil
 	}
 	// evaluate the price for receiving messages
-	costToLocalNode := pricedMessage.Price().For(Receiver, size)
+	costToLocalNode := ah.price(peer, pricedMessage, Receiver).For(Receiver, size)
 	// do the accounting
-	err := ah.Add(costToLocalNode, peer)
+	err := ah.add(costToLocalNode, peer, msg)
 	// record metrics: just increase counters for user-facing metrics
 	ah.doMetrics(costToLocalNode, size, err)
 	return err
 }
 
+// add accounts the amount with the balance, attributing it to the protocol
+// of the peer and to the type of the message if the balance supports it
+func (ah *Accounting) add(amount int64, peer *Peer, msg interface{}) error {
+	var service string
+	if peer.spec != nil {
+		service = peer.spec.Name
+	}
+	switch b := ah.Balance.(type) {
+	case MessageBalance:
+		return b.AddForMessage(amount, peer, service, ah.msgType(msg))
+	case ServiceBalance:
+		return b.AddForService(amount, peer, service)
+	}
+	return ah.Add(amount, peer)
+}
+
+// msgType returns the name of the type of the message, without the pointer
+// indirection, formatting it only once per type
+func (ah *Accounting) msgType(msg interface{}) string {
+	typ := reflect.TypeOf(msg)
+	if name, ok := ah.msgTypes.Load(typ); ok {
+		return name.(string)
+	}
+	name := strings.TrimPrefix(fmt.Sprintf("%T", msg), "*")
+	ah.msgTypes.Store(typ, name)
+	return name
+}
+
+// price returns the price of a message exchanged with the peer
+func (ah *Accounting) price(peer *Peer, msg PricedMessage, local Payer) *Price {
+	if ah.pricer != nil {
+		if price := ah.pricer(peer, msg, local); price != nil {
+			return price
+		}
+	}
+	return msg.Price()
+}
+
 // record some metrics
 // this is not an error handling. `err` is returned by both `Send` and `Receive`
 // `err` will only be non-nil if a limit has been violated (overdraft), in which case the peer has been dropped.
@@ -17,6 +17,9 @@
 package protocols
 
 import (
+	"fmt"
+	"math"
+	"strings"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/p2p"
@@ -167,6 +170,146 @@ func TestBalance(t *testing.T) {
 	checkAccountingTestCases(t, testCases, acc, peer, balance, false)
 }
 
+//test that a Pricer overrides message prices and
+//that message prices are used if it returns nil
+func TestBalanceWithPricer(t *testing.T) {
+	balance := &dummyBalance{}
+	spec := createTestSpec()
+	id := adapters.RandomNodeConfig().ID
+	p := p2p.NewPeer(id, "testPeer", nil)
+	peer := NewPeer(p, &dummyRW{}, spec)
+
+	acc := NewAccountingWithPricer(balance, func(pricePeer *Peer, msg interface{}, local Payer) *Price {
+		if pricePeer != peer {
+			t.Errorf("expected pricer to be called with peer %v, got %v", peer, pricePeer)
+		}
+		if _, ok := msg.(*perUnitMsgSenderPays); !ok {
+			return nil
+		}
+		// the price depends on the direction
+		value := uint64(10)
+		if local == Receiver {
+			value = 20
+		}
+		return &Price{
+			PerByte: false,
+			Value:   value,
+			Payer:   Sender,
+		}
+	})
+
+	testCases := []testCase{
+		{
+			&perUnitMsgSenderPays{},
+			0,
+			int64(-10),
+			int64(20),
+		},
+		{
+			&perUnitMsgReceiverPays{},
+			0,
+			int64(99),
+			int64(-99),
+		},
+		{
+			&nilPriceMsg{},
+			0,
+			int64(0),
+			int64(0),
+		},
+	}
+	checkAccountingTestCases(t, testCases, acc, peer, balance, true)
+	checkAccountingTestCases(t, testCases, acc, peer, balance, false)
+}
+
+//test that messages priced per byte are charged at least
+//the floor price and that prices are capped
+func TestPriceFor(t *testing.T) {
+	for _, tc := range []struct {
+		price Price
+		size  uint32
+		want  int64
+	}{
+		{Price{Value: 10, PerByte: true, Min: 1000, Payer: Sender}, 50, -1000},
+		{Price{Value: 10, PerByte: true, Min: 1000, Payer: Sender}, 100, -1000},
+		{Price{Value: 10, PerByte: true, Min: 1000, Payer: Sender}, 101, -1010},
+		{Price{Value: 10, PerByte: true, Min: 1000, Payer: Receiver}, 0, 1000},
+		{Price{Value: 10, PerByte: false, Min: 1000, Payer: Sender}, 50, -10},
+		{Price{Value: math.MaxUint64 / 2, PerByte: true, Payer: Receiver}, 3, math.MaxInt64},
+	} {
+		if got := tc.price.For(Sender, tc.size); got != tc.want {
+			t.Errorf("price %+v for size %v: got %v, want %v", tc.price, tc.size, got, tc.want)
+		}
+	}
+}
+
+//dummy ServiceBalance implementation, stores the service for later check
+type dummyServiceBalance struct {
+	dummyBalance
+	service string
+}
+
+func (d *dummyServiceBalance) AddForService(amount int64, peer *Peer, service string) error {
+	d.service = service
+	return d.Add(amount, peer)
+}
+
+//test that amounts are attributed to the protocol of the peer
+func TestServiceBalance(t *testing.T) {
+	balance := &dummyServiceBalance{}
+	spec := createTestSpec()
+	acc := NewAccounting(balance)
+	id := adapters.RandomNodeConfig().ID
+	p := p2p.NewPeer(id, "testPeer", nil)
+	peer := NewPeer(p, &dummyRW{}, spec)
+
+	if err := acc.Send(peer, 0, &perUnitMsgSenderPays{}); err != nil {
+		t.Fatal(err)
+	}
+	checkResults(t, nil, &balance.dummyBalance, peer, -99)
+	if balance.service != spec.Name {
+		t.Fatalf("expected service %q, got %q", spec.Name, balance.service)
+	}
+}
+
+//dummy MessageBalance implementation, stores the message type for later check
+type dummyMessageBalance struct {
+	dummyServiceBalance
+	msgType string
+}
+
+func (d *dummyMessageBalance) AddForMessage(amount int64, peer *Peer, service, msgType string) error {
+	d.msgType = msgType
+	return d.AddForService(amount, peer, service)
+}
+
+//test that amounts are attributed to the type of the accounted message
+func TestMessageBalance(t *testing.T) {
+	balance := &dummyMessageBalance{}
+	spec := createTestSpec()
+	acc := NewAccounting(balance)
+	id := adapters.RandomNodeConfig().ID
+	p := p2p.NewPeer(id, "testPeer", nil)
+	peer := NewPeer(p, &dummyRW{}, spec)
+
+	if err := acc.Receive(peer, 0, &perUnitMsgSenderPays{}); err != nil {
+		t.Fatal(err)
+	}
+	checkResults(t, nil, &balance.dummyBalance, peer, 99)
+	if balance.service != spec.Name || balance.msgType != "protocols.perUnitMsgSenderPays" {
+		t.Fatalf("expected service %q and message type protocols.perUnitMsgSenderPays, got %q and %q", spec.Name, balance.service, balance.msgType)
+	}
+	// type names are cached per type
+	for _, msg := range []interface{}{&perUnitMsgReceiverPays{}, &perUnitMsgSenderPays{}} {
+		if err := acc.Receive(peer, 0, msg); err != nil {
+			t.Fatal(err)
+		}
+		if expected := strings.TrimPrefix(fmt.Sprintf("%T", msg), "*"); balance.msgType != expected {
+			t.Fatalf("expected message type %s, got %s", expected, balance.msgType)
+		}
+	}
+}
+
 func checkAccountingTestCases(t *testing.T, cases []testCase, acc *Accounting, peer *Peer, balance *dummyBalance, send bool) {
 	for _, c := range cases {
 		var err error
@@ -0,0 +1,260 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package protocols
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"golang.org/x/time/rate"
+)
+
+// cappedSendRate is the rate in bytes per second at which messages are sent
+// to a peer over its daily cap, about a chunk per second, so that protocols
+// keep making progress instead of failing
+const cappedSendRate = 4096
+
+// BandwidthStats holds the number of bytes and messages exchanged with a peer
+type BandwidthStats struct {
+	Sent             uint64 `json:"sent"`
+	Received         uint64 `json:"received"`
+	MessagesSent     uint64 `json:"messagesSent"`
+	MessagesReceived uint64 `json:"messagesReceived"`
+}
+
+func (s *BandwidthStats) add(outgoing bool, size uint32) {
+	if outgoing {
+		s.Sent += uint64(size)
+		s.MessagesSent++
+	} else {
+		s.Received += uint64(size)
+		s.MessagesReceived++
+	}
+}
+
+// PeerBandwidth is the bandwidth used by a peer
+type PeerBandwidth struct {
+	Peer      enode.ID                   `json:"peer"`
+	Protocols map[string]*BandwidthStats `json:"protocols"` // totals per protocol since the peer first connected
+	Today     BandwidthStats             `json:"today"`     // totals of all protocols in the current UTC day
+	Capped    bool                       `json:"capped"`    // sending to the peer is throttled until the end of the day
+}
+
+type peerBandwidth struct {
+	protocols map[string]*BandwidthStats
+	day       int64
+	today     BandwidthStats
+	running   int           // number of protocols running with the peer
+	throttle  *rate.Limiter // paces messages to the peer over the daily cap, nil if not capped
+}
+
+// BandwidthAccounting tracks bytes sent to and received from connected peers
+// per protocol, independently of any payment accounting.
+// With a daily cap set, messages to a peer are throttled to cappedSendRate
+// once the bytes sent to the peer reach the cap in the current UTC day.
+// This soft cap slows sending down without failing sends or disconnecting
+// the peer, incoming messages are neither counted against it nor throttled.
+type BandwidthAccounting struct {
+	mtx      sync.Mutex
+	dailyCap uint64
+	peers    map[enode.ID]*peerBandwidth
+	now      func() time.Time // overridden in tests
+}
+
+// NewBandwidthAccounting creates a bandwidth accounting with a daily cap of
+// bytes per peer, 0 disables the cap
+func NewBandwidthAccounting(dailyCap uint64) *BandwidthAccounting {
+	return &BandwidthAccounting{
+		dailyCap: dailyCap,
+		peers:    make(map[enode.ID]*peerBandwidth),
+		now:      time.Now,
+	}
+}
+
+// SetDailyCap changes the daily cap of bytes per peer, 0 disables the cap
+func (b *BandwidthAccounting) SetDailyCap(dailyCap uint64) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.dailyCap = dailyCap
+}
+
+// Protocol wraps the protocol so that its messages are accounted
+// The peer is forgotten once all its accounted protocols stopped.
+func (b *BandwidthAccounting) Protocol(proto p2p.Protocol) p2p.Protocol {
+	run := proto.Run
+	proto.Run = func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+		b.connect(p.ID())
+		defer b.disconnect(p.ID())
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		return run(p, &bandwidthRW{
+			MsgReadWriter: rw,
+			accounting:    b,
+			peer:          p.ID(),
+			protocol:      proto.Name,
+			ctx:           ctx,
+		})
+	}
+	return proto
+}
+
+// connect counts a protocol started with the peer
+func (b *BandwidthAccounting) connect(peer enode.ID) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	pb, ok := b.peers[peer]
+	if !ok {
+		pb = &peerBandwidth{
+			protocols: make(map[string]*BandwidthStats),
+		}
+		b.peers[peer] = pb
+	}
+	pb.running++
+}
+
+// disconnect counts a protocol stopped with the peer,
+// forgetting the peer when no protocol runs with it anymore
+func (b *BandwidthAccounting) disconnect(peer enode.ID) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	pb, ok := b.peers[peer]
+	if !ok {
+		return
+	}
+	if pb.running--; pb.running <= 0 {
+		delete(b.peers, peer)
+	}
+}
+
+// add accounts a message exchanged with the peer and returns the limiter
+// pacing outgoing messages if the peer is over the daily cap, nil otherwise
+func (b *BandwidthAccounting) add(peer enode.ID, protocol string, outgoing bool, size uint32) *rate.Limiter {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	pb, ok := b.peers[peer]
+	if !ok {
+		return nil
+	}
+	if day := b.day(); pb.day != day {
+		pb.day = day
+		pb.today = BandwidthStats{}
+	}
+	var throttle *rate.Limiter
+	if outgoing && b.capped(pb) {
+		if pb.throttle == nil {
+			pb.throttle = rate.NewLimiter(cappedSendRate, cappedSendRate)
+		}
+		throttle = pb.throttle
+	} else if !b.capped(pb) {
+		pb.throttle = nil
+	}
+	stats, ok := pb.protocols[protocol]
+	if !ok {
+		stats = &BandwidthStats{}
+		pb.protocols[protocol] = stats
+	}
+	stats.add(outgoing, size)
+	pb.today.add(outgoing, size)
+	return throttle
+}
+
+func (b *BandwidthAccounting) day() int64 {
+	return b.now().UTC().Unix() / int64(24*time.Hour/time.Second)
+}
+
+func (b *BandwidthAccounting) capped(pb *peerBandwidth) bool {
+	return b.dailyCap > 0 && pb.day == b.day() && pb.today.Sent >= b.dailyCap
+}
+
+// Peer returns the bandwidth used by the peer
+func (b *BandwidthAccounting) Peer(id enode.ID) (PeerBandwidth, bool) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	pb, ok := b.peers[id]
+	if !ok {
+		return PeerBandwidth{}, false
+	}
+	return b.peerBandwidth(id, pb), true
+}
+
+// Peers returns the bandwidth used by all peers, sorted by peer id
+func (b *BandwidthAccounting) Peers() []PeerBandwidth {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	peers := make([]PeerBandwidth, 0, len(b.peers))
+	for id, pb := range b.peers {
+		peers = append(peers, b.peerBandwidth(id, pb))
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return bytes.Compare(peers[i].Peer[:], peers[j].Peer[:]) < 0
+	})
+	return peers
+}
+
+// peerBandwidth returns a copy of the peer stats, must be called with the lock held
+func (b *BandwidthAccounting) peerBandwidth(id enode.ID, pb *peerBandwidth) PeerBandwidth {
+	p := PeerBandwidth{
+		Peer:      id,
+		Protocols: make(map[string]*BandwidthStats, len(pb.protocols)),
+		Capped:    b.capped(pb),
+	}
+	for name, stats := range pb.protocols {
+		s := *stats
+		p.Protocols[name] = &s
+	}
+	if pb.day == b.day() {
+		p.Today = pb.today
+	}
+	return p
+}
+
+// bandwidthRW accounts the messages of a protocol with a peer
+type bandwidthRW struct {
+	p2p.MsgReadWriter
+	accounting *BandwidthAccounting
+	peer       enode.ID
+	protocol   string
+	ctx        context.Context // cancelled when the protocol stops
+}
+
+func (rw *bandwidthRW) ReadMsg() (p2p.Msg, error) {
+	msg, err := rw.MsgReadWriter.ReadMsg()
+	if err != nil {
+		return msg, err
+	}
+	rw.accounting.add(rw.peer, rw.protocol, false, msg.Size)
+	metrics.GetOrRegisterCounter(fmt.Sprintf("bandwidth.%s.received", rw.protocol), nil).Inc(int64(msg.Size))
+	return msg, nil
+}
+
+func (rw *bandwidthRW) WriteMsg(msg p2p.Msg) error {
+	if throttle := rw.accounting.add(rw.peer, rw.protocol, true, msg.Size); throttle != nil {
+		metrics.GetOrRegisterCounter(fmt.Sprintf("bandwidth.%s.capped", rw.protocol), nil).Inc(1)
+		if err := wait(rw.ctx, throttle, int(msg.Size)); err != nil {
+			return err
+		}
+	}
+	metrics.GetOrRegisterCounter(fmt.Sprintf("bandwidth.%s.sent", rw.protocol), nil).Inc(int64(msg.Size))
+	return rw.MsgReadWriter.WriteMsg(msg)
+}
@@ -0,0 +1,47 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package protocols
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// BandwidthAPI exposes the bandwidth used by peers over RPC
+type BandwidthAPI struct {
+	accounting *BandwidthAccounting
+}
+
+// NewBandwidthAPI creates a new BandwidthAPI
+func NewBandwidthAPI(b *BandwidthAccounting) *BandwidthAPI {
+	return &BandwidthAPI{accounting: b}
+}
+
+// Peers returns the bytes sent to and received from all peers per protocol
+func (a *BandwidthAPI) Peers() []PeerBandwidth {
+	return a.accounting.Peers()
+}
+
+// Peer returns the bytes sent to and received from the peer per protocol
+func (a *BandwidthAPI) Peer(id enode.ID) (PeerBandwidth, error) {
+	p, ok := a.accounting.Peer(id)
+	if !ok {
+		return PeerBandwidth{}, errors.New("unknown peer")
+	}
+	return p, nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package protocols
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+func TestBandwidthAccounting(t *testing.T) {
+	now := time.Date(2019, 11, 1, 12, 0, 0, 0, time.UTC)
+	b := NewBandwidthAccounting(100)
+	b.now = func() time.Time { return now }
+
+	rwc := make(chan p2p.MsgReadWriter, 1)
+	done := make(chan struct{}, 1)
+	proto := b.Protocol(p2p.Protocol{
+		Name: "test",
+		Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+			rwc <- rw
+			<-done
+			return nil
+		},
+	})
+
+	id := enode.ID{1}
+	local, remote := p2p.MsgPipe()
+	defer local.Close()
+	go proto.Run(p2p.NewPeer(id, "peer", nil), local)
+	rw := <-rwc
+
+	// the remote end discards all messages sent to it
+	go func() {
+		for {
+			msg, err := remote.ReadMsg()
+			if err != nil {
+				return
+			}
+			msg.Discard()
+		}
+	}()
+
+	send := func(size int) error {
+		return rw.WriteMsg(p2p.Msg{Code: 0, Size: uint32(size), Payload: bytes.NewReader(make([]byte, size))})
+	}
+	receive := func(size int) {
+		go remote.WriteMsg(p2p.Msg{Code: 0, Size: uint32(size), Payload: bytes.NewReader(make([]byte, size))})
+		msg, err := rw.ReadMsg()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(msg.Payload)
+	}
+
+	if err := send(40); err != nil {
+		t.Fatal(err)
+	}
+	receive(30)
+	pb, ok := b.Peer(id)
+	if !ok {
+		t.Fatal("expected peer to be accounted")
+	}
+	expected := BandwidthStats{Sent: 40, Received: 30, MessagesSent: 1, MessagesReceived: 1}
+	if *pb.Protocols["test"] != expected || pb.Today != expected {
+		t.Fatalf("expected %+v, got %+v today %+v", expected, *pb.Protocols["test"], pb.Today)
+	}
+
+	// the message reaching the cap is sent, further messages are throttled
+	if err := send(40); err != nil {
+		t.Fatal(err)
+	}
+	// received bytes do not count against the cap
+	receive(10)
+	if pb, _ := b.Peer(id); pb.Capped || pb.Today.Sent != 80 || pb.Today.Received != 40 {
+		t.Fatalf("expected uncapped peer with 80 bytes sent and 40 received, got %+v", pb)
+	}
+	if err := send(20); err != nil {
+		t.Fatal(err)
+	}
+	if pb, _ := b.Peer(id); !pb.Capped {
+		t.Fatalf("expected capped peer, got %+v", pb)
+	}
+	start := time.Now()
+	if err := send(2 * cappedSendRate); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 500*time.Millisecond {
+		t.Fatalf("expected sending over the cap to be throttled, took %v", d)
+	}
+
+	// the cap is lifted the next day
+	now = now.Add(24 * time.Hour)
+	if err := send(10); err != nil {
+		t.Fatal(err)
+	}
+	pb, _ = b.Peer(id)
+	if pb.Capped || pb.Today.Sent != 10 || pb.Protocols["test"].Sent != 110+2*cappedSendRate {
+		t.Fatalf("expected 10 bytes sent today and %d in total, got %+v, total %+v", 110+2*cappedSendRate, pb, *pb.Protocols["test"])
+	}
+	if peers := b.Peers(); len(peers) != 1 || peers[0].Peer != id {
+		t.Fatalf("expected one peer, got %v", peers)
+	}
+
+	// the peer is forgotten once the protocol stops
+	done <- struct{}{}
+	for i := 0; i < 100; i++ {
+		if _, ok := b.Peer(id); !ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("expected the peer to be forgotten after disconnecting")
+}
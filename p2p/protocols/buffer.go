@@ -0,0 +1,67 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package protocols
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+// maxPooledBufferSize is the capacity above which buffers are not returned
+// to the pool, so that a few large messages do not keep memory allocated
+const maxPooledBufferSize = 1024 * 1024
+
+// bufferPool holds the buffers messages are encoded to and read into,
+// so that sending and receiving chunks does not allocate for each message
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer returns the buffer to the pool, the buffer
+// and the slices of its content must not be used afterwards
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// readPayload reads the payload of the message into the buffer
+// and returns it as a slice of the buffer content
+func readPayload(msg p2p.Msg, buf *bytes.Buffer) ([]byte, error) {
+	// bytes.Buffer.ReadFrom needs bytes.MinRead free space to detect the end of the payload
+	buf.Grow(int(msg.Size) + bytes.MinRead)
+	n, err := buf.ReadFrom(io.LimitReader(msg.Payload, int64(msg.Size)))
+	if err != nil {
+		return nil, err
+	}
+	if n != int64(msg.Size) {
+		return nil, fmt.Errorf("payload length %v, message size %v", n, msg.Size)
+	}
+	return buf.Bytes(), nil
+}
@@ -1,12 +1,10 @@
 package protocols
 
 import (
-	"bufio"
 	"bytes"
 	"context"
-	"io/ioutil"
+	"errors"
 
-	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethersphere/swarm/spancontext"
 	opentracing "github.com/opentracing/opentracing-go"
@@ -18,62 +16,81 @@ type msgWithContext struct {
 	Msg     []byte
 }
 
-func encodeWithContext(ctx context.Context, msg interface{}) (interface{}, int, error) {
+// encodeWithContext writes the message wrapped together with the span context
+// to buf and returns the length of the RLP encoding of the message
+func encodeWithContext(ctx context.Context, msg interface{}, buf *bytes.Buffer) (int, error) {
 	var b bytes.Buffer
-	writer := bufio.NewWriter(&b)
 	tracer := opentracing.GlobalTracer()
 	sctx := spancontext.FromContext(ctx)
 	if sctx != nil {
 		err := tracer.Inject(
 			sctx,
 			opentracing.Binary,
-			writer)
+			&b)
 		if err != nil {
-			return nil, 0, err
+			return 0, err
 		}
 	}
-	writer.Flush()
-	msgBytes, err := rlp.EncodeToBytes(msg)
-	if err != nil {
-		return nil, 0, err
+
+	msgBuf := getBuffer()
+	defer putBuffer(msgBuf)
+	if err := rlp.Encode(msgBuf, msg); err != nil {
+		return 0, err
 	}
 
-	return &msgWithContext{
+	err := rlp.Encode(buf, &msgWithContext{
 		Context: b.Bytes(),
-		Msg:     msgBytes,
-	}, len(msgBytes), nil
+		Msg:     msgBuf.Bytes(),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return msgBuf.Len(), nil
 }
 
-func decodeWithContext(msg p2p.Msg) (context.Context, []byte, error) {
-	var wmsg msgWithContext
-	err := msg.Decode(&wmsg)
+// decodeWithContext splits the payload into the span context and the RLP
+// encoding of the message. The returned message bytes refer to the payload.
+func decodeWithContext(payload []byte) (context.Context, []byte, error) {
+	content, _, err := rlp.SplitList(payload)
 	if err != nil {
 		return nil, nil, err
 	}
+	sctxBytes, content, err := rlp.SplitString(content)
+	if err != nil {
+		return nil, nil, err
+	}
+	msgBytes, content, err := rlp.SplitString(content)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(content) > 0 {
+		return nil, nil, errors.New("too many elements in message with context")
+	}
 
 	ctx := context.Background()
 
-	if len(wmsg.Context) == 0 {
-		return ctx, wmsg.Msg, nil
+	if len(sctxBytes) == 0 {
+		return ctx, msgBytes, nil
 	}
 
 	tracer := opentracing.GlobalTracer()
-	sctx, err := tracer.Extract(opentracing.Binary, bytes.NewReader(wmsg.Context))
+	sctx, err := tracer.Extract(opentracing.Binary, bytes.NewReader(sctxBytes))
 	if err != nil {
 		return nil, nil, err
 	}
 	ctx = spancontext.WithContext(ctx, sctx)
-	return ctx, wmsg.Msg, nil
+	return ctx, msgBytes, nil
 }
 
-func encodeWithoutContext(ctx context.Context, msg interface{}) (interface{}, int, error) {
-	return msg, 0, nil
+// encodeWithoutContext writes the RLP encoding of the message to buf
+// and returns its length
+func encodeWithoutContext(ctx context.Context, msg interface{}, buf *bytes.Buffer) (int, error) {
+	if err := rlp.Encode(buf, msg); err != nil {
+		return 0, err
+	}
+	return buf.Len(), nil
 }
 
-func decodeWithoutContext(msg p2p.Msg) (context.Context, []byte, error) {
-	b, err := ioutil.ReadAll(msg.Payload)
-	if err != nil {
-		return nil, nil, err
-	}
-	return context.Background(), b, nil
+func decodeWithoutContext(payload []byte) (context.Context, []byte, error) {
+	return context.Background(), payload, nil
 }
@@ -0,0 +1,188 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package protocols
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+const (
+	// DefaultMaxFragmentedMsgSize is the maximum accepted length of a
+	// reassembled message if the spec does not set one
+	DefaultMaxFragmentedMsgSize = 64 * 1024 * 1024
+	// DefaultFragmentTimeout is the maximum time between the first and the last
+	// fragment of a message if the spec does not set one
+	DefaultFragmentTimeout = time.Minute
+
+	// fragmentOverhead is an upper bound of the RLP encoding overhead
+	// of a fragment, excluding its data
+	fragmentOverhead = 64
+)
+
+// fragment is a part of a message that is too long to be sent in a single frame
+type fragment struct {
+	ID    uint64 // identifies the fragments of the same message
+	Code  uint64 // code of the fragmented message
+	Index uint32 // position of the fragment in the message, starting from 0
+	Total uint32 // number of fragments of the message
+	Data  []byte
+}
+
+// fragmentCode returns the message code reserved for fragments,
+// the one following the codes of the spec messages
+func (s *Spec) fragmentCode() uint64 {
+	return uint64(len(s.Messages))
+}
+
+// fragmentSize returns the length of the data carried by a fragment,
+// so that the fragment frame fits in the maximum message size
+func (s *Spec) fragmentSize() int {
+	size := int(s.FragmentSize)
+	if s.MaxMsgSize > fragmentOverhead && size > int(s.MaxMsgSize-fragmentOverhead) {
+		size = int(s.MaxMsgSize - fragmentOverhead)
+	}
+	return size
+}
+
+func (s *Spec) maxFragmentedMsgSize() uint32 {
+	if s.MaxFragmentedMsgSize == 0 {
+		return DefaultMaxFragmentedMsgSize
+	}
+	return s.MaxFragmentedMsgSize
+}
+
+func (s *Spec) fragmentTimeout() time.Duration {
+	if s.FragmentTimeout == 0 {
+		return DefaultFragmentTimeout
+	}
+	return s.FragmentTimeout
+}
+
+// write sends the encoded message with the code to the peer, split in fragments
+// if fragmentation is enabled and the encoded message does not fit in one
+func (p *Peer) write(code uint64, payload []byte) error {
+	size := p.spec.fragmentSize()
+	if p.spec.FragmentSize == 0 || len(payload) <= size {
+		return p.rw.WriteMsg(p2p.Msg{Code: code, Size: uint32(len(payload)), Payload: bytes.NewReader(payload)})
+	}
+	if uint64(len(payload)) > uint64(p.spec.maxFragmentedMsgSize()) {
+		return errorf(ErrMsgTooLong, "%v > %v", len(payload), p.spec.maxFragmentedMsgSize())
+	}
+	metrics.GetOrRegisterCounter("peer.send.fragmented", nil).Inc(1)
+
+	// fragments of different messages are never interleaved,
+	// so that the remote peer needs to reassemble only one message at a time
+	p.fragmentMtx.Lock()
+	defer p.fragmentMtx.Unlock()
+	p.fragmentID++
+	total := (len(payload) + size - 1) / size
+	for i := 0; i < total; i++ {
+		end := (i + 1) * size
+		if end > len(payload) {
+			end = len(payload)
+		}
+		f := &fragment{
+			ID:    p.fragmentID,
+			Code:  code,
+			Index: uint32(i),
+			Total: uint32(total),
+			Data:  payload[i*size : end],
+		}
+		if err := p2p.Send(p.rw, p.spec.fragmentCode(), f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reassembly is a message being reassembled from its fragments
+type reassembly struct {
+	id      uint64
+	code    uint64
+	next    uint32 // index of the next expected fragment
+	total   uint32
+	data    []byte
+	started time.Time
+}
+
+// reassemble adds the fragment received in the message to the message being
+// reassembled. It returns the reassembled message once the last fragment is
+// received, and nil otherwise. Fragments violating the protocol return an
+// error, which disconnects the peer.
+func (p *Peer) reassemble(msg p2p.Msg) (*p2p.Msg, error) {
+	var f fragment
+	if err := msg.Decode(&f); err != nil {
+		return nil, errorf(ErrDecode, "fragment: %v", err)
+	}
+	if _, ok := p.spec.NewMsg(f.Code); !ok {
+		return nil, errorf(ErrInvalidMsgCode, "fragment: %v", f.Code)
+	}
+	if f.Total < 2 || f.Index >= f.Total || len(f.Data) == 0 {
+		return nil, errorf(ErrDecode, "fragment %v of %v with %v bytes", f.Index, f.Total, len(f.Data))
+	}
+
+	now := time.Now()
+	r := p.reassembly
+	if r != nil && now.Sub(r.started) > p.spec.fragmentTimeout() {
+		metrics.GetOrRegisterCounter("peer.receive.fragment.timeout", nil).Inc(1)
+		log.Warn("discarding fragmented message", "peer", p.ID(), "code", r.code, "received", r.next, "total", r.total)
+		p.discardedFragmentID = r.id
+		p.reassembly = nil
+		r = nil
+	}
+	if r == nil {
+		if f.ID == p.discardedFragmentID {
+			// remaining fragments of a timed out message
+			return nil, nil
+		}
+		if f.Index != 0 {
+			return nil, errorf(ErrDecode, "fragment %v of message %v received first", f.Index, f.ID)
+		}
+		r = &reassembly{
+			id:      f.ID,
+			code:    f.Code,
+			total:   f.Total,
+			started: now,
+		}
+		p.reassembly = r
+	} else if f.ID != r.id || f.Code != r.code || f.Total != r.total || f.Index != r.next {
+		return nil, errorf(ErrDecode, "fragment %v of message %v received, expected %v of message %v", f.Index, f.ID, r.next, r.id)
+	}
+
+	if uint64(len(r.data))+uint64(len(f.Data)) > uint64(p.spec.maxFragmentedMsgSize()) {
+		return nil, errorf(ErrMsgTooLong, "fragmented message %v > %v", len(r.data)+len(f.Data), p.spec.maxFragmentedMsgSize())
+	}
+	r.data = append(r.data, f.Data...)
+	r.next++
+	if r.next < r.total {
+		return nil, nil
+	}
+
+	p.reassembly = nil
+	metrics.GetOrRegisterCounter("peer.receive.fragmented", nil).Inc(1)
+	return &p2p.Msg{
+		Code:       r.code,
+		Size:       uint32(len(r.data)),
+		Payload:    bytes.NewReader(r.data),
+		ReceivedAt: msg.ReceivedAt,
+	}, nil
+}
@@ -0,0 +1,242 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package protocols
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+type fragmentTestMsg struct {
+	Data []byte
+}
+
+func newFragmentTestSpec() *Spec {
+	return &Spec{
+		Name:                 "fragment-test",
+		Version:              1,
+		MaxMsgSize:           1024,
+		FragmentSize:         100,
+		MaxFragmentedMsgSize: 10 * 1024,
+		Messages:             []interface{}{fragmentTestMsg{}},
+		DisableContext:       true,
+	}
+}
+
+// newFragmentTestPeers returns two peers of the spec connected by a message pipe
+func newFragmentTestPeers(spec *Spec) (sender, receiver *Peer, cleanup func()) {
+	rw1, rw2 := p2p.MsgPipe()
+	sender = NewPeer(p2p.NewPeer(enode.ID{1}, "sender", nil), rw1, spec)
+	receiver = NewPeer(p2p.NewPeer(enode.ID{2}, "receiver", nil), rw2, spec)
+	return sender, receiver, func() {
+		rw1.Close()
+		rw2.Close()
+	}
+}
+
+// TestFragmentation tests that messages longer than the fragment size
+// are reassembled by the receiving peer, also when sent concurrently
+func TestFragmentation(t *testing.T) {
+	spec := newFragmentTestSpec()
+	if l := spec.Length(); l != 2 {
+		t.Fatalf("got spec length %v, want 2", l)
+	}
+	sender, receiver, cleanup := newFragmentTestPeers(spec)
+	defer cleanup()
+
+	sizes := []int{0, 10, 99, 100, 101, 1000, 5000, 10000}
+	errc := make(chan error, len(sizes))
+	for _, size := range sizes {
+		go func(size int) {
+			errc <- sender.Send(context.Background(), &fragmentTestMsg{Data: bytes.Repeat([]byte{byte(size)}, size)})
+		}(size)
+	}
+
+	received := make(map[int]bool)
+	handle := func(ctx context.Context, msg interface{}) error {
+		data := msg.(*fragmentTestMsg).Data
+		if !bytes.Equal(data, bytes.Repeat([]byte{byte(len(data))}, len(data))) {
+			return fmt.Errorf("corrupt message of length %v", len(data))
+		}
+		received[len(data)] = true
+		return nil
+	}
+	for len(received) < len(sizes) {
+		if err := receiver.handleIncoming(handle); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for range sizes {
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, size := range sizes {
+		if !received[size] {
+			t.Errorf("message of length %v not received", size)
+		}
+	}
+
+	err := sender.Send(context.Background(), &fragmentTestMsg{Data: make([]byte, 11*1024)})
+	if e, ok := err.(*Error); !ok || e.Code != ErrMsgTooLong {
+		t.Fatalf("got error %v sending message over the maximum length, want %v", err, errorToString[ErrMsgTooLong])
+	}
+}
+
+// TestFragmentationInvalid tests that fragments not following
+// each other in order or exceeding the limits disconnect the peer
+func TestFragmentationInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		fragments []fragment
+		code      int
+	}{
+		{
+			name: "first fragment missing",
+			fragments: []fragment{
+				{ID: 1, Code: 0, Index: 1, Total: 2, Data: []byte{1}},
+			},
+			code: ErrDecode,
+		},
+		{
+			name: "fragment out of order",
+			fragments: []fragment{
+				{ID: 1, Code: 0, Index: 0, Total: 3, Data: []byte{1}},
+				{ID: 1, Code: 0, Index: 2, Total: 3, Data: []byte{1}},
+			},
+			code: ErrDecode,
+		},
+		{
+			name: "fragments interleaved",
+			fragments: []fragment{
+				{ID: 1, Code: 0, Index: 0, Total: 2, Data: []byte{1}},
+				{ID: 2, Code: 0, Index: 0, Total: 2, Data: []byte{1}},
+			},
+			code: ErrDecode,
+		},
+		{
+			name: "single fragment",
+			fragments: []fragment{
+				{ID: 1, Code: 0, Index: 0, Total: 1, Data: []byte{1}},
+			},
+			code: ErrDecode,
+		},
+		{
+			name: "unknown message code",
+			fragments: []fragment{
+				{ID: 1, Code: 5, Index: 0, Total: 2, Data: []byte{1}},
+			},
+			code: ErrInvalidMsgCode,
+		},
+		{
+			name: "message too long",
+			fragments: []fragment{
+				{ID: 1, Code: 0, Index: 0, Total: 3, Data: make([]byte, 900)},
+				{ID: 1, Code: 0, Index: 1, Total: 3, Data: make([]byte, 900)},
+				{ID: 1, Code: 0, Index: 2, Total: 3, Data: make([]byte, 900)},
+			},
+			code: ErrMsgTooLong,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := newFragmentTestSpec()
+			spec.MaxFragmentedMsgSize = 2000
+			sender, receiver, cleanup := newFragmentTestPeers(spec)
+			defer cleanup()
+
+			go func() {
+				for i := range tc.fragments {
+					if err := p2p.Send(sender.rw, spec.fragmentCode(), &tc.fragments[i]); err != nil {
+						return
+					}
+				}
+			}()
+
+			handle := func(ctx context.Context, msg interface{}) error {
+				return fmt.Errorf("unexpected message %v", msg)
+			}
+			var err error
+			for range tc.fragments {
+				if err = receiver.handleIncoming(handle); err != nil {
+					break
+				}
+			}
+			if e, ok := err.(*Error); !ok || e.Code != tc.code {
+				t.Fatalf("got error %v, want %v", err, errorToString[tc.code])
+			}
+		})
+	}
+}
+
+// TestFragmentationTimeout tests that a message is discarded if its fragments
+// are not received within the timeout, without disconnecting the peer
+func TestFragmentationTimeout(t *testing.T) {
+	spec := newFragmentTestSpec()
+	spec.FragmentTimeout = 50 * time.Millisecond
+	sender, receiver, cleanup := newFragmentTestPeers(spec)
+	defer cleanup()
+
+	var mu sync.Mutex
+	var received []int
+	handle := func(ctx context.Context, msg interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, len(msg.(*fragmentTestMsg).Data))
+		return nil
+	}
+	errc := make(chan error)
+	go func() {
+		for {
+			if err := receiver.handleIncoming(handle); err != nil {
+				errc <- err
+				return
+			}
+		}
+	}()
+
+	send := func(f *fragment) {
+		if err := p2p.Send(sender.rw, spec.fragmentCode(), f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	send(&fragment{ID: 100, Code: 0, Index: 0, Total: 3, Data: []byte{1}})
+	time.Sleep(100 * time.Millisecond)
+	send(&fragment{ID: 100, Code: 0, Index: 1, Total: 3, Data: []byte{1}})
+	send(&fragment{ID: 100, Code: 0, Index: 2, Total: 3, Data: []byte{1}})
+
+	if err := sender.Send(context.Background(), &fragmentTestMsg{Data: make([]byte, 500)}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-errc:
+		t.Fatalf("peer disconnected: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 || received[0] != 500 {
+		t.Fatalf("got messages of lengths %v, want [500]", received)
+	}
+}
@@ -23,12 +23,14 @@ devp2p subprotocols by abstracting away code standardly shared by protocols.
 * provide the forever loop to read incoming messages
 * standardise error handling related to communication
 * standardised	handshake negotiation
+* optional fragmentation of messages too long to be sent in a single frame
 * TODO: automatic generation of wire protocol specification for peers
 
 */
 package protocols
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -133,6 +135,22 @@ type Spec struct {
 	// MaxMsgSize is the maximum accepted length of the message payload
 	MaxMsgSize uint32
 
+	// FragmentSize enables the fragmentation of messages. Messages with a
+	// longer encoding are split into fragments of this length, which are sent
+	// with an extra message code following the codes of Messages and
+	// reassembled by the remote peer. Both peers must use the same setting.
+	// Zero disables fragmentation.
+	FragmentSize uint32
+
+	// MaxFragmentedMsgSize is the maximum accepted length of a reassembled
+	// message, DefaultMaxFragmentedMsgSize if zero
+	MaxFragmentedMsgSize uint32
+
+	// FragmentTimeout is the maximum time between the first and the last
+	// fragment of a message, after which it is discarded,
+	// DefaultFragmentTimeout if zero
+	FragmentTimeout time.Duration
+
 	// Messages is a list of message data types which this protocol uses, with
 	// each message type being sent with its array index as the code (so
 	// [&foo{}, &bar{}, &baz{}] would send foo, bar and baz with codes
@@ -168,8 +186,12 @@ func (s *Spec) init() {
 	})
 }
 
-// Length returns the number of message types in the protocol
+// Length returns the number of message codes used by the protocol,
+// including the one reserved for fragments if fragmentation is enabled
 func (s *Spec) Length() uint64 {
+	if s.FragmentSize > 0 {
+		return uint64(len(s.Messages)) + 1
+	}
 	return uint64(len(s.Messages))
 }
 
@@ -201,8 +223,13 @@ type Peer struct {
 	*p2p.Peer                   // the p2p.Peer object representing the remote
 	rw        p2p.MsgReadWriter // p2p.MsgReadWriter to send messages to and read messages from
 	spec      *Spec
-	encode    func(context.Context, interface{}) (interface{}, int, error)
-	decode    func(p2p.Msg) (context.Context, []byte, error)
+	encode    func(context.Context, interface{}, *bytes.Buffer) (int, error)
+	decode    func([]byte) (context.Context, []byte, error)
+
+	fragmentMtx         sync.Mutex  // sends fragments of one message at a time
+	fragmentID          uint64      // id of the last fragmented message sent
+	reassembly          *reassembly // fragmented message being received
+	discardedFragmentID uint64      // id of the last fragmented message timed out
 }
 
 // NewPeer constructs a new peer
@@ -264,19 +291,14 @@ func (p *Peer) Send(ctx context.Context, msg interface{}) error {
 		return errorf(ErrInvalidMsgType, "%v", code)
 	}
 
-	wmsg, size, err := p.encode(ctx, msg)
+	// the message is encoded only once, to a pooled buffer which is
+	// reused after the payload is consumed by the underlying writer
+	buf := getBuffer()
+	defer putBuffer(buf)
+	size, err := p.encode(ctx, msg, buf)
 	if err != nil {
 		return err
 	}
-
-	// if size is not set by the wrapper, need to serialise
-	if size == 0 {
-		r, err := rlp.EncodeToBytes(msg)
-		if err != nil {
-			return err
-		}
-		size = len(r)
-	}
 	// if the accounting hook is set, call it
 	if p.spec.Hook != nil {
 		err = p.spec.Hook.Send(p, uint32(size), msg)
@@ -285,7 +307,14 @@ func (p *Peer) Send(ctx context.Context, msg interface{}) error {
 		}
 	}
 
-	return p2p.Send(p.rw, code, wmsg)
+	if err := p.write(code, buf.Bytes()); err != nil {
+		return err
+	}
+	// only messages which were actually sent are recorded
+	p.recordMsg(true, code, func() ([]byte, error) {
+		return rlp.EncodeToBytes(msg)
+	})
+	return nil
 }
 
 // handleIncoming(code)
@@ -293,6 +322,7 @@ func (p *Peer) Send(ctx context.Context, msg interface{}) error {
 // if this returns an error the loop returns and the peer is disconnected with the error
 // this generic handler
 // * checks message size,
+// * reassembles fragmented messages,
 // * checks for out-of-range message codes,
 // * handles decoding with reflection,
 // * call handlers as callbacks
@@ -308,15 +338,34 @@ func (p *Peer) handleIncoming(handle func(ctx context.Context, msg interface{})
 		return errorf(ErrMsgTooLong, "%v > %v", msg.Size, p.spec.MaxMsgSize)
 	}
 
+	if p.spec.FragmentSize > 0 && msg.Code == p.spec.fragmentCode() {
+		rmsg, err := p.reassemble(msg)
+		if err != nil || rmsg == nil {
+			return err
+		}
+		msg = *rmsg
+	}
+
 	val, ok := p.spec.NewMsg(msg.Code)
 	if !ok {
 		return errorf(ErrInvalidMsgCode, "%v", msg.Code)
 	}
 
-	ctx, msgBytes, err := p.decode(msg)
+	// the payload is read to a pooled buffer, decoding the message copies
+	// the data it keeps so the buffer can be reused once it is decoded
+	buf := getBuffer()
+	defer putBuffer(buf)
+	payload, err := readPayload(msg, buf)
 	if err != nil {
 		return errorf(ErrDecode, "%v err=%v", msg.Code, err)
 	}
+	ctx, msgBytes, err := p.decode(payload)
+	if err != nil {
+		return errorf(ErrDecode, "%v err=%v", msg.Code, err)
+	}
+	p.recordMsg(false, msg.Code, func() ([]byte, error) {
+		return append([]byte(nil), msgBytes...), nil
+	})
 
 	if err := rlp.DecodeBytes(msgBytes, val); err != nil {
 		return errorf(ErrDecode, "<= %v: %v", msg, err)
@@ -19,8 +19,11 @@ package protocols
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"sync"
 	"testing"
 	"time"
@@ -589,7 +592,6 @@ func TestMultiplePeersDropOther(t *testing.T) {
 //having to build up the complete protocol
 type dummyRW struct {
 	msg  interface{}
-	size uint32
 	code uint64
 }
 
@@ -605,8 +607,102 @@ func (d *dummyRW) ReadMsg() (p2p.Msg, error) {
 	enc := bytes.NewReader(r)
 	return p2p.Msg{
 		Code:       d.code,
-		Size:       d.size,
+		Size:       uint32(len(r)),
 		Payload:    enc,
 		ReceivedAt: time.Now(),
 	}, nil
 }
+
+// benchDelivery has the shape of a chunk delivery message
+type benchDelivery struct {
+	Ruid  uint
+	Addr  []byte
+	SData []byte
+}
+
+// discardRW consumes the payload of the written messages and
+// returns the same encoded message on each read
+type discardRW struct {
+	code    uint64
+	payload []byte
+}
+
+func (d *discardRW) WriteMsg(msg p2p.Msg) error {
+	_, err := io.Copy(ioutil.Discard, msg.Payload)
+	return err
+}
+
+func (d *discardRW) ReadMsg() (p2p.Msg, error) {
+	return p2p.Msg{
+		Code:    d.code,
+		Size:    uint32(len(d.payload)),
+		Payload: bytes.NewReader(d.payload),
+	}, nil
+}
+
+func newBenchDeliveryPeer(b *testing.B, withContext bool) (*Peer, *benchDelivery) {
+	spec := &Spec{
+		Name:           "bench",
+		Version:        1,
+		MaxMsgSize:     10 * 1024 * 1024,
+		Messages:       []interface{}{benchDelivery{}},
+		DisableContext: !withContext,
+	}
+	msg := &benchDelivery{
+		Ruid:  1,
+		Addr:  make([]byte, 32),
+		SData: make([]byte, 4096+8),
+	}
+	rand.Read(msg.SData)
+	p := NewPeer(p2p.NewPeer(enode.ID{}, "bench", nil), nil, spec)
+	if withContext {
+		// context propagation is only selected by NewPeer if tracing is enabled
+		p.encode, p.decode = encodeWithContext, decodeWithContext
+	}
+	var payload bytes.Buffer
+	if _, err := p.encode(context.Background(), msg, &payload); err != nil {
+		b.Fatal(err)
+	}
+	p.rw = &discardRW{payload: payload.Bytes()}
+	return p, msg
+}
+
+// BenchmarkSendChunkDelivery measures the encoding of chunk deliveries
+func BenchmarkSendChunkDelivery(b *testing.B) {
+	for _, withContext := range []bool{false, true} {
+		b.Run(fmt.Sprintf("context=%v", withContext), func(b *testing.B) {
+			p, msg := newBenchDeliveryPeer(b, withContext)
+			b.ReportAllocs()
+			b.SetBytes(int64(len(msg.SData)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := p.Send(context.Background(), msg); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkHandleChunkDelivery measures the decoding of chunk deliveries
+func BenchmarkHandleChunkDelivery(b *testing.B) {
+	for _, withContext := range []bool{false, true} {
+		b.Run(fmt.Sprintf("context=%v", withContext), func(b *testing.B) {
+			p, msg := newBenchDeliveryPeer(b, withContext)
+			handle := func(ctx context.Context, m interface{}) error {
+				if len(m.(*benchDelivery).SData) != len(msg.SData) {
+					return errors.New("invalid chunk data length")
+				}
+				return nil
+			}
+			b.ReportAllocs()
+			b.SetBytes(int64(len(msg.SData)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := p.handleIncoming(handle); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
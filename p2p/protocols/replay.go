@@ -0,0 +1,223 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package protocols
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// ReplayRecord is a protocol message recorded in a replay log
+type ReplayRecord struct {
+	Time     uint64   // unix time of the message in nanoseconds
+	Peer     enode.ID // the remote peer
+	Protocol string   // protocol name
+	Version  uint     // protocol version
+	Outgoing bool     // whether the message was sent to the peer or received from it
+	Code     uint64   // message code
+	Data     []byte   // RLP encoded message
+}
+
+// String pretty prints a ReplayRecord
+func (r ReplayRecord) String() string {
+	dir := "<-"
+	if r.Outgoing {
+		dir = "->"
+	}
+	return fmt.Sprintf("%s %s %s %s/%d code %d (%d bytes)", time.Unix(0, int64(r.Time)).Format(time.RFC3339Nano), r.Peer.TerminalString(), dir, r.Protocol, r.Version, r.Code, len(r.Data))
+}
+
+// ReplayRecorder writes the protocol messages exchanged with selected peers
+// on selected protocols to a replay log. The log is a sequence of RLP encoded
+// ReplayRecords that can be read with ReplayReader.
+type ReplayRecorder struct {
+	mtx       sync.Mutex
+	w         *bufio.Writer
+	closer    io.Closer
+	protocols map[string]bool   // recorded protocols, all if empty
+	peers     map[enode.ID]bool // recorded peers, all if empty
+}
+
+// NewReplayRecorder creates a ReplayRecorder writing to w the messages of
+// the given protocols and peers. If protocols or peers are empty, the
+// messages of all protocols or peers are recorded.
+func NewReplayRecorder(w io.Writer, protocols []string, peers []enode.ID) *ReplayRecorder {
+	r := &ReplayRecorder{
+		w:         bufio.NewWriter(w),
+		protocols: make(map[string]bool),
+		peers:     make(map[enode.ID]bool),
+	}
+	if c, ok := w.(io.Closer); ok {
+		r.closer = c
+	}
+	for _, p := range protocols {
+		r.protocols[p] = true
+	}
+	for _, id := range peers {
+		r.peers[id] = true
+	}
+	return r
+}
+
+// OpenReplayRecorder creates a ReplayRecorder appending to the replay log at path
+func OpenReplayRecorder(path string, protocols []string, peers []enode.ID) (*ReplayRecorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayRecorder(f, protocols, peers), nil
+}
+
+// Close flushes the replay log and closes the underlying writer if it is an io.Closer
+func (r *ReplayRecorder) Close() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if err := r.w.Flush(); err != nil {
+		return err
+	}
+	if r.closer != nil {
+		return r.closer.Close()
+	}
+	return nil
+}
+
+// Records returns true if messages of the protocol with the peer are recorded
+func (r *ReplayRecorder) Records(protocol string, peer enode.ID) bool {
+	if len(r.protocols) > 0 && !r.protocols[protocol] {
+		return false
+	}
+	if len(r.peers) > 0 && !r.peers[peer] {
+		return false
+	}
+	return true
+}
+
+// record writes a message to the replay log. Records are flushed right away
+// so that the log is complete up to a crash.
+func (r *ReplayRecorder) record(rec *ReplayRecord) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if err := rlp.Encode(r.w, rec); err != nil {
+		log.Error("replay log write failed", "err", err)
+		return
+	}
+	if err := r.w.Flush(); err != nil {
+		log.Error("replay log flush failed", "err", err)
+	}
+}
+
+var (
+	replayRecorderMu sync.RWMutex
+	replayRecorder   *ReplayRecorder // recorder of all protocol peers, nil if recording is disabled
+)
+
+// SetReplayRecorder sets the recorder of the messages of all protocol peers,
+// a nil recorder disables recording
+func SetReplayRecorder(r *ReplayRecorder) {
+	replayRecorderMu.Lock()
+	defer replayRecorderMu.Unlock()
+	replayRecorder = r
+}
+
+// recordMsg records a message exchanged with the peer if it is selected
+// for recording. msg is called only if the message is recorded and returns
+// the RLP encoding of the message.
+func (p *Peer) recordMsg(outgoing bool, code uint64, msg func() ([]byte, error)) {
+	replayRecorderMu.RLock()
+	r := replayRecorder
+	replayRecorderMu.RUnlock()
+	if r == nil || p.Peer == nil || !r.Records(p.spec.Name, p.ID()) {
+		return
+	}
+	data, err := msg()
+	if err != nil {
+		log.Error("replay log encoding failed", "peer", p.ID(), "code", code, "err", err)
+		return
+	}
+	r.record(&ReplayRecord{
+		Time:     uint64(time.Now().UnixNano()),
+		Peer:     p.ID(),
+		Protocol: p.spec.Name,
+		Version:  p.spec.Version,
+		Outgoing: outgoing,
+		Code:     code,
+		Data:     data,
+	})
+}
+
+// ReplayReader reads the records of a replay log
+type ReplayReader struct {
+	s *rlp.Stream
+}
+
+// NewReplayReader creates a ReplayReader reading a replay log from r
+func NewReplayReader(r io.Reader) *ReplayReader {
+	return &ReplayReader{
+		s: rlp.NewStream(bufio.NewReader(r), 0),
+	}
+}
+
+// Next returns the next record of the replay log, or io.EOF at the end of the log
+func (r *ReplayReader) Next() (*ReplayRecord, error) {
+	var rec ReplayRecord
+	if err := r.s.Decode(&rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// Replay reads a replay log from r and calls handler with every message received
+// on the protocol of spec, in the recorded order. If peer is not the zero ID,
+// only the messages received from that peer are replayed. Replay is meant to
+// reproduce recorded message sequences against protocol handlers in unit tests.
+func Replay(r io.Reader, spec *Spec, peer enode.ID, handler func(ctx context.Context, msg interface{}) error) error {
+	reader := NewReplayReader(r)
+	for i := 0; ; i++ {
+		rec, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("replay record %d: %v", i, err)
+		}
+		if rec.Outgoing || rec.Protocol != spec.Name || (peer != (enode.ID{}) && rec.Peer != peer) {
+			continue
+		}
+		if rec.Version != spec.Version {
+			return fmt.Errorf("replay record %d: recorded with %s version %d, have %d", i, rec.Protocol, rec.Version, spec.Version)
+		}
+		msg, ok := spec.NewMsg(rec.Code)
+		if !ok {
+			return fmt.Errorf("replay record %d: %v", i, errorf(ErrInvalidMsgCode, "%v", rec.Code))
+		}
+		if err := rlp.DecodeBytes(rec.Data, msg); err != nil {
+			return fmt.Errorf("replay record %d: %v", i, errorf(ErrDecode, "%v: %v", rec.Code, err))
+		}
+		if err := handler(context.Background(), msg); err != nil {
+			return fmt.Errorf("replay record %d: %v", i, errorf(ErrHandler, "(msg code %v): %v", rec.Code, err))
+		}
+	}
+}
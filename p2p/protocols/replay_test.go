@@ -0,0 +1,120 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package protocols
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
+)
+
+// failingRW fails to write any message
+type failingRW struct {
+	dummyRW
+}
+
+func (f *failingRW) WriteMsg(msg p2p.Msg) error {
+	return errors.New("write failed")
+}
+
+// TestReplay checks that messages exchanged with a selected peer are
+// recorded in the replay log and that incoming ones can be replayed
+func TestReplay(t *testing.T) {
+	spec := createTestSpec()
+	id := adapters.RandomNodeConfig().ID
+	other := adapters.RandomNodeConfig().ID
+
+	buf := new(bytes.Buffer)
+	r := NewReplayRecorder(buf, []string{spec.Name}, []enode.ID{id})
+	SetReplayRecorder(r)
+	defer SetReplayRecorder(nil)
+
+	handler := func(ctx context.Context, msg interface{}) error {
+		return nil
+	}
+	exchange := func(id enode.ID, in, out string) {
+		rw := &dummyRW{}
+		peer := NewPeer(p2p.NewPeer(id, "testPeer", nil), rw, spec)
+		if err := peer.Send(context.TODO(), &perBytesMsgSenderPays{Content: out}); err != nil {
+			t.Fatal(err)
+		}
+		rw.msg = &perBytesMsgSenderPays{Content: in}
+		rw.code = 1
+		if err := peer.handleIncoming(handler); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exchange(id, "in1", "out1")
+	exchange(other, "ignored", "ignored")
+	exchange(id, "in2", "out2")
+
+	// messages which fail to be sent are not recorded
+	peer := NewPeer(p2p.NewPeer(id, "testPeer", nil), &failingRW{}, spec)
+	if err := peer.Send(context.TODO(), &perBytesMsgSenderPays{Content: "failed"}); err == nil {
+		t.Fatal("expected error sending to a failing peer")
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// all messages of the selected peer are recorded
+	reader := NewReplayReader(bytes.NewReader(buf.Bytes()))
+	var outgoing, incoming int
+	for {
+		rec, err := reader.Next()
+		if err != nil {
+			break
+		}
+		if rec.Peer != id || rec.Protocol != spec.Name || rec.Version != spec.Version || rec.Code != 1 {
+			t.Fatalf("unexpected record %v", rec)
+		}
+		if rec.Outgoing {
+			outgoing++
+		} else {
+			incoming++
+		}
+	}
+	if outgoing != 2 || incoming != 2 {
+		t.Fatalf("expected 2 outgoing and 2 incoming records, got %v and %v", outgoing, incoming)
+	}
+
+	// only incoming messages are replayed, in order
+	var replayed []string
+	err := Replay(bytes.NewReader(buf.Bytes()), spec, id, func(ctx context.Context, msg interface{}) error {
+		replayed = append(replayed, msg.(*perBytesMsgSenderPays).Content)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replayed) != 2 || replayed[0] != "in1" || replayed[1] != "in2" {
+		t.Fatalf("expected replayed messages [in1 in2], got %v", replayed)
+	}
+
+	// a different protocol version can not be replayed
+	spec = createTestSpec()
+	spec.Version++
+	if err := Replay(bytes.NewReader(buf.Bytes()), spec, id, handler); err == nil {
+		t.Fatal("expected error replaying with a different protocol version")
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package protocols
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p"
+	"golang.org/x/time/rate"
+)
+
+// BandwidthScheduler limits the upstream bandwidth of the node with token buckets,
+// one shared by all protocols and one per protocol.
+// Sending a message waits until both buckets hold enough tokens for its size.
+// The limits can be changed with SetLimits while protocols are running.
+type BandwidthScheduler struct {
+	mtx       sync.Mutex
+	total     *limiter
+	protocols map[string]*limiter
+}
+
+// NewBandwidthScheduler creates a scheduler limiting the upstream bandwidth of all
+// protocols to total bytes per second and of the given protocols to their own
+// bytes per second. Zero limits are not enforced.
+func NewBandwidthScheduler(total uint64, protocols map[string]uint64) *BandwidthScheduler {
+	s := &BandwidthScheduler{
+		total:     newLimiter(total),
+		protocols: make(map[string]*limiter),
+	}
+	for name, limit := range protocols {
+		s.protocols[name] = newLimiter(limit)
+	}
+	return s
+}
+
+// SetLimits changes the limit shared by all protocols and the limits of
+// the given protocols, in bytes per second. Protocols that are not given
+// keep their limits. Zero limits are not enforced.
+func (s *BandwidthScheduler) SetLimits(total uint64, protocols map[string]uint64) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.total.set(total)
+	for name, limit := range protocols {
+		if l, ok := s.protocols[name]; ok {
+			l.set(limit)
+			continue
+		}
+		s.protocols[name] = newLimiter(limit)
+	}
+}
+
+// limiter returns the limiter of the protocol, creating an unlimited one
+// if the protocol has none, so that its limit can be set later
+func (s *BandwidthScheduler) limiter(name string) *limiter {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	l, ok := s.protocols[name]
+	if !ok {
+		l = newLimiter(0)
+		s.protocols[name] = l
+	}
+	return l
+}
+
+// Protocol wraps the protocol so that its messages are sent within the limits
+func (s *BandwidthScheduler) Protocol(proto p2p.Protocol) p2p.Protocol {
+	l := s.limiter(proto.Name)
+	run := proto.Run
+	proto.Run = func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		return run(p, &scheduledRW{
+			MsgReadWriter: rw,
+			protocol:      proto.Name,
+			limiters:      []*limiter{l, s.total},
+			ctx:           ctx,
+		})
+	}
+	return proto
+}
+
+// limiter holds a token bucket that is replaced when the limit changes
+type limiter struct {
+	mtx    sync.RWMutex
+	bucket *rate.Limiter // nil if the limit is not enforced
+}
+
+// newLimiter returns a limiter with a token bucket refilled with limit bytes
+// per second holding up to one second worth of tokens, unlimited if limit is 0
+func newLimiter(limit uint64) *limiter {
+	l := &limiter{}
+	l.set(limit)
+	return l
+}
+
+func (l *limiter) set(limit uint64) {
+	var bucket *rate.Limiter
+	if limit > 0 {
+		bucket = rate.NewLimiter(rate.Limit(limit), int(limit))
+	}
+	l.mtx.Lock()
+	l.bucket = bucket
+	l.mtx.Unlock()
+}
+
+func (l *limiter) get() *rate.Limiter {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+	return l.bucket
+}
+
+// scheduledRW delays outgoing messages until the token buckets allow them
+type scheduledRW struct {
+	p2p.MsgReadWriter
+	protocol string
+	limiters []*limiter
+	ctx      context.Context // cancelled when the protocol stops
+}
+
+func (rw *scheduledRW) WriteMsg(msg p2p.Msg) error {
+	start := time.Now()
+	for _, l := range rw.limiters {
+		bucket := l.get()
+		if bucket == nil {
+			continue
+		}
+		if err := wait(rw.ctx, bucket, int(msg.Size)); err != nil {
+			return err
+		}
+	}
+	metrics.GetOrRegisterResettingTimer(fmt.Sprintf("bandwidth.scheduler.%s.wait", rw.protocol), nil).UpdateSince(start)
+	return rw.MsgReadWriter.WriteMsg(msg)
+}
+
+// wait takes n tokens from the bucket, in several steps if n exceeds its capacity
+func wait(ctx context.Context, l *rate.Limiter, n int) error {
+	for n > 0 {
+		k := n
+		if b := l.Burst(); k > b {
+			k = b
+		}
+		if err := l.WaitN(ctx, k); err != nil {
+			return err
+		}
+		n -= k
+	}
+	return nil
+}
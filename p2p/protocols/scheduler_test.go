@@ -0,0 +1,159 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package protocols
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// TestBandwidthScheduler checks that protocols are limited by their own
+// token bucket and by the one shared by all protocols
+func TestBandwidthScheduler(t *testing.T) {
+	s := NewBandwidthScheduler(40000, map[string]uint64{"limited": 10000})
+	send := newSchedulerSender(t, s)
+
+	// the bucket of the protocol allows a burst of 10000 bytes, the rest is sent at 10000 bytes per second
+	if d := send("limited", 15000); d < 400*time.Millisecond {
+		t.Fatalf("expected protocol to be limited, sent in %v", d)
+	}
+	// the shared bucket allows a burst of at most 40000 bytes and is refilled at 40000 bytes per second
+	if d := send("other", 20000); d > 400*time.Millisecond {
+		t.Fatalf("expected other protocol not to be limited by the protocol bucket, sent in %v", d)
+	}
+	if d := send("other", 60000); d < 400*time.Millisecond {
+		t.Fatalf("expected other protocol to be limited by the shared bucket, sent in %v", d)
+	}
+}
+
+// TestBandwidthSchedulerSetLimits checks that limits changed with SetLimits
+// apply to protocols that are already running
+func TestBandwidthSchedulerSetLimits(t *testing.T) {
+	s := NewBandwidthScheduler(0, nil)
+	send := newSchedulerSender(t, s)
+
+	if d := send("free", 100000); d > 400*time.Millisecond {
+		t.Fatalf("expected protocol without limits not to be limited, sent in %v", d)
+	}
+
+	s.SetLimits(0, map[string]uint64{"free": 10000})
+	if d := send("free", 15000); d < 400*time.Millisecond {
+		t.Fatalf("expected protocol to be limited after setting its limit, sent in %v", d)
+	}
+
+	s.SetLimits(0, map[string]uint64{"free": 0})
+	if d := send("free", 100000); d > 400*time.Millisecond {
+		t.Fatalf("expected protocol not to be limited after removing its limit, sent in %v", d)
+	}
+}
+
+// TestBandwidthSchedulerStop checks that a message waiting for tokens
+// is not sent once the protocol stopped
+func TestBandwidthSchedulerStop(t *testing.T) {
+	s := NewBandwidthScheduler(0, map[string]uint64{"limited": 100})
+	rwc := make(chan p2p.MsgReadWriter, 1)
+	done := make(chan struct{})
+	proto := s.Protocol(p2p.Protocol{
+		Name: "limited",
+		Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+			rwc <- rw
+			<-done
+			return nil
+		},
+	})
+	local, remote := p2p.MsgPipe()
+	defer local.Close()
+	stopped := make(chan struct{})
+	go func() {
+		proto.Run(p2p.NewPeer(enode.ID{1}, "peer", nil), local)
+		close(stopped)
+	}()
+	rw := <-rwc
+	go func() {
+		for {
+			msg, err := remote.ReadMsg()
+			if err != nil {
+				return
+			}
+			msg.Discard()
+		}
+	}()
+
+	// the first message takes the burst, the second waits for ten seconds
+	errc := make(chan error, 2)
+	go func() {
+		for _, size := range []uint32{100, 1000} {
+			errc <- rw.WriteMsg(p2p.Msg{Code: 0, Size: size, Payload: bytes.NewReader(make([]byte, size))})
+		}
+	}()
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	close(done)
+	<-stopped
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Fatal("expected the waiting message not to be sent")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message still waiting for tokens after the protocol stopped")
+	}
+}
+
+// newSchedulerSender returns a function that measures the time it takes
+// to send size bytes in messages of 1000 bytes with a protocol wrapped by s
+func newSchedulerSender(t *testing.T, s *BandwidthScheduler) func(name string, size int) time.Duration {
+	return func(name string, size int) time.Duration {
+		rwc := make(chan p2p.MsgReadWriter, 1)
+		done := make(chan struct{})
+		defer close(done)
+		proto := s.Protocol(p2p.Protocol{
+			Name: name,
+			Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+				rwc <- rw
+				<-done
+				return nil
+			},
+		})
+		local, remote := p2p.MsgPipe()
+		defer local.Close()
+		go proto.Run(p2p.NewPeer(enode.ID{1}, "peer", nil), local)
+		rw := <-rwc
+		go func() {
+			for {
+				msg, err := remote.ReadMsg()
+				if err != nil {
+					return
+				}
+				msg.Discard()
+			}
+		}()
+
+		start := time.Now()
+		for sent := 0; sent < size; sent += 1000 {
+			if err := rw.WriteMsg(p2p.Msg{Code: 0, Size: 1000, Payload: bytes.NewReader(make([]byte, 1000))}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return time.Since(start)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package protocols
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// default limits of worker pools
+const (
+	DefaultWorkers     = 512
+	DefaultWorkerQueue = 64
+)
+
+var (
+	// ErrWorkerPoolStopped is returned when submitting a handler to a stopped pool
+	ErrWorkerPoolStopped = errors.New("worker pool stopped")
+	// ErrWorkerPoolQuit is returned when the submission is abandoned
+	// while waiting for a handler of the peer to finish
+	ErrWorkerPoolQuit = errors.New("worker pool submission abandoned")
+	// ErrWorkerPoolFull is returned when a handler that may be dropped is
+	// submitted while the peer has as many handlers as allowed, the handler is dropped
+	ErrWorkerPoolFull = errors.New("worker pool full")
+)
+
+// WorkerPoolParams are the limits of a WorkerPool
+type WorkerPoolParams struct {
+	Workers int // maximum number of handlers running concurrently
+	Queue   int // maximum number of handlers of a single peer waiting or running, submitting more blocks
+}
+
+// NewWorkerPoolParams returns the default worker pool limits
+func NewWorkerPoolParams() *WorkerPoolParams {
+	return &WorkerPoolParams{
+		Workers: DefaultWorkers,
+		Queue:   DefaultWorkerQueue,
+	}
+}
+
+// WorkerPool runs message handlers on a bounded number of goroutines.
+// Workers are started on demand and exit when there are no queued
+// handlers, so an idle pool has no goroutines.
+//
+// Handlers are submitted through the PeerWorkers of a peer, which bound
+// the handlers of the peer waiting or running. Submitting more blocks,
+// so a peer flooding messages stalls its own read loop instead of
+// spawning goroutines or taking the queue from other peers.
+type WorkerPool struct {
+	name    string
+	workers int // maximum number of running workers
+	peerMax int // maximum number of handlers of a peer
+
+	mu      sync.Mutex
+	queue   []func() // handlers waiting for a worker
+	running int      // number of running workers
+
+	quit chan struct{}
+	stop sync.Once
+}
+
+// NewWorkerPool creates a worker pool with the limits, the name is used in metrics
+func NewWorkerPool(name string, params *WorkerPoolParams) *WorkerPool {
+	if params == nil {
+		params = NewWorkerPoolParams()
+	}
+	workers, queue := params.Workers, params.Queue
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	if queue <= 0 {
+		queue = DefaultWorkerQueue
+	}
+	return &WorkerPool{
+		name:    name,
+		workers: workers,
+		peerMax: queue,
+		quit:    make(chan struct{}),
+	}
+}
+
+// submit queues the handler and starts a worker for it if the limit is not
+// reached, otherwise a running worker takes the handler
+func (w *WorkerPool) submit(handler func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.running < w.workers {
+		w.running++
+		go w.work(handler)
+		return
+	}
+	w.queue = append(w.queue, handler)
+}
+
+// work runs the handler and then the queued ones until the queue is empty
+func (w *WorkerPool) work(handler func()) {
+	for {
+		handler()
+		w.mu.Lock()
+		if len(w.queue) == 0 {
+			w.running--
+			w.mu.Unlock()
+			return
+		}
+		handler = w.queue[0]
+		w.queue[0] = nil
+		w.queue = w.queue[1:]
+		w.mu.Unlock()
+	}
+}
+
+// Stop makes the pool reject new handlers,
+// the queued ones are still run
+func (w *WorkerPool) Stop() {
+	w.stop.Do(func() {
+		close(w.quit)
+	})
+}
+
+// PeerWorkers submits the handlers of the messages of a single peer to a WorkerPool
+type PeerWorkers struct {
+	pool  *WorkerPool
+	slots chan struct{}   // slots of the handlers of the peer waiting or running
+	quit  <-chan struct{} // closed when submissions should be abandoned
+}
+
+// NewPeerWorkers returns the PeerWorkers of a peer. Blocked submissions
+// are abandoned when the quit channel is closed or the pool is stopped.
+func (w *WorkerPool) NewPeerWorkers(quit <-chan struct{}) *PeerWorkers {
+	return &PeerWorkers{
+		pool:  w,
+		slots: make(chan struct{}, w.peerMax),
+		quit:  quit,
+	}
+}
+
+// Submit queues the handler to be run by a worker. It blocks while the peer
+// has as many handlers waiting or running as allowed, until one of them
+// finishes, the quit channel is closed or the pool is stopped.
+func (p *PeerWorkers) Submit(handler func()) error {
+	select {
+	case <-p.pool.quit:
+		return ErrWorkerPoolStopped
+	default:
+	}
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		metrics.GetOrRegisterCounter(fmt.Sprintf("workerpool.%s.full", p.pool.name), nil).Inc(1)
+		select {
+		case p.slots <- struct{}{}:
+		case <-p.quit:
+			return ErrWorkerPoolQuit
+		case <-p.pool.quit:
+			return ErrWorkerPoolStopped
+		}
+	}
+	p.submit(handler)
+	return nil
+}
+
+// TrySubmit queues the handler to be run by a worker, like Submit, but it
+// drops the handler and returns ErrWorkerPoolFull instead of blocking.
+// It is meant for idempotent requests, which the peer retries.
+func (p *PeerWorkers) TrySubmit(handler func()) error {
+	select {
+	case <-p.pool.quit:
+		return ErrWorkerPoolStopped
+	default:
+	}
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		metrics.GetOrRegisterCounter(fmt.Sprintf("workerpool.%s.dropped", p.pool.name), nil).Inc(1)
+		return ErrWorkerPoolFull
+	}
+	p.submit(handler)
+	return nil
+}
+
+// submit queues the handler to the pool, its slot is freed once it has run
+func (p *PeerWorkers) submit(handler func()) {
+	p.pool.submit(func() {
+		defer func() { <-p.slots }()
+		handler()
+	})
+}
@@ -0,0 +1,167 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package protocols
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestWorkerPool tests that the pool runs all submitted handlers
+// without exceeding the worker limit, and that no worker is left
+// running once the handlers are done
+func TestWorkerPool(t *testing.T) {
+	const workers = 4
+	w := NewWorkerPool("test", &WorkerPoolParams{Workers: workers, Queue: 10})
+
+	var running, max, done int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		p := w.NewPeerWorkers(nil)
+		for j := 0; j < 10; j++ {
+			wg.Add(1)
+			err := p.Submit(func() {
+				defer wg.Done()
+				n := atomic.AddInt32(&running, 1)
+				for {
+					m := atomic.LoadInt32(&max)
+					if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				atomic.AddInt32(&done, 1)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	wg.Wait()
+
+	if done != 100 {
+		t.Fatalf("got %v handlers run, want 100", done)
+	}
+	if max > workers {
+		t.Fatalf("got %v handlers running concurrently, want at most %v", max, workers)
+	}
+	for i := 0; ; i++ {
+		w.mu.Lock()
+		running := w.running
+		w.mu.Unlock()
+		if running == 0 {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("got %v workers running after all handlers finished", running)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestWorkerPoolFull tests that submitting handlers of a peer which has
+// as many handlers as allowed blocks until one of them finishes, the quit
+// channel is closed or the pool is stopped, that handlers which may be
+// dropped are dropped instead, and that other peers are not blocked
+func TestWorkerPoolFull(t *testing.T) {
+	w := NewWorkerPool("test", &WorkerPoolParams{Workers: 1, Queue: 2})
+
+	quit := make(chan struct{})
+	p := w.NewPeerWorkers(quit)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	if err := p.Submit(func() {
+		close(started)
+		<-release
+	}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	// takes the last slot of the peer
+	ran := make(chan struct{})
+	if err := p.Submit(func() { close(ran) }); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.TrySubmit(func() { t.Error("dropped handler was run") }); err != ErrWorkerPoolFull {
+		t.Fatalf("got error %v, want %v", err, ErrWorkerPoolFull)
+	}
+
+	// other peers still get their handlers queued
+	other := make(chan struct{})
+	if err := w.NewPeerWorkers(nil).Submit(func() { close(other) }); err != nil {
+		t.Fatal(err)
+	}
+
+	errc := make(chan error)
+	blocked := make(chan struct{})
+	go func() {
+		errc <- p.Submit(func() { close(blocked) })
+	}()
+	select {
+	case err := <-errc:
+		t.Fatalf("submit to a full peer returned %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	// the submission proceeds once a handler of the peer finishes
+	close(release)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []chan struct{}{ran, other, blocked} {
+		select {
+		case <-c:
+		case <-time.After(time.Second):
+			t.Fatal("queued handler was not run")
+		}
+	}
+
+	release = make(chan struct{})
+	for i := 0; i < 2; i++ {
+		if err := p.Submit(func() { <-release }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	go func() {
+		errc <- p.Submit(func() {})
+	}()
+	close(quit)
+	if err := <-errc; err != ErrWorkerPoolQuit {
+		t.Fatalf("got error %v, want %v", err, ErrWorkerPoolQuit)
+	}
+
+	p = w.NewPeerWorkers(nil)
+	for i := 0; i < 2; i++ {
+		if err := p.Submit(func() { <-release }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	go func() {
+		errc <- p.Submit(func() {})
+	}()
+	w.Stop()
+	if err := <-errc; err != ErrWorkerPoolStopped {
+		t.Fatalf("got error %v, want %v", err, ErrWorkerPoolStopped)
+	}
+	if err := p.TrySubmit(func() {}); err != ErrWorkerPoolStopped {
+		t.Fatalf("got error %v, want %v", err, ErrWorkerPoolStopped)
+	}
+	close(release)
+}
@@ -140,6 +140,21 @@ func (pssapi *API) GetSymmetricKey(symkeyid string) (hexutil.Bytes, error) {
 	return hexutil.Bytes(symkey), err
 }
 
+// GetSymmetricKeys returns the symmetric keys held by the node with their topics,
+// peer address hints and garbage collection state
+func (pssapi *API) GetSymmetricKeys() []SymmetricKeyInfo {
+	return pssapi.Pss.SymmetricKeys()
+}
+
+// RevokeSymmetricKey removes the symmetric key with the given id, it is not used
+// for sending or decrypting messages anymore
+func (pssapi *API) RevokeSymmetricKey(symkeyid string) error {
+	if !pssapi.Pss.RevokeSymmetricKey(symkeyid) {
+		return fmt.Errorf("non-existent key ID")
+	}
+	return nil
+}
+
 func (pssapi *API) GetSymmetricAddressHint(topic message.Topic, symkeyid string) (PssAddress, error) {
 	return pssapi.Pss.symKeyPool[symkeyid][topic].address, nil
 }
@@ -170,6 +185,36 @@ func (pssapi *API) SendSym(symkeyhex string, topic message.Topic, msg hexutil.By
 	return pssapi.Pss.SendSym(symkeyhex, topic, msg[:])
 }
 
+// SendAsymWithLuminosity sends an asymmetrically encrypted message revealing
+// only luminosity bytes of the recipient address hint
+func (pssapi *API) SendAsymWithLuminosity(pubkeyhex string, topic message.Topic, msg hexutil.Bytes, luminosity int) error {
+	if err := validateMsg(msg); err != nil {
+		return err
+	}
+	return pssapi.Pss.SendAsymWithLuminosity(pubkeyhex, topic, msg[:], luminosity)
+}
+
+// SendSymWithLuminosity sends a symmetrically encrypted message revealing
+// only luminosity bytes of the recipient address hint
+func (pssapi *API) SendSymWithLuminosity(symkeyhex string, topic message.Topic, msg hexutil.Bytes, luminosity int) error {
+	if err := validateMsg(msg); err != nil {
+		return err
+	}
+	return pssapi.Pss.SendSymWithLuminosity(symkeyhex, topic, msg[:], luminosity)
+}
+
+// SetTopicLuminosity sets the number of recipient address bytes revealed in
+// messages sent on topic, 0 restores the default
+func (pssapi *API) SetTopicLuminosity(topic message.Topic, luminosity int) error {
+	return pssapi.Pss.SetTopicLuminosity(topic, luminosity)
+}
+
+// GetTopicLuminosity returns the number of recipient address bytes revealed in
+// messages sent on topic, 0 if the whole address hint is revealed
+func (pssapi *API) GetTopicLuminosity(topic message.Topic) int {
+	return pssapi.Pss.TopicLuminosity(topic)
+}
+
 func (pssapi *API) SendRaw(addr hexutil.Bytes, topic message.Topic, msg hexutil.Bytes) error {
 	if err := validateMsg(msg); err != nil {
 		return err
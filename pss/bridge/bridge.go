@@ -0,0 +1,386 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package bridge relays pss messages to and from an external HTTP service.
+//
+// Incoming messages on the configured topics are POSTed as JSON to a webhook.
+// Optionally, an HTTP endpoint accepts messages to be sent over pss.
+// Both directions are authenticated with an HMAC-SHA256 signature of the
+// request timestamp, a random nonce and the body, keyed with a shared secret.
+// Requests older than the maximal clock skew are rejected as stale and
+// a nonce is accepted only once, so that requests can not be replayed.
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/pss"
+	"github.com/ethersphere/swarm/pss/internal/ticker"
+	"github.com/ethersphere/swarm/pss/internal/ttlset"
+	"github.com/ethersphere/swarm/pss/message"
+	"github.com/tilinna/clock"
+)
+
+// Headers set on webhook requests and expected on send requests.
+const (
+	TimestampHeader = "X-Pss-Timestamp"
+	NonceHeader     = "X-Pss-Nonce"
+	SignatureHeader = "X-Pss-Signature"
+	TopicHeader     = "X-Pss-Topic"
+)
+
+const (
+	// SendPath is the path of the endpoint accepting outgoing messages
+	SendPath = "/send"
+
+	maxBodySize = 1024 * 1024
+)
+
+var (
+	// ErrInvalidSignature is returned when a request signature does not match its content
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrStaleRequest is returned when a request timestamp is outside of the allowed window
+	ErrStaleRequest = errors.New("stale request")
+	// ErrReplayedRequest is returned when a request nonce was already accepted
+	ErrReplayedRequest = errors.New("replayed request")
+)
+
+// Config defines the bridge parameters
+type Config struct {
+	WebhookURL     string          // URL incoming messages are delivered to, empty disables delivery
+	Topics         []message.Topic // topics delivered to the webhook
+	Raw            bool            // also deliver raw (unencrypted) messages
+	ListenAddr     string          // address of the send endpoint, empty disables it
+	Secret         string          // shared secret used to sign and authenticate requests
+	Timeout        time.Duration   // webhook request timeout
+	MaxClockSkew   time.Duration   // maximum age of an accepted send request or of a delivered message
+	QueueSize      int             // number of messages waiting for delivery before new ones are dropped
+	DefaultSendTTL time.Duration   // time to live of raw messages sent through the endpoint
+}
+
+// NewConfig returns a bridge config with default values
+func NewConfig() *Config {
+	return &Config{
+		Timeout:        10 * time.Second,
+		MaxClockSkew:   5 * time.Minute,
+		QueueSize:      100,
+		DefaultSendTTL: 2 * time.Minute,
+	}
+}
+
+// Enabled returns true if the webhook or the send endpoint is configured
+func (c *Config) Enabled() bool {
+	return c.WebhookURL != "" || c.ListenAddr != ""
+}
+
+// Delivery is the JSON body POSTed to the webhook for every incoming message
+type Delivery struct {
+	Topic      message.Topic `json:"topic"`
+	Msg        hexutil.Bytes `json:"msg"`
+	Asymmetric bool          `json:"asymmetric"`
+	Key        string        `json:"key"`
+}
+
+// SendRequest is the JSON body accepted by the send endpoint.
+// With an empty key the message is sent raw to address,
+// otherwise key is a symmetric key id or a public key if asymmetric is set.
+type SendRequest struct {
+	Topic      message.Topic  `json:"topic"`
+	Msg        hexutil.Bytes  `json:"msg"`
+	Asymmetric bool           `json:"asymmetric"`
+	Key        string         `json:"key"`
+	Address    pss.PssAddress `json:"address"`
+}
+
+// queuedDelivery is a message waiting for delivery to the webhook
+type queuedDelivery struct {
+	*Delivery
+	received time.Time
+}
+
+// Bridge relays pss messages between a pss node and an external HTTP service
+type Bridge struct {
+	ps         *pss.Pss
+	config     *Config
+	client     *http.Client
+	queue      chan *queuedDelivery
+	nonces     *ttlset.TTLSet // nonces of accepted send requests, kept until their timestamps are stale
+	gcTicker   *ticker.Ticker
+	deregister []func()
+	server     *http.Server
+	quit       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// New creates a bridge for the given pss instance
+func New(ps *pss.Pss, config *Config) (*Bridge, error) {
+	if config.Secret == "" {
+		return nil, errors.New("bridge secret must be set")
+	}
+	if config.WebhookURL != "" && len(config.Topics) == 0 {
+		return nil, errors.New("bridge webhook requires at least one topic")
+	}
+	if config.MaxClockSkew <= 0 {
+		return nil, errors.New("bridge max clock skew must be positive")
+	}
+	queueSize := config.QueueSize
+	if queueSize <= 0 {
+		queueSize = 1
+	}
+	return &Bridge{
+		ps:     ps,
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		queue:  make(chan *queuedDelivery, queueSize),
+		// a nonce must be remembered as long as a request with it could be
+		// accepted, its timestamp may be up to MaxClockSkew in the future
+		nonces: ttlset.New(&ttlset.Config{
+			EntryTTL: 2 * config.MaxClockSkew,
+			Clock:    clock.Realtime(),
+		}),
+		quit: make(chan struct{}),
+	}, nil
+}
+
+// Start registers the topic handlers and starts the send endpoint
+func (b *Bridge) Start() error {
+	if b.config.ListenAddr != "" {
+		l, err := net.Listen("tcp", b.config.ListenAddr)
+		if err != nil {
+			return err
+		}
+		b.gcTicker = ticker.New(&ticker.Config{
+			Clock:    clock.Realtime(),
+			Interval: b.config.MaxClockSkew,
+			Callback: b.nonces.GC,
+		})
+		mux := http.NewServeMux()
+		mux.Handle(SendPath, b)
+		b.server = &http.Server{Handler: mux}
+		go b.server.Serve(l)
+		log.Info("pss bridge send endpoint started", "addr", l.Addr())
+	}
+	if b.config.WebhookURL != "" {
+		for i := range b.config.Topics {
+			topic := b.config.Topics[i]
+			h := pss.NewHandler(func(msg []byte, p *p2p.Peer, asymmetric bool, keyid string) error {
+				b.enqueue(&Delivery{
+					Topic:      topic,
+					Msg:        msg,
+					Asymmetric: asymmetric,
+					Key:        keyid,
+				})
+				return nil
+			})
+			if b.config.Raw {
+				h = h.WithRaw()
+			}
+			b.deregister = append(b.deregister, b.ps.Register(&topic, h))
+		}
+		b.wg.Add(1)
+		go b.deliverLoop()
+	}
+	return nil
+}
+
+// Stop deregisters the topic handlers and stops the send endpoint
+func (b *Bridge) Stop() error {
+	for _, deregister := range b.deregister {
+		deregister()
+	}
+	close(b.quit)
+	b.wg.Wait()
+	if b.gcTicker != nil {
+		if err := b.gcTicker.Stop(); err != nil {
+			return err
+		}
+	}
+	if b.server != nil {
+		return b.server.Shutdown(context.Background())
+	}
+	return nil
+}
+
+// enqueue schedules a message for delivery, dropping it if the queue is full
+func (b *Bridge) enqueue(d *Delivery) {
+	select {
+	case b.queue <- &queuedDelivery{Delivery: d, received: time.Now()}:
+	default:
+		metrics.GetOrRegisterCounter("pss.bridge.webhook.dropped", nil).Inc(1)
+		log.Warn("pss bridge queue full, dropping message", "topic", d.Topic.String())
+	}
+}
+
+func (b *Bridge) deliverLoop() {
+	defer b.wg.Done()
+	for {
+		select {
+		case d := <-b.queue:
+			// the webhook would reject the delivery as stale
+			if time.Since(d.received) > b.config.MaxClockSkew {
+				metrics.GetOrRegisterCounter("pss.bridge.webhook.stale", nil).Inc(1)
+				log.Warn("pss bridge dropping stale message", "topic", d.Topic.String(), "received", d.received)
+				continue
+			}
+			if err := b.deliver(d.Delivery); err != nil {
+				metrics.GetOrRegisterCounter("pss.bridge.webhook.fail", nil).Inc(1)
+				log.Warn("pss bridge webhook delivery failed", "topic", d.Topic.String(), "err", err)
+				continue
+			}
+			metrics.GetOrRegisterCounter("pss.bridge.webhook.delivered", nil).Inc(1)
+		case <-b.quit:
+			return
+		}
+	}
+}
+
+// deliver POSTs a message to the webhook
+func (b *Bridge) deliver(d *Delivery) error {
+	body, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, b.config.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce, err := NewNonce()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TopicHeader, d.Topic.String())
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(NonceHeader, nonce)
+	req.Header.Set(SignatureHeader, Sign(b.config.Secret, timestamp, nonce, body))
+	res, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %s", res.Status)
+	}
+	return nil
+}
+
+// ServeHTTP handles requests to the send endpoint
+func (b *Bridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	nonce := r.Header.Get(NonceHeader)
+	if err := Verify(b.config.Secret, r.Header.Get(TimestampHeader), nonce, r.Header.Get(SignatureHeader), body, b.config.MaxClockSkew); err != nil {
+		metrics.GetOrRegisterCounter("pss.bridge.send.unauthorized", nil).Inc(1)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if !b.nonces.AddIfAbsent(nonce) {
+		metrics.GetOrRegisterCounter("pss.bridge.send.replayed", nil).Inc(1)
+		http.Error(w, ErrReplayedRequest.Error(), http.StatusUnauthorized)
+		return
+	}
+	var sr SendRequest
+	if err := json.Unmarshal(body, &sr); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := b.send(&sr); err != nil {
+		metrics.GetOrRegisterCounter("pss.bridge.send.fail", nil).Inc(1)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	metrics.GetOrRegisterCounter("pss.bridge.send", nil).Inc(1)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (b *Bridge) send(sr *SendRequest) error {
+	switch {
+	case sr.Key == "":
+		return b.ps.SendRaw(sr.Address, sr.Topic, sr.Msg, b.config.DefaultSendTTL)
+	case sr.Asymmetric:
+		return b.ps.SendAsym(sr.Key, sr.Topic, sr.Msg)
+	default:
+		return b.ps.SendSym(sr.Key, sr.Topic, sr.Msg)
+	}
+}
+
+// NewNonce returns a random hex encoded nonce for a signed request
+func NewNonce() (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(nonce), nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of timestamp, nonce and body keyed with secret
+func Sign(secret, timestamp, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write([]byte(nonce))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks that signature matches timestamp, nonce and body, that
+// the nonce is set and that timestamp is no further than maxSkew from the
+// current time. Receivers should also reject nonces they already accepted.
+func Verify(secret, timestamp, nonce, signature string, body []byte, maxSkew time.Duration) error {
+	sig, err := hex.DecodeString(signature)
+	if err != nil || nonce == "" {
+		return ErrInvalidSignature
+	}
+	expected, _ := hex.DecodeString(Sign(secret, timestamp, nonce, body))
+	if !hmac.Equal(sig, expected) {
+		return ErrInvalidSignature
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrStaleRequest
+	}
+	if d := time.Since(time.Unix(ts, 0)); d > maxSkew || d < -maxSkew {
+		return ErrStaleRequest
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package bridge
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/pss"
+	"github.com/ethersphere/swarm/pss/message"
+)
+
+func newTestPss(t *testing.T) *pss.Pss {
+	t.Helper()
+	privKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kad := network.NewKademlia(network.RandomBzzAddr().Over(), network.NewKadParams())
+	ps, err := pss.New(kad, pss.NewParams().WithPrivateKey(privKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ps
+}
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"msg":"0x2a"}`)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := "nonce"
+	sig := Sign("secret", now, nonce, body)
+
+	if err := Verify("secret", now, nonce, sig, body, time.Minute); err != nil {
+		t.Fatalf("expected valid signature, got %v", err)
+	}
+	if err := Verify("other", now, nonce, sig, body, time.Minute); err != ErrInvalidSignature {
+		t.Fatalf("expected %v with wrong secret, got %v", ErrInvalidSignature, err)
+	}
+	if err := Verify("secret", now, nonce, sig, []byte(`{"msg":"0x2b"}`), time.Minute); err != ErrInvalidSignature {
+		t.Fatalf("expected %v with tampered body, got %v", ErrInvalidSignature, err)
+	}
+	if err := Verify("secret", now, "other", sig, body, time.Minute); err != ErrInvalidSignature {
+		t.Fatalf("expected %v with tampered nonce, got %v", ErrInvalidSignature, err)
+	}
+	if err := Verify("secret", now, "", Sign("secret", now, "", body), body, time.Minute); err != ErrInvalidSignature {
+		t.Fatalf("expected %v without nonce, got %v", ErrInvalidSignature, err)
+	}
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	if err := Verify("secret", old, nonce, Sign("secret", old, nonce, body), body, time.Minute); err != ErrStaleRequest {
+		t.Fatalf("expected %v with old timestamp, got %v", ErrStaleRequest, err)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	topic := message.NewTopic([]byte("bridge"))
+	received := make(chan Delivery, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err := Verify("secret", r.Header.Get(TimestampHeader), r.Header.Get(NonceHeader), r.Header.Get(SignatureHeader), body, time.Minute); err != nil {
+			t.Errorf("webhook request not verified: %v", err)
+		}
+		if got := r.Header.Get(TopicHeader); got != topic.String() {
+			t.Errorf("expected topic header %s, got %s", topic.String(), got)
+		}
+		var d Delivery
+		if err := json.Unmarshal(body, &d); err != nil {
+			t.Error(err)
+		}
+		received <- d
+	}))
+	defer srv.Close()
+
+	config := NewConfig()
+	config.WebhookURL = srv.URL
+	config.Topics = []message.Topic{topic}
+	config.Secret = "secret"
+	b, err := New(newTestPss(t), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Stop()
+
+	b.enqueue(&Delivery{Topic: topic, Msg: []byte("hello"), Key: "key"})
+	select {
+	case d := <-received:
+		if d.Topic != topic || !bytes.Equal(d.Msg, []byte("hello")) || d.Key != "key" {
+			t.Fatalf("unexpected delivery %+v", d)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for webhook delivery")
+	}
+
+	// messages waiting for longer than the maximal clock skew are not delivered
+	b.queue <- &queuedDelivery{
+		Delivery: &Delivery{Topic: topic, Msg: []byte("stale")},
+		received: time.Now().Add(-2 * config.MaxClockSkew),
+	}
+	b.enqueue(&Delivery{Topic: topic, Msg: []byte("fresh")})
+	select {
+	case d := <-received:
+		if !bytes.Equal(d.Msg, []byte("fresh")) {
+			t.Fatalf("expected the stale message to be dropped, got %s", d.Msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for webhook delivery")
+	}
+}
+
+func TestSendEndpoint(t *testing.T) {
+	config := NewConfig()
+	config.Secret = "secret"
+	b, err := New(newTestPss(t), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := json.Marshal(&SendRequest{
+		Topic: message.NewTopic([]byte("bridge")),
+		Msg:   []byte("hello"),
+		Key:   "unknown",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-2*config.MaxClockSkew).Unix(), 10)
+
+	for _, tc := range []struct {
+		name      string
+		timestamp string
+		nonce     string
+		signature string
+		status    int
+	}{
+		{name: "unsigned", timestamp: now, nonce: "1", signature: "", status: http.StatusUnauthorized},
+		{name: "wrong secret", timestamp: now, nonce: "1", signature: Sign("other", now, "1", body), status: http.StatusUnauthorized},
+		{name: "stale", timestamp: old, nonce: "1", signature: Sign("secret", old, "1", body), status: http.StatusUnauthorized},
+		// authenticated, but the symmetric key is not known to pss
+		{name: "unknown key", timestamp: now, nonce: "1", signature: Sign("secret", now, "1", body), status: http.StatusBadRequest},
+		{name: "replayed", timestamp: now, nonce: "1", signature: Sign("secret", now, "1", body), status: http.StatusUnauthorized},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, SendPath, bytes.NewReader(body))
+			req.Header.Set(TimestampHeader, tc.timestamp)
+			req.Header.Set(NonceHeader, tc.nonce)
+			req.Header.Set(SignatureHeader, tc.signature)
+			w := httptest.NewRecorder()
+			b.ServeHTTP(w, req)
+			if w.Code != tc.status {
+				t.Fatalf("expected status %v, got %v: %s", tc.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -50,6 +50,21 @@ func (ts *TTLSet) Add(key interface{}) error {
 	return nil
 }
 
+// AddIfAbsent adds a key to the set unless it is already/still in the set.
+// It returns true if the key was added. Checking and adding is atomic, so
+// of concurrent calls with the same key only one returns true.
+func (ts *TTLSet) AddIfAbsent(key interface{}) bool {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	now := ts.Clock.Now()
+	if entry, ok := ts.set[key]; ok && entry.expiresAt.After(now) {
+		return false
+	}
+	ts.set[key] = setEntry{expiresAt: now.Add(ts.EntryTTL)}
+	return true
+}
+
 // Has returns whether or not a key is already/still in the set
 func (ts *TTLSet) Has(key interface{}) bool {
 	ts.lock.Lock()
@@ -148,3 +148,30 @@ func TestGC(t *testing.T) {
 	}
 
 }
+
+func TestAddIfAbsent(t *testing.T) {
+	testClock := clock.NewMock(time.Unix(0, 0))
+
+	testEntryTTL := 10 * time.Second
+	testSet := ttlset.New(&ttlset.Config{
+		EntryTTL: testEntryTTL,
+		Clock:    testClock,
+	})
+
+	key := "some key"
+	if !testSet.AddIfAbsent(key) {
+		t.Fatal("expected key to be added")
+	}
+	if testSet.AddIfAbsent(key) {
+		t.Fatal("expected key not to be added again")
+	}
+
+	// Let the key expire, it can be added again:
+	testClock.Add(testEntryTTL * 2)
+	if !testSet.AddIfAbsent(key) {
+		t.Fatal("expected expired key to be added again")
+	}
+	if !testSet.Has(key) {
+		t.Fatal("key should've been in the set, but Has() returned false")
+	}
+}
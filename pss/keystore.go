@@ -17,9 +17,11 @@
 package pss
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -38,6 +40,16 @@ type KeyStore struct {
 	symKeyDecryptCacheCursor int                                // modular cursor pointing to last used, wraps on symKeyDecryptCache array
 }
 
+// SymmetricKeyInfo describes a symmetric key held in the key store for a topic
+type SymmetricKeyInfo struct {
+	ID        string        // symmetric key id
+	Topic     message.Topic // topic the key is used for
+	Address   PssAddress    // routing hint of the peer the key is shared with
+	Protected bool          // whether the key is exempt from garbage collection
+	Decrypt   bool          // whether the key is tried for decrypting incoming messages
+	Expired   bool          // whether the key is removed at the next garbage collection
+}
+
 func loadKeyStore() *KeyStore {
 	return &KeyStore{
 		Crypto:             crypto.New(),
@@ -121,6 +133,54 @@ func (ks *KeyStore) addSymmetricKeyToPool(keyid string, topic message.Topic, add
 	}
 }
 
+// SymmetricKeys returns all symmetric keys held in the key store,
+// one entry for each topic a key is used for, ordered by key id and topic
+func (ks *KeyStore) SymmetricKeys() (keys []SymmetricKeyInfo) {
+	ks.mx.RLock()
+	defer ks.mx.RUnlock()
+	cached := make(map[string]bool)
+	for i := ks.symKeyDecryptCacheCursor; i > ks.symKeyDecryptCacheCursor-cap(ks.symKeyDecryptCache) && i > 0; i-- {
+		if keyid := ks.symKeyDecryptCache[i%cap(ks.symKeyDecryptCache)]; keyid != nil {
+			cached[*keyid] = true
+		}
+	}
+	for keyid, peertopics := range ks.symKeyPool {
+		for topic, psp := range peertopics {
+			keys = append(keys, SymmetricKeyInfo{
+				ID:        keyid,
+				Topic:     topic,
+				Address:   psp.address,
+				Protected: psp.protected,
+				Decrypt:   cached[keyid],
+				Expired:   !psp.protected && !cached[keyid],
+			})
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].ID != keys[j].ID {
+			return keys[i].ID < keys[j].ID
+		}
+		return bytes.Compare(keys[i].Topic[:], keys[j].Topic[:]) < 0
+	})
+	return keys
+}
+
+// RevokeSymmetricKey removes the symmetric key with the given id for all topics
+// and deletes it from the crypto backend, so that it is neither used for sending
+// nor for decrypting incoming messages anymore.
+// Returns false if the key is not held in the key store.
+func (ks *KeyStore) RevokeSymmetricKey(keyid string) bool {
+	ks.mx.Lock()
+	_, ok := ks.symKeyPool[keyid]
+	delete(ks.symKeyPool, keyid)
+	ks.mx.Unlock()
+	removed := ks.Crypto.RemoveSymmetricKey(keyid)
+	if ok || removed {
+		log.Trace("revoked symkey", "symkeyid", keyid)
+	}
+	return ok || removed
+}
+
 // Returns all recorded topic and address combination for a specific public key
 func (ks *KeyStore) GetPublickeyPeers(keyid string) (topic []message.Topic, address []PssAddress, err error) {
 	ks.mx.RLock()
@@ -0,0 +1,83 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pss
+
+import (
+	"fmt"
+
+	"github.com/ethersphere/swarm/pot"
+	"github.com/ethersphere/swarm/pss/message"
+)
+
+// Luminosity is the number of leading bytes of the recipient address revealed
+// in a pss message. The fewer bytes are revealed, the more nodes the message
+// is delivered to and the harder it is to tell its recipient, at the cost of
+// more traffic and lower delivery probability.
+const (
+	MinLuminosity = 1                  // lowest luminosity that can be set
+	MaxLuminosity = len(pot.Address{}) // full recipient address
+)
+
+// validateLuminosity checks that luminosity is within bounds,
+// 0 is valid and means the luminosity is not set
+func validateLuminosity(luminosity int) error {
+	if luminosity != 0 && (luminosity < MinLuminosity || luminosity > MaxLuminosity) {
+		return fmt.Errorf("invalid luminosity %d, must be between %d and %d", luminosity, MinLuminosity, MaxLuminosity)
+	}
+	return nil
+}
+
+// SetTopicLuminosity sets the luminosity of the messages sent on topic,
+// overriding the default luminosity. Luminosity 0 removes the topic setting.
+func (p *Pss) SetTopicLuminosity(topic message.Topic, luminosity int) error {
+	if err := validateLuminosity(luminosity); err != nil {
+		return err
+	}
+	p.luminosityMu.Lock()
+	defer p.luminosityMu.Unlock()
+	if luminosity == 0 {
+		delete(p.topicLuminosity, topic)
+		return nil
+	}
+	p.topicLuminosity[topic] = luminosity
+	return nil
+}
+
+// TopicLuminosity returns the luminosity of the messages sent on topic,
+// 0 if the whole address hint of the recipient is used
+func (p *Pss) TopicLuminosity(topic message.Topic) int {
+	p.luminosityMu.RLock()
+	defer p.luminosityMu.RUnlock()
+	if luminosity, ok := p.topicLuminosity[topic]; ok {
+		return luminosity
+	}
+	return p.luminosity
+}
+
+// luminousAddress returns the recipient address revealed in a message on topic.
+// The address is truncated to the message luminosity if set, otherwise to the
+// topic or default luminosity. An address hint shorter than the luminosity
+// is used as it is.
+func (p *Pss) luminousAddress(to PssAddress, topic message.Topic, luminosity int) PssAddress {
+	if luminosity == 0 {
+		luminosity = p.TopicLuminosity(topic)
+	}
+	if luminosity == 0 || luminosity >= len(to) {
+		return to
+	}
+	return to[:luminosity]
+}
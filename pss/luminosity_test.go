@@ -0,0 +1,108 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pss
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	ethCrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/pss/message"
+)
+
+// TestLuminosity checks that sent messages reveal the recipient address
+// up to the message, topic or default luminosity, in that order
+func TestLuminosity(t *testing.T) {
+	privkey, err := ethCrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(network.NewKademlia(make([]byte, 32), nil), NewParams().WithPrivateKey(privkey)); err != nil {
+		t.Fatal(err)
+	}
+	params := NewParams().WithPrivateKey(privkey)
+	params.Luminosity = MaxLuminosity + 1
+	if _, err := New(network.NewKademlia(make([]byte, 32), nil), params); err == nil {
+		t.Fatal("expected error creating pss with invalid luminosity")
+	}
+
+	ps := newTestPss(privkey, nil, nil)
+	defer ps.Stop()
+	ps.luminosity = 4
+
+	sent := make(chan *message.Message, 1)
+	ps.outbox.SetForward(func(msg *message.Message) error {
+		sent <- msg
+		return nil
+	})
+
+	addr := PssAddress(network.RandomBzzAddr().Over())
+	topic := message.NewTopic([]byte("foo:42"))
+	otherTopic := message.NewTopic([]byte("bar:42"))
+	symkeyid, err := ps.GenerateSymmetricKey(topic, addr, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectTo := func(want PssAddress) {
+		t.Helper()
+		select {
+		case msg := <-sent:
+			if !bytes.Equal(msg.To, want) {
+				t.Fatalf("expected message to %x, got %x", want, msg.To)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for message")
+		}
+	}
+
+	if err := ps.SendSym(symkeyid, topic, []byte("default")); err != nil {
+		t.Fatal(err)
+	}
+	expectTo(addr[:4])
+
+	if err := ps.SetTopicLuminosity(topic, MaxLuminosity+1); err == nil {
+		t.Fatal("expected error setting invalid topic luminosity")
+	}
+	if err := ps.SetTopicLuminosity(topic, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.SendSym(symkeyid, topic, []byte("topic")); err != nil {
+		t.Fatal(err)
+	}
+	expectTo(addr[:2])
+	if l := ps.TopicLuminosity(otherTopic); l != 4 {
+		t.Fatalf("expected default luminosity 4 for other topic, got %v", l)
+	}
+
+	if err := ps.SendSymWithLuminosity(symkeyid, topic, []byte("message"), MaxLuminosity); err != nil {
+		t.Fatal(err)
+	}
+	expectTo(addr)
+	if err := ps.SendSymWithLuminosity(symkeyid, topic, []byte("invalid"), -1); err == nil {
+		t.Fatal("expected error sending with invalid luminosity")
+	}
+
+	if err := ps.SetTopicLuminosity(topic, 0); err != nil {
+		t.Fatal(err)
+	}
+	if l := ps.TopicLuminosity(topic); l != 4 {
+		t.Fatalf("expected default luminosity 4 after removing topic luminosity, got %v", l)
+	}
+}
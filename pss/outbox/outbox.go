@@ -16,6 +16,7 @@
 package outbox
 
 import (
+	"context"
 	"errors"
 	"time"
 
@@ -90,19 +91,32 @@ func (o *Outbox) Stop() {
 // Enqueue a new element in the outbox if there is any slot available.
 // Then send it to process. This method is blocking if there is no workers available.
 func (o *Outbox) Enqueue(outboxMsg *outboxMsg) {
+	o.EnqueueContext(context.Background(), outboxMsg)
+}
+
+// EnqueueContext is Enqueue which stops waiting for a slot or a worker when
+// the context is done, returning its error. The message is not forwarded then.
+func (o *Outbox) EnqueueContext(ctx context.Context, outboxMsg *outboxMsg) error {
 	// first we try to obtain a slot in the outbox.
 	select {
 	case <-o.stopC:
-		return
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	case slot := <-o.slots:
 		o.queue[slot] = outboxMsg
 		metrics.GetOrRegisterGauge("pss.outbox.len", nil).Update(int64(o.Len()))
 		// we send this message slot to process.
 		select {
 		case <-o.stopC:
+		case <-ctx.Done():
+			o.free(slot)
+			metrics.GetOrRegisterGauge("pss.outbox.len", nil).Update(int64(o.Len()))
+			return ctx.Err()
 		case o.process <- slot:
 		}
 	}
+	return nil
 }
 
 // SetForward set the forward function that will be executed on each message.
@@ -16,6 +16,7 @@
 package outbox_test
 
 import (
+	"context"
 	"errors"
 	"sync"
 	"sync/atomic"
@@ -160,6 +161,46 @@ func TestOutboxWorkers(t *testing.T) {
 	}
 }
 
+// TestOutboxEnqueueContext checks that enqueuing stops waiting for a slot
+// in a full outbox when the context is done.
+func TestOutboxEnqueueContext(t *testing.T) {
+	continueC := make(chan struct{})
+	defer close(continueC)
+	mockForwardFunction := func(msg *message.Message) error {
+		<-continueC
+		return nil
+	}
+
+	testOutbox := outbox.NewMock(&outbox.Config{
+		NumberSlots: 1,
+		Forward:     mockForwardFunction,
+	})
+
+	testOutbox.Start()
+	defer testOutbox.Stop()
+
+	// the only slot is taken by a message which is not forwarded until continueC is closed
+	testOutbox.Enqueue(testOutbox.NewOutboxMessage(newTestMessage(0)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), blockTimeout)
+	defer cancel()
+	errC := make(chan error)
+	go func() {
+		errC <- testOutbox.EnqueueContext(ctx, testOutbox.NewOutboxMessage(newTestMessage(1)))
+	}()
+	select {
+	case err := <-errC:
+		if err != context.DeadlineExceeded {
+			t.Fatalf("expected error %v, got %v", context.DeadlineExceeded, err)
+		}
+	case <-time.After(timeout):
+		t.Fatal("timeout waiting for enqueue to give up")
+	}
+	if numMessages := testOutbox.Len(); numMessages != 1 {
+		t.Errorf("Expected one message in outbox, instead got %v", numMessages)
+	}
+}
+
 func newTestMessage(num byte) *message.Message {
 	return &message.Message{
 		To:      nil,
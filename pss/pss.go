@@ -51,7 +51,7 @@ const (
 	defaultMaxMsgSize          = 1024 * 1024
 	defaultCleanInterval       = time.Minute * 10
 	defaultOutboxCapacity      = 50
-	protocolName               = "pss"
+	ProtocolName               = "pss" // name of the pss devp2p protocol
 	protocolVersion            = 2
 	CapabilityID               = capability.CapabilityID(1)
 	capabilitiesSend           = 0 // node sends pss messages
@@ -66,7 +66,7 @@ var (
 )
 
 var spec = &protocols.Spec{
-	Name:       protocolName,
+	Name:       ProtocolName,
 	Version:    protocolVersion,
 	MaxMsgSize: defaultMaxMsgSize,
 	Messages: []interface{}{
@@ -93,11 +93,12 @@ type peer struct {
 // Pss configuration parameters
 type Params struct {
 	MsgTTL              time.Duration
-	CacheTTL            time.Duration
+	CacheTTL            time.Duration // window in which duplicates of a seen message are neither processed nor forwarded
 	privateKey          *ecdsa.PrivateKey
 	SymKeyCacheCapacity int
 	AllowRaw            bool // If true, enables sending and receiving messages without builtin pss encryption
 	AllowForward        bool
+	Luminosity          int // default number of recipient address bytes revealed in messages, 0 reveals the whole address hint
 }
 
 // Sane defaults for Pss
@@ -134,6 +135,11 @@ type Pss struct {
 	capstring string
 	outbox    *outbox.Outbox
 
+	// dark routing
+	luminosity      int                   // default luminosity of sent messages
+	topicLuminosity map[message.Topic]int // luminosity of sent messages by topic
+	luminosityMu    sync.RWMutex
+
 	// message handling
 	handlers           map[message.Topic]map[*handler]bool // topic and version based pss payload handlers. See pss.Handle()
 	handlersMu         sync.RWMutex
@@ -157,10 +163,14 @@ func New(k *network.Kademlia, params *Params) (*Pss, error) {
 		return nil, errors.New("missing private key for pss")
 	}
 
+	if err := validateLuminosity(params.Luminosity); err != nil {
+		return nil, err
+	}
+
 	clock := clock.Realtime() //TODO: Clock should be injected by Params so it can be mocked.
 
 	c := p2p.Cap{
-		Name:    protocolName,
+		Name:    ProtocolName,
 		Version: protocolVersion,
 	}
 	ps := &Pss{
@@ -175,6 +185,9 @@ func New(k *network.Kademlia, params *Params) (*Pss, error) {
 		msgTTL:    params.MsgTTL,
 		capstring: c.String(),
 
+		luminosity:      params.Luminosity,
+		topicLuminosity: make(map[message.Topic]int),
+
 		handlers:         make(map[message.Topic]map[*handler]bool),
 		topicHandlerCaps: make(map[message.Topic]*handlerCaps),
 	}
@@ -205,6 +218,13 @@ func New(k *network.Kademlia, params *Params) (*Pss, error) {
 	}
 	k.Capabilities.Add(cp)
 
+	ps.addAPI(rpc.API{
+		Namespace: "pss",
+		Version:   "1.0",
+		Service:   network.NewLoadBalancerAPI(ps.kademliaLB),
+		Public:    false,
+	})
+
 	return ps, nil
 }
 
@@ -450,11 +470,11 @@ func (p *Pss) handlePssMsg(ctx context.Context, pssmsg *message.Message) error {
 		log.Warn("pss filtered expired message", "from", hex.EncodeToString(p.Kademlia.BaseAddr()), "to", hex.EncodeToString(pssmsg.To))
 		return nil
 	}
-	if p.checkFwdCache(pssmsg) {
+	// a message arriving via several routes is handled only once
+	if !p.addNewFwdCache(pssmsg) {
 		log.Trace("pss relay block-cache match (process)", "from", hex.EncodeToString(p.Kademlia.BaseAddr()), "to", (hex.EncodeToString(pssmsg.To)))
 		return nil
 	}
-	p.addFwdCache(pssmsg)
 
 	psstopic := pssmsg.Topic
 
@@ -589,17 +609,27 @@ func (p *Pss) isSelfPossibleRecipient(msg *message.Message, prox bool) bool {
 /////////////////////////////////////////////////////////////////////
 
 func (p *Pss) enqueue(msg *message.Message) {
+	p.enqueueContext(context.Background(), msg)
+}
+
+func (p *Pss) enqueueContext(ctx context.Context, msg *message.Message) error {
 	defer metrics.GetOrRegisterResettingTimer("pss.enqueue", nil).UpdateSince(time.Now())
 
 	// TODO: create and enqueue in one outbox method
 	outboxMsg := p.outbox.NewOutboxMessage(msg)
-	p.outbox.Enqueue(outboxMsg)
+	return p.outbox.EnqueueContext(ctx, outboxMsg)
 }
 
 // Send a raw message (any encryption is responsibility of calling client)
 //
 // Will fail if raw messages are disallowed
 func (p *Pss) SendRaw(address PssAddress, topic message.Topic, msg []byte, messageTTL time.Duration) error {
+	return p.SendRawContext(context.Background(), address, topic, msg, messageTTL)
+}
+
+// SendRawContext is SendRaw which gives up waiting for the outbox to accept
+// the message when the context is done
+func (p *Pss) SendRawContext(ctx context.Context, address PssAddress, topic message.Topic, msg []byte, messageTTL time.Duration) error {
 	defer metrics.GetOrRegisterResettingTimer("pss.send.raw", nil).UpdateSince(time.Now())
 
 	if err := validateAddress(address); err != nil {
@@ -611,21 +641,31 @@ func (p *Pss) SendRaw(address PssAddress, topic message.Topic, msg []byte, messa
 	}
 
 	pssMsg := message.New(pssMsgParams)
-	pssMsg.To = address
+	pssMsg.To = p.luminousAddress(address, topic, 0)
 	pssMsg.Expire = uint32(time.Now().Add(messageTTL).Unix())
 	pssMsg.Payload = msg
 	pssMsg.Topic = topic
 
 	p.addFwdCache(pssMsg)
 
-	p.enqueue(pssMsg)
-	return nil
+	return p.enqueueContext(ctx, pssMsg)
 }
 
 // Send a message using symmetric encryption
 //
 // Fails if the key id does not match any of the stored symmetric keys
 func (p *Pss) SendSym(symkeyid string, topic message.Topic, msg []byte) error {
+	return p.SendSymWithLuminosity(symkeyid, topic, msg, 0)
+}
+
+// Send a message using symmetric encryption revealing only luminosity bytes
+// of the recipient address hint. Luminosity 0 uses the topic or default luminosity.
+//
+// Fails if the key id does not match any of the stored symmetric keys
+func (p *Pss) SendSymWithLuminosity(symkeyid string, topic message.Topic, msg []byte, luminosity int) error {
+	if err := validateLuminosity(luminosity); err != nil {
+		return err
+	}
 	symkey, err := p.GetSymmetricKey(symkeyid)
 	if err != nil {
 		return fmt.Errorf("missing valid send symkey %s: %v", symkeyid, err)
@@ -634,13 +674,24 @@ func (p *Pss) SendSym(symkeyid string, topic message.Topic, msg []byte) error {
 	if !ok {
 		return fmt.Errorf("invalid topic '%s' for symkey '%s'", topic.String(), symkeyid)
 	}
-	return p.send(psp.address, topic, msg, false, symkey)
+	return p.send(p.luminousAddress(psp.address, topic, luminosity), topic, msg, false, symkey)
 }
 
 // Send a message using asymmetric encryption
 //
 // Fails if the key id does not match any in of the stored public keys
 func (p *Pss) SendAsym(pubkeyid string, topic message.Topic, msg []byte) error {
+	return p.SendAsymWithLuminosity(pubkeyid, topic, msg, 0)
+}
+
+// Send a message using asymmetric encryption revealing only luminosity bytes
+// of the recipient address hint. Luminosity 0 uses the topic or default luminosity.
+//
+// Fails if the key id does not match any in of the stored public keys
+func (p *Pss) SendAsymWithLuminosity(pubkeyid string, topic message.Topic, msg []byte, luminosity int) error {
+	if err := validateLuminosity(luminosity); err != nil {
+		return err
+	}
 	if _, err := p.Crypto.UnmarshalPublicKey(common.FromHex(pubkeyid)); err != nil {
 		return fmt.Errorf("Cannot unmarshal pubkey: %x", pubkeyid)
 	}
@@ -648,7 +699,7 @@ func (p *Pss) SendAsym(pubkeyid string, topic message.Topic, msg []byte) error {
 	if !ok {
 		return fmt.Errorf("invalid topic '%s' for pubkey '%s'", topic.String(), pubkeyid)
 	}
-	return p.send(psp.address, topic, msg, true, common.FromHex(pubkeyid))
+	return p.send(p.luminousAddress(psp.address, topic, luminosity), topic, msg, true, common.FromHex(pubkeyid))
 }
 
 // Send is payload agnostic, and will accept any byte slice as payload
@@ -690,6 +741,9 @@ func (p *Pss) send(to []byte, topic message.Topic, msg []byte, asymmetric bool,
 	pssMsg.Payload = envelope
 	pssMsg.Topic = topic
 
+	// do not handle our own message when it is routed back to us
+	p.addFwdCache(pssMsg)
+
 	p.enqueue(pssMsg)
 	return nil
 }
@@ -816,15 +870,14 @@ func (p *Pss) addFwdCache(msg *message.Message) error {
 	return p.forwardCache.Add(msg.Digest())
 }
 
-// check if message is in the cache
-func (p *Pss) checkFwdCache(msg *message.Message) bool {
-	hit := p.forwardCache.Has(msg.Digest())
-	if hit {
-		metrics.GetOrRegisterCounter("pss.checkfwdcache.hit", nil).Inc(1)
-	} else {
-		metrics.GetOrRegisterCounter("pss.checkfwdcache.miss", nil).Inc(1)
+// add a message to the cache unless it is already there, returns false for duplicates
+func (p *Pss) addNewFwdCache(msg *message.Message) bool {
+	if !p.forwardCache.AddIfAbsent(msg.Digest()) {
+		metrics.GetOrRegisterCounter("pss.dedup.suppressed", nil).Inc(1)
+		return false
 	}
-	return hit
+	metrics.GetOrRegisterCounter("pss.dedup.new", nil).Inc(1)
+	return true
 }
 
 func validateAddress(addr PssAddress) error {
@@ -23,9 +23,11 @@ import (
 	"encoding/binary"
 	"fmt"
 	"math/rand"
+	"reflect"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -355,6 +357,76 @@ func TestAddressMatchProx(t *testing.T) {
 	}
 }
 
+// TestDedup checks that a message arriving several times is handled only once
+func TestDedup(t *testing.T) {
+	privKey, err := ethCrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	localAddr := network.RandomBzzAddr().Over()
+	ps, err := New(network.NewKademlia(localAddr, network.NewKadParams()), NewParams().WithPrivateKey(privKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var forwarded int32
+	ps.outbox.SetForward(func(msg *message.Message) error {
+		atomic.AddInt32(&forwarded, 1)
+		return nil
+	})
+	ps.outbox.Start()
+	defer ps.outbox.Stop()
+
+	var received int32
+	topic := message.NewTopic([]byte{0x2a})
+	ps.Register(&topic, &handler{
+		f: func(msg []byte, p *p2p.Peer, asymmetric bool, keyid string) error {
+			atomic.AddInt32(&received, 1)
+			return nil
+		},
+		caps: &handlerCaps{
+			raw: true,
+		},
+	})
+
+	pssMsg := message.New(message.Flags{Raw: true})
+	pssMsg.To = localAddr
+	pssMsg.Expire = uint32(time.Now().Unix() + 4200)
+	pssMsg.Payload = []byte("dedup")
+	pssMsg.Topic = topic
+
+	// the same message arrives concurrently via several routes
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := ps.handlePssMsg(context.Background(), pssMsg); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&received); n != 1 {
+		t.Fatalf("expected message to be handled once, got %v", n)
+	}
+
+	// a message to another node is forwarded only once
+	pssMsg = message.New(message.Flags{Raw: true})
+	pssMsg.To = network.RandomBzzAddr().Over()
+	pssMsg.Expire = uint32(time.Now().Unix() + 4200)
+	pssMsg.Payload = []byte("dedup")
+	pssMsg.Topic = topic
+	for i := 0; i < 3; i++ {
+		if err := ps.handlePssMsg(context.Background(), pssMsg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt32(&forwarded); n != 1 {
+		t.Fatalf("expected message to be forwarded once, got %v", n)
+	}
+}
+
 // set and generate pubkeys and symkeys
 func TestKeys(t *testing.T) {
 	// make our key and init pss with it
@@ -409,6 +481,61 @@ func TestKeys(t *testing.T) {
 	}
 }
 
+// check that symmetric keys are listed with their state and can be revoked
+func TestSymmetricKeys(t *testing.T) {
+	privkey, err := ethCrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := newTestPss(privkey, nil, nil)
+	defer ps.Stop()
+	api := NewAPI(ps)
+
+	addr := make(PssAddress, 32)
+	copy(addr, network.RandomBzzAddr().Over())
+	topic := message.NewTopic([]byte("foo:42"))
+	protectedid, err := ps.SetSymmetricKey(network.RandomBzzAddr().Over(), topic, addr, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cachedid, err := ps.GenerateSymmetricKey(topic, addr, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiredid, err := ps.GenerateSymmetricKey(topic, addr, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := api.GetSymmetricKeys()
+	if len(keys) != 3 {
+		t.Fatalf("expected 3 symmetric keys, got %v", len(keys))
+	}
+	want := map[string]SymmetricKeyInfo{
+		protectedid: {ID: protectedid, Topic: topic, Address: addr, Protected: true},
+		cachedid:    {ID: cachedid, Topic: topic, Address: addr, Decrypt: true},
+		expiredid:   {ID: expiredid, Topic: topic, Address: addr, Expired: true},
+	}
+	for _, key := range keys {
+		if !reflect.DeepEqual(key, want[key.ID]) {
+			t.Fatalf("got key %+v, want %+v", key, want[key.ID])
+		}
+	}
+
+	if err := api.RevokeSymmetricKey(cachedid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ps.GetSymmetricKey(cachedid); err == nil {
+		t.Fatal("expected revoked key to be removed from the crypto backend")
+	}
+	if keys := api.GetSymmetricKeys(); len(keys) != 2 {
+		t.Fatalf("expected 2 symmetric keys after revocation, got %v", len(keys))
+	}
+	if err := api.RevokeSymmetricKey(cachedid); err == nil {
+		t.Fatal("expected error revoking a non-existent key")
+	}
+}
+
 // check that we can retrieve previously added public key entires per topic and peer
 func TestGetPublickeyEntries(t *testing.T) {
 
@@ -486,7 +613,7 @@ func TestPeerCapabilityMismatch(t *testing.T) {
 	// one peer has a mismatching version of pss
 	wrongpssaddr := network.RandomBzzAddr()
 	wrongpsscap := p2p.Cap{
-		Name:    protocolName,
+		Name:    ProtocolName,
 		Version: 0,
 	}
 	nid := enode.ID{0x01}
@@ -1546,7 +1673,7 @@ func newServices(allowRaw bool) map[string]simulation.ServiceFunc {
 			bucket.Store(simulation.BucketKeyKademlia, pskad)
 			return network.NewBzz(config, pskad, stateStore, nil, nil, nil, nil), nil, nil
 		},
-		protocolName: func(ctx *adapters.ServiceContext, bucket *sync.Map) (node.Service, func(), error) {
+		ProtocolName: func(ctx *adapters.ServiceContext, bucket *sync.Map) (node.Service, func(), error) {
 			// execadapter does not exec init()
 			initTest()
 
@@ -17,6 +17,7 @@
 package pss
 
 import (
+	"context"
 	"time"
 
 	"github.com/ethereum/go-ethereum/metrics"
@@ -44,8 +45,13 @@ func (p *PubSub) BaseAddr() []byte {
 	return p.pss.BaseAddr()
 }
 
+// NeighbourhoodDepth returns the neighbourhood depth of the Kademlia
+func (p *PubSub) NeighbourhoodDepth() int {
+	return p.pss.NeighbourhoodDepth()
+}
+
 func isPssPeer(bp *network.BzzPeer) bool {
-	return bp.HasCap(protocolName)
+	return bp.HasCap(ProtocolName)
 }
 
 // IsClosestTo returns true is self is the closest known node to addr
@@ -55,6 +61,23 @@ func (p *PubSub) IsClosestTo(addr []byte) bool {
 	return p.pss.IsClosestTo(addr, isPssPeer)
 }
 
+// NeighbourhoodPeers returns the addresses of the connected pss capable peers
+// within the neighbourhood depth of addr, closest first
+func (p *PubSub) NeighbourhoodPeers(addr []byte) [][]byte {
+	depth := p.pss.NeighbourhoodDepth()
+	var peers [][]byte
+	p.pss.EachConn(addr, 255, func(peer *network.Peer, po int) bool {
+		if po < depth {
+			return false
+		}
+		if isPssPeer(peer.BzzPeer) {
+			peers = append(peers, peer.Address())
+		}
+		return true
+	})
+	return peers
+}
+
 // Register registers a handler
 func (p *PubSub) Register(topic string, prox bool, handler func(msg []byte, p *p2p.Peer) error) func() {
 	f := func(msg []byte, peer *p2p.Peer, _ bool, _ string) error {
@@ -70,7 +93,13 @@ func (p *PubSub) Register(topic string, prox bool, handler func(msg []byte, p *p
 
 // Send sends a message using pss SendRaw
 func (p *PubSub) Send(to []byte, topic string, msg []byte) error {
+	return p.SendContext(context.Background(), to, topic, msg)
+}
+
+// SendContext sends a message using pss SendRawContext, giving up
+// when the context is done before the message is enqueued
+func (p *PubSub) SendContext(ctx context.Context, to []byte, topic string, msg []byte) error {
 	defer metrics.GetOrRegisterResettingTimer("pss.pubsub.send", nil).UpdateSince(time.Now())
 	pt := message.NewTopic([]byte(topic))
-	return p.pss.SendRaw(PssAddress(to), pt, msg, p.messageTTL)
+	return p.pss.SendRawContext(ctx, PssAddress(to), pt, msg, p.messageTTL)
 }
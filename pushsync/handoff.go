@@ -0,0 +1,123 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pushsync
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/chunk"
+)
+
+// HandoffDB is the localstore interface needed to hand off chunks
+type HandoffDB interface {
+	// addresses of chunks not yet confirmed to be stored in their neighbourhood
+	UnsyncedAddresses() ([]chunk.Address, error)
+	// iterate over chunks of a proximity order bin
+	SubscribePull(ctx context.Context, bin uint8, since, until uint64) (<-chan chunk.Descriptor, func())
+	LastPullSubscriptionBinID(bin uint8) (uint64, error)
+	Get(ctx context.Context, mode chunk.ModeGet, addr chunk.Address) (chunk.Chunk, error)
+}
+
+// HandoffPubSub is the PubSub interface needed to hand off chunks
+type HandoffPubSub interface {
+	PubSub
+	// SendContext is Send which gives up when the context is done
+	SendContext(ctx context.Context, to []byte, topic string, msg []byte) error
+}
+
+// contextPubSub bounds sending of a HandoffPubSub by a context
+type contextPubSub struct {
+	HandoffPubSub
+	ctx context.Context
+}
+
+// Send sends a message, giving up when the context is done
+func (c *contextPubSub) Send(to []byte, topic string, msg []byte) error {
+	return c.SendContext(c.ctx, to, topic, msg)
+}
+
+// Handoff pushes the chunks in the area of responsibility of the node, the bins
+// from depth up, to their nearest neighbours. It is meant to be called before
+// the node shuts down to reduce the loss of data availability.
+// Chunks not yet confirmed to be synced are pushed first, followed by the
+// chunks of the most proximate bins, which are replicated by the fewest nodes.
+// Handoff returns the number of chunks sent, it stops early when ctx is done,
+// also when it is waiting for a chunk to be accepted for sending.
+func Handoff(ctx context.Context, db HandoffDB, ps HandoffPubSub, depth uint8) (sent int, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.GetOrRegisterCounter("pushsync.handoff.sent", nil).Inc(int64(sent))
+		log.Info("chunk handoff finished", "sent", sent, "elapsed", time.Since(start), "err", err)
+	}()
+
+	send := func(addr chunk.Address) error {
+		ch, err := db.Get(ctx, chunk.ModeGetSync, addr)
+		if err != nil {
+			// the chunk may have been garbage collected in the meantime
+			log.Debug("chunk handoff get", "addr", addr, "err", err)
+			return nil
+		}
+		if err := sendChunkMsg(&contextPubSub{HandoffPubSub: ps, ctx: ctx}, ch); err != nil {
+			return err
+		}
+		sent++
+		return nil
+	}
+
+	unsynced, err := db.UnsyncedAddresses()
+	if err != nil {
+		return sent, err
+	}
+	pushed := make(map[string]bool, len(unsynced))
+	for _, addr := range unsynced {
+		pushed[addr.Hex()] = true
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+		if err := send(addr); err != nil {
+			return sent, err
+		}
+	}
+
+	for bin := int(chunk.MaxPO); bin >= int(depth); bin-- {
+		until, err := db.LastPullSubscriptionBinID(uint8(bin))
+		if err != nil {
+			return sent, err
+		}
+		if until == 0 {
+			continue
+		}
+		descriptors, stop := db.SubscribePull(ctx, uint8(bin), 0, until)
+		for d := range descriptors {
+			if pushed[d.Address.Hex()] {
+				continue
+			}
+			if err := send(d.Address); err != nil {
+				stop()
+				return sent, err
+			}
+		}
+		stop()
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
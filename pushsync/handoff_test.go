@@ -0,0 +1,164 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pushsync
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethersphere/swarm/chunk"
+	chunktesting "github.com/ethersphere/swarm/chunk/testing"
+	"github.com/ethersphere/swarm/storage/localstore"
+)
+
+// TestHandoff checks that unsynced chunks are handed off first, followed by
+// all chunks in the area of responsibility, each only once
+func TestHandoff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pushsync-handoff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	baseKey := make([]byte, 32)
+	db, err := localstore.New(dir, baseKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	uploaded := chunktesting.GenerateTestRandomChunks(10)
+	if _, err := db.Put(ctx, chunk.ModePutUpload, uploaded...); err != nil {
+		t.Fatal(err)
+	}
+	synced := chunktesting.GenerateTestRandomChunks(100)
+	if _, err := db.Put(ctx, chunk.ModePutSync, synced...); err != nil {
+		t.Fatal(err)
+	}
+
+	var received []chunk.Address
+	lb := newLoopBack()
+	lb.Register(pssChunkTopic, true, func(msg []byte, _ *p2p.Peer) error {
+		chmsg, err := decodeChunkMsg(msg)
+		if err != nil {
+			return err
+		}
+		received = append(received, chmsg.Addr)
+		return nil
+	})
+	ps := &testPubSub{loopBack: lb}
+
+	depth := uint8(1)
+	sent, err := Handoff(ctx, db, ps, depth)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// all uploaded chunks are unsynced and are sent first
+	for i, ch := range uploaded {
+		if !bytes.Equal(received[i], ch.Address()) {
+			t.Fatalf("expected unsynced chunk %s to be sent at %d, got %s", ch.Address(), i, received[i])
+		}
+	}
+	// followed by the synced chunks in the area of responsibility
+	expected := len(uploaded)
+	for _, ch := range synced {
+		if chunk.Proximity(baseKey, ch.Address()) >= int(depth) {
+			expected++
+		}
+	}
+	if sent != expected || len(received) != expected {
+		t.Fatalf("expected %d chunks to be sent, got %d, received %d", expected, sent, len(received))
+	}
+	seen := make(map[string]bool)
+	for _, addr := range received {
+		if seen[addr.Hex()] {
+			t.Fatalf("chunk %s sent more than once", addr)
+		}
+		seen[addr.Hex()] = true
+		if po := chunk.Proximity(baseKey, addr); po < int(depth) && !isUploaded(uploaded, addr) {
+			t.Fatalf("chunk %s outside of the area of responsibility sent", addr)
+		}
+	}
+}
+
+// TestHandoffTimeout checks that the handoff stops when the context is done
+// while it waits for chunks to be accepted for sending
+func TestHandoffTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pushsync-handoff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := localstore.New(dir, make([]byte, 32), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Put(context.Background(), chunk.ModePutUpload, chunktesting.GenerateTestRandomChunks(10)...); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	errC := make(chan error)
+	go func() {
+		_, err := Handoff(ctx, db, &blockingPubSub{testPubSub: &testPubSub{loopBack: newLoopBack()}}, 0)
+		errC <- err
+	}()
+	select {
+	case err := <-errC:
+		if err != context.DeadlineExceeded {
+			t.Fatalf("expected error %v, got %v", context.DeadlineExceeded, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("handoff not stopped after the timeout")
+	}
+}
+
+// SendContext needed to implement HandoffPubSub interface
+func (tps *testPubSub) SendContext(ctx context.Context, to []byte, topic string, msg []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return tps.Send(to, topic, msg)
+}
+
+// blockingPubSub never accepts messages for sending, like a pubsub with a full outbox
+type blockingPubSub struct {
+	*testPubSub
+}
+
+func (bps *blockingPubSub) SendContext(ctx context.Context, to []byte, topic string, msg []byte) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func isUploaded(chunks []chunk.Chunk, addr chunk.Address) bool {
+	for _, ch := range chunks {
+		if bytes.Equal(ch.Address(), addr) {
+			return true
+		}
+	}
+	return false
+}
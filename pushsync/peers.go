@@ -0,0 +1,116 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pushsync
+
+import (
+	"encoding/hex"
+	"sync"
+)
+
+// maxPeerStatCount is the number of chunks pushed to a peer at which its
+// counts are halved, so that its receipt rate follows its recent availability
+const maxPeerStatCount = 128
+
+// neighbourhoodLister is implemented by pubsubs which know the connected
+// peers, used to choose the peer a chunk is pushed to among the peers in
+// its neighbourhood
+type neighbourhoodLister interface {
+	// NeighbourhoodPeers returns the addresses of the connected peers in
+	// the neighbourhood of addr, closest first
+	NeighbourhoodPeers(addr []byte) [][]byte
+}
+
+// PeerStat is the push-sync availability of a peer
+type PeerStat struct {
+	Pushed    uint64 // number of recent chunks pushed to the peer
+	Receipted uint64 // number of recent chunks pushed to the peer which were receipted
+}
+
+// receiptRate returns the estimated rate at which chunks pushed to the peer are
+// receipted, peers without chunks pushed to them are estimated at one half
+func (s PeerStat) receiptRate() float64 {
+	return float64(s.Receipted+1) / float64(s.Pushed+2)
+}
+
+// peerStats tracks the push-sync availability of the peers chunks are pushed to
+type peerStats struct {
+	mu    sync.Mutex
+	peers map[string]*PeerStat
+}
+
+func newPeerStats() *peerStats {
+	return &peerStats{
+		peers: make(map[string]*PeerStat),
+	}
+}
+
+// choose returns the candidate with the highest receipt rate, the closest
+// one of them if several have the same rate
+func (s *peerStats) choose(candidates [][]byte) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var chosen []byte
+	var best float64
+	for _, c := range candidates {
+		var rate float64
+		if stat, ok := s.peers[string(c)]; ok {
+			rate = stat.receiptRate()
+		} else {
+			rate = PeerStat{}.receiptRate()
+		}
+		if chosen == nil || rate > best {
+			chosen, best = c, rate
+		}
+	}
+	return chosen
+}
+
+// pushed records that a chunk was pushed to the peer
+func (s *peerStats) pushed(peer []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stat, ok := s.peers[string(peer)]
+	if !ok {
+		stat = &PeerStat{}
+		s.peers[string(peer)] = stat
+	}
+	stat.Pushed++
+	if stat.Pushed >= maxPeerStatCount {
+		stat.Pushed /= 2
+		stat.Receipted /= 2
+	}
+}
+
+// receipted records that a chunk pushed to the peer was receipted
+func (s *peerStats) receipted(peer []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stat, ok := s.peers[string(peer)]; ok && stat.Receipted < stat.Pushed {
+		stat.Receipted++
+	}
+}
+
+// stats returns the availability of the peers chunks were pushed to by their hex encoded address
+func (s *peerStats) stats() map[string]PeerStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make(map[string]PeerStat, len(s.peers))
+	for peer, stat := range s.peers {
+		stats[hex.EncodeToString([]byte(peer))] = *stat
+	}
+	return stats
+}
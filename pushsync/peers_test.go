@@ -0,0 +1,161 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pushsync
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/storage"
+)
+
+// TestPeerStatsChoose tests that the peer with the highest receipt rate is
+// chosen, the closest one on ties, and that counts follow recent availability
+func TestPeerStatsChoose(t *testing.T) {
+	stats := newPeerStats()
+	a, b := []byte{1}, []byte{2}
+
+	if got := stats.choose([][]byte{a, b}); !bytes.Equal(got, a) {
+		t.Fatalf("expected the closest peer without stats, got %x", got)
+	}
+	stats.pushed(a)
+	if got := stats.choose([][]byte{a, b}); !bytes.Equal(got, b) {
+		t.Fatalf("expected the peer without stats over the one without receipts, got %x", got)
+	}
+	stats.pushed(b)
+	stats.receipted(b)
+	stats.receipted(a)
+	if got := stats.choose([][]byte{a, b}); !bytes.Equal(got, a) {
+		t.Fatalf("expected the closest peer on ties, got %x", got)
+	}
+
+	for i := 1; i < maxPeerStatCount; i++ {
+		stats.pushed(b)
+	}
+	if got := stats.stats()[hex.EncodeToString(b)]; got.Pushed != maxPeerStatCount/2 || got.Receipted != 0 {
+		t.Fatalf("expected counts to be halved, got %+v", got)
+	}
+	// receipts never outnumber pushed chunks
+	for i := 0; i < maxPeerStatCount; i++ {
+		stats.receipted(b)
+	}
+	if got := stats.stats()[hex.EncodeToString(b)]; got.Receipted != got.Pushed {
+		t.Fatalf("expected receipts to be capped by pushed chunks, got %+v", got)
+	}
+}
+
+// neighbourhoodPubSub is a testPubSub with peers in the neighbourhood of
+// every chunk, of which only the receipting one stores and receipts chunks
+type neighbourhoodPubSub struct {
+	*testPubSub
+	peers      [][]byte
+	receipting []byte
+	sent       chan []byte // peers chunks are pushed to
+}
+
+func (ps *neighbourhoodPubSub) NeighbourhoodPeers([]byte) [][]byte {
+	return ps.peers
+}
+
+func (ps *neighbourhoodPubSub) Send(to []byte, topic string, msg []byte) error {
+	if topic != pssDirectChunkTopic {
+		return ps.testPubSub.Send(to, topic, msg)
+	}
+	ps.sent <- to
+	if !bytes.Equal(to, ps.receipting) {
+		return nil
+	}
+	chmsg, err := decodeChunkMsg(msg)
+	if err != nil {
+		return err
+	}
+	rmsg, err := rlp.EncodeToBytes(&receiptMsg{Addr: chmsg.Addr})
+	if err != nil {
+		return err
+	}
+	return ps.testPubSub.Send(chmsg.Origin, pssReceiptTopic, rmsg)
+}
+
+// TestPusherPrefersReceiptingPeer tests that chunks are pushed to the peer in
+// their neighbourhood whose pushed chunks are receipted
+func TestPusherPrefersReceiptingPeer(t *testing.T) {
+	bad, good := []byte{1}, []byte{2}
+	lb := newLoopBack()
+	ps := &neighbourhoodPubSub{
+		testPubSub: &testPubSub{lb, func([]byte) bool { return false }},
+		peers:      [][]byte{bad, good},
+		receipting: good,
+		sent:       make(chan []byte, 1),
+	}
+	chunkCnt := 10
+	index := &liveIndex{
+		chunks: make(chan storage.Chunk),
+		synced: make(chan storage.Address, chunkCnt),
+	}
+	p := NewPusher(index, ps, chunk.NewTags(), nil)
+	defer p.Close()
+
+	for i := 0; i < chunkCnt; i++ {
+		addr := make([]byte, 32)
+		binary.BigEndian.PutUint64(addr, uint64(i))
+		index.chunks <- storage.NewChunk(addr, nil)
+		var to []byte
+		select {
+		case to = <-ps.sent:
+		case <-time.After(retryInterval / 2):
+			t.Fatalf("chunk %d not pushed", i)
+		}
+		// the first chunk goes to the closest peer, the following ones to the receipting peer
+		if i == 0 {
+			if !bytes.Equal(to, bad) {
+				t.Fatalf("expected the first chunk to be pushed to the closest peer, got %x", to)
+			}
+			continue
+		}
+		if !bytes.Equal(to, good) {
+			t.Fatalf("expected chunk %d to be pushed to the receipting peer, got %x", i, to)
+		}
+		// wait for the receipt to be counted before the next chunk is pushed
+		deadline := time.Now().Add(retryInterval / 2)
+		for p.PeerStats()[hex.EncodeToString(good)].Receipted != uint64(i) {
+			if time.Now().After(deadline) {
+				t.Fatalf("chunk %d not receipted", i)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	for i := 1; i < chunkCnt; i++ {
+		select {
+		case <-index.synced:
+		case <-time.After(retryInterval / 2):
+			t.Fatalf("%d of %d receipted chunks synced", i-1, chunkCnt-1)
+		}
+	}
+
+	stats := p.PeerStats()
+	if got := stats[hex.EncodeToString(bad)]; got.Pushed != 1 || got.Receipted != 0 {
+		t.Fatalf("unexpected stats of the peer without receipts: %+v", got)
+	}
+	if got := stats[hex.EncodeToString(good)]; got.Pushed != uint64(chunkCnt-1) || got.Receipted != uint64(chunkCnt-1) {
+		t.Fatalf("unexpected stats of the receipting peer: %+v", got)
+	}
+}
@@ -26,8 +26,9 @@ import (
 )
 
 const (
-	pssChunkTopic   = "PUSHSYNC_CHUNKS"   // pss topic for chunks
-	pssReceiptTopic = "PUSHSYNC_RECEIPTS" // pss topic for statement of custody receipts
+	pssChunkTopic       = "PUSHSYNC_CHUNKS"        // pss topic for chunks
+	pssDirectChunkTopic = "PUSHSYNC_CHUNKS_DIRECT" // pss topic for chunks pushed to a chosen peer in their neighbourhood
+	pssReceiptTopic     = "PUSHSYNC_RECEIPTS"      // pss topic for statement of custody receipts
 )
 
 // PubSub is a Postal Service interface needed to send/receive chunks and receipts for push syncing
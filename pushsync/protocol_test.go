@@ -60,7 +60,7 @@ func TestProtocol(t *testing.T) {
 	// isClosestTo function mocked
 	isClosestTo := func([]byte) bool { return false }
 	// start push syncing in a go routine
-	p := NewPusher(tp, &testPubSub{lb, isClosestTo}, tags)
+	p := NewPusher(tp, &testPubSub{lb, isClosestTo}, tags, nil)
 	defer p.Close()
 
 	synced := make(map[int]int)
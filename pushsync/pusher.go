@@ -44,10 +44,25 @@ type DB interface {
 
 var retryInterval = 10 * time.Second // time interval between retries
 
+// syncedInterval is the time interval at which receipted chunks are set synced,
+// so that the synced count of an upload tag progresses while the upload is still
+// being split and stored instead of only after the push index is iterated
+var syncedInterval = 500 * time.Millisecond
+
+const (
+	// maxSyncedBatch is the number of receipted chunks at which they are set
+	// synced without waiting for syncedInterval
+	maxSyncedBatch = 256
+	// sendWorkers is the maximal number of chunks sent concurrently, so that
+	// chunks of an upload are pushed as fast as the splitter stores them
+	sendWorkers = 16
+)
+
 // Pusher takes care of the push syncing
 type Pusher struct {
 	store          DB                     // localstore DB
 	tags           *chunk.Tags            // tags to update counts
+	events         *chunk.Events          // chunk lifecycle events, may be nil
 	quit           chan struct{}          // channel to signal quitting on all loops
 	closedChunks   chan struct{}          // channel to signal sync loop terminated
 	closedReceipts chan struct{}          // channel to signal sync loop terminated
@@ -55,8 +70,12 @@ type Pusher struct {
 	pushedMu       sync.Mutex
 	syncedAddrs    []storage.Address
 	syncedAddrsMu  sync.Mutex
+	syncedFull     chan struct{} // signals that maxSyncedBatch chunks are waiting to be set synced
+	sends          chan struct{} // semaphore of the chunks being sent
+	sendsWg        sync.WaitGroup
 	receipts       chan []byte // channel to receive receipts
 	ps             PubSub      // PubSub interface to send chunks and receive receipts
+	peers          *peerStats  // availability of the peers chunks are pushed to
 	logger         log.Logger  // custom logger
 }
 
@@ -67,6 +86,8 @@ type pushedItem struct {
 	shortcut bool             // if the chunk receipt was sent by self
 	sentAt   time.Time        // first sent at time
 	synced   bool             // set when chunk got synced
+	receipts int              // number of receipts received for the chunk
+	peer     []byte           // peer the chunk was last pushed to, nil if it was sent to its address
 	span     opentracing.Span // roundtrip span
 }
 
@@ -75,16 +96,21 @@ type pushedItem struct {
 // - a DB interface to subscribe to push sync index to allow iterating over recently stored chunks
 // - a pubsub interface to send chunks and receive statements of custody
 // - tags that hold the tags
-func NewPusher(store DB, ps PubSub, tags *chunk.Tags) *Pusher {
+// - an optional chunk lifecycle events hub to report pushed and synced chunks
+func NewPusher(store DB, ps PubSub, tags *chunk.Tags, events *chunk.Events) *Pusher {
 	p := &Pusher{
 		store:          store,
 		tags:           tags,
+		events:         events,
 		quit:           make(chan struct{}),
 		closedChunks:   make(chan struct{}),
 		closedReceipts: make(chan struct{}),
 		pushed:         make(map[string]*pushedItem),
 		receipts:       make(chan []byte),
+		syncedFull:     make(chan struct{}, 1),
+		sends:          make(chan struct{}, sendWorkers),
 		ps:             ps,
+		peers:          newPeerStats(),
 		logger:         log.New("self", label(ps.BaseAddr())),
 	}
 	go p.chunksWorker()
@@ -120,11 +146,16 @@ func (p *Pusher) chunksWorker() {
 	var chunks <-chan chunk.Chunk
 	var unsubscribe func()
 	defer close(p.closedChunks)
+	defer p.sendsWg.Wait()
 
 	// timer, initially set to 0 to fall through select case on timer.C for initialisation
 	timer := time.NewTimer(0)
 	defer timer.Stop()
 
+	// receipted chunks are set synced while the push index is being iterated
+	syncedTicker := time.NewTicker(syncedInterval)
+	defer syncedTicker.Stop()
+
 	chunksInBatch := -1
 	var batchStartTime time.Time
 	ctx := context.Background()
@@ -153,11 +184,34 @@ func (p *Pusher) chunksWorker() {
 
 			metrics.GetOrRegisterCounter("pusher.send-chunk.send-to-sync", nil).Inc(1)
 			// send the chunk and ignore the error
-
-			if err := p.sendChunkMsg(ch); err != nil {
-				metrics.GetOrRegisterCounter("pusher.send-chunk-msg.err", nil).Inc(1)
-				p.logger.Error("error sending chunk", "addr", ch.Address().Hex(), "err", err)
+			select {
+			case p.sends <- struct{}{}:
+			case <-p.quit:
+				if unsubscribe != nil {
+					unsubscribe()
+				}
+				return
 			}
+			p.sendsWg.Add(1)
+			go func(ch chunk.Chunk) {
+				defer func() {
+					<-p.sends
+					p.sendsWg.Done()
+				}()
+				if err := p.sendChunkMsg(ch); err != nil {
+					metrics.GetOrRegisterCounter("pusher.send-chunk-msg.err", nil).Inc(1)
+					p.logger.Error("error sending chunk", "addr", ch.Address().Hex(), "err", err)
+				} else {
+					p.events.Emit(chunk.EventPushed, "push", ch.Address())
+				}
+			}(ch)
+
+		// set the receipted chunks synced, so that the tags of uploads still
+		// being split and stored show their synced progress
+		case <-syncedTicker.C:
+			p.setSynced(ctx)
+		case <-p.syncedFull:
+			p.setSynced(ctx)
 
 			// retry interval timer triggers starting from new
 		case <-timer.C:
@@ -171,30 +225,7 @@ func (p *Pusher) chunksWorker() {
 					unsubscribe()
 				}
 
-				// reset synced list
-				p.syncedAddrsMu.Lock()
-				syncedAddrs := p.syncedAddrs
-				p.syncedAddrs = nil
-				p.syncedAddrsMu.Unlock()
-
-				// set chunk status to synced, insert to db GC index
-				if err := p.store.Set(ctx, chunk.ModeSetSyncPush, syncedAddrs...); err != nil {
-					log.Error("pushsync: error setting chunks to synced", "err", err)
-				}
-
-				// delete from pushed item
-				p.pushedMu.Lock()
-				for i := 0; i < len(syncedAddrs); i++ {
-					hexaddr := syncedAddrs[i].Hex()
-					item, found := p.pushed[hexaddr]
-					if found && item.tag != nil && item.tag.Done(chunk.StateSynced) {
-						p.logger.Debug("closing root span for tag", "taguid", item.tag.Uid, "tagname", item.tag.Name)
-						item.tag.FinishRootSpan()
-					}
-
-					delete(p.pushed, hexaddr)
-				}
-				p.pushedMu.Unlock()
+				p.setSynced(ctx)
 
 				// we don't want to record the first iteration
 				if chunksInBatch != -1 {
@@ -222,6 +253,38 @@ func (p *Pusher) chunksWorker() {
 	}
 }
 
+// setSynced sets the receipted chunks synced in the store, which counts them on
+// their tags, and forgets them
+func (p *Pusher) setSynced(ctx context.Context) {
+	// reset synced list
+	p.syncedAddrsMu.Lock()
+	syncedAddrs := p.syncedAddrs
+	p.syncedAddrs = nil
+	p.syncedAddrsMu.Unlock()
+	if len(syncedAddrs) == 0 {
+		return
+	}
+
+	// set chunk status to synced, insert to db GC index
+	if err := p.store.Set(ctx, chunk.ModeSetSyncPush, syncedAddrs...); err != nil {
+		log.Error("pushsync: error setting chunks to synced", "err", err)
+	}
+
+	// delete from pushed item
+	p.pushedMu.Lock()
+	for i := 0; i < len(syncedAddrs); i++ {
+		hexaddr := syncedAddrs[i].Hex()
+		item, found := p.pushed[hexaddr]
+		if found && item.tag != nil && item.tag.Done(chunk.StateSynced) {
+			p.logger.Debug("closing root span for tag", "taguid", item.tag.Uid, "tagname", item.tag.Name)
+			item.tag.FinishRootSpan()
+		}
+
+		delete(p.pushed, hexaddr)
+	}
+	p.pushedMu.Unlock()
+}
+
 func (p *Pusher) receiptsWorker() {
 	defer close(p.closedReceipts)
 
@@ -241,12 +304,26 @@ func (p *Pusher) receiptsWorker() {
 			// ignore if already received receipt
 			p.pushedMu.Lock()
 			item, found := p.pushed[hexaddr]
+			var peer []byte
+			if found {
+				peer = item.peer
+			}
 			p.pushedMu.Unlock()
 			if !found {
 				metrics.GetOrRegisterCounter("pusher.receipts.not-found", nil).Inc(1)
 				p.logger.Trace("not wanted or already got... ignore", "addr", hexaddr)
 				break
 			}
+			item.receipts++
+			if peer != nil && item.receipts == 1 {
+				p.peers.receipted(peer)
+			}
+			p.events.EmitSynced("receipt", addr, item.receipts)
+			// aggregate the first receipt from a storer node into the upload tag,
+			// receipts pushed locally when self is the closest node are not counted
+			if item.receipts == 1 && !item.shortcut && item.tag != nil {
+				item.tag.Inc(chunk.StateReceipted)
+			}
 			if item.synced { // already got receipt in this same batch
 				metrics.GetOrRegisterCounter("pusher.receipts.already-synced", nil).Inc(1)
 				p.logger.Trace("just synced... ignore", "addr", hexaddr)
@@ -265,8 +342,15 @@ func (p *Pusher) receiptsWorker() {
 			// collect synced addresses and corresponding items to do subsequent batch operations
 			p.syncedAddrsMu.Lock()
 			p.syncedAddrs = append(p.syncedAddrs, addr)
+			full := len(p.syncedAddrs) >= maxSyncedBatch
 			p.syncedAddrsMu.Unlock()
 			item.synced = true
+			if full {
+				select {
+				case p.syncedFull <- struct{}{}:
+				default:
+				}
+			}
 
 		case <-p.quit:
 			return
@@ -296,12 +380,61 @@ func (p *Pusher) pushReceipt(addr []byte) {
 }
 
 // sendChunkMsg sends chunks to their destination
-// using the PubSub interface Send method (e.g., pss neighbourhood addressing)
+// using the PubSub interface Send method (e.g., pss neighbourhood addressing).
+// If several connected peers are in the neighbourhood of a chunk outside of
+// the neighbourhood of the node, the chunk is pushed to the one whose pushed
+// chunks were receipted at the highest rate instead.
 func (p *Pusher) sendChunkMsg(ch chunk.Chunk) error {
+	peer := p.choosePeer(ch.Address())
+	p.pushedMu.Lock()
+	if item, ok := p.pushed[ch.Address().Hex()]; ok {
+		item.peer = peer
+	}
+	p.pushedMu.Unlock()
+	if peer == nil {
+		p.logger.Trace("send chunk", "addr", label(ch.Address()))
+		return sendChunkMsg(p.ps, ch)
+	}
+	p.logger.Trace("send chunk to peer", "addr", label(ch.Address()), "peer", label(peer))
+	metrics.GetOrRegisterCounter("pusher.send.direct", nil).Inc(1)
+	p.peers.pushed(peer)
+	return sendChunkMsgTo(p.ps, peer, pssDirectChunkTopic, ch)
+}
+
+// choosePeer returns the peer the chunk is pushed to, nil if it is sent to its address
+func (p *Pusher) choosePeer(addr chunk.Address) []byte {
+	nl, ok := p.ps.(neighbourhoodLister)
+	if !ok {
+		return nil
+	}
+	// within the neighbourhood of the node chunks are sent to all its neighbours
+	if dr, ok := p.ps.(depthReporter); ok && chunk.Proximity(p.ps.BaseAddr(), addr) >= dr.NeighbourhoodDepth() {
+		return nil
+	}
+	candidates := nl.NeighbourhoodPeers(addr)
+	if len(candidates) < 2 {
+		return nil
+	}
+	return p.peers.choose(candidates)
+}
+
+// PeerStats returns the push-sync availability of the peers chunks
+// were pushed to, by their hex encoded overlay address
+func (p *Pusher) PeerStats() map[string]PeerStat {
+	return p.peers.stats()
+}
+
+// sendChunkMsg sends a chunk to its neighbourhood
+func sendChunkMsg(ps PubSub, ch chunk.Chunk) error {
+	return sendChunkMsgTo(ps, ch.Address(), pssChunkTopic, ch)
+}
+
+// sendChunkMsgTo sends a chunk to the address with the topic
+func sendChunkMsgTo(ps PubSub, to []byte, topic string, ch chunk.Chunk) error {
 	rlpTimer := time.Now()
 
 	cmsg := &chunkMsg{
-		Origin: p.ps.BaseAddr(),
+		Origin: ps.BaseAddr(),
 		Addr:   ch.Address(),
 		Data:   ch.Data(),
 		Nonce:  newNonce(),
@@ -310,12 +443,10 @@ func (p *Pusher) sendChunkMsg(ch chunk.Chunk) error {
 	if err != nil {
 		return err
 	}
-	p.logger.Trace("send chunk", "addr", label(ch.Address()))
-
 	metrics.GetOrRegisterResettingTimer("pusher.send.chunk.rlp", nil).UpdateSince(rlpTimer)
 
 	defer metrics.GetOrRegisterResettingTimer("pusher.send.chunk.pss", nil).UpdateSince(time.Now())
-	return p.ps.Send(ch.Address()[:], pssChunkTopic, msg)
+	return ps.Send(to, topic, msg)
 }
 
 // needToSync checks if a chunk needs to be push-synced:
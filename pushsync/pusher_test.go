@@ -95,7 +95,7 @@ func TestPusher(t *testing.T) {
 	// construct the mock push sync index iterator
 	tp := newTestPushSyncIndex(chunkCnt, tagIDs, tags, sent)
 	// start push syncing in a go routine
-	p := NewPusher(tp, &testPubSub{lb, func([]byte) bool { return false }}, tags)
+	p := NewPusher(tp, &testPubSub{lb, func([]byte) bool { return false }}, tags, nil)
 	defer p.Close()
 	// collect synced chunks until all chunks synced
 	// wait on errc for errors on any thread
@@ -308,5 +308,72 @@ func checkTags(t *testing.T, expTotal int64, tagIDs []uint32, tags *chunk.Tags)
 		}
 
 		chunktesting.CheckTag(t, tag, 0, expTotal, 0, expTotal, expTotal, expTotal)
+
+		// all receipts come from storer nodes since the pusher is never the closest node
+		if n := tag.Get(chunk.StateReceipted); n != expTotal {
+			t.Fatalf("mismatch receipted chunks, got %d want %d", n, expTotal)
+		}
+	}
+}
+
+// liveIndex mocks a push index whose subscription stays open for chunks
+// stored later, like the localstore push index while an upload is split
+type liveIndex struct {
+	chunks chan storage.Chunk
+	synced chan storage.Address
+}
+
+func (li *liveIndex) SubscribePush(context.Context) (<-chan storage.Chunk, func()) {
+	return li.chunks, func() {}
+}
+
+func (li *liveIndex) Set(ctx context.Context, _ chunk.ModeSet, addrs ...storage.Address) error {
+	for _, addr := range addrs {
+		li.synced <- addr
+	}
+	return nil
+}
+
+// TestPusherSyncsWhileStoring tests that chunks are set synced while the
+// upload they belong to is still being stored
+func TestPusherSyncsWhileStoring(t *testing.T) {
+	lb := newLoopBack()
+	lb.Register(pssChunkTopic, false, func(msg []byte, _ *p2p.Peer) error {
+		chmsg, err := decodeChunkMsg(msg)
+		if err != nil {
+			return err
+		}
+		rmsg, err := rlp.EncodeToBytes(&receiptMsg{Addr: chmsg.Addr})
+		if err != nil {
+			return err
+		}
+		return lb.Send(chmsg.Origin, pssReceiptTopic, rmsg)
+	})
+	chunkCnt := 10
+	tags := chunk.NewTags()
+	tag, err := tags.Create("upload", int64(2*chunkCnt), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := &liveIndex{
+		chunks: make(chan storage.Chunk),
+		synced: make(chan storage.Address, chunkCnt),
+	}
+	p := NewPusher(index, &testPubSub{lb, func([]byte) bool { return false }}, tags, nil)
+	defer p.Close()
+
+	// the first half of the upload is stored, the rest is still being split
+	for i := 0; i < chunkCnt; i++ {
+		addr := make([]byte, 32)
+		binary.BigEndian.PutUint64(addr, uint64(i))
+		index.chunks <- storage.NewChunk(addr, nil).WithTagID(tag.Uid)
+	}
+	timeout := time.After(retryInterval / 2)
+	for i := 0; i < chunkCnt; i++ {
+		select {
+		case <-index.synced:
+		case <-timeout:
+			t.Fatalf("%d of %d stored chunks set synced before the push index was iterated", i, chunkCnt)
+		}
 	}
 }
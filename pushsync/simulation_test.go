@@ -206,7 +206,7 @@ func newServiceFunc(ctx *adapters.ServiceContext, bucket *sync.Map) (node.Servic
 
 	pubSub := pss.NewPubSub(ps, 1*time.Second)
 	// setup pusher
-	p := NewPusher(lstore, pubSub, tags)
+	p := NewPusher(lstore, pubSub, tags, nil)
 	bucket.Store(bucketKeyPushSyncer, p)
 
 	// setup storer
@@ -21,14 +21,25 @@ import (
 	"encoding/hex"
 
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/spancontext"
 	"github.com/ethersphere/swarm/storage"
+	lru "github.com/hashicorp/golang-lru"
 	olog "github.com/opentracing/opentracing-go/log"
 )
 
+// forwardedCapacity is the number of misplaced chunks remembered as forwarded
+const forwardedCapacity = 10000
+
+// depthReporter is implemented by pubsubs which know the neighbourhood depth
+// of the node, used to check if received chunks fall within its area of responsibility
+type depthReporter interface {
+	NeighbourhoodDepth() int
+}
+
 // Store is the storage interface to save chunks
 // NetStore implements this interface
 type Store interface {
@@ -39,8 +50,9 @@ type Store interface {
 type Storer struct {
 	store      Store      // store to put chunks in, and retrieve them from
 	ps         PubSub     // pubsub interface to receive chunks and send receipts
-	deregister func()     // deregister the registered handler when Storer is closed
+	deregister func()     // deregister the registered handlers when Storer is closed
 	logger     log.Logger // custom logger
+	forwarded  *lru.Cache // addresses of misplaced chunks forwarded to their neighbourhood
 }
 
 // NewStorer constructs a Storer
@@ -51,14 +63,23 @@ type Storer struct {
 // - a statement of custody receipt is sent as a response to the originator
 // it sets a cancel function that deregisters the handler
 func NewStorer(store Store, ps PubSub) *Storer {
+	forwarded, _ := lru.New(forwardedCapacity)
 	s := &Storer{
-		store:  store,
-		ps:     ps,
-		logger: log.New("self", label(ps.BaseAddr())),
+		store:     store,
+		ps:        ps,
+		logger:    log.New("self", label(ps.BaseAddr())),
+		forwarded: forwarded,
 	}
-	s.deregister = ps.Register(pssChunkTopic, true, func(msg []byte, _ *p2p.Peer) error {
+	deregisterChunks := ps.Register(pssChunkTopic, true, func(msg []byte, _ *p2p.Peer) error {
 		return s.handleChunkMsg(msg)
 	})
+	deregisterDirect := ps.Register(pssDirectChunkTopic, false, func(msg []byte, _ *p2p.Peer) error {
+		return s.handleDirectChunkMsg(msg)
+	})
+	s.deregister = func() {
+		deregisterChunks()
+		deregisterDirect()
+	}
 	return s
 }
 
@@ -86,13 +107,56 @@ func (s *Storer) handleChunkMsg(msg []byte) error {
 	return s.processChunkMsg(ctx, chmsg)
 }
 
+// handleDirectChunkMsg is called by the pss dispatcher on pssDirectChunkTopic msgs,
+// chunks pushed to this node as a chosen peer in their neighbourhood. They are
+// stored and receipted even if another node is closer, the chunk reaches it
+// with pull syncing. Chunks outside of the neighbourhood are processed as
+// chunks sent to their address.
+func (s *Storer) handleDirectChunkMsg(msg []byte) error {
+	chmsg, err := decodeChunkMsg(msg)
+	if err != nil {
+		return err
+	}
+
+	ctx, osp := spancontext.StartSpan(context.Background(), "handle.direct.chunk.msg")
+	defer osp.Finish()
+	hexaddr := hex.EncodeToString(chmsg.Addr)
+	osp.LogFields(olog.String("ref", hexaddr))
+	osp.SetTag("addr", hexaddr)
+	s.logger.Trace("handleDirectChunkMsg", "chunk", hexaddr, "origin", label(chmsg.Origin))
+
+	if !s.inNeighbourhood(chmsg.Addr) {
+		return s.processChunkMsg(ctx, chmsg)
+	}
+	metrics.GetOrRegisterCounter("pushsync.storer.direct", nil).Inc(1)
+	ch := storage.NewChunk(chmsg.Addr, chmsg.Data)
+	if _, err := s.store.Put(ctx, chunk.ModePutSync, ch); err != nil {
+		return err
+	}
+	return s.sendReceiptMsg(ctx, chmsg)
+}
+
 // processChunkMsg processes a chunk received via pss pssChunkTopic
 // these chunk messages are sent to their address as destination
 // using neighbourhood addressing. Therefore nodes only handle
 // chunks that fall within their area of responsibility.
 // Upon receiving the chunk is saved and a statement of custody
 // receipt message is sent as a response to the originator.
+// Peers with a stale view of kademlia may send chunks to nodes outside
+// of their neighbourhood, these chunks are forwarded onwards once
+// instead of being stored.
 func (s *Storer) processChunkMsg(ctx context.Context, chmsg *chunkMsg) error {
+	if !s.inNeighbourhood(chmsg.Addr) {
+		if ok, _ := s.forwarded.ContainsOrAdd(string(chmsg.Addr), struct{}{}); !ok {
+			metrics.GetOrRegisterCounter("pushsync.storer.misplaced", nil).Inc(1)
+			s.logger.Debug("chunk outside of neighbourhood: forward", "ref", label(chmsg.Addr), "origin", label(chmsg.Origin))
+			return s.forwardChunkMsg(ctx, chmsg)
+		}
+		// the chunk came back after it was forwarded, there is
+		// no closer node known to the network, so store it here
+		s.logger.Debug("forwarded chunk returned: store", "ref", label(chmsg.Addr))
+	}
+
 	ch := storage.NewChunk(chmsg.Addr, chmsg.Data)
 	if _, err := s.store.Put(ctx, chunk.ModePutSync, ch); err != nil {
 		return err
@@ -106,6 +170,41 @@ func (s *Storer) processChunkMsg(ctx context.Context, chmsg *chunkMsg) error {
 	return nil
 }
 
+// inNeighbourhood returns true if the chunk address plausibly falls within
+// the area of responsibility of the node, that is its proximity is at least
+// the neighbourhood depth or the node is the closest known one to the address.
+// Without a known depth every chunk is accepted.
+func (s *Storer) inNeighbourhood(addr []byte) bool {
+	dr, ok := s.ps.(depthReporter)
+	if !ok {
+		return true
+	}
+	if chunk.Proximity(s.ps.BaseAddr(), addr) >= dr.NeighbourhoodDepth() {
+		return true
+	}
+	return s.ps.IsClosestTo(addr)
+}
+
+// forwardChunkMsg sends a misplaced chunk on towards its neighbourhood,
+// keeping the originator so that the receipt is sent back to it.
+func (s *Storer) forwardChunkMsg(ctx context.Context, chmsg *chunkMsg) error {
+	_, osp := spancontext.StartSpan(ctx, "forward.chunk")
+	defer osp.Finish()
+	osp.LogFields(olog.String("ref", hex.EncodeToString(chmsg.Addr)))
+
+	fmsg := &chunkMsg{
+		Addr:   chmsg.Addr,
+		Data:   chmsg.Data,
+		Origin: chmsg.Origin,
+		Nonce:  newNonce(),
+	}
+	msg, err := rlp.EncodeToBytes(fmsg)
+	if err != nil {
+		return err
+	}
+	return s.ps.Send(chmsg.Addr, pssChunkTopic, msg)
+}
+
 // sendReceiptMsg sends a statement of custody receipt message
 // to the originator of a push-synced chunk message.
 // Including a unique nonce makes the receipt immune to deduplication cache
@@ -0,0 +1,200 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pushsync
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// depthPubSub is a PubSub with a neighbourhood depth recording the chunks and receipts sent
+type depthPubSub struct {
+	*testPubSub
+	depth    int
+	sent     []*chunkMsg
+	receipts int
+}
+
+// NeighbourhoodDepth implements the depthReporter interface
+func (d *depthPubSub) NeighbourhoodDepth() int {
+	return d.depth
+}
+
+// Send records the chunk messages instead of delivering them
+func (d *depthPubSub) Send(to []byte, topic string, msg []byte) error {
+	if topic == pssReceiptTopic {
+		d.receipts++
+	}
+	if topic != pssChunkTopic {
+		return nil
+	}
+	chmsg, err := decodeChunkMsg(msg)
+	if err != nil {
+		return err
+	}
+	d.sent = append(d.sent, chmsg)
+	return nil
+}
+
+// TestStorerNeighbourhood tests that a storer stores chunks within its area of
+// responsibility and forwards misplaced chunks once before storing them
+func TestStorerNeighbourhood(t *testing.T) {
+	store := &sync.Map{}
+	closest := make([]byte, 32)
+	ps := &depthPubSub{
+		testPubSub: &testPubSub{newLoopBack(), func(addr []byte) bool {
+			return bytes.Equal(addr, closest)
+		}},
+		depth: 4,
+	}
+	s := NewStorer(&testStore{store}, ps)
+	defer s.Close()
+
+	origin := []byte{1, 2, 3}
+	nonce := newNonce()
+	receive := func(addr []byte) {
+		msg, err := rlp.EncodeToBytes(&chunkMsg{
+			Addr:   addr,
+			Data:   []byte{0},
+			Origin: origin,
+			Nonce:  nonce,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.handleChunkMsg(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stored := func(addr []byte) bool {
+		_, ok := store.Load(binary.BigEndian.Uint64(addr[:8]))
+		return ok
+	}
+
+	// the base address is all zeros, so this chunk is within depth
+	near := make([]byte, 32)
+	near[31] = 1
+	receive(near)
+	if !stored(near) {
+		t.Fatal("chunk within depth not stored")
+	}
+	if len(ps.sent) != 0 {
+		t.Fatalf("expected no forwarded chunks, got %v", len(ps.sent))
+	}
+
+	far := make([]byte, 32)
+	far[0] = 0x80
+	receive(far)
+	if stored(far) {
+		t.Fatal("chunk outside of depth stored")
+	}
+	if len(ps.sent) != 1 {
+		t.Fatalf("expected 1 forwarded chunk, got %v", len(ps.sent))
+	}
+	fwd := ps.sent[0]
+	if !bytes.Equal(fwd.Addr, far) || !bytes.Equal(fwd.Origin, origin) {
+		t.Fatalf("unexpected forwarded chunk %x from %x", fwd.Addr, fwd.Origin)
+	}
+	if bytes.Equal(fwd.Nonce, nonce) {
+		t.Fatal("forwarded chunk has the nonce of the received one")
+	}
+
+	// the chunk returning after it was forwarded is stored
+	receive(far)
+	if !stored(far) {
+		t.Fatal("returned chunk not stored")
+	}
+	if len(ps.sent) != 1 {
+		t.Fatalf("expected 1 forwarded chunk, got %v", len(ps.sent))
+	}
+
+	// chunks outside of depth are stored if the node is the closest one
+	closest[0] = 0x40
+	receive(closest)
+	if !stored(closest) {
+		t.Fatal("chunk closest to the node not stored")
+	}
+	if len(ps.sent) != 1 {
+		t.Fatalf("expected 1 forwarded chunk, got %v", len(ps.sent))
+	}
+}
+
+// TestStorerDirectChunk tests that chunks pushed to the storer as a chosen peer
+// in their neighbourhood are stored and receipted even if it is not the closest node
+func TestStorerDirectChunk(t *testing.T) {
+	store := &sync.Map{}
+	ps := &depthPubSub{
+		testPubSub: &testPubSub{newLoopBack(), func([]byte) bool { return false }},
+		depth:      4,
+	}
+	s := NewStorer(&testStore{store}, ps)
+	defer s.Close()
+
+	message := func(addr []byte) []byte {
+		msg, err := rlp.EncodeToBytes(&chunkMsg{
+			Addr:   addr,
+			Data:   []byte{0},
+			Origin: []byte{1, 2, 3},
+			Nonce:  newNonce(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+	stored := func(addr []byte) bool {
+		_, ok := store.Load(binary.BigEndian.Uint64(addr[:8]))
+		return ok
+	}
+
+	near := make([]byte, 32)
+	near[31] = 1
+	if err := s.handleChunkMsg(message(near)); err != nil {
+		t.Fatal(err)
+	}
+	if ps.receipts != 0 {
+		t.Fatalf("expected no receipt for a chunk sent to its address, got %d", ps.receipts)
+	}
+	direct := make([]byte, 32)
+	direct[7] = 1
+	if err := s.handleDirectChunkMsg(message(direct)); err != nil {
+		t.Fatal(err)
+	}
+	if !stored(direct) {
+		t.Fatal("direct chunk within depth not stored")
+	}
+	if ps.receipts != 1 {
+		t.Fatalf("expected a receipt for the direct chunk, got %d", ps.receipts)
+	}
+
+	// misplaced direct chunks are forwarded to their address
+	far := make([]byte, 32)
+	far[0] = 0x80
+	if err := s.handleDirectChunkMsg(message(far)); err != nil {
+		t.Fatal(err)
+	}
+	if stored(far) {
+		t.Fatal("direct chunk outside of depth stored")
+	}
+	if len(ps.sent) != 1 || ps.receipts != 1 {
+		t.Fatalf("expected the direct chunk outside of depth to be forwarded without a receipt, got %d forwarded and %d receipts", len(ps.sent), ps.receipts)
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swarm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	gethlog "github.com/ethereum/go-ethereum/log"
+	"github.com/ethersphere/swarm/api"
+	"github.com/ethersphere/swarm/internal/debug"
+	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/network/stream"
+	"github.com/ethersphere/swarm/pss"
+)
+
+// ErrConfigReloadUnavailable is returned by ConfigAPI.Reload
+// if the node was started without a way to load its configuration
+var ErrConfigReloadUnavailable = errors.New("configuration reload not available")
+
+// ConfigReload reports the outcome of a configuration reload
+// by the names of the configuration fields that changed
+type ConfigReload struct {
+	Applied         []string `json:"applied"`         // changed values applied to the running node
+	RequiresRestart []string `json:"requiresRestart"` // changed values that take effect only after a restart
+}
+
+// configReloader applies changes of a group of configuration fields
+// to a running node
+type configReloader struct {
+	fields []string
+	apply  func(s *Swarm, config *api.Config) error
+}
+
+// configReloaders lists the configuration fields that are safe to change
+// without restarting the node
+var configReloaders = []configReloader{
+	{
+		fields: []string{"LogVerbosity", "LogVmodule"},
+		apply: func(_ *Swarm, config *api.Config) error {
+			return applyLogConfig(config)
+		},
+	},
+	{
+		fields: []string{"BandwidthDailyCap"},
+		apply: func(s *Swarm, config *api.Config) error {
+			s.bandwidth.SetDailyCap(config.BandwidthDailyCap)
+			return nil
+		},
+	},
+	{
+		fields: []string{"BandwidthUpstream", "BandwidthSync", "BandwidthRetrieval", "BandwidthPss"},
+		apply: func(s *Swarm, config *api.Config) error {
+			s.scheduler.SetLimits(config.BandwidthUpstream, map[string]uint64{
+				stream.Spec.Name:        config.BandwidthSync,
+				s.retrieval.Spec().Name: config.BandwidthRetrieval,
+				pss.ProtocolName:        config.BandwidthPss,
+			})
+			return nil
+		},
+	},
+	{
+		fields: []string{"SwapPaymentThreshold", "SwapDisconnectThreshold"},
+		apply: func(s *Swarm, config *api.Config) error {
+			if s.swap == nil {
+				return nil
+			}
+			return s.swap.SetThresholds(int64(config.SwapPaymentThreshold), int64(config.SwapDisconnectThreshold))
+		},
+	},
+	{
+		fields: []string{"DbCapacity"},
+		apply: func(s *Swarm, config *api.Config) error {
+			return s.localStore.SetCapacity(config.DbCapacity)
+		},
+	},
+}
+
+// derivedConfigFields are set when the configuration is initialised
+// with the account key and are not compared on reload
+var derivedConfigFields = map[string]bool{
+	"Path":        true,
+	"ChunkDbPath": true,
+	"BaseKey":     true,
+	"PublicKey":   true,
+	"BzzKey":      true,
+	"Enode":       true,
+	"BzzAccount":  true,
+}
+
+// applyLogConfig sets the log verbosity and the per source file log levels
+// of the configuration, if they are set
+func applyLogConfig(config *api.Config) error {
+	if config.LogVerbosity != "" {
+		lvl, err := gethlog.LvlFromString(config.LogVerbosity)
+		if err != nil {
+			return err
+		}
+		debug.Handler.Verbosity(int(lvl))
+	}
+	if config.LogVmodule != "" {
+		return debug.Handler.Vmodule(config.LogVmodule)
+	}
+	return nil
+}
+
+// SetConfigLoader sets the function reading the current configuration
+// of the node when it is reloaded with ConfigAPI.Reload
+func (s *Swarm) SetConfigLoader(load func() (*api.Config, error)) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	s.configLoader = load
+}
+
+// ReloadConfig applies the values of config that are safe to change to the
+// running node and reports the changed values that require a restart.
+// Nothing is applied if a changed value is invalid.
+func (s *Swarm) ReloadConfig(config *api.Config) (*ConfigReload, error) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	return s.reloadConfig(config)
+}
+
+func (s *Swarm) reloadConfig(config *api.Config) (*ConfigReload, error) {
+	changed, err := changedConfigFields(s.config, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateReload(changed, config); err != nil {
+		return nil, err
+	}
+	reload := &ConfigReload{
+		Applied:         []string{},
+		RequiresRestart: []string{},
+	}
+	reloadable := make(map[string]bool)
+	for _, r := range configReloaders {
+		var fields []string
+		for _, name := range r.fields {
+			reloadable[name] = true
+			if changed[name] {
+				fields = append(fields, name)
+			}
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		if err := r.apply(s, config); err != nil {
+			return reload, fmt.Errorf("apply %v: %v", fields, err)
+		}
+		target := reflect.ValueOf(s.config).Elem()
+		for _, name := range fields {
+			target.FieldByName(name).Set(reflect.ValueOf(config).Elem().FieldByName(name))
+		}
+		reload.Applied = append(reload.Applied, fields...)
+	}
+	for _, name := range sortedConfigFields(changed) {
+		if !reloadable[name] {
+			reload.RequiresRestart = append(reload.RequiresRestart, name)
+		}
+	}
+	log.Info("configuration reloaded", "applied", reload.Applied, "requires restart", reload.RequiresRestart)
+	return reload, nil
+}
+
+// validateReload checks the changed values that would be applied by a reload
+func validateReload(changed map[string]bool, config *api.Config) error {
+	if changed["LogVerbosity"] && config.LogVerbosity != "" {
+		if _, err := gethlog.LvlFromString(config.LogVerbosity); err != nil {
+			return err
+		}
+	}
+	if (changed["SwapPaymentThreshold"] || changed["SwapDisconnectThreshold"]) && config.SwapDisconnectThreshold <= config.SwapPaymentThreshold {
+		return fmt.Errorf("disconnect threshold %d must be higher than payment threshold %d", config.SwapDisconnectThreshold, config.SwapPaymentThreshold)
+	}
+	return nil
+}
+
+// changedConfigFields returns the names of the serialised configuration fields
+// that differ between the two configurations. Fields of embedded parameters are
+// named by the type of the parameters and the field, like HiveParams.Discovery.
+func changedConfigFields(old, new *api.Config) (map[string]bool, error) {
+	changed := make(map[string]bool)
+	err := compareConfigFields(reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem(), "", changed)
+	return changed, err
+}
+
+func compareConfigFields(old, new reflect.Value, prefix string, changed map[string]bool) error {
+	t := old.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" || derivedConfigFields[f.Name] {
+			continue
+		}
+		if f.Anonymous && f.Type.Kind() == reflect.Ptr && f.Type.Elem().Kind() == reflect.Struct {
+			o, n := old.Field(i), new.Field(i)
+			if o.IsNil() || n.IsNil() {
+				if o.IsNil() != n.IsNil() {
+					changed[f.Name] = true
+				}
+				continue
+			}
+			if err := compareConfigFields(o.Elem(), n.Elem(), f.Type.Elem().Name()+".", changed); err != nil {
+				return err
+			}
+			continue
+		}
+		o, err := json.Marshal(old.Field(i).Interface())
+		if err != nil {
+			return err
+		}
+		n, err := json.Marshal(new.Field(i).Interface())
+		if err != nil {
+			return err
+		}
+		if string(o) != string(n) {
+			changed[prefix+f.Name] = true
+		}
+	}
+	return nil
+}
+
+// sortedConfigFields returns the names in the order of the configuration fields
+func sortedConfigFields(names map[string]bool) (sorted []string) {
+	var walk func(t reflect.Type, prefix string)
+	walk = func(t reflect.Type, prefix string) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Anonymous && f.Type.Kind() == reflect.Ptr && f.Type.Elem().Kind() == reflect.Struct {
+				if names[f.Name] {
+					sorted = append(sorted, f.Name)
+				}
+				walk(f.Type.Elem(), f.Type.Elem().Name()+".")
+				continue
+			}
+			if names[prefix+f.Name] {
+				sorted = append(sorted, prefix+f.Name)
+			}
+		}
+	}
+	walk(reflect.TypeOf(api.Config{}), "")
+	return sorted
+}
+
+// ConfigAPI reloads the configuration of a running node,
+// invoked as bzz_reloadConfig
+type ConfigAPI struct {
+	swarm *Swarm
+}
+
+// ReloadConfig reads the configuration of the node again, applies the values
+// that are safe to change and reports the changed values that require a restart.
+func (c *ConfigAPI) ReloadConfig() (*ConfigReload, error) {
+	s := c.swarm
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	if s.configLoader == nil {
+		return nil, ErrConfigReloadUnavailable
+	}
+	config, err := s.configLoader()
+	if err != nil {
+		return nil, err
+	}
+	return s.reloadConfig(config)
+}
@@ -0,0 +1,107 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+
+package swarm
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethersphere/swarm/api"
+)
+
+// TestReloadConfig checks that a reload applies the values that are safe
+// to change and reports the changed values that require a restart
+func TestReloadConfig(t *testing.T) {
+	config := api.NewConfig()
+
+	dir, err := ioutil.TempDir("", "node")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config.Path = dir
+
+	privkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodekey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.Init(privkey, nodekey)
+
+	s, err := NewSwarm(config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configAPI := &ConfigAPI{s}
+	if _, err := configAPI.ReloadConfig(); err != ErrConfigReloadUnavailable {
+		t.Fatalf("expected error %v without a config loader, got %v", ErrConfigReloadUnavailable, err)
+	}
+
+	// the loaded configuration is not initialised with the account key
+	loaded := api.NewConfig()
+	loaded.BandwidthDailyCap = 1000
+	loaded.DbCapacity = 5000
+	loaded.NetworkID = 42
+	loaded.HiveParams.Discovery = false
+	s.SetConfigLoader(func() (*api.Config, error) {
+		return loaded, nil
+	})
+
+	reload, err := configAPI.ReloadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"BandwidthDailyCap", "DbCapacity"}; !reflect.DeepEqual(reload.Applied, want) {
+		t.Errorf("got applied %v, want %v", reload.Applied, want)
+	}
+	if want := []string{"HiveParams.Discovery", "NetworkID"}; !reflect.DeepEqual(reload.RequiresRestart, want) {
+		t.Errorf("got requires restart %v, want %v", reload.RequiresRestart, want)
+	}
+	if s.config.BandwidthDailyCap != 1000 || s.config.DbCapacity != 5000 {
+		t.Errorf("expected applied values in the node config, got daily cap %v, capacity %v", s.config.BandwidthDailyCap, s.config.DbCapacity)
+	}
+	if s.config.NetworkID == 42 {
+		t.Error("expected network ID that requires a restart not to be changed in the node config")
+	}
+	stats, err := s.localStore.GCStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Capacity != 5000 {
+		t.Errorf("got localstore capacity %v, want 5000", stats.Capacity)
+	}
+
+	// nothing is applied if a changed value is invalid
+	loaded.BandwidthDailyCap = 2000
+	loaded.SwapDisconnectThreshold = loaded.SwapPaymentThreshold
+	if _, err := configAPI.ReloadConfig(); err == nil {
+		t.Fatal("expected reload with invalid swap thresholds to fail")
+	}
+	if s.config.BandwidthDailyCap != 1000 {
+		t.Errorf("expected daily cap not to be changed by a failed reload, got %v", s.config.BandwidthDailyCap)
+	}
+}
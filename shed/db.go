@@ -23,6 +23,7 @@
 package shed
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -32,7 +33,6 @@ import (
 	"github.com/ethersphere/swarm/log"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/iterator"
-	"github.com/syndtr/goleveldb/leveldb/opt"
 )
 
 const (
@@ -40,31 +40,64 @@ const (
 	writePauseWarningThrottler = 1 * time.Minute
 )
 
-// DB provides abstractions over LevelDB in order to
-// implement complex structures using fields and ordered indexes.
+// ErrReadOnly is returned by write operations on a DB
+// opened with the ReadOnly option.
+var ErrReadOnly = errors.New("read-only database")
+
+// DB provides abstractions over a storage Engine, LevelDB by default,
+// in order to implement complex structures using fields and ordered indexes.
 // It provides a schema functionality to store fields and indexes
 // information about naming and types.
 type DB struct {
-	ldb  *leveldb.DB
-	quit chan struct{} // Quit channel to stop the metrics collection before closing the database
+	engine   Engine
+	readOnly bool
+	// schema of a read-only database, which can not be stored
+	roSchema *schema
+	quit     chan struct{} // Quit channel to stop the metrics collection before closing the database
+}
+
+// Options holds optional parameters for opening a DB.
+type Options struct {
+	// Engine is the name of the storage engine. If it is empty,
+	// the engine of an existing database or EngineLevelDB
+	// for a new database is used.
+	Engine string
+	// ReadOnly opens an existing database without allowing any
+	// writes. Fields and indexes that are not in the stored schema
+	// can still be created, but only for the lifetime of the DB.
+	ReadOnly bool
 }
 
 // NewDB constructs a new DB and validates the schema
 // if it exists in database on the given path.
 // metricsPrefix is used for metrics collection for the given DB.
 func NewDB(path string, metricsPrefix string) (db *DB, err error) {
-	ldb, err := leveldb.OpenFile(path, &opt.Options{
-		OpenFilesCacheCapacity: openFileLimit,
-	})
+	return NewDBWithEngine(path, metricsPrefix, "")
+}
+
+// NewDBWithEngine constructs a new DB like NewDB, using the storage
+// engine with the given name. If the name is empty, the engine of an
+// existing database or EngineLevelDB for a new database is used.
+func NewDBWithEngine(path string, metricsPrefix string, engine string) (db *DB, err error) {
+	return NewDBWithOptions(path, metricsPrefix, &Options{Engine: engine})
+}
+
+// NewDBWithOptions constructs a new DB like NewDB with provided Options.
+func NewDBWithOptions(path string, metricsPrefix string, o *Options) (db *DB, err error) {
+	if o == nil {
+		o = new(Options)
+	}
+	e, err := openEngine(path, o.Engine, o.ReadOnly)
 	if err != nil {
 		return nil, err
 	}
 	db = &DB{
-		ldb: ldb,
+		engine:   e,
+		readOnly: o.ReadOnly,
 	}
 
 	if _, err = db.getSchema(); err != nil {
-		if err == leveldb.ErrNotFound {
+		if err == leveldb.ErrNotFound && !o.ReadOnly {
 			// save schema with initialized default fields
 			if err = db.putSchema(schema{
 				Fields:  make(map[string]fieldSpec),
@@ -80,14 +113,19 @@ func NewDB(path string, metricsPrefix string) (db *DB, err error) {
 	// Create a quit channel for the periodic metrics collector and run it
 	db.quit = make(chan struct{})
 
-	go db.meter(metricsPrefix, 10*time.Second)
+	if p, ok := e.(propertyGetter); ok {
+		go db.meter(p, metricsPrefix, 10*time.Second)
+	}
 
 	return db, nil
 }
 
-// Put wraps LevelDB Put method to increment metrics counter.
+// Put wraps Engine Put method to increment metrics counter.
 func (db *DB) Put(key []byte, value []byte) (err error) {
-	err = db.ldb.Put(key, value, nil)
+	if db.readOnly {
+		return ErrReadOnly
+	}
+	err = db.engine.Put(key, value)
 	if err != nil {
 		metrics.GetOrRegisterCounter("DB.putFail", nil).Inc(1)
 		return err
@@ -96,9 +134,9 @@ func (db *DB) Put(key []byte, value []byte) (err error) {
 	return nil
 }
 
-// Get wraps LevelDB Get method to increment metrics counter.
+// Get wraps Engine Get method to increment metrics counter.
 func (db *DB) Get(key []byte) (value []byte, err error) {
-	value, err = db.ldb.Get(key, nil)
+	value, err = db.engine.Get(key)
 	if err != nil {
 		if err == leveldb.ErrNotFound {
 			metrics.GetOrRegisterCounter("DB.getNotFound", nil).Inc(1)
@@ -111,9 +149,9 @@ func (db *DB) Get(key []byte) (value []byte, err error) {
 	return value, nil
 }
 
-// Has wraps LevelDB Has method to increment metrics counter.
+// Has wraps Engine Has method to increment metrics counter.
 func (db *DB) Has(key []byte) (yes bool, err error) {
-	yes, err = db.ldb.Has(key, nil)
+	yes, err = db.engine.Has(key)
 	if err != nil {
 		metrics.GetOrRegisterCounter("DB.hasFail", nil).Inc(1)
 		return false, err
@@ -122,9 +160,12 @@ func (db *DB) Has(key []byte) (yes bool, err error) {
 	return yes, nil
 }
 
-// Delete wraps LevelDB Delete method to increment metrics counter.
+// Delete wraps Engine Delete method to increment metrics counter.
 func (db *DB) Delete(key []byte) (err error) {
-	err = db.ldb.Delete(key, nil)
+	if db.readOnly {
+		return ErrReadOnly
+	}
+	err = db.engine.Delete(key)
 	if err != nil {
 		metrics.GetOrRegisterCounter("DB.deleteFail", nil).Inc(1)
 		return err
@@ -133,16 +174,19 @@ func (db *DB) Delete(key []byte) (err error) {
 	return nil
 }
 
-// NewIterator wraps LevelDB NewIterator method to increment metrics counter.
+// NewIterator wraps Engine NewIterator method to increment metrics counter.
 func (db *DB) NewIterator() iterator.Iterator {
 	metrics.GetOrRegisterCounter("DB.newiterator", nil).Inc(1)
 
-	return db.ldb.NewIterator(nil, nil)
+	return db.engine.NewIterator()
 }
 
-// WriteBatch wraps LevelDB Write method to increment metrics counter.
+// WriteBatch wraps Engine Write method to increment metrics counter.
 func (db *DB) WriteBatch(batch *leveldb.Batch) (err error) {
-	err = db.ldb.Write(batch, nil)
+	if db.readOnly {
+		return ErrReadOnly
+	}
+	err = db.engine.Write(batch)
 	if err != nil {
 		metrics.GetOrRegisterCounter("DB.writebatchFail", nil).Inc(1)
 		return err
@@ -151,13 +195,18 @@ func (db *DB) WriteBatch(batch *leveldb.Batch) (err error) {
 	return nil
 }
 
-// Close closes LevelDB database.
+// Close closes the database.
 func (db *DB) Close() (err error) {
 	close(db.quit)
-	return db.ldb.Close()
+	return db.engine.Close()
 }
 
-func (db *DB) meter(prefix string, refresh time.Duration) {
+// snapshot returns a consistent read-only view of the database.
+func (db *DB) snapshot() (Snapshot, error) {
+	return db.engine.GetSnapshot()
+}
+
+func (db *DB) meter(ldb propertyGetter, prefix string, refresh time.Duration) {
 	// Meter for measuring the total time spent in database compaction
 	compTimeMeter := metrics.NewRegisteredMeter(prefix+"compact/time", nil)
 	// Meter for measuring the data read during compaction
@@ -190,7 +239,7 @@ func (db *DB) meter(prefix string, refresh time.Duration) {
 	// Iterate ad infinitum and collect the stats
 	for i := 1; true; i++ {
 		// Retrieve the database stats
-		stats, err := db.ldb.GetProperty("leveldb.stats")
+		stats, err := ldb.GetProperty("leveldb.stats")
 		if err != nil {
 			log.Error("Failed to read database stats", "err", err)
 			continue
@@ -215,6 +264,14 @@ func (db *DB) meter(prefix string, refresh time.Duration) {
 			if len(parts) != 6 {
 				break
 			}
+			// number of tables and size of every level
+			level := strings.TrimSpace(parts[0])
+			if tables, err := strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64); err == nil {
+				metrics.GetOrRegisterGauge(prefix+"level/"+level+"/tables", nil).Update(tables)
+			}
+			if size, err := strconv.ParseFloat(strings.TrimSpace(parts[2]), 64); err == nil {
+				metrics.GetOrRegisterGauge(prefix+"level/"+level+"/size", nil).Update(int64(size * 1024 * 1024))
+			}
 			for idx, counter := range parts[3:] {
 				value, err := strconv.ParseFloat(strings.TrimSpace(counter), 64)
 				if err != nil {
@@ -236,7 +293,7 @@ func (db *DB) meter(prefix string, refresh time.Duration) {
 		}
 
 		// Retrieve the write delay statistic
-		writedelay, err := db.ldb.GetProperty("leveldb.writedelay")
+		writedelay, err := ldb.GetProperty("leveldb.writedelay")
 		if err != nil {
 			log.Error("Failed to read database write delay statistic", "err", err)
 			continue
@@ -272,7 +329,7 @@ func (db *DB) meter(prefix string, refresh time.Duration) {
 		delaystats[0], delaystats[1] = delayN, duration.Nanoseconds()
 
 		// Retrieve the database iostats.
-		ioStats, err := db.ldb.GetProperty("leveldb.iostats")
+		ioStats, err := ldb.GetProperty("leveldb.iostats")
 		if err != nil {
 			log.Error("Failed to read database iostats", "err", err)
 			continue
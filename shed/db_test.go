@@ -90,6 +90,79 @@ func TestDB_persistence(t *testing.T) {
 	}
 }
 
+// TestDB_readOnly validates that a DB opened with the ReadOnly option
+// serves stored values, rejects writes and can be opened more than once.
+func TestDB_readOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shed-test-readonly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := NewDBWithOptions(dir, "", &Options{ReadOnly: true}); err == nil {
+		t.Fatal("expected error opening a missing database read-only")
+	}
+
+	db, err := NewDB(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stringField, err := db.NewStringField("preserve-me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "persistent value"
+	err = stringField.Put(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = NewDBWithOptions(dir, "", &Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	db2, err := NewDBWithOptions(dir, "", &Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db2.Close()
+
+	stringField, err = db.NewStringField("preserve-me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := stringField.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got string %q, want %q", got, want)
+	}
+	err = stringField.Put("new value")
+	if err != ErrReadOnly {
+		t.Errorf("got error %v, want %v", err, ErrReadOnly)
+	}
+
+	// fields not in the stored schema are empty
+	newField, err := db.NewStringField("new-field")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = newField.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "" {
+		t.Errorf("got string %q, want empty", got)
+	}
+}
+
 // newTestDB is a helper function that constructs a
 // temporary database and returns a cleanup function that must
 // be called to remove the data.
@@ -0,0 +1,136 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package shed
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+)
+
+// Names of the supported storage engines.
+const (
+	// EngineLevelDB stores all keys and values in LevelDB.
+	EngineLevelDB = "leveldb"
+	// EngineShardedFile stores large values in sharded files and keeps
+	// only keys, small values and references to files in LevelDB, which
+	// greatly reduces the amount of data rewritten by LevelDB compaction.
+	EngineShardedFile = "shardedfile"
+)
+
+// engineFileName is the name of the file in the database directory
+// that records the engine of databases not using EngineLevelDB.
+const engineFileName = "ENGINE"
+
+// ErrUnknownEngine is returned when a storage engine name is not supported.
+var ErrUnknownEngine = errors.New("unknown storage engine")
+
+// Engine is the key/value persistence layer that DB fields and indexes
+// are stored in. Keys are iterated in lexicographical order. Get returns
+// leveldb.ErrNotFound for missing keys, and batches and iterators are
+// the ones from the goleveldb package, regardless of the implementation.
+type Engine interface {
+	Get(key []byte) (value []byte, err error)
+	Has(key []byte) (yes bool, err error)
+	Put(key []byte, value []byte) (err error)
+	Delete(key []byte) (err error)
+	Write(batch *leveldb.Batch) (err error)
+	NewIterator() iterator.Iterator
+	GetSnapshot() (Snapshot, error)
+	Close() (err error)
+}
+
+// Snapshot is a consistent read-only view of an Engine.
+type Snapshot interface {
+	Get(key []byte) (value []byte, err error)
+	Has(key []byte) (yes bool, err error)
+	Release()
+}
+
+// propertyGetter is implemented by engines that expose LevelDB
+// properties used for database metrics.
+type propertyGetter interface {
+	GetProperty(name string) (value string, err error)
+}
+
+// Engines returns the names of all supported storage engines.
+func Engines() []string {
+	return []string{EngineLevelDB, EngineShardedFile}
+}
+
+// DetectEngine returns the name of the engine of the database
+// on the given path, or an empty string if there is no database.
+func DetectEngine(path string) (name string, err error) {
+	data, err := ioutil.ReadFile(filepath.Join(path, engineFileName))
+	if err == nil {
+		return strings.TrimSpace(string(data)), nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	// databases created before engines were introduced have no engine file
+	if _, err := os.Stat(filepath.Join(path, "CURRENT")); err == nil {
+		return EngineLevelDB, nil
+	}
+	return "", nil
+}
+
+// openEngine opens the storage engine with the given name on path.
+// If name is empty, the engine of an existing database is used,
+// or EngineLevelDB for a new one. An error is returned if the name
+// does not match the engine of an existing database. A read-only
+// engine can only be opened for an existing database.
+func openEngine(path string, name string, readOnly bool) (Engine, error) {
+	existing, err := DetectEngine(path)
+	if err != nil {
+		return nil, err
+	}
+	if readOnly && existing == "" {
+		return nil, fmt.Errorf("no database to open read-only in %s", path)
+	}
+	if name == "" {
+		name = existing
+		if name == "" {
+			name = EngineLevelDB
+		}
+	}
+	if existing != "" && existing != name {
+		return nil, fmt.Errorf("database %s uses storage engine %q, not %q", path, existing, name)
+	}
+
+	switch name {
+	case EngineLevelDB:
+		return newLevelDBEngine(path, readOnly)
+	case EngineShardedFile:
+		if existing == "" {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return nil, err
+			}
+			if err := ioutil.WriteFile(filepath.Join(path, engineFileName), []byte(name), 0644); err != nil {
+				return nil, err
+			}
+		}
+		return newShardedFileEngine(path, readOnly)
+	}
+	return nil, ErrUnknownEngine
+}
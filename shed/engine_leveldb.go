@@ -0,0 +1,96 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package shed
+
+import (
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// levelDBEngine is the default Engine storing all data in LevelDB.
+type levelDBEngine struct {
+	ldb *leveldb.DB
+}
+
+func newLevelDBEngine(path string, readOnly bool) (*levelDBEngine, error) {
+	ldb, err := leveldb.OpenFile(path, &opt.Options{
+		OpenFilesCacheCapacity: openFileLimit,
+		ReadOnly:               readOnly,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &levelDBEngine{ldb: ldb}, nil
+}
+
+func (e *levelDBEngine) Get(key []byte) (value []byte, err error) {
+	return e.ldb.Get(key, nil)
+}
+
+func (e *levelDBEngine) Has(key []byte) (yes bool, err error) {
+	return e.ldb.Has(key, nil)
+}
+
+func (e *levelDBEngine) Put(key []byte, value []byte) (err error) {
+	return e.ldb.Put(key, value, nil)
+}
+
+func (e *levelDBEngine) Delete(key []byte) (err error) {
+	return e.ldb.Delete(key, nil)
+}
+
+func (e *levelDBEngine) Write(batch *leveldb.Batch) (err error) {
+	return e.ldb.Write(batch, nil)
+}
+
+func (e *levelDBEngine) NewIterator() iterator.Iterator {
+	return e.ldb.NewIterator(nil, nil)
+}
+
+func (e *levelDBEngine) GetSnapshot() (Snapshot, error) {
+	s, err := e.ldb.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return levelDBSnapshot{s}, nil
+}
+
+func (e *levelDBEngine) GetProperty(name string) (value string, err error) {
+	return e.ldb.GetProperty(name)
+}
+
+func (e *levelDBEngine) Close() (err error) {
+	return e.ldb.Close()
+}
+
+// levelDBSnapshot adapts leveldb.Snapshot to the Snapshot interface.
+type levelDBSnapshot struct {
+	s *leveldb.Snapshot
+}
+
+func (s levelDBSnapshot) Get(key []byte) (value []byte, err error) {
+	return s.s.Get(key, nil)
+}
+
+func (s levelDBSnapshot) Has(key []byte) (yes bool, err error) {
+	return s.s.Has(key, nil)
+}
+
+func (s levelDBSnapshot) Release() {
+	s.s.Release()
+}
@@ -0,0 +1,326 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package shed
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+const (
+	// shardedFileThreshold is the minimal value length
+	// stored in a file instead of the LevelDB index
+	shardedFileThreshold = 512
+	// shardedFileDir is the directory in the database
+	// directory where shard directories are created
+	shardedFileDir = "shards"
+)
+
+// prefixes of values in the sharded file engine index
+const (
+	shardedValueInline byte = iota // value is stored in the index
+	shardedValueFile               // value is stored in a file, followed by its length
+)
+
+var errShardedFileLength = errors.New("sharded file length mismatch")
+
+// shardedFileEngine is an Engine that keeps keys and small values in a
+// LevelDB index and stores larger values, such as chunk data, in files
+// spread over 256 shard directories. File names are derived from keys,
+// so a key always maps to the same file.
+type shardedFileEngine struct {
+	index *leveldb.DB
+	dir   string
+	mu    sync.Mutex // serializes writes to keep index and files consistent
+}
+
+func newShardedFileEngine(path string, readOnly bool) (*shardedFileEngine, error) {
+	index, err := leveldb.OpenFile(path, &opt.Options{
+		OpenFilesCacheCapacity: openFileLimit,
+		ReadOnly:               readOnly,
+	})
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(path, shardedFileDir)
+	if !readOnly {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			index.Close()
+			return nil, err
+		}
+	}
+	return &shardedFileEngine{
+		index: index,
+		dir:   dir,
+	}, nil
+}
+
+// filePath returns the path of the file storing the value for the key.
+func (e *shardedFileEngine) filePath(key []byte) string {
+	h := sha256.Sum256(key)
+	name := hex.EncodeToString(h[:])
+	return filepath.Join(e.dir, name[:2], name[2:])
+}
+
+// resolve returns the value encoded in the index value for the key.
+func (e *shardedFileEngine) resolve(key, v []byte) (value []byte, err error) {
+	if len(v) == 0 {
+		return nil, errors.New("invalid sharded file engine value")
+	}
+	if v[0] == shardedValueInline {
+		return v[1:], nil
+	}
+	if len(v) != 9 {
+		return nil, errors.New("invalid sharded file engine reference")
+	}
+	value, err = ioutil.ReadFile(e.filePath(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, leveldb.ErrNotFound
+		}
+		return nil, err
+	}
+	if uint64(len(value)) != binary.BigEndian.Uint64(v[1:]) {
+		return nil, errShardedFileLength
+	}
+	return value, nil
+}
+
+func (e *shardedFileEngine) Get(key []byte) (value []byte, err error) {
+	v, err := e.index.Get(key, nil)
+	if err != nil {
+		return nil, err
+	}
+	return e.resolve(key, v)
+}
+
+func (e *shardedFileEngine) Has(key []byte) (yes bool, err error) {
+	return e.index.Has(key, nil)
+}
+
+func (e *shardedFileEngine) Put(key []byte, value []byte) (err error) {
+	batch := new(leveldb.Batch)
+	batch.Put(key, value)
+	return e.Write(batch)
+}
+
+func (e *shardedFileEngine) Delete(key []byte) (err error) {
+	batch := new(leveldb.Batch)
+	batch.Delete(key)
+	return e.Write(batch)
+}
+
+// Write stores large values of the batch in files before the index
+// is updated, and removes files no longer referenced after the index
+// is written. A crash between the two steps may leave orphaned files,
+// but never index entries referencing missing files.
+func (e *shardedFileEngine) Write(batch *leveldb.Batch) (err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	r := &shardedBatchReplay{
+		engine: e,
+		index:  new(leveldb.Batch),
+		keys:   make(map[string][]byte),
+		inFile: make(map[string]bool),
+	}
+	if err := batch.Replay(r); err != nil {
+		return err
+	}
+	if r.err != nil {
+		return r.err
+	}
+	// files of deleted keys, or keys overwritten with inline values
+	var remove []string
+	for k, key := range r.keys {
+		inFile, written := r.inFile[k]
+		if inFile {
+			continue
+		}
+		if !written {
+			v, err := e.index.Get(key, nil)
+			if err == leveldb.ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if len(v) == 0 || v[0] != shardedValueFile {
+				continue
+			}
+		}
+		remove = append(remove, e.filePath(key))
+	}
+	if err := e.index.Write(r.index, nil); err != nil {
+		return err
+	}
+	for _, p := range remove {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *shardedFileEngine) NewIterator() iterator.Iterator {
+	return &shardedFileIterator{
+		Iterator: e.index.NewIterator(nil, nil),
+		engine:   e,
+	}
+}
+
+func (e *shardedFileEngine) GetSnapshot() (Snapshot, error) {
+	s, err := e.index.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return shardedFileSnapshot{s: s, engine: e}, nil
+}
+
+func (e *shardedFileEngine) GetProperty(name string) (value string, err error) {
+	return e.index.GetProperty(name)
+}
+
+func (e *shardedFileEngine) Close() (err error) {
+	return e.index.Close()
+}
+
+// shardedBatchReplay converts a batch into an index batch,
+// writing large values to files as they are replayed.
+type shardedBatchReplay struct {
+	engine *shardedFileEngine
+	index  *leveldb.Batch
+	keys   map[string][]byte // all keys in the batch
+	inFile map[string]bool   // for keys with files written by the batch, whether their last value is in the file
+	err    error
+}
+
+func (r *shardedBatchReplay) Put(key, value []byte) {
+	if r.err != nil {
+		return
+	}
+	k := string(key)
+	r.keys[k] = append([]byte(nil), key...)
+	if len(value) < shardedFileThreshold {
+		r.index.Put(key, append([]byte{shardedValueInline}, value...))
+		if _, ok := r.inFile[k]; ok {
+			r.inFile[k] = false
+		}
+		return
+	}
+	if err := writeFileAtomic(r.engine.filePath(key), value); err != nil {
+		r.err = err
+		return
+	}
+	v := make([]byte, 9)
+	v[0] = shardedValueFile
+	binary.BigEndian.PutUint64(v[1:], uint64(len(value)))
+	r.index.Put(key, v)
+	r.inFile[k] = true
+}
+
+func (r *shardedBatchReplay) Delete(key []byte) {
+	if r.err != nil {
+		return
+	}
+	k := string(key)
+	r.keys[k] = append([]byte(nil), key...)
+	r.index.Delete(key)
+	if _, ok := r.inFile[k]; ok {
+		r.inFile[k] = false
+	}
+}
+
+// writeFileAtomic writes data to a temporary file
+// and renames it to path, creating the directory if needed.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// shardedFileIterator resolves values stored in files
+// while iterating over the index.
+type shardedFileIterator struct {
+	iterator.Iterator
+	engine *shardedFileEngine
+	err    error
+}
+
+func (i *shardedFileIterator) Value() []byte {
+	v, err := i.engine.resolve(i.Key(), i.Iterator.Value())
+	if err != nil {
+		i.err = err
+		return nil
+	}
+	return v
+}
+
+func (i *shardedFileIterator) Error() error {
+	if i.err != nil {
+		return i.err
+	}
+	return i.Iterator.Error()
+}
+
+// shardedFileSnapshot is a snapshot of the index. Values stored in files
+// are read when requested and reflect the latest write to the key.
+type shardedFileSnapshot struct {
+	s      *leveldb.Snapshot
+	engine *shardedFileEngine
+}
+
+func (s shardedFileSnapshot) Get(key []byte) (value []byte, err error) {
+	v, err := s.s.Get(key, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.engine.resolve(key, v)
+}
+
+func (s shardedFileSnapshot) Has(key []byte) (yes bool, err error) {
+	return s.s.Has(key, nil)
+}
+
+func (s shardedFileSnapshot) Release() {
+	s.s.Release()
+}
@@ -0,0 +1,250 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package shed
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// TestEngines validates basic operations, batches, iteration
+// and snapshots of all storage engines.
+func TestEngines(t *testing.T) {
+	for _, name := range Engines() {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "shed-test-engine")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			e, err := openEngine(dir, name, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer e.Close()
+
+			small := []byte("small value")
+			large := bytes.Repeat([]byte{1}, 4096)
+
+			if _, err := e.Get([]byte("missing")); err != leveldb.ErrNotFound {
+				t.Fatalf("got error %v, want %v", err, leveldb.ErrNotFound)
+			}
+
+			batch := new(leveldb.Batch)
+			batch.Put([]byte("a"), small)
+			batch.Put([]byte("b"), large)
+			batch.Put([]byte("c"), large)
+			batch.Delete([]byte("c"))
+			if err := e.Write(batch); err != nil {
+				t.Fatal(err)
+			}
+			if err := e.Put([]byte("d"), large); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, tc := range []struct {
+				key   string
+				value []byte
+			}{
+				{"a", small},
+				{"b", large},
+				{"d", large},
+			} {
+				got, err := e.Get([]byte(tc.key))
+				if err != nil {
+					t.Fatalf("get %s: %v", tc.key, err)
+				}
+				if !bytes.Equal(got, tc.value) {
+					t.Errorf("got value of length %v for key %s, want %v", len(got), tc.key, len(tc.value))
+				}
+			}
+			if has, err := e.Has([]byte("c")); err != nil || has {
+				t.Errorf("got has %v, err %v for deleted key", has, err)
+			}
+
+			snapshot, err := e.GetSnapshot()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// overwrite a large value with a small one and remove another one
+			if err := e.Put([]byte("b"), small); err != nil {
+				t.Fatal(err)
+			}
+			if err := e.Delete([]byte("d")); err != nil {
+				t.Fatal(err)
+			}
+			if has, err := snapshot.Has([]byte("d")); err != nil || !has {
+				t.Errorf("got has %v, err %v for key deleted after snapshot", has, err)
+			}
+			snapshot.Release()
+
+			it := e.NewIterator()
+			var keys []string
+			for it.Next() {
+				keys = append(keys, fmt.Sprintf("%s=%d", it.Key(), len(it.Value())))
+			}
+			if err := it.Error(); err != nil {
+				t.Fatal(err)
+			}
+			it.Release()
+			want := fmt.Sprintf("[a=%d b=%d]", len(small), len(small))
+			if got := fmt.Sprint(keys); got != want {
+				t.Errorf("got iterated keys %s, want %s", got, want)
+			}
+
+			if name == EngineShardedFile {
+				// no files are left after large values are overwritten and deleted
+				var files int
+				err := filepath.Walk(filepath.Join(dir, shardedFileDir), func(path string, info os.FileInfo, err error) error {
+					if err != nil {
+						return err
+					}
+					if !info.IsDir() {
+						files++
+					}
+					return nil
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if files != 0 {
+					t.Errorf("got %v sharded files, want 0", files)
+				}
+			}
+		})
+	}
+}
+
+// TestNewDBWithEngine validates that the engine of an existing
+// database is detected and that a different engine can not be used.
+func TestNewDBWithEngine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shed-test-engine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := NewDBWithEngine(dir, "", "unknown"); err != ErrUnknownEngine {
+		t.Fatalf("got error %v, want %v", err, ErrUnknownEngine)
+	}
+
+	db, err := NewDBWithEngine(dir, "", EngineShardedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewDBWithEngine(dir, "", EngineLevelDB); err == nil {
+		t.Fatal("expected error opening database with a different engine")
+	}
+
+	db, err = NewDB(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, ok := db.engine.(*shardedFileEngine); !ok {
+		t.Errorf("got engine %T, want sharded file engine", db.engine)
+	}
+}
+
+// TestMigrateEngine copies a database to a new storage engine
+// and validates that fields and indexes are preserved.
+func TestMigrateEngine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shed-test-migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+
+	db, err := NewDB(src, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	field, err := db.NewStringField("field")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := field.Put("value"); err != nil {
+		t.Fatal(err)
+	}
+	index, err := db.NewIndex("Hash->Data", retrievalIndexFuncs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := make([]Item, 10)
+	for i := range items {
+		items[i] = Item{
+			Address: []byte(fmt.Sprintf("hash%d", i)),
+			Data:    bytes.Repeat([]byte{byte(i)}, 4096),
+		}
+		if err := index.Put(items[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := MigrateEngine(src, dst, EngineShardedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count == 0 {
+		t.Fatal("no data migrated")
+	}
+	if _, err := MigrateEngine(src, dst, EngineShardedFile); err == nil {
+		t.Fatal("expected error migrating to an existing database")
+	}
+
+	db, err = NewDB(dst, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	field, err = db.NewStringField("field")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := field.Get(); err != nil || got != "value" {
+		t.Errorf("got field value %q, err %v", got, err)
+	}
+	index, err = db.NewIndex("Hash->Data", retrievalIndexFuncs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range items {
+		got, err := index.Get(Item{Address: want.Address})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Data, want.Data) {
+			t.Errorf("got data of length %v for %s, want %v", len(got.Data), want.Address, len(want.Data))
+		}
+	}
+}
@@ -43,6 +43,9 @@ type Item struct {
 	BinID           uint64
 	PinCounter      uint64 // maintains the no of time a chunk is pinned
 	Tag             uint32
+	Expiry          int64  // time to live hint as unix nanoseconds, 0 if the chunk does not expire
+	Owner           string // uploader the chunk is accounted to, empty if it is not accounted
+	Size            uint64 // length of the chunk data accounted to the owner
 }
 
 // Merge is a helper method to construct a new
@@ -70,6 +73,15 @@ func (i Item) Merge(i2 Item) (new Item) {
 	if i.Tag == 0 {
 		i.Tag = i2.Tag
 	}
+	if i.Expiry == 0 {
+		i.Expiry = i2.Expiry
+	}
+	if i.Owner == "" {
+		i.Owner = i2.Owner
+	}
+	if i.Size == 0 {
+		i.Size = i2.Size
+	}
 	return i
 }
 
@@ -156,9 +168,9 @@ func (f Index) Get(keyFields Item) (out Item, err error) {
 // fields. Every item must have all fields needed for encoding the
 // key set. The passed slice items will be changed so that they
 // contain data from the index values. No new slice is allocated.
-// This function uses a single leveldb snapshot.
+// This function uses a single database snapshot.
 func (f Index) Fill(items []Item) (err error) {
-	snapshot, err := f.db.ldb.GetSnapshot()
+	snapshot, err := f.db.snapshot()
 	if err != nil {
 		return err
 	}
@@ -169,7 +181,7 @@ func (f Index) Fill(items []Item) (err error) {
 		if err != nil {
 			return err
 		}
-		value, err := snapshot.Get(key, nil)
+		value, err := snapshot.Get(key)
 		if err != nil {
 			return err
 		}
@@ -197,7 +209,7 @@ func (f Index) Has(keyFields Item) (bool, error) {
 // there this Item's encoded key is stored in the index for each of them.
 func (f Index) HasMulti(items ...Item) ([]bool, error) {
 	have := make([]bool, len(items))
-	snapshot, err := f.db.ldb.GetSnapshot()
+	snapshot, err := f.db.snapshot()
 	if err != nil {
 		return nil, err
 	}
@@ -207,7 +219,7 @@ func (f Index) HasMulti(items ...Item) ([]bool, error) {
 		if err != nil {
 			return nil, err
 		}
-		have[i], err = snapshot.Has(key, nil)
+		have[i], err = snapshot.Has(key)
 		if err != nil {
 			return nil, err
 		}
@@ -415,6 +427,11 @@ func incByteSlice(b []byte) (next []byte) {
 	return nil
 }
 
+// Prefix returns the key prefix byte which identifies the index.
+func (f Index) Prefix() byte {
+	return f.prefix[0]
+}
+
 // Count returns the number of items in index.
 func (f Index) Count() (count int, err error) {
 	it := f.db.NewIterator()
@@ -0,0 +1,73 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package shed
+
+import (
+	"fmt"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// migrateEngineBatchSize is the number of key/value pairs
+// copied in a single batch by MigrateEngine
+const migrateEngineBatchSize = 1000
+
+// MigrateEngine copies all data from the database on srcPath to a new
+// database on dstPath that uses the storage engine with the given name.
+// The source database is not changed, so that it can be kept until the
+// new one is verified. It returns the number of copied key/value pairs.
+func MigrateEngine(srcPath, dstPath string, engine string) (count int, err error) {
+	existing, err := DetectEngine(dstPath)
+	if err != nil {
+		return 0, err
+	}
+	if existing != "" {
+		return 0, fmt.Errorf("database already exists on %s", dstPath)
+	}
+	src, err := openEngine(srcPath, "", true)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	dst, err := openEngine(dstPath, engine, false)
+	if err != nil {
+		return 0, err
+	}
+	defer dst.Close()
+
+	it := src.NewIterator()
+	defer it.Release()
+
+	batch := new(leveldb.Batch)
+	for it.Next() {
+		batch.Put(it.Key(), it.Value())
+		count++
+		if batch.Len() >= migrateEngineBatchSize {
+			if err := dst.Write(batch); err != nil {
+				return count, err
+			}
+			batch.Reset()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return count, err
+	}
+	if err := dst.Write(batch); err != nil {
+		return count, err
+	}
+	return count, nil
+}
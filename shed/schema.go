@@ -147,6 +147,9 @@ func (db *DB) schemaIndexPrefix(name string) (id byte, err error) {
 // getSchema retrieves the complete schema from
 // the database.
 func (db *DB) getSchema() (s schema, err error) {
+	if db.roSchema != nil {
+		return *db.roSchema, nil
+	}
 	b, err := db.Get(keySchema)
 	if err != nil {
 		return s, err
@@ -156,8 +159,13 @@ func (db *DB) getSchema() (s schema, err error) {
 }
 
 // putSchema stores the complete schema to
-// the database.
+// the database. The schema of a read-only database
+// is kept only in memory.
 func (db *DB) putSchema(s schema) (err error) {
+	if db.readOnly {
+		db.roSchema = &s
+		return nil
+	}
 	b, err := json.Marshal(s)
 	if err != nil {
 		return err
@@ -0,0 +1,404 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package swapeconomics simulates the settlement of many nodes exchanging
+// retrieval traffic with the Swap accounting rules, without running nodes.
+// It estimates how thresholds, prices, the grace allowance and churn affect
+// the number and size of cheques, how often they are cashed and at which
+// gas cost, so that parameter changes can be evaluated before deploying them.
+package swapeconomics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethersphere/swarm/swap"
+)
+
+// Params are the parameters of a simulation
+type Params struct {
+	Nodes               int                  // number of nodes in the network
+	Peers               int                  // number of peers every node connects to when it joins
+	Steps               int                  // number of simulated steps
+	Step                time.Duration        // simulated time of a step
+	Requests            int                  // mean number of chunks a node retrieves in a step
+	GatewayRatio        float64              // fraction of nodes retrieving GatewayFactor times more chunks
+	GatewayFactor       int                  // traffic multiplier of gateways
+	Churn               float64              // probability of a node being replaced by a new node in a step
+	ChunkSize           uint64               // size of delivered chunks in bytes
+	RetrievePricing     swap.RetrievePricing // price of retrieve requests by proximity order
+	ChunkDeliveryPrice  uint64               // honey per delivered byte
+	PaymentThreshold    int64                // honey debt at which a cheque is sent
+	DisconnectThreshold int64                // honey credit at which requests are refused
+	GraceAllowance      int64                // honey balance within which balances decay, disabled if 0
+	GraceDecayRate      int64                // honey per second by which balances within the grace allowance decay
+	HoneyPrice          uint64               // wei per honey
+	GasPrice            uint64               // wei per gas
+	CashGas             uint64               // gas of a cheque cashing transaction
+	Seed                int64                // seed of the random traffic, topology and churn
+}
+
+// NewParams returns the parameters of a small network with the default
+// swap prices and thresholds
+func NewParams() *Params {
+	return &Params{
+		Nodes:               100,
+		Peers:               8,
+		Steps:               1440,
+		Step:                time.Minute,
+		Requests:            100,
+		GatewayRatio:        0.05,
+		GatewayFactor:       10,
+		Churn:               0.001,
+		ChunkSize:           4096,
+		RetrievePricing:     swap.DefaultRetrievePricing,
+		ChunkDeliveryPrice:  swap.ChunkDeliveryPrice,
+		PaymentThreshold:    int64(swap.DefaultPaymentThreshold),
+		DisconnectThreshold: int64(swap.DefaultDisconnectThreshold),
+		HoneyPrice:          1,
+		GasPrice:            20000000000,
+		CashGas:             50000,
+		Seed:                1,
+	}
+}
+
+// validate checks that the parameters describe a network that can be simulated
+func (p *Params) validate() error {
+	switch {
+	case p.Nodes < 2:
+		return errors.New("at least 2 nodes are needed")
+	case p.Peers < 1 || p.Peers >= p.Nodes:
+		return fmt.Errorf("peers %d must be between 1 and the number of nodes %d", p.Peers, p.Nodes)
+	case p.Steps < 1 || p.Step <= 0:
+		return errors.New("steps and step duration must be positive")
+	case p.Requests < 0 || p.GatewayFactor < 0:
+		return errors.New("requests and gateway factor must not be negative")
+	case p.GatewayRatio < 0 || p.GatewayRatio > 1 || p.Churn < 0 || p.Churn > 1:
+		return errors.New("gateway ratio and churn must be between 0 and 1")
+	case p.DisconnectThreshold <= p.PaymentThreshold || p.PaymentThreshold <= 0:
+		return fmt.Errorf("disconnect threshold %d must be higher than the positive payment threshold %d", p.DisconnectThreshold, p.PaymentThreshold)
+	case p.GraceAllowance < 0 || p.GraceDecayRate < 0 || p.GraceAllowance >= p.PaymentThreshold:
+		return fmt.Errorf("grace allowance %d must be lower than the payment threshold %d", p.GraceAllowance, p.PaymentThreshold)
+	}
+	return nil
+}
+
+// Report is the outcome of a simulation
+type Report struct {
+	Duration      time.Duration // simulated time
+	Requests      uint64        // retrieved chunks
+	Refused       uint64        // requests refused by peers over the disconnect threshold
+	Honey         uint64        // honey accounted for served chunks
+	Cheques       uint64        // cheques sent
+	ChequeHoney   uint64        // honey settled with cheques
+	ChequeValue   *big.Int      // wei value of the cheques
+	Cashes        uint64        // cheque cashing transactions
+	CashedValue   *big.Int      // wei value of cashed cheques
+	GasCost       *big.Int      // wei spent on cashing transactions
+	CashInterval  time.Duration // mean time between cashing transactions of a node
+	Forgiven      uint64        // honey decayed within the grace allowance
+	Churned       uint64        // nodes replaced by new nodes
+	LostToChurn   uint64        // honey of balances with nodes which left
+	UncashedValue *big.Int      // wei value of cheques not cashed at the end
+}
+
+// MeanCheque returns the mean honey amount of a cheque
+func (r *Report) MeanCheque() uint64 {
+	if r.Cheques == 0 {
+		return 0
+	}
+	return r.ChequeHoney / r.Cheques
+}
+
+// GasShare returns the gas cost relative to the value of the cashed cheques
+func (r *Report) GasShare() float64 {
+	if r.CashedValue.Sign() == 0 {
+		return 0
+	}
+	share, _ := new(big.Rat).SetFrac(r.GasCost, r.CashedValue).Float64()
+	return share
+}
+
+// String formats the report as a table
+func (r *Report) String() string {
+	var b strings.Builder
+	row := func(name string, value interface{}) {
+		fmt.Fprintf(&b, "%-24s %v\n", name, value)
+	}
+	row("simulated time", r.Duration)
+	row("requests", r.Requests)
+	row("refused requests", r.Refused)
+	row("accounted honey", r.Honey)
+	row("cheques", r.Cheques)
+	row("mean cheque honey", r.MeanCheque())
+	row("cheque value (wei)", r.ChequeValue)
+	row("cashing transactions", r.Cashes)
+	row("cashed value (wei)", r.CashedValue)
+	row("gas cost (wei)", r.GasCost)
+	row("gas cost share", fmt.Sprintf("%.4f", r.GasShare()))
+	row("mean cash interval", r.CashInterval)
+	row("forgiven honey", r.Forgiven)
+	row("churned nodes", r.Churned)
+	row("honey lost to churn", r.LostToChurn)
+	row("uncashed value (wei)", r.UncashedValue)
+	return b.String()
+}
+
+// edge is a connection between nodes a < b
+type edge struct {
+	a, b int
+}
+
+func newEdge(a, b int) edge {
+	if a > b {
+		a, b = b, a
+	}
+	return edge{a, b}
+}
+
+// simulation is the state of a running simulation
+type simulation struct {
+	*Params
+	rnd       *rand.Rand
+	report    *Report
+	gateway   []bool
+	peers     []map[int]struct{}
+	balances  map[edge]int64 // balance of a with b, positive if b owes a
+	uncashed  []map[int]*big.Int
+	lastCash  []time.Duration // time of the last cashing transaction of a node, -1 if none
+	intervals []time.Duration
+	now       time.Duration
+	cashCost  *big.Int
+}
+
+// Run simulates the network and returns the report
+func Run(params *Params) (*Report, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	s := &simulation{
+		Params: params,
+		rnd:    rand.New(rand.NewSource(params.Seed)),
+		report: &Report{
+			ChequeValue:   new(big.Int),
+			CashedValue:   new(big.Int),
+			GasCost:       new(big.Int),
+			UncashedValue: new(big.Int),
+		},
+		gateway:  make([]bool, params.Nodes),
+		peers:    make([]map[int]struct{}, params.Nodes),
+		balances: make(map[edge]int64),
+		uncashed: make([]map[int]*big.Int, params.Nodes),
+		lastCash: make([]time.Duration, params.Nodes),
+		cashCost: new(big.Int).Mul(new(big.Int).SetUint64(params.GasPrice), new(big.Int).SetUint64(params.CashGas)),
+	}
+	for n := 0; n < s.Nodes; n++ {
+		s.peers[n] = make(map[int]struct{})
+	}
+	for n := 0; n < s.Nodes; n++ {
+		s.join(n)
+	}
+	for step := 0; step < s.Steps; step++ {
+		s.now += s.Step
+		for n := 0; n < s.Nodes; n++ {
+			s.retrieve(n)
+		}
+		s.decay()
+		s.churn()
+	}
+	s.report.Duration = s.now
+	for _, u := range s.uncashed {
+		for _, v := range u {
+			s.report.UncashedValue.Add(s.report.UncashedValue, v)
+		}
+	}
+	if len(s.intervals) > 0 {
+		var total time.Duration
+		for _, i := range s.intervals {
+			total += i
+		}
+		s.report.CashInterval = total / time.Duration(len(s.intervals))
+	}
+	return s.report, nil
+}
+
+// join connects a new node at index n to random peers
+func (s *simulation) join(n int) {
+	s.gateway[n] = s.rnd.Float64() < s.GatewayRatio
+	s.uncashed[n] = make(map[int]*big.Int)
+	s.lastCash[n] = -1
+	for len(s.peers[n]) < s.Peers {
+		p := s.rnd.Intn(s.Nodes)
+		if p == n {
+			continue
+		}
+		s.peers[n][p] = struct{}{}
+		s.peers[p][n] = struct{}{}
+	}
+}
+
+// churn replaces nodes by new nodes, the balances with them are lost
+// and the cheques they did not cash are forfeited
+func (s *simulation) churn() {
+	for n := 0; n < s.Nodes; n++ {
+		if s.Churn == 0 || s.rnd.Float64() >= s.Churn {
+			continue
+		}
+		for p := range s.peers[n] {
+			e := newEdge(n, p)
+			balance := s.balances[e]
+			if balance < 0 {
+				balance = -balance
+			}
+			s.report.LostToChurn += uint64(balance)
+			delete(s.balances, e)
+			delete(s.peers[p], n)
+		}
+		s.peers[n] = make(map[int]struct{})
+		s.report.Churned++
+		s.join(n)
+	}
+}
+
+// retrieve has node n retrieve chunks from random peers
+func (s *simulation) retrieve(n int) {
+	if len(s.peers[n]) == 0 || s.Requests == 0 {
+		return
+	}
+	requests := s.rnd.Intn(2*s.Requests + 1)
+	if s.gateway[n] {
+		requests *= s.GatewayFactor
+	}
+	peers := make([]int, 0, len(s.peers[n]))
+	for p := range s.peers[n] {
+		peers = append(peers, p)
+	}
+	// map iteration is random, sort to be deterministic for the seed
+	sort.Ints(peers)
+	for i := 0; i < requests; i++ {
+		s.serve(peers[s.rnd.Intn(len(peers))], n)
+	}
+}
+
+// price returns the honey price of retrieving a chunk from a node at a
+// random proximity order, which is i with probability 2^-(i+1)
+func (s *simulation) price() int64 {
+	po := bits.LeadingZeros32(s.rnd.Uint32())
+	// like swap, deliveries of less than 512 bytes are charged as 512 bytes
+	size := s.ChunkSize
+	if size < 512 {
+		size = 512
+	}
+	delivery := s.ChunkDeliveryPrice * size
+	price := s.RetrievePricing.Price(po) + delivery
+	if price > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(price)
+}
+
+// balance returns the balance of node a with node b, positive if b owes a
+func (s *simulation) balance(a, b int) int64 {
+	balance := s.balances[newEdge(a, b)]
+	if a > b {
+		return -balance
+	}
+	return balance
+}
+
+func (s *simulation) setBalance(a, b int, balance int64) {
+	if a > b {
+		balance = -balance
+	}
+	s.balances[newEdge(a, b)] = balance
+}
+
+// serve has server deliver a chunk to client, which pays with a cheque
+// when its debt reaches the payment threshold
+func (s *simulation) serve(server, client int) {
+	s.report.Requests++
+	price := s.price()
+	credit := s.balance(server, client)
+	if credit >= s.DisconnectThreshold || credit > math.MaxInt64-price {
+		s.report.Refused++
+		return
+	}
+	credit += price
+	s.report.Honey += uint64(price)
+	if credit < s.PaymentThreshold {
+		s.setBalance(server, client, credit)
+		return
+	}
+	// the client sends a cheque over its whole debt
+	s.setBalance(server, client, 0)
+	s.report.Cheques++
+	s.report.ChequeHoney += uint64(credit)
+	value := new(big.Int).Mul(big.NewInt(credit), new(big.Int).SetUint64(s.HoneyPrice))
+	s.report.ChequeValue.Add(s.report.ChequeValue, value)
+
+	uncashed, ok := s.uncashed[server][client]
+	if !ok {
+		uncashed = new(big.Int)
+		s.uncashed[server][client] = uncashed
+	}
+	uncashed.Add(uncashed, value)
+	// like swap, cheques are cashed once they are worth twice the transaction cost
+	if uncashed.Cmp(new(big.Int).Lsh(s.cashCost, 1)) > 0 {
+		s.report.Cashes++
+		s.report.CashedValue.Add(s.report.CashedValue, uncashed)
+		s.report.GasCost.Add(s.report.GasCost, s.cashCost)
+		delete(s.uncashed[server], client)
+		if s.lastCash[server] >= 0 {
+			s.intervals = append(s.intervals, s.now-s.lastCash[server])
+		}
+		s.lastCash[server] = s.now
+	}
+}
+
+// decay moves balances within the grace allowance towards zero
+func (s *simulation) decay() {
+	if s.GraceAllowance == 0 || s.GraceDecayRate == 0 {
+		return
+	}
+	decay := int64(float64(s.GraceDecayRate) * s.Step.Seconds())
+	for e, balance := range s.balances {
+		if balance > s.GraceAllowance || balance < -s.GraceAllowance || balance == 0 {
+			continue
+		}
+		switch {
+		case balance > decay:
+			balance -= decay
+			s.report.Forgiven += uint64(decay)
+		case balance < -decay:
+			balance += decay
+			s.report.Forgiven += uint64(decay)
+		default:
+			if balance < 0 {
+				balance = -balance
+			}
+			s.report.Forgiven += uint64(balance)
+			balance = 0
+		}
+		s.balances[e] = balance
+	}
+}
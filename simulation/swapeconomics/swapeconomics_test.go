@@ -0,0 +1,116 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swapeconomics
+
+import (
+	"math/big"
+	"testing"
+	"time"
+)
+
+func testParams() *Params {
+	params := NewParams()
+	params.Nodes = 20
+	params.Peers = 4
+	params.Steps = 200
+	params.Requests = 50
+	params.HoneyPrice = 100
+	return params
+}
+
+// TestRun checks that the simulation settles the accounted honey with cheques
+// and that it is deterministic for a seed
+func TestRun(t *testing.T) {
+	params := testParams()
+	report, err := Run(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Duration != time.Duration(params.Steps)*params.Step {
+		t.Fatalf("expected simulated time %v, got %v", time.Duration(params.Steps)*params.Step, report.Duration)
+	}
+	if report.Requests == 0 || report.Cheques == 0 || report.Cashes == 0 {
+		t.Fatalf("expected requests, cheques and cashes, got %v", report)
+	}
+	if report.ChequeHoney > report.Honey {
+		t.Fatalf("cheques of %d honey exceed the accounted %d honey", report.ChequeHoney, report.Honey)
+	}
+	if report.MeanCheque() < uint64(params.PaymentThreshold) {
+		t.Fatalf("mean cheque %d is lower than the payment threshold %d", report.MeanCheque(), params.PaymentThreshold)
+	}
+	cashed := new(big.Int).Add(report.CashedValue, report.UncashedValue)
+	if cashed.Cmp(report.ChequeValue) > 0 {
+		t.Fatalf("cashed and uncashed value %v exceeds the cheque value %v", cashed, report.ChequeValue)
+	}
+	expectedGas := new(big.Int).SetUint64(report.Cashes * params.GasPrice * params.CashGas)
+	if report.GasCost.Cmp(expectedGas) != 0 {
+		t.Fatalf("expected gas cost %v, got %v", expectedGas, report.GasCost)
+	}
+
+	again, err := Run(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != report.String() {
+		t.Fatalf("expected the same report for the same seed, got\n%v\nand\n%v", report, again)
+	}
+}
+
+// TestRunParams checks the effect of the thresholds, the grace allowance and churn
+func TestRunParams(t *testing.T) {
+	base, err := Run(testParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := testParams()
+	params.PaymentThreshold *= 4
+	higher, err := Run(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if higher.Cheques >= base.Cheques {
+		t.Fatalf("expected fewer than %d cheques with a higher payment threshold, got %d", base.Cheques, higher.Cheques)
+	}
+
+	params = testParams()
+	params.GraceAllowance = params.PaymentThreshold / 2
+	params.GraceDecayRate = params.PaymentThreshold
+	grace, err := Run(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grace.Forgiven == 0 || grace.Cheques >= base.Cheques {
+		t.Fatalf("expected forgiven honey and fewer than %d cheques with a grace allowance, got %d forgiven and %d cheques", base.Cheques, grace.Forgiven, grace.Cheques)
+	}
+
+	params = testParams()
+	params.Churn = 0.05
+	churn, err := Run(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if churn.Churned == 0 || churn.LostToChurn == 0 {
+		t.Fatalf("expected churned nodes and lost honey, got %d nodes and %d honey", churn.Churned, churn.LostToChurn)
+	}
+
+	params = testParams()
+	params.DisconnectThreshold = params.PaymentThreshold
+	if _, err := Run(params); err == nil {
+		t.Fatal("expected an error for a disconnect threshold not higher than the payment threshold")
+	}
+}
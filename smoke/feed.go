@@ -0,0 +1,242 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package smoke
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethersphere/swarm/storage/feed"
+	"github.com/pborman/uuid"
+)
+
+const (
+	feedRandomDataLength = 8
+	// feedPrivateKeyHex is the key the feed updates are signed with
+	feedPrivateKeyHex = "0000000000000000000000000000000000000000000000000000000000001976"
+)
+
+// FeedUploadAndSync creates feeds with a random topic, subtopic and their
+// combination on the first host using the swarm binary, updates them with
+// random data and then with the hash of the uploaded file, and checks that
+// all updates can be retrieved from every host in the cluster.
+func (c *Cluster) FeedUploadAndSync(ctx context.Context, file []byte, swarmBinary string) error {
+	if len(c.Hosts) == 0 {
+		return errors.New("no hosts")
+	}
+	endpoint := c.HTTPEndpoint(c.Hosts[0])
+	log.Info("generating and uploading feeds to " + endpoint + " and syncing")
+
+	// create a random private key to sign updates with and derive the address
+	pkFile, err := ioutil.TempFile("", "swarm-feed-smoke-test")
+	if err != nil {
+		return err
+	}
+	defer pkFile.Close()
+	defer os.Remove(pkFile.Name())
+
+	privKey, err := crypto.HexToECDSA(feedPrivateKeyHex)
+	if err != nil {
+		return err
+	}
+	user := crypto.PubkeyToAddress(privKey.PublicKey)
+	userHex := hexutil.Encode(user.Bytes())
+
+	// save the private key to a file
+	_, err = io.WriteString(pkFile, feedPrivateKeyHex)
+	if err != nil {
+		return err
+	}
+
+	// generate random topic and subtopic and put a hex on them
+	topicBytes, err := GenerateRandomData(feed.TopicLength)
+	if err != nil {
+		return err
+	}
+	topicHex := hexutil.Encode(topicBytes)
+	subTopicBytes, err := GenerateRandomData(8)
+	if err != nil {
+		return err
+	}
+	subTopicHex := hexutil.Encode(subTopicBytes)
+
+	// and create combination hex topics for bzz-feed retrieval
+	// xor'ed with topic (zero-value topic if no topic)
+	mergedSubTopic, err := feed.NewTopic(subTopicHex, topicBytes)
+	if err != nil {
+		return err
+	}
+	mergedSubTopicHex := hexutil.Encode(mergedSubTopic[:])
+	subTopicOnlyBytes, err := feed.NewTopic(subTopicHex, nil)
+	if err != nil {
+		return err
+	}
+	subTopicOnlyHex := hexutil.Encode(subTopicOnlyBytes[:])
+
+	// feed selectors for topic only, subtopic only and merged topic
+	selectors := [][]string{
+		{"--topic", topicHex},
+		{"--name", subTopicHex},
+		{"--topic", topicHex, "--name", subTopicHex},
+	}
+
+	// create feed manifests
+	var manifests []string
+	for _, selector := range selectors {
+		args := append([]string{"--bzzapi", endpoint, "feed", "create"}, selector...)
+		out, err := runSwarm(swarmBinary, append(args, "--user", userHex)...)
+		if err != nil {
+			return err
+		}
+		manifest := strings.TrimRight(out, string([]byte{0x0a}))
+		if len(manifest) != 64 {
+			return fmt.Errorf("unknown feed create manifest hash format (%s): (%d) %s", strings.Join(selector, " "), len(out), manifest)
+		}
+		log.Debug("create feed", "selector", selector, "manifest", manifest)
+		manifests = append(manifests, manifest)
+	}
+
+	update := func(data string) error {
+		for _, selector := range selectors {
+			args := append([]string{"--bzzaccount", pkFile.Name(), "--bzzapi", endpoint, "feed", "update"}, selector...)
+			out, err := runSwarm(swarmBinary, append(args, data)...)
+			if err != nil {
+				return err
+			}
+			log.Debug("feed update", "selector", selector, "out", out)
+		}
+		return nil
+	}
+
+	// create test data
+	data, err := GenerateRandomData(feedRandomDataLength)
+	if err != nil {
+		return err
+	}
+	h := md5.New()
+	h.Write(data)
+	dataHash := h.Sum(nil)
+
+	if err := update(hexutil.Encode(data)); err != nil {
+		return err
+	}
+
+	select {
+	case <-time.After(3 * time.Second):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	// retrieve the data
+	err = c.retryOnAllHosts(ctx, []string{topicHex, subTopicOnlyHex, mergedSubTopicHex}, func(topic string, endpoint string, ruid string) error {
+		return c.FetchFeed(ctx, topic, userHex, endpoint, dataHash, ruid)
+	})
+	if err != nil {
+		return err
+	}
+	log.Info("all endpoints synced random data successfully")
+
+	// upload test file
+	log.Info("feed uploading to "+endpoint+" and syncing", "size", len(file))
+
+	hash, err := Upload(file, endpoint)
+	if err != nil {
+		return err
+	}
+	hashBytes, err := hexutil.Decode("0x" + hash)
+	if err != nil {
+		return err
+	}
+	fileHash, err := Digest(bytes.NewReader(file))
+	if err != nil {
+		return err
+	}
+
+	log.Info("uploaded successfully", "hash", hash, "digest", fmt.Sprintf("%x", fileHash))
+
+	if err := update(hexutil.Encode(hashBytes)); err != nil {
+		return err
+	}
+
+	select {
+	case <-time.After(3 * time.Second):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	// retrieve the file through the feed manifests
+	err = c.retryOnAllHosts(ctx, manifests, func(manifest string, endpoint string, ruid string) error {
+		return c.Fetch(ctx, manifest, endpoint, fileHash, ruid)
+	})
+	if err != nil {
+		return err
+	}
+	log.Info("all endpoints synced random file successfully")
+
+	return nil
+}
+
+// retryOnAllHosts calls fetch for every target on every host in parallel,
+// retrying until all calls succeed or the context is done.
+func (c *Cluster) retryOnAllHosts(ctx context.Context, targets []string, fetch func(target string, endpoint string, ruid string) error) error {
+	var wg sync.WaitGroup
+	for _, host := range c.Hosts {
+		for _, target := range targets {
+			wg.Add(1)
+			go func(target string, endpoint string, ruid string) {
+				defer wg.Done()
+				for {
+					if fetch(target, endpoint, ruid) == nil {
+						return
+					}
+					select {
+					case <-ctx.Done():
+						return
+					default:
+					}
+				}
+			}(target, c.HTTPEndpoint(host), uuid.New()[:8])
+		}
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// runSwarm executes the swarm binary with the arguments and returns its output
+func runSwarm(binary string, args ...string) (string, error) {
+	var out bytes.Buffer
+	cmd := exec.Command(binary, args...)
+	cmd.Stdout = &out
+	log.Debug("swarm cmd", "cmd", cmd)
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
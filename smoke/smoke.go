@@ -0,0 +1,229 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package smoke implements end-to-end health verifications of a cluster of
+// swarm nodes: content is uploaded to one node and retrieved from the others,
+// chunk placement is checked against the expected neighbourhoods and feed
+// updates are verified on every node.
+//
+// The checks return errors instead of exiting and report timings both in
+// their results and as metrics, so they can be run programmatically by the
+// swarm-smoke command, CI pipelines or operators of other clusters.
+package smoke
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	crand "crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethersphere/swarm/api"
+	"github.com/ethersphere/swarm/api/client"
+	"github.com/ethersphere/swarm/spancontext"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/pborman/uuid"
+)
+
+// Cluster is a set of swarm nodes that smoke tests are run against.
+// Content is uploaded to the first host and retrieved from the others.
+type Cluster struct {
+	Hosts    []string // host names or IP addresses of the nodes
+	HTTPPort int      // port of the HTTP API on every host
+	WSPort   int      // port of the websocket RPC API on every host
+	// Name is used to name tracing spans and HTTP client metrics,
+	// "smoke" is used if it is empty
+	Name string
+}
+
+// HTTPEndpoint returns the HTTP API endpoint of a host.
+func (c *Cluster) HTTPEndpoint(host string) string {
+	return fmt.Sprintf("http://%s:%d", host, c.HTTPPort)
+}
+
+// WSEndpoint returns the websocket RPC endpoint of a host.
+func (c *Cluster) WSEndpoint(host string) string {
+	return fmt.Sprintf("ws://%s:%d", host, c.WSPort)
+}
+
+func (c *Cluster) name() string {
+	if c.Name == "" {
+		return "smoke"
+	}
+	return c.Name
+}
+
+// Fetch gets the requested hash from the endpoint and compares
+// its md5 digest with the digest of the original content.
+func (c *Cluster) Fetch(ctx context.Context, hash string, endpoint string, original []byte, ruid string) error {
+	ctx, sp := spancontext.StartSpan(ctx, c.name()+".fetch")
+	defer sp.Finish()
+
+	log.Info("http get request", "ruid", ruid, "endpoint", endpoint, "hash", hash)
+
+	var tn time.Time
+	reqUri := endpoint + "/bzz:/" + hash + "/"
+	req, _ := http.NewRequest("GET", reqUri, nil)
+
+	opentracing.GlobalTracer().Inject(
+		sp.Context(),
+		opentracing.HTTPHeaders,
+		opentracing.HTTPHeadersCarrier(req.Header))
+
+	trace := client.GetClientTrace(c.name()+" - http get", c.name(), ruid, &tn)
+
+	req = req.WithContext(httptrace.WithClientTrace(ctx, trace))
+	transport := http.DefaultTransport
+
+	tn = time.Now()
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		log.Error(err.Error(), "ruid", ruid)
+		return err
+	}
+	log.Info("http get response", "ruid", ruid, "endpoint", endpoint, "hash", hash, "code", res.StatusCode, "len", res.ContentLength)
+
+	if res.StatusCode != 200 {
+		err := fmt.Errorf("expected status code %d, got %v", 200, res.StatusCode)
+		log.Warn(err.Error(), "ruid", ruid)
+		return err
+	}
+
+	defer res.Body.Close()
+
+	rdigest, err := Digest(res.Body)
+	if err != nil {
+		log.Warn(err.Error(), "ruid", ruid)
+		return err
+	}
+
+	if !bytes.Equal(rdigest, original) {
+		err := fmt.Errorf("downloaded imported file md5=%x is not the same as the generated one=%x", rdigest, original)
+		log.Warn(err.Error(), "ruid", ruid)
+		return err
+	}
+
+	log.Trace("downloaded file matches random file", "ruid", ruid, "len", res.ContentLength)
+
+	return nil
+}
+
+// FetchFeed gets the latest update of a feed from the endpoint and compares
+// its md5 digest with the digest of the original content.
+func (c *Cluster) FetchFeed(ctx context.Context, topic string, user string, endpoint string, original []byte, ruid string) error {
+	ctx, sp := spancontext.StartSpan(ctx, c.name()+".fetch-feed")
+	defer sp.Finish()
+
+	log.Trace("http get request (feed)", "ruid", ruid, "api", endpoint, "topic", topic, "user", user)
+
+	var tn time.Time
+	reqUri := endpoint + "/bzz-feed:/?topic=" + topic + "&user=" + user
+	req, _ := http.NewRequest("GET", reqUri, nil)
+
+	opentracing.GlobalTracer().Inject(
+		sp.Context(),
+		opentracing.HTTPHeaders,
+		opentracing.HTTPHeadersCarrier(req.Header))
+
+	trace := client.GetClientTrace(c.name()+" - http get", c.name(), ruid, &tn)
+
+	req = req.WithContext(httptrace.WithClientTrace(ctx, trace))
+	transport := http.DefaultTransport
+
+	tn = time.Now()
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		log.Error(err.Error(), "ruid", ruid)
+		return err
+	}
+
+	log.Trace("http get response (feed)", "ruid", ruid, "api", endpoint, "topic", topic, "user", user, "code", res.StatusCode, "len", res.ContentLength)
+
+	if res.StatusCode != 200 {
+		return fmt.Errorf("expected status code %d, got %v (ruid %v)", 200, res.StatusCode, ruid)
+	}
+
+	defer res.Body.Close()
+
+	rdigest, err := Digest(res.Body)
+	if err != nil {
+		log.Warn(err.Error(), "ruid", ruid)
+		return err
+	}
+
+	if !bytes.Equal(rdigest, original) {
+		err := fmt.Errorf("downloaded imported file md5=%x is not the same as the generated one=%x", rdigest, original)
+		log.Warn(err.Error(), "ruid", ruid)
+		return err
+	}
+
+	log.Trace("downloaded file matches random file", "ruid", ruid, "len", res.ContentLength)
+
+	return nil
+}
+
+// Upload uploads arbitrary bytes as a plaintext file to the endpoint
+// using the api client and returns the swarm hash of the manifest.
+func Upload(data []byte, endpoint string) (string, error) {
+	return UploadWithTag(data, endpoint, uuid.New()[:8])
+}
+
+// UploadWithTag uploads arbitrary bytes as a plaintext file to the endpoint
+// using the api client with a given tag name.
+func UploadWithTag(data []byte, endpoint string, tag string) (string, error) {
+	swarm := client.NewClient(endpoint)
+	f := &client.File{
+		ReadCloser: ioutil.NopCloser(bytes.NewReader(data)),
+		ManifestEntry: api.ManifestEntry{
+			ContentType: "text/plain",
+			Mode:        0660,
+			Size:        int64(len(data)),
+		},
+		Tag: tag,
+	}
+
+	return swarm.TarUpload("", &client.FileUploader{File: f}, "", false, false, true)
+}
+
+// Digest returns the md5 digest of the content read from r.
+func Digest(r io.Reader) ([]byte, error) {
+	h := md5.New()
+	_, err := io.Copy(h, r)
+	if err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// GenerateRandomData returns size bytes read from a cryptographically
+// secure random source.
+func GenerateRandomData(size int) ([]byte, error) {
+	b := make([]byte, size)
+	c, err := crand.Read(b)
+	if err != nil {
+		return nil, err
+	} else if c != size {
+		return nil, errors.New("short read")
+	}
+	return b, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package smoke
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethersphere/swarm/storage"
+)
+
+// TestFetch checks that Fetch only succeeds if the retrieved content
+// matches the digest of the original data
+func TestFetch(t *testing.T) {
+	data := []byte("smoke test content")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bzz:/abcd/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	digest, err := Digest(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Cluster{}
+	if err := c.Fetch(context.Background(), "abcd", srv.URL, digest, "test"); err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if err := c.Fetch(context.Background(), "abcd", srv.URL, []byte("other"), "test"); err == nil {
+		t.Fatal("expected digest mismatch error")
+	}
+	if err := c.Fetch(context.Background(), "dcba", srv.URL, digest, "test"); err == nil {
+		t.Fatal("expected status code error")
+	}
+}
+
+// TestCheckChunksVsMostProxHosts validates chunk placement checks
+// for pull and push sync modes
+func TestCheckChunksVsMostProxHosts(t *testing.T) {
+	addr := make([]byte, 32)
+	addrs := []storage.Address{addr}
+
+	near := make([]byte, 32)
+	far := make([]byte, 32)
+	far[0] = 0x80
+	bzzAddrs := map[string]string{
+		"near": hex.EncodeToString(near),
+		"far":  hex.EncodeToString(far),
+	}
+
+	for _, tc := range []struct {
+		name     string
+		chunks   map[string]string
+		syncMode string
+		ok       bool
+	}{
+		{"pull on both", map[string]string{"near": "1", "far": "1"}, SyncModePull, true},
+		{"pull only near", map[string]string{"near": "1", "far": "0"}, SyncModePull, false},
+		{"pull only far", map[string]string{"near": "0", "far": "1"}, SyncModePull, false},
+		{"push only near", map[string]string{"near": "1", "far": "0"}, SyncModePush, true},
+		{"push only far", map[string]string{"near": "0", "far": "1"}, SyncModePush, false},
+		{"both only near", map[string]string{"near": "1", "far": "0"}, SyncModeBoth, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkChunksVsMostProxHosts(addrs, tc.chunks, bzzAddrs, tc.syncMode)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tc.ok && err != errChunkPlacement {
+				t.Fatalf("got error %v, want %v", err, errChunkPlacement)
+			}
+		})
+	}
+
+	if err := checkChunksVsMostProxHosts(addrs, map[string]string{"bad": "1"}, map[string]string{"bad": "zz"}, SyncModePull); err == nil {
+		t.Fatal("expected invalid bzz address error")
+	}
+}
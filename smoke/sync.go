@@ -0,0 +1,353 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package smoke
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/client"
+	"github.com/ethersphere/swarm/storage"
+	"golang.org/x/sync/errgroup"
+)
+
+// Sync modes the cluster is expected to run with,
+// they determine which chunk placement checks are done.
+const (
+	SyncModePull = "pullsync"
+	SyncModePush = "pushsync"
+	SyncModeBoth = "both"
+)
+
+// errChunkPlacement is returned when chunks are not found on the expected hosts
+var errChunkPlacement = errors.New("error in checkChunksVsMostProxHost. see smoke test output for more details")
+
+// WaitPushSynced blocks until the tag on the host reports all chunks as
+// push synced or the context is done.
+func (c *Cluster) WaitPushSynced(ctx context.Context, host string, tagname string) error {
+	defer metrics.GetOrRegisterResettingTimer("upload-and-sync.wait-to-push-sync", nil).UpdateSince(time.Now())
+
+	for {
+		select {
+		case <-time.After(200 * time.Millisecond):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if func() (synced bool) {
+			rpcClient, err := rpc.DialContext(ctx, c.WSEndpoint(host))
+			if rpcClient != nil {
+				defer rpcClient.Close()
+			}
+			if err != nil {
+				log.Error("error dialing host", "err", err)
+				return false
+			}
+
+			bzzClient := client.NewBzz(rpcClient)
+
+			synced, err = bzzClient.IsPushSynced(tagname)
+			if err != nil {
+				log.Error(err.Error())
+				return false
+			}
+			return synced
+		}() {
+			return nil
+		}
+	}
+}
+
+// WaitPullSynced blocks until no host in the cluster reports receiving
+// chunks by pull syncing or the context is done.
+func (c *Cluster) WaitPullSynced(ctx context.Context) error {
+	t1 := time.Now()
+
+	ns := uint64(1)
+
+	for ns > 0 {
+		select {
+		case <-time.After(3 * time.Second):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		notSynced := uint64(0)
+
+		var g errgroup.Group
+		for i := 0; i < len(c.Hosts); i++ {
+			i := i
+			g.Go(func() error {
+				rpcClient, err := rpc.DialContext(ctx, c.WSEndpoint(c.Hosts[i]))
+				if rpcClient != nil {
+					defer rpcClient.Close()
+				}
+				if err != nil {
+					log.Error("error dialing host", "err", err)
+					return err
+				}
+
+				bzzClient := client.NewBzz(rpcClient)
+
+				stillSyncing, err := bzzClient.IsPullSyncing()
+				if err != nil {
+					return err
+				}
+
+				if stillSyncing {
+					atomic.AddUint64(&notSynced, 1)
+				}
+
+				return nil
+			})
+		}
+
+		// Wait for all RPC calls to complete.
+		if err := g.Wait(); err == nil {
+			ns = atomic.LoadUint64(&notSynced)
+		}
+	}
+
+	t2 := time.Since(t1)
+	metrics.GetOrRegisterResettingTimer("upload-and-sync.single.wait-for-sync.deployment", nil).Update(t2)
+	return nil
+}
+
+// TrackChunks queries all hosts for the presence of the chunks of the test data
+// and checks that they are stored by the hosts closest to them with respect
+// to the sync mode.
+func (c *Cluster) TrackChunks(testData []byte, syncMode string) error {
+	addrs, err := getAllRefs(testData)
+	if err != nil {
+		return err
+	}
+
+	for i, ref := range addrs {
+		log.Debug(fmt.Sprintf("ref %d", i), "ref", ref)
+	}
+
+	var globalYes, globalNo int
+	var globalMu sync.Mutex
+	var hasErr bool
+
+	var wg sync.WaitGroup
+	wg.Add(len(c.Hosts))
+
+	var mu sync.Mutex                    // mutex protecting the allHostsChunks and bzzAddrs maps
+	allHostChunks := map[string]string{} // host->bitvector of presence for chunks
+	bzzAddrs := map[string]string{}      // host->bzzAddr
+
+	for _, host := range c.Hosts {
+		host := host
+		go func() {
+			defer wg.Done()
+			wsHost := c.WSEndpoint(host)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+
+			rpcClient, err := rpc.DialContext(ctx, wsHost)
+			if rpcClient != nil {
+				defer rpcClient.Close()
+			}
+			if err != nil {
+				log.Error("error dialing host", "err", err, "host", wsHost)
+				globalMu.Lock()
+				hasErr = true
+				globalMu.Unlock()
+				return
+			}
+
+			bzzClient := client.NewBzz(rpcClient)
+
+			hostChunks, err := bzzClient.GetChunksBitVector(addrs)
+			if err != nil {
+				log.Error("error getting chunks bit vector from host", "err", err, "host", wsHost)
+				globalMu.Lock()
+				hasErr = true
+				globalMu.Unlock()
+				return
+			}
+
+			bzzAddr, err := bzzClient.GetBzzAddr()
+			if err != nil {
+				log.Error("error getting bzz addrs from host", "err", err, "host", wsHost)
+				globalMu.Lock()
+				hasErr = true
+				globalMu.Unlock()
+				return
+			}
+
+			mu.Lock()
+			allHostChunks[host] = hostChunks
+			bzzAddrs[host] = bzzAddr
+			mu.Unlock()
+
+			yes, no := 0, 0
+			for _, val := range hostChunks {
+				if val == '1' {
+					yes++
+				} else {
+					no++
+				}
+			}
+
+			if no == 0 {
+				log.Info("host reported to have all chunks", "host", host)
+			}
+
+			log.Debug("chunks", "chunks", hostChunks, "yes", yes, "no", no, "host", host)
+
+			globalMu.Lock()
+			globalYes += yes
+			globalNo += no
+			globalMu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	err = checkChunksVsMostProxHosts(addrs, allHostChunks, bzzAddrs, syncMode)
+	if err != nil {
+		return err
+	}
+
+	metrics.GetOrRegisterGauge("deployment.nodes", nil).Update(int64(len(c.Hosts)))
+
+	if !hasErr {
+		// remove the chunks stored on the uploader node
+		globalYes -= len(addrs)
+
+		metrics.GetOrRegisterCounter("deployment.chunks.yes", nil).Inc(int64(globalYes))
+		metrics.GetOrRegisterCounter("deployment.chunks.no", nil).Inc(int64(globalNo))
+		metrics.GetOrRegisterCounter("deployment.chunks.refs", nil).Inc(int64(len(addrs)))
+	}
+
+	return nil
+}
+
+// checkChunksVsMostProxHosts is checking:
+// 1. whether a chunk has been found at less than 2 hosts. Considering our NN size, this should not happen.
+// 2. if a chunk is not found at its closest node. This should also not happen.
+// Together with the --only-upload flag, we could run this smoke test and make sure that our syncing
+// functionality is correct (without even trying to retrieve the content).
+//
+// addrs - a slice with all uploaded chunk refs
+// allHostChunks - host->bit vector, showing what chunks are present on what hosts
+// bzzAddrs - host->bzz address, used when determining the most proximate host for a given chunk
+func checkChunksVsMostProxHosts(addrs []storage.Address, allHostChunks map[string]string, bzzAddrs map[string]string, syncMode string) error {
+	for k, v := range bzzAddrs {
+		log.Trace("bzzAddr", "bzz", v, "host", k)
+	}
+	errored := false
+
+	for i := range addrs {
+		var foundAt int
+		maxProx := -1
+		var maxProxHosts []string
+		for host := range allHostChunks {
+			if allHostChunks[host][i] == '1' {
+				foundAt++
+			}
+
+			ba, err := hex.DecodeString(bzzAddrs[host])
+			if err != nil {
+				return fmt.Errorf("invalid bzz address of host %s: %v", host, err)
+			}
+
+			// calculate the host closest to any chunk
+			prox := chunk.Proximity(addrs[i], ba)
+			if prox > maxProx {
+				maxProx = prox
+				maxProxHosts = []string{host}
+			} else if prox == maxProx {
+				maxProxHosts = append(maxProxHosts, host)
+			}
+		}
+
+		log.Trace("sync mode", "sync mode", syncMode)
+		if syncMode == SyncModePull || syncMode == SyncModeBoth {
+			for _, maxProxHost := range maxProxHosts {
+				if allHostChunks[maxProxHost][i] == '0' {
+					metrics.GetOrRegisterCounter("upload-and-sync.pull-sync.chunk-not-max-prox", nil).Inc(1)
+					log.Error("chunk not found at max prox host", "ref", addrs[i], "host", maxProxHost, "bzzAddr", bzzAddrs[maxProxHost])
+					errored = true
+				} else {
+					log.Trace("chunk present at max prox host", "ref", addrs[i], "host", maxProxHost, "bzzAddr", bzzAddrs[maxProxHost])
+				}
+			}
+
+			// if chunk found at less than 2 hosts, which is actually less that the min size of a NN
+			if foundAt < 2 {
+				metrics.GetOrRegisterCounter("upload-and-sync.pull-sync.chunk-less-nn", nil).Inc(1)
+				log.Error("chunk found at less than two hosts", "foundAt", foundAt, "ref", addrs[i])
+				errored = true
+			}
+		}
+
+		if syncMode == SyncModePush {
+			var found bool
+			for _, maxProxHost := range maxProxHosts {
+				if allHostChunks[maxProxHost][i] == '1' {
+					found = true
+					log.Trace("chunk present at max prox host", "ref", addrs[i], "host", maxProxHost, "bzzAddr", bzzAddrs[maxProxHost])
+				}
+			}
+
+			if !found {
+				for _, maxProxHost := range maxProxHosts {
+					metrics.GetOrRegisterCounter("upload-and-sync.push-sync.chunk-not-max-prox", nil).Inc(1)
+					log.Error("chunk not found at any max prox host", "ref", addrs[i], "host", maxProxHost, "bzzAddr", bzzAddrs[maxProxHost])
+					errored = true
+				}
+			}
+		}
+	}
+	if errored {
+		return errChunkPlacement
+	}
+	return nil
+}
+
+// getAllRefs returns the references of all chunks of the test data
+func getAllRefs(testData []byte) (storage.AddressCollection, error) {
+	datadir, err := ioutil.TempDir("", "chunk-debug")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(datadir)
+	fileStore, cleanup, err := storage.NewLocalFileStore(datadir, make([]byte, 32), chunk.NewTags())
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	reader := bytes.NewReader(testData)
+	return fileStore.GetAllReferences(context.Background(), reader)
+}
@@ -0,0 +1,163 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package smoke
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/pborman/uuid"
+)
+
+// maxUploadRetries is the number of upload attempts before giving up
+const maxUploadRetries = 5
+
+// UploadAndSyncOptions configure the upload and sync check.
+type UploadAndSyncOptions struct {
+	SyncMode      string // sync mode of the cluster, one of SyncModePull, SyncModePush or SyncModeBoth
+	PushSyncDelay bool   // wait for the upload tag to be push synced before fetching
+	SyncDelay     bool   // wait for all hosts to finish pull syncing before fetching
+	Debug         bool   // check the chunk placement on all hosts before fetching
+	Bail          bool   // fail the check if the chunk placement is not as expected
+	OnlyUpload    bool   // do not fetch the content after it is uploaded
+}
+
+// UploadAndSyncResult holds the outcome and timings of the upload and sync check.
+// Timings of skipped steps are zero.
+type UploadAndSyncResult struct {
+	Hash          string        // swarm hash of the uploaded content
+	Digest        []byte        // md5 digest of the uploaded content
+	FetchHost     string        // host the content was fetched from
+	UploadTime    time.Duration // time to upload the content to the first host
+	PushSyncTime  time.Duration // time until the upload tag was push synced
+	PullSyncTime  time.Duration // time until all hosts finished pull syncing
+	FetchTime     time.Duration // time of the successful fetch from another host
+	FetchAttempts int           // number of fetch attempts until the content was retrieved
+}
+
+// UploadAndSync uploads data to the first host in the cluster and
+// retrieves it from a random other host, retrying until it succeeds
+// or the context is done.
+func (c *Cluster) UploadAndSync(ctx context.Context, data []byte, o UploadAndSyncOptions) (r *UploadAndSyncResult, err error) {
+	if len(c.Hosts) == 0 {
+		return nil, errors.New("no hosts")
+	}
+	if !o.OnlyUpload && len(c.Hosts) < 2 {
+		return nil, errors.New("at least two hosts are needed to fetch the uploaded content")
+	}
+	uploadHost := c.Hosts[0]
+	log.Info("uploading to "+c.HTTPEndpoint(uploadHost)+" and syncing", "size", len(data))
+
+	r = new(UploadAndSyncResult)
+	t1 := time.Now()
+	tag := uuid.New()[:8]
+
+	for i := 0; i < maxUploadRetries; i++ {
+		r.Hash, err = UploadWithTag(data, c.HTTPEndpoint(uploadHost), tag)
+		if err != nil {
+			log.Error(err.Error())
+		} else {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r.UploadTime = time.Since(t1)
+	metrics.GetOrRegisterResettingTimer("upload-and-sync.upload-time", nil).Update(r.UploadTime)
+	uploadSpeed := float64(len(data)) / r.UploadTime.Seconds() // bytes per second
+	metrics.GetOrRegisterGauge("upload-and-sync.upload-speed", nil).Update(int64(uploadSpeed))
+
+	r.Digest, err = Digest(bytes.NewReader(data))
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("uploaded successfully", "hash", r.Hash, "took", r.UploadTime, "digest", fmt.Sprintf("%x", r.Digest))
+
+	// wait to push sync sync
+	if o.PushSyncDelay {
+		start := time.Now()
+		if err := c.WaitPushSynced(ctx, uploadHost, tag); err != nil {
+			return r, err
+		}
+		r.PushSyncTime = time.Since(start)
+
+		log.Info("push synced successfully", "hash", r.Hash)
+	}
+
+	// wait to sync and log chunks before fetch attempt, only if syncDelay is set to true
+	if o.SyncDelay {
+		start := time.Now()
+		if err := c.WaitPullSynced(ctx); err != nil {
+			return r, err
+		}
+		r.PullSyncTime = time.Since(start)
+
+		log.Info("pull synced successfully", "hash", r.Hash)
+	}
+
+	if o.Debug {
+		err = c.TrackChunks(data, o.SyncMode)
+		if err != nil {
+			log.Error(err.Error())
+			if o.Bail {
+				return r, err
+			}
+		}
+	}
+
+	if o.OnlyUpload {
+		log.Debug("only-upload is true, stoppping test", "hash", r.Hash)
+		return r, nil
+	}
+
+	r.FetchHost = c.Hosts[1+rand.Intn(len(c.Hosts)-1)]
+	endpoint := c.HTTPEndpoint(r.FetchHost)
+
+	for {
+		r.FetchAttempts++
+		start := time.Now()
+		err := c.Fetch(ctx, r.Hash, endpoint, r.Digest, "")
+		if err != nil {
+			select {
+			case <-time.After(2 * time.Second):
+			case <-ctx.Done():
+				return r, ctx.Err()
+			}
+			continue
+		}
+		r.FetchTime = time.Since(start)
+
+		metrics.GetOrRegisterResettingTimer("upload-and-sync.single.fetch-time", nil).Update(r.FetchTime)
+		downloadSpeed := float64(len(data)) / r.FetchTime.Seconds() // bytes per second
+		metrics.GetOrRegisterGauge("upload-and-sync.download-speed", nil).Update(int64(downloadSpeed))
+
+		log.Info("fetch successful", "took", r.FetchTime, "endpoint", endpoint)
+		break
+	}
+
+	return r, nil
+}
@@ -0,0 +1,391 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package standby keeps a warm standby node in step with a primary node.
+//
+// The standby dials the primary directly and, once the primary recognises it
+// as one of its configured standbys, receives every chunk of the primary's
+// pull index and its complete pin set. Progress is persisted per bin so that
+// a restarted standby resumes where it stopped instead of mirroring the whole
+// localstore again.
+package standby
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/p2p/protocols"
+	"github.com/ethersphere/swarm/state"
+	"github.com/ethersphere/swarm/storage/pin"
+)
+
+const (
+	progressKey = "standby_progress" // state store key of the last bin ids stored per bin
+	mirroredKey = "standby_pins"     // state store key of the pins set by mirroring
+)
+
+var (
+	errUnauthorized        = errors.New("peer is not a configured standby or primary")
+	errDuplicateSubscribe  = errors.New("duplicate subscribe")
+	errUnexpectedMsg       = errors.New("unexpected message")
+	errSelfPrimaryStandbys = errors.New("a standby node can not have standbys of its own")
+)
+
+// PinsInterval is how often the primary checks its pin set for changes
+var PinsInterval = time.Minute
+
+// DB is the part of the localstore used on both sides of the mirror
+type DB interface {
+	SubscribePull(ctx context.Context, bin uint8, since, until uint64) (c <-chan chunk.Descriptor, stop func())
+	Get(ctx context.Context, mode chunk.ModeGet, addr chunk.Address) (ch chunk.Chunk, err error)
+	Put(ctx context.Context, mode chunk.ModePut, chs ...chunk.Chunk) (exist []bool, err error)
+}
+
+// Pinner lists and changes the pins of a node, it is implemented by pin.API
+type Pinner interface {
+	ListPins() ([]pin.PinInfo, error)
+	PinFiles(addr []byte, isRaw bool, credentials string) error
+	UnpinFiles(addr []byte, credentials string) error
+}
+
+// Config selects the role of the node
+type Config struct {
+	Primary  *enode.Node // node mirrored by this standby, nil if this node is not a standby
+	Standbys []enode.ID  // standby nodes allowed to mirror this node
+}
+
+// NewConfig parses the primary enode URL and the enode URLs or public keys of
+// the standbys, either of which may be empty
+func NewConfig(primary string, standbys []string) (*Config, error) {
+	c := &Config{}
+	if primary != "" {
+		n, err := enode.ParseV4(primary)
+		if err != nil {
+			return nil, fmt.Errorf("invalid standby primary %q: %v", primary, err)
+		}
+		c.Primary = n
+	}
+	for _, s := range standbys {
+		n, err := enode.ParseV4(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid standby peer %q: %v", s, err)
+		}
+		c.Standbys = append(c.Standbys, n.ID())
+	}
+	return c, nil
+}
+
+// Enabled reports whether the node is a standby or has standbys
+func (c *Config) Enabled() bool {
+	return c.Primary != nil || len(c.Standbys) > 0
+}
+
+// Standby mirrors the localstore and pins of a primary node.
+// The same type serves the primary side, streaming to authorised standbys.
+type Standby struct {
+	db       DB
+	pinner   Pinner // nil if pinning is disabled
+	store    state.Store
+	primary  *enode.Node
+	standbys map[enode.ID]bool
+	server   *p2p.Server
+
+	mu       sync.Mutex // guards progress
+	progress []uint64   // last bin id stored from the primary per bin
+	pinMu    sync.Mutex // serialises pin mirroring
+
+	quit chan struct{}
+}
+
+// New constructs the standby service, loading the mirroring progress from the state store
+func New(db DB, pinner Pinner, store state.Store, config *Config) (*Standby, error) {
+	if config.Primary != nil && len(config.Standbys) > 0 {
+		return nil, errSelfPrimaryStandbys
+	}
+	s := &Standby{
+		db:       db,
+		pinner:   pinner,
+		store:    store,
+		primary:  config.Primary,
+		standbys: make(map[enode.ID]bool),
+		progress: make([]uint64, chunk.MaxPO+1),
+		quit:     make(chan struct{}),
+	}
+	for _, id := range config.Standbys {
+		s.standbys[id] = true
+	}
+	var progress []uint64
+	if err := store.Get(progressKey, &progress); err != nil && err != state.ErrNotFound {
+		return nil, err
+	}
+	copy(s.progress, progress)
+	return s, nil
+}
+
+// Protocols returns the standby protocol
+func (s *Standby) Protocols() []p2p.Protocol {
+	return []p2p.Protocol{
+		{
+			Name:    Spec.Name,
+			Version: Spec.Version,
+			Length:  Spec.Length(),
+			Run:     s.Run,
+		},
+	}
+}
+
+// Start dials the primary if this node is a standby
+func (s *Standby) Start(server *p2p.Server) error {
+	s.server = server
+	if s.primary != nil {
+		log.Info("standby mirroring primary", "primary", s.primary.ID())
+		server.AddPeer(s.primary)
+	}
+	return nil
+}
+
+// Stop terminates mirroring
+func (s *Standby) Stop() error {
+	close(s.quit)
+	if s.primary != nil && s.server != nil {
+		s.server.RemovePeer(s.primary)
+	}
+	return nil
+}
+
+// Run is the protocol run function.
+// Connections from peers that are neither the primary nor a configured standby
+// are kept for the other protocols, but any standby message from them is an error.
+func (s *Standby) Run(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+	peer := protocols.NewPeer(p, rw, Spec)
+	switch {
+	case s.primary != nil && p.ID() == s.primary.ID():
+		return s.runStandby(peer)
+	case s.standbys[p.ID()]:
+		return s.runPrimary(peer)
+	}
+	return peer.Run(func(context.Context, interface{}) error {
+		return errUnauthorized
+	})
+}
+
+// runStandby subscribes to the primary and stores everything it sends
+func (s *Standby) runStandby(peer *protocols.Peer) error {
+	s.mu.Lock()
+	since := make([]uint64, len(s.progress))
+	for i, p := range s.progress {
+		since[i] = p + 1
+	}
+	s.mu.Unlock()
+
+	if err := peer.Send(context.TODO(), &Subscribe{Since: since}); err != nil {
+		return err
+	}
+	log.Info("standby connected to primary", "peer", peer.ID())
+	return peer.Run(func(ctx context.Context, msg interface{}) error {
+		switch msg := msg.(type) {
+		case *ChunkDelivery:
+			return s.handleChunkDelivery(ctx, msg)
+		case *Pins:
+			go s.mirrorPins(msg.Pins)
+			return nil
+		}
+		return fmt.Errorf("%v: %T", errUnexpectedMsg, msg)
+	})
+}
+
+// handleChunkDelivery stores a chunk received from the primary and records the progress
+func (s *Standby) handleChunkDelivery(ctx context.Context, msg *ChunkDelivery) error {
+	if int(msg.Bin) >= len(s.progress) {
+		return fmt.Errorf("invalid bin %d", msg.Bin)
+	}
+	if _, err := s.db.Put(ctx, chunk.ModePutSync, chunk.NewChunk(msg.Addr, msg.Data)); err != nil {
+		return err
+	}
+	metrics.GetOrRegisterCounter("standby.chunks.stored", nil).Inc(1)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if msg.BinID <= s.progress[msg.Bin] {
+		return nil
+	}
+	s.progress[msg.Bin] = msg.BinID
+	return s.store.Put(progressKey, s.progress)
+}
+
+// mirrorPins pins everything pinned on the primary and unpins whatever this
+// node pinned earlier on behalf of the primary that is no longer pinned there.
+// Pins set locally on the standby are left untouched.
+func (s *Standby) mirrorPins(pins []Pin) {
+	if s.pinner == nil {
+		return
+	}
+	s.pinMu.Lock()
+	defer s.pinMu.Unlock()
+
+	mirrored := make(map[string]bool)
+	if err := s.store.Get(mirroredKey, &mirrored); err != nil && err != state.ErrNotFound {
+		log.Error("standby load mirrored pins", "err", err)
+		return
+	}
+	wanted := make(map[string]bool)
+	for _, p := range pins {
+		key := hex.EncodeToString(p.Addr)
+		wanted[key] = true
+		if mirrored[key] {
+			continue
+		}
+		if err := s.pinner.PinFiles(p.Addr, p.IsRaw, ""); err != nil {
+			log.Warn("standby pin", "addr", key, "err", err)
+			continue
+		}
+		mirrored[key] = true
+	}
+	for key := range mirrored {
+		if wanted[key] {
+			continue
+		}
+		addr, _ := hex.DecodeString(key)
+		if err := s.pinner.UnpinFiles(addr, ""); err != nil {
+			log.Warn("standby unpin", "addr", key, "err", err)
+			continue
+		}
+		delete(mirrored, key)
+	}
+	if err := s.store.Put(mirroredKey, mirrored); err != nil {
+		log.Error("standby save mirrored pins", "err", err)
+	}
+}
+
+// runPrimary serves a standby once it subscribes
+func (s *Standby) runPrimary(peer *protocols.Peer) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-s.quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var subscribed bool
+	log.Info("standby connected", "peer", peer.ID())
+	return peer.Run(func(_ context.Context, msg interface{}) error {
+		sub, ok := msg.(*Subscribe)
+		if !ok {
+			return fmt.Errorf("%v: %T", errUnexpectedMsg, msg)
+		}
+		if subscribed {
+			return errDuplicateSubscribe
+		}
+		subscribed = true
+		for bin := 0; bin <= chunk.MaxPO; bin++ {
+			var since uint64
+			if bin < len(sub.Since) {
+				since = sub.Since[bin]
+			}
+			go s.serveBin(ctx, peer, uint8(bin), since)
+		}
+		go s.servePins(ctx, peer)
+		return nil
+	})
+}
+
+// serveBin streams the chunks of one bin of the pull index, starting at since,
+// and keeps streaming newly stored chunks until the standby disconnects
+func (s *Standby) serveBin(ctx context.Context, peer *protocols.Peer, bin uint8, since uint64) {
+	c, stop := s.db.SubscribePull(ctx, bin, since, 0)
+	defer stop()
+	for {
+		select {
+		case d, ok := <-c:
+			if !ok {
+				return
+			}
+			ch, err := s.db.Get(ctx, chunk.ModeGetSync, d.Address)
+			if err != nil {
+				if err == chunk.ErrChunkNotFound {
+					// garbage collected since it was indexed
+					continue
+				}
+				log.Error("standby get chunk", "addr", d.Address, "err", err)
+				return
+			}
+			err = peer.Send(ctx, &ChunkDelivery{
+				Bin:   bin,
+				BinID: d.BinID,
+				Addr:  ch.Address(),
+				Data:  ch.Data(),
+			})
+			if err != nil {
+				log.Debug("standby send chunk", "peer", peer.ID(), "err", err)
+				return
+			}
+			metrics.GetOrRegisterCounter("standby.chunks.sent", nil).Inc(1)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// servePins sends the pin set to the standby on subscription and whenever it changes
+func (s *Standby) servePins(ctx context.Context, peer *protocols.Peer) {
+	if s.pinner == nil {
+		return
+	}
+	ticker := time.NewTicker(PinsInterval)
+	defer ticker.Stop()
+
+	var last string
+	for {
+		infos, err := s.pinner.ListPins()
+		if err != nil {
+			log.Error("standby list pins", "err", err)
+		} else {
+			pins := make([]Pin, len(infos))
+			keys := make([]string, len(infos))
+			for i, info := range infos {
+				pins[i] = Pin{Addr: info.Address, IsRaw: info.IsRaw}
+				keys[i] = fmt.Sprintf("%x:%t", info.Address, info.IsRaw)
+			}
+			sort.Strings(keys)
+			if key := strings.Join(keys, ","); key != last {
+				if err := peer.Send(ctx, &Pins{Pins: pins}); err != nil {
+					log.Debug("standby send pins", "peer", peer.ID(), "err", err)
+					return
+				}
+				last = key
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
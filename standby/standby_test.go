@@ -0,0 +1,239 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package standby
+
+import (
+	"context"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/chunk"
+	chunktesting "github.com/ethersphere/swarm/chunk/testing"
+	"github.com/ethersphere/swarm/state"
+	"github.com/ethersphere/swarm/storage/localstore"
+	"github.com/ethersphere/swarm/storage/pin"
+)
+
+// TestMirror checks that the standby receives the existing and newly stored
+// chunks of the primary as well as its pins, and persists its progress
+func TestMirror(t *testing.T) {
+	defer func(d time.Duration) { PinsInterval = d }(PinsInterval)
+	PinsInterval = 10 * time.Millisecond
+
+	primaryDB, cleanup := newTestDB(t)
+	defer cleanup()
+	standbyDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	existing := chunktesting.GenerateTestRandomChunks(50)
+	if _, err := primaryDB.Put(ctx, chunk.ModePutUpload, existing...); err != nil {
+		t.Fatal(err)
+	}
+
+	primaryKey, _ := crypto.GenerateKey()
+	standbyKey, _ := crypto.GenerateKey()
+	primaryNode := enode.NewV4(&primaryKey.PublicKey, nil, 0, 0)
+	standbyNode := enode.NewV4(&standbyKey.PublicKey, nil, 0, 0)
+
+	primaryPins := newTestPinner([]byte{1}, []byte{2})
+	standbyPins := newTestPinner([]byte{3}) // pinned locally on the standby
+	standbyStore := state.NewInmemoryStore()
+
+	primary, err := New(primaryDB, primaryPins, state.NewInmemoryStore(), &Config{Standbys: []enode.ID{standbyNode.ID()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer primary.Stop()
+	sb, err := New(standbyDB, standbyPins, standbyStore, &Config{Primary: primaryNode})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sb.Stop()
+
+	disconnect := connect(primary, primaryNode, sb, standbyNode)
+	defer disconnect()
+
+	waitFor(t, func() bool { return hasAll(standbyDB, existing) })
+
+	// chunks stored on the primary after subscription are mirrored as well
+	live := chunktesting.GenerateTestRandomChunks(20)
+	if _, err := primaryDB.Put(ctx, chunk.ModePutUpload, live...); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return hasAll(standbyDB, live) })
+
+	waitFor(t, func() bool { return standbyPins.has([]byte{1}, []byte{2}, []byte{3}) })
+
+	// unpinned on the primary, only pins set by mirroring are removed
+	primaryPins.UnpinFiles([]byte{1}, "")
+	waitFor(t, func() bool { return standbyPins.has([]byte{2}, []byte{3}) })
+
+	var progress []uint64
+	if err := standbyStore.Get(progressKey, &progress); err != nil {
+		t.Fatal(err)
+	}
+	var stored uint64
+	for _, p := range progress {
+		stored += p
+	}
+	if want := uint64(len(existing) + len(live)); stored != want {
+		t.Fatalf("expected progress to cover %d chunks, got %d", want, stored)
+	}
+}
+
+// TestUnauthorized checks that the primary refuses to serve unknown peers
+func TestUnauthorized(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	standbyKey, _ := crypto.GenerateKey()
+	otherKey, _ := crypto.GenerateKey()
+	standbyID := enode.PubkeyToIDV4(&standbyKey.PublicKey)
+	primary, err := New(db, nil, state.NewInmemoryStore(), &Config{Standbys: []enode.ID{standbyID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer primary.Stop()
+
+	rw, remote := p2p.MsgPipe()
+	defer rw.Close()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- primary.Run(p2p.NewPeer(enode.PubkeyToIDV4(&otherKey.PublicKey), "other", nil), rw)
+	}()
+	if err := p2p.Send(remote, 0, &Subscribe{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+}
+
+// connect runs the protocol between a primary and a standby over a message pipe
+func connect(primary *Standby, primaryNode *enode.Node, standby *Standby, standbyNode *enode.Node) (disconnect func()) {
+	primaryRW, standbyRW := p2p.MsgPipe()
+	go primary.Run(p2p.NewPeer(standbyNode.ID(), "standby", nil), primaryRW)
+	go standby.Run(p2p.NewPeer(primaryNode.ID(), "primary", nil), standbyRW)
+	return func() {
+		primaryRW.Close()
+	}
+}
+
+func newTestDB(t *testing.T) (db *localstore.DB, cleanup func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "standby")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err = localstore.New(dir, make([]byte, 32), nil)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func hasAll(db *localstore.DB, chunks []chunk.Chunk) bool {
+	for _, ch := range chunks {
+		has, err := db.Has(context.Background(), ch.Address())
+		if err != nil || !has {
+			return false
+		}
+	}
+	return true
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// testPinner is an in-memory Pinner
+type testPinner struct {
+	mu   sync.Mutex
+	pins map[string]bool
+}
+
+func newTestPinner(addrs ...[]byte) *testPinner {
+	p := &testPinner{pins: make(map[string]bool)}
+	for _, addr := range addrs {
+		p.PinFiles(addr, false, "")
+	}
+	return p
+}
+
+func (p *testPinner) ListPins() ([]pin.PinInfo, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var infos []pin.PinInfo
+	for key := range p.pins {
+		addr, _ := hex.DecodeString(key)
+		infos = append(infos, pin.PinInfo{Address: addr})
+	}
+	return infos, nil
+}
+
+func (p *testPinner) PinFiles(addr []byte, isRaw bool, credentials string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pins[hex.EncodeToString(addr)] = true
+	return nil
+}
+
+func (p *testPinner) UnpinFiles(addr []byte, credentials string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.pins, hex.EncodeToString(addr))
+	return nil
+}
+
+// has reports whether exactly the given addresses are pinned
+func (p *testPinner) has(addrs ...[]byte) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.pins) != len(addrs) {
+		return false
+	}
+	for _, addr := range addrs {
+		if !p.pins[hex.EncodeToString(addr)] {
+			return false
+		}
+	}
+	return true
+}
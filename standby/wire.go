@@ -0,0 +1,59 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package standby
+
+import (
+	"github.com/ethersphere/swarm/p2p/protocols"
+)
+
+// Spec is the protocol spec for mirroring a primary node to its standby
+var Spec = &protocols.Spec{
+	Name:       "bzz-standby",
+	Version:    1,
+	MaxMsgSize: 10 * 1024 * 1024,
+	Messages: []interface{}{
+		Subscribe{},
+		ChunkDelivery{},
+		Pins{},
+	},
+	DisableContext: true,
+}
+
+// Subscribe is sent by the standby to the primary to start mirroring.
+// Since holds, for every proximity order bin, the first bin id the standby has not stored yet.
+type Subscribe struct {
+	Since []uint64
+}
+
+// ChunkDelivery carries a chunk from the primary's pull index to the standby
+type ChunkDelivery struct {
+	Bin   uint8  // proximity order bin of the chunk on the primary
+	BinID uint64 // bin id of the chunk on the primary
+	Addr  []byte
+	Data  []byte
+}
+
+// Pin describes a single pinned file or raw chunk
+type Pin struct {
+	Addr  []byte
+	IsRaw bool
+}
+
+// Pins is the complete pin set of the primary, sent whenever it changes
+type Pins struct {
+	Pins []Pin
+}
@@ -17,6 +17,7 @@
 package state
 
 import (
+	"crypto/cipher"
 	"encoding"
 	"encoding/json"
 	"errors"
@@ -41,7 +42,8 @@ type Store interface {
 
 // DBStore uses LevelDB to store values.
 type DBStore struct {
-	db *leveldb.DB
+	db   *leveldb.DB
+	aead cipher.AEAD // encrypts values, nil if the store is not encrypted
 }
 
 // NewDBStore creates a new instance of DBStore.
@@ -50,6 +52,10 @@ func NewDBStore(path string) (s *DBStore, err error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := checkNotEncrypted(db); err != nil {
+		db.Close()
+		return nil, err
+	}
 	return &DBStore{
 		db: db,
 	}, nil
@@ -77,6 +83,10 @@ func (s *DBStore) Get(key string, i interface{}) (err error) {
 		}
 		return err
 	}
+	data, err = s.open([]byte(key), data)
+	if err != nil {
+		return err
+	}
 
 	unmarshaler, ok := i.(encoding.BinaryUnmarshaler)
 	if !ok {
@@ -97,6 +107,9 @@ func (s *DBStore) Put(key string, i interface{}) (err error) {
 			return err
 		}
 	}
+	if bytes, err = s.seal([]byte(key), bytes); err != nil {
+		return err
+	}
 	return s.db.Put([]byte(key), bytes, nil)
 }
 
@@ -117,7 +130,14 @@ func (s *DBStore) Iterate(prefix string, iterFunc iterFunction) (err error) {
 	iter := s.db.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
 	defer iter.Release()
 	for iter.Next() {
-		stop, err := iterFunc(iter.Key(), iter.Value())
+		if s.aead != nil && string(iter.Key()) == encryptionMarkerKey {
+			continue
+		}
+		value, err := s.open(iter.Key(), iter.Value())
+		if err != nil {
+			return err
+		}
+		stop, err := iterFunc(iter.Key(), value)
 		if err != nil {
 			return err
 		}
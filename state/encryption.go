@@ -0,0 +1,164 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+var (
+	// ErrInvalidEncryptionKey is returned when an encrypted store is opened with a wrong key
+	ErrInvalidEncryptionKey = errors.New("invalid state store encryption key")
+	// ErrStoreEncrypted is returned when an encrypted store is opened without a key
+	ErrStoreEncrypted = errors.New("state store is encrypted")
+)
+
+// encryptionMarkerKey holds a known value sealed with the encryption key of
+// an encrypted store, it marks the store as encrypted and verifies the key
+const encryptionMarkerKey = "__encrypted"
+
+var encryptionMarker = []byte("swarm state store")
+
+// EncryptionKey derives the key encrypting the state store with name from the
+// node account key. As the account key is unlocked with the account password,
+// the store is only readable by the account owner, but changing the password
+// of the account keeps the store readable.
+func EncryptionKey(prvkey *ecdsa.PrivateKey, name string) []byte {
+	mac := hmac.New(sha256.New, crypto.FromECDSA(prvkey))
+	mac.Write([]byte("swarm state store encryption:" + name))
+	return mac.Sum(nil)
+}
+
+// NewEncryptedDBStore creates a new instance of DBStore which encrypts values
+// with AES-GCM using the 32 byte key. Keys are not encrypted, so that they can
+// be iterated by prefix. Values of a store created without encryption are
+// encrypted when it is first opened with a key.
+func NewEncryptedDBStore(path string, key []byte) (s *DBStore, err error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	s, err = newEncryptedDBStore(db, key)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func newEncryptedDBStore(db *leveldb.DB, key []byte) (*DBStore, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	s := &DBStore{
+		db:   db,
+		aead: aead,
+	}
+	marker, err := db.Get([]byte(encryptionMarkerKey), nil)
+	switch err {
+	case nil:
+		if value, err := s.open([]byte(encryptionMarkerKey), marker); err != nil || !bytes.Equal(value, encryptionMarker) {
+			return nil, ErrInvalidEncryptionKey
+		}
+		return s, nil
+	case leveldb.ErrNotFound:
+		return s, s.encryptValues()
+	default:
+		return nil, err
+	}
+}
+
+// encryptValues encrypts the values of a store created without encryption
+// and marks it as encrypted in a single batch
+func (s *DBStore) encryptValues() error {
+	batch := new(leveldb.Batch)
+	iter := s.db.NewIterator(nil, nil)
+	for iter.Next() {
+		value, err := s.seal(iter.Key(), iter.Value())
+		if err != nil {
+			iter.Release()
+			return err
+		}
+		batch.Put(append([]byte(nil), iter.Key()...), value)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	marker, err := s.seal([]byte(encryptionMarkerKey), encryptionMarker)
+	if err != nil {
+		return err
+	}
+	batch.Put([]byte(encryptionMarkerKey), marker)
+	return s.db.Write(batch, nil)
+}
+
+// checkNotEncrypted returns ErrStoreEncrypted if the store was encrypted
+func checkNotEncrypted(db *leveldb.DB) error {
+	has, err := db.Has([]byte(encryptionMarkerKey), nil)
+	if err != nil {
+		return err
+	}
+	if has {
+		return ErrStoreEncrypted
+	}
+	return nil
+}
+
+// seal encrypts value if the store is encrypted, the key is authenticated
+// so that values cannot be swapped between keys
+func (s *DBStore) seal(key, value []byte) ([]byte, error) {
+	if s.aead == nil {
+		return value, nil
+	}
+	nonce := make([]byte, s.aead.NonceSize(), s.aead.NonceSize()+len(value)+s.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return s.aead.Seal(nonce, nonce, value, key), nil
+}
+
+// open decrypts data sealed for key if the store is encrypted
+func (s *DBStore) open(key, data []byte) ([]byte, error) {
+	if s.aead == nil {
+		return data, nil
+	}
+	size := s.aead.NonceSize()
+	if len(data) < size {
+		return nil, ErrInvalidEncryptionKey
+	}
+	value, err := s.aead.Open(nil, data[:size], data[size:], key)
+	if err != nil {
+		return nil, ErrInvalidEncryptionKey
+	}
+	return value, nil
+}
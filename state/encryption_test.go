@@ -0,0 +1,111 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// TestEncryptedDBStore tests basic functionality of an encrypted DBStore
+// and that values are not stored in plain text
+func TestEncryptedDBStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "db_store_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	prvkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := EncryptionKey(prvkey, "test")
+
+	store, err := NewEncryptedDBStore(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, store)
+
+	persistedStore, err := NewEncryptedDBStore(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testPersistedStore(t, persistedStore)
+
+	iteratedStore, err := NewEncryptedDBStore(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStoreIterator(t, iteratedStore)
+
+	db, err := leveldb.OpenFile(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := db.NewIterator(nil, nil)
+	for iter.Next() {
+		if bytes.Contains(iter.Value(), []byte("value")) {
+			t.Fatalf("value of key %s stored in plain text", iter.Key())
+		}
+	}
+	iter.Release()
+	db.Close()
+
+	if _, err := NewEncryptedDBStore(dir, EncryptionKey(prvkey, "other")); err != ErrInvalidEncryptionKey {
+		t.Fatalf("got error %v opening with a wrong key, want %v", err, ErrInvalidEncryptionKey)
+	}
+	if _, err := NewDBStore(dir); err != ErrStoreEncrypted {
+		t.Fatalf("got error %v opening without a key, want %v", err, ErrStoreEncrypted)
+	}
+}
+
+// TestEncryptExistingDBStore tests that values of a store created
+// without encryption are readable once it is encrypted
+func TestEncryptExistingDBStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "db_store_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewDBStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, store)
+
+	prvkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedStore, err := NewEncryptedDBStore(dir, EncryptionKey(prvkey, "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testPersistedStore(t, encryptedStore)
+
+	if _, err := NewDBStore(dir); err != ErrStoreEncrypted {
+		t.Fatalf("got error %v opening without a key, want %v", err, ErrStoreEncrypted)
+	}
+}
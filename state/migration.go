@@ -0,0 +1,85 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethersphere/swarm/log"
+)
+
+// ErrSchemaTooNew is returned by Migrate when the data of a component was
+// written with a newer schema version than the one known to this node
+var ErrSchemaTooNew = errors.New("schema version of stored data is newer than supported")
+
+// schemaNamespace is the namespace of the schema versions of components
+const schemaNamespace = "schema"
+
+// Migration upgrades the data of a component from the previous schema version.
+// Migrations are also run on stores that hold no data of the component yet,
+// so they must handle missing values.
+type Migration func(s Store) error
+
+// Schema is the versioned format of the data a component keeps in the state store
+type Schema struct {
+	Component  string      // name of the component owning the data
+	Migrations []Migration // ordered migrations, each upgrading to the next version
+}
+
+// Version returns the current schema version, the number of its migrations
+func (sc Schema) Version() uint64 {
+	return uint64(len(sc.Migrations))
+}
+
+// SchemaVersion returns the schema version of the data of component in s,
+// 0 if the data was never migrated
+func SchemaVersion(s Store, component string) (version uint64, err error) {
+	err = NewNamespacedStore(s, schemaNamespace).Get(component, &version)
+	if err == ErrNotFound {
+		return 0, nil
+	}
+	return version, err
+}
+
+// Migrate upgrades the data of the components in s to the current versions of
+// their schemas. Migrations newer than the stored version of a component are
+// run in order, and the version is stored after each of them, so an
+// interrupted upgrade resumes from where it stopped. It returns ErrSchemaTooNew
+// if data was written by a newer version of a component.
+func Migrate(s Store, schemas ...Schema) error {
+	versions := NewNamespacedStore(s, schemaNamespace)
+	for _, sc := range schemas {
+		version, err := SchemaVersion(s, sc.Component)
+		if err != nil {
+			return fmt.Errorf("%s: get schema version: %v", sc.Component, err)
+		}
+		if version > sc.Version() {
+			return fmt.Errorf("%s: %v: stored %v, supported %v", sc.Component, ErrSchemaTooNew, version, sc.Version())
+		}
+		for ; version < sc.Version(); version++ {
+			log.Info("migrating state store", "component", sc.Component, "from", version, "to", version+1)
+			if err := sc.Migrations[version](s); err != nil {
+				return fmt.Errorf("%s: migrate to schema version %v: %v", sc.Component, version+1, err)
+			}
+			if err := versions.Put(sc.Component, version+1); err != nil {
+				return fmt.Errorf("%s: put schema version: %v", sc.Component, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// TestMigrate tests that migrations newer than the stored schema version
+// are run in order and that an interrupted upgrade is resumed
+func TestMigrate(t *testing.T) {
+	store := NewInmemoryStore()
+	defer store.Close()
+
+	var ran []int
+	migration := func(n int) Migration {
+		return func(s Store) error {
+			ran = append(ran, n)
+			return s.Put("value", n)
+		}
+	}
+	schema := Schema{
+		Component: "test",
+		Migrations: []Migration{
+			migration(1),
+			func(s Store) error { return errors.New("migration failed") },
+		},
+	}
+
+	if err := Migrate(store, schema); err == nil {
+		t.Fatal("expected migration error")
+	}
+	checkSchemaVersion(t, store, "test", 1)
+
+	schema.Migrations[1] = migration(2)
+	schema.Migrations = append(schema.Migrations, migration(3))
+	if err := Migrate(store, schema); err != nil {
+		t.Fatal(err)
+	}
+	checkSchemaVersion(t, store, "test", 3)
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(ran, want) {
+		t.Fatalf("got migrations %v, want %v", ran, want)
+	}
+	var value int
+	if err := store.Get("value", &value); err != nil {
+		t.Fatal(err)
+	}
+	if value != 3 {
+		t.Fatalf("got value %v, want 3", value)
+	}
+
+	// migrating again is a noop
+	if err := Migrate(store, schema); err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 3 {
+		t.Fatalf("got %v migrations, want 3", len(ran))
+	}
+
+	// data of a newer version is not downgraded
+	schema.Migrations = schema.Migrations[:2]
+	if err := Migrate(store, schema); err == nil {
+		t.Fatal("expected error migrating a newer schema version")
+	}
+	checkSchemaVersion(t, store, "test", 3)
+}
+
+func checkSchemaVersion(t *testing.T, s Store, component string, want uint64) {
+	t.Helper()
+	version, err := SchemaVersion(s, component)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != want {
+		t.Fatalf("got schema version %v, want %v", version, want)
+	}
+}
+
+// TestNamespacedStore tests that namespaced stores sharing a store
+// keep and iterate their values separately
+func TestNamespacedStore(t *testing.T) {
+	store := NewInmemoryStore()
+	defer store.Close()
+
+	a := NewNamespacedStore(store, "a")
+	b := NewNamespacedStore(store, "b")
+	for _, s := range []*NamespacedStore{a, b} {
+		if err := s.Put("key_1", s.prefix); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Put("key_2", s.prefix); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Delete("key_2"); err != nil {
+		t.Fatal(err)
+	}
+
+	var value string
+	if err := store.Get("a/key_1", &value); err != nil {
+		t.Fatal(err)
+	}
+	if value != "a/" {
+		t.Fatalf("got value %q, want %q", value, "a/")
+	}
+	if err := b.Get("key_2", &value); err != ErrNotFound {
+		t.Fatalf("got error %v, want %v", err, ErrNotFound)
+	}
+
+	for s, want := range map[*NamespacedStore][]string{
+		a: {"key_1", "key_2"},
+		b: {"key_1"},
+	} {
+		var keys []string
+		if err := s.Iterate("key_", func(key, _ []byte) (bool, error) {
+			keys = append(keys, string(key))
+			return false, nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(keys, want) {
+			t.Fatalf("namespace %v: got keys %v, want %v", s.prefix, keys, want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"strings"
+)
+
+// namespaceSeparator separates the namespace from the key of values
+// stored in a namespaced store
+const namespaceSeparator = "/"
+
+// NamespacedStore is a Store whose keys are prefixed with a namespace, so that
+// components sharing the underlying store do not collide and their data can be
+// iterated, versioned and migrated on its own.
+type NamespacedStore struct {
+	Store
+	prefix string
+}
+
+// NewNamespacedStore returns a store keeping its values in s under namespace
+func NewNamespacedStore(s Store, namespace string) *NamespacedStore {
+	return &NamespacedStore{
+		Store:  s,
+		prefix: namespace + namespaceSeparator,
+	}
+}
+
+// Get retrieves the value persisted for key in the namespace
+func (s *NamespacedStore) Get(key string, i interface{}) (err error) {
+	return s.Store.Get(s.prefix+key, i)
+}
+
+// Put stores a value for key in the namespace
+func (s *NamespacedStore) Put(key string, i interface{}) (err error) {
+	return s.Store.Put(s.prefix+key, i)
+}
+
+// Delete removes the value stored for key in the namespace
+func (s *NamespacedStore) Delete(key string) (err error) {
+	return s.Store.Delete(s.prefix + key)
+}
+
+// Iterate entries of the namespace which have keys matching the given prefix,
+// keys are passed to iterFunc without the namespace
+func (s *NamespacedStore) Iterate(prefix string, iterFunc iterFunction) (err error) {
+	return s.Store.Iterate(s.prefix+prefix, func(key, value []byte) (bool, error) {
+		return iterFunc([]byte(strings.TrimPrefix(string(key), s.prefix)), value)
+	})
+}
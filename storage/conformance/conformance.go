@@ -0,0 +1,259 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package conformance provides canonical test vectors of swarm chunk addressing,
+// content split into a chunk tree with and without encryption and the resulting
+// root reference, together with an API to verify alternative chunker implementations
+// against them.
+//
+// The content of a vector is the first Size bytes of the keccak256 counter stream,
+// keccak256(0) || keccak256(1) || ... where the counter is an 8 byte big endian integer.
+// The content is split with the default chunk size of 4096 bytes and the BMT hash,
+// intermediate chunks hold up to 128 references (64 with encryption).
+// For encrypted vectors the key of every chunk is keccak256(KeySeed || chunk data),
+// the chunk data being the 8 byte little endian span followed by the payload,
+// and the payload is padded with zeros to the chunk size before it is encrypted.
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/storage"
+	"github.com/ethersphere/swarm/storage/encryption"
+	"golang.org/x/crypto/sha3"
+)
+
+// KeySeed is prepended to the chunk data to derive the encryption keys of encrypted vectors
+const KeySeed = "swarm-conformance"
+
+// Vector is a canonical chunk addressing test vector
+type Vector struct {
+	Name      string `json:"name"`
+	Size      int64  `json:"size"`
+	Encrypted bool   `json:"encrypted"`
+	Root      string `json:"root"` // hex encoded root reference
+}
+
+// Content returns the content of the vector
+func (v Vector) Content() []byte {
+	return Content(v.Size)
+}
+
+// Verify checks the root reference computed by an implementation against the vector
+func (v Vector) Verify(root []byte) error {
+	if got := hex.EncodeToString(root); got != v.Root {
+		return fmt.Errorf("vector %s: root reference %s, expected %s", v.Name, got, v.Root)
+	}
+	return nil
+}
+
+// Vectors returns the canonical test vectors
+func Vectors() []Vector {
+	vs := make([]Vector, len(vectors))
+	copy(vs, vectors)
+	return vs
+}
+
+// Content returns size bytes of the keccak256 counter stream
+func Content(size int64) []byte {
+	content := make([]byte, 0, size+32)
+	counter := make([]byte, 8)
+	hasher := sha3.NewLegacyKeccak256()
+	for i := uint64(0); int64(len(content)) < size; i++ {
+		binary.BigEndian.PutUint64(counter, i)
+		hasher.Reset()
+		hasher.Write(counter)
+		content = hasher.Sum(content)
+	}
+	return content[:size]
+}
+
+// Key returns the encryption key of a chunk of an encrypted vector
+func Key(chunkData []byte) []byte {
+	hasher := sha3.NewLegacyKeccak256()
+	hasher.Write([]byte(KeySeed))
+	hasher.Write(chunkData)
+	return hasher.Sum(nil)
+}
+
+// TreeChunk is a chunk of the chunk tree of a vector
+type TreeChunk struct {
+	Reference hexutil.Bytes `json:"reference"`
+	Span      uint64        `json:"span"`
+	Depth     int           `json:"depth"` // 0 for the root chunk
+}
+
+// Tree is a vector with its chunk tree, the chunks are listed depth first
+// starting with the root chunk, children in the order of their references
+type Tree struct {
+	Vector
+	Chunks []TreeChunk `json:"chunks"`
+}
+
+// Split splits the content of the vector with this implementation
+// and returns the resulting chunk tree
+func Split(v Vector) (*Tree, error) {
+	tree, _, err := split(v)
+	return tree, err
+}
+
+// split splits the content of the vector and returns its chunk tree
+// together with the store holding the chunks
+func split(v Vector) (*Tree, *memStore, error) {
+	ctx := context.Background()
+	store := newMemStore()
+	hashFunc := storage.MakeHashFunc(storage.DefaultHash)
+	tag := chunk.NewTag(0, "conformance", 0, false)
+	hasherStore := storage.NewHasherStore(store, hashFunc, false, tag)
+	if v.Encrypted {
+		hasherStore = storage.NewDeterministicHasherStore(store, hashFunc, func(data storage.ChunkData) encryption.Key {
+			return Key(data)
+		}, tag)
+	}
+	root, wait, err := storage.PyramidSplit(ctx, bytes.NewReader(v.Content()), hasherStore, hasherStore, tag)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := wait(ctx); err != nil {
+		return nil, nil, err
+	}
+	tree := &Tree{Vector: v}
+	tree.Root = hex.EncodeToString(root)
+	if err := tree.walk(ctx, hasherStore, storage.Reference(root), int(hasherStore.RefSize()), 0); err != nil {
+		return nil, nil, err
+	}
+	return tree, store, nil
+}
+
+func (t *Tree) walk(ctx context.Context, getter storage.Getter, ref storage.Reference, refSize int, depth int) error {
+	data, err := getter.Get(ctx, ref)
+	if err != nil {
+		return fmt.Errorf("chunk %x: %v", ref, err)
+	}
+	span := data.Size()
+	t.Chunks = append(t.Chunks, TreeChunk{
+		Reference: hexutil.Bytes(ref),
+		Span:      span,
+		Depth:     depth,
+	})
+	if span <= chunk.DefaultSize {
+		return nil
+	}
+	refs := data[8:]
+	for i := 0; i+refSize <= len(refs); i += refSize {
+		if err := t.walk(ctx, getter, storage.Reference(refs[i:i+refSize]), refSize, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Splitter is a chunker implementation under test, it returns the root reference
+// of the content, encrypting the chunks with the keys returned by key if it is not nil
+type Splitter func(content []byte, key func(chunkData []byte) []byte) ([]byte, error)
+
+// Mismatch is a vector for which an implementation failed to compute the expected root reference
+type Mismatch struct {
+	Vector Vector
+	Err    error
+}
+
+func (m Mismatch) String() string {
+	return m.Err.Error()
+}
+
+// Verify runs split on the content of all canonical vectors and returns the mismatches
+func Verify(split Splitter) []Mismatch {
+	var mismatches []Mismatch
+	for _, v := range vectors {
+		var key func([]byte) []byte
+		if v.Encrypted {
+			key = Key
+		}
+		root, err := split(v.Content(), key)
+		if err != nil {
+			err = fmt.Errorf("vector %s: %v", v.Name, err)
+		} else {
+			err = v.Verify(root)
+		}
+		if err != nil {
+			mismatches = append(mismatches, Mismatch{Vector: v, Err: err})
+		}
+	}
+	return mismatches
+}
+
+// WriteJSON writes the canonical vectors with their chunk trees as JSON,
+// for implementations which can not use this package directly
+func WriteJSON(w io.Writer) error {
+	trees := make([]*Tree, 0, len(vectors))
+	for _, v := range vectors {
+		tree, err := Split(v)
+		if err != nil {
+			return err
+		}
+		if err := v.Verify(tree.Chunks[0].Reference); err != nil {
+			return err
+		}
+		trees = append(trees, tree)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(trees)
+}
+
+// memStore is an in memory chunk store used to split and walk the vectors
+type memStore struct {
+	storage.FakeChunkStore
+	chunks map[string]storage.Chunk
+	mu     sync.RWMutex
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		chunks: make(map[string]storage.Chunk),
+	}
+}
+
+func (m *memStore) Put(_ context.Context, _ chunk.ModePut, chs ...storage.Chunk) ([]bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	exist := make([]bool, len(chs))
+	for i, ch := range chs {
+		_, exist[i] = m.chunks[ch.Address().Hex()]
+		m.chunks[ch.Address().Hex()] = ch
+	}
+	return exist, nil
+}
+
+func (m *memStore) Get(_ context.Context, _ chunk.ModeGet, addr storage.Address) (storage.Chunk, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ch, ok := m.chunks[addr.Hex()]
+	if !ok {
+		return nil, storage.ErrChunkNotFound
+	}
+	return ch, nil
+}
@@ -0,0 +1,167 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/storage"
+	"github.com/ethersphere/swarm/storage/encryption"
+)
+
+// TestVectors tests that this implementation produces the canonical root references
+// and chunk trees from which the content can be retrieved
+func TestVectors(t *testing.T) {
+	for _, v := range Vectors() {
+		t.Run(v.Name, func(t *testing.T) {
+			tree, store, err := split(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tree.Root != v.Root {
+				t.Fatalf("expected root %s, got %s", v.Root, tree.Root)
+			}
+			root := tree.Chunks[0]
+			if err := v.Verify(root.Reference); err != nil {
+				t.Fatal(err)
+			}
+			if root.Span != uint64(v.Size) {
+				t.Fatalf("expected root span %v, got %v", v.Size, root.Span)
+			}
+			var data uint64
+			for _, c := range tree.Chunks {
+				if c.Span <= chunk.DefaultSize {
+					data += c.Span
+				}
+			}
+			if data != uint64(v.Size) {
+				t.Fatalf("expected data chunks to span %v, got %v", v.Size, data)
+			}
+
+			fileStore := storage.NewFileStore(store, store, storage.NewFileStoreParams(), chunk.NewTags())
+			reader, isEncrypted := fileStore.Retrieve(context.Background(), storage.Address(root.Reference))
+			if isEncrypted != v.Encrypted {
+				t.Fatalf("expected encrypted %v, got %v", v.Encrypted, isEncrypted)
+			}
+			content, err := ioutil.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(content, v.Content()) {
+				t.Fatal("retrieved content differs")
+			}
+		})
+	}
+}
+
+// TestVerify tests verifying a splitter against the vectors
+func TestVerify(t *testing.T) {
+	splitter := func(content []byte, key func([]byte) []byte) ([]byte, error) {
+		store := newMemStore()
+		hashFunc := storage.MakeHashFunc(storage.DefaultHash)
+		tag := chunk.NewTag(0, "test", 0, false)
+		hasherStore := storage.NewHasherStore(store, hashFunc, false, tag)
+		if key != nil {
+			hasherStore = storage.NewDeterministicHasherStore(store, hashFunc, func(data storage.ChunkData) encryption.Key {
+				return key(data)
+			}, tag)
+		}
+		ctx := context.Background()
+		root, wait, err := storage.PyramidSplit(ctx, bytes.NewReader(content), hasherStore, hasherStore, tag)
+		if err != nil {
+			return nil, err
+		}
+		return root, wait(ctx)
+	}
+	if mismatches := Verify(splitter); len(mismatches) != 0 {
+		t.Fatalf("expected no mismatches, got %v", mismatches)
+	}
+
+	// a splitter ignoring encryption fails the encrypted vectors
+	mismatches := Verify(func(content []byte, _ func([]byte) []byte) ([]byte, error) {
+		return splitter(content, nil)
+	})
+	var encrypted int
+	for _, v := range Vectors() {
+		if v.Encrypted {
+			encrypted++
+		}
+	}
+	if len(mismatches) != encrypted {
+		t.Fatalf("expected %v mismatches, got %v", encrypted, len(mismatches))
+	}
+	for _, m := range mismatches {
+		if !m.Vector.Encrypted {
+			t.Fatalf("unexpected mismatch %v", m)
+		}
+	}
+
+	errSplit := errors.New("split failed")
+	mismatches = Verify(func([]byte, func([]byte) []byte) ([]byte, error) {
+		return nil, errSplit
+	})
+	if len(mismatches) != len(Vectors()) {
+		t.Fatalf("expected %v mismatches, got %v", len(Vectors()), len(mismatches))
+	}
+}
+
+// TestContent tests the keccak256 counter stream content
+func TestContent(t *testing.T) {
+	content := Content(40)
+	if len(content) != 40 {
+		t.Fatalf("expected 40 bytes, got %v", len(content))
+	}
+	// keccak256 of 8 zero bytes
+	expected := "011b4d03dd8c01f1049143cf9c4c817e4b167f1d1b83e5c6f0f10d89ba1e7bce"
+	if got := hex.EncodeToString(content[:32]); got != expected {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if !bytes.Equal(Content(33), content[:33]) {
+		t.Fatal("content is not a prefix of longer content")
+	}
+}
+
+// TestWriteJSON tests the JSON export of the vectors
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var trees []*Tree
+	if err := json.Unmarshal(buf.Bytes(), &trees); err != nil {
+		t.Fatal(err)
+	}
+	vectors := Vectors()
+	if len(trees) != len(vectors) {
+		t.Fatalf("expected %v trees, got %v", len(vectors), len(trees))
+	}
+	for i, tree := range trees {
+		if tree.Vector != vectors[i] {
+			t.Fatalf("expected vector %v, got %v", vectors[i], tree.Vector)
+		}
+		if err := tree.Verify(tree.Chunks[0].Reference); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package conformance
+
+// vectors are the canonical test vectors, sizes cover the chunk and intermediate
+// chunk boundaries with 128 references per chunk and 64 with encryption
+var vectors = []Vector{
+	{Name: "plain-1", Size: 1, Encrypted: false, Root: "b53eefd07d02536e66551f7b3162e80fc41864bd989da51ac88e71807983fac2"},
+	{Name: "plain-32", Size: 32, Encrypted: false, Root: "a838a5652b851d5e670eb73fb6c0bd5fa67133b68a38d2e6a62e0a00e9a9f54e"},
+	{Name: "plain-33", Size: 33, Encrypted: false, Root: "b0098cf9a69ae008a88e196356265c801010099e25d2e48e67744d90552c5452"},
+	{Name: "plain-4095", Size: 4095, Encrypted: false, Root: "7d7141d0340fb52e6edb81abe79d66215e4f8cba9d140001447069ecb33b17fd"},
+	{Name: "plain-4096", Size: 4096, Encrypted: false, Root: "86757ec281fb242dbe3f917330eb0940153b251d3407d7a44507d40899ca0fba"},
+	{Name: "plain-4097", Size: 4097, Encrypted: false, Root: "81aeb21765aa5f50d007c73a2ddec2c0f6a81a8bae32f3bf7b0dcc4a41a17c78"},
+	{Name: "plain-8192", Size: 8192, Encrypted: false, Root: "d46b6cf4f620cae4bb191bbe244850e9336baf19f27e474c729f87ef791fca4a"},
+	{Name: "plain-262144", Size: 262144, Encrypted: false, Root: "a6d87f9419e691b98e4603b80a6dcb27f1793515ea435bdb26993a06296dc7c1"},
+	{Name: "plain-262145", Size: 262145, Encrypted: false, Root: "1cbf0415da5d846b3bcb3f61581717bad8010136bdd14f7578c26f1b24061149"},
+	{Name: "plain-524288", Size: 524288, Encrypted: false, Root: "1283142a1accc461e8119cc955524a448dc5e82fe3f4c610a49f6e36d7335f23"},
+	{Name: "plain-524289", Size: 524289, Encrypted: false, Root: "a025d636c9fdea65d6b19fe2f42452053b7a6b84273142aefed41a656510b157"},
+	{Name: "plain-1052673", Size: 1052673, Encrypted: false, Root: "76d0869537a61ee0759ae92db7e516d1e98b880bf027a56b1d4a4bdd39a1a5f8"},
+	{Name: "encrypted-1", Size: 1, Encrypted: true, Root: "0f5602e77564ae6c900bc2c28ee8022013baaa0f8950d595d71089ea65b313dd9e87de56f9092437cf4afd856fc387aca89115e10bbb8df5fccc1b9cd5b3e27c"},
+	{Name: "encrypted-32", Size: 32, Encrypted: true, Root: "93486ba2b255585cf8dea8dd5f327a242ed22d8ffd90ffffa36a8dd10d78c15eee95fb2f73bb96bdf82c7b8774b7bcb2c53748c9c9f8b6cce05088dac4e8fe8f"},
+	{Name: "encrypted-33", Size: 33, Encrypted: true, Root: "28b975df2b747904ee0ad9f39e7273a8938e8463a3689efdbbb0020aa30cdb7474875267073113eff757f59f58b1a27a1fe1470552f20f2c2ef1dd901bbd9dc2"},
+	{Name: "encrypted-4095", Size: 4095, Encrypted: true, Root: "3ca96324a4c25dee8a2128f7acdb683b3c3883803c60494bf2560f0b91780c5f1d6e7a96924b4f37c2d9c08033dbbf8e310c9f74e308741ff1bfebc94af21c1f"},
+	{Name: "encrypted-4096", Size: 4096, Encrypted: true, Root: "f66df0900a6bcdf8cc6dc944c683db0a187cf40246b4f3ae01b3b6b48cbf5e89e6271bb556634b6da0a56a4fa81606ec5140248158147de6336a9da40daebef6"},
+	{Name: "encrypted-4097", Size: 4097, Encrypted: true, Root: "e88129e089a93177fc71d68c071c932448fb9bbe87e0cc4592e17fe25b5316f68cb64728bc13af050a085efabf9a0c43ccc6c67bad1afbd0884d624a8c950759"},
+	{Name: "encrypted-8192", Size: 8192, Encrypted: true, Root: "d863cdbfa4bc6d041282f910e404e86485e51e395d0d719b4f969b693d74dbfbc21208d9232b683aabd673ae93f80cad88eb7199b01086a4d4fa41e945d8125a"},
+	{Name: "encrypted-262144", Size: 262144, Encrypted: true, Root: "0628552db30308118b070aff44f89113c2b5203f697107606bce87d8fdec4f2fcb530b2a6e1000d3bb3600124d60f2d459f864d9679be6917e88e6fc81d5a713"},
+	{Name: "encrypted-262145", Size: 262145, Encrypted: true, Root: "39f9a83694a19c37d5b209866d6572b6c1621b92721f0c82ff496acd2c1c0828320d3682a5bca23876597920f9063bd416bc5adddb4ef1af70de33daced2ccec"},
+	{Name: "encrypted-524288", Size: 524288, Encrypted: true, Root: "7d364120d43e0a0c541162f66f924b03bfc01d15e518e69377973d6d8337bb64ad5437dd2fbde8fd137aa0c2e7c123f98351b8d23f1de8ea0b36f1efdee57ea7"},
+	{Name: "encrypted-524289", Size: 524289, Encrypted: true, Root: "3ad125f3f6c29a65b9b235e839b0fe8b64107d90ac709c4733c9ae0ea1f5b27e6dfca117163df96c021117e471a50a76de0ea7ba15e5d17fde34935dedf5d309"},
+	{Name: "encrypted-1052673", Size: 1052673, Encrypted: true, Root: "7e7b113eaed19c45c8560c26f88f544d74f6da98395f1559306798ed41a9d63efb97ad022c67bc478533e918e6f00a05255d67c81efa2afcf3a77cc3f437b2e6"},
+}
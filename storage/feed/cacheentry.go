@@ -34,7 +34,8 @@ const (
 type cacheEntry struct {
 	Update
 	*bytes.Reader
-	lastKey storage.Address
+	lastKey    storage.Address
+	deltaDepth int // number of deltas the update is from the last full update
 }
 
 // implements storage.LazySectionReader
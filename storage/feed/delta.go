@@ -0,0 +1,214 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package feed
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/storage"
+	"github.com/ethersphere/swarm/storage/feed/lookup"
+)
+
+// Payload encodings of a feed update, stored in the update header
+const (
+	EncodingFull  uint8 = 0 // the update data is the full payload
+	EncodingDelta uint8 = 1 // the update data is a delta against the payload of a previous update
+)
+
+// Delta payload layout:
+// base epoch EpochLength bytes, epoch of the update the delta applies to
+// depth 1 byte, number of deltas since the last full update, this one included
+// operations, each either
+// a copy: opCopy, uvarint offset in the base payload, uvarint length
+// or an insert: opInsert, uvarint length, bytes
+const deltaHeaderLength = lookup.EpochLength + 1
+
+const (
+	opCopy   = 0
+	opInsert = 1
+)
+
+// minDeltaMatch is the shortest run of bytes of the base payload worth copying instead of inserting
+const minDeltaMatch = 8
+
+// maxDeltaDepth bounds the number of deltas followed to reconstruct a payload
+const maxDeltaDepth = 255
+
+// deltaBase is the last known update of a feed that a new update can be encoded against
+type deltaBase struct {
+	epoch lookup.Epoch
+	data  []byte // full payload of the base update
+	depth int    // number of deltas the base update is from the last full update
+}
+
+// encodeDelta returns the delta payload that reconstructs next from the base payload
+func encodeDelta(base *deltaBase, next []byte) []byte {
+	epochBytes, _ := base.epoch.MarshalBinary()
+	buf := bytes.NewBuffer(make([]byte, 0, deltaHeaderLength+len(next)/4))
+	buf.Write(epochBytes)
+	buf.WriteByte(uint8(base.depth + 1))
+
+	varint := make([]byte, binary.MaxVarintLen64)
+	putUvarint := func(v int) {
+		buf.Write(varint[:binary.PutUvarint(varint, uint64(v))])
+	}
+	var pending []byte
+	flush := func() {
+		if len(pending) > 0 {
+			buf.WriteByte(opInsert)
+			putUvarint(len(pending))
+			buf.Write(pending)
+			pending = nil
+		}
+	}
+
+	// index the positions of every minDeltaMatch long window of the base payload
+	index := make(map[string]int)
+	for i := len(base.data) - minDeltaMatch; i >= 0; i-- {
+		index[string(base.data[i:i+minDeltaMatch])] = i
+	}
+	// shift between the positions in the base and the new payload of the last copy. Unchanged
+	// content after an edit is most likely found at the same shift or at the same position,
+	// so those are tried besides the indexed position and the longest match is copied
+	shift := 0
+	for i := 0; i < len(next); {
+		if i+minDeltaMatch <= len(next) {
+			offset, length := -1, 0
+			candidates := []int{i + shift, i}
+			if indexed, ok := index[string(next[i:i+minDeltaMatch])]; ok {
+				candidates = append(candidates, indexed)
+			}
+			for _, c := range candidates {
+				if l := matchLength(base.data, c, next[i:]); l > length {
+					offset, length = c, l
+				}
+			}
+			if length >= minDeltaMatch {
+				flush()
+				buf.WriteByte(opCopy)
+				putUvarint(offset)
+				putUvarint(length)
+				shift = offset - i
+				i += length
+				continue
+			}
+		}
+		pending = append(pending, next[i])
+		i++
+	}
+	flush()
+	return buf.Bytes()
+}
+
+// matchLength returns the number of leading bytes of next found at offset in the base payload
+func matchLength(base []byte, offset int, next []byte) int {
+	if offset < 0 || offset >= len(base) {
+		return 0
+	}
+	length := 0
+	for offset+length < len(base) && length < len(next) && base[offset+length] == next[length] {
+		length++
+	}
+	return length
+}
+
+// decodeDeltaHeader returns the epoch of the base update and the depth of a delta payload
+func decodeDeltaHeader(delta []byte) (base lookup.Epoch, depth int, err error) {
+	if len(delta) < deltaHeaderLength {
+		return base, 0, NewError(ErrCorruptData, "feed update delta too short")
+	}
+	if err := base.UnmarshalBinary(delta[:lookup.EpochLength]); err != nil {
+		return base, 0, NewError(ErrCorruptData, err.Error())
+	}
+	return base, int(delta[lookup.EpochLength]), nil
+}
+
+// applyDelta reconstructs a payload from the base payload and a delta payload
+func applyDelta(base []byte, delta []byte) ([]byte, error) {
+	r := bytes.NewReader(delta[deltaHeaderLength:])
+	var result []byte
+	for r.Len() > 0 {
+		op, _ := r.ReadByte()
+		switch op {
+		case opCopy:
+			offset, err1 := binary.ReadUvarint(r)
+			length, err2 := binary.ReadUvarint(r)
+			if err1 != nil || err2 != nil || offset > uint64(len(base)) || length > uint64(len(base))-offset {
+				return nil, NewError(ErrCorruptData, "invalid copy in feed update delta")
+			}
+			result = append(result, base[offset:offset+length]...)
+		case opInsert:
+			length, err := binary.ReadUvarint(r)
+			if err != nil || length > uint64(r.Len()) {
+				return nil, NewError(ErrCorruptData, "invalid insert in feed update delta")
+			}
+			data := make([]byte, length)
+			r.Read(data)
+			result = append(result, data...)
+		default:
+			return nil, NewErrorf(ErrCorruptData, "unknown feed update delta operation %d", op)
+		}
+		if len(result) > MaxUpdateDataLength {
+			return nil, NewError(ErrDataOverflow, "feed update delta exceeds the maximum payload length")
+		}
+	}
+	return result, nil
+}
+
+// resolve returns the full payload of a feed update, following the deltas back to the last
+// full update, and the number of deltas followed
+func (h *Handler) resolve(ctx context.Context, request *Request) ([]byte, int, error) {
+	var deltas [][]byte
+	update := request
+	for update.Header.Encoding == EncodingDelta {
+		if len(deltas) == maxDeltaDepth {
+			return nil, 0, NewError(ErrCorruptData, "feed update delta chain too long")
+		}
+		base, _, err := decodeDeltaHeader(update.data)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !update.Epoch.After(base) {
+			return nil, 0, NewError(ErrCorruptData, "feed update delta base is not older than the update")
+		}
+		deltas = append(deltas, update.data)
+
+		id := ID{Feed: request.Feed, Epoch: base}
+		rctx, cancel := context.WithTimeout(ctx, defaultRetrieveTimeout)
+		ch, err := h.chunkStore.Get(rctx, chunk.ModeGetLookup, storage.NewRequest(id.Addr()))
+		cancel()
+		if err != nil {
+			return nil, 0, NewErrorf(ErrNotFound, "feed update delta base %s not found: %v", base.String(), err)
+		}
+		update = new(Request)
+		if err := update.fromChunk(ch); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	data := update.data
+	for i := len(deltas) - 1; i >= 0; i-- {
+		var err error
+		if data, err = applyDelta(data, deltas[i]); err != nil {
+			return nil, 0, err
+		}
+	}
+	return data, len(deltas), nil
+}
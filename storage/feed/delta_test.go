@@ -0,0 +1,176 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package feed
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/storage"
+	"github.com/ethersphere/swarm/storage/feed/lookup"
+)
+
+// TestDelta checks that deltas reconstruct the new payload for different kinds of changes
+func TestDelta(t *testing.T) {
+	doc := bytes.Repeat([]byte("swarm feeds store nearly identical documents. "), 60)
+	edited := append([]byte{}, doc...)
+	copy(edited[1000:], "EDITED")
+	for _, tc := range []struct {
+		name      string
+		base      []byte
+		next      []byte
+		maxLength int
+	}{
+		{name: "edit", base: doc, next: edited, maxLength: 100},
+		{name: "append", base: doc, next: append(append([]byte{}, doc...), "more"...), maxLength: 100},
+		{name: "truncate", base: doc, next: doc[:1500], maxLength: 100},
+		{name: "different", base: doc, next: []byte("something else entirely")},
+		{name: "empty base", base: nil, next: doc},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			base := &deltaBase{epoch: lookup.Epoch{Time: 4200, Level: 3}, data: tc.base, depth: 2}
+			delta := encodeDelta(base, tc.next)
+			if tc.maxLength > 0 && len(delta) > tc.maxLength {
+				t.Fatalf("expected delta of at most %d bytes, got %d", tc.maxLength, len(delta))
+			}
+			epoch, depth, err := decodeDeltaHeader(delta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !epoch.Equals(base.epoch) || depth != 3 {
+				t.Fatalf("expected base epoch %v and depth 3, got %v and %d", base.epoch.String(), epoch.String(), depth)
+			}
+			data, err := applyDelta(tc.base, delta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, tc.next) {
+				t.Fatalf("reconstructed payload differs from the original")
+			}
+		})
+	}
+
+	// a copy out of the base payload range is rejected
+	delta := encodeDelta(&deltaBase{data: doc}, edited)
+	if _, err := applyDelta(doc[:100], delta); err == nil {
+		t.Fatal("expected error applying delta to a shorter base")
+	}
+}
+
+// TestDeltaUpdates checks that updates are stored as deltas with periodic full updates
+// and that their payload is reconstructed on lookup
+func TestDeltaUpdates(t *testing.T) {
+	clock := &fakeTimeProvider{
+		currentTime: startTime.Time,
+	}
+	TimestampProvider = clock
+	signer := newAliceSigner()
+
+	datadir, err := ioutil.TempDir("", "fh-delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+	params := &HandlerParams{DeltaSnapshotInterval: 3}
+	fh, err := NewTestHandler(datadir, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	topic, _ := NewTopic("delta", nil)
+	fd := Feed{
+		Topic: topic,
+		User:  signer.Address(),
+	}
+
+	doc := bytes.Repeat([]byte("a frequently updated document. "), 100)
+	var docs [][]byte
+	var times []uint64
+	wantEncodings := []uint8{EncodingFull, EncodingDelta, EncodingDelta, EncodingFull, EncodingDelta}
+	for i, wantEncoding := range wantEncodings {
+		doc = append([]byte{}, doc...)
+		copy(doc[i*100:], fmt.Sprintf("revision %d", i))
+
+		var request *Request
+		if i == 0 {
+			request = NewFirstRequest(fd.Topic)
+		} else if request, err = fh.NewRequest(ctx, &fd); err != nil {
+			t.Fatal(err)
+		}
+		request.SetData(doc)
+		if err := request.Sign(signer); err != nil {
+			t.Fatal(err)
+		}
+		addr, err := fh.Update(ctx, request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs = append(docs, doc)
+		times = append(times, clock.currentTime)
+
+		ch, err := fh.chunkStore.Get(ctx, chunk.ModeGetLookup, storage.NewRequest(addr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var stored Request
+		if err := stored.fromChunk(ch); err != nil {
+			t.Fatal(err)
+		}
+		if stored.Header.Encoding != wantEncoding {
+			t.Fatalf("update %d: expected encoding %d, got %d", i, wantEncoding, stored.Header.Encoding)
+		}
+		if wantEncoding == EncodingDelta && len(stored.data) > 100 {
+			t.Fatalf("update %d: expected a small delta, got %d bytes", i, len(stored.data))
+		}
+		clock.FastForward(10)
+	}
+	fh.Close()
+
+	// a new handler without cache nor deltas enabled reconstructs the payloads from the stored updates
+	fh, err = NewTestHandler(datadir, &HandlerParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+	if _, err := fh.Lookup(ctx, NewQueryLatest(&fd, lookup.NoClue)); err != nil {
+		t.Fatal(err)
+	}
+	_, data, err := fh.GetContent(&fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, docs[len(docs)-1]) {
+		t.Fatal("latest reconstructed payload differs from the last update")
+	}
+	for i := range docs {
+		update, err := fh.Lookup(ctx, NewQuery(&fd, times[i], lookup.NoClue))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(update.data, docs[i]) {
+			t.Fatalf("reconstructed payload of update %d differs from the original", i)
+		}
+	}
+}
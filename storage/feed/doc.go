@@ -32,12 +32,19 @@ Structure Summary:
 
 Request: Feed Update with signature
 	Update: headers + data
-		Header: Protocol version and reserved for future use placeholders
+		Header: Protocol version, payload encoding and reserved for future use placeholders
 		ID: Information about how to locate a specific update
 			Feed: Represents a user's series of publications about a specific Topic
 				Topic: Item that the updates are about
 				User: User who updates the Feed
 			Epoch: time slot where the update is stored
 
+Delta updates:
+
+A Handler created with a DeltaSnapshotInterval prepares requests whose data is stored as a delta
+against the payload of the previous update whenever that is smaller, with a full update every
+DeltaSnapshotInterval updates. The delta refers to the epoch of the update it applies to, and
+lookups reconstruct the payload transparently by following deltas back to the last full update.
+
 */
 package feed
@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/log"
@@ -36,11 +37,16 @@ type Handler struct {
 	HashSize   int
 	cache      map[uint64]*cacheEntry
 	cacheLock  sync.RWMutex
+
+	deltaSnapshotInterval int
+	clockTolerance        uint64 // seconds by which the clocks of publishers may be ahead of the host clock
 }
 
 // HandlerParams pass parameters to the Handler constructor NewHandler
 // Signer and TimestampProvider are mandatory parameters
 type HandlerParams struct {
+	DeltaSnapshotInterval int           // store updates as deltas against the previous one, with a full update every DeltaSnapshotInterval updates. 0 disables deltas
+	ClockTolerance        time.Duration // lookups of the latest update also find updates this much later than the host clock
 }
 
 // hashPool contains a pool of ready hashers
@@ -59,6 +65,9 @@ func init() {
 func NewHandler(params *HandlerParams) *Handler {
 	fh := &Handler{
 		cache: make(map[uint64]*cacheEntry),
+
+		deltaSnapshotInterval: params.DeltaSnapshotInterval,
+		clockTolerance:        uint64(params.ClockTolerance / time.Second),
 	}
 
 	for i := 0; i < hasherCount; i++ {
@@ -146,7 +155,21 @@ func (h *Handler) NewRequest(ctx context.Context, feed *Feed) (request *Request,
 
 	// if we already have an update, then find next epoch
 	if feedUpdate != nil {
+		// the latest update is later than the host clock if the clock of its publisher
+		// is ahead or the host clock is behind. the next update must not be earlier
+		if feedUpdate.Epoch.Time > now {
+			log.Warn("latest feed update is later than the host clock, the clock may be behind", "ahead", time.Duration(feedUpdate.Epoch.Time-now)*time.Second)
+			now = feedUpdate.Epoch.Time
+		}
 		request.Epoch = lookup.GetNextEpoch(feedUpdate.Epoch, now)
+		// the new update can be a delta against this one, unless a full snapshot is due
+		if feedUpdate.deltaDepth+1 < h.deltaSnapshotInterval {
+			request.base = &deltaBase{
+				epoch: feedUpdate.Epoch,
+				data:  feedUpdate.data,
+				depth: feedUpdate.deltaDepth,
+			}
+		}
 	} else {
 		request.Epoch = lookup.GetFirstEpoch(now)
 	}
@@ -162,7 +185,8 @@ func (h *Handler) Lookup(ctx context.Context, query *Query) (*cacheEntry, error)
 
 	timeLimit := query.TimeLimit
 	if timeLimit == 0 { // if time limit is set to zero, the user wants to get the latest update
-		timeLimit = TimestampProvider.Now().Time
+		// tolerate updates published by peers with a clock ahead of ours
+		timeLimit = TimestampProvider.Now().Time + h.clockTolerance
 	}
 
 	if query.Hint == lookup.NoClue { // try to use our cache
@@ -218,12 +242,17 @@ func (h *Handler) Lookup(ctx context.Context, query *Query) (*cacheEntry, error)
 	if request == nil {
 		return nil, NewError(ErrNotFound, "no feed updates found")
 	}
-	return h.updateCache(request)
+	// reconstruct the payload of updates stored as deltas
+	data, depth, err := h.resolve(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return h.updateCache(request, data, depth)
 
 }
 
 // update feed updates cache with specified content
-func (h *Handler) updateCache(request *Request) (*cacheEntry, error) {
+func (h *Handler) updateCache(request *Request, data []byte, deltaDepth int) (*cacheEntry, error) {
 
 	updateAddr := request.Addr()
 	log.Trace("feed cache update", "topic", request.Topic.Hex(), "updateaddr", updateAddr, "epoch time", request.Epoch.Time, "epoch level", request.Epoch.Level)
@@ -237,6 +266,8 @@ func (h *Handler) updateCache(request *Request) (*cacheEntry, error) {
 	// update our rsrcs entry map
 	entry.lastKey = updateAddr
 	entry.Update = request.Update
+	entry.data = data
+	entry.deltaDepth = deltaDepth
 	entry.Reader = bytes.NewReader(entry.data)
 	return entry, nil
 }
@@ -272,16 +303,36 @@ func (h *Handler) Update(ctx context.Context, r *Request) (updateAddr storage.Ad
 
 	// update our feed updates map cache entry if the new update is older than the one we have, if we have it.
 	if feedUpdate != nil && r.Epoch.After(feedUpdate.Epoch) {
+		data, depth, err := h.updatePayload(ctx, r)
+		if err != nil {
+			// the update is stored, the cache will catch up with the next lookup
+			log.Warn("Cannot reconstruct feed update payload", "addr", r.idAddr, "err", err)
+			return r.idAddr, nil
+		}
 		feedUpdate.Epoch = r.Epoch
-		feedUpdate.data = make([]byte, len(r.data))
+		feedUpdate.data = make([]byte, len(data))
 		feedUpdate.lastKey = r.idAddr
-		copy(feedUpdate.data, r.data)
+		feedUpdate.deltaDepth = depth
+		copy(feedUpdate.data, data)
 		feedUpdate.Reader = bytes.NewReader(feedUpdate.data)
 	}
 
 	return r.idAddr, nil
 }
 
+// updatePayload returns the full payload of an update being published and its delta depth
+func (h *Handler) updatePayload(ctx context.Context, r *Request) ([]byte, int, error) {
+	if r.Header.Encoding != EncodingDelta {
+		return r.data, 0, nil
+	}
+	if r.fullData == nil {
+		// the request was not prepared locally, reconstruct its payload
+		return h.resolve(ctx, r)
+	}
+	_, depth, err := decodeDeltaHeader(r.data)
+	return r.fullData, depth, err
+}
+
 // Retrieves the feed update cache value for the given nameHash
 func (h *Handler) get(feed *Feed) *cacheEntry {
 	mapKey := feed.mapKey()
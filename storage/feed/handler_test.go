@@ -501,3 +501,61 @@ func newCharlieSigner() *GenericSigner {
 	privKey, _ := crypto.HexToECDSA("facadefacadefacadefacadefacadefacadefacadefacadefacadefacadefaca")
 	return NewGenericSigner(privKey)
 }
+
+// TestClockTolerance tests that updates published with a clock ahead of the host clock
+// are found within the clock tolerance and that the next update is not timestamped earlier
+func TestClockTolerance(t *testing.T) {
+	// the publisher clock is a minute ahead
+	clock := &fakeTimeProvider{
+		currentTime: startTime.Time + 60,
+	}
+	signer := newAliceSigner()
+	fh, datadir, teardownTest, err := setupTest(clock, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardownTest()
+	defer os.RemoveAll(datadir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	topic, _ := NewTopic("Clock ahead", nil)
+	fd := Feed{
+		Topic: topic,
+		User:  signer.Address(),
+	}
+	request := NewFirstRequest(fd.Topic)
+	request.SetData([]byte("ahead"))
+	if err := request.Sign(signer); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fh.Update(ctx, request); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Set(startTime.Time)
+
+	strict := NewHandler(&HandlerParams{})
+	strict.SetStore(fh.chunkStore)
+	if _, err := strict.Lookup(ctx, NewQueryLatest(&fd, lookup.NoClue)); err == nil {
+		t.Fatal("expected update later than the host clock not to be found without tolerance")
+	}
+
+	tolerant := NewHandler(&HandlerParams{ClockTolerance: 2 * time.Minute})
+	tolerant.SetStore(fh.chunkStore)
+	entry, err := tolerant.Lookup(ctx, NewQueryLatest(&fd, lookup.NoClue))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Epoch.Time != startTime.Time+60 || !bytes.Equal(entry.data, []byte("ahead")) {
+		t.Fatalf("got update at %v with data %q", entry.Epoch.Time, entry.data)
+	}
+
+	request, err = tolerant.NewRequest(ctx, &fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.Epoch.Time < startTime.Time+60 {
+		t.Fatalf("got next update at %v, earlier than the latest update", request.Epoch.Time)
+	}
+}
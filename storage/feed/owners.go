@@ -0,0 +1,69 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package feed
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// MaxOwners is the maximum number of owners of a multi-owner feed
+const MaxOwners = 64
+
+// OwnerSet is a set of accounts that may sign updates of multi-owner feeds
+// Multi-owner feeds use the owner set address as their user. Every update
+// carries the owner set, so that any node can check that its signer is one
+// of the owners of the feed without knowing the owner set beforehand.
+type OwnerSet []common.Address
+
+// NewOwnerSet returns the sorted set of the provided owners without duplicates
+func NewOwnerSet(owners ...common.Address) OwnerSet {
+	set := make(OwnerSet, 0, len(owners))
+	for _, o := range owners {
+		if !set.Contains(o) {
+			set = append(set, o)
+		}
+	}
+	sort.Slice(set, func(i, j int) bool {
+		return bytes.Compare(set[i][:], set[j][:]) < 0
+	})
+	return set
+}
+
+// Address returns the address multi-owner feeds of the owner set are published under
+// It is derived from the hash of the owners, so it does not collide with account addresses
+func (o OwnerSet) Address() common.Address {
+	set := NewOwnerSet(o...)
+	data := make([]byte, 0, len(set)*common.AddressLength)
+	for _, owner := range set {
+		data = append(data, owner[:]...)
+	}
+	return common.BytesToAddress(crypto.Keccak256(data))
+}
+
+// Contains returns true if addr is one of the owners
+func (o OwnerSet) Contains(addr common.Address) bool {
+	for _, owner := range o {
+		if owner == addr {
+			return true
+		}
+	}
+	return false
+}
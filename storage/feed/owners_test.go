@@ -0,0 +1,161 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package feed
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethersphere/swarm/storage/feed/lookup"
+)
+
+func TestOwnerSetAddress(t *testing.T) {
+	alice := newAliceSigner().Address()
+	bob := newBobSigner().Address()
+
+	a := NewOwnerSet(alice, bob).Address()
+	if b := NewOwnerSet(bob, alice, bob).Address(); a != b {
+		t.Fatalf("expected owner set address to not depend on order and duplicates, got %x and %x", a, b)
+	}
+	if a == alice || a == bob || a == NewOwnerSet(alice).Address() {
+		t.Fatalf("expected owner set address %x to differ from owners and other sets", a)
+	}
+}
+
+// TestMultiOwnerFeed checks that any owner in the owner set carried by an
+// update may update the feed of the owner set, and no one else
+func TestMultiOwnerFeed(t *testing.T) {
+	alice := newAliceSigner()
+	bob := newBobSigner()
+	charlie := newCharlieSigner()
+
+	owners := NewOwnerSet(alice.Address(), bob.Address())
+	user := owners.Address()
+	fh := NewHandler(&HandlerParams{})
+
+	topic, _ := NewTopic("team pointer", nil)
+	newRequest := func() *Request {
+		request := new(Request)
+		request.Feed = Feed{Topic: topic, User: user}
+		request.Epoch = lookup.Epoch{Time: 7888, Level: 6}
+		request.data = []byte("latest release")
+		return request
+	}
+	validate := func(t *testing.T, r *Request) bool {
+		t.Helper()
+		ch, err := r.toChunk()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fh.Validate(ch)
+	}
+
+	for _, signer := range []*GenericSigner{alice, bob} {
+		r := newRequest()
+		if err := r.SignAsOwner(signer, owners); err != nil {
+			t.Fatal(err)
+		}
+		if !validate(t, r) {
+			t.Fatalf("expected update of owner %x to be valid", signer.Address())
+		}
+		if r.User != user {
+			t.Fatalf("expected update of user %x, got %x", user, r.User)
+		}
+
+		// the owner set is recovered from the chunk by any node
+		ch, err := r.toChunk()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var recovered Request
+		if err := recovered.fromChunk(ch); err != nil {
+			t.Fatal(err)
+		}
+		if err := recovered.Verify(); err != nil {
+			t.Fatal(err)
+		}
+		if recovered.User != user || !bytes.Equal(recovered.data, r.data) || len(recovered.owners) != 2 {
+			t.Fatalf("expected update of user %x with data %q and 2 owners, got %x %q %v", user, r.data, recovered.User, recovered.data, recovered.owners)
+		}
+	}
+
+	if err := newRequest().SignAsOwner(charlie, owners); err == nil {
+		t.Fatal("expected signing by a non owner to fail")
+	}
+
+	// an update carrying an owner set the signer belongs to
+	// is not valid for the feed of another owner set
+	r := newRequest()
+	if err := r.SignAsOwner(charlie, NewOwnerSet(alice.Address(), charlie.Address())); err != nil {
+		t.Fatal(err)
+	}
+	r.Feed.User = user
+	r.binaryData = nil
+	if _, err := r.GetDigest(); err != nil {
+		t.Fatal(err)
+	}
+	r.idAddr = r.Addr()
+	if validate(t, r) {
+		t.Fatal("expected update carrying another owner set to be invalid")
+	}
+
+	// single owner feeds are unaffected
+	r = newRequest()
+	if err := r.Sign(charlie); err != nil {
+		t.Fatal(err)
+	}
+	if !validate(t, r) {
+		t.Fatal("expected single owner update to be valid")
+	}
+}
+
+// TestMultiOwnerFeedValues checks that the owner set survives
+// the serialization of update requests to query values and JSON
+func TestMultiOwnerFeedValues(t *testing.T) {
+	alice := newAliceSigner()
+	owners := NewOwnerSet(alice.Address(), newBobSigner().Address())
+	topic, _ := NewTopic("team pointer", nil)
+	r := new(Request)
+	r.Feed = Feed{Topic: topic, User: owners.Address()}
+	r.Epoch = lookup.Epoch{Time: 7888, Level: 6}
+	r.data = []byte("latest release")
+	if err := r.SignAsOwner(alice, owners); err != nil {
+		t.Fatal(err)
+	}
+
+	values := make(KV)
+	data := r.AppendValues(values)
+	var fromValues Request
+	if err := fromValues.FromValues(values, data); err != nil {
+		t.Fatal(err)
+	}
+	if err := fromValues.Verify(); err != nil {
+		t.Fatalf("expected update from values to be valid: %v", err)
+	}
+
+	j, err := r.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromJSON Request
+	if err := fromJSON.UnmarshalJSON(j); err != nil {
+		t.Fatal(err)
+	}
+	if err := fromJSON.Verify(); err != nil {
+		t.Fatalf("expected update from JSON to be valid: %v", err)
+	}
+}
@@ -19,7 +19,9 @@ package feed
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"hash"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -33,14 +35,18 @@ type Request struct {
 	Signature  *Signature
 	idAddr     storage.Address // cached chunk address for the update (not serialized, for internal use)
 	binaryData []byte          // cached serialized data (does not get serialized again!, for efficiency/internal use)
+	base       *deltaBase      // previous update the data can be encoded against, set by Handler.NewRequest
+	fullData   []byte          // full payload when data holds a delta (not serialized, for internal use)
 }
 
 // updateRequestJSON represents a JSON-serialized UpdateRequest
 type updateRequestJSON struct {
 	ID
-	ProtocolVersion uint8  `json:"protocolVersion"`
-	Data            string `json:"data,omitempty"`
-	Signature       string `json:"signature,omitempty"`
+	ProtocolVersion uint8            `json:"protocolVersion"`
+	Encoding        uint8            `json:"encoding,omitempty"`
+	Owners          []common.Address `json:"owners,omitempty"`
+	Data            string           `json:"data,omitempty"`
+	Signature       string           `json:"signature,omitempty"`
 }
 
 // Request layout
@@ -63,9 +69,21 @@ func NewFirstRequest(topic Topic) *Request {
 }
 
 // SetData stores the payload data the feed update will be updated with
+// If the request was prepared by a Handler with delta updates enabled, the data is stored
+// as a delta against the previous update whenever that is smaller, unless the request
+// is of a protocol version before delta encoding.
 func (r *Request) SetData(data []byte) {
 	r.data = data
+	r.fullData = nil
+	r.Header.Encoding = EncodingFull
 	r.Signature = nil
+	if r.base != nil && len(data) > 0 && r.Header.Version >= deltaEncodingVersion {
+		if delta := encodeDelta(r.base, data); len(delta) < len(data) {
+			r.data = delta
+			r.fullData = data
+			r.Header.Encoding = EncodingDelta
+		}
+	}
 }
 
 // IsUpdate returns true if this request models a signed update or otherwise it is a signature request
@@ -74,6 +92,7 @@ func (r *Request) IsUpdate() bool {
 }
 
 // Verify checks that signatures are valid
+// Updates of multi-owner feeds must be signed by one of the owners they carry
 func (r *Request) Verify() (err error) {
 	if len(r.data) == 0 {
 		return NewError(ErrInvalidValue, "Update does not contain data")
@@ -87,25 +106,66 @@ func (r *Request) Verify() (err error) {
 		return err
 	}
 
-	// get the address of the signer (which also checks that it's a valid signature)
-	r.Feed.User, err = getUserAddr(digest, *r.Signature)
-	if err != nil {
-		return err
-	}
+	user := r.Feed.User
+	var errs []string
+	for _, d := range r.signedDigests(digest) {
+		// get the address of the signer (which also checks that it's a valid signature)
+		r.Feed.User, err = getUserAddr(d, *r.Signature)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
 
-	// check that the lookup information contained in the chunk matches the updateAddr (chunk search key)
-	// that was used to retrieve this chunk
-	// if this validation fails, someone forged a chunk.
-	if !bytes.Equal(r.idAddr, r.Addr()) {
-		return NewError(ErrInvalidSignature, "Signature address does not match with update user address")
+		// check that the lookup information contained in the chunk matches the updateAddr (chunk search key)
+		// that was used to retrieve this chunk
+		// if this validation fails, someone forged a chunk.
+		if bytes.Equal(r.idAddr, r.Addr()) {
+			return nil
+		}
+
+		// the signer may be one of the owners of a multi-owner feed, whose
+		// user is the address of the owner set
+		if len(r.owners) > 0 && r.owners.Contains(r.Feed.User) && r.owners.Address() == user {
+			r.Feed.User = user
+			if bytes.Equal(r.idAddr, r.Addr()) {
+				return nil
+			}
+		}
+		errs = append(errs, fmt.Sprintf("signer %x is not the update user", r.Feed.User))
 	}
+	return NewErrorf(ErrInvalidSignature, "Signature address does not match with update user address: %s", strings.Join(errs, "; "))
+}
 
-	return nil
+// signedDigests returns the digests the update may be signed over: the digest
+// itself and, for updates of textSignatureVersion and later, the digest with
+// the Ethereum signed message prefix external signers apply
+func (r *Request) signedDigests(digest common.Hash) []common.Hash {
+	if r.Header.Version < textSignatureVersion {
+		return []common.Hash{digest}
+	}
+	return []common.Hash{digest, textDigest(digest)}
 }
 
 // Sign executes the signature to validate the update message
 func (r *Request) Sign(signer Signer) error {
-	r.Feed.User = signer.Address()
+	r.owners = nil
+	return r.sign(signer, signer.Address())
+}
+
+// SignAsOwner signs the update of the multi-owner feed of the owner set
+// the signer belongs to. The update carries the owner set.
+func (r *Request) SignAsOwner(signer Signer, owners OwnerSet) error {
+	owners = NewOwnerSet(owners...)
+	if !owners.Contains(signer.Address()) {
+		return NewError(ErrInvalidSignature, "Signer is not an owner of the feed")
+	}
+	r.owners = owners
+	return r.sign(signer, owners.Address())
+}
+
+// sign signs the update of the feed of user
+func (r *Request) sign(signer Signer, user common.Address) error {
+	r.Feed.User = user
 	r.binaryData = nil           //invalidate serialized data
 	digest, err := r.GetDigest() // computes digest and serializes into .binaryData
 	if err != nil {
@@ -119,18 +179,22 @@ func (r *Request) Sign(signer Signer) error {
 
 	// Although the Signer interface returns the public address of the signer,
 	// recover it from the signature to see if they match
-	userAddr, err := getUserAddr(digest, signature)
-	if err != nil {
-		return NewError(ErrInvalidSignature, "Error verifying signature")
-	}
-
-	if userAddr != signer.Address() { // sanity check to make sure the Signer is declaring the same address used to sign!
-		return NewError(ErrInvalidSignature, "Signer address does not match update user address")
+	var errs []string
+	for _, d := range r.signedDigests(digest) {
+		userAddr, err := getUserAddr(d, signature)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if userAddr != signer.Address() { // sanity check to make sure the Signer is declaring the same address used to sign!
+			errs = append(errs, fmt.Sprintf("signature of %x", userAddr))
+			continue
+		}
+		r.Signature = &signature
+		r.idAddr = r.Addr()
+		return nil
 	}
-
-	r.Signature = &signature
-	r.idAddr = r.Addr()
-	return nil
+	return NewErrorf(ErrInvalidSignature, "Signer address does not match update user address: %s", strings.Join(errs, "; "))
 }
 
 // GetDigest creates the feed update digest used in signatures
@@ -233,6 +297,8 @@ func (r *Request) fromJSON(j *updateRequestJSON) error {
 
 	r.ID = j.ID
 	r.Header.Version = j.ProtocolVersion
+	r.Header.Encoding = j.Encoding
+	r.owners = j.Owners
 
 	var err error
 	if j.Data != "" {
@@ -278,6 +344,8 @@ func (r *Request) MarshalJSON() (rawData []byte, err error) {
 	requestJSON := &updateRequestJSON{
 		ID:              r.ID,
 		ProtocolVersion: r.Header.Version,
+		Encoding:        r.Header.Encoding,
+		Owners:          r.owners,
 		Data:            dataString,
 		Signature:       signatureString,
 	}
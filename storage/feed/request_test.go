@@ -21,10 +21,15 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethersphere/swarm/storage"
 	"github.com/ethersphere/swarm/storage/feed/lookup"
 )
@@ -230,6 +235,17 @@ func TestUpdateChunkSerializationErrorChecking(t *testing.T) {
 	if !reflect.DeepEqual(recovered, r) {
 		t.Fatal("Expected recovered feed update request to equal the original one")
 	}
+
+	// updates of protocol versions before delta encoding are full payloads
+	r.Header.Encoding = EncodingDelta
+	if err := r.Sign(charlie); err == nil {
+		t.Fatal("expected signing a delta encoded update of an earlier protocol version to fail")
+	}
+	data := chunk.Data()
+	data[1] = EncodingDelta
+	if err := recovered.fromChunk(storage.NewChunk(chunk.Address(), data)); err == nil {
+		t.Fatal("expected parsing a delta encoded update of an earlier protocol version to fail")
+	}
 }
 
 // check that signature address matches update signer address
@@ -310,3 +326,136 @@ func TestReverse(t *testing.T) {
 		t.Fatalf("Expected data '%x', was '%x'", data, checkUpdate.data)
 	}
 }
+
+// fakeClef mimics the account_signData method of an external signer
+type fakeClef struct {
+	signer *GenericSigner
+}
+
+func (c *fakeClef) SignData(contentType string, addr common.MixedcaseAddress, data hexutil.Bytes) (hexutil.Bytes, error) {
+	if contentType != accounts.MimetypeTextPlain {
+		return nil, fmt.Errorf("unsupported content type %s", contentType)
+	}
+	if addr.Address() != c.signer.Address() {
+		return nil, fmt.Errorf("unknown account %s", addr.Address().Hex())
+	}
+	sig, err := crypto.Sign(accounts.TextHash(data), c.signer.PrivKey)
+	if err != nil {
+		return nil, err
+	}
+	sig[64] += 27
+	return sig, nil
+}
+
+// TestExternalSigners checks that updates signed beforehand or by an
+// external signer are accepted only for the user that signed them
+func TestExternalSigners(t *testing.T) {
+	alice := newAliceSigner()
+	bob := newBobSigner()
+
+	newRequest := func() *Request {
+		topic, _ := NewTopic("Cervantes quotes", nil)
+		request := new(Request)
+		request.Feed = Feed{
+			Topic: topic,
+			User:  alice.Address(),
+		}
+		request.Epoch = lookup.Epoch{Time: 7888, Level: 6}
+		request.Header.Version = ProtocolVersion
+		request.data = []byte("Donde una puerta se cierra, otra se abre")
+		return request
+	}
+
+	// verify signs the request and checks that the update chunk recovers to alice
+	verify := func(t *testing.T, signer Signer) {
+		t.Helper()
+		request := newRequest()
+		if err := request.Sign(signer); err != nil {
+			t.Fatal(err)
+		}
+		chunk, err := request.toChunk()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var checkUpdate Request
+		if err := checkUpdate.fromChunk(chunk); err != nil {
+			t.Fatal(err)
+		}
+		if err := checkUpdate.Verify(); err != nil {
+			t.Fatal(err)
+		}
+		if checkUpdate.User != alice.Address() {
+			t.Fatalf("expected user %x, got %x", alice.Address(), checkUpdate.User)
+		}
+	}
+
+	digest, err := newRequest().GetDigest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("presigned", func(t *testing.T) {
+		signature, err := alice.Sign(digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		verify(t, NewPresignedSigner(alice.Address(), signature))
+
+		// the signature does not match another user
+		if err := newRequest().Sign(NewPresignedSigner(bob.Address(), signature)); err == nil {
+			t.Fatal("expected signature of another user to fail")
+		}
+	})
+
+	t.Run("presigned with prefix", func(t *testing.T) {
+		sig, err := crypto.Sign(textDigest(digest).Bytes(), alice.PrivKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var signature Signature
+		copy(signature[:], sig)
+		verify(t, NewPresignedSigner(alice.Address(), signature))
+
+		// updates of earlier versions are only signed without the prefix
+		request := newRequest()
+		request.Header.Version = textSignatureVersion - 1
+		legacyDigest, err := request.GetDigest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig, err = crypto.Sign(textDigest(legacyDigest).Bytes(), alice.PrivKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		copy(signature[:], sig)
+		if err := request.Sign(NewPresignedSigner(alice.Address(), signature)); err == nil {
+			t.Fatal("expected a prefixed signature of an earlier version update to fail")
+		}
+	})
+
+	t.Run("clef", func(t *testing.T) {
+		server := rpc.NewServer()
+		defer server.Stop()
+		if err := server.RegisterName("account", &fakeClef{signer: alice}); err != nil {
+			t.Fatal(err)
+		}
+		srv := httptest.NewServer(server)
+		defer srv.Close()
+
+		signer, err := NewExternalSigner(srv.URL, alice.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer signer.Close()
+		verify(t, signer)
+
+		signer, err = NewExternalSigner(srv.URL, bob.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer signer.Close()
+		if err := newRequest().Sign(signer); err == nil {
+			t.Fatal("expected signing with an unknown account to fail")
+		}
+	})
+}
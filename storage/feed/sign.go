@@ -19,8 +19,11 @@ package feed
 import (
 	"crypto/ecdsa"
 
+	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 const signatureLength = 65
@@ -65,6 +68,85 @@ func (s *GenericSigner) Address() common.Address {
 	return s.address
 }
 
+// PresignedSigner implements the Signer interface for updates signed
+// by the client beforehand, so the private key never leaves the user
+type PresignedSigner struct {
+	address   common.Address
+	signature Signature
+}
+
+// NewPresignedSigner builds a signer that returns the provided signature of user
+// The signature is checked against the update digest when the request is signed
+func NewPresignedSigner(user common.Address, signature Signature) *PresignedSigner {
+	return &PresignedSigner{
+		address:   user,
+		signature: signature,
+	}
+}
+
+// Sign returns the provided signature
+func (s *PresignedSigner) Sign(data common.Hash) (Signature, error) {
+	return s.signature, nil
+}
+
+// Address returns the address of the user that signed the update
+func (s *PresignedSigner) Address() common.Address {
+	return s.address
+}
+
+// ExternalSigner implements the Signer interface using an external signer such as clef
+// External signers apply the Ethereum signed message prefix (EIP-191) to the digest,
+// signatures of prefixed digests are accepted by Verify
+type ExternalSigner struct {
+	client  *rpc.Client
+	address common.Address
+}
+
+// NewExternalSigner builds a signer that asks the external signer at endpoint to sign with the account address
+func NewExternalSigner(endpoint string, address common.Address) (*ExternalSigner, error) {
+	client, err := rpc.Dial(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &ExternalSigner{
+		client:  client,
+		address: address,
+	}, nil
+}
+
+// Sign requests a signature of the supplied data from the external signer
+func (s *ExternalSigner) Sign(data common.Hash) (signature Signature, err error) {
+	var res hexutil.Bytes
+	signAddress := common.NewMixedcaseAddress(s.address)
+	if err := s.client.Call(&res, "account_signData", accounts.MimetypeTextPlain, &signAddress, hexutil.Encode(data[:])); err != nil {
+		return signature, err
+	}
+	if len(res) != signatureLength {
+		return signature, NewError(ErrInvalidSignature, "External signer returned a signature of invalid length")
+	}
+	copy(signature[:], res)
+	// clef returns V in the 27/28 form
+	if signature[64] == 27 || signature[64] == 28 {
+		signature[64] -= 27
+	}
+	return signature, nil
+}
+
+// Address returns the address of the external signer account
+func (s *ExternalSigner) Address() common.Address {
+	return s.address
+}
+
+// Close closes the connection to the external signer
+func (s *ExternalSigner) Close() {
+	s.client.Close()
+}
+
+// textDigest returns the digest signed by signers that apply the Ethereum signed message prefix
+func textDigest(digest common.Hash) common.Hash {
+	return common.BytesToHash(accounts.TextHash(digest[:]))
+}
+
 // getUserAddr extracts the address of the feed update signer
 func getUserAddr(digest common.Hash, signature Signature) (common.Address, error) {
 	pub, err := crypto.SigToPub(digest.Bytes(), signature[:])
@@ -19,26 +19,45 @@ package feed
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethersphere/swarm/chunk"
 )
 
 // ProtocolVersion defines the current version of the protocol that will be included in each update message
-const ProtocolVersion uint8 = 0
+const ProtocolVersion uint8 = 2
+
+// textSignatureVersion is the first protocol version whose updates may be
+// signed with the Ethereum signed message prefix (EIP-191) by external signers
+const textSignatureVersion uint8 = 1
+
+// deltaEncodingVersion is the first protocol version whose updates may be
+// delta encoded, the encoding byte of the header is padding before
+const deltaEncodingVersion uint8 = 2
 
 const headerLength = 8
 
 // Header defines a update message header including a protocol version byte
+// The number of owners carried by updates of multi-owner feeds is
+// serialized in the byte following the encoding.
 type Header struct {
-	Version uint8                   // Protocol version
-	Padding [headerLength - 1]uint8 // reserved for future use
+	Version  uint8                   // Protocol version
+	Encoding uint8                   // Payload encoding, EncodingFull or EncodingDelta
+	Padding  [headerLength - 3]uint8 // reserved for future use
 }
 
 // Update encapsulates the information sent as part of a feed update
+// Update layout:
+// headerLength bytes
+// idLength bytes
+// len(owners) * common.AddressLength bytes
+// data bytes
 type Update struct {
-	Header Header //
-	ID            // Feed Update identifying information
-	data   []byte // actual data payload
+	Header Header   //
+	ID              // Feed Update identifying information
+	owners OwnerSet // owners of a multi-owner feed, empty for feeds of a single user
+	data   []byte   // actual data payload
 }
 
 const minimumUpdateDataLength = idLength + headerLength + 1
@@ -53,8 +72,16 @@ func (r *Update) binaryPut(serializedData []byte) error {
 		return NewError(ErrInvalidValue, "a feed update must contain data")
 	}
 
-	if datalength > MaxUpdateDataLength {
-		return NewErrorf(ErrInvalidValue, "feed update data is too big (length=%d). Max length=%d", datalength, MaxUpdateDataLength)
+	if len(r.owners) > MaxOwners {
+		return NewErrorf(ErrInvalidValue, "feed update has too many owners (count=%d). Max count=%d", len(r.owners), MaxOwners)
+	}
+
+	if r.Header.Encoding != EncodingFull && r.Header.Version < deltaEncodingVersion {
+		return NewErrorf(ErrInvalidValue, "feed update of protocol version %d can not be delta encoded", r.Header.Version)
+	}
+
+	if maxLength := MaxUpdateDataLength - len(r.owners)*common.AddressLength; datalength > maxLength {
+		return NewErrorf(ErrInvalidValue, "feed update data is too big (length=%d). Max length=%d", datalength, maxLength)
 	}
 
 	if len(serializedData) != r.binaryLength() {
@@ -64,7 +91,9 @@ func (r *Update) binaryPut(serializedData []byte) error {
 	var cursor int
 	// serialize Header
 	serializedData[cursor] = r.Header.Version
-	copy(serializedData[cursor+1:headerLength], r.Header.Padding[:headerLength-1])
+	serializedData[cursor+1] = r.Header.Encoding
+	serializedData[cursor+2] = uint8(len(r.owners))
+	copy(serializedData[cursor+3:headerLength], r.Header.Padding[:headerLength-3])
 	cursor += headerLength
 
 	// serialize ID
@@ -73,6 +102,12 @@ func (r *Update) binaryPut(serializedData []byte) error {
 	}
 	cursor += idLength
 
+	// serialize the owners
+	for _, owner := range r.owners {
+		copy(serializedData[cursor:cursor+common.AddressLength], owner[:])
+		cursor += common.AddressLength
+	}
+
 	// add the data
 	copy(serializedData[cursor:], r.data)
 	cursor += datalength
@@ -82,7 +117,7 @@ func (r *Update) binaryPut(serializedData []byte) error {
 
 // binaryLength returns the expected number of bytes this structure will take to encode
 func (r *Update) binaryLength() int {
-	return idLength + headerLength + len(r.data)
+	return idLength + headerLength + len(r.owners)*common.AddressLength + len(r.data)
 }
 
 // binaryGet populates this instance from the information contained in the passed byte slice
@@ -90,21 +125,36 @@ func (r *Update) binaryGet(serializedData []byte) error {
 	if len(serializedData) < minimumUpdateDataLength {
 		return NewErrorf(ErrNothingToReturn, "chunk less than %d bytes cannot be a feed update chunk", minimumUpdateDataLength)
 	}
-	dataLength := len(serializedData) - idLength - headerLength
+	ownersLength := int(serializedData[2]) * common.AddressLength
+	if len(serializedData) < minimumUpdateDataLength+ownersLength {
+		return NewErrorf(ErrNothingToReturn, "chunk less than %d bytes cannot be a feed update chunk with %d owners", minimumUpdateDataLength+ownersLength, serializedData[2])
+	}
+	dataLength := len(serializedData) - idLength - headerLength - ownersLength
 	// at this point we can be satisfied that we have the correct data length to read
 
 	var cursor int
 
 	// deserialize Header
 	r.Header.Version = serializedData[cursor]                                      // extract the protocol version
-	copy(r.Header.Padding[:headerLength-1], serializedData[cursor+1:headerLength]) // extract the padding
+	r.Header.Encoding = serializedData[cursor+1]                                   // extract the payload encoding
+	copy(r.Header.Padding[:headerLength-3], serializedData[cursor+3:headerLength]) // extract the padding
 	cursor += headerLength
 
+	if r.Header.Encoding != EncodingFull && r.Header.Version < deltaEncodingVersion {
+		return NewErrorf(ErrInvalidValue, "feed update of protocol version %d can not be delta encoded", r.Header.Version)
+	}
+
 	if err := r.ID.binaryGet(serializedData[cursor : cursor+idLength]); err != nil {
 		return err
 	}
 	cursor += idLength
 
+	r.owners = nil
+	for i := 0; i < ownersLength; i += common.AddressLength {
+		r.owners = append(r.owners, common.BytesToAddress(serializedData[cursor:cursor+common.AddressLength]))
+		cursor += common.AddressLength
+	}
+
 	data := serializedData[cursor : cursor+dataLength]
 	cursor += dataLength
 
@@ -122,6 +172,17 @@ func (r *Update) FromValues(values Values, data []byte) error {
 	r.data = data
 	version, _ := strconv.ParseUint(values.Get("protocolVersion"), 10, 32)
 	r.Header.Version = uint8(version)
+	encoding, _ := strconv.ParseUint(values.Get("encoding"), 10, 8)
+	r.Header.Encoding = uint8(encoding)
+	r.owners = nil
+	if owners := values.Get("owners"); owners != "" {
+		for _, owner := range strings.Split(owners, ",") {
+			if !common.IsHexAddress(owner) {
+				return NewErrorf(ErrInvalidValue, "invalid feed owner %q", owner)
+			}
+			r.owners = append(r.owners, common.HexToAddress(owner))
+		}
+	}
 	return r.ID.FromValues(values)
 }
 
@@ -130,5 +191,15 @@ func (r *Update) FromValues(values Values, data []byte) error {
 func (r *Update) AppendValues(values Values) []byte {
 	r.ID.AppendValues(values)
 	values.Set("protocolVersion", fmt.Sprintf("%d", r.Header.Version))
+	if r.Header.Encoding != EncodingFull {
+		values.Set("encoding", fmt.Sprintf("%d", r.Header.Encoding))
+	}
+	if len(r.owners) > 0 {
+		owners := make([]string, len(r.owners))
+		for i, owner := range r.owners {
+			owners[i] = owner.Hex()
+		}
+		values.Set("owners", strings.Join(owners, ","))
+	}
 	return r.data
 }
@@ -42,13 +42,14 @@ type hasherStore struct {
 	toEncrypt bool
 	doWait    sync.Once
 	hashFunc  SwarmHasher
-	hashSize  int           // content hash size
-	refSize   int64         // reference size (content hash + possibly encryption key)
-	errC      chan error    // global error channel
-	waitC     chan error    // global wait channel
-	doneC     chan struct{} // closed by Close() call to indicate that count is the final number of chunks
-	quitC     chan struct{} // closed to quit unterminated routines
-	workers   chan Chunk    // back pressure for limiting storage workers goroutines
+	hashSize  int                            // content hash size
+	refSize   int64                          // reference size (content hash + possibly encryption key)
+	errC      chan error                     // global error channel
+	waitC     chan error                     // global wait channel
+	doneC     chan struct{}                  // closed by Close() call to indicate that count is the final number of chunks
+	quitC     chan struct{}                  // closed to quit unterminated routines
+	workers   chan Chunk                     // back pressure for limiting storage workers goroutines
+	keyFunc   func(ChunkData) encryption.Key // derives encryption keys from chunk data, random keys are used if nil
 }
 
 // NewHasherStore creates a hasherStore object, which implements Putter and Getter interfaces.
@@ -77,6 +78,16 @@ func NewHasherStore(store ChunkStore, hashFunc SwarmHasher, toEncrypt bool, tag
 	return h
 }
 
+// NewDeterministicHasherStore creates an encrypting hasherStore which derives the encryption key
+// of every chunk from its plain chunk data with keyFunc and pads the data with encrypted zeros
+// instead of random bytes, so that the same content always results in the same references.
+// Equal chunks get equal references, it is meant for reproducible test vectors, not for uploads.
+func NewDeterministicHasherStore(store ChunkStore, hashFunc SwarmHasher, keyFunc func(ChunkData) encryption.Key, tag *chunk.Tag) *hasherStore {
+	h := NewHasherStore(store, hashFunc, true, tag)
+	h.keyFunc = keyFunc
+	return h
+}
+
 // Put stores the chunkData into the ChunkStore of the hasherStore and returns the reference.
 // If hasherStore has a chunkEncryption object, the data will be encrypted.
 // Asynchronous function, the data will not necessarily be stored when it returns.
@@ -134,10 +145,10 @@ func (h *hasherStore) Close() {
 }
 
 // Wait returns when
-//    1) the Close() function has been called and
-//    2) all the chunks which has been Put has been stored
-//    OR
-//    1) if there is error while storing chunk
+//  1. the Close() function has been called and
+//  2. all the chunks which has been Put has been stored
+//     OR
+//  1. if there is error while storing chunk
 func (h *hasherStore) Wait(ctx context.Context) error {
 	defer close(h.quitC)
 	err := <-h.waitC
@@ -240,12 +251,21 @@ func (h *hasherStore) RefSize() int64 {
 }
 
 func (h *hasherStore) encrypt(chunkData ChunkData) (encryption.Key, []byte, []byte, error) {
-	key := encryption.GenerateRandomKey(encryption.KeyLength)
+	var key encryption.Key
+	data := []byte(chunkData[8:])
+	if h.keyFunc != nil {
+		key = h.keyFunc(chunkData)
+		// zero padding is encrypted together with the data, otherwise random padding is appended
+		data = make([]byte, chunk.DefaultSize)
+		copy(data, chunkData[8:])
+	} else {
+		key = encryption.GenerateRandomKey(encryption.KeyLength)
+	}
 	encryptedSpan, err := h.newSpanEncryption(key).Encrypt(chunkData[:8])
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	encryptedData, err := h.newDataEncryption(key).Encrypt(chunkData[8:])
+	encryptedData, err := h.newDataEncryption(key).Encrypt(data)
 	if err != nil {
 		return nil, nil, nil, err
 	}
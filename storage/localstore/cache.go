@@ -0,0 +1,106 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/shed"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// chunkCache keeps recently retrieved items of the retrieval data index in
+// memory, so that popular chunks are not read from leveldb on every request.
+// Items are invalidated when chunks are removed by garbage collection or
+// by ModeSetRemove. A nil *chunkCache is valid and caches nothing.
+type chunkCache struct {
+	mu    sync.Mutex
+	items *lru.ARCCache
+	// generation is incremented on every invalidation, to prevent adding
+	// items that were read from the database before they were removed
+	generation uint64
+}
+
+// newChunkCache returns a cache for capacity chunks,
+// or nil if capacity is zero
+func newChunkCache(capacity int) (*chunkCache, error) {
+	if capacity <= 0 {
+		return nil, nil
+	}
+	items, err := lru.NewARC(capacity)
+	if err != nil {
+		return nil, err
+	}
+	return &chunkCache{
+		items: items,
+	}, nil
+}
+
+// get returns the cached retrieval data index item for the address
+func (c *chunkCache) get(addr chunk.Address) (item shed.Item, ok bool) {
+	if c == nil {
+		return item, false
+	}
+	v, ok := c.items.Get(string(addr))
+	if !ok {
+		metrics.GetOrRegisterCounter("localstore.cache.miss", nil).Inc(1)
+		return item, false
+	}
+	metrics.GetOrRegisterCounter("localstore.cache.hit", nil).Inc(1)
+	return v.(shed.Item), true
+}
+
+// gen returns the current cache generation, which must be
+// acquired before the item passed to add is read from the database
+func (c *chunkCache) gen() uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// add caches the retrieval data index item if no items were
+// invalidated since the generation gen was acquired
+func (c *chunkCache) add(item shed.Item, gen uint64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation != gen {
+		return
+	}
+	c.items.Add(string(item.Address), item)
+}
+
+// remove invalidates cached items for the addresses
+func (c *chunkCache) remove(addrs ...chunk.Address) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	for _, addr := range addrs {
+		c.items.Remove(string(addr))
+	}
+	metrics.GetOrRegisterGauge("localstore.cache.size", nil).Update(int64(c.items.Len()))
+}
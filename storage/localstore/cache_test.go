@@ -0,0 +1,149 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethersphere/swarm/chunk"
+)
+
+// TestDB_cache validates that retrieved chunks are cached
+// and that the cache is invalidated on removal.
+func TestDB_cache(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, &Options{
+		CacheCapacity: 10,
+	})
+	defer cleanupFunc()
+
+	ch := generateTestRandomChunk()
+
+	_, err := db.Put(context.Background(), chunk.ModePutUpload, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := db.cache.get(ch.Address()); ok {
+		t.Fatal("chunk cached before it is retrieved")
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err = db.Get(context.Background(), chunk.ModeGetRequest, ch.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := db.cache.get(ch.Address()); !ok {
+			t.Fatal("retrieved chunk not cached")
+		}
+	}
+
+	err = db.Set(context.Background(), chunk.ModeSetRemove, ch.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := db.cache.get(ch.Address()); ok {
+		t.Fatal("removed chunk cached")
+	}
+	_, err = db.Get(context.Background(), chunk.ModeGetRequest, ch.Address())
+	if err != chunk.ErrChunkNotFound {
+		t.Fatalf("got error %v, want %v", err, chunk.ErrChunkNotFound)
+	}
+}
+
+// TestDB_cacheGarbageCollection validates that chunks removed by
+// garbage collection are not returned from the cache, even if they are
+// retrieved while garbage collection runs.
+func TestDB_cacheGarbageCollection(t *testing.T) {
+	chunkCount := 150
+
+	db, cleanupFunc := newTestDB(t, &Options{
+		Capacity:      100,
+		CacheCapacity: 1000,
+	})
+	testHookCollectGarbageChan := make(chan uint64)
+	defer setTestHookCollectGarbage(func(collectedCount uint64) {
+		select {
+		case testHookCollectGarbageChan <- collectedCount:
+		case <-db.close:
+		}
+	})()
+	defer cleanupFunc()
+
+	addrs := make([]chunk.Address, 0)
+
+	for i := 0; i < chunkCount; i++ {
+		ch := generateTestRandomChunk()
+
+		_, err := db.Put(context.Background(), chunk.ModePutUpload, ch)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = db.Set(context.Background(), chunk.ModeSetSyncPull, ch.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		addrs = append(addrs, ch.Address())
+
+		// retrieve all chunks uploaded so far to fill the cache
+		// while garbage collection is running
+		for _, a := range addrs {
+			_, err = db.Get(context.Background(), chunk.ModeGetLookup, a)
+			if err != nil && err != chunk.ErrChunkNotFound {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	gcTarget := db.gcTarget()
+
+	for {
+		select {
+		case <-testHookCollectGarbageChan:
+		case <-time.After(10 * time.Second):
+			t.Fatal("collect garbage timeout")
+		}
+		gcSize, err := db.gcSize.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gcSize == gcTarget {
+			break
+		}
+	}
+
+	var collected int
+	for _, a := range addrs {
+		_, err := db.retrievalDataIndex.Get(addressToItem(a))
+		if err == nil {
+			continue
+		}
+		collected++
+		if _, ok := db.cache.get(a); ok {
+			t.Errorf("garbage collected chunk %s cached", a)
+		}
+		_, err = db.Get(context.Background(), chunk.ModeGetLookup, a)
+		if err != chunk.ErrChunkNotFound {
+			t.Errorf("got error %v for garbage collected chunk %s, want %v", err, a, chunk.ErrChunkNotFound)
+		}
+	}
+	if collected == 0 {
+		t.Error("no chunks garbage collected")
+	}
+}
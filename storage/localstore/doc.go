@@ -17,7 +17,8 @@
 /*
 Package localstore provides disk storage layer for Swarm Chunk persistence.
 It uses swarm/shed abstractions on top of github.com/syndtr/goleveldb LevelDB
-implementation.
+implementation, or on top of the sharded file storage engine that keeps large
+chunk data out of LevelDB.
 
 The main type is DB which manages the storage by providing methods to
 access and add Chunks and to manage their status.
@@ -0,0 +1,96 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"time"
+
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/shed"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// Expiry returns the time to live hint of a locally uploaded chunk.
+// Zero time is returned if the chunk was uploaded without a hint.
+// If the chunk is not in the database, chunk.ErrChunkNotFound is returned.
+func (db *DB) Expiry(addr chunk.Address) (expiry time.Time, err error) {
+	item := addressToItem(addr)
+
+	has, err := db.retrievalDataIndex.Has(item)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !has {
+		return time.Time{}, chunk.ErrChunkNotFound
+	}
+	item, err = db.expiryIndex.Get(item)
+	switch err {
+	case nil:
+		return time.Unix(0, item.Expiry), nil
+	case leveldb.ErrNotFound:
+		return time.Time{}, nil
+	default:
+		return time.Time{}, err
+	}
+}
+
+// expiredGCItems returns at most limit items from the gc index
+// with a time to live hint earlier than the provided timestamp,
+// keyed by their address. Pinned chunks and chunks that are not
+// yet synced are not in the gc index and are never returned.
+func (db *DB) expiredGCItems(ts int64, limit uint64) (items map[string]shed.Item, err error) {
+	items = make(map[string]shed.Item)
+	if limit == 0 {
+		return items, nil
+	}
+	err = db.expiryIndex.Iterate(func(item shed.Item) (stop bool, err error) {
+		if item.Expiry > ts {
+			return false, nil
+		}
+		i, err := db.retrievalDataIndex.Get(item)
+		if err != nil {
+			if err == leveldb.ErrNotFound {
+				return false, nil
+			}
+			return true, err
+		}
+		item.StoreTimestamp = i.StoreTimestamp
+		item.BinID = i.BinID
+
+		i, err = db.retrievalAccessIndex.Get(item)
+		if err != nil {
+			if err == leveldb.ErrNotFound {
+				return false, nil
+			}
+			return true, err
+		}
+		item.AccessTimestamp = i.AccessTimestamp
+
+		has, err := db.gcIndex.Has(item)
+		if err != nil {
+			return true, err
+		}
+		if has {
+			items[string(item.Address)] = item
+		}
+		return uint64(len(items)) >= limit, nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -17,10 +17,13 @@
 package localstore
 
 import (
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/shed"
 	"github.com/syndtr/goleveldb/leveldb"
 )
@@ -78,6 +81,12 @@ func (db *DB) collectGarbageWorker() {
 // the rest of the garbage as the batch size limit is reached.
 // This function is called in collectGarbageWorker.
 func (db *DB) collectGarbage() (collectedCount uint64, done bool, err error) {
+	return db.collectGarbageToTarget(db.gcTarget(), gcBatchSize)
+}
+
+// collectGarbageToTarget removes chunks from the gc index until
+// its size drops to target or batchSize chunks are removed.
+func (db *DB) collectGarbageToTarget(target, batchSize uint64) (collectedCount uint64, done bool, err error) {
 	metricName := "localstore.gc"
 	metrics.GetOrRegisterCounter(metricName, nil).Inc(1)
 	defer totalTimeMetric(metricName, time.Now())
@@ -87,8 +96,8 @@ func (db *DB) collectGarbage() (collectedCount uint64, done bool, err error) {
 		}
 	}()
 
+	start := time.Now()
 	batch := new(leveldb.Batch)
-	target := db.gcTarget()
 
 	// protect database from changing idexes and gcSize
 	db.batchMu.Lock()
@@ -108,11 +117,52 @@ func (db *DB) collectGarbage() (collectedCount uint64, done bool, err error) {
 	}
 	metrics.GetOrRegisterGauge(metricName+".gcsize", nil).Update(int64(gcSize))
 
+	var collected []chunk.Address
+	usage := make(usageChanges)
 	done = true
+
+	// chunks with a time to live hint expired by more than the clock
+	// tolerance are collected before the least recently accessed ones
+	var limit uint64
+	if gcSize > target {
+		limit = gcSize - target
+	}
+	if limit > batchSize {
+		limit = batchSize
+	}
+	expired, err := db.expiredGCItems(now()-int64(db.clockTolerance), limit)
+	if err != nil {
+		return 0, true, err
+	}
+	for _, item := range expired {
+		db.retrievalDataIndex.DeleteInBatch(batch, item)
+		db.retrievalAccessIndex.DeleteInBatch(batch, item)
+		db.pullIndex.DeleteInBatch(batch, item)
+		db.gcIndex.DeleteInBatch(batch, item)
+		db.expiryIndex.DeleteInBatch(batch, item)
+		if err := db.removeOwnerInBatch(batch, item, usage); err != nil {
+			return 0, true, err
+		}
+		collectedCount++
+		if db.events != nil || db.cache != nil {
+			collected = append(collected, chunk.Address(item.Address))
+		}
+	}
+	metrics.GetOrRegisterCounter(metricName+".expired-count", nil).Inc(int64(len(expired)))
+	if collectedCount >= batchSize {
+		// bach size limit reached,
+		// another gc run is needed
+		done = false
+	}
+
 	err = db.gcIndex.Iterate(func(item shed.Item) (stop bool, err error) {
-		if gcSize-collectedCount <= target {
+		if gcSize-collectedCount <= target || !done {
 			return true, nil
 		}
+		if _, ok := expired[string(item.Address)]; ok {
+			// already collected as expired
+			return false, nil
+		}
 
 		metrics.GetOrRegisterGauge(metricName+".storets", nil).Update(item.StoreTimestamp)
 		metrics.GetOrRegisterGauge(metricName+".accessts", nil).Update(item.AccessTimestamp)
@@ -122,8 +172,15 @@ func (db *DB) collectGarbage() (collectedCount uint64, done bool, err error) {
 		db.retrievalAccessIndex.DeleteInBatch(batch, item)
 		db.pullIndex.DeleteInBatch(batch, item)
 		db.gcIndex.DeleteInBatch(batch, item)
+		db.expiryIndex.DeleteInBatch(batch, item)
+		if err := db.removeOwnerInBatch(batch, item, usage); err != nil {
+			return true, err
+		}
 		collectedCount++
-		if collectedCount >= gcBatchSize {
+		if db.events != nil || db.cache != nil {
+			collected = append(collected, chunk.Address(item.Address))
+		}
+		if collectedCount >= batchSize {
 			// bach size limit reached,
 			// another gc run is needed
 			done = false
@@ -138,11 +195,15 @@ func (db *DB) collectGarbage() (collectedCount uint64, done bool, err error) {
 
 	db.gcSize.PutInBatch(batch, gcSize-collectedCount)
 
-	err = db.shed.WriteBatch(batch)
+	err = db.writeBatch(batch)
 	if err != nil {
 		metrics.GetOrRegisterCounter(metricName+".writebatch.err", nil).Inc(1)
 		return 0, false, err
 	}
+	db.usage.add(usage)
+	db.cache.remove(collected...)
+	db.events.Emit(chunk.EventRemoved, "gc", collected...)
+	db.gcStats.record(start, collectedCount, uint64(len(expired)))
 	return collectedCount, done, nil
 }
 
@@ -199,7 +260,7 @@ func (db *DB) removeChunksInExcludeIndexFromGC() (err error) {
 	}
 
 	metrics.GetOrRegisterCounter(metricName+".excluded-count", nil).Inc(int64(excludedCount))
-	err = db.shed.WriteBatch(batch)
+	err = db.writeBatch(batch)
 	if err != nil {
 		metrics.GetOrRegisterCounter(metricName+".writebatch.err", nil).Inc(1)
 		return err
@@ -208,10 +269,114 @@ func (db *DB) removeChunksInExcludeIndexFromGC() (err error) {
 	return nil
 }
 
+// CollectGarbage removes at most limit chunks from the gc index,
+// expired ones first and then the least recently accessed, regardless
+// of the database capacity. Pinned chunks are not removed. It returns
+// the number of removed chunks, which is less then limit only if the
+// gc index is exhausted. A limit of 0 is rejected with ErrInvalidGCLimit.
+func (db *DB) CollectGarbage(limit uint64) (collected uint64, err error) {
+	if db.readOnly {
+		return 0, ErrReadOnly
+	}
+	if limit == 0 {
+		return 0, ErrInvalidGCLimit
+	}
+	for collected < limit {
+		batchSize := limit - collected
+		if batchSize > gcBatchSize {
+			batchSize = gcBatchSize
+		}
+		c, done, err := db.collectGarbageToTarget(0, batchSize)
+		collected += c
+		if err != nil {
+			return collected, err
+		}
+		if done {
+			break
+		}
+	}
+	return collected, nil
+}
+
+// GCStats holds garbage collection counters since the database
+// was opened and the current gc index size.
+type GCStats struct {
+	Runs            uint64        // number of gc batches
+	Collected       uint64        // number of removed chunks
+	Expired         uint64        // number of removed chunks with an expired time to live hint
+	LastRun         time.Time     // start time of the last gc batch, zero if none
+	LastRunDuration time.Duration // duration of the last gc batch
+	LastCollected   uint64        // number of chunks removed in the last gc batch
+	Size            uint64        // number of chunks in the gc index
+	Target          uint64        // gc index size that garbage collection reduces to
+	Capacity        uint64        // gc index size that triggers garbage collection
+}
+
+// GCStats returns garbage collection counters and sizes.
+func (db *DB) GCStats() (stats GCStats, err error) {
+	stats = db.gcStats.get()
+	stats.Size, err = db.gcSize.Get()
+	if err != nil {
+		return stats, err
+	}
+	stats.Target = db.gcTarget()
+	stats.Capacity = db.getCapacity()
+	return stats, nil
+}
+
+// gcStats accumulates GCStats counters of gc batches.
+type gcStats struct {
+	mu    sync.Mutex
+	stats GCStats
+}
+
+// record updates counters with a gc batch started at start.
+func (s *gcStats) record(start time.Time, collected, expired uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.Runs++
+	s.stats.Collected += collected
+	s.stats.Expired += expired
+	s.stats.LastRun = start
+	s.stats.LastRunDuration = time.Since(start)
+	s.stats.LastCollected = collected
+}
+
+func (s *gcStats) get() GCStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stats
+}
+
 // gcTrigger retruns the absolute value for garbage collection
 // target value, calculated from db.capacity and gcTargetRatio.
 func (db *DB) gcTarget() (target uint64) {
-	return uint64(float64(db.capacity) * gcTargetRatio)
+	return uint64(float64(db.getCapacity()) * gcTargetRatio)
+}
+
+// getCapacity returns the capacity that triggers garbage collection.
+func (db *DB) getCapacity() uint64 {
+	return atomic.LoadUint64(&db.capacity)
+}
+
+// SetCapacity changes the garbage collection index size that triggers
+// garbage collection while the database is open. Garbage collection is
+// triggered immediately if the index is already over the new capacity.
+func (db *DB) SetCapacity(capacity uint64) error {
+	if capacity == 0 {
+		capacity = defaultCapacity
+	}
+	atomic.StoreUint64(&db.capacity, capacity)
+	gcSize, err := db.gcSize.Get()
+	if err != nil {
+		return err
+	}
+	if gcSize >= capacity {
+		db.triggerGarbageCollection()
+	}
+	return nil
 }
 
 // triggerGarbageCollection signals collectGarbageWorker
@@ -252,7 +417,7 @@ func (db *DB) incGCSizeInBatch(batch *leveldb.Batch, change int64) (err error) {
 	db.gcSize.PutInBatch(batch, new)
 
 	// trigger garbage collection if we reached the capacity
-	if new >= db.capacity {
+	if new >= db.getCapacity() {
 		db.triggerGarbageCollection()
 	}
 	return nil
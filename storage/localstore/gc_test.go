@@ -134,6 +134,283 @@ func testDBCollectGarbageWorker(t *testing.T) {
 	})
 }
 
+// TestDB_collectGarbageExpired validates that chunks with an expired
+// time to live hint are garbage collected before the least recently
+// accessed ones and that the hint can be read for stored chunks.
+func TestDB_collectGarbageExpired(t *testing.T) {
+	tags := chunk.NewTags()
+	db, cleanupFunc := newTestDB(t, &Options{
+		Capacity: 1000,
+		Tags:     tags,
+	})
+	defer cleanupFunc()
+
+	expiredTag, err := tags.Create("expired", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiredTag.Expiry = time.Now().Add(-time.Minute)
+	liveTag, err := tags.Create("live", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	liveTag.Expiry = time.Now().Add(time.Hour)
+
+	upload := func(count int, tagID uint32) (addrs []chunk.Address) {
+		for i := 0; i < count; i++ {
+			ch := generateTestRandomChunk().WithTagID(tagID)
+			if _, err := db.Put(context.Background(), chunk.ModePutUpload, ch); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.Set(context.Background(), chunk.ModeSetSyncPull, ch.Address()); err != nil {
+				t.Fatal(err)
+			}
+			addrs = append(addrs, ch.Address())
+		}
+		return addrs
+	}
+
+	persistent := upload(90, 0)
+	live := upload(10, liveTag.Uid)
+	// expired chunks are the most recently accessed ones
+	expired := upload(10, expiredTag.Uid)
+
+	expiry, err := db.Expiry(live[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expiry.Equal(liveTag.Expiry) {
+		t.Errorf("got expiry %v, want %v", expiry, liveTag.Expiry)
+	}
+	expiry, err = db.Expiry(persistent[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expiry.IsZero() {
+		t.Errorf("got expiry %v, want zero time", expiry)
+	}
+
+	// lower the capacity to collect 20 chunks with target 90
+	db.capacity = 100
+	collectedCount, done, err := db.collectGarbage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !done {
+		t.Fatal("expected garbage collection to be done")
+	}
+	if collectedCount != 20 {
+		t.Fatalf("got collected count %v, want 20", collectedCount)
+	}
+
+	for _, addr := range expired {
+		_, err := db.Get(context.Background(), chunk.ModeGetRequest, addr)
+		if err != chunk.ErrChunkNotFound {
+			t.Errorf("got error %v, want %v", err, chunk.ErrChunkNotFound)
+		}
+	}
+	for i, addr := range persistent {
+		_, err := db.Get(context.Background(), chunk.ModeGetRequest, addr)
+		if i < 10 && err != chunk.ErrChunkNotFound {
+			t.Errorf("chunk %v: got error %v, want %v", i, err, chunk.ErrChunkNotFound)
+		}
+		if i >= 10 && err != nil {
+			t.Errorf("chunk %v: %v", i, err)
+		}
+	}
+	for _, addr := range live {
+		if _, err := db.Get(context.Background(), chunk.ModeGetRequest, addr); err != nil {
+			t.Error(err)
+		}
+	}
+
+	t.Run("expiry index count", newItemsCountTest(db.expiryIndex, len(live)))
+}
+
+// TestDB_collectGarbageExpiredClockTolerance validates that chunks with
+// a time to live hint expired within the clock tolerance are collected
+// as the least recently accessed ones.
+func TestDB_collectGarbageExpiredClockTolerance(t *testing.T) {
+	tags := chunk.NewTags()
+	db, cleanupFunc := newTestDB(t, &Options{
+		Capacity:       1000,
+		Tags:           tags,
+		ClockTolerance: time.Hour,
+	})
+	defer cleanupFunc()
+
+	expiredTag, err := tags.Create("expired", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiredTag.Expiry = time.Now().Add(-time.Minute)
+
+	var persistent, expired []chunk.Address
+	for i := 0; i < 100; i++ {
+		ch := generateTestRandomChunk()
+		if i >= 90 {
+			ch = ch.WithTagID(expiredTag.Uid)
+		}
+		if _, err := db.Put(context.Background(), chunk.ModePutUpload, ch); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Set(context.Background(), chunk.ModeSetSyncPull, ch.Address()); err != nil {
+			t.Fatal(err)
+		}
+		if i >= 90 {
+			expired = append(expired, ch.Address())
+		} else {
+			persistent = append(persistent, ch.Address())
+		}
+	}
+
+	// lower the capacity to collect 10 chunks with target 90
+	db.capacity = 100
+	collectedCount, _, err := db.collectGarbage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if collectedCount != 10 {
+		t.Fatalf("got collected count %v, want 10", collectedCount)
+	}
+	for _, addr := range expired {
+		if _, err := db.Get(context.Background(), chunk.ModeGetRequest, addr); err != nil {
+			t.Error(err)
+		}
+	}
+	for _, addr := range persistent[:10] {
+		if _, err := db.Get(context.Background(), chunk.ModeGetRequest, addr); err != chunk.ErrChunkNotFound {
+			t.Errorf("got error %v, want %v", err, chunk.ErrChunkNotFound)
+		}
+	}
+}
+
+// TestDB_CollectGarbage validates that forced garbage collection
+// removes no more than the requested number of chunks, leaves
+// pinned chunks and is reported in gc stats.
+func TestDB_CollectGarbage(t *testing.T) {
+	defer func(s uint64) { gcBatchSize = s }(gcBatchSize)
+	gcBatchSize = 3
+
+	db, cleanupFunc := newTestDB(t, &Options{
+		Capacity: 100,
+	})
+	defer cleanupFunc()
+
+	var addrs []chunk.Address
+	for i := 0; i < 50; i++ {
+		ch := generateTestRandomChunk()
+		if _, err := db.Put(context.Background(), chunk.ModePutUpload, ch); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Set(context.Background(), chunk.ModeSetSyncPull, ch.Address()); err != nil {
+			t.Fatal(err)
+		}
+		if i < 10 {
+			if err := db.Set(context.Background(), chunk.ModeSetPin, ch.Address()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		addrs = append(addrs, ch.Address())
+	}
+
+	collected, err := db.CollectGarbage(25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if collected != 25 {
+		t.Fatalf("got collected count %v, want 25", collected)
+	}
+	stats, err := db.GCStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Runs != 9 || stats.Collected != 25 || stats.LastCollected != 1 || stats.LastRun.IsZero() {
+		t.Errorf("got stats %+v, want 9 runs collecting 25 chunks, 1 in the last run", stats)
+	}
+	if stats.Size != 15 || stats.Target != 90 || stats.Capacity != 100 {
+		t.Errorf("got size %v, target %v, capacity %v, want 15, 90, 100", stats.Size, stats.Target, stats.Capacity)
+	}
+
+	if _, err := db.CollectGarbage(0); err != ErrInvalidGCLimit {
+		t.Fatalf("got error %v for a limit of 0, want %v", err, ErrInvalidGCLimit)
+	}
+
+	// requesting more than there is in the gc index
+	collected, err = db.CollectGarbage(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if collected != 15 {
+		t.Fatalf("got collected count %v, want 15", collected)
+	}
+	for i, addr := range addrs {
+		_, err := db.Get(context.Background(), chunk.ModeGetRequest, addr)
+		if i < 10 && err != nil {
+			t.Errorf("pinned chunk %v: %v", i, err)
+		}
+		if i >= 10 && err != chunk.ErrChunkNotFound {
+			t.Errorf("chunk %v: got error %v, want %v", i, err, chunk.ErrChunkNotFound)
+		}
+	}
+	t.Run("gc size", newIndexGCSizeTest(db))
+}
+
+// TestDB_SetCapacity checks that lowering the capacity of an open
+// database garbage collects chunks down to the new target.
+func TestDB_SetCapacity(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, &Options{
+		Capacity: 100,
+	})
+	testHookCollectGarbageChan := make(chan uint64)
+	defer setTestHookCollectGarbage(func(collectedCount uint64) {
+		select {
+		case testHookCollectGarbageChan <- collectedCount:
+		case <-db.close:
+		}
+	})()
+	defer cleanupFunc()
+
+	for i := 0; i < 50; i++ {
+		ch := generateTestRandomChunk()
+		if _, err := db.Put(context.Background(), chunk.ModePutUpload, ch); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Set(context.Background(), chunk.ModeSetSyncPull, ch.Address()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := db.SetCapacity(40); err != nil {
+		t.Fatal(err)
+	}
+	gcTarget := db.gcTarget()
+	if gcTarget != 36 {
+		t.Fatalf("got gc target %v, want 36", gcTarget)
+	}
+	for {
+		select {
+		case <-testHookCollectGarbageChan:
+		case <-time.After(10 * time.Second):
+			t.Fatal("collect garbage timeout")
+		}
+		gcSize, err := db.gcSize.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gcSize == gcTarget {
+			break
+		}
+	}
+	stats, err := db.GCStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Capacity != 40 {
+		t.Errorf("got capacity %v, want 40", stats.Capacity)
+	}
+}
+
 // Pin a file, upload chunks to go past the gc limit to trigger GC,
 // check if the pinned files are still around and removed from gcIndex
 func TestPinGC(t *testing.T) {
@@ -291,6 +568,80 @@ func TestGCAfterPin(t *testing.T) {
 	}
 }
 
+// TestGCPinAccessUnpin checks that accessing pinned chunks does not make
+// them collectable and that they are collectable again when unpinned.
+func TestGCPinAccessUnpin(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, &Options{
+		Capacity: 100,
+	})
+	defer cleanupFunc()
+
+	ctx := context.Background()
+	var addrs []chunk.Address
+	for i := 0; i < 10; i++ {
+		ch := generateTestRandomChunk()
+		if _, err := db.Put(ctx, chunk.ModePutUpload, ch); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Set(ctx, chunk.ModeSetSyncPull, ch.Address()); err != nil {
+			t.Fatal(err)
+		}
+		addrs = append(addrs, ch.Address())
+	}
+	// pin the first half of the chunks twice, as if they
+	// were referenced by two pinned files
+	for i := 0; i < 2; i++ {
+		if err := db.Set(ctx, chunk.ModeSetPin, addrs[:5]...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, addr := range addrs {
+		if _, err := db.Get(ctx, chunk.ModeGetRequest, addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.updateGCWG.Wait()
+
+	t.Run("gc index count", newItemsCountTest(db.gcIndex, 5))
+
+	t.Run("gc size", newIndexGCSizeTest(db))
+
+	if err := db.Set(ctx, chunk.ModeSetUnpin, addrs[:5]...); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("gc index count with a pin left", newItemsCountTest(db.gcIndex, 5))
+
+	collected, err := db.CollectGarbage(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if collected != 5 {
+		t.Fatalf("got collected count %v, want 5", collected)
+	}
+	for _, addr := range addrs[:5] {
+		if _, err := db.Get(ctx, chunk.ModeGetLookup, addr); err != nil {
+			t.Errorf("pinned chunk %s: %v", addr, err)
+		}
+	}
+
+	if err := db.Set(ctx, chunk.ModeSetUnpin, addrs[:5]...); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("gc index count unpinned", newItemsCountTest(db.gcIndex, 5))
+
+	t.Run("gc size unpinned", newIndexGCSizeTest(db))
+
+	collected, err = db.CollectGarbage(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if collected != 5 {
+		t.Fatalf("got collected count %v of unpinned chunks, want 5", collected)
+	}
+}
+
 // TestDB_collectGarbageWorker_withRequests is a helper test function
 // to test garbage collection runs by uploading, syncing and
 // requesting a number of chunks.
@@ -0,0 +1,150 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// Chunk sources reported by ChunkInfo.
+const (
+	ChunkSourceUpload  = "upload"  // has an upload tag or waits to be push synced
+	ChunkSourceNetwork = "network" // no upload information, received by syncing or retrieval
+)
+
+// ChunkInfo holds the metadata of a single chunk
+// stored in localstore indexes.
+type ChunkInfo struct {
+	Address         chunk.Address
+	Size            int    // length of chunk data
+	Bin             uint8  // proximity order to the base key
+	BinID           uint64 // serial id in the pull syncing bin
+	StoreTimestamp  int64
+	AccessTimestamp int64  // zero if the chunk was not accessed since it was stored
+	Tag             uint32 // upload tag, zero if unknown
+	Source          string // ChunkSourceUpload or ChunkSourceNetwork
+	PushPending     bool   // waiting to be push synced
+	PinCounter      uint64
+	Expiry          int64  // time to live hint, zero if not set
+	Owner           string // uploader the chunk is accounted to, empty if not accounted
+	// GCPosition is the number of chunks that are garbage collected
+	// before this one, or -1 if the chunk is not in the gc index.
+	GCPosition int
+	GCExcluded bool // excluded from garbage collection by pinning
+}
+
+// ChunkInfo returns the metadata of the chunk with the provided address
+// from all localstore indexes. If the chunk is not in the database,
+// chunk.ErrChunkNotFound is returned. It is intended for debugging
+// and it iterates the gc index to find the chunk position in it.
+func (db *DB) ChunkInfo(addr chunk.Address) (info *ChunkInfo, err error) {
+	item, err := db.retrievalDataIndex.Get(addressToItem(addr))
+	if err != nil {
+		if err == leveldb.ErrNotFound {
+			return nil, chunk.ErrChunkNotFound
+		}
+		return nil, err
+	}
+	info = &ChunkInfo{
+		Address:        addr,
+		Size:           len(item.Data),
+		Bin:            db.po(addr),
+		BinID:          item.BinID,
+		StoreTimestamp: item.StoreTimestamp,
+		Source:         ChunkSourceNetwork,
+		GCPosition:     -1,
+	}
+
+	i, err := db.retrievalAccessIndex.Get(item)
+	switch err {
+	case nil:
+		info.AccessTimestamp = i.AccessTimestamp
+		item.AccessTimestamp = i.AccessTimestamp
+	case leveldb.ErrNotFound:
+	default:
+		return nil, err
+	}
+
+	i, err = db.pullIndex.Get(item)
+	switch err {
+	case nil:
+		info.Tag = i.Tag
+	case leveldb.ErrNotFound:
+	default:
+		return nil, err
+	}
+
+	info.PushPending, err = db.pushIndex.Has(item)
+	if err != nil {
+		return nil, err
+	}
+	if info.PushPending || info.Tag != 0 {
+		info.Source = ChunkSourceUpload
+	}
+
+	i, err = db.pinIndex.Get(item)
+	switch err {
+	case nil:
+		info.PinCounter = i.PinCounter
+	case leveldb.ErrNotFound:
+	default:
+		return nil, err
+	}
+
+	i, err = db.expiryIndex.Get(item)
+	switch err {
+	case nil:
+		info.Expiry = i.Expiry
+	case leveldb.ErrNotFound:
+	default:
+		return nil, err
+	}
+
+	i, err = db.ownerIndex.Get(item)
+	switch err {
+	case nil:
+		info.Owner = i.Owner
+	case leveldb.ErrNotFound:
+	default:
+		return nil, err
+	}
+
+	info.GCExcluded, err = db.gcExcludeIndex.Has(item)
+	if err != nil {
+		return nil, err
+	}
+
+	if item.AccessTimestamp != 0 {
+		inGC, err := db.gcIndex.Has(item)
+		if err != nil {
+			return nil, err
+		}
+		if inGC {
+			total, err := db.gcIndex.Count()
+			if err != nil {
+				return nil, err
+			}
+			from, err := db.gcIndex.CountFrom(item)
+			if err != nil {
+				return nil, err
+			}
+			info.GCPosition = total - from
+		}
+	}
+	return info, nil
+}
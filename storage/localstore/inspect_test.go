@@ -0,0 +1,138 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/ethersphere/swarm/chunk"
+)
+
+// TestDB_ChunkInfo validates that chunk metadata is collected
+// from all indexes as chunks are uploaded, synced, accessed and pinned.
+func TestDB_ChunkInfo(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, nil)
+	defer cleanupFunc()
+
+	uploaded := generateTestRandomChunk().WithTagID(42)
+	synced := generateTestRandomChunk()
+
+	var ts int64 = 1000
+	defer setNow(func() int64 { return ts })()
+
+	_, err := db.Put(context.Background(), chunk.ModePutUpload, uploaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Put(context.Background(), chunk.ModePutSync, synced)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := db.ChunkInfo(uploaded.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ChunkInfo{
+		Address:        uploaded.Address(),
+		Size:           len(uploaded.Data()),
+		Bin:            db.po(uploaded.Address()),
+		BinID:          info.BinID,
+		StoreTimestamp: 1000,
+		Tag:            42,
+		Source:         ChunkSourceUpload,
+		PushPending:    true,
+		GCPosition:     -1,
+	}
+	checkChunkInfo(t, info, want)
+
+	info, err = db.ChunkInfo(synced.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkChunkInfo(t, info, ChunkInfo{
+		Address:        synced.Address(),
+		Size:           len(synced.Data()),
+		Bin:            db.po(synced.Address()),
+		BinID:          info.BinID,
+		StoreTimestamp: 1000,
+		Source:         ChunkSourceNetwork,
+		GCPosition:     -1,
+	})
+
+	ts = 2000
+	err = db.Set(context.Background(), chunk.ModeSetSyncPush, uploaded.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts = 3000
+	err = db.Set(context.Background(), chunk.ModeSetAccess, synced.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err = db.ChunkInfo(uploaded.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.AccessTimestamp = 2000
+	want.PushPending = false
+	want.GCPosition = 0
+	checkChunkInfo(t, info, want)
+
+	info, err = db.ChunkInfo(synced.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.AccessTimestamp != 3000 {
+		t.Errorf("got access timestamp %v, want %v", info.AccessTimestamp, 3000)
+	}
+	if info.GCPosition != 1 {
+		t.Errorf("got gc position %v, want %v", info.GCPosition, 1)
+	}
+
+	err = db.Set(context.Background(), chunk.ModeSetPin, synced.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err = db.ChunkInfo(synced.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.PinCounter != 1 {
+		t.Errorf("got pin counter %v, want %v", info.PinCounter, 1)
+	}
+	if !info.GCExcluded {
+		t.Error("pinned chunk is not excluded from gc")
+	}
+
+	_, err = db.ChunkInfo(generateTestRandomChunk().Address())
+	if err != chunk.ErrChunkNotFound {
+		t.Errorf("got error %v, want %v", err, chunk.ErrChunkNotFound)
+	}
+}
+
+// checkChunkInfo validates that all ChunkInfo fields are as expected.
+func checkChunkInfo(t *testing.T, got *ChunkInfo, want ChunkInfo) {
+	t.Helper()
+
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("got chunk info %+v, want %+v", *got, want)
+	}
+}
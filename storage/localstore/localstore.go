@@ -19,6 +19,7 @@ package localstore
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"os"
 	"runtime/pprof"
 	"sync"
@@ -42,6 +43,12 @@ var (
 	// is updated in parallel and one of the updates
 	// takes longer then the configured timeout duration.
 	ErrAddressLockTimeout = errors.New("address lock timeout")
+	// ErrReadOnly is returned by Put and Set
+	// on a DB opened with the ReadOnly option.
+	ErrReadOnly = errors.New("read-only localstore")
+	// ErrInvalidGCLimit is returned by CollectGarbage
+	// when no chunks are allowed to be removed.
+	ErrInvalidGCLimit = errors.New("invalid gc limit")
 )
 
 var (
@@ -55,8 +62,16 @@ var (
 // DB is the local store implementation and holds
 // database related objects.
 type DB struct {
-	shed *shed.DB
-	tags *chunk.Tags
+	shed   *shed.DB
+	tags   *chunk.Tags
+	events *chunk.Events // chunk lifecycle events, nil if not instrumented
+	cache  *chunkCache   // in-memory cache of retrieved chunks, nil if disabled
+
+	// batches ModePutSync writes, nil if disabled
+	syncBatcher *syncBatcher
+
+	// rejects writes and disables garbage collection
+	readOnly bool
 
 	// schema name of loaded data
 	schemaName shed.StringField
@@ -89,6 +104,14 @@ type DB struct {
 	// pin files Index
 	pinIndex shed.Index
 
+	// time to live hints of uploaded chunks
+	expiryIndex shed.Index
+
+	// owners and sizes of uploaded chunks accounted to an owner
+	ownerIndex shed.Index
+	// storage usage of every owner
+	usage *ownerUsage
+
 	// field that stores number of intems in gc index
 	gcSize shed.Uint64Field
 
@@ -98,6 +121,8 @@ type DB struct {
 
 	// triggers garbage collection event loop
 	collectGarbageTrigger chan struct{}
+	// counters of garbage collection runs reported by GCStats
+	gcStats gcStats
 
 	// a buffered channel acting as a semaphore
 	// to limit the maximal number of goroutines
@@ -120,8 +145,15 @@ type DB struct {
 	// are done
 	collectGarbageWorkerDone chan struct{}
 
+	// index entry counts, nil if metrics are disabled
+	indexCounter *indexCounter
+
 	putToGCCheck func([]byte) bool
 
+	// time by which a time to live hint must be passed
+	// before the chunk is garbage collected
+	clockTolerance time.Duration
+
 	// wait for all subscriptions to finish before closing
 	// underlaying LevelDB to prevent possible panics from
 	// iterators
@@ -142,10 +174,34 @@ type Options struct {
 	// MetricsPrefix defines a prefix for metrics names.
 	MetricsPrefix string
 	Tags          *chunk.Tags
+	// Events receives chunk lifecycle events for stored, synced
+	// and removed chunks. Events are not emitted if it is nil.
+	Events *chunk.Events
+	// Engine is the name of the shed storage engine used for
+	// a new database. The engine of an existing database is used
+	// if it is empty, and shed.EngineLevelDB for a new one.
+	Engine string
+	// SyncBatchDelay enables writing chunks from concurrent ModePutSync
+	// Put calls in a single batch. It is the longest time to wait for
+	// more chunks when concurrent calls are observed. SyncBatchSize is
+	// the maximal number of chunks in a batch, 128 if it is zero.
+	SyncBatchDelay time.Duration
+	SyncBatchSize  int
+	// CacheCapacity is the number of recently retrieved chunks
+	// kept in memory. Chunks are not cached if it is zero.
+	CacheCapacity uint
+	// ReadOnly opens an existing database only to serve chunks.
+	// Put and Set return ErrReadOnly, access timestamps are not
+	// updated and garbage collection is not run.
+	ReadOnly bool
 	// PutSetCheckFunc is a function called after a Put of a chunk
 	// to verify whether that chunk needs to be Set and added to
 	// garbage collection index too
 	PutToGCCheck func([]byte) bool
+	// ClockTolerance delays garbage collection of chunks with
+	// an expired time to live hint, so that they are not removed
+	// early if the host clock is ahead.
+	ClockTolerance time.Duration
 }
 
 // New returns a new DB.  All fields and indexes are initialized
@@ -167,6 +223,7 @@ func New(path string, baseKey []byte, o *Options) (db *DB, err error) {
 		capacity: o.Capacity,
 		baseKey:  baseKey,
 		tags:     o.Tags,
+		events:   o.Events,
 		// channel collectGarbageTrigger
 		// needs to be buffered with the size of 1
 		// to signal another event if it
@@ -175,6 +232,8 @@ func New(path string, baseKey []byte, o *Options) (db *DB, err error) {
 		close:                    make(chan struct{}),
 		collectGarbageWorkerDone: make(chan struct{}),
 		putToGCCheck:             o.PutToGCCheck,
+		readOnly:                 o.ReadOnly,
+		clockTolerance:           o.ClockTolerance,
 	}
 	if db.capacity <= 0 {
 		db.capacity = defaultCapacity
@@ -183,7 +242,15 @@ func New(path string, baseKey []byte, o *Options) (db *DB, err error) {
 		db.updateGCSem = make(chan struct{}, maxParallelUpdateGC)
 	}
 
-	db.shed, err = shed.NewDB(path, o.MetricsPrefix)
+	db.cache, err = newChunkCache(int(o.CacheCapacity))
+	if err != nil {
+		return nil, err
+	}
+
+	db.shed, err = shed.NewDBWithOptions(path, o.MetricsPrefix, &shed.Options{
+		Engine:   o.Engine,
+		ReadOnly: o.ReadOnly,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -197,7 +264,12 @@ func New(path string, baseKey []byte, o *Options) (db *DB, err error) {
 	if err != nil {
 		return nil, err
 	}
-	if schemaName == "" {
+	if o.ReadOnly {
+		// data can not be migrated without writing
+		if schemaName != DbSchemaCurrent {
+			return nil, fmt.Errorf("read-only localstore has schema %q, expected %q", schemaName, DbSchemaCurrent)
+		}
+	} else if schemaName == "" {
 		// initial new localstore run
 		err := db.schemaName.Put(DbSchemaCurrent)
 		if err != nil {
@@ -430,8 +502,73 @@ func New(path string, baseKey []byte, o *Options) (db *DB, err error) {
 		return nil, err
 	}
 
+	// Create a index structure for time to live hints of uploaded chunks
+	db.expiryIndex, err = db.shed.NewIndex("Hash->Expiry", shed.IndexFuncs{
+		EncodeKey: func(fields shed.Item) (key []byte, err error) {
+			return fields.Address, nil
+		},
+		DecodeKey: func(key []byte) (e shed.Item, err error) {
+			e.Address = key
+			return e, nil
+		},
+		EncodeValue: func(fields shed.Item) (value []byte, err error) {
+			b := make([]byte, 8)
+			binary.BigEndian.PutUint64(b, uint64(fields.Expiry))
+			return b, nil
+		},
+		DecodeValue: func(keyItem shed.Item, value []byte) (e shed.Item, err error) {
+			e.Expiry = int64(binary.BigEndian.Uint64(value[:8]))
+			return e, nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a index structure for the owners of uploaded chunks
+	db.ownerIndex, err = db.shed.NewIndex("Hash->Owner", shed.IndexFuncs{
+		EncodeKey: func(fields shed.Item) (key []byte, err error) {
+			return fields.Address, nil
+		},
+		DecodeKey: func(key []byte) (e shed.Item, err error) {
+			e.Address = key
+			return e, nil
+		},
+		EncodeValue: func(fields shed.Item) (value []byte, err error) {
+			b := make([]byte, 8, 8+len(fields.Owner))
+			binary.BigEndian.PutUint64(b, fields.Size)
+			return append(b, fields.Owner...), nil
+		},
+		DecodeValue: func(keyItem shed.Item, value []byte) (e shed.Item, err error) {
+			e.Size = binary.BigEndian.Uint64(value[:8])
+			e.Owner = string(value[8:])
+			return e, nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	db.usage, err = db.newOwnerUsage()
+	if err != nil {
+		return nil, err
+	}
+
+	// count index entries once, the counts are updated with every written batch
+	if metrics.Enabled {
+		db.indexCounter, err = db.newIndexCounter()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if o.ReadOnly {
+		// nothing is written, there is no garbage to collect
+		close(db.collectGarbageWorkerDone)
+		return db, nil
+	}
 	// start garbage collection worker
 	go db.collectGarbageWorker()
+	db.syncBatcher = newSyncBatcher(db, o.SyncBatchSize, o.SyncBatchDelay)
 	return db, nil
 }
 
@@ -447,6 +584,10 @@ func (db *DB) Close() (err error) {
 		// wait for gc worker to
 		// return before closing the shed
 		<-db.collectGarbageWorkerDone
+		// wait for pending sync batch to be written
+		if db.syncBatcher != nil {
+			<-db.syncBatcher.done
+		}
 		close(done)
 	}()
 	select {
@@ -478,6 +619,8 @@ func (db *DB) DebugIndices() (indexInfo map[string]int, err error) {
 		"gcIndex":              db.gcIndex,
 		"gcExcludeIndex":       db.gcExcludeIndex,
 		"pinIndex":             db.pinIndex,
+		"expiryIndex":          db.expiryIndex,
+		"ownerIndex":           db.ownerIndex,
 	} {
 		indexSize, err := v.Count()
 		if err != nil {
@@ -537,8 +680,9 @@ func init() {
 
 // totalTimeMetric logs a message about time between provided start time
 // and the time when the function is called and sends a resetting timer metric
-// with provided name appended with ".total-time".
+// with provided name appended with ".total-time" and a latency histogram.
 func totalTimeMetric(name string, start time.Time) {
 	totalTime := time.Since(start)
 	metrics.GetOrRegisterResettingTimer(name+".total-time", nil).Update(totalTime)
+	latencyMetric(name, totalTime)
 }
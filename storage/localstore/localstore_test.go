@@ -81,6 +81,180 @@ func TestDB(t *testing.T) {
 	}
 }
 
+// TestDB_shardedFileEngine validates that chunks are stored, retrieved
+// and removed when the sharded file storage engine is used.
+func TestDB_shardedFileEngine(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, &Options{
+		Engine: shed.EngineShardedFile,
+	})
+	defer cleanupFunc()
+
+	ch := generateTestRandomChunk()
+
+	_, err := db.Put(context.Background(), chunk.ModePutUpload, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := db.Get(context.Background(), chunk.ModeGetRequest, ch.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Data(), ch.Data()) {
+		t.Errorf("got data %x, want %x", got.Data(), ch.Data())
+	}
+
+	err = db.Set(context.Background(), chunk.ModeSetRemove, ch.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Get(context.Background(), chunk.ModeGetRequest, ch.Address())
+	if err != chunk.ErrChunkNotFound {
+		t.Fatalf("got error %v, want %v", err, chunk.ErrChunkNotFound)
+	}
+}
+
+// TestDB_readOnly validates that a read-only DB serves chunks
+// stored before, rejects writes and does not update gc indexes.
+func TestDB_readOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "localstore-readonly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	baseKey := make([]byte, 32)
+	if _, err := rand.Read(baseKey); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New(dir, baseKey, &Options{ReadOnly: true}); err == nil {
+		t.Fatal("expected error opening a missing database read-only")
+	}
+
+	db, err := New(dir, baseKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := generateTestRandomChunk()
+	_, err = db.Put(context.Background(), chunk.ModePutUpload, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = New(dir, baseKey, &Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	got, err := db.Get(context.Background(), chunk.ModeGetRequest, ch.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Data(), ch.Data()) {
+		t.Errorf("got data %x, want %x", got.Data(), ch.Data())
+	}
+	db.updateGCWG.Wait()
+	t.Run("retrieve access index count", newItemsCountTest(db.retrievalAccessIndex, 0))
+	t.Run("gc index count", newItemsCountTest(db.gcIndex, 0))
+
+	_, err = db.Put(context.Background(), chunk.ModePutUpload, generateTestRandomChunk())
+	if err != ErrReadOnly {
+		t.Errorf("got error %v, want %v", err, ErrReadOnly)
+	}
+	err = db.Set(context.Background(), chunk.ModeSetRemove, ch.Address())
+	if err != ErrReadOnly {
+		t.Errorf("got error %v, want %v", err, ErrReadOnly)
+	}
+	has, err := db.Has(context.Background(), ch.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !has {
+		t.Error("chunk not found after rejected remove")
+	}
+}
+
+// TestDB_Events validates that chunk lifecycle events are emitted
+// for newly stored, synced and removed chunks.
+func TestDB_Events(t *testing.T) {
+	events := chunk.NewEvents()
+	db, cleanupFunc := newTestDB(t, &Options{
+		Events: events,
+	})
+	defer cleanupFunc()
+
+	c, unsubscribe := events.Subscribe(10)
+	defer unsubscribe()
+
+	uploaded := generateTestRandomChunk()
+	synced := generateTestRandomChunk()
+
+	for _, step := range []struct {
+		do   func() error
+		want []chunk.Event
+	}{
+		{
+			do: func() error {
+				_, err := db.Put(context.Background(), chunk.ModePutUpload, uploaded)
+				return err
+			},
+			want: []chunk.Event{{Type: chunk.EventStored, Address: uploaded.Address(), Reason: "Upload"}},
+		},
+		{
+			// existing chunks must not be reported again
+			do: func() error {
+				_, err := db.Put(context.Background(), chunk.ModePutUpload, uploaded)
+				return err
+			},
+		},
+		{
+			do: func() error {
+				_, err := db.Put(context.Background(), chunk.ModePutSync, synced)
+				return err
+			},
+			want: []chunk.Event{{Type: chunk.EventReceived, Address: synced.Address(), Reason: "Sync"}},
+		},
+		{
+			do: func() error {
+				return db.Set(context.Background(), chunk.ModeSetSyncPush, uploaded.Address())
+			},
+			want: []chunk.Event{{Type: chunk.EventSynced, Address: uploaded.Address(), Reason: "SyncPush"}},
+		},
+		{
+			do: func() error {
+				return db.Set(context.Background(), chunk.ModeSetRemove, uploaded.Address(), synced.Address())
+			},
+			want: []chunk.Event{
+				{Type: chunk.EventRemoved, Address: uploaded.Address(), Reason: "Remove"},
+				{Type: chunk.EventRemoved, Address: synced.Address(), Reason: "Remove"},
+			},
+		},
+	} {
+		if err := step.do(); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range step.want {
+			select {
+			case got := <-c:
+				if got.Type != want.Type || !bytes.Equal(got.Address, want.Address) || got.Reason != want.Reason {
+					t.Fatalf("got event %s %s %s, want %s %s %s", got.Type, got.Address, got.Reason, want.Type, want.Address, want.Reason)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("timeout waiting for %s event", want.Type)
+			}
+		}
+		select {
+		case got := <-c:
+			t.Fatalf("unexpected event %s %s", got.Type, got.Address)
+		default:
+		}
+	}
+}
+
 // TestDB_updateGCSem tests maxParallelUpdateGC limit.
 // This test temporary sets the limit to a low number,
 // makes updateGC function execution time longer by
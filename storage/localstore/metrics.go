@@ -0,0 +1,193 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/shed"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// indexes returns the localstore indexes by their metric names.
+func (db *DB) indexes() map[string]shed.Index {
+	return map[string]shed.Index{
+		"retrieval-data":   db.retrievalDataIndex,
+		"retrieval-access": db.retrievalAccessIndex,
+		"push":             db.pushIndex,
+		"pull":             db.pullIndex,
+		"gc":               db.gcIndex,
+		"gc-exclude":       db.gcExcludeIndex,
+		"pin":              db.pinIndex,
+		"expiry":           db.expiryIndex,
+		"owner":            db.ownerIndex,
+	}
+}
+
+// IndexCounts returns the number of entries in every index by its name.
+// The counts are kept up to date while metrics are enabled, otherwise
+// all indexes are iterated, which is intended for debugging only.
+func (db *DB) IndexCounts() (counts map[string]int, err error) {
+	if db.indexCounter != nil {
+		return db.indexCounter.get(), nil
+	}
+	counts = make(map[string]int)
+	for name, index := range db.indexes() {
+		counts[name], err = index.Count()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return counts, nil
+}
+
+// indexCounter keeps the number of entries in every index, so that the
+// index sizes are exported as metrics without iterating the indexes.
+// The indexes are counted once when the database is opened and the
+// counts are then updated by every written batch.
+type indexCounter struct {
+	names  map[byte]string // index names by their key prefixes
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+// newIndexCounter counts the entries of all indexes.
+func (db *DB) newIndexCounter() (c *indexCounter, err error) {
+	c = &indexCounter{
+		names:  make(map[byte]string),
+		counts: make(map[string]int64),
+	}
+	deltas := make(map[string]int64)
+	for name, index := range db.indexes() {
+		c.names[index.Prefix()] = name
+		count, err := index.Count()
+		if err != nil {
+			return nil, err
+		}
+		deltas[name] = int64(count)
+	}
+	c.add(deltas)
+	return c, nil
+}
+
+// add updates the index counts and their gauges by deltas.
+func (c *indexCounter) add(deltas map[string]int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name, delta := range deltas {
+		c.counts[name] += delta
+		metrics.GetOrRegisterGauge("localstore.index."+name+".count", nil).Update(c.counts[name])
+	}
+}
+
+// get returns the index counts by index names.
+func (c *indexCounter) get() (counts map[string]int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts = make(map[string]int, len(c.counts))
+	for name, count := range c.counts {
+		counts[name] = int(count)
+	}
+	return counts
+}
+
+// writeBatch writes the batch to the database and updates
+// the index counts by the entries it adds and removes.
+// The caller is expected to hold db.batchMu, so that the
+// database does not change between counting and writing.
+func (db *DB) writeBatch(batch *leveldb.Batch) (err error) {
+	if db.indexCounter == nil {
+		return db.shed.WriteBatch(batch)
+	}
+	bc := &batchCounter{
+		db:     db.shed,
+		names:  db.indexCounter.names,
+		exists: make(map[string]bool),
+		deltas: make(map[string]int64),
+	}
+	if err := batch.Replay(bc); err != nil {
+		return err
+	}
+	if bc.err != nil {
+		return bc.err
+	}
+	if err := db.shed.WriteBatch(batch); err != nil {
+		return err
+	}
+	db.indexCounter.add(bc.deltas)
+	return nil
+}
+
+// batchCounter replays a batch to count by how many entries
+// it changes every index.
+type batchCounter struct {
+	db     *shed.DB
+	names  map[byte]string // index names by their key prefixes
+	exists map[string]bool // whether the keys exist after the replayed operations
+	deltas map[string]int64
+	err    error
+}
+
+// Put is called for every put operation in the replayed batch.
+func (bc *batchCounter) Put(key, _ []byte) {
+	bc.set(key, true)
+}
+
+// Delete is called for every delete operation in the replayed batch.
+func (bc *batchCounter) Delete(key []byte) {
+	bc.set(key, false)
+}
+
+// set records whether the key exists after an operation, counting
+// the index entry if it is added or removed by it.
+func (bc *batchCounter) set(key []byte, exists bool) {
+	if bc.err != nil || len(key) == 0 {
+		return
+	}
+	name, ok := bc.names[key[0]]
+	if !ok {
+		// not an index key
+		return
+	}
+	existed, ok := bc.exists[string(key)]
+	if !ok {
+		existed, bc.err = bc.db.Has(key)
+		if bc.err != nil {
+			return
+		}
+	}
+	bc.exists[string(key)] = exists
+	switch {
+	case exists && !existed:
+		bc.deltas[name]++
+	case !exists && existed:
+		bc.deltas[name]--
+	}
+}
+
+// latencyMetric updates the latency histogram with provided name appended
+// with ".latency" by the duration in microseconds.
+func latencyMetric(name string, d time.Duration) {
+	h := metrics.GetOrRegister(name+".latency", func() metrics.Histogram {
+		return metrics.NewHistogram(metrics.NewExpDecaySample(1028, 0.015))
+	}).(metrics.Histogram)
+	h.Update(d.Nanoseconds() / int64(time.Microsecond))
+}
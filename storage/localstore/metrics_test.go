@@ -0,0 +1,93 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/chunk"
+)
+
+// TestIndexCounts validates that index sizes are counted
+// and exported as metrics while metrics are enabled.
+func TestIndexCounts(t *testing.T) {
+	defer func(enabled bool) { metrics.Enabled = enabled }(metrics.Enabled)
+	metrics.Enabled = true
+
+	db, cleanupFunc := newTestDB(t, nil)
+	defer cleanupFunc()
+
+	count := 10
+	chunks := generateTestRandomChunks(count)
+	if _, err := db.Put(context.Background(), chunk.ModePutUpload, chunks...); err != nil {
+		t.Fatal(err)
+	}
+	// the same chunks put again do not add index entries
+	if _, err := db.Put(context.Background(), chunk.ModePutUpload, chunks...); err != nil {
+		t.Fatal(err)
+	}
+
+	counts, err := db.IndexCounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]int{
+		"retrieval-data":   count,
+		"retrieval-access": 0,
+		"push":             count,
+		"pull":             count,
+		"gc":               0,
+		"pin":              0,
+	} {
+		if counts[name] != want {
+			t.Errorf("got %d entries in %s index, want %d", counts[name], name, want)
+		}
+	}
+
+	if gauge := metrics.GetOrRegisterGauge("localstore.index.push.count", nil); gauge.Value() != int64(count) {
+		t.Fatalf("got push index size metric %d, want %d", gauge.Value(), count)
+	}
+
+	// the maintained counts match the iterated ones after entries are moved between indexes
+	if err := db.Set(context.Background(), chunk.ModeSetSyncPush, chunks[0].Address(), chunks[1].Address()); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Set(context.Background(), chunk.ModeSetPin, chunks[2].Address()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Get(context.Background(), chunk.ModeGetRequest, chunks[0].Address()); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Set(context.Background(), chunk.ModeSetRemove, chunks[1].Address()); err != nil {
+		t.Fatal(err)
+	}
+	counts, err = db.IndexCounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, index := range db.indexes() {
+		want, err := index.Count()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if counts[name] != want {
+			t.Errorf("got %d entries in %s index, want %d", counts[name], name, want)
+		}
+	}
+}
@@ -60,9 +60,14 @@ func (db *DB) Get(ctx context.Context, mode chunk.ModeGet, addr chunk.Address) (
 func (db *DB) get(mode chunk.ModeGet, addr chunk.Address) (out shed.Item, err error) {
 	item := addressToItem(addr)
 
-	out, err = db.retrievalDataIndex.Get(item)
-	if err != nil {
-		return out, err
+	out, ok := db.cache.get(addr)
+	if !ok {
+		gen := db.cache.gen()
+		out, err = db.retrievalDataIndex.Get(item)
+		if err != nil {
+			return out, err
+		}
+		db.cache.add(out, gen)
 	}
 	switch mode {
 	// update the access timestamp and gc index
@@ -87,8 +92,11 @@ func (db *DB) get(mode chunk.ModeGet, addr chunk.Address) (out shed.Item, err er
 
 // updateGCItems is called when ModeGetRequest is used
 // for Get or GetMulti to update access time and gc indexes
-// for all returned chunks.
+// for all returned chunks. Read-only databases are not updated.
 func (db *DB) updateGCItems(items ...shed.Item) {
+	if db.readOnly {
+		return
+	}
 	if db.updateGCSem != nil {
 		// wait before creating new goroutines
 		// if updateGCSem buffer id full
@@ -147,16 +155,40 @@ func (db *DB) updateGC(item shed.Item) (err error) {
 		// do not add it to the gc index
 		return nil
 	}
-	// delete current entry from the gc index
-	db.gcIndex.DeleteInBatch(batch, item)
+	// pinned chunks are not added back to the gc index,
+	// otherwise accessing content shared with pinned content
+	// would make it collectable
+	pinned, err := db.pinIndex.Has(item)
+	if err != nil {
+		return err
+	}
+	if pinned {
+		// the chunk may still be in the gc index if it was
+		// pinned after the last garbage collection run
+		inGC, err := db.gcIndex.Has(item)
+		if err != nil {
+			return err
+		}
+		if inGC {
+			db.gcIndex.DeleteInBatch(batch, item)
+			if err := db.incGCSizeInBatch(batch, -1); err != nil {
+				return err
+			}
+		}
+	} else {
+		// delete current entry from the gc index
+		db.gcIndex.DeleteInBatch(batch, item)
+	}
 	// update access timestamp
 	item.AccessTimestamp = now()
 	// update retrieve access index
 	db.retrievalAccessIndex.PutInBatch(batch, item)
-	// add new entry to gc index
-	db.gcIndex.PutInBatch(batch, item)
+	if !pinned {
+		// add new entry to gc index
+		db.gcIndex.PutInBatch(batch, item)
+	}
 
-	return db.shed.WriteBatch(batch)
+	return db.writeBatch(batch)
 }
 
 // testHookUpdateGC is a hook that can provide
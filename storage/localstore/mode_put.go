@@ -38,7 +38,14 @@ func (db *DB) Put(ctx context.Context, mode chunk.ModePut, chs ...chunk.Chunk) (
 	metrics.GetOrRegisterCounter(metricName, nil).Inc(1)
 	defer totalTimeMetric(metricName, time.Now())
 
-	exist, err = db.put(mode, chs...)
+	if db.readOnly {
+		return nil, ErrReadOnly
+	}
+	if mode == chunk.ModePutSync && db.syncBatcher != nil {
+		exist, err = db.syncBatcher.put(chs...)
+	} else {
+		exist, err = db.put(mode, chs...)
+	}
 	if err != nil {
 		metrics.GetOrRegisterCounter(metricName+".error", nil).Inc(1)
 	}
@@ -65,6 +72,8 @@ func (db *DB) put(mode chunk.ModePut, chs ...chunk.Chunk) (exist []bool, err err
 	var gcSizeChange int64                      // number to add or subtract from gcSize
 	var triggerPushFeed bool                    // signal push feed subscriptions to iterate
 	triggerPullFeed := make(map[uint8]struct{}) // signal pull feed subscriptions to iterate
+	var stored []chunk.Address                  // newly stored chunks to report lifecycle events for
+	usage := make(usageChanges)                 // changes of the owners storage usage
 
 	exist = make([]bool, len(chs))
 
@@ -86,6 +95,9 @@ func (db *DB) put(mode chunk.ModePut, chs ...chunk.Chunk) (exist []bool, err err
 				return nil, err
 			}
 			exist[i] = exists
+			if !exists {
+				stored = append(stored, ch.Address())
+			}
 			gcSizeChange += c
 		}
 
@@ -95,7 +107,7 @@ func (db *DB) put(mode chunk.ModePut, chs ...chunk.Chunk) (exist []bool, err err
 				exist[i] = true
 				continue
 			}
-			exists, c, err := db.putUpload(batch, binIDs, chunkToItem(ch))
+			exists, c, err := db.putUpload(batch, binIDs, chunkToItem(ch), usage)
 			if err != nil {
 				return nil, err
 			}
@@ -105,6 +117,7 @@ func (db *DB) put(mode chunk.ModePut, chs ...chunk.Chunk) (exist []bool, err err
 				// after the batch is successfully written
 				triggerPullFeed[db.po(ch.Address())] = struct{}{}
 				triggerPushFeed = true
+				stored = append(stored, ch.Address())
 			}
 			gcSizeChange += c
 		}
@@ -124,6 +137,7 @@ func (db *DB) put(mode chunk.ModePut, chs ...chunk.Chunk) (exist []bool, err err
 				// chunk is new so, trigger pull subscription feed
 				// after the batch is successfully written
 				triggerPullFeed[db.po(ch.Address())] = struct{}{}
+				stored = append(stored, ch.Address())
 			}
 			gcSizeChange += c
 		}
@@ -141,10 +155,11 @@ func (db *DB) put(mode chunk.ModePut, chs ...chunk.Chunk) (exist []bool, err err
 		return nil, err
 	}
 
-	err = db.shed.WriteBatch(batch)
+	err = db.writeBatch(batch)
 	if err != nil {
 		return nil, err
 	}
+	db.usage.add(usage)
 
 	for po := range triggerPullFeed {
 		db.triggerPullSubscriptions(po)
@@ -152,6 +167,11 @@ func (db *DB) put(mode chunk.ModePut, chs ...chunk.Chunk) (exist []bool, err err
 	if triggerPushFeed {
 		db.triggerPushSubscriptions()
 	}
+	if mode == chunk.ModePutUpload {
+		db.events.Emit(chunk.EventStored, mode.String(), stored...)
+	} else {
+		db.events.Emit(chunk.EventReceived, mode.String(), stored...)
+	}
 	return exist, nil
 }
 
@@ -196,8 +216,8 @@ func (db *DB) putRequest(batch *leveldb.Batch, binIDs map[uint8]uint64, item she
 // putUpload adds an Item to the batch by updating required indexes:
 //  - put to indexes: retrieve, push, pull
 // The batch can be written to the database.
-// Provided batch and binID map are updated.
-func (db *DB) putUpload(batch *leveldb.Batch, binIDs map[uint8]uint64, item shed.Item) (exists bool, gcSizeChange int64, err error) {
+// Provided batch, binID map and usage changes are updated.
+func (db *DB) putUpload(batch *leveldb.Batch, binIDs map[uint8]uint64, item shed.Item, usage usageChanges) (exists bool, gcSizeChange int64, err error) {
 	exists, err = db.retrievalDataIndex.Has(item)
 	if err != nil {
 		return false, 0, err
@@ -219,6 +239,13 @@ func (db *DB) putUpload(batch *leveldb.Batch, binIDs map[uint8]uint64, item shed
 			return false, 0, err
 		}
 		anonymous = tag.Anonymous
+		if !tag.Expiry.IsZero() {
+			item.Expiry = tag.Expiry.UnixNano()
+		}
+		if tag.Owner != "" {
+			item.Owner = tag.Owner
+			item.Size = uint64(len(item.Data))
+		}
 	}
 
 	item.StoreTimestamp = now()
@@ -231,6 +258,13 @@ func (db *DB) putUpload(batch *leveldb.Batch, binIDs map[uint8]uint64, item shed
 	if !anonymous {
 		db.pushIndex.PutInBatch(batch, item)
 	}
+	if item.Expiry != 0 {
+		db.expiryIndex.PutInBatch(batch, item)
+	}
+	if item.Owner != "" {
+		db.ownerIndex.PutInBatch(batch, item)
+		usage.add(item, false)
+	}
 
 	if db.putToGCCheck(item.Address) {
 
@@ -37,6 +37,10 @@ func (db *DB) Set(ctx context.Context, mode chunk.ModeSet, addrs ...chunk.Addres
 
 	metrics.GetOrRegisterCounter(metricName, nil).Inc(1)
 	defer totalTimeMetric(metricName, time.Now())
+
+	if db.readOnly {
+		return ErrReadOnly
+	}
 	err = db.set(mode, addrs...)
 	if err != nil {
 		metrics.GetOrRegisterCounter(metricName+".error", nil).Inc(1)
@@ -59,6 +63,7 @@ func (db *DB) set(mode chunk.ModeSet, addrs ...chunk.Address) (err error) {
 	// to be done after write batch function successfully executes
 	var gcSizeChange int64                      // number to add or subtract from gcSize
 	triggerPullFeed := make(map[uint8]struct{}) // signal pull feed subscriptions to iterate
+	usage := make(usageChanges)                 // changes of the owners storage usage
 
 	switch mode {
 	case chunk.ModeSetAccess:
@@ -90,7 +95,7 @@ func (db *DB) set(mode chunk.ModeSet, addrs ...chunk.Address) (err error) {
 
 	case chunk.ModeSetRemove:
 		for _, addr := range addrs {
-			c, err := db.setRemove(batch, addr)
+			c, err := db.setRemove(batch, addr, usage)
 			if err != nil {
 				return err
 			}
@@ -106,10 +111,11 @@ func (db *DB) set(mode chunk.ModeSet, addrs ...chunk.Address) (err error) {
 		}
 	case chunk.ModeSetUnpin:
 		for _, addr := range addrs {
-			err := db.setUnpin(batch, addr)
+			c, err := db.setUnpin(batch, addr)
 			if err != nil {
 				return err
 			}
+			gcSizeChange += c
 		}
 
 	default:
@@ -121,13 +127,21 @@ func (db *DB) set(mode chunk.ModeSet, addrs ...chunk.Address) (err error) {
 		return err
 	}
 
-	err = db.shed.WriteBatch(batch)
+	err = db.writeBatch(batch)
 	if err != nil {
 		return err
 	}
+	db.usage.add(usage)
 	for po := range triggerPullFeed {
 		db.triggerPullSubscriptions(po)
 	}
+	switch mode {
+	case chunk.ModeSetSyncPush, chunk.ModeSetSyncPull:
+		db.events.Emit(chunk.EventSynced, mode.String(), addrs...)
+	case chunk.ModeSetRemove:
+		db.cache.remove(addrs...)
+		db.events.Emit(chunk.EventRemoved, mode.String(), addrs...)
+	}
 	return nil
 }
 
@@ -305,8 +319,8 @@ func (db *DB) setSync(batch *leveldb.Batch, addr chunk.Address, mode chunk.ModeS
 
 // setRemove removes the chunk by updating indexes:
 //  - delete from retrieve, pull, gc
-// Provided batch is updated.
-func (db *DB) setRemove(batch *leveldb.Batch, addr chunk.Address) (gcSizeChange int64, err error) {
+// Provided batch and usage changes are updated.
+func (db *DB) setRemove(batch *leveldb.Batch, addr chunk.Address, usage usageChanges) (gcSizeChange int64, err error) {
 	item := addressToItem(addr)
 
 	// need to get access timestamp here as it is not
@@ -331,6 +345,10 @@ func (db *DB) setRemove(batch *leveldb.Batch, addr chunk.Address) (gcSizeChange
 	db.retrievalAccessIndex.DeleteInBatch(batch, item)
 	db.pullIndex.DeleteInBatch(batch, item)
 	db.gcIndex.DeleteInBatch(batch, item)
+	db.expiryIndex.DeleteInBatch(batch, item)
+	if err := db.removeOwnerInBatch(batch, item, usage); err != nil {
+		return 0, err
+	}
 	// a check is needed for decrementing gcSize
 	// as delete is not reporting if the key/value pair
 	// is deleted or not
@@ -372,14 +390,16 @@ func (db *DB) setPin(batch *leveldb.Batch, addr chunk.Address) (err error) {
 }
 
 // setUnpin decrements pin counter for the chunk by updating pin index.
+// When the last pin is removed, the chunk is returned to the gc index,
+// if it was synced, so that it can be garbage collected again.
 // Provided batch is updated.
-func (db *DB) setUnpin(batch *leveldb.Batch, addr chunk.Address) (err error) {
+func (db *DB) setUnpin(batch *leveldb.Batch, addr chunk.Address) (gcSizeChange int64, err error) {
 	item := addressToItem(addr)
 
 	// Get the existing pin counter of the chunk
 	pinnedChunk, err := db.pinIndex.Get(item)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Decrement the pin counter or
@@ -387,9 +407,34 @@ func (db *DB) setUnpin(batch *leveldb.Batch, addr chunk.Address) (err error) {
 	if pinnedChunk.PinCounter > 1 {
 		item.PinCounter = pinnedChunk.PinCounter - 1
 		db.pinIndex.PutInBatch(batch, item)
-	} else {
-		db.pinIndex.DeleteInBatch(batch, item)
+		return 0, nil
 	}
+	db.pinIndex.DeleteInBatch(batch, item)
+	db.gcExcludeIndex.DeleteInBatch(batch, item)
 
-	return nil
+	i, err := db.retrievalAccessIndex.Get(item)
+	switch err {
+	case nil:
+		item.AccessTimestamp = i.AccessTimestamp
+	case leveldb.ErrNotFound:
+		// the chunk is not synced yet and it is
+		// added to the gc index when it is
+		return 0, nil
+	default:
+		return 0, err
+	}
+	i, err = db.retrievalDataIndex.Get(item)
+	if err != nil {
+		return 0, err
+	}
+	item.BinID = i.BinID
+	inGC, err := db.gcIndex.Has(item)
+	if err != nil {
+		return 0, err
+	}
+	if !inGC {
+		db.gcIndex.PutInBatch(batch, item)
+		gcSizeChange++
+	}
+	return gcSizeChange, nil
 }
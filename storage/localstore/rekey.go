@@ -0,0 +1,96 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"context"
+
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/shed"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// RekeyReport counts how the chunks of a database are moved
+// to a database with a different base key.
+type RekeyReport struct {
+	Chunks      int64 // chunks in the database
+	Kept        int64 // chunks within the depth of the new base key
+	Pinned      int64 // pinned chunks outside the depth, kept with their pin counter
+	PushPending int64 // chunks outside the depth waiting to be push synced, kept to be synced
+	Discarded   int64 // chunks outside the depth which are not kept
+}
+
+// Rekey moves the chunks of the database to the database to, which has
+// the different base key baseKey. The bins of the pull index depend on the
+// base key, so chunks can not stay in a database after the overlay address
+// changes. Chunks with a proximity order to baseKey lower than depth are
+// outside of the new neighbourhood and are discarded, unless they are pinned
+// or not yet push synced. Kept chunks are stored as synced, or as uploaded if
+// they are not yet push synced, and pinned chunks are pinned as many times as
+// before. If to is nil, the chunks are only counted, so that the consequences
+// of changing the base key can be reported first. The database is not changed.
+func (db *DB) Rekey(to *DB, baseKey []byte, depth uint8) (report RekeyReport, err error) {
+	ctx := context.Background()
+	err = db.retrievalDataIndex.Iterate(func(item shed.Item) (stop bool, err error) {
+		report.Chunks++
+
+		pushPending, err := db.pushIndex.Has(item)
+		if err != nil {
+			return true, err
+		}
+		var pinCounter uint64
+		i, err := db.pinIndex.Get(item)
+		switch err {
+		case nil:
+			pinCounter = i.PinCounter
+		case leveldb.ErrNotFound:
+		default:
+			return true, err
+		}
+
+		switch {
+		case uint8(chunk.Proximity(baseKey, item.Address)) >= depth:
+			report.Kept++
+		case pinCounter > 0:
+			report.Pinned++
+		case pushPending:
+			report.PushPending++
+		default:
+			report.Discarded++
+			return false, nil
+		}
+		if to == nil {
+			return false, nil
+		}
+
+		mode := chunk.ModePutSync
+		if pushPending {
+			mode = chunk.ModePutUpload
+		}
+		ch := chunk.NewChunk(item.Address, item.Data)
+		if _, err := to.Put(ctx, mode, ch); err != nil {
+			return true, err
+		}
+		for ; pinCounter > 0; pinCounter-- {
+			if err := to.Set(ctx, chunk.ModeSetPin, item.Address); err != nil {
+				return true, err
+			}
+		}
+		return false, nil
+	}, nil)
+	return report, err
+}
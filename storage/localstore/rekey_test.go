@@ -0,0 +1,113 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethersphere/swarm/chunk"
+)
+
+// TestDB_Rekey validates that chunks within the depth of the new base key,
+// pinned chunks and chunks waiting to be push synced are moved to the new
+// database and that the other chunks are discarded.
+func TestDB_Rekey(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, nil)
+	defer cleanupFunc()
+	to, cleanupTo := newTestDB(t, nil)
+	defer cleanupTo()
+
+	var depth uint8 = 1
+	near := generateTestRandomChunk()
+	for uint8(chunk.Proximity(to.baseKey, near.Address())) < depth {
+		near = generateTestRandomChunk()
+	}
+	far := func() chunk.Chunk {
+		ch := generateTestRandomChunk()
+		for uint8(chunk.Proximity(to.baseKey, ch.Address())) >= depth {
+			ch = generateTestRandomChunk()
+		}
+		return ch
+	}
+	pinned, pending, discarded := far(), far(), far()
+
+	ctx := context.Background()
+	if _, err := db.Put(ctx, chunk.ModePutSync, near, pinned, discarded); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Put(ctx, chunk.ModePutUpload, pending); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := db.Set(ctx, chunk.ModeSetPin, pinned.Address()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := RekeyReport{
+		Chunks:      4,
+		Kept:        1,
+		Pinned:      1,
+		PushPending: 1,
+		Discarded:   1,
+	}
+	report, err := db.Rekey(nil, to.baseKey, depth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != want {
+		t.Fatalf("got report %+v without a database, want %+v", report, want)
+	}
+	if n, err := to.retrievalDataIndex.Count(); err != nil || n != 0 {
+		t.Fatalf("got %d chunks in the new database after counting, want none (err %v)", n, err)
+	}
+
+	report, err = db.Rekey(to, to.baseKey, depth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != want {
+		t.Fatalf("got report %+v, want %+v", report, want)
+	}
+
+	for _, ch := range []chunk.Chunk{near, pinned, pending} {
+		if _, err := to.Get(ctx, chunk.ModeGetLookup, ch.Address()); err != nil {
+			t.Fatalf("chunk %s not moved: %v", ch.Address(), err)
+		}
+	}
+	if _, err := to.Get(ctx, chunk.ModeGetLookup, discarded.Address()); err != chunk.ErrChunkNotFound {
+		t.Fatalf("got error %v for a discarded chunk, want %v", err, chunk.ErrChunkNotFound)
+	}
+	info, err := to.ChunkInfo(pinned.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.PinCounter != 2 {
+		t.Errorf("got pin counter %d, want 2", info.PinCounter)
+	}
+	info, err = to.ChunkInfo(pending.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.PushPending {
+		t.Error("chunk waiting to be push synced is not push pending in the new database")
+	}
+	if info.Bin != uint8(chunk.Proximity(to.baseKey, pending.Address())) {
+		t.Errorf("got bin %d, want the proximity order to the new base key", info.Bin)
+	}
+}
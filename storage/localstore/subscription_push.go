@@ -161,3 +161,15 @@ func (db *DB) triggerPushSubscriptions() {
 		}
 	}
 }
+
+// UnsyncedAddresses returns the addresses of chunks in the push syncing index,
+// which are not yet confirmed to be stored in their neighbourhood.
+func (db *DB) UnsyncedAddresses() (addrs []chunk.Address, err error) {
+	metrics.GetOrRegisterCounter("localstore.UnsyncedAddresses", nil).Inc(1)
+
+	err = db.pushIndex.Iterate(func(item shed.Item) (stop bool, err error) {
+		addrs = append(addrs, item.Address)
+		return false, nil
+	}, nil)
+	return addrs, err
+}
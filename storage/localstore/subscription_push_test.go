@@ -204,3 +204,34 @@ func TestDB_SubscribePush_multiple(t *testing.T) {
 
 	checkErrChan(ctx, t, errChan, wantedChunksCount)
 }
+
+// TestDB_UnsyncedAddresses validates that only uploaded chunks
+// not yet set as synced are returned.
+func TestDB_UnsyncedAddresses(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, nil)
+	defer cleanupFunc()
+
+	uploaded := generateTestRandomChunks(3)
+	if _, err := db.Put(context.Background(), chunk.ModePutUpload, uploaded...); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Put(context.Background(), chunk.ModePutSync, generateTestRandomChunk()); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Set(context.Background(), chunk.ModeSetSyncPush, uploaded[0].Address()); err != nil {
+		t.Fatal(err)
+	}
+
+	addrs, err := db.UnsyncedAddresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 2 {
+		t.Fatalf("got %v unsynced addresses, want 2", len(addrs))
+	}
+	for _, addr := range addrs {
+		if !bytes.Equal(addr, uploaded[1].Address()) && !bytes.Equal(addr, uploaded[2].Address()) {
+			t.Fatalf("unexpected unsynced address %s", addr)
+		}
+	}
+}
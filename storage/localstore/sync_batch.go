@@ -0,0 +1,192 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/chunk"
+)
+
+// defaultSyncBatchSize is the number of chunks that triggers a write
+// of the sync batch if Options.SyncBatchDelay is set without a size
+const defaultSyncBatchSize = 128
+
+// syncBatchRequest is a ModePutSync Put call
+// waiting for its chunks to be written
+type syncBatchRequest struct {
+	chunks []chunk.Chunk
+	exist  []bool
+	err    error
+	done   chan struct{}
+}
+
+// syncBatcher accumulates chunks from concurrent ModePutSync Put calls
+// and writes them in a single batch sorted by address. Chunks queued
+// while a batch is written are written together in the next one. If more
+// than one call is queued, the batcher waits for more chunks until the
+// batch size is reached or the batch delay passes, so a single writer is
+// never delayed. Put calls return after their chunks are written.
+type syncBatcher struct {
+	db       *DB
+	size     int
+	delay    time.Duration
+	requests chan *syncBatchRequest
+	done     chan struct{} // closed when the batcher stops after the database is closed
+}
+
+// newSyncBatcher returns a new syncBatcher, or nil if the delay is not
+// positive. The batcher goroutine is stopped when the database is closed.
+func newSyncBatcher(db *DB, size int, delay time.Duration) *syncBatcher {
+	if delay <= 0 {
+		return nil
+	}
+	if size <= 0 {
+		size = defaultSyncBatchSize
+	}
+	b := &syncBatcher{
+		db:       db,
+		size:     size,
+		delay:    delay,
+		requests: make(chan *syncBatchRequest),
+		done:     make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// put queues chunks to be written in the next batch and
+// blocks until they are written
+func (b *syncBatcher) put(chs ...chunk.Chunk) (exist []bool, err error) {
+	r := &syncBatchRequest{
+		chunks: chs,
+		done:   make(chan struct{}),
+	}
+	select {
+	case b.requests <- r:
+	case <-b.db.close:
+		// the batcher is stopped, write directly
+		return b.db.put(chunk.ModePutSync, chs...)
+	}
+	<-r.done
+	return r.exist, r.err
+}
+
+func (b *syncBatcher) run() {
+	defer close(b.done)
+
+	for {
+		var r *syncBatchRequest
+		select {
+		case r = <-b.requests:
+		case <-b.db.close:
+			return
+		}
+		pending := []*syncBatchRequest{r}
+		count := len(r.chunks)
+
+		// collect requests that were queued while the previous batch was written
+		count = b.collect(&pending, count, nil)
+
+		// if there are concurrent writers, wait for more
+		// chunks until the batch is full or the delay passes
+		if len(pending) > 1 && count < b.size {
+			timer := time.NewTimer(b.delay)
+			b.collect(&pending, count, timer.C)
+			timer.Stop()
+		}
+
+		b.write(pending)
+	}
+}
+
+// collect appends queued requests to pending until the number of chunks
+// reaches the batch size. If timeout is nil, it returns when there are no
+// more queued requests, otherwise it waits for requests until the timeout.
+func (b *syncBatcher) collect(pending *[]*syncBatchRequest, count int, timeout <-chan time.Time) int {
+	for count < b.size {
+		if timeout == nil {
+			select {
+			case r := <-b.requests:
+				*pending = append(*pending, r)
+				count += len(r.chunks)
+			default:
+				return count
+			}
+			continue
+		}
+		select {
+		case r := <-b.requests:
+			*pending = append(*pending, r)
+			count += len(r.chunks)
+		case <-timeout:
+			return count
+		case <-b.db.close:
+			return count
+		}
+	}
+	return count
+}
+
+// write stores the chunks of all requests sorted by address
+// in one database batch and notifies the waiting requests
+func (b *syncBatcher) write(requests []*syncBatchRequest) {
+	if len(requests) == 0 {
+		return
+	}
+	// chunk references to map the results back to requests
+	type ref struct {
+		r *syncBatchRequest
+		i int
+	}
+	var refs []ref
+	for _, r := range requests {
+		r.exist = make([]bool, len(r.chunks))
+		for i := range r.chunks {
+			refs = append(refs, ref{r: r, i: i})
+		}
+	}
+	sort.SliceStable(refs, func(i, j int) bool {
+		return bytes.Compare(refs[i].r.chunks[refs[i].i].Address(), refs[j].r.chunks[refs[j].i].Address()) < 0
+	})
+	chs := make([]chunk.Chunk, len(refs))
+	for i, f := range refs {
+		chs[i] = f.r.chunks[f.i]
+	}
+
+	metrics.GetOrRegisterCounter("localstore.syncbatch.write", nil).Inc(1)
+	metrics.GetOrRegisterCounter("localstore.syncbatch.chunks", nil).Inc(int64(len(chs)))
+	metrics.GetOrRegisterCounter("localstore.syncbatch.requests", nil).Inc(int64(len(requests)))
+
+	exist, err := b.db.put(chunk.ModePutSync, chs...)
+	for i, f := range refs {
+		if err != nil {
+			f.r.err = err
+			continue
+		}
+		f.r.exist[f.i] = exist[i]
+	}
+	for _, r := range requests {
+		if r.err != nil {
+			r.exist = nil
+		}
+		close(r.done)
+	}
+}
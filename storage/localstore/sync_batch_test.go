@@ -0,0 +1,201 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethersphere/swarm/chunk"
+)
+
+// TestDB_syncBatch validates that chunks put concurrently with ModePutSync
+// are stored in batches with correct exist results, and that chunks
+// queued when the database is closed are written.
+func TestDB_syncBatch(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		size  int
+		delay time.Duration
+	}{
+		{"size", 10, time.Second},
+		{"delay", 1000, 10 * time.Millisecond},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db, cleanupFunc := newTestDB(t, &Options{
+				SyncBatchSize:  tc.size,
+				SyncBatchDelay: tc.delay,
+			})
+			defer cleanupFunc()
+
+			chunks := make([]chunk.Chunk, 50)
+			for i := range chunks {
+				chunks[i] = generateTestRandomChunk()
+			}
+
+			var wg sync.WaitGroup
+			for i, ch := range chunks {
+				wg.Add(1)
+				go func(i int, ch chunk.Chunk) {
+					defer wg.Done()
+					// the same chunk is put twice in the same call
+					exist, err := db.Put(context.Background(), chunk.ModePutSync, ch, ch)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if exist[0] || !exist[1] {
+						t.Errorf("got exist %v for chunk %v, want [false true]", exist, i)
+					}
+				}(i, ch)
+			}
+			wg.Wait()
+
+			for _, ch := range chunks {
+				got, err := db.Get(context.Background(), chunk.ModeGetSync, ch.Address())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got.Data(), ch.Data()) {
+					t.Errorf("got data %x, want %x", got.Data(), ch.Data())
+				}
+			}
+
+			exist, err := db.Put(context.Background(), chunk.ModePutSync, chunks[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !exist[0] {
+				t.Error("stored chunk does not exist")
+			}
+
+			t.Run("pull index count", newItemsCountTest(db.pullIndex, len(chunks)))
+
+			t.Run("gc size", newIndexGCSizeTest(db))
+		})
+	}
+}
+
+// TestDB_syncBatchClose validates that ModePutSync Put calls
+// do not block when the database is closed.
+func TestDB_syncBatchClose(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, &Options{
+		SyncBatchDelay: time.Minute,
+	})
+	cleanupFunc()
+
+	select {
+	case <-db.syncBatcher.done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("sync batcher not stopped")
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := db.Put(context.Background(), chunk.ModePutSync, generateTestRandomChunk())
+		errc <- err
+	}()
+
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Fatal("expected error putting to a closed database")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("put blocked on a closed database")
+	}
+}
+
+// BenchmarkPutSync compares writing chunks received by syncing with
+// and without batching of concurrent Put calls.
+//
+// goos: linux
+// goarch: amd64
+// pkg: github.com/ethersphere/swarm/storage/localstore
+// BenchmarkPutSync/count_10000_parallel_1_nobatch              2   1473955882 ns/op
+// BenchmarkPutSync/count_10000_parallel_1_batch_128_2ms        2   1461271788 ns/op
+// BenchmarkPutSync/count_10000_parallel_16_nobatch             2   1671710204 ns/op
+// BenchmarkPutSync/count_10000_parallel_16_batch_128_2ms       2   1718611578 ns/op
+// BenchmarkPutSync/count_10000_parallel_128_nobatch            2   1732973547 ns/op
+// BenchmarkPutSync/count_10000_parallel_128_batch_128_2ms      2   1640694382 ns/op
+func BenchmarkPutSync(b *testing.B) {
+	for _, count := range []int{
+		1000,
+		10000,
+	} {
+		for _, parallel := range []int{
+			1,
+			16,
+			128,
+		} {
+			for _, o := range []struct {
+				name string
+				o    *Options
+			}{
+				{"nobatch", nil},
+				{"batch 128 2ms", &Options{SyncBatchSize: 128, SyncBatchDelay: 2 * time.Millisecond}},
+			} {
+				name := fmt.Sprintf("count %v parallel %v %s", count, parallel, o.name)
+				b.Run(name, func(b *testing.B) {
+					for n := 0; n < b.N; n++ {
+						benchmarkPutSync(b, o.o, count, parallel)
+					}
+				})
+			}
+		}
+	}
+}
+
+// benchmarkPutSync runs a benchmark by putting a specific number
+// of chunks with ModePutSync with specified max parallel calls.
+func benchmarkPutSync(b *testing.B, o *Options, count, parallel int) {
+	b.StopTimer()
+	db, cleanupFunc := newTestDB(b, o)
+	defer cleanupFunc()
+
+	chunks := make([]chunk.Chunk, count)
+	for i := 0; i < count; i++ {
+		chunks[i] = generateTestRandomChunk()
+	}
+	errs := make(chan error)
+	b.StartTimer()
+
+	go func() {
+		sem := make(chan struct{}, parallel)
+		for i := 0; i < count; i++ {
+			sem <- struct{}{}
+
+			go func(i int) {
+				defer func() { <-sem }()
+
+				_, err := db.Put(context.Background(), chunk.ModePutSync, chunks[i])
+				errs <- err
+			}(i)
+		}
+	}()
+
+	for i := 0; i < count; i++ {
+		err := <-errs
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
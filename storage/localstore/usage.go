@@ -0,0 +1,134 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"sync"
+
+	"github.com/ethersphere/swarm/shed"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// OwnerUsage is the storage used by the chunks accounted to an owner.
+type OwnerUsage struct {
+	Chunks uint64 `json:"chunks"`
+	Bytes  uint64 `json:"bytes"`
+}
+
+// usageChanges are the changes of the owners usage made by a batch,
+// applied once the batch is written.
+type usageChanges map[string]*usageChange
+
+type usageChange struct {
+	chunks int64
+	bytes  int64
+}
+
+// add records that the chunk of the item is added to or removed from
+// the chunks accounted to its owner.
+func (c usageChanges) add(item shed.Item, removed bool) {
+	change := c[item.Owner]
+	if change == nil {
+		change = new(usageChange)
+		c[item.Owner] = change
+	}
+	if removed {
+		change.chunks--
+		change.bytes -= int64(item.Size)
+	} else {
+		change.chunks++
+		change.bytes += int64(item.Size)
+	}
+}
+
+// ownerUsage keeps the storage usage of every owner, so that it is
+// known without iterating the owner index. The usage is counted once
+// when the database is opened and then updated by every written batch.
+type ownerUsage struct {
+	mu     sync.Mutex
+	owners map[string]OwnerUsage
+}
+
+// newOwnerUsage counts the chunks in the owner index by their owners.
+func (db *DB) newOwnerUsage() (u *ownerUsage, err error) {
+	changes := make(usageChanges)
+	err = db.ownerIndex.Iterate(func(item shed.Item) (stop bool, err error) {
+		changes.add(item, false)
+		return false, nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	u = &ownerUsage{owners: make(map[string]OwnerUsage)}
+	u.add(changes)
+	return u, nil
+}
+
+// add applies the changes of a written batch.
+func (u *ownerUsage) add(changes usageChanges) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for owner, change := range changes {
+		usage := u.owners[owner]
+		usage.Chunks = uint64(int64(usage.Chunks) + change.chunks)
+		usage.Bytes = uint64(int64(usage.Bytes) + change.bytes)
+		if usage.Chunks == 0 {
+			delete(u.owners, owner)
+			continue
+		}
+		u.owners[owner] = usage
+	}
+}
+
+// Usage returns the storage used by the chunks accounted to every owner.
+// Chunks are accounted to the owner of the upload tag that stored them
+// first, chunks stored without an owner are not accounted.
+func (db *DB) Usage() (owners map[string]OwnerUsage) {
+	db.usage.mu.Lock()
+	defer db.usage.mu.Unlock()
+
+	owners = make(map[string]OwnerUsage, len(db.usage.owners))
+	for owner, usage := range db.usage.owners {
+		owners[owner] = usage
+	}
+	return owners
+}
+
+// OwnerUsage returns the storage used by the chunks accounted to the owner.
+func (db *DB) OwnerUsage(owner string) OwnerUsage {
+	db.usage.mu.Lock()
+	defer db.usage.mu.Unlock()
+
+	return db.usage.owners[owner]
+}
+
+// removeOwnerInBatch removes the chunk of the item from the owner index,
+// recording the change of its owner usage if it is accounted to an owner.
+func (db *DB) removeOwnerInBatch(batch *leveldb.Batch, item shed.Item, changes usageChanges) (err error) {
+	i, err := db.ownerIndex.Get(item)
+	switch err {
+	case nil:
+		db.ownerIndex.DeleteInBatch(batch, item)
+		changes.add(i, true)
+		return nil
+	case leveldb.ErrNotFound:
+		return nil
+	default:
+		return err
+	}
+}
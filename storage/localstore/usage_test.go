@@ -0,0 +1,135 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/ethersphere/swarm/chunk"
+)
+
+// TestDB_Usage validates that the storage used by uploaded chunks is
+// accounted to the owner of their upload tag, that removed and garbage
+// collected chunks are no longer accounted and that the usage is
+// counted again when the database is opened.
+func TestDB_Usage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "localstore-usage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	baseKey := make([]byte, 32)
+	if _, err := rand.Read(baseKey); err != nil {
+		t.Fatal(err)
+	}
+	tags := chunk.NewTags()
+	db, err := New(dir, baseKey, &Options{Tags: tags})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aliceTag, err := tags.Create("alice", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliceTag.Owner = "alice"
+	bobTag, err := tags.Create("bob", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobTag.Owner = "bob"
+
+	var bytes uint64
+	upload := func(count int, tagID uint32) (addrs []chunk.Address) {
+		for i := 0; i < count; i++ {
+			ch := generateTestRandomChunk().WithTagID(tagID)
+			if _, err := db.Put(context.Background(), chunk.ModePutUpload, ch); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.Set(context.Background(), chunk.ModeSetSyncPull, ch.Address()); err != nil {
+				t.Fatal(err)
+			}
+			bytes = uint64(len(ch.Data()))
+			addrs = append(addrs, ch.Address())
+		}
+		return addrs
+	}
+	alice := upload(3, aliceTag.Uid)
+	upload(2, bobTag.Uid)
+	upload(2, 0)
+
+	// a chunk stored again is not accounted to another owner
+	if _, err := db.Put(context.Background(), chunk.ModePutUpload, chunk.NewChunk(alice[0], nil).WithTagID(bobTag.Uid)); err != nil {
+		t.Fatal(err)
+	}
+
+	checkUsage := func(want map[string]OwnerUsage) {
+		t.Helper()
+		if got := db.Usage(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("got usage %v, want %v", got, want)
+		}
+		for owner, usage := range want {
+			if got := db.OwnerUsage(owner); got != usage {
+				t.Fatalf("got usage %v of %s, want %v", got, owner, usage)
+			}
+		}
+	}
+	checkUsage(map[string]OwnerUsage{
+		"alice": {Chunks: 3, Bytes: 3 * bytes},
+		"bob":   {Chunks: 2, Bytes: 2 * bytes},
+	})
+
+	info, err := db.ChunkInfo(alice[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Owner != "alice" {
+		t.Fatalf("got chunk owner %q, want alice", info.Owner)
+	}
+
+	if err := db.Set(context.Background(), chunk.ModeSetRemove, alice[2]); err != nil {
+		t.Fatal(err)
+	}
+	checkUsage(map[string]OwnerUsage{
+		"alice": {Chunks: 2, Bytes: 2 * bytes},
+		"bob":   {Chunks: 2, Bytes: 2 * bytes},
+	})
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db, err = New(dir, baseKey, &Options{Tags: tags})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	checkUsage(map[string]OwnerUsage{
+		"alice": {Chunks: 2, Bytes: 2 * bytes},
+		"bob":   {Chunks: 2, Bytes: 2 * bytes},
+	})
+
+	if _, _, err := db.collectGarbageToTarget(0, 100); err != nil {
+		t.Fatal(err)
+	}
+	checkUsage(map[string]OwnerUsage{})
+	t.Run("owner index count", newItemsCountTest(db.ownerIndex, 0))
+}
@@ -0,0 +1,213 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package faulty implements a mock store that wraps another mock store
+// and injects configurable faults into its operations: latency, errors
+// and corrupted chunk data. It is intended for simulations and integration
+// tests of retrieval retries, erasure coding and repair subsystems.
+package faulty
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethersphere/swarm/storage/mock"
+)
+
+// ErrInjected is returned by Get and Put when a fault is injected.
+var ErrInjected = errors.New("injected fault")
+
+// Options configure which faults are injected and how often.
+// Rates are probabilities in range [0,1] and are evaluated independently
+// for every operation. Zero value Options inject no faults.
+type Options struct {
+	// Latency returns the delay applied to every Get and Put call.
+	// No delay is applied if it is nil.
+	Latency func(r *rand.Rand) time.Duration
+	// GetErrorRate is the probability of Get returning ErrInjected.
+	GetErrorRate float64
+	// PutErrorRate is the probability of Put returning ErrInjected
+	// without storing the chunk data.
+	PutErrorRate float64
+	// CorruptRate is the probability of Get returning chunk
+	// data with at least one byte altered.
+	CorruptRate float64
+	// Seed for the pseudo random source, making injected faults
+	// reproducible for the same sequence of operations.
+	Seed int64
+}
+
+// Stats holds the number of injected faults.
+type Stats struct {
+	GetErrors   uint64
+	PutErrors   uint64
+	Corruptions uint64
+}
+
+// GlobalStore wraps a mock.GlobalStorer and injects faults into
+// Get and Put calls. All other methods are passed to the wrapped store.
+// It implements mock.GlobalStorer interface.
+type GlobalStore struct {
+	mock.GlobalStorer
+	o     Options
+	rand  *rand.Rand
+	mu    sync.Mutex // protects rand
+	stats Stats
+}
+
+// NewGlobalStore creates a new instance of GlobalStore that injects
+// faults configured by options into the provided store.
+func NewGlobalStore(store mock.GlobalStorer, o Options) *GlobalStore {
+	return &GlobalStore{
+		GlobalStorer: store,
+		o:            o,
+		rand:         rand.New(rand.NewSource(o.Seed)),
+	}
+}
+
+// NewNodeStore returns a new instance of NodeStore that retrieves and stores
+// chunk data only for a node with address addr, with faults injected.
+func (s *GlobalStore) NewNodeStore(addr common.Address) *mock.NodeStore {
+	return mock.NewNodeStore(addr, s)
+}
+
+// Get returns chunk data from the wrapped store after the configured
+// latency, unless an error or data corruption is injected.
+func (s *GlobalStore) Get(addr common.Address, key []byte) (data []byte, err error) {
+	s.delay()
+	if s.chance(s.o.GetErrorRate) {
+		atomic.AddUint64(&s.stats.GetErrors, 1)
+		return nil, ErrInjected
+	}
+	data, err = s.GlobalStorer.Get(addr, key)
+	if err != nil || len(data) == 0 {
+		return data, err
+	}
+	if s.chance(s.o.CorruptRate) {
+		atomic.AddUint64(&s.stats.Corruptions, 1)
+		data = s.corrupt(data)
+	}
+	return data, nil
+}
+
+// Put saves chunk data to the wrapped store after the configured
+// latency, unless an error is injected.
+func (s *GlobalStore) Put(addr common.Address, key []byte, data []byte) error {
+	s.delay()
+	if s.chance(s.o.PutErrorRate) {
+		atomic.AddUint64(&s.stats.PutErrors, 1)
+		return ErrInjected
+	}
+	return s.GlobalStorer.Put(addr, key, data)
+}
+
+// Import passes the tar archive to the wrapped store if it implements
+// mock.Importer interface.
+func (s *GlobalStore) Import(r io.Reader) (n int, err error) {
+	i, ok := s.GlobalStorer.(mock.Importer)
+	if !ok {
+		return 0, errors.New("wrapped store does not support import")
+	}
+	return i.Import(r)
+}
+
+// Export writes the tar archive of the wrapped store if it implements
+// mock.Exporter interface.
+func (s *GlobalStore) Export(w io.Writer) (n int, err error) {
+	e, ok := s.GlobalStorer.(mock.Exporter)
+	if !ok {
+		return 0, errors.New("wrapped store does not support export")
+	}
+	return e.Export(w)
+}
+
+// Stats returns the number of faults injected so far.
+func (s *GlobalStore) Stats() Stats {
+	return Stats{
+		GetErrors:   atomic.LoadUint64(&s.stats.GetErrors),
+		PutErrors:   atomic.LoadUint64(&s.stats.PutErrors),
+		Corruptions: atomic.LoadUint64(&s.stats.Corruptions),
+	}
+}
+
+// delay blocks for the duration returned by the Latency option.
+func (s *GlobalStore) delay() {
+	if s.o.Latency == nil {
+		return
+	}
+	s.mu.Lock()
+	d := s.o.Latency(s.rand)
+	s.mu.Unlock()
+	if d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// chance returns true with the provided probability.
+func (s *GlobalStore) chance(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rand.Float64() < rate
+}
+
+// corrupt returns a copy of data with one random byte altered.
+func (s *GlobalStore) corrupt(data []byte) []byte {
+	c := make([]byte, len(data))
+	copy(c, data)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c[s.rand.Intn(len(c))] ^= byte(1 + s.rand.Intn(255))
+	return c
+}
+
+// ConstantLatency returns a Latency option that always delays for d.
+func ConstantLatency(d time.Duration) func(*rand.Rand) time.Duration {
+	return func(*rand.Rand) time.Duration {
+		return d
+	}
+}
+
+// UniformLatency returns a Latency option with delays
+// uniformly distributed in range [min, max).
+func UniformLatency(min, max time.Duration) func(*rand.Rand) time.Duration {
+	return func(r *rand.Rand) time.Duration {
+		if max <= min {
+			return min
+		}
+		return min + time.Duration(r.Int63n(int64(max-min)))
+	}
+}
+
+// NormalLatency returns a Latency option with normally distributed
+// delays with the provided mean and standard deviation. Negative
+// samples are truncated to zero.
+func NormalLatency(mean, stddev time.Duration) func(*rand.Rand) time.Duration {
+	return func(r *rand.Rand) time.Duration {
+		d := time.Duration(r.NormFloat64()*float64(stddev)) + mean
+		if d < 0 {
+			return 0
+		}
+		return d
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package faulty
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethersphere/swarm/storage/mock/mem"
+	"github.com/ethersphere/swarm/storage/mock/test"
+)
+
+// TestGlobalStore is running test for a GlobalStore
+// that injects no faults using test.MockStore function.
+func TestGlobalStore(t *testing.T) {
+	test.MockStore(t, NewGlobalStore(mem.NewGlobalStore(), Options{}), 100)
+}
+
+// TestImportExport is running tests for importing and
+// exporting data between two wrapped GlobalStores
+// using test.ImportExport function.
+func TestImportExport(t *testing.T) {
+	test.ImportExport(t, NewGlobalStore(mem.NewGlobalStore(), Options{}), NewGlobalStore(mem.NewGlobalStore(), Options{}), 100)
+}
+
+// TestErrors validates that errors are injected with
+// the configured rates for Get and Put calls.
+func TestErrors(t *testing.T) {
+	addr := common.HexToAddress("0x01")
+	key := []byte("key")
+
+	s := NewGlobalStore(mem.NewGlobalStore(), Options{PutErrorRate: 1})
+	if err := s.Put(addr, key, []byte("data")); err != ErrInjected {
+		t.Fatalf("got error %v, want %v", err, ErrInjected)
+	}
+	if s.HasKey(addr, key) {
+		t.Fatal("chunk stored on injected put error")
+	}
+
+	s = NewGlobalStore(mem.NewGlobalStore(), Options{GetErrorRate: 0.5, Seed: 1})
+	if err := s.Put(addr, key, []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	n := 1000
+	for i := 0; i < n; i++ {
+		_, err := s.Get(addr, key)
+		if err != nil && err != ErrInjected {
+			t.Fatal(err)
+		}
+	}
+	stats := s.Stats()
+	if stats.GetErrors < uint64(n)/3 || stats.GetErrors > uint64(n)*2/3 {
+		t.Errorf("got %v injected errors for %v get calls with rate 0.5", stats.GetErrors, n)
+	}
+	if stats.PutErrors != 0 {
+		t.Errorf("got %v put errors, want 0", stats.PutErrors)
+	}
+}
+
+// TestCorruption validates that corrupted data is returned
+// by Get without altering the stored chunk data.
+func TestCorruption(t *testing.T) {
+	inner := mem.NewGlobalStore()
+	s := NewGlobalStore(inner, Options{CorruptRate: 1})
+	addr := common.HexToAddress("0x01")
+	key := []byte("key")
+	data := []byte("some chunk data")
+
+	if err := s.Put(addr, key, data); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.NewNodeStore(addr).Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(data) || bytes.Equal(got, data) {
+		t.Fatalf("got data %x, want corrupted %x", got, data)
+	}
+	stored, err := inner.Get(addr, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stored, data) {
+		t.Fatalf("stored data altered, got %x, want %x", stored, data)
+	}
+	if c := s.Stats().Corruptions; c != 1 {
+		t.Fatalf("got %v corruptions, want 1", c)
+	}
+}
+
+// TestLatency validates that the configured latency
+// is applied and that distributions respect their bounds.
+func TestLatency(t *testing.T) {
+	delay := 20 * time.Millisecond
+	s := NewGlobalStore(mem.NewGlobalStore(), Options{Latency: ConstantLatency(delay)})
+	start := time.Now()
+	if err := s.Put(common.HexToAddress("0x01"), []byte("key"), []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < delay {
+		t.Fatalf("put took %v, want at least %v", d, delay)
+	}
+
+	r := rand.New(rand.NewSource(1))
+	uniform := UniformLatency(time.Millisecond, 2*time.Millisecond)
+	normal := NormalLatency(time.Millisecond, 10*time.Millisecond)
+	for i := 0; i < 1000; i++ {
+		if d := uniform(r); d < time.Millisecond || d >= 2*time.Millisecond {
+			t.Fatalf("uniform latency %v out of range", d)
+		}
+		if d := normal(r); d < 0 {
+			t.Fatalf("negative normal latency %v", d)
+		}
+	}
+}
@@ -23,6 +23,7 @@
 //  - db - LevelDB backend
 //  - mem - in memory map backend
 //  - rpc - RPC client that can connect to other backends
+//  - faulty - wrapper of other backends with fault injection
 //
 // Mock storages can implement Importer and Exporter interfaces
 // for importing and exporting all chunk data that they contain.
@@ -54,6 +54,8 @@ type Fetcher struct {
 	Delivered chan struct{} // when closed, it means that the chunk this Fetcher refers to is delivered
 	Chunk     chunk.Chunk   // the delivered chunk data
 
+	rerequest chan struct{} // signals that the chunk should be requested from the next peer right away
+
 	// it is possible for multiple actors to be delivering the same chunk,
 	// for example through syncing and through retrieve request. however we want the `Delivered` channel to be closed only
 	// once, even if we put the same chunk multiple times in the NetStore.
@@ -69,6 +71,7 @@ type Fetcher struct {
 func NewFetcher() *Fetcher {
 	return &Fetcher{
 		Delivered:         make(chan struct{}),
+		rerequest:         make(chan struct{}, 1),
 		once:              sync.Once{},
 		CreatedAt:         time.Now(),
 		CreatedBy:         "",
@@ -88,6 +91,10 @@ func (fi *Fetcher) SafeClose(ch chunk.Chunk) {
 
 type RemoteGetFunc func(ctx context.Context, req *Request, localID enode.ID) (*enode.ID, error)
 
+// SearchTimeoutFunc returns how long to wait for a chunk delivery from a peer
+// before the request is sent to the next one
+type SearchTimeoutFunc func(peer enode.ID) time.Duration
+
 // NetStore is an extension of LocalStore
 // it implements the ChunkStore interface
 // on request it initiates remote cloud retrieval
@@ -98,7 +105,9 @@ type NetStore struct {
 	putMu        sync.Mutex
 	requestGroup singleflight.Group
 	RemoteGet    RemoteGetFunc
-	logger       log.Logger
+	// SearchTimeout sets a per-peer search timeout, if nil timeouts.SearchTimeout is used
+	SearchTimeout SearchTimeoutFunc
+	logger        log.Logger
 }
 
 // NewNetStore creates a new NetStore using the provided chunk.Store and localID of the node.
@@ -123,6 +132,11 @@ func (n *NetStore) Put(ctx context.Context, mode chunk.ModePut, chs ...Chunk) ([
 		n.logger.Trace("netstore.put", "index", i, "ref", ch.Address().String(), "mode", mode)
 		fi, ok := n.fetchers.Get(ch.Address().String())
 		if ok {
+			// chunks failing validation must not reach the goroutines waiting on the fetcher
+			if v, isValidator := n.Store.(chunk.Validator); isValidator && !v.Validate(ch) {
+				n.putMu.Unlock()
+				return nil, ErrChunkInvalid
+			}
 			// we need SafeClose, because it is possible for a chunk to both be
 			// delivered through syncing and through a retrieve request
 			fii := fi.(*Fetcher)
@@ -226,8 +240,8 @@ func (n *NetStore) Get(ctx context.Context, mode chunk.ModeGet, req *Request) (c
 
 // RemoteFetch is handling the retry mechanism when making a chunk request to our peers.
 // For a given chunk Request, we call RemoteGet, which selects the next eligible peer and
-// issues a RetrieveRequest and we wait for a delivery. If a delivery doesn't arrive within the SearchTimeout
-// we retry.
+// issues a RetrieveRequest and we wait for a delivery. If a delivery doesn't arrive within the search
+// timeout of the selected peer we retry.
 func (n *NetStore) RemoteFetch(ctx context.Context, req *Request, fi *Fetcher) (chunk.Chunk, error) {
 	// while we haven't timed-out, and while we don't have a chunk,
 	// iterate over peers and try to find a chunk
@@ -247,6 +261,12 @@ func (n *NetStore) RemoteFetch(ctx context.Context, req *Request, fi *Fetcher) (
 
 		log.Trace("remote.fetch", "ref", ref)
 
+		// drop a rerequest signalled for the previous peer
+		select {
+		case <-fi.rerequest:
+		default:
+		}
+
 		currentPeer, err := n.RemoteGet(ctx, req, n.LocalID)
 		if err != nil {
 			n.logger.Trace(err.Error(), "ref", ref)
@@ -266,7 +286,14 @@ func (n *NetStore) RemoteFetch(ctx context.Context, req *Request, fi *Fetcher) (
 			osp.LogFields(olog.Bool("delivered", true))
 			osp.Finish()
 			return fi.Chunk, nil
-		case <-time.After(timeouts.SearchTimeout):
+		case <-fi.rerequest:
+			metrics.GetOrRegisterCounter("remote.fetch.rerequest", nil).Inc(1)
+			n.logger.Debug("remote.fetch, requesting from the next peer", "ref", ref, "peer", currentPeer.String())
+
+			osp.LogFields(olog.Bool("rerequest", true))
+			osp.Finish()
+			break
+		case <-time.After(n.searchTimeout(*currentPeer)):
 			metrics.GetOrRegisterCounter("remote.fetch.timeout.search", nil).Inc(1)
 
 			osp.LogFields(olog.Bool("timeout", true))
@@ -283,6 +310,32 @@ func (n *NetStore) RemoteFetch(ctx context.Context, req *Request, fi *Fetcher) (
 	}
 }
 
+// Rerequest makes a pending remote fetch of the chunk request it from the
+// next peer without waiting for the search timeout of the current peer,
+// for example after the peer delivered an invalid chunk.
+// It returns false if the chunk is not being fetched.
+func (n *NetStore) Rerequest(ref Address) bool {
+	n.putMu.Lock()
+	defer n.putMu.Unlock()
+	fi, ok := n.fetchers.Get(ref.String())
+	if !ok {
+		return false
+	}
+	select {
+	case fi.(*Fetcher).rerequest <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// searchTimeout returns how long to wait for a chunk delivery from the peer
+func (n *NetStore) searchTimeout(peer enode.ID) time.Duration {
+	if n.SearchTimeout == nil {
+		return timeouts.SearchTimeout
+	}
+	return n.SearchTimeout(peer)
+}
+
 // Has is the storage layer entry point to query the underlying
 // database to return if it has a chunk or not.
 func (n *NetStore) Has(ctx context.Context, ref Address) (bool, error) {
@@ -0,0 +1,113 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pin
+
+import (
+	"context"
+
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/storage"
+)
+
+// ForgetResult reports the outcome of removing the local copy of a file or collection
+type ForgetResult struct {
+	Chunks  int    `json:"chunks"`  // number of distinct chunks of the content found in the local store
+	Shared  int    `json:"shared"`  // number of chunks referenced more than once by the content
+	Pinned  int    `json:"pinned"`  // number of chunks kept because they are pinned
+	Removed int    `json:"removed"` // number of chunks removed, or that would be removed on a dry run
+	Bytes   uint64 `json:"bytes"`   // size of the chunk data removed, or that would be reclaimed on a dry run
+	DryRun  bool   `json:"dryRun"`
+}
+
+// Forget walks the chunk tree of a file or collection and removes its chunks
+// from the local store. Every chunk is counted once no matter how many times
+// the content references it, and chunks that are pinned, by this or any other
+// content, are kept. Chunks missing from the local store are not walked.
+// If dryRun is set, nothing is removed and the result reports the chunks and
+// bytes that would be reclaimed.
+func (p *API) Forget(addr []byte, isRaw bool, credentials string, dryRun bool) (*ForgetResult, error) {
+	ctx := context.Background()
+	has, err := p.db.Has(ctx, chunk.Address(p.removeDecryptionKeyFromChunkHash(addr)))
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, errFileNotUploaded
+	}
+
+	// references counts how many times the walked content references
+	// each locally stored chunk, so that shared chunks are visited once
+	references := make(map[string]int)
+	var addrs []chunk.Address
+	check := func(ref storage.Reference) bool {
+		chunkAddr := chunk.Address(p.removeDecryptionKeyFromChunkHash(ref))
+		references[string(chunkAddr)]++
+		if references[string(chunkAddr)] > 1 {
+			return false
+		}
+		has, err := p.db.Has(ctx, chunkAddr)
+		if err != nil {
+			log.Error("Error checking chunk in localstore", "Address", chunkAddr, "err", err)
+			return false
+		}
+		if has {
+			addrs = append(addrs, chunkAddr)
+		}
+		return has
+	}
+
+	files, err := p.fileRefs(ctx, addr, isRaw, credentials)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if err := p.verifyFile(ctx, file, check); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &ForgetResult{
+		Chunks: len(addrs),
+		DryRun: dryRun,
+	}
+	var remove []chunk.Address
+	for _, chunkAddr := range addrs {
+		if references[string(chunkAddr)] > 1 {
+			result.Shared++
+		}
+		if _, err := p.db.Get(ctx, chunk.ModeGetPin, chunkAddr); err == nil {
+			result.Pinned++
+			continue
+		}
+		ch, err := p.db.Get(ctx, chunk.ModeGetLookup, chunkAddr)
+		if err != nil {
+			return nil, err
+		}
+		result.Bytes += uint64(len(ch.Data()))
+		remove = append(remove, chunkAddr)
+	}
+	result.Removed = len(remove)
+	if dryRun || len(remove) == 0 {
+		return result, nil
+	}
+	if err := p.db.Set(ctx, chunk.ModeSetRemove, remove...); err != nil {
+		return nil, err
+	}
+	log.Debug("File forgotten", "Address", storage.Address(addr), "removed", result.Removed, "pinned", result.Pinned)
+	return result, nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pin
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/testutil"
+)
+
+// TestForget removes the local copy of a file which references one of its
+// chunks several times and shares it with a pinned file
+func TestForget(t *testing.T) {
+	p, f, closeFunc := getPinApiAndFileStore(t)
+	defer closeFunc()
+
+	ctx := context.Background()
+	block := testutil.RandomBytes(1, chunk.DefaultSize)
+	data := append(bytes.Repeat(block, 3), testutil.RandomBytes(2, 1000)...)
+	hash := uploadFile(t, f, data, false)
+	pinned := uploadFile(t, f, append(block, testutil.RandomBytes(3, 1000)...), false)
+	if err := p.PinFiles(pinned, true, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// root chunk, the repeated block and the trailing data chunk
+	want := ForgetResult{
+		Chunks:  3,
+		Shared:  1,
+		Pinned:  1,
+		Removed: 2,
+		Bytes:   8 + 4*32 + 8 + 1000,
+		DryRun:  true,
+	}
+	result, err := p.Forget(hash, true, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *result != want {
+		t.Fatalf("got dry run result %+v, want %+v", *result, want)
+	}
+	if has, err := p.db.Has(ctx, chunk.Address(hash)); err != nil || !has {
+		t.Fatalf("expected root chunk to be kept on a dry run: %v", err)
+	}
+
+	want.DryRun = false
+	result, err = p.Forget(hash, true, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *result != want {
+		t.Fatalf("got result %+v, want %+v", *result, want)
+	}
+	if has, err := p.db.Has(ctx, chunk.Address(hash)); err != nil || has {
+		t.Fatalf("expected root chunk to be removed: %v", err)
+	}
+	refs, err := f.GetAllReferences(ctx, bytes.NewReader(block))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if has, err := p.db.Has(ctx, refs[0]); err != nil || !has {
+		t.Fatalf("expected pinned chunk to be kept: %v", err)
+	}
+
+	if _, err := p.Forget(hash, true, "", false); err != errFileNotUploaded {
+		t.Fatalf("expected error %v for forgotten content, got %v", errFileNotUploaded, err)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/ethersphere/swarm/api"
 	"github.com/ethersphere/swarm/chunk"
@@ -128,7 +129,7 @@ func (p *API) PinFiles(addr []byte, isRaw bool, credentials string) error {
 		}
 		return nil
 	}
-	err = p.walkChunksFromRootHash(addr, isRaw, credentials, walkerFunction)
+	err = p.walkChunksFromRootHash(addr, isRaw, credentials, once(walkerFunction))
 	if err != nil {
 		log.Error("Error walking root hash.", "Hash", hex.EncodeToString(addr), "err", err)
 		return nil
@@ -206,7 +207,7 @@ func (p *API) UnpinFiles(addr []byte, credentials string) error {
 		}
 		return nil
 	}
-	err = p.walkChunksFromRootHash(addr, pinInfo.IsRaw, credentials, walkerFunction)
+	err = p.walkChunksFromRootHash(addr, pinInfo.IsRaw, credentials, once(walkerFunction))
 	if err != nil {
 		log.Error("Error walking root hash.", "Hash", hex.EncodeToString(addr), "err", err)
 		return nil
@@ -268,6 +269,12 @@ func (p *API) ListPins() ([]PinInfo, error) {
 	return pinnedFiles, nil
 }
 
+// Expiry returns the time to live hint the file or collection was uploaded with,
+// as stored with its root chunk. Zero time is returned if there is no hint.
+func (p *API) Expiry(addr []byte) (time.Time, error) {
+	return p.db.Expiry(chunk.Address(p.removeDecryptionKeyFromChunkHash(addr)))
+}
+
 func (p *API) walkChunksFromRootHash(addr []byte, isRaw bool, credentials string,
 	executeFunc func(storage.Reference) error) error {
 
@@ -451,6 +458,25 @@ QuitChunkFor:
 	return <-chunkErrC
 }
 
+// once wraps a walker function to be executed only once for every chunk
+// referenced by a file or collection, so that pin counters of chunks count
+// the pins of all files and collections referencing them, no matter how
+// many times each of them references the chunk
+func once(executeFunc func(storage.Reference) error) func(storage.Reference) error {
+	var mu sync.Mutex
+	seen := make(map[string]struct{})
+	return func(ref storage.Reference) error {
+		mu.Lock()
+		_, ok := seen[string(ref)]
+		seen[string(ref)] = struct{}{}
+		mu.Unlock()
+		if ok {
+			return nil
+		}
+		return executeFunc(ref)
+	}
+}
+
 func (p *API) removeDecryptionKeyFromChunkHash(ref []byte) []byte {
 	// remove the decryption key from the encrypted file hash
 	isEncrypted := len(ref) > p.hashSize
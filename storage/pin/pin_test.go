@@ -126,6 +126,56 @@ func TestWalker(t *testing.T) {
 	}
 }
 
+// TestPinSharedChunks pins two files sharing a chunk, which one of them
+// references several times, and checks that the chunk stays pinned until
+// both files are unpinned
+func TestPinSharedChunks(t *testing.T) {
+	p, f, closeFunc := getPinApiAndFileStore(t)
+	defer closeFunc()
+
+	block := testutil.RandomBytes(1, chunk.DefaultSize)
+	hash1 := uploadFile(t, f, append(bytes.Repeat(block, 3), testutil.RandomBytes(2, 1000)...), false)
+	hash2 := uploadFile(t, f, append(block, testutil.RandomBytes(3, 1000)...), false)
+	refs, err := f.GetAllReferences(context.Background(), bytes.NewReader(block))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared := chunk.Address(refs[0])
+
+	for _, tc := range []struct {
+		pin     bool
+		hash    storage.Address
+		counter uint64
+	}{
+		{pin: true, hash: hash1, counter: 1},
+		{pin: true, hash: hash2, counter: 2},
+		{pin: false, hash: hash1, counter: 1},
+		{pin: false, hash: hash2, counter: 0},
+	} {
+		if tc.pin {
+			err = p.PinFiles(tc.hash, true, "")
+		} else {
+			err = p.UnpinFiles(tc.hash, "")
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		counter, err := p.getPinCounterOfChunk(shared)
+		if tc.counter == 0 {
+			if err != chunk.ErrChunkNotFound {
+				t.Fatalf("expected shared chunk to be unpinned, got counter %v, error %v", counter, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if counter != tc.counter {
+			t.Fatalf("got pin counter %v of the shared chunk, want %v", counter, tc.counter)
+		}
+	}
+}
+
 // TestListPinInfo tests the ListPins command by pinning and unpinning a collection
 // twice and check if this gets reflected properly in the data structure
 func TestListPinInfo(t *testing.T) {
@@ -0,0 +1,81 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pin
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+var errFileNotUploaded = errors.New("file not found in the local store")
+
+// RPC exposes pinning to the RPC clients of the node, it is registered in the
+// "pin" namespace
+type RPC struct {
+	api *API
+}
+
+// NewRPC creates the RPC service of the pinning API
+func NewRPC(api *API) *RPC {
+	return &RPC{api: api}
+}
+
+// Pin pins a file or collection with the given root hash which must be
+// stored in the local store
+func (r *RPC) Pin(addr hexutil.Bytes, isRaw bool, credentials string) error {
+	has, err := r.api.db.Has(context.Background(), r.api.removeDecryptionKeyFromChunkHash(addr))
+	if err != nil {
+		return err
+	}
+	if !has {
+		return errFileNotUploaded
+	}
+	if err := r.api.PinFiles(addr, isRaw, credentials); err != nil {
+		return err
+	}
+	// PinFiles only logs most failures
+	if _, err := r.api.getPinnedFile(addr); err != nil {
+		return errNotPinned
+	}
+	return nil
+}
+
+// Unpin unpins a file or collection with the given root hash
+func (r *RPC) Unpin(addr hexutil.Bytes, credentials string) error {
+	return r.api.UnpinFiles(addr, credentials)
+}
+
+// List returns all pinned files and collections
+func (r *RPC) List() ([]PinInfo, error) {
+	return r.api.ListPins()
+}
+
+// Verify reports the chunks of a pinned file or collection missing from the
+// local store, and retrieves them from the network and pins them again if
+// fetch is set
+func (r *RPC) Verify(addr hexutil.Bytes, credentials string, fetch bool) (*VerifyResult, error) {
+	return r.api.Verify(addr, credentials, fetch)
+}
+
+// Forget removes the chunks of a file or collection with the given root hash
+// from the local store, keeping the pinned ones, or only reports the chunks
+// and bytes that would be reclaimed if dryRun is set
+func (r *RPC) Forget(addr hexutil.Bytes, isRaw bool, credentials string, dryRun bool) (*ForgetResult, error) {
+	return r.api.Forget(addr, isRaw, credentials, dryRun)
+}
@@ -0,0 +1,161 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pin
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+
+	"github.com/ethersphere/swarm/api"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/storage"
+)
+
+var errNotPinned = errors.New("not pinned")
+
+// VerifyResult reports the outcome of verifying a pinned file or collection
+type VerifyResult struct {
+	Missing []chunk.Address `json:"missing"` // chunks that were not found in the local store
+	Fetched int             `json:"fetched"` // number of missing chunks retrieved from the network and pinned again
+}
+
+// Verify walks the chunk tree of a pinned file or collection and reports the
+// chunks that are missing from the local store. If fetch is set, missing chunks
+// are retrieved from the network, pinned again and their subtrees verified as
+// well. Otherwise the subtrees of missing chunks can not be walked and only
+// their roots are reported.
+func (p *API) Verify(addr []byte, credentials string, fetch bool) (*VerifyResult, error) {
+	ctx := context.Background()
+	pinInfo, err := p.getPinnedFile(addr)
+	if err != nil {
+		return nil, errNotPinned
+	}
+
+	result := &VerifyResult{}
+	// check makes sure the chunk is stored locally, fetching it if needed,
+	// and reports whether it is available to be walked
+	check := func(ref storage.Reference) bool {
+		chunkAddr := chunk.Address(p.removeDecryptionKeyFromChunkHash(ref))
+		has, err := p.db.Has(ctx, chunkAddr)
+		if err != nil {
+			log.Error("Error checking chunk in localstore", "Address", chunkAddr, "err", err)
+			return false
+		}
+		if has {
+			return true
+		}
+		result.Missing = append(result.Missing, chunkAddr)
+		if !fetch {
+			return false
+		}
+		if _, err := p.api.RetrieveChunk(ctx, chunkAddr); err != nil {
+			log.Warn("Could not fetch missing pinned chunk", "Address", chunkAddr, "err", err)
+			return false
+		}
+		if err := p.repin(ctx, chunkAddr); err != nil {
+			log.Error("Could not pin fetched chunk", "Address", chunkAddr, "err", err)
+			return false
+		}
+		result.Fetched++
+		return true
+	}
+
+	if !check(addr) {
+		return result, nil
+	}
+	files, err := p.fileRefs(ctx, addr, pinInfo.IsRaw, credentials)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if err := p.verifyFile(ctx, file, check); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// fileRefs returns the root hash followed by the hashes of all files
+// in the manifest if the root hash is not a raw file
+func (p *API) fileRefs(ctx context.Context, addr []byte, isRaw bool, credentials string) ([]storage.Reference, error) {
+	files := []storage.Reference{addr}
+	if isRaw {
+		return files, nil
+	}
+	walker, err := p.api.NewManifestWalker(ctx, storage.Address(addr), p.api.Decryptor(ctx, credentials), nil)
+	if err != nil {
+		return nil, err
+	}
+	err = walker.Walk(func(entry *api.ManifestEntry) error {
+		fileAddr, err := hex.DecodeString(entry.Hash)
+		if err != nil {
+			return err
+		}
+		files = append(files, fileAddr)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// verifyFile walks the chunk tree of a file breadth first, descending only
+// into chunks that are available according to check
+func (p *API) verifyFile(ctx context.Context, fileRef storage.Reference, check func(storage.Reference) bool) error {
+	hashFunc := storage.MakeHashFunc(storage.DefaultHash)
+	hashSize := len(fileRef)
+	isEncrypted := hashSize > hashFunc().Size()
+	getter := storage.NewHasherStore(p.db, hashFunc, isEncrypted, chunk.NewTag(0, "verify-chunks-tag", 0, false))
+
+	queue := []storage.Reference{fileRef}
+	for len(queue) > 0 {
+		ref := queue[0]
+		queue = queue[1:]
+		if !check(ref) {
+			continue
+		}
+		chunkData, err := getter.Get(ctx, ref)
+		if err != nil {
+			return err
+		}
+		if len(chunkData) < 9 {
+			return errInvalidChunkData
+		}
+		if chunkData.Size() <= chunk.DefaultSize {
+			continue
+		}
+		// this is a tree chunk, load the tree's branches
+		branches := (len(chunkData) - 8) / hashSize
+		for i := 0; i < branches; i++ {
+			brAddr := make([]byte, hashSize)
+			copy(brAddr, chunkData[8+i*hashSize:8+(i+1)*hashSize])
+			queue = append(queue, storage.Reference(brAddr))
+		}
+	}
+	return nil
+}
+
+// repin pins a chunk fetched again unless its pin survived its removal
+func (p *API) repin(ctx context.Context, addr chunk.Address) error {
+	if _, err := p.db.Get(ctx, chunk.ModeGetPin, addr); err == nil {
+		return nil
+	}
+	return p.db.Set(ctx, chunk.ModeSetPin, addr)
+}
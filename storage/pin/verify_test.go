@@ -0,0 +1,122 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pin
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/ethersphere/swarm/api"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/storage"
+	"github.com/ethersphere/swarm/storage/localstore"
+	"github.com/ethersphere/swarm/testutil"
+)
+
+// TestVerify removes a chunk of a pinned file and checks that it is reported
+// missing and, if requested, fetched and pinned again
+func TestVerify(t *testing.T) {
+	p, f, closeFunc := getPinApiAndFileStore(t)
+	defer closeFunc()
+
+	ctx := context.Background()
+	data := testutil.RandomBytes(1, 10000)
+	hash := uploadFile(t, f, data, false)
+	if err := p.PinFiles(hash, true, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := p.Verify(hash, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Missing) != 0 {
+		t.Fatalf("expected no missing chunks, got %v", result.Missing)
+	}
+
+	// remove a data chunk, keeping it available on the "network"
+	refs, err := f.GetAllReferences(ctx, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var removed chunk.Address
+	for _, ref := range refs {
+		if !bytes.Equal(ref, hash) {
+			removed = ref
+			break
+		}
+	}
+	remote, err := p.db.Get(ctx, chunk.ModeGetRequest, removed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.db.Set(ctx, chunk.ModeSetRemove, removed); err != nil {
+		t.Fatal(err)
+	}
+	store := &fetchingStore{DB: p.db, remote: remote}
+	p.api = api.NewAPI(storage.NewFileStore(store, store, storage.NewFileStoreParams(), chunk.NewTags()), nil, nil, nil, nil, chunk.NewTags())
+
+	result, err = p.Verify(hash, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Missing) != 1 || !bytes.Equal(result.Missing[0], removed) || result.Fetched != 0 {
+		t.Fatalf("expected %s to be missing, got %+v", removed, result)
+	}
+
+	result, err = p.Verify(hash, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Missing) != 1 || result.Fetched != 1 {
+		t.Fatalf("expected %s to be fetched, got %+v", removed, result)
+	}
+	if _, err := p.db.Get(ctx, chunk.ModeGetPin, removed); err != nil {
+		t.Fatalf("expected fetched chunk to be pinned: %v", err)
+	}
+
+	result, err = p.Verify(hash, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Missing) != 0 {
+		t.Fatalf("expected no missing chunks after fetching, got %v", result.Missing)
+	}
+
+	if _, err := p.Verify(testutil.RandomBytes(2, 32), "", false); err != errNotPinned {
+		t.Fatalf("expected error %v for a hash that is not pinned, got %v", errNotPinned, err)
+	}
+}
+
+// fetchingStore retrieves a chunk missing from the local store from the
+// "network" and stores it, as the NetStore does
+type fetchingStore struct {
+	*localstore.DB
+	remote chunk.Chunk
+}
+
+func (s *fetchingStore) Get(ctx context.Context, mode chunk.ModeGet, addr chunk.Address) (chunk.Chunk, error) {
+	ch, err := s.DB.Get(ctx, mode, addr)
+	if err == nil || !bytes.Equal(addr, s.remote.Address()) {
+		return ch, err
+	}
+	if _, err := s.DB.Put(ctx, chunk.ModePutRequest, s.remote); err != nil {
+		return nil, err
+	}
+	return s.remote, nil
+}
@@ -32,6 +32,7 @@ type Request struct {
 	Addr        Address  // chunk address
 	Origin      enode.ID // who is sending us that request? we compare Origin to the suggested peer from RequestFromPeers
 	PeersToSkip sync.Map // peers not to request chunk from
+	ID          uint64   // id of the request kept when it is forwarded, 0 until it is sent to a peer
 }
 
 // NewRequest returns a new instance of Request based on chunk address skip check and
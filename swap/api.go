@@ -28,6 +28,20 @@ type swapAPI interface {
 	Balances() (map[enode.ID]int64, error)
 	PeerCheques(peer enode.ID) (PeerCheques, error)
 	Cheques() (map[enode.ID]*PeerCheques, error)
+	PeerChequeStats(peer enode.ID) ([]ChequeStats, error)
+	ChequeStats() (map[enode.ID][]ChequeStats, error)
+	ChequeRecords() ([]ChequeRecord, error)
+	ExportInvoices(format string) (string, error)
+	SetThresholds(paymentThreshold, disconnectThreshold int64) error
+	ExemptPeers() []string
+	AddExemptPeer(peer string) error
+	RemoveExemptPeer(peer string) (bool, error)
+	OwnerStatus() OwnerStatus
+	LockOwner() error
+	UnlockOwner(password string, seconds uint64) error
+	ChangeOwnerPassword(oldPassword, newPassword string) error
+	ExportState() (*StateExport, error)
+	ImportState(export *StateExport) error
 }
 
 // API would be the API accessor for protocol methods
@@ -87,44 +101,53 @@ func (s *Swap) AvailableBalance() (uint64, error) {
 	return liquidBalance.Uint64() + cashedChequesWorth - sentChequesWorth, nil
 }
 
-// PeerBalance returns the balance for a given peer
+// PeerBalance returns the ETH balance for a given peer,
+// balances in other assets are returned by PeerAssetBalances
 func (s *Swap) PeerBalance(peer enode.ID) (balance int64, err error) {
-	if swapPeer := s.getPeer(peer); swapPeer != nil {
-		return swapPeer.getBalance(), nil
+	if swapPeer := s.getPeer(peer); swapPeer != nil && swapPeer.asset == AssetETH {
+		swapPeer.lock.RLock()
+		defer swapPeer.lock.RUnlock()
+		return swapPeer.decayedBalance(s.clock.Time()), nil
 	}
 	err = s.store.Get(balanceKey(peer), &balance)
 	return balance, err
 }
 
-// Balances returns the balances for all known SWAP peers
+// Balances returns the ETH balances for all known SWAP peers, as balances
+// in different assets do not add up. Balances in other assets are
+// returned by PeerAssetBalances.
+// The connected peers and the stored balances are taken together, so no peer
+// is missed, but the balances of connected peers are read one peer at a time
+// and are not atomic across peers.
 func (s *Swap) Balances() (map[enode.ID]int64, error) {
 	balances := make(map[enode.ID]int64)
 
-	s.peersLock.Lock()
-	for peer, swapPeer := range s.peers {
-		swapPeer.lock.Lock()
-		balances[peer] = swapPeer.getBalance()
-		swapPeer.lock.Unlock()
-	}
-	s.peersLock.Unlock()
-
-	// add store balances, if peer was not already added
+	// store balances, overridden by those of connected peers
 	balanceIterFunction := func(key []byte, value []byte) (stop bool, err error) {
-		peer := keyToID(string(key), balancePrefix)
-		if _, peerHasBalance := balances[peer]; !peerHasBalance {
-			var peerBalance int64
-			err = json.Unmarshal(value, &peerBalance)
-			if err == nil {
-				balances[peer] = peerBalance
-			}
+		var peerBalance int64
+		err = json.Unmarshal(value, &peerBalance)
+		if err == nil {
+			balances[keyToID(string(key), balancePrefix)] = peerBalance
 		}
 		return stop, err
 	}
+	s.peersLock.RLock()
+	peers := s.peersSnapshot()
 	err := s.store.Iterate(balancePrefix, balanceIterFunction)
+	s.peersLock.RUnlock()
 	if err != nil {
 		return nil, err
 	}
 
+	for _, swapPeer := range peers {
+		if swapPeer.asset != AssetETH {
+			continue
+		}
+		swapPeer.lock.RLock()
+		balances[swapPeer.ID()] = swapPeer.decayedBalance(s.clock.Time())
+		swapPeer.lock.RUnlock()
+	}
+
 	return balances, nil
 }
 
@@ -134,9 +157,11 @@ func (s *Swap) PeerCheques(peer enode.ID) (PeerCheques, error) {
 
 	swapPeer := s.getPeer(peer)
 	if swapPeer != nil {
+		swapPeer.lock.RLock()
 		pendingCheque = swapPeer.getPendingCheque()
 		sentCheque = swapPeer.getLastSentCheque()
 		receivedCheque = swapPeer.getLastReceivedCheque()
+		swapPeer.lock.RUnlock()
 	} else {
 		errPendingCheque := s.store.Get(pendingChequeKey(peer), &pendingCheque)
 		if errPendingCheque != nil && errPendingCheque != state.ErrNotFound {
@@ -154,37 +179,58 @@ func (s *Swap) PeerCheques(peer enode.ID) (PeerCheques, error) {
 	return PeerCheques{pendingCheque, sentCheque, receivedCheque}, nil
 }
 
-// Cheques returns all known last sent and received cheques, grouped by peer
+// Cheques returns all known last sent and received cheques, grouped by peer.
+// The connected peers and the stored cheques are taken together, so no peer
+// is missed, but the cheques of connected peers are read one peer at a time
+// and are not atomic across peers.
 func (s *Swap) Cheques() (map[enode.ID]*PeerCheques, error) {
 	cheques := make(map[enode.ID]*PeerCheques)
+	stored := make(map[enode.ID]*PeerCheques)
+
+	// get peer cheques from store
+	s.peersLock.RLock()
+	peers := s.peersSnapshot()
+	err := s.addStoreCheques(pendingChequePrefix, stored)
+	if err == nil {
+		err = s.addStoreCheques(sentChequePrefix, stored)
+	}
+	if err == nil {
+		err = s.addStoreCheques(receivedChequePrefix, stored)
+	}
+	s.peersLock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
 
 	// get peer cheques from memory
-	s.peersLock.Lock()
-	for peer, swapPeer := range s.peers {
-		swapPeer.lock.Lock()
+	for _, swapPeer := range peers {
+		swapPeer.lock.RLock()
 		pendingCheque := swapPeer.getPendingCheque()
 		sentCheque := swapPeer.getLastSentCheque()
 		receivedCheque := swapPeer.getLastReceivedCheque()
 		// don't add peer to result if there are no cheques
 		if sentCheque != nil || receivedCheque != nil || pendingCheque != nil {
-			cheques[peer] = &PeerCheques{pendingCheque, sentCheque, receivedCheque}
+			cheques[swapPeer.ID()] = &PeerCheques{pendingCheque, sentCheque, receivedCheque}
 		}
-		swapPeer.lock.Unlock()
+		swapPeer.lock.RUnlock()
 	}
-	s.peersLock.Unlock()
 
-	// get peer cheques from store
-	err := s.addStoreCheques(pendingChequePrefix, cheques)
-	if err != nil {
-		return nil, err
-	}
-	err = s.addStoreCheques(sentChequePrefix, cheques)
-	if err != nil {
-		return nil, err
-	}
-	err = s.addStoreCheques(receivedChequePrefix, cheques)
-	if err != nil {
-		return nil, err
+	// stored cheques take the place of those in memory
+	for peer, storedCheques := range stored {
+		peerCheques := cheques[peer]
+		if peerCheques == nil {
+			cheques[peer] = storedCheques
+			continue
+		}
+		if storedCheques.PendingCheque != nil {
+			peerCheques.PendingCheque = storedCheques.PendingCheque
+		}
+		if storedCheques.LastSentCheque != nil {
+			peerCheques.LastSentCheque = storedCheques.LastSentCheque
+		}
+		if storedCheques.LastReceivedCheque != nil {
+			peerCheques.LastReceivedCheque = storedCheques.LastReceivedCheque
+		}
 	}
 
 	return cheques, nil
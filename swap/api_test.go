@@ -89,7 +89,7 @@ func testPeerBalance(t *testing.T, s *Swap, id enode.ID, expectedBalance int64)
 
 func addPeer(t *testing.T, s *Swap) *Peer {
 	t.Helper()
-	peer, err := s.addPeer(newDummyPeer().Peer, common.Address{}, common.Address{})
+	peer, err := s.addPeer(newDummyPeer().Peer, common.Address{}, common.Address{}, DefaultRetrievePricing)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -305,7 +305,7 @@ func testCheques(t *testing.T, testCases []chequesTestCase) {
 			// add test case peers
 			peersMapping := make(map[*protocols.Peer]*Peer)
 			for _, pp := range tc.protoPeers {
-				peer, err := swap.addPeer(pp, common.Address{}, common.Address{})
+				peer, err := swap.addPeer(pp, common.Address{}, common.Address{}, DefaultRetrievePricing)
 				if err != nil {
 					t.Fatal(err)
 				}
@@ -463,7 +463,7 @@ func testPeerCheques(t *testing.T, testCases []peerChequesTestCase) {
 			defer clean()
 
 			// add test case peer
-			peer, err := swap.addPeer(tc.peer, common.Address{}, common.Address{})
+			peer, err := swap.addPeer(tc.peer, common.Address{}, common.Address{}, DefaultRetrievePricing)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -0,0 +1,260 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/state"
+)
+
+// Asset is a settlement asset, ETH or an ERC20 token given by its address
+type Asset string
+
+// AssetETH is the settlement asset of the chequebook deployed by the node
+const AssetETH Asset = "eth"
+
+// assetPrefix prefixes the store keys of balances and cheques in assets other than ETH
+const assetPrefix = "asset_"
+
+// ParseAsset returns the asset of "eth" or of the address of an ERC20 token
+func ParseAsset(s string) (Asset, error) {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, string(AssetETH)) {
+		return AssetETH, nil
+	}
+	if !common.IsHexAddress(s) {
+		return "", fmt.Errorf("invalid settlement asset %q: must be eth or the address of an ERC20 token", s)
+	}
+	return Asset(common.HexToAddress(s).Hex()), nil
+}
+
+// AssetChequebook is a settlement asset offered in the handshake
+// with the chequebook from which cheques in the asset are paid
+type AssetChequebook struct {
+	Asset    Asset
+	Contract common.Address
+}
+
+// TokenChequebook configures a chequebook holding an ERC20 token
+type TokenChequebook struct {
+	Token    Asset          // the ERC20 token
+	Contract common.Address // the chequebook holding the token
+	Oracle   PriceOracle    // resolves the price of honey in the token, the swap price oracle if nil
+}
+
+// ParseTokenChequebook parses a token chequebook given as
+// <token address>:<chequebook address>[:<fixed honey price>]
+func ParseTokenChequebook(s string) (*TokenChequebook, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, fmt.Errorf("invalid token chequebook %q: must be <token>:<chequebook>[:<honey price>]", s)
+	}
+	token, err := ParseAsset(parts[0])
+	if err != nil || token == AssetETH {
+		return nil, fmt.Errorf("invalid token chequebook %q: %q is not a token address", s, parts[0])
+	}
+	if !common.IsHexAddress(parts[1]) {
+		return nil, fmt.Errorf("invalid token chequebook %q: %q is not a chequebook address", s, parts[1])
+	}
+	tc := &TokenChequebook{
+		Token:    token,
+		Contract: common.HexToAddress(parts[1]),
+	}
+	if len(parts) == 3 {
+		price, err := strconv.ParseUint(parts[2], 10, 64)
+		if err != nil || price == 0 {
+			return nil, fmt.Errorf("invalid token chequebook %q: %q is not a honey price", s, parts[2])
+		}
+		tc.Oracle = NewFixedPriceOracle(price)
+	}
+	return tc, nil
+}
+
+// ParseAssets parses the settlement assets in order of preference and the
+// token chequebooks of a node, and checks that every token has a chequebook
+func ParseAssets(assets, chequebooks []string) ([]Asset, []*TokenChequebook, error) {
+	var parsedAssets []Asset
+	for _, a := range assets {
+		asset, err := ParseAsset(a)
+		if err != nil {
+			return nil, nil, err
+		}
+		parsedAssets = append(parsedAssets, asset)
+	}
+	var parsedChequebooks []*TokenChequebook
+	for _, c := range chequebooks {
+		tc, err := ParseTokenChequebook(c)
+		if err != nil {
+			return nil, nil, err
+		}
+		parsedChequebooks = append(parsedChequebooks, tc)
+	}
+	if err := validateAssets(parsedAssets, parsedChequebooks); err != nil {
+		return nil, nil, err
+	}
+	return parsedAssets, parsedChequebooks, nil
+}
+
+// validateAssets checks that every settlement asset other than ETH has a chequebook
+func validateAssets(assets []Asset, chequebooks []*TokenChequebook) error {
+	seen := make(map[Asset]bool)
+	for _, a := range assets {
+		if seen[a] {
+			return fmt.Errorf("settlement asset %s is given more than once", a)
+		}
+		seen[a] = true
+		if a == AssetETH {
+			continue
+		}
+		if tokenChequebook(chequebooks, a) == nil {
+			return fmt.Errorf("settlement asset %s has no chequebook", a)
+		}
+	}
+	return nil
+}
+
+// tokenChequebook returns the chequebook holding the token, nil if there is none
+func tokenChequebook(chequebooks []*TokenChequebook, token Asset) *TokenChequebook {
+	for _, tc := range chequebooks {
+		if tc.Token == token {
+			return tc
+		}
+	}
+	return nil
+}
+
+// assets returns the settlement assets of the node in order of preference
+func (s *Swap) assets() []Asset {
+	if len(s.params.Assets) == 0 {
+		return []Asset{AssetETH}
+	}
+	return s.params.Assets
+}
+
+// assetChequebooks returns the settlement assets offered in the handshake
+func (s *Swap) assetChequebooks() []AssetChequebook {
+	var offered []AssetChequebook
+	for _, a := range s.assets() {
+		offered = append(offered, AssetChequebook{
+			Asset:    a,
+			Contract: s.chequebook(a),
+		})
+	}
+	return offered
+}
+
+// chequebook returns the address of the chequebook paying cheques in the asset
+func (s *Swap) chequebook(asset Asset) common.Address {
+	if asset == AssetETH {
+		return s.GetParams().ContractAddress
+	}
+	if tc := tokenChequebook(s.params.TokenChequebooks, asset); tc != nil {
+		return tc.Contract
+	}
+	return common.Address{}
+}
+
+// priceOracle returns the oracle resolving the price of honey in the asset
+func (s *Swap) priceOracle(asset Asset) PriceOracle {
+	if tc := tokenChequebook(s.params.TokenChequebooks, asset); tc != nil && tc.Oracle != nil {
+		return tc.Oracle
+	}
+	return s.honeyPriceOracle
+}
+
+// agreeAsset returns the settlement asset both peers use, given their assets
+// in order of preference. The common asset with the lowest sum of both
+// preference ranks is chosen, ties are broken by the asset, so that both
+// peers agree without another message. It returns false if there is no
+// common asset, in which case the peers do not settle.
+func agreeAsset(ours, theirs []AssetChequebook) (AssetChequebook, bool) {
+	var agreed AssetChequebook
+	best := -1
+	for i, o := range ours {
+		for j, t := range theirs {
+			if o.Asset != t.Asset {
+				continue
+			}
+			if rank := i + j; best < 0 || rank < best || (rank == best && t.Asset < agreed.Asset) {
+				best, agreed = rank, t
+			}
+		}
+	}
+	return agreed, best >= 0
+}
+
+// assetKey returns the store key of a balance or cheque in the asset. ETH keys
+// have no asset prefix, so that they are compatible with nodes settling only in ETH.
+func assetKey(key string, asset Asset) string {
+	if asset == AssetETH || asset == "" {
+		return key
+	}
+	return assetPrefix + string(asset) + "_" + key
+}
+
+// PeerAsset returns the settlement asset agreed with a connected peer,
+// empty if the peer is settlement-free, and false if it is not connected
+func (s *Swap) PeerAsset(peer enode.ID) (Asset, bool) {
+	swapPeer := s.getPeer(peer)
+	if swapPeer == nil {
+		return "", false
+	}
+	return swapPeer.asset, true
+}
+
+// PeerAssetBalances returns the balances with a peer in all settlement assets
+// it was ever accounted in
+func (s *Swap) PeerAssetBalances(peer enode.ID) (map[Asset]int64, error) {
+	balances := make(map[Asset]int64)
+	var balance int64
+	switch err := s.store.Get(balanceKey(peer), &balance); err {
+	case nil:
+		balances[AssetETH] = balance
+	case state.ErrNotFound:
+	default:
+		return nil, err
+	}
+	suffix := "_" + balanceKey(peer)
+	err := s.store.Iterate(assetPrefix, func(key []byte, value []byte) (stop bool, err error) {
+		k := string(key)
+		if !strings.HasSuffix(k, suffix) {
+			return false, nil
+		}
+		var balance int64
+		if err := json.Unmarshal(value, &balance); err != nil {
+			return true, err
+		}
+		balances[Asset(strings.TrimSuffix(strings.TrimPrefix(k, assetPrefix), suffix))] = balance
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// the balance of a connected peer is kept in memory
+	if swapPeer := s.getPeer(peer); swapPeer != nil && swapPeer.asset != "" {
+		swapPeer.lock.RLock()
+		balances[swapPeer.asset] = swapPeer.decayedBalance(s.clock.Time())
+		swapPeer.lock.RUnlock()
+	}
+	return balances, nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TestAgreeAsset tests that both peers agree on the same settlement asset
+// whatever their preferences and that peers without a common asset do not settle
+func TestAgreeAsset(t *testing.T) {
+	tokenA, tokenB := Asset(common.HexToAddress("0x0a").Hex()), Asset(common.HexToAddress("0x0b").Hex())
+	offer := func(assets ...Asset) []AssetChequebook {
+		var offered []AssetChequebook
+		for _, a := range assets {
+			offered = append(offered, AssetChequebook{Asset: a, Contract: common.HexToAddress("0x01")})
+		}
+		return offered
+	}
+	for _, tc := range []struct {
+		ours, theirs []AssetChequebook
+		agreed       Asset
+	}{
+		{offer(AssetETH), offer(AssetETH), AssetETH},
+		{offer(tokenA, AssetETH), offer(AssetETH, tokenA), tokenA},
+		{offer(tokenA, tokenB), offer(tokenB), tokenB},
+		{offer(tokenA, AssetETH, tokenB), offer(AssetETH, tokenB), AssetETH},
+		{offer(tokenA, AssetETH, tokenB), offer(tokenB, AssetETH), tokenB}, // tie broken by the asset
+		{offer(tokenA), offer(AssetETH), ""},
+		{offer(AssetETH), nil, ""},
+	} {
+		agreed, ok := agreeAsset(tc.ours, tc.theirs)
+		if agreed.Asset != tc.agreed || ok != (tc.agreed != "") {
+			t.Errorf("expected asset %q for %v and %v, got %q", tc.agreed, tc.ours, tc.theirs, agreed.Asset)
+		}
+		if reverse, _ := agreeAsset(tc.theirs, tc.ours); reverse.Asset != agreed.Asset {
+			t.Errorf("expected both peers to agree on %q, got %q", agreed.Asset, reverse.Asset)
+		}
+	}
+}
+
+// TestParseTokenChequebook tests parsing of settlement assets and token chequebooks
+func TestParseTokenChequebook(t *testing.T) {
+	if a, err := ParseAsset("ETH"); err != nil || a != AssetETH {
+		t.Fatalf("expected eth, got %q %v", a, err)
+	}
+	if _, err := ParseAsset("dai"); err == nil {
+		t.Fatal("expected an error for an asset which is not a token address")
+	}
+	tc, err := ParseTokenChequebook("0x000000000000000000000000000000000000000a:0x000000000000000000000000000000000000000b:3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.Token != Asset(common.HexToAddress("0x0a").Hex()) || tc.Contract != common.HexToAddress("0x0b") {
+		t.Fatalf("unexpected token chequebook %v", tc)
+	}
+	if amount, _ := tc.Oracle.GetPrice(5); amount != 15 {
+		t.Fatalf("expected amount 15 at the honey price of the chequebook, got %d", amount)
+	}
+	for _, s := range []string{"eth:0x000000000000000000000000000000000000000b", "0x000000000000000000000000000000000000000a", "0x000000000000000000000000000000000000000a:0x0b:x"} {
+		if _, err := ParseTokenChequebook(s); err == nil {
+			t.Errorf("expected an error for token chequebook %q", s)
+		}
+	}
+	if err := validateAssets([]Asset{AssetETH, tc.Token}, nil); err == nil {
+		t.Fatal("expected an error for a token without a chequebook")
+	}
+	if err := validateAssets([]Asset{AssetETH, tc.Token}, []*TokenChequebook{tc}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestAssetAccounting tests that balances are kept per settlement asset
+// and that peers without a common asset are not accounted with
+func TestAssetAccounting(t *testing.T) {
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	testDeploy(context.Background(), swap, big.NewInt(0))
+
+	token := Asset(common.HexToAddress("0x0a").Hex())
+	swap.params.Assets = []Asset{token, AssetETH}
+	swap.params.TokenChequebooks = []*TokenChequebook{{Token: token, Contract: common.HexToAddress("0x0b")}}
+
+	testPeer := newDummyPeer()
+	// the peer was accounted with in ETH before
+	if err := swap.saveBalance(testPeer.ID(), 7); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := swap.addAssetPeer(testPeer.Peer, token, swap.owner.address, swap.chequebook(token), DefaultRetrievePricing); err != nil {
+		t.Fatal(err)
+	}
+	if err := swap.Add(10, testPeer.Peer); err != nil {
+		t.Fatal(err)
+	}
+	if asset, ok := swap.PeerAsset(testPeer.ID()); !ok || asset != token {
+		t.Fatalf("expected asset %s, got %q", token, asset)
+	}
+	balances, err := swap.PeerAssetBalances(testPeer.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(balances) != 2 || balances[token] != 10 || balances[AssetETH] != 7 {
+		t.Fatalf("expected balances of 10 in %s and 7 in eth, got %v", token, balances)
+	}
+	swap.removePeer(swap.getPeer(testPeer.ID()))
+	if balances, _ := swap.PeerAssetBalances(testPeer.ID()); balances[token] != 10 {
+		t.Fatalf("expected the stored balance of 10 in %s, got %v", token, balances)
+	}
+
+	freePeer := newDummyPeer()
+	if _, err := swap.addAssetPeer(freePeer.Peer, "", common.Address{}, common.Address{}, DefaultRetrievePricing); err != nil {
+		t.Fatal(err)
+	}
+	// a debt over the disconnect threshold would disconnect
+	if err := swap.Add(2*int64(DefaultDisconnectThreshold), freePeer.Peer); err != nil {
+		t.Fatal(err)
+	}
+	if balance, _ := swap.PeerBalance(freePeer.ID()); balance != 0 {
+		t.Fatalf("expected no balance with a settlement-free peer, got %d", balance)
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// DefaultBalanceLogMaxSize is the size in bytes at which the balance audit log is rotated
+const DefaultBalanceLogMaxSize = 64 * 1024 * 1024
+
+// DefaultBalanceLogMaxFiles is the number of rotated balance audit log files which are kept
+const DefaultBalanceLogMaxFiles = 10
+
+// reasons of balance mutations which are not caused by accounted messages
+const (
+	auditChequeSent     = "cheque sent"
+	auditChequeReceived = "cheque received"
+	auditDecay          = "grace decay"
+)
+
+// AuditEntry is a balance mutation in the balance audit log
+type AuditEntry struct {
+	Time    time.Time `json:"time"`              // time of the mutation
+	Peer    string    `json:"peer"`              // node ID of the peer
+	Asset   Asset     `json:"asset"`             // settlement asset of the balance
+	Delta   int64     `json:"delta"`             // honey amount the balance changed by
+	Reason  string    `json:"reason"`            // type of the accounted message or other cause of the mutation
+	Service string    `json:"service,omitempty"` // protocol of the accounted message
+	Balance int64     `json:"balance"`           // balance after the mutation
+}
+
+// auditLog is an append-only file of JSON encoded balance mutations, one
+// per line. When the file grows over the maximal size, it is renamed with
+// the time of the rotation appended and a new file is started. Only the most
+// recent rotated files are kept, the older ones are removed on rotation.
+type auditLog struct {
+	mtx      sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+// newAuditLog opens the balance audit log at path for appending
+func newAuditLog(path string, maxSize int64, maxFiles int) (*auditLog, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultBalanceLogMaxSize
+	}
+	if maxFiles <= 0 {
+		maxFiles = DefaultBalanceLogMaxFiles
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	a := &auditLog{
+		path:     path,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+	}
+	if err := a.open(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// open opens or creates the current log file
+// the caller is expected to hold a.mtx unless the log is not shared yet
+func (a *auditLog) open() error {
+	f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening balance audit log: %v", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	a.file, a.size = f, fi.Size()
+	return nil
+}
+
+// rotate renames the current log file and starts a new one
+// the caller is expected to hold a.mtx
+func (a *auditLog) rotate(now time.Time) error {
+	err := a.file.Close()
+	a.file = nil
+	if err == nil {
+		rotated := fmt.Sprintf("%s.%s", a.path, now.UTC().Format("20060102T150405.000000000"))
+		err = os.Rename(a.path, rotated)
+	}
+	// keep appending to the current file if it could not be rotated
+	if openErr := a.open(); err == nil {
+		err = openErr
+	}
+	if err == nil {
+		err = a.prune()
+	}
+	return err
+}
+
+// prune removes the oldest rotated files so that at most maxFiles are kept
+// the caller is expected to hold a.mtx
+func (a *auditLog) prune() error {
+	infos, err := ioutil.ReadDir(filepath.Dir(a.path))
+	if err != nil {
+		return err
+	}
+	// the rotation times have a fixed width, so the names sort by age
+	// and ReadDir returns them sorted
+	var rotated []string
+	prefix := filepath.Base(a.path) + "."
+	for _, info := range infos {
+		if !info.IsDir() && strings.HasPrefix(info.Name(), prefix) {
+			rotated = append(rotated, filepath.Join(filepath.Dir(a.path), info.Name()))
+		}
+	}
+	for len(rotated) > a.maxFiles {
+		if err := os.Remove(rotated[0]); err != nil {
+			return err
+		}
+		rotated = rotated[1:]
+	}
+	return nil
+}
+
+// record appends the entry to the log. A nil log records nothing, so that
+// the audit can be disabled.
+func (a *auditLog) record(entry AuditEntry) {
+	if a == nil {
+		return
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		swapLog.Error("error encoding balance audit entry", "err", err)
+		return
+	}
+	line = append(line, '\n')
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	if a.file == nil {
+		return
+	}
+	if a.size > 0 && a.size+int64(len(line)) > a.maxSize {
+		if err := a.rotate(entry.Time); err != nil {
+			swapLog.Error("error rotating balance audit log", "err", err)
+			if a.file == nil {
+				return
+			}
+		}
+	}
+	n, err := a.file.Write(line)
+	a.size += int64(n)
+	if err != nil {
+		metrics.GetOrRegisterCounter("swap.audit.errors", nil).Inc(1)
+		swapLog.Error("error writing balance audit log", "err", err)
+	}
+}
+
+// close closes the log file
+func (a *auditLog) close() error {
+	if a == nil {
+		return nil
+	}
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
+
+// auditBalance records a mutation of the balance with the peer in the audit log
+// the caller is expected to hold p.lock
+func (p *Peer) auditBalance(delta int64, reason, service string) {
+	p.swap.audit.record(AuditEntry{
+		Time:    p.swap.clock.Time(),
+		Peer:    p.ID().String(),
+		Asset:   p.asset,
+		Delta:   delta,
+		Reason:  reason,
+		Service: service,
+		Balance: p.getBalance(),
+	})
+}
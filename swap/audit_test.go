@@ -0,0 +1,150 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// readAuditEntries reads the entries of all files of the audit log at path,
+// the rotated files in the order of their rotation before the current file
+func readAuditEntries(t *testing.T, path string) (entries []AuditEntry, files int) {
+	t.Helper()
+	rotated, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range append(rotated, path) {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var entry AuditEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				t.Fatal(err)
+			}
+			entries = append(entries, entry)
+		}
+		f.Close()
+		files++
+	}
+	return entries, files
+}
+
+// TestAuditLog tests that balance mutations are appended to the audit log
+// with the type of the accounted message and that the log is rotated
+func TestAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swap-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	testDeploy(context.Background(), swap, big.NewInt(0))
+	path := filepath.Join(dir, "balances.log")
+	if swap.audit, err = newAuditLog(path, 1024, 0); err != nil {
+		t.Fatal(err)
+	}
+	testPeer := newDummyPeer()
+	if _, err := swap.addPeer(testPeer.Peer, swap.owner.address, swap.GetParams().ContractAddress, DefaultRetrievePricing); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := swap.AddForMessage(100, testPeer.Peer, "bzz-retrieve", "retrieval.ChunkDelivery"); err != nil {
+		t.Fatal(err)
+	}
+	if err := swap.Add(-30, testPeer.Peer); err != nil {
+		t.Fatal(err)
+	}
+	entries, files := readAuditEntries(t, path)
+	if len(entries) != 2 || files != 1 {
+		t.Fatalf("expected 2 entries in 1 file, got %d in %d", len(entries), files)
+	}
+	first, second := entries[0], entries[1]
+	if first.Peer != testPeer.ID().String() || first.Asset != AssetETH || first.Delta != 100 || first.Balance != 100 || first.Reason != "retrieval.ChunkDelivery" || first.Service != "bzz-retrieve" {
+		t.Fatalf("unexpected entry %+v", first)
+	}
+	if second.Delta != -30 || second.Balance != 70 {
+		t.Fatalf("expected a delta of -30 to a balance of 70, got %+v", second)
+	}
+
+	for i := 0; i < 20; i++ {
+		if err := swap.Add(1, testPeer.Peer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := swap.audit.close(); err != nil {
+		t.Fatal(err)
+	}
+	entries, files = readAuditEntries(t, path)
+	if len(entries) != 22 || files < 2 {
+		t.Fatalf("expected 22 entries in rotated files, got %d in %d", len(entries), files)
+	}
+	// every mutation continues from the balance of the one before
+	balance := int64(0)
+	for _, entry := range entries {
+		if entry.Balance != balance+entry.Delta {
+			t.Fatalf("expected balance %d after a delta of %d, got %d", balance+entry.Delta, entry.Delta, entry.Balance)
+		}
+		balance = entry.Balance
+	}
+}
+
+// TestAuditLogRetention tests that only the most recent rotated files
+// of the audit log are kept
+func TestAuditLogRetention(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swap-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "balances.log")
+	// every entry exceeds the maximal size, so the log is rotated before each following entry
+	audit, err := newAuditLog(path, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		audit.record(AuditEntry{Time: start.Add(time.Duration(i) * time.Second), Balance: int64(i)})
+	}
+	if err := audit.close(); err != nil {
+		t.Fatal(err)
+	}
+	entries, files := readAuditEntries(t, path)
+	if files != 3 {
+		t.Fatalf("expected 2 rotated files and the current one, got %d files", files)
+	}
+	for i, entry := range entries {
+		if entry.Balance != int64(7+i) {
+			t.Fatalf("expected the last 3 entries to be kept, got balance %d at %d", entry.Balance, i)
+		}
+	}
+}
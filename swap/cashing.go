@@ -0,0 +1,266 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/metrics"
+	contract "github.com/ethersphere/swarm/contracts/swap"
+)
+
+const (
+	cashingPrefix = "cashing_"
+	// cashingRetryInterval is the interval at which cheques whose cashing the
+	// cashout strategy deferred are reconsidered, if no cashing interval is set
+	cashingRetryInterval = time.Minute
+)
+
+// CashoutStrategy decides whether a received cheque is cashed. Cheques are only
+// cashed if the amount they pay out is worth twice the transaction costs.
+type CashoutStrategy struct {
+	MinAmount   uint64        // amount a cheque must pay out to be cashed, no minimum if 0
+	MaxDelay    time.Duration // time after which a cheque is cashed regardless of MinAmount and MaxGasPrice, no limit if 0
+	MaxGasPrice uint64        // gas price in wei above which cheques are not cashed, no limit if 0
+}
+
+// enabled returns whether the strategy can defer cashing a cheque
+func (c CashoutStrategy) enabled() bool {
+	return c.MinAmount > 0 || c.MaxGasPrice > 0
+}
+
+// cash returns whether a cheque paying out amount which was received delay
+// ago is cashed at gasPrice
+func (c CashoutStrategy) cash(amount uint64, gasPrice uint64, delay time.Duration) bool {
+	if c.MaxDelay > 0 && delay >= c.MaxDelay {
+		return true
+	}
+	if c.MaxGasPrice > 0 && gasPrice > c.MaxGasPrice {
+		return false
+	}
+	return amount >= c.MinAmount
+}
+
+// cashingKey returns the store key of the queued cheque issued by the chequebook
+func cashingKey(chequebook common.Address) string {
+	return cashingPrefix + chequebook.Hex()
+}
+
+// queuedCheque is a cheque waiting to be cashed
+type queuedCheque struct {
+	Cheque *Cheque
+	Since  time.Time // time the chequebook was first queued since it was last cashed
+}
+
+// cashingQueue holds the latest received cheque of every chequebook which
+// is not cashed yet. Cheques are cumulative, so cashing the latest cheque of
+// a chequebook cashes all the cheques received from it before.
+type cashingQueue struct {
+	lock    sync.Mutex
+	cheques map[common.Address]*queuedCheque // latest uncashed cheque by issuing chequebook
+	cashing map[common.Address]bool          // chequebooks with a cashing transaction in progress
+}
+
+func newCashingQueue() *cashingQueue {
+	return &cashingQueue{
+		cheques: make(map[common.Address]*queuedCheque),
+		cashing: make(map[common.Address]bool),
+	}
+}
+
+// startCashing marks the chequebook as being cashed, it returns false if it
+// is already being cashed. Chequebooks are cashed one transaction at a time.
+func (c *cashingQueue) startCashing(chequebook common.Address) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.cashing[chequebook] {
+		return false
+	}
+	c.cashing[chequebook] = true
+	return true
+}
+
+// doneCashing marks the chequebook as no longer being cashed
+func (c *cashingQueue) doneCashing(chequebook common.Address) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.cashing, chequebook)
+}
+
+// loadCashingQueue loads the cheques queued for cashing before the node was stopped.
+// Cheques queued before the cashout strategy are stored without the time they
+// were queued at, they are considered queued since they are loaded.
+func (s *Swap) loadCashingQueue() error {
+	s.cashing.lock.Lock()
+	defer s.cashing.lock.Unlock()
+	return s.store.Iterate(cashingPrefix, func(key []byte, value []byte) (stop bool, err error) {
+		var queued queuedCheque
+		if err := json.Unmarshal(value, &queued); err != nil {
+			return true, err
+		}
+		if queued.Cheque == nil {
+			var cheque Cheque
+			if err := json.Unmarshal(value, &cheque); err != nil {
+				return true, err
+			}
+			queued = queuedCheque{Cheque: &cheque, Since: s.clock.Time()}
+		}
+		if (queued.Cheque.Contract == common.Address{}) {
+			swapLog.Warn("ignoring invalid queued cheque", "key", string(key))
+			return false, nil
+		}
+		s.cashing.cheques[queued.Cheque.Contract] = &queued
+		return false, nil
+	})
+}
+
+// queueCheque queues a received cheque for cashing in place of the cheque
+// queued for its chequebook. Without a cashing interval it is cashed right
+// away unless the cashout strategy defers it, otherwise it is cashed right
+// away only if the amount it pays out reaches the cashing threshold.
+func (s *Swap) queueCheque(ctx context.Context, cheque *Cheque) error {
+	s.cashing.lock.Lock()
+	queued := &queuedCheque{Cheque: cheque, Since: s.clock.Time()}
+	if previous, ok := s.cashing.cheques[cheque.Contract]; ok {
+		if previous.Cheque.CumulativePayout >= cheque.CumulativePayout {
+			s.cashing.lock.Unlock()
+			return nil
+		}
+		queued.Since = previous.Since
+	}
+	if err := s.store.Put(cashingKey(cheque.Contract), queued); err != nil {
+		s.cashing.lock.Unlock()
+		return err
+	}
+	s.cashing.cheques[cheque.Contract] = queued
+	s.cashing.lock.Unlock()
+	metrics.GetOrRegisterCounter("swap.cheques.queued.num", nil).Inc(1)
+
+	if s.params.CashingInterval > 0 {
+		if s.params.CashingThreshold == 0 {
+			return nil
+		}
+		otherSwap, err := contract.InstanceAt(cheque.Contract, s.backend)
+		if err != nil {
+			return err
+		}
+		paidOut, err := otherSwap.PaidOut(nil, cheque.Beneficiary)
+		if err != nil {
+			return err
+		}
+		if cheque.CumulativePayout-paidOut.Uint64() < s.params.CashingThreshold {
+			return nil
+		}
+	}
+	// a cheque of a chequebook being cashed stays queued
+	if !s.cashing.startCashing(cheque.Contract) {
+		return nil
+	}
+	// cash cheque in async, otherwise this blocks here until the TX is mined
+	go func() {
+		defer s.cashing.doneCashing(cheque.Contract)
+		if err := s.cashQueuedCheque(ctx, queued); err != nil {
+			swapLog.Error("error cashing queued cheque", "contract", cheque.Contract, "err", err)
+		}
+	}()
+	return nil
+}
+
+// cashQueuedCheque cashes a queued cheque and blocks until the transaction
+// is mined. The cheque is removed from the queue only once the transaction
+// succeeded, unless a later cheque replaced it meanwhile, so that a cheque
+// whose transaction fails or reverts is retried by the cashing loop.
+// The caller is expected to have started cashing the chequebook.
+func (s *Swap) cashQueuedCheque(ctx context.Context, queued *queuedCheque) error {
+	cheque := queued.Cheque
+	otherSwap, opts, err := s.cashingTransaction(ctx, cheque, s.clock.Since(queued.Since))
+	if err != nil || otherSwap == nil {
+		return err
+	}
+	if err := defaultCashCheque(s, otherSwap, opts, cheque); err != nil {
+		metrics.GetOrRegisterCounter("swap.cheques.cashing.failed", nil).Inc(1)
+		return err
+	}
+
+	s.cashing.lock.Lock()
+	defer s.cashing.lock.Unlock()
+	if s.cashing.cheques[cheque.Contract] != queued {
+		return nil
+	}
+	if err := s.store.Delete(cashingKey(cheque.Contract)); err != nil {
+		return err
+	}
+	delete(s.cashing.cheques, cheque.Contract)
+	return nil
+}
+
+// queuedCheques returns the cheques queued for cashing
+func (s *Swap) queuedCheques() []*queuedCheque {
+	s.cashing.lock.Lock()
+	defer s.cashing.lock.Unlock()
+	cheques := make([]*queuedCheque, 0, len(s.cashing.cheques))
+	for _, queued := range s.cashing.cheques {
+		cheques = append(cheques, queued)
+	}
+	return cheques
+}
+
+// cashQueue cashes the queued cheques which pay out enough to cover the
+// transaction costs and which the cashout strategy does not defer, the others
+// stay queued until later cheques add to them or the strategy allows cashing.
+// It blocks until the transactions of all chequebooks are mined.
+func (s *Swap) cashQueue(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, queued := range s.queuedCheques() {
+		// a cheque of a chequebook being cashed stays queued
+		if !s.cashing.startCashing(queued.Cheque.Contract) {
+			continue
+		}
+		wg.Add(1)
+		go func(queued *queuedCheque) {
+			defer wg.Done()
+			defer s.cashing.doneCashing(queued.Cheque.Contract)
+			if err := s.cashQueuedCheque(ctx, queued); err != nil {
+				swapLog.Error("error cashing queued cheque", "contract", queued.Cheque.Contract, "err", err)
+			}
+		}(queued)
+	}
+	wg.Wait()
+}
+
+// cashQueueLoop periodically cashes the queued cheques, at the cashing
+// interval or at cashingRetryInterval if only the cashout strategy defers cashing
+func (s *Swap) cashQueueLoop() {
+	interval := s.params.CashingInterval
+	if interval == 0 {
+		interval = cashingRetryInterval
+	}
+	ticker := s.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.cashQueue(context.Background())
+		case <-s.quit:
+			return
+		}
+	}
+}
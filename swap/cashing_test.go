@@ -0,0 +1,316 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common/mclock"
+	cswap "github.com/ethersphere/swarm/contracts/swap"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/state"
+)
+
+// TestCashingQueue tests that only the latest cheque of a chequebook is
+// queued and that the queue is restored from the store
+func TestCashingQueue(t *testing.T) {
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	swap.params.CashingInterval = time.Minute
+	ctx := context.Background()
+
+	earlier := newTestCheque()
+	later := newTestCheque()
+	later.CumulativePayout = 2 * earlier.CumulativePayout
+
+	if err := swap.queueCheque(ctx, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := swap.queueCheque(ctx, earlier); err != nil {
+		t.Fatal(err)
+	}
+	queued := swap.queuedCheques()
+	if len(queued) != 1 || !queued[0].Cheque.Equal(later) {
+		t.Fatalf("expected only the later cheque to be queued, got %v", queued)
+	}
+
+	restored := newSwapInstance(swap.store, swap.owner, swap.backend, swap.chainID, swap.params, swap.chequebookFactory)
+	if err := restored.loadCashingQueue(); err != nil {
+		t.Fatal(err)
+	}
+	queued = restored.queuedCheques()
+	if len(queued) != 1 || !queued[0].Cheque.Equal(later) {
+		t.Fatalf("expected the later cheque to be restored, got %v", queued)
+	}
+}
+
+// TestCashingQueueLegacy tests that cheques queued without the time they were
+// queued at are restored and that invalid entries are ignored
+func TestCashingQueueLegacy(t *testing.T) {
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+
+	cheque := newTestCheque()
+	if err := swap.store.Put(cashingKey(cheque.Contract), cheque); err != nil {
+		t.Fatal(err)
+	}
+	if err := swap.store.Put(cashingPrefix+"invalid", &queuedCheque{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := swap.loadCashingQueue(); err != nil {
+		t.Fatal(err)
+	}
+	queued := swap.queuedCheques()
+	if len(queued) != 1 || !queued[0].Cheque.Equal(cheque) {
+		t.Fatalf("expected the legacy cheque to be restored, got %v", queued)
+	}
+	if queued[0].Since.IsZero() {
+		t.Fatal("expected the legacy cheque to be queued since it was loaded")
+	}
+}
+
+// TestCashQueue tests that received cheques are queued instead of cashed on
+// receipt, and cashed when the queue is cashed or the cashing threshold is reached
+func TestCashQueue(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		threshold uint64
+		onReceipt bool
+	}{
+		{name: "interval"},
+		{name: "threshold reached", threshold: DefaultPaymentThreshold, onReceipt: true},
+		{name: "threshold not reached", threshold: 2 * DefaultPaymentThreshold},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			creditorSwap, cheque, cashDone, clean := newCashingTest(t, func(s *Swap) {
+				s.params.CashingInterval = time.Minute
+				s.params.CashingThreshold = tc.threshold
+			})
+			defer clean()
+			if !tc.onReceipt {
+				expectQueued(t, creditorSwap, cheque)
+				creditorSwap.cashQueue(context.Background())
+			}
+			expectCashed(t, creditorSwap, cheque, cashDone)
+		})
+	}
+}
+
+// TestCashoutStrategy tests the conditions under which the strategy cashes cheques
+func TestCashoutStrategy(t *testing.T) {
+	strategy := CashoutStrategy{MinAmount: 100, MaxDelay: time.Hour, MaxGasPrice: 10}
+	for _, tc := range []struct {
+		name     string
+		amount   uint64
+		gasPrice uint64
+		delay    time.Duration
+		cash     bool
+	}{
+		{name: "within limits", amount: 100, gasPrice: 10, cash: true},
+		{name: "below min amount", amount: 99, gasPrice: 10},
+		{name: "above max gas price", amount: 100, gasPrice: 11},
+		{name: "max delay reached", amount: 1, gasPrice: 11, delay: time.Hour, cash: true},
+	} {
+		if cash := strategy.cash(tc.amount, tc.gasPrice, tc.delay); cash != tc.cash {
+			t.Errorf("%s: got cash %v, want %v", tc.name, cash, tc.cash)
+		}
+	}
+	if (CashoutStrategy{MaxDelay: time.Hour}).enabled() {
+		t.Error("expected a strategy with only a max delay to never defer cashing")
+	}
+}
+
+// TestCashoutStrategyDefers tests that a cheque whose cashing the strategy
+// defers stays queued until the max delay is reached
+func TestCashoutStrategyDefers(t *testing.T) {
+	sim := new(mclock.Simulated)
+	creditorSwap, cheque, cashDone, clean := newCashingTest(t, func(s *Swap) {
+		s.clock = network.NewClock(sim, time.Now())
+		s.params.CashoutStrategy = CashoutStrategy{MinAmount: 2 * DefaultPaymentThreshold, MaxDelay: time.Hour}
+	})
+	defer clean()
+	ctx := context.Background()
+
+	expectQueued(t, creditorSwap, cheque)
+	sim.Run(time.Hour - time.Second)
+	creditorSwap.cashQueue(ctx)
+	expectQueued(t, creditorSwap, cheque)
+
+	sim.Run(time.Second)
+	creditorSwap.cashQueue(ctx)
+	expectCashed(t, creditorSwap, cheque, cashDone)
+}
+
+// TestCashQueueRetry tests that a cheque whose cashing transaction fails
+// stays queued and is cashed by a later run of the queue
+func TestCashQueueRetry(t *testing.T) {
+	creditorSwap, cheque, cashDone, clean := newCashingTest(t, func(s *Swap) {
+		s.params.CashingInterval = time.Minute
+	})
+	defer clean()
+	ctx := context.Background()
+
+	defaultCashCheque = func(s *Swap, otherSwap cswap.Contract, opts *bind.TransactOpts, cheque *Cheque) error {
+		return cswap.ErrTransactionReverted
+	}
+	creditorSwap.cashQueue(ctx)
+	expectQueued(t, creditorSwap, cheque)
+
+	defaultCashCheque = testCashCheque
+	creditorSwap.cashQueue(ctx)
+	expectCashed(t, creditorSwap, cheque, cashDone)
+}
+
+// TestCashQueueSerialised tests that a chequebook is not cashed again while
+// its cashing transaction is in progress
+func TestCashQueueSerialised(t *testing.T) {
+	creditorSwap, cheque, cashDone, clean := newCashingTest(t, func(s *Swap) {
+		s.params.CashingInterval = time.Minute
+	})
+	defer clean()
+	ctx := context.Background()
+
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	defaultCashCheque = func(s *Swap, otherSwap cswap.Contract, opts *bind.TransactOpts, cheque *Cheque) error {
+		started <- struct{}{}
+		<-release
+		return testCashCheque(s, otherSwap, opts, cheque)
+	}
+	done := make(chan struct{})
+	go func() {
+		creditorSwap.cashQueue(ctx)
+		close(done)
+	}()
+	<-started
+
+	creditorSwap.cashQueue(ctx)
+	select {
+	case <-started:
+		t.Fatal("expected the chequebook not to be cashed while its cashing is in progress")
+	default:
+	}
+	expectQueued(t, creditorSwap, cheque)
+
+	close(release)
+	<-done
+	expectCashed(t, creditorSwap, cheque, cashDone)
+}
+
+// newCashingTest deploys the chequebooks of a creditor set up by setup and a
+// debitor, and lets the creditor receive a cheque from the debitor
+func newCashingTest(t *testing.T, setup func(*Swap)) (*Swap, *Cheque, chan struct{}, func()) {
+	t.Helper()
+	testBackend := newTestBackend(t)
+	creditorSwap, clean1 := newTestSwap(t, beneficiaryKey, testBackend)
+	debitorSwap, clean2 := newTestSwap(t, ownerKey, testBackend)
+	cleanup := setupContractTest()
+	clean := func() {
+		cleanup()
+		clean2()
+		clean1()
+		testBackend.Close()
+	}
+	setup(creditorSwap)
+
+	testAmount := int64(DefaultPaymentThreshold + 42)
+	ctx := context.Background()
+	if err := testDeploy(ctx, creditorSwap, big.NewInt(0)); err != nil {
+		clean()
+		t.Fatal(err)
+	}
+	if err := testDeploy(ctx, debitorSwap, big.NewInt(testAmount)); err != nil {
+		clean()
+		t.Fatal(err)
+	}
+
+	creditor, err := debitorSwap.addPeer(newDummyPeerWithSpec(Spec).Peer, creditorSwap.owner.address, debitorSwap.GetParams().ContractAddress, DefaultRetrievePricing)
+	if err != nil {
+		clean()
+		t.Fatal(err)
+	}
+	debitor, err := creditorSwap.addPeer(newDummyPeerWithSpec(Spec).Peer, debitorSwap.owner.address, debitorSwap.GetParams().ContractAddress, DefaultRetrievePricing)
+	if err != nil {
+		clean()
+		t.Fatal(err)
+	}
+	debitor.setBalance(testAmount)
+	creditor.setBalance(-testAmount)
+	if err := creditor.sendCheque(); err != nil {
+		clean()
+		t.Fatal(err)
+	}
+	cheque := creditor.getPendingCheque()
+
+	testBackend.cashDone = make(chan struct{}, 1)
+	if err := creditorSwap.handleEmitChequeMsg(ctx, debitor, &EmitChequeMsg{Cheque: cheque}); err != nil {
+		clean()
+		t.Fatal(err)
+	}
+	waitCashing(t, creditorSwap)
+	return creditorSwap, cheque, testBackend.cashDone, clean
+}
+
+// waitCashing waits until no chequebook is being cashed
+func waitCashing(t *testing.T, s *Swap) {
+	t.Helper()
+	deadline := time.Now().Add(4 * time.Second)
+	for {
+		s.cashing.lock.Lock()
+		cashing := len(s.cashing.cashing)
+		s.cashing.lock.Unlock()
+		if cashing == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for cashing to complete")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// expectQueued fails the test if the cheque is not the only queued cheque
+func expectQueued(t *testing.T, s *Swap, cheque *Cheque) {
+	t.Helper()
+	queued := s.queuedCheques()
+	if len(queued) != 1 || !queued[0].Cheque.Equal(cheque) {
+		t.Fatalf("expected the cheque to be queued, got %v", queued)
+	}
+}
+
+// expectCashed waits for the cheque to be cashed and fails the test if it
+// is not or if it stays queued
+func expectCashed(t *testing.T, s *Swap, cheque *Cheque, cashDone chan struct{}) {
+	t.Helper()
+	select {
+	case <-cashDone:
+	case <-time.After(4 * time.Second):
+		t.Fatal("timeout waiting for the cash transaction to complete")
+	}
+	waitCashing(t, s)
+	if queued := s.queuedCheques(); len(queued) != 0 {
+		t.Fatalf("expected no queued cheques, got %v", queued)
+	}
+	var stored queuedCheque
+	if err := s.store.Get(cashingKey(cheque.Contract), &stored); err != state.ErrNotFound {
+		t.Fatalf("expected the cashed cheque to be removed from the store, got %v", err)
+	}
+}
@@ -78,7 +78,16 @@ func (cheque *Cheque) VerifySig(expectedSigner common.Address) error {
 
 // Sign returns the cheque's signature with supplied private key
 func (cheque *ChequeParams) Sign(prv *ecdsa.PrivateKey) ([]byte, error) {
-	sig, err := crypto.Sign(cheque.sigHash(), prv)
+	return contractSignature(crypto.Sign(cheque.sigHash(), prv))
+}
+
+// signBy returns the cheque's signature by the chequebook owner
+func (cheque *ChequeParams) signBy(owner *Owner) ([]byte, error) {
+	return contractSignature(owner.sign(cheque.sigHash()))
+}
+
+// contractSignature returns the signature in the form accepted by the contract
+func contractSignature(sig []byte, err error) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
@@ -129,8 +138,9 @@ func (cheque *Cheque) verifyChequeProperties(p *Peer, expectedBeneficiary common
 }
 
 // verifyChequeAgainstLast verifies that the amount is higher than in the previous cheque and the increase is as expected
+// within the tolerance percentage, as the issuer may have priced the honey with a slightly different oracle price
 // returns the actual amount received in this cheque
-func (cheque *Cheque) verifyChequeAgainstLast(lastCheque *Cheque, expectedAmount uint64) (uint64, error) {
+func (cheque *Cheque) verifyChequeAgainstLast(lastCheque *Cheque, expectedAmount, tolerance uint64) (uint64, error) {
 	actualAmount := cheque.CumulativePayout
 
 	if lastCheque != nil {
@@ -141,8 +151,8 @@ func (cheque *Cheque) verifyChequeAgainstLast(lastCheque *Cheque, expectedAmount
 		actualAmount -= lastCheque.CumulativePayout
 	}
 
-	if expectedAmount != actualAmount {
-		return 0, fmt.Errorf("unexpected amount for honey, expected %d was %d", expectedAmount, actualAmount)
+	if !withinTolerance(actualAmount, expectedAmount, tolerance) {
+		return 0, fmt.Errorf("unexpected amount for honey, expected %d (tolerance %d%%) was %d", expectedAmount, tolerance, actualAmount)
 	}
 
 	return actualAmount, nil
@@ -0,0 +1,139 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/state"
+)
+
+const (
+	// ChequeStatsWindow is the length of time windows by which debt is
+	// attributed to the cheques paying it
+	ChequeStatsWindow = time.Hour
+	// maxChequeStats is the number of latest emitted cheques per peer
+	// for which debt statistics are kept
+	maxChequeStats = 100
+)
+
+// DebtStats is the debt generated by a service in a time window
+type DebtStats struct {
+	Service string    // name of the protocol which generated the debt
+	Window  time.Time // start of the time window of length ChequeStatsWindow
+	Honey   uint64    // debited honey amount
+}
+
+// ChequeStats is the breakdown of the debt paid by an emitted cheque.
+// Debts are the amounts debited since the previous cheque to the same peer.
+// Credits from the peer reduce the cheque amount, so the sum of debts can be
+// higher than the cheque honey. Debts accrued before a node restart are not
+// known and are missing from the breakdown.
+type ChequeStats struct {
+	Time             time.Time // time of cheque emission
+	Honey            uint64    // honey amount of the cheque
+	CumulativePayout uint64    // cumulative payout of the cheque
+	Debts            []DebtStats
+}
+
+// debtKey identifies accrued debt by service and time window
+type debtKey struct {
+	service string
+	window  int64
+}
+
+// recordDebt attributes the debited honey amount to the service
+// in the current time window
+// the caller is expected to hold p.lock
+func (p *Peer) recordDebt(service string, honey uint64) {
+	if p.debts == nil {
+		p.debts = make(map[debtKey]uint64)
+	}
+	window := p.swap.clock.Time().Truncate(ChequeStatsWindow).Unix()
+	p.debts[debtKey{service: service, window: window}] += honey
+}
+
+// takeDebts returns the debts accrued since the last call,
+// sorted by time window and service
+// the caller is expected to hold p.lock
+func (p *Peer) takeDebts() (debts []DebtStats) {
+	for k, honey := range p.debts {
+		debts = append(debts, DebtStats{
+			Service: k.service,
+			Window:  time.Unix(k.window, 0),
+			Honey:   honey,
+		})
+	}
+	sort.Slice(debts, func(i, j int) bool {
+		if !debts[i].Window.Equal(debts[j].Window) {
+			return debts[i].Window.Before(debts[j].Window)
+		}
+		return debts[i].Service < debts[j].Service
+	})
+	p.debts = nil
+	return debts
+}
+
+// addChequeStats stores the debt breakdown of a newly emitted cheque
+// the caller is expected to hold p.lock
+func (p *Peer) addChequeStats(cheque *Cheque) error {
+	stats, err := p.swap.loadChequeStats(p.ID())
+	if err != nil {
+		return err
+	}
+	stats = append(stats, ChequeStats{
+		Time:             p.swap.clock.Time(),
+		Honey:            cheque.Honey,
+		CumulativePayout: cheque.CumulativePayout,
+		Debts:            p.takeDebts(),
+	})
+	if len(stats) > maxChequeStats {
+		stats = stats[len(stats)-maxChequeStats:]
+	}
+	return p.swap.store.Put(chequeStatsKey(p.ID()), stats)
+}
+
+// loadChequeStats loads the debt breakdowns of cheques emitted to the peer
+func (s *Swap) loadChequeStats(peer enode.ID) (stats []ChequeStats, err error) {
+	err = s.store.Get(chequeStatsKey(peer), &stats)
+	if err == state.ErrNotFound {
+		return nil, nil
+	}
+	return stats, err
+}
+
+// PeerChequeStats returns the debt breakdowns of the latest cheques emitted to the peer
+func (s *Swap) PeerChequeStats(peer enode.ID) ([]ChequeStats, error) {
+	return s.loadChequeStats(peer)
+}
+
+// ChequeStats returns the debt breakdowns of the latest emitted cheques, grouped by peer
+func (s *Swap) ChequeStats() (map[enode.ID][]ChequeStats, error) {
+	stats := make(map[enode.ID][]ChequeStats)
+	err := s.store.Iterate(chequeStatsPrefix, func(key []byte, value []byte) (stop bool, err error) {
+		var peerStats []ChequeStats
+		if err := json.Unmarshal(value, &peerStats); err != nil {
+			return true, err
+		}
+		stats[keyToID(string(key), chequeStatsPrefix)] = peerStats
+		return false, nil
+	})
+	return stats, err
+}
@@ -0,0 +1,159 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// ExemptPeers is the set of peers Swap does not account with, like the
+// worker nodes of the same operator. A peer is exempt if its node ID or
+// its bzz overlay address is in the set.
+type ExemptPeers struct {
+	mu      sync.RWMutex
+	peers   map[common.Hash]struct{} // node IDs and overlay addresses
+	overlay func(enode.ID) []byte    // returns the overlay address of a connected peer, nil if unknown
+}
+
+// NewExemptPeers creates an ExemptPeers set. A peer is given as an enode URL
+// or as its node ID or overlay address in hex.
+func NewExemptPeers(peers []string) (*ExemptPeers, error) {
+	e := &ExemptPeers{
+		peers: make(map[common.Hash]struct{}),
+	}
+	for _, p := range peers {
+		if err := e.Add(p); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// parseExemptPeer returns the node ID of an enode URL or the node ID or
+// overlay address in hex, which are both 32 bytes and are not told apart
+func parseExemptPeer(peer string) (common.Hash, error) {
+	peer = strings.TrimSpace(peer)
+	if strings.HasPrefix(peer, "enode://") {
+		n, err := enode.ParseV4(peer)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		return common.Hash(n.ID()), nil
+	}
+	b, err := hex.DecodeString(strings.TrimPrefix(peer, "0x"))
+	if err != nil || len(b) != common.HashLength {
+		return common.Hash{}, fmt.Errorf("invalid exempt peer %q: must be an enode URL, a node ID or an overlay address", peer)
+	}
+	return common.BytesToHash(b), nil
+}
+
+// Add exempts a peer from accounting
+func (e *ExemptPeers) Add(peer string) error {
+	h, err := parseExemptPeer(peer)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.peers[h] = struct{}{}
+	return nil
+}
+
+// Remove ends the exemption of a peer and returns false if it was not exempt
+func (e *ExemptPeers) Remove(peer string) (bool, error) {
+	h, err := parseExemptPeer(peer)
+	if err != nil {
+		return false, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, ok := e.peers[h]
+	delete(e.peers, h)
+	return ok, nil
+}
+
+// List returns the exempt node IDs and overlay addresses in hex
+func (e *ExemptPeers) List() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	list := make([]string, 0, len(e.peers))
+	for h := range e.peers {
+		list = append(list, hex.EncodeToString(h[:]))
+	}
+	return list
+}
+
+// SetOverlayLookup sets the function returning the overlay addresses of
+// connected peers, so that peers can be exempt by their overlay address
+func (e *ExemptPeers) SetOverlayLookup(overlay func(enode.ID) []byte) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.overlay = overlay
+}
+
+// Exempt returns true if the peer is not accounted with
+func (e *ExemptPeers) Exempt(id enode.ID) bool {
+	if e == nil {
+		return false
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if len(e.peers) == 0 {
+		return false
+	}
+	if _, ok := e.peers[common.Hash(id)]; ok {
+		return true
+	}
+	if e.overlay == nil {
+		return false
+	}
+	over := e.overlay(id)
+	if len(over) != common.HashLength {
+		return false
+	}
+	_, ok := e.peers[common.BytesToHash(over)]
+	return ok
+}
+
+// ExemptPeers returns the node IDs and overlay addresses of the peers
+// which are not accounted with
+func (s *Swap) ExemptPeers() []string {
+	return s.params.ExemptPeers.List()
+}
+
+// AddExemptPeer stops accounting with a peer given by its enode URL,
+// node ID or overlay address. The balance with the peer is kept as it is.
+func (s *Swap) AddExemptPeer(peer string) error {
+	return s.params.ExemptPeers.Add(peer)
+}
+
+// RemoveExemptPeer resumes accounting with a peer
+func (s *Swap) RemoveExemptPeer(peer string) (bool, error) {
+	return s.params.ExemptPeers.Remove(peer)
+}
+
+// SetOverlayLookup sets the function returning the overlay addresses
+// of connected peers, for peers exempt by their overlay address
+func (s *Swap) SetOverlayLookup(overlay func(enode.ID) []byte) {
+	s.params.ExemptPeers.SetOverlayLookup(overlay)
+}
@@ -0,0 +1,115 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// GraceTerms are the grace allowance and decay rate a node applies to small
+// balances with its peers. They are announced in the handshake, and both
+// nodes of a balance apply the lower allowance and rate of the two, so that
+// both forgive the same amount and keep agreeing on the balance.
+type GraceTerms struct {
+	Allowance uint64 // honey amount within which balances decay towards zero, no decay if 0
+	DecayRate uint64 // honey per second by which balances within the allowance decay, no decay if 0
+}
+
+// graceTerms returns the grace terms of the node
+func (s *Swap) graceTerms() GraceTerms {
+	return GraceTerms{
+		Allowance: uint64(s.params.GraceAllowance),
+		DecayRate: uint64(s.params.GraceDecayRate),
+	}
+}
+
+// agreeGrace returns the grace terms both nodes apply to their balance,
+// the lower allowance and the lower decay rate of the two
+func agreeGrace(ours, theirs GraceTerms) GraceTerms {
+	agreed := ours
+	if theirs.Allowance < agreed.Allowance {
+		agreed.Allowance = theirs.Allowance
+	}
+	if theirs.DecayRate < agreed.DecayRate {
+		agreed.DecayRate = theirs.DecayRate
+	}
+	return agreed
+}
+
+// validateGrace checks that balances within the grace allowance
+// can not trigger a cheque
+func validateGrace(allowance, decayRate, paymentThreshold int64) error {
+	if allowance < 0 || decayRate < 0 {
+		return fmt.Errorf("grace allowance %d and decay rate %d must not be negative", allowance, decayRate)
+	}
+	if allowance >= paymentThreshold {
+		return fmt.Errorf("grace allowance %d must be lower than the payment threshold %d", allowance, paymentThreshold)
+	}
+	return nil
+}
+
+// decayedBalance returns the balance with the peer after the decay since the
+// last balance change. Balances within the grace allowance agreed with the
+// peer move towards zero by the agreed decay rate per second, so that peers
+// exchanging roughly symmetric traffic do not send each other micro-cheques.
+// Debts in both directions decay, as the peer applies the same decay to its
+// side of the balance. Larger balances do not decay.
+// the caller is expected to hold p.lock
+func (p *Peer) decayedBalance(now time.Time) int64 {
+	balance := p.getBalance()
+	magnitude := balance
+	if magnitude < 0 {
+		magnitude = -magnitude
+	}
+	allowance, rate := p.grace.Allowance, p.grace.DecayRate
+	if allowance == 0 || rate == 0 || balance == 0 || balance == math.MinInt64 || uint64(magnitude) > allowance {
+		return balance
+	}
+	elapsed := now.Sub(p.decayedAt).Seconds()
+	if elapsed <= 0 {
+		return balance
+	}
+	decay := float64(rate) * elapsed
+	if decay >= float64(magnitude) {
+		return 0
+	}
+	if balance < 0 {
+		return balance + int64(decay)
+	}
+	return balance - int64(decay)
+}
+
+// decayBalance persists the decay of the balance since the last balance change
+// the caller is expected to hold p.lock
+func (p *Peer) decayBalance() error {
+	now := p.swap.clock.Time()
+	balance := p.decayedBalance(now)
+	delta := balance - p.getBalance()
+	if delta == 0 {
+		p.decayedAt = now
+		return nil
+	}
+	if err := p.setBalance(balance); err != nil {
+		return err
+	}
+	p.logger.Debug("decayed balance within grace allowance", "balance", strconv.FormatInt(balance, 10))
+	p.auditBalance(delta, auditDecay, "")
+	return nil
+}
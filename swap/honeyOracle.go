@@ -16,17 +16,48 @@
 
 package swap
 
-// HoneyOracle is the interface through which Oracles will deliver prices
-type HoneyOracle interface {
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// priceRefreshInterval is how long a price fetched from an oracle is used
+	priceRefreshInterval = 10 * time.Minute
+	// priceMaxAge is how long the last fetched price is used while the oracle can not be reached
+	priceMaxAge = time.Hour
+	// priceFetchTimeout is the maximal duration of fetching a price from an oracle
+	priceFetchTimeout = 10 * time.Second
+	// priceOracleABI is the ABI of oracle contracts, returning the price of honey in wei
+	priceOracleABI = `[{"constant":true,"inputs":[],"name":"getPrice","outputs":[{"name":"","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"}]`
+)
+
+// PriceOracle is the interface through which oracles deliver the price of honey
+type PriceOracle interface {
+	// GetPrice returns the wei amount of the honey
 	GetPrice(honey uint64) (uint64, error)
 }
 
-// NewHoneyPriceOracle returns the actual oracle to be used for discovering the price
-// It will return a default one
-func NewHoneyPriceOracle() HoneyOracle {
-
+// NewFixedPriceOracle returns an oracle with a fixed price of honey in wei,
+// the default price if it is 0
+func NewFixedPriceOracle(honeyPrice uint64) PriceOracle {
+	if honeyPrice == 0 {
+		honeyPrice = defaultHoneyPrice
+	}
 	return &fixedPriceOracle{
-		honeyPrice: defaultHoneyPrice,
+		honeyPrice: honeyPrice,
 	}
 }
 
@@ -37,5 +68,177 @@ type fixedPriceOracle struct {
 
 // GetPrice returns the actual price for honey
 func (cpo *fixedPriceOracle) GetPrice(honey uint64) (uint64, error) {
-	return honey * cpo.honeyPrice, nil
+	return honeyAmount(honey, cpo.honeyPrice)
+}
+
+// honeyAmount returns the wei amount of honey at the price
+func honeyAmount(honey, honeyPrice uint64) (uint64, error) {
+	if honeyPrice != 0 && honey > math.MaxUint64/honeyPrice {
+		return 0, fmt.Errorf("wei amount of %d honey at %d wei overflows", honey, honeyPrice)
+	}
+	return honey * honeyPrice, nil
+}
+
+// cachingPriceOracle fetches the price of honey from a remote oracle and uses
+// it for the refresh interval. If the oracle can not be reached, the last
+// price is used until it is older than the maximal age.
+type cachingPriceOracle struct {
+	mu      sync.Mutex
+	fetch   func(ctx context.Context) (uint64, error) // fetches the price of honey in wei
+	price   uint64                                    // last fetched price
+	fetched time.Time                                 // time of the last fetched price
+	now     func() time.Time
+}
+
+func newCachingPriceOracle(fetch func(ctx context.Context) (uint64, error)) *cachingPriceOracle {
+	return &cachingPriceOracle{
+		fetch: fetch,
+		now:   time.Now,
+	}
+}
+
+// GetPrice returns the wei amount of the honey at the current price
+func (o *cachingPriceOracle) GetPrice(honey uint64) (uint64, error) {
+	price, err := o.honeyPrice()
+	if err != nil {
+		return 0, err
+	}
+	return honeyAmount(honey, price)
+}
+
+// honeyPrice returns the cached price or fetches a new one once it is due
+func (o *cachingPriceOracle) honeyPrice() (uint64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := o.now()
+	if !o.fetched.IsZero() && now.Sub(o.fetched) < priceRefreshInterval {
+		return o.price, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), priceFetchTimeout)
+	defer cancel()
+	price, err := o.fetch(ctx)
+	if err == nil && price == 0 {
+		err = errors.New("oracle returned a zero price")
+	}
+	if err != nil {
+		if !o.fetched.IsZero() && now.Sub(o.fetched) < priceMaxAge {
+			swapLog.Warn("price oracle unavailable, using the last price", "price", o.price, "err", err)
+			return o.price, nil
+		}
+		return 0, fmt.Errorf("error fetching honey price: %v", err)
+	}
+	if price != o.price {
+		swapLog.Info("honey price changed", "price", price)
+	}
+	o.price, o.fetched = price, now
+	return price, nil
+}
+
+// NewHTTPPriceOracle returns an oracle fetching the price of honey in wei from
+// the URL, which responds with a JSON object like {"price": 1000}
+func NewHTTPPriceOracle(url string) PriceOracle {
+	client := &http.Client{Timeout: priceFetchTimeout}
+	return newCachingPriceOracle(func(ctx context.Context) (uint64, error) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return 0, err
+		}
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return 0, fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		var body struct {
+			Price json.Number `json:"price"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return 0, err
+		}
+		price, err := body.Price.Int64()
+		if err != nil || price < 0 {
+			return 0, fmt.Errorf("invalid price %q", body.Price)
+		}
+		return uint64(price), nil
+	})
+}
+
+// NewContractPriceOracle returns an oracle calling getPrice on the contract
+// at the address, which returns the price of honey in wei
+func NewContractPriceOracle(caller bind.ContractCaller, address common.Address) (PriceOracle, error) {
+	parsed, err := abi.JSON(strings.NewReader(priceOracleABI))
+	if err != nil {
+		return nil, err
+	}
+	contract := bind.NewBoundContract(address, parsed, caller, nil, nil)
+	return newCachingPriceOracle(func(ctx context.Context) (uint64, error) {
+		var price *big.Int
+		if err := contract.Call(&bind.CallOpts{Context: ctx}, &price, "getPrice"); err != nil {
+			return 0, err
+		}
+		if !price.IsUint64() {
+			return 0, fmt.Errorf("price %v exceeds 64 bits", price)
+		}
+		return price.Uint64(), nil
+	}), nil
+}
+
+// ValidatePriceOracleSource checks that the source of a price oracle is an
+// HTTP URL or a contract address
+func ValidatePriceOracleSource(source string) error {
+	if source == "" || strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") || common.IsHexAddress(source) {
+		return nil
+	}
+	return fmt.Errorf("invalid price oracle %q: must be an HTTP URL or a contract address", source)
+}
+
+// newPriceOracle returns the oracle of the source, which is an HTTP URL or
+// the address of an oracle contract on the backend
+func newPriceOracle(source string, backend bind.ContractCaller) (PriceOracle, error) {
+	if err := ValidatePriceOracleSource(source); err != nil {
+		return nil, err
+	}
+	if common.IsHexAddress(source) {
+		return NewContractPriceOracle(backend, common.HexToAddress(source))
+	}
+	return NewHTTPPriceOracle(source), nil
+}
+
+// validatePriceTolerance checks that cheques are accepted with a tolerance if
+// the price of honey comes from an oracle, as the issuer fetches the price at
+// another time and may see another price
+func validatePriceTolerance(oracleSource string, tolerance uint64) error {
+	if tolerance > 100 {
+		return fmt.Errorf("price tolerance %d must not be higher than 100 percent", tolerance)
+	}
+	if oracleSource != "" && tolerance == 0 {
+		return fmt.Errorf("price oracle %s requires a price tolerance", oracleSource)
+	}
+	return nil
+}
+
+// receivedHoney returns the honey a cheque paying amount for honey is credited
+// with, in proportion to the expected amount at the local price. Amounts above
+// the expected one do not credit more honey than the cheque pays for.
+func receivedHoney(honey, amount, expected uint64) uint64 {
+	if amount >= expected || expected == 0 {
+		return honey
+	}
+	received := new(big.Int).Mul(new(big.Int).SetUint64(honey), new(big.Int).SetUint64(amount))
+	return received.Div(received, new(big.Int).SetUint64(expected)).Uint64()
+}
+
+// withinTolerance reports whether the amount differs from the expected amount
+// by at most the tolerance percentage of the expected amount
+func withinTolerance(amount, expected, tolerance uint64) bool {
+	if amount == expected {
+		return true
+	}
+	diff := amount - expected
+	if amount < expected {
+		diff = expected - amount
+	}
+	return new(big.Int).Mul(new(big.Int).SetUint64(diff), big.NewInt(100)).Cmp(new(big.Int).Mul(new(big.Int).SetUint64(expected), new(big.Int).SetUint64(tolerance))) <= 0
 }
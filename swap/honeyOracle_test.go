@@ -0,0 +1,152 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestFixedPriceOracle tests the default price and overflowing amounts
+func TestFixedPriceOracle(t *testing.T) {
+	amount, err := NewFixedPriceOracle(0).GetPrice(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amount != 42*defaultHoneyPrice {
+		t.Fatalf("expected amount %d at the default price, got %d", 42*defaultHoneyPrice, amount)
+	}
+	amount, err = NewFixedPriceOracle(3).GetPrice(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amount != 126 {
+		t.Fatalf("expected amount 126, got %d", amount)
+	}
+	if _, err := NewFixedPriceOracle(2).GetPrice(math.MaxUint64); err == nil {
+		t.Fatal("expected an error for an overflowing amount")
+	}
+}
+
+// TestHTTPPriceOracle tests that the price fetched from an HTTP oracle is
+// cached for the refresh interval and used while the oracle is unavailable
+func TestHTTPPriceOracle(t *testing.T) {
+	var price, failing int64 = 5, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt64(&failing) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, `{"price": %d}`, atomic.LoadInt64(&price))
+	}))
+	defer srv.Close()
+
+	oracle := NewHTTPPriceOracle(srv.URL).(*cachingPriceOracle)
+	now := time.Now()
+	oracle.now = func() time.Time { return now }
+
+	expectAmount := func(expected uint64) {
+		t.Helper()
+		amount, err := oracle.GetPrice(10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if amount != expected {
+			t.Fatalf("expected amount %d, got %d", expected, amount)
+		}
+	}
+
+	expectAmount(50)
+	// the price is cached within the refresh interval
+	atomic.StoreInt64(&price, 7)
+	now = now.Add(priceRefreshInterval / 2)
+	expectAmount(50)
+	// and fetched again after it
+	now = now.Add(priceRefreshInterval)
+	expectAmount(70)
+	// the last price is used while the oracle is unavailable
+	atomic.StoreInt64(&failing, 1)
+	now = now.Add(priceRefreshInterval)
+	expectAmount(70)
+	// until it is older than the maximal age
+	now = now.Add(priceMaxAge)
+	if _, err := oracle.GetPrice(10); err == nil {
+		t.Fatal("expected an error for an unavailable oracle after the maximal age")
+	}
+}
+
+// TestPriceTolerance tests that cheques priced within the tolerance are accepted
+func TestPriceTolerance(t *testing.T) {
+	for _, tc := range []struct {
+		amount, expected, tolerance uint64
+		ok                          bool
+	}{
+		{100, 100, 0, true},
+		{101, 100, 0, false},
+		{105, 100, 5, true},
+		{95, 100, 5, true},
+		{106, 100, 5, false},
+		{94, 100, 5, false},
+		{math.MaxUint64, math.MaxUint64 - 1, 1, true},
+	} {
+		if ok := withinTolerance(tc.amount, tc.expected, tc.tolerance); ok != tc.ok {
+			t.Errorf("expected %v for amount %d, expected amount %d and tolerance %d%%, got %v", tc.ok, tc.amount, tc.expected, tc.tolerance, ok)
+		}
+	}
+
+	oldCheque := newTestCheque()
+	newCheque := newTestCheque()
+	newCheque.CumulativePayout = oldCheque.CumulativePayout + 102
+	if _, err := newCheque.verifyChequeAgainstLast(oldCheque, 100, 0); err == nil {
+		t.Fatal("expected an error for a cheque amount differing from the price")
+	}
+	if _, err := newCheque.verifyChequeAgainstLast(oldCheque, 100, 2); err != nil {
+		t.Fatalf("expected a cheque amount within the tolerance to be accepted, got %v", err)
+	}
+
+	if err := validatePriceTolerance("http://oracle", 0); err == nil {
+		t.Fatal("expected a price oracle without tolerance to be rejected")
+	}
+	if err := validatePriceTolerance("", 101); err == nil {
+		t.Fatal("expected a tolerance above 100 percent to be rejected")
+	}
+	if err := validatePriceTolerance("http://oracle", 5); err != nil {
+		t.Fatalf("expected a price oracle with tolerance to be accepted, got %v", err)
+	}
+}
+
+// TestReceivedHoney tests that cheques are credited with honey in proportion to the amount paid
+func TestReceivedHoney(t *testing.T) {
+	for _, tc := range []struct {
+		honey, amount, expected, received uint64
+	}{
+		{100, 1000, 1000, 100},
+		{100, 950, 1000, 95},
+		{100, 1050, 1000, 100},
+		{100, 999, 1000, 99},
+		{math.MaxUint64, math.MaxUint64 - 1, math.MaxUint64, math.MaxUint64 - 1},
+	} {
+		if received := receivedHoney(tc.honey, tc.amount, tc.expected); received != tc.received {
+			t.Errorf("honey %d paid with %d of %d: expected %d honey, got %d", tc.honey, tc.amount, tc.expected, tc.received, received)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/state"
+)
+
+const (
+	// DefaultInterestDebtLevel is the honey amount a peer owes above which
+	// interest accrues. Peers owing it have been asked to pay.
+	DefaultInterestDebtLevel = DefaultPaymentRequestThreshold
+	// DefaultInterestGracePeriod is the time a peer may owe more than the
+	// interest debt level before interest accrues
+	DefaultInterestGracePeriod = 24 * time.Hour
+	// interestInterval is the interval at which interest is accrued on the debt of connected peers
+	interestInterval = time.Minute
+	// interestRateUnit is the unit of the interest rate, parts per million per hour
+	interestRateUnit = 1000000
+	// MaxInterestRate is the highest interest rate, 1% of the debt per hour,
+	// peers announcing a higher rate are rejected
+	MaxInterestRate = interestRateUnit / 100
+)
+
+const (
+	interestPrefix = "interest_"
+	auditInterest  = "interest"
+)
+
+// interestKey returns the store key of the debt crossing of the peer
+func interestKey(peer enode.ID) string {
+	return interestPrefix + peer.String()
+}
+
+// debtCrossing records when the debt with a peer crossed the interest debt level
+type debtCrossing struct {
+	Since     time.Time // time the debt last went above the interest debt level, zero if it is not above
+	AccruedAt time.Time // time interest was last accrued, zero if it was not accrued since the crossing
+	Interest  int64     // interest accrued since the crossing, which does not bear interest itself
+}
+
+// InterestTerms are the terms of the interest a node charges on the debts of
+// its peers. They are announced in the handshake, so that both nodes of a
+// debt accrue the same interest and keep agreeing on the balance.
+type InterestTerms struct {
+	DebtLevel   uint64 // honey amount a peer owes above which interest accrues
+	GracePeriod uint64 // seconds a peer may owe more than the debt level before interest accrues
+	Rate        uint64 // interest in parts per million of the debt per hour, no interest if 0
+}
+
+// validate checks interest terms announced by a peer
+func (t InterestTerms) validate() error {
+	if t.Rate == 0 {
+		return nil
+	}
+	if t.Rate > MaxInterestRate || t.DebtLevel == 0 || t.DebtLevel > math.MaxInt64 || t.GracePeriod > math.MaxInt64/uint64(time.Second) {
+		return ErrInvalidInterestTerms
+	}
+	return nil
+}
+
+// gracePeriod returns the grace period of the terms
+func (t InterestTerms) gracePeriod() time.Duration {
+	return time.Duration(t.GracePeriod) * time.Second
+}
+
+// interestTerms returns the interest terms of the node
+func (s *Swap) interestTerms() InterestTerms {
+	return InterestTerms{
+		DebtLevel:   uint64(s.params.InterestDebtLevel),
+		GracePeriod: uint64(s.params.InterestGracePeriod / time.Second),
+		Rate:        s.params.InterestRate,
+	}
+}
+
+// validateInterest checks that interest accrues on debt below the disconnect threshold
+// at a rate peers accept
+func validateInterest(debtLevel int64, rate uint64, gracePeriod time.Duration, disconnectThreshold int64) error {
+	if rate == 0 {
+		return nil
+	}
+	if rate > MaxInterestRate {
+		return fmt.Errorf("interest rate %d must not be higher than %d", rate, MaxInterestRate)
+	}
+	if debtLevel <= 0 || debtLevel >= disconnectThreshold {
+		return fmt.Errorf("interest debt level %d must be positive and lower than the disconnect threshold %d", debtLevel, disconnectThreshold)
+	}
+	if gracePeriod < 0 {
+		return fmt.Errorf("interest grace period %v must not be negative", gracePeriod)
+	}
+	return nil
+}
+
+// loadDebtCrossing loads the debt crossing of the peer, a zero one if there is none
+func (s *Swap) loadDebtCrossing(key string) (crossing debtCrossing, err error) {
+	err = s.store.Get(key, &crossing)
+	if err == state.ErrNotFound {
+		return debtCrossing{}, nil
+	}
+	return crossing, err
+}
+
+// setDebtCrossing persists the debt crossing before updating it in memory
+// the caller is expected to hold p.lock
+func (p *Peer) setDebtCrossing(crossing debtCrossing) error {
+	if err := p.swap.store.Put(p.key(interestKey(p.ID())), crossing); err != nil {
+		return err
+	}
+	p.debtCrossing = crossing
+	return nil
+}
+
+// accrueInterest records when the debt with the peer crosses the interest debt
+// level and adds interest to the debt for the time it stays above the level
+// after the grace period. The terms of the creditor apply: those of the node on
+// debts of the peer, and those the peer announced on debts owed to it. Interest
+// is the interest rate in parts per million of the principal per hour, the
+// debt without the interest accrued since the crossing, so interest is not
+// compounded. Fractions of honey are carried over to the next accrual.
+// the caller is expected to hold p.lock
+func (p *Peer) accrueInterest() error {
+	// peers of the first protocol version do not know about interest
+	if p.asset == "" || p.legacy {
+		return nil
+	}
+	now := p.swap.clock.Time()
+	balance := p.getBalance()
+	terms, debt, sign := p.swap.interestTerms(), balance, int64(1)
+	if balance < 0 {
+		terms, debt, sign = p.interestTerms, -balance, -1
+	}
+	crossing := p.debtCrossing
+	if terms.Rate == 0 || debt <= int64(terms.DebtLevel) {
+		if crossing.Since.IsZero() {
+			return nil
+		}
+		p.logger.Debug("debt went below the interest debt level", "balance", strconv.FormatInt(balance, 10))
+		return p.setDebtCrossing(debtCrossing{})
+	}
+	if crossing.Since.IsZero() {
+		p.logger.Info("debt went above the interest debt level", "balance", strconv.FormatInt(balance, 10), "level", terms.DebtLevel)
+		return p.setDebtCrossing(debtCrossing{Since: now})
+	}
+
+	from := crossing.Since.Add(terms.gracePeriod())
+	if crossing.AccruedAt.After(from) {
+		from = crossing.AccruedAt
+	}
+	elapsed := now.Sub(from)
+	if elapsed <= 0 {
+		return nil
+	}
+	principal := debt - crossing.Interest
+	if principal <= 0 {
+		return nil
+	}
+	interest := float64(principal) * float64(terms.Rate) / interestRateUnit * elapsed.Hours()
+	if interest < 1 {
+		return nil
+	}
+	// the balance must not wrap around
+	if interest > float64(math.MaxInt64-debt) {
+		interest = float64(math.MaxInt64 - debt)
+	}
+	if err := p.updateBalance(sign*int64(interest), auditInterest, ""); err != nil {
+		return err
+	}
+	metrics.GetOrRegisterCounter("swap.interest.honey", nil).Inc(int64(interest))
+	p.logger.Info("accrued interest on debt", "interest", sign*int64(interest), "since", crossing.Since)
+	crossing.AccruedAt = now
+	crossing.Interest += int64(interest)
+	return p.setDebtCrossing(crossing)
+}
+
+// accrueInterestLoop periodically accrues interest on the debts with connected
+// peers, so that it accrues while they do not use our services
+func (s *Swap) accrueInterestLoop() {
+	ticker := s.clock.NewTicker(interestInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, p := range s.peerList() {
+				p.lock.Lock()
+				err := p.accrueInterest()
+				p.lock.Unlock()
+				if err != nil {
+					p.logger.Error("error accruing interest", "err", err)
+				}
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
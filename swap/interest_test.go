@@ -0,0 +1,240 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethersphere/swarm/network"
+)
+
+// TestValidateInterest tests the validation of the interest parameters
+func TestValidateInterest(t *testing.T) {
+	for _, tc := range []struct {
+		debtLevel   int64
+		rate        uint64
+		gracePeriod time.Duration
+		ok          bool
+	}{
+		{0, 0, 0, true},
+		{100, 1000, time.Hour, true},
+		{0, 1000, time.Hour, false},
+		{1000, 1000, time.Hour, false},
+		{100, 1000, -time.Hour, false},
+		{100, MaxInterestRate + 1, time.Hour, false},
+	} {
+		err := validateInterest(tc.debtLevel, tc.rate, tc.gracePeriod, 1000)
+		if (err == nil) != tc.ok {
+			t.Errorf("debt level %d, rate %d, grace period %v: got error %v", tc.debtLevel, tc.rate, tc.gracePeriod, err)
+		}
+	}
+}
+
+// TestAccrueInterest tests that interest accrues on debt above the debt level
+// after the grace period and that the crossing is reset when the debt drops
+func TestAccrueInterest(t *testing.T) {
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	testDeploy(context.Background(), swap, big.NewInt(0))
+	sim := new(mclock.Simulated)
+	swap.clock = network.NewClock(sim, time.Now())
+	swap.params.InterestDebtLevel = 1000
+	swap.params.InterestGracePeriod = time.Hour
+	swap.params.InterestRate = 100000 // 10% per hour
+
+	testPeer := newDummyPeer()
+	debitor, err := swap.addPeer(testPeer.Peer, swap.owner.address, swap.GetParams().ContractAddress, DefaultRetrievePricing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	debitor.lock.Lock()
+	defer debitor.lock.Unlock()
+	accrue := func(balance int64) {
+		t.Helper()
+		if err := debitor.accrueInterest(); err != nil {
+			t.Fatal(err)
+		}
+		if debitor.getBalance() != balance {
+			t.Fatalf("expected balance %d, got %d", balance, debitor.getBalance())
+		}
+	}
+
+	if err := debitor.setBalance(1000); err != nil {
+		t.Fatal(err)
+	}
+	accrue(1000)
+	if !debitor.debtCrossing.Since.IsZero() {
+		t.Fatal("expected no crossing at the debt level")
+	}
+
+	if err := debitor.setBalance(2000); err != nil {
+		t.Fatal(err)
+	}
+	accrue(2000)
+	crossedAt := swap.clock.Time()
+	if !debitor.debtCrossing.Since.Equal(crossedAt) {
+		t.Fatalf("expected crossing at %v, got %v", crossedAt, debitor.debtCrossing.Since)
+	}
+
+	// no interest within the grace period
+	sim.Run(time.Hour)
+	accrue(2000)
+
+	// interest for the time after the grace period
+	sim.Run(30 * time.Minute)
+	accrue(2100)
+	// interest accrues on the principal only
+	sim.Run(time.Hour)
+	accrue(2300)
+	// fractions of honey are carried over
+	sim.Run(time.Millisecond)
+	accrue(2300)
+
+	// the crossing survives a restart
+	crossing, err := swap.loadDebtCrossing(debitor.key(interestKey(debitor.ID())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !crossing.Since.Equal(crossedAt) || !crossing.AccruedAt.Equal(swap.clock.Time().Add(-time.Millisecond)) || crossing.Interest != 300 {
+		t.Fatalf("expected persisted crossing at %v, got %+v", crossedAt, crossing)
+	}
+
+	if err := debitor.setBalance(500); err != nil {
+		t.Fatal(err)
+	}
+	accrue(500)
+	if debitor.debtCrossing != (debtCrossing{}) {
+		t.Fatalf("expected crossing to be reset, got %+v", debitor.debtCrossing)
+	}
+
+	// the grace period starts again with the next crossing
+	if err := debitor.setBalance(2000); err != nil {
+		t.Fatal(err)
+	}
+	accrue(2000)
+	sim.Run(time.Hour)
+	accrue(2000)
+}
+
+// TestAccrueInterestDisabled tests that no interest accrues if the rate is 0
+func TestAccrueInterestDisabled(t *testing.T) {
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	testDeploy(context.Background(), swap, big.NewInt(0))
+	sim := new(mclock.Simulated)
+	swap.clock = network.NewClock(sim, time.Now())
+	swap.params.InterestDebtLevel = 1000
+
+	testPeer := newDummyPeer()
+	debitor, err := swap.addPeer(testPeer.Peer, swap.owner.address, swap.GetParams().ContractAddress, DefaultRetrievePricing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	debitor.lock.Lock()
+	defer debitor.lock.Unlock()
+	if err := debitor.setBalance(2000); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := debitor.accrueInterest(); err != nil {
+			t.Fatal(err)
+		}
+		sim.Run(48 * time.Hour)
+	}
+	if debitor.getBalance() != 2000 {
+		t.Fatalf("expected balance 2000, got %d", debitor.getBalance())
+	}
+	if !debitor.debtCrossing.Since.IsZero() {
+		t.Fatal("expected no crossing without interest")
+	}
+}
+
+// TestAccrueInterestOwed tests that interest accrues on debts owed to the peer
+// by the terms the peer announced, and not with peers of the first protocol version
+func TestAccrueInterestOwed(t *testing.T) {
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	testDeploy(context.Background(), swap, big.NewInt(0))
+	sim := new(mclock.Simulated)
+	swap.clock = network.NewClock(sim, time.Now())
+	// the terms of the node do not apply to its own debts
+	swap.params.InterestDebtLevel = 10
+	swap.params.InterestRate = MaxInterestRate
+
+	testPeer := newDummyPeer()
+	creditor, err := swap.addPeer(testPeer.Peer, swap.owner.address, swap.GetParams().ContractAddress, DefaultRetrievePricing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creditor.lock.Lock()
+	defer creditor.lock.Unlock()
+	creditor.interestTerms = InterestTerms{DebtLevel: 1000, GracePeriod: 3600, Rate: 100000}
+	accrue := func(balance int64) {
+		t.Helper()
+		if err := creditor.accrueInterest(); err != nil {
+			t.Fatal(err)
+		}
+		if creditor.getBalance() != balance {
+			t.Fatalf("expected balance %d, got %d", balance, creditor.getBalance())
+		}
+	}
+
+	if err := creditor.setBalance(-1000); err != nil {
+		t.Fatal(err)
+	}
+	accrue(-1000)
+	sim.Run(2 * time.Hour)
+	accrue(-1000)
+
+	if err := creditor.setBalance(-2000); err != nil {
+		t.Fatal(err)
+	}
+	accrue(-2000)
+	sim.Run(time.Hour)
+	accrue(-2000)
+	sim.Run(time.Hour)
+	accrue(-2200)
+
+	// peers of the first protocol version do not charge interest
+	creditor.legacy = true
+	sim.Run(time.Hour)
+	accrue(-2200)
+}
+
+// TestInterestTermsValidate tests the validation of interest terms announced by peers
+func TestInterestTermsValidate(t *testing.T) {
+	for _, tc := range []struct {
+		terms InterestTerms
+		ok    bool
+	}{
+		{InterestTerms{}, true},
+		{InterestTerms{DebtLevel: 1000, GracePeriod: 3600, Rate: MaxInterestRate}, true},
+		{InterestTerms{DebtLevel: 1000, Rate: MaxInterestRate + 1}, false},
+		{InterestTerms{Rate: 1}, false},
+		{InterestTerms{DebtLevel: math.MaxUint64, Rate: 1}, false},
+		{InterestTerms{DebtLevel: 1000, GracePeriod: math.MaxUint64, Rate: 1}, false},
+	} {
+		if err := tc.terms.validate(); (err == nil) != tc.ok {
+			t.Errorf("terms %+v: got error %v", tc.terms, err)
+		}
+	}
+}
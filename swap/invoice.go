@@ -0,0 +1,235 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// Invoice export formats
+const (
+	InvoiceFormatCSV    = "csv"    // comma separated values, one line per invoice
+	InvoiceFormatLedger = "ledger" // plain text accounting journal as read by ledger and hledger
+)
+
+// Cheque directions
+const (
+	ChequeSent     = "sent"
+	ChequeReceived = "received"
+)
+
+// ChequeRecord is the bookkeeping entry of a cheque sent to or received from a peer.
+// Amount is the increase of the cumulative payout, which is the value of the cheque
+// converted from honey at the price in effect when the cheque was issued.
+type ChequeRecord struct {
+	Time             time.Time // time of cheque emission or reception
+	Peer             enode.ID  // counterparty of the cheque
+	Direction        string    // ChequeSent or ChequeReceived
+	Honey            uint64    // honey amount of the cheque
+	Amount           uint64    // value of the cheque in wei
+	CumulativePayout uint64    // cumulative payout of the cheque
+}
+
+// Invoice sums up the cheques exchanged with a peer in one direction during a calendar month
+type Invoice struct {
+	Month     time.Time // first day of the month, in UTC
+	Peer      enode.ID
+	Direction string
+	Cheques   int    // number of cheques
+	Honey     uint64 // total honey amount
+	Amount    uint64 // total value in wei
+}
+
+// HoneyPrice returns the average price of one honey in wei over the cheques of the invoice
+func (i *Invoice) HoneyPrice() *big.Rat {
+	if i.Honey == 0 {
+		return new(big.Rat)
+	}
+	return new(big.Rat).SetFrac(new(big.Int).SetUint64(i.Amount), new(big.Int).SetUint64(i.Honey))
+}
+
+// returns the store key for a cheque record, cumulative payouts grow with every cheque
+// so that records of a peer are unique and ordered by the key
+func chequeRecordKey(peer enode.ID, direction string, cumulativePayout uint64) string {
+	return fmt.Sprintf("%s%s_%s_%020d", chequeRecordPrefix, peer.String(), direction, cumulativePayout)
+}
+
+// addChequeRecord stores the bookkeeping entry of a sent or received cheque
+// the caller is expected to hold p.lock
+func (p *Peer) addChequeRecord(direction string, cheque *Cheque, amount uint64) error {
+	record := ChequeRecord{
+		Time:             p.swap.clock.Time(),
+		Peer:             p.ID(),
+		Direction:        direction,
+		Honey:            cheque.Honey,
+		Amount:           amount,
+		CumulativePayout: cheque.CumulativePayout,
+	}
+	return p.swap.store.Put(chequeRecordKey(p.ID(), direction, cheque.CumulativePayout), record)
+}
+
+// ChequeRecords returns the bookkeeping entries of all sent and received cheques, ordered by time
+func (s *Swap) ChequeRecords() ([]ChequeRecord, error) {
+	var records []ChequeRecord
+	err := s.store.Iterate(chequeRecordPrefix, func(key []byte, value []byte) (stop bool, err error) {
+		var record ChequeRecord
+		if err := json.Unmarshal(value, &record); err != nil {
+			return true, err
+		}
+		records = append(records, record)
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+	return records, nil
+}
+
+// Invoices aggregates cheque records by month, peer and direction,
+// ordered by month, peer and direction
+func Invoices(records []ChequeRecord) []*Invoice {
+	type invoiceKey struct {
+		month     int64
+		peer      enode.ID
+		direction string
+	}
+	invoices := make(map[invoiceKey]*Invoice)
+	for _, r := range records {
+		t := r.Time.UTC()
+		month := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		k := invoiceKey{month: month.Unix(), peer: r.Peer, direction: r.Direction}
+		invoice, ok := invoices[k]
+		if !ok {
+			invoice = &Invoice{Month: month, Peer: r.Peer, Direction: r.Direction}
+			invoices[k] = invoice
+		}
+		invoice.Cheques++
+		invoice.Honey += r.Honey
+		invoice.Amount += r.Amount
+	}
+	result := make([]*Invoice, 0, len(invoices))
+	for _, invoice := range invoices {
+		result = append(result, invoice)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if !a.Month.Equal(b.Month) {
+			return a.Month.Before(b.Month)
+		}
+		if a.Peer != b.Peer {
+			return bytes.Compare(a.Peer[:], b.Peer[:]) < 0
+		}
+		return a.Direction < b.Direction
+	})
+	return result
+}
+
+// weiToEther formats a wei amount as ether with full precision
+func weiToEther(wei uint64) string {
+	return new(big.Rat).SetFrac(new(big.Int).SetUint64(wei), big.NewInt(1e18)).FloatString(18)
+}
+
+// WriteInvoicesCSV writes the invoices as CSV with a header line
+func WriteInvoicesCSV(w io.Writer, invoices []*Invoice) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"month", "peer", "direction", "cheques", "honey", "honey_price_wei", "amount_wei", "amount_eth"}); err != nil {
+		return err
+	}
+	for _, i := range invoices {
+		err := cw.Write([]string{
+			i.Month.Format("2006-01"),
+			i.Peer.String(),
+			i.Direction,
+			strconv.Itoa(i.Cheques),
+			strconv.FormatUint(i.Honey, 10),
+			i.HoneyPrice().FloatString(6),
+			strconv.FormatUint(i.Amount, 10),
+			weiToEther(i.Amount),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteInvoicesLedger writes the invoices as a double-entry journal, with one transaction
+// per invoice dated on the last day of its month. Received cheques are booked as income
+// and sent cheques as expenses against the chequebook.
+func WriteInvoicesLedger(w io.Writer, invoices []*Invoice) error {
+	for _, i := range invoices {
+		date := i.Month.AddDate(0, 1, -1).Format("2006/01/02")
+		account := "Income:Swarm:" + i.Peer.String()
+		description := "Swarm cheques received from " + i.Peer.String()
+		if i.Direction == ChequeSent {
+			account = "Expenses:Swarm:" + i.Peer.String()
+			description = "Swarm cheques sent to " + i.Peer.String()
+		}
+		_, err := fmt.Fprintf(w, "%s %s\n    ; cheques: %d, honey: %d, honey price: %s wei\n",
+			date, description, i.Cheques, i.Honey, i.HoneyPrice().FloatString(6))
+		if err != nil {
+			return err
+		}
+		amount := weiToEther(i.Amount) + " ETH"
+		if i.Direction == ChequeSent {
+			_, err = fmt.Fprintf(w, "    %s  %s\n    Assets:Swarm:Chequebook\n\n", account, amount)
+		} else {
+			_, err = fmt.Fprintf(w, "    Assets:Swarm:Chequebook  %s\n    %s\n\n", amount, account)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportInvoices returns the monthly invoices per peer of all sent and received cheques
+// in the given format, either InvoiceFormatCSV or InvoiceFormatLedger
+func (s *Swap) ExportInvoices(format string) (string, error) {
+	records, err := s.ChequeRecords()
+	if err != nil {
+		return "", err
+	}
+	invoices := Invoices(records)
+	var buf bytes.Buffer
+	switch format {
+	case InvoiceFormatCSV:
+		err = WriteInvoicesCSV(&buf, invoices)
+	case InvoiceFormatLedger:
+		err = WriteInvoicesLedger(&buf, invoices)
+	default:
+		return "", fmt.Errorf("unknown invoice format %q", format)
+	}
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
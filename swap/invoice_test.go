@@ -0,0 +1,132 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/network"
+)
+
+// TestChequeRecords tests that emitted cheques are recorded with their value
+func TestChequeRecords(t *testing.T) {
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	testDeploy(context.Background(), swap, big.NewInt(int64(DefaultPaymentThreshold)*2))
+	testPeer := newDummyPeerWithSpec(Spec)
+	swap.addPeer(testPeer.Peer, swap.owner.address, swap.GetParams().ContractAddress, DefaultRetrievePricing)
+
+	now := time.Date(2019, 12, 1, 10, 30, 0, 0, time.UTC)
+	swap.clock = network.NewClock(new(mclock.Simulated), now)
+
+	threshold := int64(DefaultPaymentThreshold)
+	if err := swap.Add(-threshold, testPeer.Peer); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := swap.ChequeRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("got %v cheque records, want 1", len(records))
+	}
+	r := records[0]
+	if r.Peer != testPeer.ID() || r.Direction != ChequeSent || !r.Time.Equal(now) {
+		t.Errorf("got record %+v", r)
+	}
+	price, err := swap.honeyPriceOracle.GetPrice(uint64(threshold))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Honey != uint64(threshold) || r.Amount != price || r.CumulativePayout != price {
+		t.Errorf("got honey %v, amount %v, cumulative payout %v, want %v, %v, %v", r.Honey, r.Amount, r.CumulativePayout, threshold, price, price)
+	}
+}
+
+// TestExportInvoices tests the aggregation of cheque records
+// into monthly invoices and their export formats
+func TestExportInvoices(t *testing.T) {
+	peer1 := enode.HexID("1000000000000000000000000000000000000000000000000000000000000000")
+	peer2 := enode.HexID("2000000000000000000000000000000000000000000000000000000000000000")
+	nov := time.Date(2019, 11, 30, 23, 0, 0, 0, time.UTC)
+	dec := time.Date(2019, 12, 1, 1, 0, 0, 0, time.UTC)
+	records := []ChequeRecord{
+		{Time: nov, Peer: peer2, Direction: ChequeReceived, Honey: 100, Amount: 1e15, CumulativePayout: 1e15},
+		{Time: dec, Peer: peer1, Direction: ChequeSent, Honey: 10, Amount: 3e16, CumulativePayout: 3e16},
+		{Time: dec, Peer: peer2, Direction: ChequeReceived, Honey: 100, Amount: 1e15, CumulativePayout: 2e15},
+		{Time: dec.Add(time.Hour), Peer: peer2, Direction: ChequeReceived, Honey: 300, Amount: 5e15, CumulativePayout: 7e15},
+	}
+
+	invoices := Invoices(records)
+	if len(invoices) != 3 {
+		t.Fatalf("got %v invoices, want 3", len(invoices))
+	}
+	last := invoices[2]
+	if last.Peer != peer2 || last.Cheques != 2 || last.Honey != 400 || last.Amount != 6e15 {
+		t.Errorf("got invoice %+v", last)
+	}
+
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	for _, r := range records {
+		if err := swap.store.Put(chequeRecordKey(r.Peer, r.Direction, r.CumulativePayout), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	csv, err := swap.ExportInvoices(InvoiceFormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantCSV := "month,peer,direction,cheques,honey,honey_price_wei,amount_wei,amount_eth\n" +
+		"2019-11," + peer2.String() + ",received,1,100,10000000000000.000000,1000000000000000,0.001000000000000000\n" +
+		"2019-12," + peer1.String() + ",sent,1,10,3000000000000000.000000,30000000000000000,0.030000000000000000\n" +
+		"2019-12," + peer2.String() + ",received,2,400,15000000000000.000000,6000000000000000,0.006000000000000000\n"
+	if csv != wantCSV {
+		t.Errorf("got csv\n%s\nwant\n%s", csv, wantCSV)
+	}
+
+	ledger, err := swap.ExportInvoices(InvoiceFormatLedger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantLedger := "2019/11/30 Swarm cheques received from " + peer2.String() + "\n" +
+		"    ; cheques: 1, honey: 100, honey price: 10000000000000.000000 wei\n" +
+		"    Assets:Swarm:Chequebook  0.001000000000000000 ETH\n" +
+		"    Income:Swarm:" + peer2.String() + "\n\n" +
+		"2019/12/31 Swarm cheques sent to " + peer1.String() + "\n" +
+		"    ; cheques: 1, honey: 10, honey price: 3000000000000000.000000 wei\n" +
+		"    Expenses:Swarm:" + peer1.String() + "  0.030000000000000000 ETH\n" +
+		"    Assets:Swarm:Chequebook\n\n" +
+		"2019/12/31 Swarm cheques received from " + peer2.String() + "\n" +
+		"    ; cheques: 2, honey: 400, honey price: 15000000000000.000000 wei\n" +
+		"    Assets:Swarm:Chequebook  0.006000000000000000 ETH\n" +
+		"    Income:Swarm:" + peer2.String() + "\n\n"
+	if ledger != wantLedger {
+		t.Errorf("got ledger\n%s\nwant\n%s", ledger, wantLedger)
+	}
+
+	if _, err := swap.ExportInvoices("xls"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
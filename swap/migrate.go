@@ -0,0 +1,248 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// StateExportVersion is the version of the swap state export format
+const StateExportVersion = 1
+
+// auditImport is the reason of balance mutations importing the balance of a migrated node
+const auditImport = "import"
+
+// StateExport is the swap state of a node, exported to migrate the node
+// without losing the debts accrued with its peers and the cheques exchanged with them
+type StateExport struct {
+	Version    uint64            `json:"version"`    // version of the export format
+	ChainID    uint64            `json:"chainID"`    // chain id of the blockchain of the chequebook
+	Chequebook common.Address    `json:"chequebook"` // address of the chequebook of the node
+	Owner      common.Address    `json:"owner"`      // owner of the chequebook
+	Accounts   []ExportedAccount `json:"accounts"`   // accounts with the peers, ordered by peer and asset
+}
+
+// ExportedAccount is the state of the account with a peer in a settlement asset
+type ExportedAccount struct {
+	Peer               enode.ID `json:"peer"`
+	Asset              Asset    `json:"asset"`
+	Balance            int64    `json:"balance"`
+	PendingCheque      *Cheque  `json:"pendingCheque,omitempty"`
+	LastSentCheque     *Cheque  `json:"lastSentCheque,omitempty"`
+	LastReceivedCheque *Cheque  `json:"lastReceivedCheque,omitempty"`
+}
+
+// empty returns true if nothing was accounted with the peer
+func (a *ExportedAccount) empty() bool {
+	return a.Balance == 0 && a.PendingCheque == nil && a.LastSentCheque == nil && a.LastReceivedCheque == nil
+}
+
+// accountKey identifies an account by peer and asset
+type accountKey struct {
+	peer  enode.ID
+	asset Asset
+}
+
+// parseAccountKey returns the account and the prefix of a store key of a
+// balance or a cheque, false if the key is not a balance or a cheque
+func parseAccountKey(key string) (accountKey, string, bool) {
+	asset := AssetETH
+	if strings.HasPrefix(key, assetPrefix) {
+		rest := strings.TrimPrefix(key, assetPrefix)
+		i := strings.Index(rest, "_")
+		if i < 0 {
+			return accountKey{}, "", false
+		}
+		asset, key = Asset(rest[:i]), rest[i+1:]
+	}
+	for _, prefix := range []string{balancePrefix, pendingChequePrefix, sentChequePrefix, receivedChequePrefix} {
+		if strings.HasPrefix(key, prefix) {
+			return accountKey{keyToID(key, prefix), asset}, prefix, true
+		}
+	}
+	return accountKey{}, "", false
+}
+
+// storedAccounts returns the stored balances and cheques by account
+func (s *Swap) storedAccounts() (map[accountKey]*ExportedAccount, error) {
+	accounts := make(map[accountKey]*ExportedAccount)
+	err := s.store.Iterate("", func(key []byte, value []byte) (stop bool, err error) {
+		k, prefix, ok := parseAccountKey(string(key))
+		if !ok {
+			return false, nil
+		}
+		a, ok := accounts[k]
+		if !ok {
+			a = &ExportedAccount{Peer: k.peer, Asset: k.asset}
+			accounts[k] = a
+		}
+		switch prefix {
+		case balancePrefix:
+			err = json.Unmarshal(value, &a.Balance)
+		case pendingChequePrefix:
+			err = json.Unmarshal(value, &a.PendingCheque)
+		case sentChequePrefix:
+			err = json.Unmarshal(value, &a.LastSentCheque)
+		case receivedChequePrefix:
+			err = json.Unmarshal(value, &a.LastReceivedCheque)
+		}
+		return err != nil, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// ExportState exports the balances and the cheques of all accounts with
+// peers together with the chequebook, to be imported on another node
+func (s *Swap) ExportState() (*StateExport, error) {
+	accounts, err := s.storedAccounts()
+	if err != nil {
+		return nil, err
+	}
+	// the balances of connected peers are kept in memory
+	for _, p := range s.peerList() {
+		if p.asset == "" {
+			continue
+		}
+		p.lock.RLock()
+		k := accountKey{p.ID(), p.asset}
+		if a, ok := accounts[k]; ok {
+			a.Balance = p.decayedBalance(s.clock.Time())
+		}
+		p.lock.RUnlock()
+	}
+
+	export := &StateExport{
+		Version:    StateExportVersion,
+		ChainID:    s.chainID,
+		Chequebook: s.GetParams().ContractAddress,
+		Owner:      s.owner.address,
+	}
+	for _, a := range accounts {
+		if !a.empty() {
+			export.Accounts = append(export.Accounts, *a)
+		}
+	}
+	sort.Slice(export.Accounts, func(i, j int) bool {
+		a, b := export.Accounts[i], export.Accounts[j]
+		if a.Peer != b.Peer {
+			return a.Peer.String() < b.Peer.String()
+		}
+		return a.Asset < b.Asset
+	})
+	return export, nil
+}
+
+// ImportState imports the balances and the cheques exported by a node
+// migrated to this one. The node must use the same chequebook, that is it
+// must be started with the chequebook of the migrated node and its owner key.
+// No account is imported if the node already accounted with any of the
+// peers in the same asset, so the state is best imported before the node
+// connects to them. The peers of the migrated node converge with the imported
+// balances when they reconnect.
+func (s *Swap) ImportState(export *StateExport) error {
+	if export.Version != StateExportVersion {
+		return fmt.Errorf("unsupported swap state export version %d, supported %d", export.Version, StateExportVersion)
+	}
+	if export.ChainID != s.chainID {
+		return fmt.Errorf("swap state exported on chain %d, node on chain %d", export.ChainID, s.chainID)
+	}
+	if chequebook := s.GetParams().ContractAddress; export.Chequebook != chequebook {
+		return fmt.Errorf("swap state exported with chequebook %s, node uses chequebook %s", export.Chequebook.Hex(), chequebook.Hex())
+	}
+	if export.Owner != s.owner.address {
+		return fmt.Errorf("swap state exported with chequebook owner %s, node owner is %s", export.Owner.Hex(), s.owner.address.Hex())
+	}
+
+	existing, err := s.storedAccounts()
+	if err != nil {
+		return err
+	}
+	imported := make(map[accountKey]bool)
+	for _, a := range export.Accounts {
+		k := accountKey{a.Peer, a.Asset}
+		if a.Asset == "" {
+			return fmt.Errorf("account with peer %s has no settlement asset", a.Peer)
+		}
+		if imported[k] {
+			return fmt.Errorf("account with peer %s in %s exported twice", a.Peer, a.Asset)
+		}
+		imported[k] = true
+		if e, ok := existing[k]; ok && !e.empty() {
+			return fmt.Errorf("account with peer %s in %s already exists", a.Peer, a.Asset)
+		}
+	}
+
+	for _, a := range export.Accounts {
+		if err := s.importAccount(a); err != nil {
+			return err
+		}
+	}
+	swapLog.Info("imported swap state", "accounts", len(export.Accounts))
+	return nil
+}
+
+// importAccount stores the balance and the cheques of an account, in
+// memory too if the peer is connected and settles in the asset
+func (s *Swap) importAccount(a ExportedAccount) error {
+	if p := s.getPeer(a.Peer); p != nil && p.asset == a.Asset {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		if a.PendingCheque != nil {
+			if err := p.setPendingCheque(a.PendingCheque); err != nil {
+				return err
+			}
+		}
+		if a.LastSentCheque != nil {
+			if err := p.setLastSentCheque(a.LastSentCheque); err != nil {
+				return err
+			}
+		}
+		if a.LastReceivedCheque != nil {
+			if err := p.setLastReceivedCheque(a.LastReceivedCheque); err != nil {
+				return err
+			}
+		}
+		return p.updateBalance(a.Balance-p.getBalance(), auditImport, "")
+	}
+
+	cheques := []struct {
+		key    string
+		cheque *Cheque
+	}{
+		{pendingChequeKey(a.Peer), a.PendingCheque},
+		{sentChequeKey(a.Peer), a.LastSentCheque},
+		{receivedChequeKey(a.Peer), a.LastReceivedCheque},
+	}
+	for _, c := range cheques {
+		if c.cheque == nil {
+			continue
+		}
+		if err := s.store.Put(assetKey(c.key, a.Asset), c.cheque); err != nil {
+			return err
+		}
+	}
+	return s.store.Put(assetKey(balanceKey(a.Peer), a.Asset), a.Balance)
+}
@@ -0,0 +1,165 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// TestStateExportImport tests that the balances and cheques exported by a
+// node are imported on a node using the same chequebook
+func TestStateExportImport(t *testing.T) {
+	source, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	if err := testDeploy(context.Background(), source, big.NewInt(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	// a connected peer settling in ETH
+	testPeer := newDummyPeer()
+	peer, err := source.addPeer(testPeer.Peer, source.owner.address, source.GetParams().ContractAddress, DefaultRetrievePricing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := newTestCheque()
+	peer.lock.Lock()
+	if err := peer.setBalance(-100); err != nil {
+		t.Fatal(err)
+	}
+	if err := peer.setLastSentCheque(sent); err != nil {
+		t.Fatal(err)
+	}
+	peer.lock.Unlock()
+
+	// a disconnected peer settling in a token
+	token := Asset(common.HexToAddress("0x0a").Hex())
+	tokenPeer := enode.HexID("0x1000000000000000000000000000000000000000000000000000000000000001")
+	received := newRandomTestCheque()
+	if err := source.store.Put(assetKey(balanceKey(tokenPeer), token), int64(42)); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.store.Put(assetKey(receivedChequeKey(tokenPeer), token), received); err != nil {
+		t.Fatal(err)
+	}
+
+	export, err := source.ExportState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bundle StateExport
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		t.Fatal(err)
+	}
+	if len(bundle.Accounts) != 2 {
+		t.Fatalf("expected 2 exported accounts, got %d", len(bundle.Accounts))
+	}
+
+	dest, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	if err := testDeploy(context.Background(), dest, big.NewInt(0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := dest.ImportState(&bundle); err == nil || !strings.Contains(err.Error(), "chequebook") {
+		t.Fatalf("expected an error importing the state of another chequebook, got %v", err)
+	}
+
+	dest.contract = source.contract
+	if err := dest.ImportState(&bundle); err != nil {
+		t.Fatal(err)
+	}
+	if balance, err := dest.PeerBalance(testPeer.ID()); err != nil || balance != -100 {
+		t.Fatalf("expected balance -100, got %d (%v)", balance, err)
+	}
+	cheques, err := dest.PeerCheques(testPeer.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cheques.LastSentCheque, sent) {
+		t.Fatalf("expected last sent cheque %v, got %v", sent, cheques.LastSentCheque)
+	}
+	balances, err := dest.PeerAssetBalances(tokenPeer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balances[token] != 42 {
+		t.Fatalf("expected token balance 42, got %d", balances[token])
+	}
+	imported, err := dest.loadCheque(assetKey(receivedChequeKey(tokenPeer), token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(imported, received) {
+		t.Fatalf("expected last received cheque %v, got %v", received, imported)
+	}
+
+	if err := dest.ImportState(&bundle); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("expected an error importing existing accounts, got %v", err)
+	}
+}
+
+// TestStateImportConnectedPeer tests that the account with a connected peer
+// is imported in memory too
+func TestStateImportConnectedPeer(t *testing.T) {
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	if err := testDeploy(context.Background(), swap, big.NewInt(0)); err != nil {
+		t.Fatal(err)
+	}
+	testPeer := newDummyPeer()
+	peer, err := swap.addPeer(testPeer.Peer, swap.owner.address, swap.GetParams().ContractAddress, DefaultRetrievePricing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := newTestCheque()
+	err = swap.ImportState(&StateExport{
+		Version:    StateExportVersion,
+		ChainID:    swap.chainID,
+		Chequebook: swap.GetParams().ContractAddress,
+		Owner:      swap.owner.address,
+		Accounts: []ExportedAccount{{
+			Peer:               testPeer.ID(),
+			Asset:              AssetETH,
+			Balance:            500,
+			LastReceivedCheque: received,
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peer.lock.RLock()
+	defer peer.lock.RUnlock()
+	if balance := peer.getBalance(); balance != 500 {
+		t.Fatalf("expected balance 500, got %d", balance)
+	}
+	if !reflect.DeepEqual(peer.getLastReceivedCheque(), received) {
+		t.Fatalf("expected last received cheque %v, got %v", received, peer.getLastReceivedCheque())
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// ErrOwnerLocked is returned when signing needs the chequebook owner key, which is locked in the keystore
+	ErrOwnerLocked = errors.New("chequebook owner key is locked")
+	// ErrNoOwnerKeystore is returned when the chequebook owner key is not in the node keystore
+	ErrNoOwnerKeystore = errors.New("chequebook owner key is not in the keystore")
+)
+
+// Owner encapsulates information related to accessing the contract
+type Owner struct {
+	address       common.Address     // owner address
+	privateKey    *ecdsa.PrivateKey  // private key, nil if it is kept locked in the keystore
+	publicKey     *ecdsa.PublicKey   // public key
+	keystore      *keystore.KeyStore // keystore holding the key, nil if the key is not in the keystore
+	account       accounts.Account   // keystore account of the key
+	unlockedUntil time.Time          // time the keystore locks the key again, zero if not timed
+	lock          sync.RWMutex       // lock for the private key and the unlock time
+}
+
+// OwnerStatus describes the chequebook owner key
+type OwnerStatus struct {
+	Address       common.Address // address of the chequebook owner
+	Keystore      bool           // whether the key is in the node keystore
+	Locked        bool           // whether cheques and transactions can not be signed until the key is unlocked
+	UnlockedUntil time.Time      // time the key is locked again, zero if it is not unlocked for a limited time
+}
+
+// createOwner assings keys and addresses
+func createOwner(prvkey *ecdsa.PrivateKey) *Owner {
+	pubkey := &prvkey.PublicKey
+	return &Owner{
+		address:    crypto.PubkeyToAddress(*pubkey),
+		privateKey: prvkey,
+		publicKey:  pubkey,
+	}
+}
+
+// newOwner returns the owner of the key, which is loaded through the keystore
+// if the keystore holds it, so that the key can be locked and its password changed
+func newOwner(prvkey *ecdsa.PrivateKey, ks *keystore.KeyStore) (*Owner, error) {
+	owner := createOwner(prvkey)
+	if ks == nil || !ks.HasAddress(owner.address) {
+		return owner, nil
+	}
+	account, err := ks.Find(accounts.Account{Address: owner.address})
+	if err != nil {
+		return nil, err
+	}
+	owner.keystore = ks
+	owner.account = account
+	return owner, nil
+}
+
+// sign signs the hash with the owner key, through the keystore if the key is locked in it
+func (o *Owner) sign(hash []byte) ([]byte, error) {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+	if o.privateKey != nil {
+		return crypto.Sign(hash, o.privateKey)
+	}
+	sig, err := o.keystore.SignHash(o.account, hash)
+	if err == keystore.ErrLocked {
+		return nil, ErrOwnerLocked
+	}
+	return sig, err
+}
+
+// transactor returns the transaction options signing with the owner key,
+// through the keystore if the key is locked in it
+func (o *Owner) transactor() (*bind.TransactOpts, error) {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+	if o.privateKey != nil {
+		return bind.NewKeyedTransactor(o.privateKey), nil
+	}
+	if o.keystoreLocked() {
+		return nil, ErrOwnerLocked
+	}
+	return bind.NewKeyStoreTransactor(o.keystore, o.account)
+}
+
+// keystoreLocked returns whether the keystore can not sign with the key
+// the caller is expected to hold o.lock
+func (o *Owner) keystoreLocked() bool {
+	for _, w := range o.keystore.Wallets() {
+		if !w.Contains(o.account) {
+			continue
+		}
+		status, err := w.Status()
+		return err != nil || status != "Unlocked"
+	}
+	return true
+}
+
+// status returns the status of the owner key
+func (o *Owner) status() OwnerStatus {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+	status := OwnerStatus{
+		Address:  o.address,
+		Keystore: o.keystore != nil,
+	}
+	if o.privateKey == nil {
+		status.Locked = o.keystoreLocked()
+		if !status.Locked {
+			status.UnlockedUntil = o.unlockedUntil
+		}
+	}
+	return status
+}
+
+// lockKey drops the key from memory and locks it in the keystore,
+// so that signing needs the key to be unlocked with its password
+func (o *Owner) lockKey() error {
+	if o.keystore == nil {
+		return ErrNoOwnerKeystore
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.privateKey = nil
+	o.unlockedUntil = time.Time{}
+	return o.keystore.Lock(o.address)
+}
+
+// unlockKey unlocks the key in the keystore for the timeout, until it is locked if the timeout is 0
+func (o *Owner) unlockKey(password string, timeout time.Duration) error {
+	if o.keystore == nil {
+		return ErrNoOwnerKeystore
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.privateKey != nil {
+		return errors.New("chequebook owner key is not locked")
+	}
+	if err := o.keystore.TimedUnlock(o.account, password, timeout); err != nil {
+		return fmt.Errorf("error unlocking chequebook owner key: %v", err)
+	}
+	o.unlockedUntil = time.Time{}
+	if timeout > 0 {
+		o.unlockedUntil = time.Now().Add(timeout)
+	}
+	return nil
+}
+
+// OwnerStatus returns the status of the chequebook owner key
+func (s *Swap) OwnerStatus() OwnerStatus {
+	return s.owner.status()
+}
+
+// LockOwner locks the chequebook owner key in the keystore. Cheques are
+// not emitted and received cheques are not cashed until it is unlocked.
+func (s *Swap) LockOwner() error {
+	if err := s.owner.lockKey(); err != nil {
+		return err
+	}
+	swapLog.Info("chequebook owner key locked", "owner", s.owner.address)
+	return nil
+}
+
+// UnlockOwner unlocks the locked chequebook owner key for the given number of
+// seconds, until it is locked again if seconds is 0
+func (s *Swap) UnlockOwner(password string, seconds uint64) error {
+	if err := s.owner.unlockKey(password, time.Duration(seconds)*time.Second); err != nil {
+		return err
+	}
+	swapLog.Info("chequebook owner key unlocked", "owner", s.owner.address, "seconds", seconds)
+	return nil
+}
+
+// ChangeOwnerPassword changes the password of the chequebook owner key in the keystore
+func (s *Swap) ChangeOwnerPassword(oldPassword, newPassword string) error {
+	if s.owner.keystore == nil {
+		return ErrNoOwnerKeystore
+	}
+	if err := s.owner.keystore.Update(s.owner.account, oldPassword, newPassword); err != nil {
+		return fmt.Errorf("error changing chequebook owner key password: %v", err)
+	}
+	swapLog.Info("chequebook owner key password changed", "owner", s.owner.address)
+	return nil
+}
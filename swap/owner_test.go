@@ -0,0 +1,224 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+)
+
+// newTestOwnerKeystore returns a keystore holding the owner key with the password
+func newTestOwnerKeystore(t *testing.T, password string) (*keystore.KeyStore, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "swap_owner_keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks := keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP)
+	if _, err := ks.ImportECDSA(ownerKey, password); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return ks, func() { os.RemoveAll(dir) }
+}
+
+// TestOwnerLocked tests that the owner key locked in the keystore
+// signs cheques only while it is unlocked with its password
+func TestOwnerLocked(t *testing.T) {
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	ks, cleanKeystore := newTestOwnerKeystore(t, "pw")
+	defer cleanKeystore()
+
+	owner, err := newOwner(ownerKey, ks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	swap.owner = owner
+	if err := swap.LockOwner(); err != nil {
+		t.Fatal(err)
+	}
+
+	status := swap.OwnerStatus()
+	if !status.Keystore || !status.Locked || status.Address != owner.address {
+		t.Fatalf("expected locked owner %x in the keystore, got %+v", owner.address, status)
+	}
+
+	cheque := newTestCheque()
+	if _, err := cheque.signBy(swap.owner); err != ErrOwnerLocked {
+		t.Fatalf("expected error %v, got %v", ErrOwnerLocked, err)
+	}
+	if _, err := swap.owner.transactor(); err != ErrOwnerLocked {
+		t.Fatalf("expected error %v, got %v", ErrOwnerLocked, err)
+	}
+
+	if err := swap.UnlockOwner("wrong", 0); err == nil {
+		t.Fatal("expected error unlocking with the wrong password")
+	}
+	if err := swap.UnlockOwner("pw", 0); err != nil {
+		t.Fatal(err)
+	}
+	if status := swap.OwnerStatus(); status.Locked || !status.UnlockedUntil.IsZero() {
+		t.Fatalf("expected owner unlocked until locked, got %+v", status)
+	}
+	if cheque.Signature, err = cheque.signBy(swap.owner); err != nil {
+		t.Fatal(err)
+	}
+	if err := cheque.VerifySig(owner.address); err != nil {
+		t.Fatal(err)
+	}
+	expected, err := cheque.Sign(ownerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(expected) != string(cheque.Signature) {
+		t.Fatal("expected the keystore signature to be the signature of the owner key")
+	}
+	if _, err := swap.owner.transactor(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := swap.LockOwner(); err != nil {
+		t.Fatal(err)
+	}
+	if !swap.OwnerStatus().Locked {
+		t.Fatal("expected owner locked again")
+	}
+}
+
+// TestOwnerTimedUnlock tests that the owner key is locked again after the unlock timeout
+func TestOwnerTimedUnlock(t *testing.T) {
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	ks, cleanKeystore := newTestOwnerKeystore(t, "pw")
+	defer cleanKeystore()
+
+	owner, err := newOwner(ownerKey, ks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	swap.owner = owner
+	if err := swap.LockOwner(); err != nil {
+		t.Fatal(err)
+	}
+	if err := swap.UnlockOwner("pw", 1); err != nil {
+		t.Fatal(err)
+	}
+	status := swap.OwnerStatus()
+	if status.Locked || status.UnlockedUntil.IsZero() {
+		t.Fatalf("expected owner unlocked for a limited time, got %+v", status)
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	if !swap.OwnerStatus().Locked {
+		t.Fatal("expected owner locked after the unlock timeout")
+	}
+	if _, err := newTestCheque().signBy(swap.owner); err != ErrOwnerLocked {
+		t.Fatalf("expected error %v, got %v", ErrOwnerLocked, err)
+	}
+}
+
+// TestOwnerChangePassword tests that the owner key is unlocked with its new password
+func TestOwnerChangePassword(t *testing.T) {
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	if err := swap.ChangeOwnerPassword("pw", "new"); err != ErrNoOwnerKeystore {
+		t.Fatalf("expected error %v, got %v", ErrNoOwnerKeystore, err)
+	}
+	ks, cleanKeystore := newTestOwnerKeystore(t, "pw")
+	defer cleanKeystore()
+
+	owner, err := newOwner(ownerKey, ks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	swap.owner = owner
+	if err := swap.LockOwner(); err != nil {
+		t.Fatal(err)
+	}
+	if err := swap.ChangeOwnerPassword("wrong", "new"); err == nil {
+		t.Fatal("expected error changing the password with the wrong password")
+	}
+	if err := swap.ChangeOwnerPassword("pw", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if err := swap.UnlockOwner("pw", 0); err == nil {
+		t.Fatal("expected error unlocking with the old password")
+	}
+	if err := swap.UnlockOwner("new", 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestOwnerLockedPaymentThreshold tests that no cheque is sent while the owner
+// key is locked and that it is sent once the key is unlocked
+func TestOwnerLockedPaymentThreshold(t *testing.T) {
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	testDeploy(context.Background(), swap, big.NewInt(0))
+	ks, cleanKeystore := newTestOwnerKeystore(t, "pw")
+	defer cleanKeystore()
+
+	owner, err := newOwner(ownerKey, ks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	swap.owner = owner
+	if err := swap.LockOwner(); err != nil {
+		t.Fatal(err)
+	}
+
+	testPeer := newDummyPeerWithSpec(Spec)
+	creditor, err := swap.addPeer(testPeer.Peer, swap.owner.address, swap.GetParams().ContractAddress, DefaultRetrievePricing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creditor.lock.Lock()
+	defer creditor.lock.Unlock()
+	if err := creditor.setBalance(-int64(DefaultPaymentThreshold)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := swap.checkPaymentThresholdAndSendCheque(creditor); err != nil {
+		t.Fatal(err)
+	}
+	if creditor.getPendingCheque() != nil {
+		t.Fatal("expected no cheque while the owner key is locked")
+	}
+	if creditor.getBalance() != -int64(DefaultPaymentThreshold) {
+		t.Fatalf("expected balance %d, got %d", -int64(DefaultPaymentThreshold), creditor.getBalance())
+	}
+
+	if err := swap.UnlockOwner("pw", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := swap.checkPaymentThresholdAndSendCheque(creditor); err != nil {
+		t.Fatal(err)
+	}
+	if creditor.getPendingCheque() == nil {
+		t.Fatal("expected a cheque once the owner key is unlocked")
+	}
+	if creditor.getBalance() != 0 {
+		t.Fatalf("expected balance 0, got %d", creditor.getBalance())
+	}
+}
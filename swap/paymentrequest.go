@@ -0,0 +1,126 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+const (
+	// DefaultPaymentRequestThreshold is the honey amount a peer owes at which
+	// it is asked to pay. It is above the payment threshold, so that debitors
+	// normally pay before they are asked to.
+	DefaultPaymentRequestThreshold = 2 * DefaultPaymentThreshold
+	// DefaultPaymentRequestInterval is the time after which an unanswered
+	// payment request is repeated, and within which a debitor honours only
+	// one payment request of a peer
+	DefaultPaymentRequestInterval = 10 * time.Second
+	// maxPaymentRequestBackoff is the longest time between unanswered payment requests
+	maxPaymentRequestBackoff = 5 * time.Minute
+)
+
+// validatePaymentRequest checks that debitors are asked to pay between the
+// payment and the disconnect thresholds
+func validatePaymentRequest(threshold, paymentThreshold, disconnectThreshold int64) error {
+	if threshold == 0 {
+		return nil
+	}
+	if threshold <= paymentThreshold || threshold >= disconnectThreshold {
+		return fmt.Errorf("payment request threshold %d must be higher than the payment threshold %d and lower than the disconnect threshold %d", threshold, paymentThreshold, disconnectThreshold)
+	}
+	return nil
+}
+
+// paymentRequestInterval returns the interval of payment requests
+func (s *Swap) paymentRequestInterval() time.Duration {
+	if s.params.PaymentRequestInterval > 0 {
+		return s.params.PaymentRequestInterval
+	}
+	return DefaultPaymentRequestInterval
+}
+
+// checkPaymentRequest asks the peer to pay if the balance from our point of
+// view passes the payment request threshold, in case the peer does not notice
+// its debt. Unanswered requests are repeated with an exponential backoff,
+// which is reset once the peer sends a cheque.
+// the caller is expected to hold p.lock
+func (s *Swap) checkPaymentRequest(p *Peer) error {
+	threshold := s.params.PaymentRequestThreshold
+	if threshold == 0 || p.legacy || p.getBalance() < threshold {
+		return nil
+	}
+	now := s.clock.Time()
+	if !p.paymentRequestedAt.IsZero() && now.Sub(p.paymentRequestedAt) < p.paymentRequestBackoff {
+		return nil
+	}
+	if p.paymentRequestBackoff == 0 {
+		p.paymentRequestBackoff = s.paymentRequestInterval()
+	} else if p.paymentRequestBackoff *= 2; p.paymentRequestBackoff > maxPaymentRequestBackoff {
+		p.paymentRequestBackoff = maxPaymentRequestBackoff
+	}
+	p.paymentRequestedAt = now
+
+	metrics.GetOrRegisterCounter("swap.paymentrequests.sent", nil).Inc(1)
+	p.logger.Info("balance for peer went over the payment request threshold, requesting payment", "balance", strconv.FormatInt(p.getBalance(), 10), "payment request threshold", threshold)
+	return p.Send(context.Background(), &PaymentRequestMsg{
+		Honey: uint64(p.getBalance()),
+	})
+}
+
+// resetPaymentRequests resets the backoff of payment requests once the peer paid
+// the caller is expected to hold p.lock
+func (p *Peer) resetPaymentRequests() {
+	p.paymentRequestedAt = time.Time{}
+	p.paymentRequestBackoff = 0
+}
+
+// handlePaymentRequestMsg is handled by the debitor when the creditor asks
+// it to pay. A cheque is sent if the debitor owes the peer more than the
+// grace allowance from its point of view, or the pending cheque is resent.
+// Only one request of a peer is honoured per payment request interval, so
+// that a peer can not make the debitor emit a stream of small cheques.
+func (s *Swap) handlePaymentRequestMsg(ctx context.Context, p *Peer, msg *PaymentRequestMsg) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := s.clock.Time()
+	if !p.paymentRequestHonouredAt.IsZero() && now.Sub(p.paymentRequestHonouredAt) < s.paymentRequestInterval() {
+		metrics.GetOrRegisterCounter("swap.paymentrequests.ignored", nil).Inc(1)
+		p.logger.Debug("ignoring payment request, peer asked again within the interval", "honey", msg.Honey)
+		return nil
+	}
+
+	if err := p.decayBalance(); err != nil {
+		return err
+	}
+	balance := p.getBalance()
+	if p.getPendingCheque() == nil && (balance >= 0 || uint64(-balance) <= p.grace.Allowance) {
+		metrics.GetOrRegisterCounter("swap.paymentrequests.unfounded", nil).Inc(1)
+		p.logger.Warn("ignoring payment request, no debt with peer", "honey", msg.Honey, "balance", strconv.FormatInt(balance, 10))
+		return nil
+	}
+	p.paymentRequestHonouredAt = now
+
+	metrics.GetOrRegisterCounter("swap.paymentrequests.honoured", nil).Inc(1)
+	p.logger.Info("peer requested payment, sending cheque", "honey", msg.Honey, "balance", strconv.FormatInt(balance, 10))
+	return p.sendCheque()
+}
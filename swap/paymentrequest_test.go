@@ -0,0 +1,209 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethersphere/swarm/network"
+	p2ptest "github.com/ethersphere/swarm/p2p/testing"
+)
+
+// TestPaymentRequestBackoff tests that unanswered payment requests
+// are repeated with an exponential backoff, reset by a cheque
+func TestPaymentRequestBackoff(t *testing.T) {
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	testDeploy(context.Background(), swap, big.NewInt(0))
+	testPeer := newDummyPeerWithSpec(Spec)
+	debitor, err := swap.addPeer(testPeer.Peer, swap.owner.address, swap.GetParams().ContractAddress, DefaultRetrievePricing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim := new(mclock.Simulated)
+	swap.clock = network.NewClock(sim, time.Now())
+	swap.params.PaymentRequestThreshold = int64(DefaultPaymentRequestThreshold)
+
+	debitor.lock.Lock()
+	defer debitor.lock.Unlock()
+	requested := func() bool {
+		t.Helper()
+		before := debitor.paymentRequestedAt
+		if err := swap.checkPaymentRequest(debitor); err != nil {
+			t.Fatal(err)
+		}
+		return debitor.paymentRequestedAt != before
+	}
+
+	if err := debitor.setBalance(int64(DefaultPaymentRequestThreshold) - 1); err != nil {
+		t.Fatal(err)
+	}
+	if requested() {
+		t.Fatal("expected no payment request below the threshold")
+	}
+	if err := debitor.setBalance(int64(DefaultPaymentRequestThreshold)); err != nil {
+		t.Fatal(err)
+	}
+	if !requested() {
+		t.Fatal("expected a payment request at the threshold")
+	}
+	for _, backoff := range []time.Duration{DefaultPaymentRequestInterval, 2 * DefaultPaymentRequestInterval, 4 * DefaultPaymentRequestInterval} {
+		if debitor.paymentRequestBackoff != backoff {
+			t.Fatalf("expected a backoff of %v, got %v", backoff, debitor.paymentRequestBackoff)
+		}
+		sim.Run(backoff - time.Second)
+		if requested() {
+			t.Fatal("expected no payment request within the backoff")
+		}
+		sim.Run(time.Second)
+		if !requested() {
+			t.Fatal("expected a payment request after the backoff")
+		}
+	}
+
+	debitor.resetPaymentRequests()
+	if !requested() {
+		t.Fatal("expected a payment request after the peer paid")
+	}
+	if debitor.paymentRequestBackoff != DefaultPaymentRequestInterval {
+		t.Fatalf("expected the backoff to be reset, got %v", debitor.paymentRequestBackoff)
+	}
+}
+
+// TestSetThresholdsPaymentRequest tests that thresholds which no longer
+// enclose the payment request threshold are rejected
+func TestSetThresholdsPaymentRequest(t *testing.T) {
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	swap.params.PaymentRequestThreshold = 1500
+
+	if err := swap.SetThresholds(1500, 2000); err == nil {
+		t.Fatal("expected a payment threshold at the payment request threshold to be rejected")
+	}
+	if err := swap.SetThresholds(1000, 1500); err == nil {
+		t.Fatal("expected a disconnect threshold at the payment request threshold to be rejected")
+	}
+	if err := swap.SetThresholds(1000, 2000); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestHandlePaymentRequest tests that the debitor pays on a payment request
+// only if it owes the peer and only once per interval
+func TestHandlePaymentRequest(t *testing.T) {
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	testDeploy(context.Background(), swap, big.NewInt(int64(DefaultPaymentThreshold)*2))
+	testPeer := newDummyPeerWithSpec(Spec)
+	creditor, err := swap.addPeer(testPeer.Peer, swap.owner.address, swap.GetParams().ContractAddress, DefaultRetrievePricing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim := new(mclock.Simulated)
+	swap.clock = network.NewClock(sim, time.Now())
+
+	request := func() {
+		t.Helper()
+		if err := swap.handlePaymentRequestMsg(context.Background(), creditor, &PaymentRequestMsg{Honey: 100}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a peer we do not owe is not paid
+	request()
+	if creditor.getPendingCheque() != nil {
+		t.Fatal("expected no cheque without debt")
+	}
+
+	setBalance(t, creditor, -100)
+	request()
+	cheque := creditor.getPendingCheque()
+	if cheque == nil {
+		t.Fatal("expected a cheque on the payment request")
+	}
+	if cheque.Honey != 100 {
+		t.Fatalf("expected a cheque of 100 honey, got %d", cheque.Honey)
+	}
+	if creditor.getBalance() != 0 {
+		t.Fatalf("expected balance 0 after paying, got %d", creditor.getBalance())
+	}
+
+	// the cheque is confirmed and the peer asks again right away
+	creditor.lock.Lock()
+	creditor.setLastSentCheque(cheque)
+	creditor.setPendingCheque(nil)
+	creditor.lock.Unlock()
+	setBalance(t, creditor, -100)
+	request()
+	if creditor.getPendingCheque() != nil {
+		t.Fatal("expected the payment request within the interval to be ignored")
+	}
+	sim.Run(DefaultPaymentRequestInterval)
+	request()
+	if creditor.getPendingCheque() == nil {
+		t.Fatal("expected a cheque on the payment request after the interval")
+	}
+}
+
+// TestPaymentRequestMsg tests that the creditor sends a payment request
+// when the debt of the peer passes the payment request threshold
+func TestPaymentRequestMsg(t *testing.T) {
+	protocolTester, clean, err := newSwapTester(t, nil, big.NewInt(0))
+	defer clean()
+	if err != nil {
+		t.Fatal(err)
+	}
+	creditorSwap := protocolTester.swap
+	creditorSwap.params.PaymentRequestThreshold = int64(DefaultPaymentRequestThreshold)
+
+	if err = protocolTester.testHandshake(
+		correctSwapHandshakeMsg(creditorSwap),
+		correctSwapHandshakeMsg(creditorSwap),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	debitor := creditorSwap.getPeer(protocolTester.Nodes[0].ID())
+	setBalance(t, debitor, int64(DefaultPaymentRequestThreshold)-1)
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- creditorSwap.Add(1, debitor.Peer)
+	}()
+	err = protocolTester.TestExchanges(p2ptest.Exchange{
+		Label: "payment request",
+		Expects: []p2ptest.Expect{
+			{
+				Code: 3,
+				Msg: &PaymentRequestMsg{
+					Honey: DefaultPaymentRequestThreshold,
+				},
+				Peer: protocolTester.Nodes[0].ID(),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errC; err != nil {
+		t.Fatal(err)
+	}
+}
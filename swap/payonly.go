@@ -0,0 +1,44 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"errors"
+)
+
+var (
+	// ErrPayOnly is returned when a peer would owe a pay-only node, which does not extend credit
+	ErrPayOnly = errors.New("pay-only node does not extend credit")
+	// ErrPeerPayOnly is returned when we would owe a pay-only peer, which does not extend credit
+	ErrPeerPayOnly = errors.New("pay-only peer does not extend credit")
+)
+
+// checkCredit checks that adding the amount to the balance does not make
+// a pay-only node a creditor. A pay-only node pays for the services it uses
+// with cheques but has no chequebook to receive into, so its peers must
+// never owe it. Amounts settling existing debt are always accounted.
+// the caller is expected to hold p.lock
+func (p *Peer) checkCredit(amount int64) error {
+	balance := p.getBalance()
+	if p.swap.params.PayOnly && amount > 0 && balance+amount > 0 {
+		return ErrPayOnly
+	}
+	if p.payOnly && amount < 0 && balance+amount < 0 {
+		return ErrPeerPayOnly
+	}
+	return nil
+}
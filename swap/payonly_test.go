@@ -0,0 +1,113 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"context"
+	"math/big"
+	"testing"
+)
+
+// TestPayOnly tests that a pay-only node accounts the services it uses
+// and the services it provides up to its debt, but never lets a peer owe it
+func TestPayOnly(t *testing.T) {
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	testDeploy(context.Background(), swap, big.NewInt(0))
+	swap.params.PayOnly = true
+	testPeer := newDummyPeer()
+	peer, err := swap.addPeer(testPeer.Peer, swap.owner.address, swap.GetParams().ContractAddress, DefaultRetrievePricing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := swap.Add(1, testPeer.Peer); err != ErrPayOnly {
+		t.Fatalf("expected %v for credit to the peer, got %v", ErrPayOnly, err)
+	}
+	if err := swap.Add(-100, testPeer.Peer); err != nil {
+		t.Fatal(err)
+	}
+	if err := swap.Add(100, testPeer.Peer); err != nil {
+		t.Fatalf("expected services settling our debt to be accounted, got %v", err)
+	}
+	if err := swap.Add(1, testPeer.Peer); err != ErrPayOnly {
+		t.Fatalf("expected %v for credit to the peer, got %v", ErrPayOnly, err)
+	}
+	if balance := peer.getBalance(); balance != 0 {
+		t.Fatalf("expected balance 0, got %d", balance)
+	}
+}
+
+// TestPeerPayOnly tests that services of a pay-only peer are used
+// only while the peer owes us
+func TestPeerPayOnly(t *testing.T) {
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	testDeploy(context.Background(), swap, big.NewInt(0))
+	testPeer := newDummyPeer()
+	peer, err := swap.addPeer(testPeer.Peer, swap.owner.address, swap.GetParams().ContractAddress, DefaultRetrievePricing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer.payOnly = true
+
+	if err := swap.Add(-1, testPeer.Peer); err != ErrPeerPayOnly {
+		t.Fatalf("expected %v for credit from the peer, got %v", ErrPeerPayOnly, err)
+	}
+	if err := swap.Add(100, testPeer.Peer); err != nil {
+		t.Fatal(err)
+	}
+	if err := swap.Add(-60, testPeer.Peer); err != nil {
+		t.Fatalf("expected services settling the debt of the peer to be accounted, got %v", err)
+	}
+	if err := swap.Add(-60, testPeer.Peer); err != ErrPeerPayOnly {
+		t.Fatalf("expected %v for credit from the peer, got %v", ErrPeerPayOnly, err)
+	}
+	if balance := peer.getBalance(); balance != 40 {
+		t.Fatalf("expected balance 40, got %d", balance)
+	}
+}
+
+// TestHandshakePayOnly tests that a peer advertising the pay-only mode
+// in the handshake is marked as pay-only
+func TestHandshakePayOnly(t *testing.T) {
+	protocolTester, clean, err := newSwapTester(t, nil, big.NewInt(0))
+	defer clean()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := correctSwapHandshakeMsg(protocolTester.swap)
+	msg.PayOnly = true
+	err = protocolTester.testHandshake(
+		correctSwapHandshakeMsg(protocolTester.swap),
+		msg,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peer := protocolTester.swap.getPeer(protocolTester.Nodes[0].ID())
+	if peer == nil {
+		t.Fatal("expected the peer to be added")
+	}
+	peer.lock.RLock()
+	defer peer.lock.RUnlock()
+	if !peer.payOnly {
+		t.Fatal("expected the peer to be pay-only")
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/log"
@@ -35,46 +36,70 @@ var ErrDontOwe = errors.New("no negative balance")
 // Peer is a devp2p peer for the Swap protocol
 type Peer struct {
 	*protocols.Peer
-	lock               sync.RWMutex
-	swap               *Swap
-	beneficiary        common.Address // address of the peers chequebook owner
-	contractAddress    common.Address // address of the peers chequebook
-	lastReceivedCheque *Cheque        // last cheque we received from the peer
-	lastSentCheque     *Cheque        // last cheque that was sent to peer that was confirmed
-	pendingCheque      *Cheque        // last cheque that was sent to peer but is not yet confirmed
-	balance            int64          // current balance of the peer
-	logger             log.Logger     // logger for swap related messages and audit trail with peer identifier
+	lock                     sync.RWMutex
+	swap                     *Swap
+	asset                    Asset              // settlement asset agreed with the peer, empty if the peer is settlement-free
+	beneficiary              common.Address     // address of the peers chequebook owner
+	contractAddress          common.Address     // address of the peers chequebook
+	lastReceivedCheque       *Cheque            // last cheque we received from the peer
+	lastSentCheque           *Cheque            // last cheque that was sent to peer that was confirmed
+	pendingCheque            *Cheque            // last cheque that was sent to peer but is not yet confirmed
+	balance                  int64              // current balance of the peer
+	decayedAt                time.Time          // time the grace decay was last applied to the balance
+	retrievePricing          RetrievePricing    // retrieve request pricing announced by the peer
+	debts                    map[debtKey]uint64 // debt accrued since the last emitted cheque
+	paymentRequestedAt       time.Time          // time we last asked the peer to pay
+	paymentRequestBackoff    time.Duration      // time before we ask the peer to pay again
+	paymentRequestHonouredAt time.Time          // time we last paid the peer on its request
+	debtCrossing             debtCrossing       // when the debt with the peer crossed the interest debt level
+	interestTerms            InterestTerms      // interest the peer charges on debts owed to it
+	grace                    GraceTerms         // grace terms agreed with the peer, balances do not decay if zero
+	payOnly                  bool               // the peer pays for services but extends no credit
+	legacy                   bool               // the peer speaks the first version of the protocol, which has no payment requests
+	logger                   log.Logger         // logger for swap related messages and audit trail with peer identifier
 }
 
-// NewPeer creates a new swap Peer instance
-func NewPeer(p *protocols.Peer, s *Swap, beneficiary common.Address, contractAddress common.Address) (peer *Peer, err error) {
+// NewPeer creates a new swap Peer instance settling in the asset,
+// its balance and cheques are loaded for the asset
+func NewPeer(p *protocols.Peer, s *Swap, asset Asset, beneficiary common.Address, contractAddress common.Address) (peer *Peer, err error) {
 	peer = &Peer{
 		Peer:            p,
 		swap:            s,
+		asset:           asset,
 		beneficiary:     beneficiary,
 		contractAddress: contractAddress,
 		logger:          newPeerLogger(s, p.ID()),
+		decayedAt:       s.clock.Time(),
 	}
 
-	if peer.lastReceivedCheque, err = s.loadLastReceivedCheque(p.ID()); err != nil {
+	if peer.lastReceivedCheque, err = s.loadCheque(peer.key(receivedChequeKey(p.ID()))); err != nil {
 		return nil, err
 	}
 
-	if peer.lastSentCheque, err = s.loadLastSentCheque(p.ID()); err != nil {
+	if peer.lastSentCheque, err = s.loadCheque(peer.key(sentChequeKey(p.ID()))); err != nil {
 		return nil, err
 	}
 
-	if peer.balance, err = s.loadBalance(p.ID()); err != nil {
+	if peer.balance, err = s.loadBalanceAt(peer.key(balanceKey(p.ID()))); err != nil {
 		return nil, err
 	}
 
-	if peer.pendingCheque, err = s.loadPendingCheque(p.ID()); err != nil {
+	if peer.pendingCheque, err = s.loadCheque(peer.key(pendingChequeKey(p.ID()))); err != nil {
+		return nil, err
+	}
+
+	if peer.debtCrossing, err = s.loadDebtCrossing(peer.key(interestKey(p.ID()))); err != nil {
 		return nil, err
 	}
 
 	return peer, nil
 }
 
+// key returns the store key of the balance or a cheque in the settlement asset of the peer
+func (p *Peer) key(key string) string {
+	return assetKey(key, p.asset)
+}
+
 // getLastReceivedCheque returns the last cheque we received for this peer
 // the caller is expected to hold p.lock
 func (p *Peer) getLastReceivedCheque() *Cheque {
@@ -97,21 +122,21 @@ func (p *Peer) getPendingCheque() *Cheque {
 // the caller is expected to hold p.lock
 func (p *Peer) setLastReceivedCheque(cheque *Cheque) error {
 	p.lastReceivedCheque = cheque
-	return p.swap.saveLastReceivedCheque(p.ID(), cheque)
+	return p.swap.store.Put(p.key(receivedChequeKey(p.ID())), cheque)
 }
 
 // setLastReceivedCheque sets the given cheque as the last sent cheque for this peer
 // the caller is expected to hold p.lock
 func (p *Peer) setLastSentCheque(cheque *Cheque) error {
 	p.lastSentCheque = cheque
-	return p.swap.saveLastSentCheque(p.ID(), cheque)
+	return p.swap.store.Put(p.key(sentChequeKey(p.ID())), cheque)
 }
 
 // setLastReceivedCheque sets the given cheque as the pending cheque for this peer
 // the caller is expected to hold p.lock
 func (p *Peer) setPendingCheque(cheque *Cheque) error {
 	p.pendingCheque = cheque
-	return p.swap.savePendingCheque(p.ID(), cheque)
+	return p.swap.store.Put(p.key(pendingChequeKey(p.ID())), cheque)
 }
 
 // getLastSentCumulativePayout returns the cumulative payout of the last sent cheque or 0 if there is none
@@ -124,10 +149,18 @@ func (p *Peer) getLastSentCumulativePayout() uint64 {
 	return 0
 }
 
+// setBalance persists the balance before updating it in memory,
+// so that the balance does not change if it can not be restored
+// after a restart
 // the caller is expected to hold p.lock
 func (p *Peer) setBalance(balance int64) error {
+	if err := p.swap.store.Put(p.key(balanceKey(p.ID())), balance); err != nil {
+		return err
+	}
 	p.balance = balance
-	return p.swap.saveBalance(p.ID(), balance)
+	// the grace decay restarts with every balance change
+	p.decayedAt = p.swap.clock.Time()
+	return nil
 }
 
 // getBalance returns the current balance for this peer
@@ -136,8 +169,14 @@ func (p *Peer) getBalance() int64 {
 	return p.balance
 }
 
+// updateBalance adds the amount to the balance and records the mutation
+// with its reason and the service causing it in the audit log
 // the caller is expected to hold p.lock
-func (p *Peer) updateBalance(amount int64) error {
+func (p *Peer) updateBalance(amount int64, reason, service string) error {
+	// the decay until now applies to the balance before the change
+	if err := p.decayBalance(); err != nil {
+		return err
+	}
 	//adjust the balance
 	//if amount is negative, it will decrease, otherwise increase
 	newBalance := p.getBalance() + amount
@@ -145,6 +184,7 @@ func (p *Peer) updateBalance(amount int64) error {
 		return err
 	}
 	p.logger.Debug("updated balance", "balance", strconv.FormatInt(newBalance, 10))
+	p.auditBalance(amount, reason, service)
 	return nil
 }
 
@@ -162,7 +202,7 @@ func (p *Peer) createCheque() (*Cheque, error) {
 	// the balance should be negative here, we take the absolute value:
 	honey := uint64(-p.getBalance())
 
-	amount, err := p.swap.honeyPriceOracle.GetPrice(honey)
+	amount, err := p.swap.priceOracle(p.asset).GetPrice(honey)
 	if err != nil {
 		return nil, fmt.Errorf("error getting price from oracle: %v", err)
 	}
@@ -172,12 +212,12 @@ func (p *Peer) createCheque() (*Cheque, error) {
 	cheque = &Cheque{
 		ChequeParams: ChequeParams{
 			CumulativePayout: total + amount,
-			Contract:         p.swap.GetParams().ContractAddress,
+			Contract:         p.swap.chequebook(p.asset),
 			Beneficiary:      p.beneficiary,
 		},
 		Honey: honey,
 	}
-	cheque.Signature, err = cheque.Sign(p.swap.owner.privateKey)
+	cheque.Signature, err = cheque.signBy(p.swap.owner)
 
 	return cheque, err
 }
@@ -194,6 +234,9 @@ func (p *Peer) sendCheque() error {
 		})
 	}
 	cheque, err := p.createCheque()
+	if err == ErrOwnerLocked {
+		return err
+	}
 	if err != nil {
 		return fmt.Errorf("error while creating cheque: %v", err)
 	}
@@ -204,11 +247,18 @@ func (p *Peer) sendCheque() error {
 	}
 
 	honeyAmount := int64(cheque.Honey)
-	err = p.updateBalance(honeyAmount)
+	err = p.updateBalance(honeyAmount, auditChequeSent, "")
 	if err != nil {
 		return fmt.Errorf("error while creating cheque: %v", err)
 	}
 
+	if err := p.addChequeStats(cheque); err != nil {
+		p.logger.Warn("error while saving cheque statistics", "err", err)
+	}
+	if err := p.addChequeRecord(ChequeSent, cheque, cheque.CumulativePayout-p.getLastSentCumulativePayout()); err != nil {
+		p.logger.Warn("error while saving cheque record", "err", err)
+	}
+
 	metrics.GetOrRegisterCounter("swap.cheques.emitted.num", nil).Inc(1)
 	metrics.GetOrRegisterCounter("swap.cheques.emitted.honey", nil).Inc(honeyAmount)
 
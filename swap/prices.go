@@ -32,7 +32,7 @@ but it could potentially be any currency the oracle and Swarm support,
 allowing for a multi-currency design.
 */
 
-//TODO: this calculations make little sense now, after update to ERC20-enabled chequebook
+// TODO: this calculations make little sense now, after update to ERC20-enabled chequebook
 // Placeholder prices
 // Based on a very crude calculation: average monthly cost for bandwidth in the US / average monthly usage of bandwidth in the US
 // $67 / 190GB = $0.35 / GB
@@ -41,9 +41,56 @@ allowing for a multi-currency design.
 // per byte of data transferred, we account for 1 chunkDelivery price (accounted per byte), and 1/4096 retrieveRequest (accounted per message)
 // RetrieveRequestPrice = 0.1 * 19636319 * 4096 = 8043036262, where 0.1 is a bogus factor
 // ChunkDeliveryPrice = 0.9 * 19636319 = 17672687, where 0.9 is a bogus factor
+// ChunkDeliveryMinPrice charges deliveries of less than 512 bytes as 512 bytes for the message overhead
 const (
-	RetrieveRequestPrice = uint64(8043036262)
-	ChunkDeliveryPrice   = uint64(17672687)
+	RetrieveRequestPrice  = uint64(8043036262)
+	ChunkDeliveryPrice    = uint64(17672687)
+	ChunkDeliveryMinPrice = 512 * ChunkDeliveryPrice
+	// MaxRetrievePrice is the highest base price of a retrieve request
+	// a peer may announce, so that a single request can not overflow
+	// a balance or run up a large debt
+	MaxRetrievePrice = 64 * RetrieveRequestPrice
 	// default conversion of honey into output currency - currently ETH in Wei
 	defaultHoneyPrice = uint64(1)
 )
+
+// RetrievePricing defines the price of a retrieve request by the proximity
+// order between the requested chunk and the node serving it. Closer nodes
+// need fewer hops to serve a chunk and are cheaper: the price is Base reduced
+// by Discount for every proximity order, but never lower than Min.
+// Nodes announce their pricing in the swap handshake and every request
+// is charged by the pricing of the node serving it.
+type RetrievePricing struct {
+	Base     uint64 // price of a chunk at proximity order 0
+	Discount uint64 // price reduction for every proximity order
+	Min      uint64 // lowest price
+}
+
+// DefaultRetrievePricing charges RetrieveRequestPrice for the most distant
+// chunks, down to a quarter of it for proximity orders greater than 12.
+var DefaultRetrievePricing = RetrievePricing{
+	Base:     RetrieveRequestPrice,
+	Discount: RetrieveRequestPrice / 16,
+	Min:      RetrieveRequestPrice / 4,
+}
+
+// Price returns the price of a retrieve request for a chunk
+// at proximity order po to the serving node
+func (p RetrievePricing) Price(po int) uint64 {
+	if po <= 0 || p.Discount == 0 || p.Min >= p.Base {
+		return p.Base
+	}
+	if uint64(po) > (p.Base-p.Min)/p.Discount {
+		return p.Min
+	}
+	return p.Base - uint64(po)*p.Discount
+}
+
+// validate checks that prices do not increase with proximity
+// and do not exceed MaxRetrievePrice
+func (p RetrievePricing) validate() error {
+	if p.Min > p.Base || p.Base > MaxRetrievePrice {
+		return ErrInvalidRetrievePricing
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import "testing"
+
+// TestRetrievePricing tests that retrieve request prices decrease
+// with proximity and do not go below the minimal price
+func TestRetrievePricing(t *testing.T) {
+	pricing := RetrievePricing{
+		Base:     1000,
+		Discount: 150,
+		Min:      300,
+	}
+	for _, tc := range []struct {
+		po    int
+		price uint64
+	}{
+		{po: 0, price: 1000},
+		{po: 1, price: 850},
+		{po: 4, price: 400},
+		{po: 5, price: 300},
+		{po: 6, price: 300},
+		{po: 255, price: 300},
+	} {
+		if got := pricing.Price(tc.po); got != tc.price {
+			t.Errorf("po %v: got price %v, want %v", tc.po, got, tc.price)
+		}
+	}
+
+	// a huge discount does not overflow
+	pricing.Discount = 1 << 63
+	if got := pricing.Price(8); got != pricing.Min {
+		t.Errorf("got price %v, want %v", got, pricing.Min)
+	}
+
+	// constant price
+	pricing = RetrievePricing{Base: 1000}
+	if got := pricing.Price(10); got != 1000 {
+		t.Errorf("got price %v, want %v", got, 1000)
+	}
+
+	if err := (RetrievePricing{Base: 100, Min: 200}).validate(); err != ErrInvalidRetrievePricing {
+		t.Errorf("got error %v, want %v", err, ErrInvalidRetrievePricing)
+	}
+	if err := (RetrievePricing{Base: MaxRetrievePrice + 1}).validate(); err != ErrInvalidRetrievePricing {
+		t.Errorf("got error %v, want %v", err, ErrInvalidRetrievePricing)
+	}
+	if err := DefaultRetrievePricing.validate(); err != nil {
+		t.Error(err)
+	}
+	if got := DefaultRetrievePricing.Price(13); got != DefaultRetrievePricing.Min {
+		t.Errorf("got default price %v at po 13, want %v", got, DefaultRetrievePricing.Min)
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/enode"
 
 	"github.com/ethereum/go-ethereum/p2p"
+	contract "github.com/ethersphere/swarm/contracts/swap"
 	"github.com/ethersphere/swarm/p2p/protocols"
 )
 
@@ -39,20 +40,54 @@ var (
 	// structure of the HandshakeMsg
 	ErrInvalidHandshakeMsg = errors.New("invalid handshake message")
 
+	// ErrInvalidRetrievePricing is used when the retrieve request pricing
+	// received during handshake has a minimal price higher than the base price
+	ErrInvalidRetrievePricing = errors.New("invalid retrieve pricing")
+
+	// ErrInvalidInterestTerms is used when the interest terms received
+	// during handshake are incomplete or exceed the maximum interest rate
+	ErrInvalidInterestTerms = errors.New("invalid interest terms")
+
+	// ErrInvalidAssetChequebook is used when a chequebook offered in the handshake
+	// does not pay cheques in the settlement asset it is offered for
+	ErrInvalidAssetChequebook = errors.New("invalid asset chequebook")
+
 	// Spec is the swap protocol specification
 	Spec = &protocols.Spec{
 		Name:       "swap",
-		Version:    1,
+		Version:    6,
 		MaxMsgSize: 10 * 1024 * 1024,
 		Messages: []interface{}{
 			HandshakeMsg{},
 			EmitChequeMsg{},
 			ConfirmChequeMsg{},
+			PaymentRequestMsg{},
+		},
+	}
+
+	// legacySpec is the specification of the first version of the swap protocol,
+	// still served to peers which do not support the current version.
+	// Its handshake only has the chain id and the ETH chequebook, and
+	// it has no payment requests.
+	legacySpec = &protocols.Spec{
+		Name:       "swap",
+		Version:    1,
+		MaxMsgSize: 10 * 1024 * 1024,
+		Messages: []interface{}{
+			legacyHandshakeMsg{},
+			EmitChequeMsg{},
+			ConfirmChequeMsg{},
 		},
 	}
 )
 
+// legacyRetrievePricing is the flat retrieve request pricing of peers
+// speaking the first version of the swap protocol
+var legacyRetrievePricing = RetrievePricing{Base: RetrieveRequestPrice}
+
 // Protocols is a node.Service interface method
+// Peers select the highest version both support, so that peers running
+// the first version of the protocol still settle with the node in ETH.
 func (s *Swap) Protocols() []p2p.Protocol {
 	return []p2p.Protocol{
 		{
@@ -61,6 +96,12 @@ func (s *Swap) Protocols() []p2p.Protocol {
 			Length:  Spec.Length(),
 			Run:     s.run,
 		},
+		{
+			Name:    legacySpec.Name,
+			Version: legacySpec.Version,
+			Length:  legacySpec.Length(),
+			Run:     s.runLegacy,
+		},
 	}
 }
 
@@ -91,16 +132,87 @@ func (s *Swap) verifyHandshake(msg interface{}) error {
 		return ErrDifferentChainID
 	}
 
-	return s.chequebookFactory.VerifyContract(handshake.ContractAddress)
+	if err := handshake.RetrievePricing.validate(); err != nil {
+		return err
+	}
+
+	if err := handshake.InterestTerms.validate(); err != nil {
+		return err
+	}
+
+	if err := s.chequebookFactory.VerifyContract(handshake.ContractAddress); err != nil {
+		return err
+	}
+
+	for _, a := range handshake.Assets {
+		if err := s.verifyAssetChequebook(a, handshake.ContractAddress); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyAssetChequebook verifies that a chequebook offered in the handshake
+// pays cheques in its asset. The ETH chequebook must be the verified
+// chequebook of the handshake, token chequebooks must be deployed by the
+// chequebook factory and hold the token.
+func (s *Swap) verifyAssetChequebook(a AssetChequebook, ethChequebook common.Address) error {
+	if (a.Contract == common.Address{}) {
+		return ErrEmptyAddressInSignature
+	}
+	if a.Asset == AssetETH {
+		if a.Contract != ethChequebook {
+			return ErrInvalidAssetChequebook
+		}
+		return nil
+	}
+	if !common.IsHexAddress(string(a.Asset)) {
+		return ErrInvalidAssetChequebook
+	}
+	if err := s.chequebookFactory.VerifyContract(a.Contract); err != nil {
+		return err
+	}
+	chequebook, err := contract.InstanceAt(a.Contract, s.backend)
+	if err != nil {
+		return err
+	}
+	token, err := chequebook.Token(nil)
+	if err != nil {
+		return err
+	}
+	if token != common.HexToAddress(string(a.Asset)) {
+		return ErrInvalidAssetChequebook
+	}
+	return nil
+}
+
+// verifyLegacyHandshake verifies the handshake of a peer speaking the first version of the protocol
+func (s *Swap) verifyLegacyHandshake(msg interface{}) error {
+	handshake, ok := msg.(*legacyHandshakeMsg)
+	if !ok {
+		return ErrInvalidHandshakeMsg
+	}
+	return s.verifyHandshake(handshake.upgrade())
 }
 
 // run is the actual swap protocol run method
 func (s *Swap) run(p *p2p.Peer, rw p2p.MsgReadWriter) error {
 	protoPeer := protocols.NewPeer(p, rw, Spec)
 
+	accounts, err := s.accountStates(p.ID())
+	if err != nil {
+		return err
+	}
+
 	handshake, err := protoPeer.Handshake(context.Background(), &HandshakeMsg{
 		ContractAddress: s.GetParams().ContractAddress,
 		ChainID:         s.chainID,
+		RetrievePricing: s.params.RetrievePricing,
+		Assets:          s.assetChequebooks(),
+		Accounts:        accounts,
+		PayOnly:         s.params.PayOnly,
+		InterestTerms:   s.interestTerms(),
+		GraceTerms:      s.graceTerms(),
 	}, s.verifyHandshake)
 	if err != nil {
 		return err
@@ -111,16 +223,70 @@ func (s *Swap) run(p *p2p.Peer, rw p2p.MsgReadWriter) error {
 		return ErrInvalidHandshakeMsg
 	}
 
-	beneficiary, err := s.getContractOwner(context.Background(), response.ContractAddress)
+	return s.runPeer(protoPeer, response, false)
+}
+
+// runLegacy runs the first version of the swap protocol, in which
+// peers settle in ETH at the flat legacy retrieve request price
+func (s *Swap) runLegacy(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+	protoPeer := protocols.NewPeer(p, rw, legacySpec)
+
+	handshake, err := protoPeer.Handshake(context.Background(), &legacyHandshakeMsg{
+		ContractAddress: s.GetParams().ContractAddress,
+		ChainID:         s.chainID,
+	}, s.verifyLegacyHandshake)
 	if err != nil {
 		return err
 	}
 
-	swapPeer, err := s.addPeer(protoPeer, beneficiary, response.ContractAddress)
+	response, ok := handshake.(*legacyHandshakeMsg)
+	if !ok {
+		return ErrInvalidHandshakeMsg
+	}
+
+	return s.runPeer(protoPeer, response.upgrade(), true)
+}
+
+// runPeer accounts with a peer after the handshake until it disconnects
+func (s *Swap) runPeer(protoPeer *protocols.Peer, response *HandshakeMsg, legacy bool) error {
+	var err error
+	// peers without a common settlement asset do not account with each other
+	var beneficiary common.Address
+	agreed, ok := agreeAsset(s.assetChequebooks(), response.Assets)
+	if ok {
+		if beneficiary, err = s.getContractOwner(context.Background(), agreed.Contract); err != nil {
+			return err
+		}
+	} else {
+		log.Info("no common settlement asset with peer, not settling", "peer", protoPeer.ID())
+	}
+
+	swapPeer, err := s.addAssetPeer(protoPeer, agreed.Asset, beneficiary, agreed.Contract, response.RetrievePricing)
 	if err != nil {
 		return err
 	}
 	defer s.removePeer(swapPeer)
+	swapPeer.lock.Lock()
+	swapPeer.legacy = legacy
+	swapPeer.interestTerms = response.InterestTerms
+	swapPeer.grace = agreeGrace(s.graceTerms(), response.GraceTerms)
+	swapPeer.lock.Unlock()
+	if response.PayOnly {
+		swapPeer.lock.Lock()
+		swapPeer.payOnly = true
+		swapPeer.lock.Unlock()
+		swapPeer.logger.Info("peer is pay-only, not using its services on credit")
+	}
+
+	// converge the account with what the peer knows, which may differ if either crashed
+	if theirs, ok := accountState(response.Accounts, swapPeer.asset); ok && swapPeer.asset != "" {
+		swapPeer.lock.Lock()
+		err = swapPeer.reconcile(theirs)
+		swapPeer.lock.Unlock()
+		if err != nil {
+			return err
+		}
+	}
 
 	return swapPeer.Run(s.handleMsg(swapPeer))
 }
@@ -131,13 +297,20 @@ func (s *Swap) removePeer(p *Peer) {
 	delete(s.peers, p.ID())
 }
 
-func (s *Swap) addPeer(protoPeer *protocols.Peer, beneficiary common.Address, contractAddress common.Address) (*Peer, error) {
+// addPeer adds a peer settling in ETH
+func (s *Swap) addPeer(protoPeer *protocols.Peer, beneficiary common.Address, contractAddress common.Address, retrievePricing RetrievePricing) (*Peer, error) {
+	return s.addAssetPeer(protoPeer, AssetETH, beneficiary, contractAddress, retrievePricing)
+}
+
+// addAssetPeer adds a peer settling in the asset, or a settlement-free peer if the asset is empty
+func (s *Swap) addAssetPeer(protoPeer *protocols.Peer, asset Asset, beneficiary common.Address, contractAddress common.Address, retrievePricing RetrievePricing) (*Peer, error) {
 	s.peersLock.Lock()
 	defer s.peersLock.Unlock()
-	p, err := NewPeer(protoPeer, s, beneficiary, contractAddress)
+	p, err := NewPeer(protoPeer, s, asset, beneficiary, contractAddress)
 	if err != nil {
 		return nil, err
 	}
+	p.retrievePricing = retrievePricing
 	s.peers[p.ID()] = p
 	return p, nil
 }
@@ -148,3 +321,21 @@ func (s *Swap) getPeer(id enode.ID) *Peer {
 	peer := s.peers[id]
 	return peer
 }
+
+// peerList returns the connected swap peers. The peers map is locked only
+// while it is copied, so that queries do not block the accounting of all
+// peers while they wait for the lock of a single one.
+func (s *Swap) peerList() []*Peer {
+	s.peersLock.RLock()
+	defer s.peersLock.RUnlock()
+	return s.peersSnapshot()
+}
+
+// peersSnapshot copies the connected swap peers, the caller must hold peersLock
+func (s *Swap) peersSnapshot() []*Peer {
+	peers := make([]*Peer, 0, len(s.peers))
+	for _, p := range s.peers {
+		peers = append(peers, p)
+	}
+	return peers
+}
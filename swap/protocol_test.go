@@ -129,7 +129,15 @@ func newSwapHandshakeMsg(contractAddress common.Address, chainID uint64) *Handsh
 
 // creates the correct HandshakeMsg based on Swap instance
 func correctSwapHandshakeMsg(swap *Swap) *HandshakeMsg {
-	return newSwapHandshakeMsg(swap.GetParams().ContractAddress, swap.chainID)
+	msg := newSwapHandshakeMsg(swap.GetParams().ContractAddress, swap.chainID)
+	msg.RetrievePricing = swap.params.RetrievePricing
+	msg.Assets = swap.assetChequebooks()
+	msg.PayOnly = swap.params.PayOnly
+	msg.InterestTerms = swap.interestTerms()
+	for _, a := range swap.assets() {
+		msg.Accounts = append(msg.Accounts, AccountState{Asset: a})
+	}
+	return msg
 }
 
 // TestHandshake tests the correct handshake scenario
@@ -172,6 +180,34 @@ func TestHandshakeInvalidChainID(t *testing.T) {
 	}
 }
 
+// TestHandshakeInvalidRetrievePricing tests that a handshake with a retrieve pricing
+// which increases the price with proximity is rejected
+func TestHandshakeInvalidRetrievePricing(t *testing.T) {
+	// setup the protocolTester, which will allow protocol testing by sending messages
+	protocolTester, clean, err := newSwapTester(t, nil, big.NewInt(0))
+	defer clean()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := correctSwapHandshakeMsg(protocolTester.swap)
+	msg.RetrievePricing = RetrievePricing{
+		Base: 100,
+		Min:  200,
+	}
+	err = protocolTester.testHandshake(
+		correctSwapHandshakeMsg(protocolTester.swap),
+		msg,
+		&p2ptest.Disconnect{
+			Peer:  protocolTester.Nodes[0].ID(),
+			Error: fmt.Errorf("Handshake error: Message handler error: (msg code 0): %v", ErrInvalidRetrievePricing),
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 // TestHandshakeEmptyContract tests that a handshake with an empty contract address is rejected
 func TestHandshakeEmptyContract(t *testing.T) {
 	// setup the protocolTester, which will allow protocol testing by sending messages
@@ -216,6 +252,83 @@ func TestHandshakeInvalidContract(t *testing.T) {
 	}
 }
 
+// TestHandshakeAssetChequebooks tests that only chequebooks paying cheques
+// in the settlement asset they are offered for are accepted in the handshake
+func TestHandshakeAssetChequebooks(t *testing.T) {
+	testBackend := newTestBackend(t)
+	defer testBackend.Close()
+	swap, clean := newTestSwap(t, ownerKey, testBackend)
+	defer clean()
+	if err := testDeploy(context.Background(), swap, big.NewInt(0)); err != nil {
+		t.Fatal(err)
+	}
+	chequebook := swap.GetParams().ContractAddress
+	token := Asset(testBackend.tokenAddress.Hex())
+
+	for _, tc := range []struct {
+		name   string
+		assets []AssetChequebook
+		err    error
+	}{
+		{"eth", []AssetChequebook{{AssetETH, chequebook}}, nil},
+		{"token", []AssetChequebook{{token, chequebook}, {AssetETH, chequebook}}, nil},
+		{"other eth chequebook", []AssetChequebook{{AssetETH, ownerAddress}}, ErrInvalidAssetChequebook},
+		{"other token", []AssetChequebook{{Asset(common.HexToAddress("0x0a").Hex()), chequebook}}, ErrInvalidAssetChequebook},
+		{"invalid token", []AssetChequebook{{"dai", chequebook}}, ErrInvalidAssetChequebook},
+		{"token chequebook not deployed by factory", []AssetChequebook{{token, ownerAddress}}, contract.ErrNotDeployedByFactory},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			msg := correctSwapHandshakeMsg(swap)
+			msg.Assets = tc.assets
+			if err := swap.verifyHandshake(msg); err != tc.err {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+// TestLegacyHandshake tests that peers speaking the first version of the
+// protocol are accepted and settle in ETH at the legacy retrieve request price
+func TestLegacyHandshake(t *testing.T) {
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	if err := testDeploy(context.Background(), swap, big.NewInt(0)); err != nil {
+		t.Fatal(err)
+	}
+	protocolTester := &swapTester{
+		ProtocolTester: p2ptest.NewProtocolTester(swap.owner.privateKey, 1, swap.runLegacy),
+		swap:           swap,
+	}
+	defer protocolTester.Stop()
+
+	msg := &legacyHandshakeMsg{
+		ContractAddress: swap.GetParams().ContractAddress,
+		ChainID:         swap.chainID,
+	}
+	id := protocolTester.Nodes[0].ID()
+	err := protocolTester.TestExchanges(
+		p2ptest.Exchange{Expects: []p2ptest.Expect{{Code: 0, Msg: msg, Peer: id}}},
+		p2ptest.Exchange{Triggers: []p2ptest.Trigger{{Code: 0, Msg: msg, Peer: id}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var peer *Peer
+	for i := 0; i < 100 && peer == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+		peer = swap.getPeer(id)
+	}
+	if peer == nil {
+		t.Fatal("expected the legacy peer to be added")
+	}
+	peer.lock.RLock()
+	defer peer.lock.RUnlock()
+	if peer.asset != AssetETH || !peer.legacy || peer.retrievePricing != legacyRetrievePricing {
+		t.Fatalf("expected a legacy peer settling in eth, got asset %q legacy %v pricing %v", peer.asset, peer.legacy, peer.retrievePricing)
+	}
+}
+
 // TestEmitCheque tests the correct processing of EmitChequeMsg messages
 // One protocol tester is created which will receive the EmitChequeMsg
 // A second swap instance is created for easy creation of a chequebook contract which is deployed to the simulated backend
@@ -483,7 +596,7 @@ func TestTriggerDisconnectThreshold(t *testing.T) {
 
 	// create a dummy pper
 	cPeer := newDummyPeerWithSpec(Spec)
-	debitor, err := creditorSwap.addPeer(cPeer.Peer, common.Address{}, common.Address{})
+	debitor, err := creditorSwap.addPeer(cPeer.Peer, common.Address{}, common.Address{}, DefaultRetrievePricing)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -588,7 +701,7 @@ func TestSwapRPC(t *testing.T) {
 		t.Fatalf("Expected balance to be 0 but it is %d", balance)
 	}
 
-	peer1, err := swap.addPeer(dummyPeer1.Peer, common.Address{}, common.Address{})
+	peer1, err := swap.addPeer(dummyPeer1.Peer, common.Address{}, common.Address{}, DefaultRetrievePricing)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -597,7 +710,7 @@ func TestSwapRPC(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	peer2, err := swap.addPeer(dummyPeer2.Peer, common.Address{}, common.Address{})
+	peer2, err := swap.addPeer(dummyPeer2.Peer, common.Address{}, common.Address{}, DefaultRetrievePricing)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,196 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// DefaultReconcileTolerance is the largest disagreement of the balances of two
+// peers which is converged when they reconnect, balances are not reconciled by default
+const DefaultReconcileTolerance = 0
+
+// auditReconciliation is the reason of balance mutations converging the balance with the peer
+const auditReconciliation = "reconciliation"
+
+// AccountState is the last known state of the account with a peer in a
+// settlement asset, exchanged in the handshake to reconcile both sides.
+// The balance is split into credit and debt, as RLP has no signed integers.
+type AccountState struct {
+	Asset              Asset  // settlement asset of the account
+	Credit             uint64 // honey the receiver owes the sender
+	Debt               uint64 // honey the sender owes the receiver
+	LastSentPayout     uint64 // cumulative payout of the last cheque the sender sent and got confirmed
+	LastReceivedPayout uint64 // cumulative payout of the last cheque the sender received
+}
+
+// accountStates returns the stored state of the accounts with the peer
+// in all settlement assets the node offers
+func (s *Swap) accountStates(peer enode.ID) ([]AccountState, error) {
+	var states []AccountState
+	for _, a := range s.assets() {
+		balance, err := s.loadBalanceAt(assetKey(balanceKey(peer), a))
+		if err != nil {
+			return nil, err
+		}
+		state := newAccountState(a, balance)
+		sent, err := s.loadCheque(assetKey(sentChequeKey(peer), a))
+		if err != nil {
+			return nil, err
+		}
+		if sent != nil {
+			state.LastSentPayout = sent.CumulativePayout
+		}
+		received, err := s.loadCheque(assetKey(receivedChequeKey(peer), a))
+		if err != nil {
+			return nil, err
+		}
+		if received != nil {
+			state.LastReceivedPayout = received.CumulativePayout
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// newAccountState returns the account state in the asset with the balance of the sender
+func newAccountState(asset Asset, balance int64) AccountState {
+	state := AccountState{Asset: asset}
+	if balance > 0 {
+		state.Credit = uint64(balance)
+	} else {
+		state.Debt = uint64(-balance)
+	}
+	return state
+}
+
+// balance returns the balance of the sender with the receiver
+func (a AccountState) balance() int64 {
+	return int64(a.Credit - a.Debt)
+}
+
+// accountState returns the account state in the asset, false if there is none
+func accountState(states []AccountState, asset Asset) (AccountState, bool) {
+	for _, s := range states {
+		if s.Asset == asset {
+			return s, true
+		}
+	}
+	return AccountState{}, false
+}
+
+// reconcileBalance returns the balance both peers converge to given our balance
+// and the balance the peer knows, both from our point of view, false if they do
+// not converge. Neither peer converges to a balance worse for it than its own.
+// If the peer claims a balance worse for us, each peer claims a balance better
+// for itself and they do not converge. Otherwise every balance between the two
+// is at least as good for both peers as their own, and they converge to the
+// larger of the debts the peers admit. The rule is symmetric, so that both
+// peers reach the same balance without another message.
+func reconcileBalance(ours, theirs int64) (int64, bool) {
+	switch {
+	case theirs == ours:
+		return ours, true
+	case theirs < ours:
+		return ours, false
+	case ours >= 0:
+		// the peer admits owing us more than we know
+		return theirs, true
+	case theirs <= 0:
+		// we admit owing the peer more than it knows
+		return ours, true
+	case theirs > -ours:
+		// both admit owing the other, the peer more
+		return theirs, true
+	case theirs < -ours:
+		return ours, true
+	default:
+		return 0, true
+	}
+}
+
+// reconcile converges the account with the peer with the account state it
+// sent in the handshake. Cheques which got lost while the peers were
+// disconnected are settled first: a pending cheque the peer already received
+// is confirmed and one it did not receive is resent. Balances are only
+// converged once no cheque is in flight, as the cheque changes the balance of
+// the peer when it arrives, and only to a balance which is not worse for us
+// and within the reconcile tolerance, so that a peer can neither wipe its debt
+// nor inflate ours by announcing a different balance.
+// the caller is expected to hold p.lock
+func (p *Peer) reconcile(theirs AccountState) error {
+	if pending := p.getPendingCheque(); pending != nil {
+		if theirs.LastReceivedPayout < pending.CumulativePayout {
+			p.logger.Info("peer did not receive pending cheque, resending", "pending", pending, "received", theirs.LastReceivedPayout)
+			return p.Send(context.Background(), &EmitChequeMsg{
+				Cheque: pending,
+			})
+		}
+		p.logger.Info("peer received pending cheque, confirming", "pending", pending, "received", theirs.LastReceivedPayout)
+		if err := p.setLastSentCheque(pending); err != nil {
+			return err
+		}
+		if err := p.setPendingCheque(nil); err != nil {
+			return err
+		}
+	}
+
+	var lastReceived uint64
+	if cheque := p.getLastReceivedCheque(); cheque != nil {
+		lastReceived = cheque.CumulativePayout
+	}
+	if theirs.LastSentPayout > lastReceived {
+		// the peer resends the cheque we did not receive
+		return nil
+	}
+
+	ours := p.getBalance()
+	// the balance of the peer is kept from its point of view
+	known := -theirs.balance()
+	if known == ours {
+		return nil
+	}
+	reconciled, ok := reconcileBalance(ours, known)
+	if !ok {
+		metrics.GetOrRegisterCounter("swap.reconcile.rejected", nil).Inc(1)
+		p.logger.Warn("peer claims a balance worse for us, keeping balance", "balance", strconv.FormatInt(ours, 10), "peer balance", strconv.FormatInt(known, 10))
+		return nil
+	}
+	disagreement := ours - known
+	if disagreement < 0 {
+		disagreement = -disagreement
+	}
+	if disagreement > p.swap.params.ReconcileTolerance {
+		metrics.GetOrRegisterCounter("swap.reconcile.rejected", nil).Inc(1)
+		p.logger.Warn("balance disagreement with peer beyond tolerance, keeping balance", "balance", strconv.FormatInt(ours, 10), "peer balance", strconv.FormatInt(known, 10))
+		return nil
+	}
+	if reconciled == ours {
+		return nil
+	}
+	if err := p.setBalance(reconciled); err != nil {
+		return err
+	}
+	metrics.GetOrRegisterCounter("swap.reconcile.converged", nil).Inc(1)
+	p.logger.Info("reconciled balance with peer", "balance", strconv.FormatInt(ours, 10), "peer balance", strconv.FormatInt(known, 10), "reconciled", strconv.FormatInt(reconciled, 10))
+	p.auditBalance(reconciled-ours, auditReconciliation, "")
+	return nil
+}
@@ -0,0 +1,153 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"context"
+	"math/big"
+	"testing"
+)
+
+// TestReconcileBalance tests that both peers converge to the same balance
+func TestReconcileBalance(t *testing.T) {
+	for _, tc := range []struct {
+		ours, theirs, reconciled int64
+		converged                bool
+	}{
+		{10, 10, 10, true},
+		{10, 4, 10, false},
+		{4, 10, 10, true},
+		{-10, -4, -10, true},
+		{-4, -10, -4, false},
+		{10, -4, 10, false},
+		{-10, 4, -10, true},
+		{-3, 5, 5, true},
+		{-5, 5, 0, true},
+		{0, 7, 7, true},
+		{-7, 0, -7, true},
+		{0, -7, 0, false},
+	} {
+		if reconciled, ok := reconcileBalance(tc.ours, tc.theirs); ok != tc.converged || ok && reconciled != tc.reconciled {
+			t.Errorf("expected balances %d and %d to converge to %d (%v), got %d (%v)", tc.ours, tc.theirs, tc.reconciled, tc.converged, reconciled, ok)
+		}
+		// the peer reconciles from its point of view
+		if reconciled, ok := reconcileBalance(-tc.theirs, -tc.ours); ok != tc.converged || ok && reconciled != -tc.reconciled {
+			t.Errorf("expected the peer to converge balances %d and %d to %d (%v), got %d (%v)", -tc.theirs, -tc.ours, -tc.reconciled, tc.converged, reconciled, ok)
+		}
+	}
+}
+
+// TestAccountState tests that account states carry signed balances
+func TestAccountState(t *testing.T) {
+	for _, balance := range []int64{0, 42, -42} {
+		if got := newAccountState(AssetETH, balance).balance(); got != balance {
+			t.Errorf("expected balance %d, got %d", balance, got)
+		}
+	}
+}
+
+// TestReconcile tests that the balance with a reconnecting peer is converged
+// within the tolerance and only once no cheque is in flight
+func TestReconcile(t *testing.T) {
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	testDeploy(context.Background(), swap, big.NewInt(0))
+
+	testPeer := newDummyPeer()
+	swapPeer, err := swap.addPeer(testPeer.Peer, swap.owner.address, swap.GetParams().ContractAddress, DefaultRetrievePricing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reconcile := func(theirs AccountState) int64 {
+		t.Helper()
+		swapPeer.lock.Lock()
+		defer swapPeer.lock.Unlock()
+		if err := swapPeer.reconcile(theirs); err != nil {
+			t.Fatal(err)
+		}
+		return swapPeer.getBalance()
+	}
+
+	// we crashed and know an older balance, the peer owes us more
+	setBalance(t, swapPeer, 60)
+	if balance := reconcile(newAccountState(AssetETH, -100)); balance != 60 {
+		t.Fatalf("expected no reconciliation without tolerance, got balance %d", balance)
+	}
+	swap.params.ReconcileTolerance = 50
+	if balance := reconcile(newAccountState(AssetETH, -100)); balance != 100 {
+		t.Fatalf("expected balance 100, got %d", balance)
+	}
+	stored, err := swap.loadBalance(testPeer.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored != 100 {
+		t.Fatalf("expected stored balance 100, got %d", stored)
+	}
+	if balance := reconcile(newAccountState(AssetETH, -1000)); balance != 100 {
+		t.Fatalf("expected no reconciliation beyond tolerance, got balance %d", balance)
+	}
+
+	// the peer claims to owe less or to be owed, its debt is kept
+	if balance := reconcile(newAccountState(AssetETH, -60)); balance != 100 {
+		t.Fatalf("expected no reconciliation to a smaller debt, got balance %d", balance)
+	}
+	if balance := reconcile(newAccountState(AssetETH, 20)); balance != 100 {
+		t.Fatalf("expected no reconciliation to an opposite balance, got balance %d", balance)
+	}
+
+	// we owe the peer, which claims we owe more, our debt is kept
+	setBalance(t, swapPeer, -60)
+	if balance := reconcile(newAccountState(AssetETH, 100)); balance != -60 {
+		t.Fatalf("expected no reconciliation to a larger debt of ours, got balance %d", balance)
+	}
+	// the peer claims we owe less, the debt we admit is kept and adopted by the peer
+	if balance := reconcile(newAccountState(AssetETH, 40)); balance != -60 {
+		t.Fatalf("expected balance -60, got %d", balance)
+	}
+	setBalance(t, swapPeer, 100)
+
+	// a cheque the peer sent did not arrive, it is resent by the peer
+	theirs := newAccountState(AssetETH, -120)
+	theirs.LastSentPayout = 42
+	if balance := reconcile(theirs); balance != 100 {
+		t.Fatalf("expected no reconciliation with a cheque in flight, got balance %d", balance)
+	}
+
+	// the peer received our pending cheque but its confirmation was lost
+	cheque := newTestCheque()
+	swapPeer.lock.Lock()
+	err = swapPeer.setPendingCheque(cheque)
+	swapPeer.lock.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	theirs = newAccountState(AssetETH, -140)
+	theirs.LastReceivedPayout = cheque.CumulativePayout
+	if balance := reconcile(theirs); balance != 140 {
+		t.Fatalf("expected balance 140, got %d", balance)
+	}
+	swapPeer.lock.RLock()
+	defer swapPeer.lock.RUnlock()
+	if swapPeer.getPendingCheque() != nil {
+		t.Fatal("expected the pending cheque to be confirmed")
+	}
+	if !cheque.Equal(swapPeer.getLastSentCheque()) {
+		t.Fatalf("expected last sent cheque %v, got %v", cheque, swapPeer.getLastSentCheque())
+	}
+}
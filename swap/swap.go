@@ -21,15 +21,18 @@ import (
 	"crypto/ecdsa"
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
 	"path/filepath"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/console"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
@@ -63,24 +66,52 @@ type Swap struct {
 	backend           contract.Backend           // the backend (blockchain) used
 	chainID           uint64                     // id of the chain the backend is connected to
 	params            *Params                    // economic and operational parameters
+	thresholdsLock    sync.RWMutex               // lock for the thresholds in params, which can be changed at runtime
 	contract          contract.Contract          // reference to the smart contract
 	chequebookFactory contract.SimpleSwapFactory // the chequebook factory used
-	honeyPriceOracle  HoneyOracle                // oracle which resolves the price of honey (in Wei)
-}
-
-// Owner encapsulates information related to accessing the contract
-type Owner struct {
-	address    common.Address    // owner address
-	privateKey *ecdsa.PrivateKey // private key
-	publicKey  *ecdsa.PublicKey  // public key
+	honeyPriceOracle  PriceOracle                // oracle which resolves the price of honey (in Wei)
+	clock             *network.Clock             // source of time
+	audit             *auditLog                  // balance audit log, nil if disabled
+	cashing           *cashingQueue              // latest received cheques waiting to be cashed
+	quit              chan struct{}              // closed on Close to stop background accounting
+	quitOnce          sync.Once                  // Close is called by Stop and by the owner of the instance
 }
 
 // Params encapsulates economic and operational parameters
 type Params struct {
-	BaseAddrs           *network.BzzAddr // this node's base address
-	LogPath             string           // optional audit log path
-	PaymentThreshold    int64            // honey amount at which a payment is triggered
-	DisconnectThreshold int64            // honey amount at which a peer disconnects
+	BaseAddrs               *network.BzzAddr   // this node's base address
+	LogPath                 string             // optional directory the swap execution logs are also written to, unrelated to the balance log
+	PaymentThreshold        int64              // honey amount at which a payment is triggered
+	DisconnectThreshold     int64              // honey amount at which a peer disconnects
+	RetrievePricing         RetrievePricing    // retrieve request pricing announced to peers
+	EncryptStore            bool               // encrypt the swap store with a key derived from the owner key
+	Clock                   mclock.Clock       // source of time, the system clock if nil
+	ExemptPeers             *ExemptPeers       // peers which are not accounted with, none if nil
+	GraceAllowance          int64              // honey amount within which balances decay towards zero, the lower one of the node and the peer applies, disabled if 0
+	GraceDecayRate          int64              // honey per second by which balances within the grace allowance decay
+	PriceOracle             PriceOracle        // oracle resolving the price of honey in wei, set up from PriceOracleSource if nil
+	PriceOracleSource       string             // URL of an HTTP oracle or address of an oracle contract, the fixed HoneyPrice if empty
+	HoneyPrice              uint64             // fixed price of honey in wei without a price oracle, the default price if 0
+	PriceTolerance          uint64             // percentage by which the amount of a received cheque may differ from the local price of its honey
+	Assets                  []Asset            // settlement assets in order of preference, ETH only if empty
+	TokenChequebooks        []*TokenChequebook // chequebooks paying cheques in the ERC20 tokens among the assets
+	BalanceLogPath          string             // file to append every balance mutation to, no balance audit log if empty
+	BalanceLogMaxSize       int64              // size in bytes at which the balance audit log is rotated, DefaultBalanceLogMaxSize if 0
+	BalanceLogMaxFiles      int                // number of rotated balance audit log files kept, DefaultBalanceLogMaxFiles if 0
+	ThrottleFraction        float64            // fraction of the disconnect threshold above which serving a peer is delayed, no throttling if 0
+	ThrottleMaxDelay        time.Duration      // delay of serving a peer at the disconnect threshold, DefaultThrottleMaxDelay if 0
+	ReconcileTolerance      int64              // largest balance disagreement with a reconnecting peer which is converged, balances are not reconciled if 0
+	PaymentRequestThreshold int64              // honey amount a peer owes at which it is asked to pay, no payment requests if 0
+	PaymentRequestInterval  time.Duration      // time before an unanswered payment request is repeated, DefaultPaymentRequestInterval if 0
+	Keystore                *keystore.KeyStore // keystore through which the owner key is used if it holds the key
+	InterestDebtLevel       int64              // honey amount a peer owes above which interest accrues
+	InterestGracePeriod     time.Duration      // time a peer may owe more than the interest debt level before interest accrues
+	InterestRate            uint64             // interest in parts per million of the debt per hour, no interest if 0
+	OwnerLocked             bool               // lock the owner key in the keystore after the chequebook is started
+	PayOnly                 bool               // pay for services with cheques but extend no credit to peers
+	CashingInterval         time.Duration      // interval at which the latest received cheques are cashed in a batch, cheques are cashed on receipt if 0
+	CashingThreshold        uint64             // amount a queued cheque pays out at which it is cashed without waiting for the interval, disabled if 0
+	CashoutStrategy         CashoutStrategy    // conditions on the amount, delay and gas price under which received cheques are cashed
 }
 
 // newSwapLogger returns a new logger for standard swap logs
@@ -135,6 +166,12 @@ func swapRotatingFileHandler(logdir string) (log.Handler, error) {
 
 // newSwapInstance is a swap constructor function without integrity checks
 func newSwapInstance(stateStore state.Store, owner *Owner, backend contract.Backend, chainID uint64, params *Params, chequebookFactory contract.SimpleSwapFactory) *Swap {
+	if params.ExemptPeers == nil {
+		params.ExemptPeers, _ = NewExemptPeers(nil)
+	}
+	if params.PriceOracle == nil {
+		params.PriceOracle = NewFixedPriceOracle(params.HoneyPrice)
+	}
 	return &Swap{
 		store:             stateStore,
 		peers:             make(map[enode.ID]*Peer),
@@ -142,8 +179,11 @@ func newSwapInstance(stateStore state.Store, owner *Owner, backend contract.Back
 		owner:             owner,
 		params:            params,
 		chequebookFactory: chequebookFactory,
-		honeyPriceOracle:  NewHoneyPriceOracle(),
+		honeyPriceOracle:  params.PriceOracle,
 		chainID:           chainID,
+		clock:             network.NewClock(params.Clock, time.Now()),
+		cashing:           newCashingQueue(),
+		quit:              make(chan struct{}),
 	}
 }
 
@@ -167,12 +207,47 @@ func New(dbPath string, prvkey *ecdsa.PrivateKey, backendURL string, params *Par
 	swapLog.Info("connecting to SWAP API", "url", backendURL)
 	// initialize the balances store
 	var stateStore state.Store
-	if stateStore, err = state.NewDBStore(filepath.Join(dbPath, "swap.db")); err != nil {
+	if params.EncryptStore {
+		stateStore, err = state.NewEncryptedDBStore(filepath.Join(dbPath, "swap.db"), state.EncryptionKey(prvkey, "swap"))
+	} else {
+		stateStore, err = state.NewDBStore(filepath.Join(dbPath, "swap.db"))
+	}
+	if err != nil {
 		return nil, fmt.Errorf("error while initializing statestore: %v", err)
 	}
+	if err := state.Migrate(stateStore, StoreSchema); err != nil {
+		return nil, fmt.Errorf("error while migrating statestore: %v", err)
+	}
 	if params.DisconnectThreshold <= params.PaymentThreshold {
 		return nil, fmt.Errorf("disconnect threshold lower or at payment threshold. DisconnectThreshold: %d, PaymentThreshold: %d", params.DisconnectThreshold, params.PaymentThreshold)
 	}
+	if err := validateGrace(params.GraceAllowance, params.GraceDecayRate, params.PaymentThreshold); err != nil {
+		return nil, err
+	}
+	if err := validateAssets(params.Assets, params.TokenChequebooks); err != nil {
+		return nil, err
+	}
+	if err := validatePriceTolerance(params.PriceOracleSource, params.PriceTolerance); err != nil {
+		return nil, err
+	}
+	if err := validateThrottle(params.ThrottleFraction, params.ThrottleMaxDelay); err != nil {
+		return nil, err
+	}
+	if err := validatePaymentRequest(params.PaymentRequestThreshold, params.PaymentThreshold, params.DisconnectThreshold); err != nil {
+		return nil, err
+	}
+	if err := validateInterest(params.InterestDebtLevel, params.InterestRate, params.InterestGracePeriod, params.DisconnectThreshold); err != nil {
+		return nil, err
+	}
+	if params.ReconcileTolerance < 0 {
+		return nil, fmt.Errorf("reconcile tolerance %d must not be negative", params.ReconcileTolerance)
+	}
+	if params.CashingInterval < 0 {
+		return nil, fmt.Errorf("cashing interval %v must not be negative", params.CashingInterval)
+	}
+	if params.CashoutStrategy.MaxDelay < 0 {
+		return nil, fmt.Errorf("cashout max delay %v must not be negative", params.CashoutStrategy.MaxDelay)
+	}
 	// connect to the backend
 	backend, err := ethclient.Dial(backendURL)
 	if err != nil {
@@ -190,7 +265,19 @@ func New(dbPath string, prvkey *ecdsa.PrivateKey, backendURL string, params *Par
 	swapLog.Info("Using backend network ID", "ID", chainID.Uint64())
 
 	// create the owner of SWAP
-	owner := createOwner(prvkey)
+	owner, err := newOwner(prvkey, params.Keystore)
+	if err != nil {
+		return nil, err
+	}
+	if params.OwnerLocked && owner.keystore == nil {
+		return nil, ErrNoOwnerKeystore
+	}
+	// set up the price oracle on the backend
+	if params.PriceOracle == nil && params.PriceOracleSource != "" {
+		if params.PriceOracle, err = newPriceOracle(params.PriceOracleSource, backend); err != nil {
+			return nil, err
+		}
+	}
 	// initialize the factory
 	factory, err := createFactory(factoryAddress, chainID, backend)
 	if err != nil {
@@ -206,6 +293,12 @@ func New(dbPath string, prvkey *ecdsa.PrivateKey, backendURL string, params *Par
 		params,
 		factory,
 	)
+	// open the balance audit log
+	if params.BalanceLogPath != "" {
+		if swap.audit, err = newAuditLog(params.BalanceLogPath, params.BalanceLogMaxSize, params.BalanceLogMaxFiles); err != nil {
+			return nil, err
+		}
+	}
 	// start the chequebook
 	if swap.contract, err = swap.StartChequebook(chequebookAddressFlag); err != nil {
 		return nil, err
@@ -231,6 +324,22 @@ func New(dbPath string, prvkey *ecdsa.PrivateKey, backendURL string, params *Par
 		}
 	}
 
+	// the key is only used from memory while the chequebook is started
+	if params.OwnerLocked {
+		if err := swap.LockOwner(); err != nil {
+			return nil, err
+		}
+	}
+
+	// interest accrues on debts owed to peers charging interest as well
+	go swap.accrueInterestLoop()
+	if params.CashingInterval > 0 || params.CashoutStrategy.enabled() {
+		if err := swap.loadCashingQueue(); err != nil {
+			return nil, err
+		}
+		go swap.cashQueueLoop()
+	}
+
 	return swap, nil
 }
 
@@ -239,6 +348,8 @@ const (
 	sentChequePrefix       = "sent_cheque_"
 	receivedChequePrefix   = "received_cheque_"
 	pendingChequePrefix    = "pending_cheque_"
+	chequeStatsPrefix      = "cheque_stats_"
+	chequeRecordPrefix     = "cheque_record_"
 	connectedChequebookKey = "connected_chequebook"
 	connectedBlockchainKey = "connected_blockchain"
 )
@@ -281,6 +392,12 @@ func checkChainID(currentChainID uint64, s state.Store) (err error) {
 	return nil
 }
 
+// StoreSchema is the schema of the balances and cheques persisted in the swap store,
+// it has no migrations yet
+var StoreSchema = state.Schema{
+	Component: "swap",
+}
+
 // returns the store key for retrieving a peer's balance
 func balanceKey(peer enode.ID) string {
 	return balancePrefix + peer.String()
@@ -300,51 +417,131 @@ func pendingChequeKey(peer enode.ID) string {
 	return pendingChequePrefix + peer.String()
 }
 
-func keyToID(key string, prefix string) enode.ID {
-	return enode.HexID(key[len(prefix):])
+// returns the store key for retrieving debt breakdowns of cheques emitted to a peer
+func chequeStatsKey(peer enode.ID) string {
+	return chequeStatsPrefix + peer.String()
 }
 
-// createOwner assings keys and addresses
-func createOwner(prvkey *ecdsa.PrivateKey) *Owner {
-	pubkey := &prvkey.PublicKey
-	return &Owner{
-		address:    crypto.PubkeyToAddress(*pubkey),
-		privateKey: prvkey,
-		publicKey:  pubkey,
-	}
+func keyToID(key string, prefix string) enode.ID {
+	return enode.HexID(key[len(prefix):])
 }
 
 // Add is the (sole) accounting function
 // Swap implements the protocols.Balance interface
 func (s *Swap) Add(amount int64, peer *protocols.Peer) (err error) {
+	return s.AddForService(amount, peer, "")
+}
+
+// AddForService is Add for an amount generated by the named service,
+// to which debt is attributed in cheque statistics
+// Swap implements the protocols.ServiceBalance interface
+func (s *Swap) AddForService(amount int64, peer *protocols.Peer, service string) (err error) {
+	return s.AddForMessage(amount, peer, service, "")
+}
+
+// AddForMessage is AddForService for an amount accounted for a message
+// of the given type, which is recorded in the balance audit log
+// Swap implements the protocols.MessageBalance interface
+func (s *Swap) AddForMessage(amount int64, peer *protocols.Peer, service, msgType string) (err error) {
+	// exempt peers do not need to be swap enabled, no balance is kept and no cheques are sent
+	if s.params.ExemptPeers.Exempt(peer.ID()) {
+		return nil
+	}
 	swapPeer := s.getPeer(peer.ID())
 	if swapPeer == nil {
 		return fmt.Errorf("peer %s not a swap enabled peer", peer.ID().String())
 	}
+	// peers without a common settlement asset use each other's services for free
+	if swapPeer.asset == "" {
+		return nil
+	}
 	swapPeer.lock.Lock()
 	defer swapPeer.lock.Unlock()
 
+	if err := swapPeer.decayBalance(); err != nil {
+		return err
+	}
+	if err := swapPeer.accrueInterest(); err != nil {
+		return err
+	}
+
 	// check if balance with peer is over the disconnect threshold and if the message would increase the existing debt
 	balance := swapPeer.getBalance()
-	if balance >= s.params.DisconnectThreshold && amount > 0 {
-		return fmt.Errorf("balance for peer %s is over the disconnect threshold %d and cannot incur more debt, disconnecting", peer.ID().String(), s.params.DisconnectThreshold)
+	if _, disconnectThreshold := s.thresholds(); balance >= disconnectThreshold && amount > 0 {
+		return fmt.Errorf("balance for peer %s is over the disconnect threshold %d and cannot incur more debt, disconnecting", peer.ID().String(), disconnectThreshold)
+	}
+
+	// amounts of messages priced per byte can be large, make sure they do not wrap the balance around
+	if (amount > 0 && balance > math.MaxInt64-amount) || (amount < 0 && balance < math.MinInt64-amount) {
+		return fmt.Errorf("amount %d would overflow the balance %d for peer %s", amount, balance, peer.ID().String())
 	}
 
-	if err = swapPeer.updateBalance(amount); err != nil {
+	if err := swapPeer.checkCredit(amount); err != nil {
+		return err
+	}
+
+	if err = swapPeer.updateBalance(amount, msgType, service); err != nil {
+		return err
+	}
+	if amount < 0 {
+		swapPeer.recordDebt(service, uint64(-amount))
+	}
+	// the debt may have crossed the interest debt level
+	if err := swapPeer.accrueInterest(); err != nil {
 		return err
 	}
 
+	if err := s.checkPaymentRequest(swapPeer); err != nil {
+		return err
+	}
 	return s.checkPaymentThresholdAndSendCheque(swapPeer)
 }
 
+// thresholds returns the payment and the disconnect thresholds
+func (s *Swap) thresholds() (paymentThreshold, disconnectThreshold int64) {
+	s.thresholdsLock.RLock()
+	defer s.thresholdsLock.RUnlock()
+	return s.params.PaymentThreshold, s.params.DisconnectThreshold
+}
+
+// SetThresholds changes the payment and the disconnect thresholds of a running node.
+// The disconnect threshold must be higher than the payment threshold.
+// The new thresholds apply to the next balance change with a peer.
+func (s *Swap) SetThresholds(paymentThreshold, disconnectThreshold int64) error {
+	if disconnectThreshold <= paymentThreshold {
+		return fmt.Errorf("disconnect threshold lower or at payment threshold. DisconnectThreshold: %d, PaymentThreshold: %d", disconnectThreshold, paymentThreshold)
+	}
+	if err := validateGrace(s.params.GraceAllowance, s.params.GraceDecayRate, paymentThreshold); err != nil {
+		return err
+	}
+	if err := validateInterest(s.params.InterestDebtLevel, s.params.InterestRate, s.params.InterestGracePeriod, disconnectThreshold); err != nil {
+		return err
+	}
+	if err := validatePaymentRequest(s.params.PaymentRequestThreshold, paymentThreshold, disconnectThreshold); err != nil {
+		return err
+	}
+	s.thresholdsLock.Lock()
+	defer s.thresholdsLock.Unlock()
+	s.params.PaymentThreshold = paymentThreshold
+	s.params.DisconnectThreshold = disconnectThreshold
+	return nil
+}
+
 // checkPaymentThresholdAndSendCheque checks if balance with peer crosses the payment threshold and attempts to send a cheque if so
 // It is the peer with a negative balance who sends a cheque, thus we check
 // that the balance is *below* the threshold
 // the caller is expected to hold swapPeer.lock
 func (s *Swap) checkPaymentThresholdAndSendCheque(swapPeer *Peer) error {
-	if swapPeer.getBalance() <= -s.params.PaymentThreshold {
-		swapPeer.logger.Info("balance for peer went over the payment threshold, sending cheque", "payment threshold", s.params.PaymentThreshold)
-		return swapPeer.sendCheque()
+	if paymentThreshold, _ := s.thresholds(); swapPeer.getBalance() <= -paymentThreshold {
+		swapPeer.logger.Info("balance for peer went over the payment threshold, sending cheque", "payment threshold", paymentThreshold)
+		err := swapPeer.sendCheque()
+		if err == ErrOwnerLocked {
+			// the debt keeps growing until the key is unlocked or the peer disconnects
+			metrics.GetOrRegisterCounter("swap.cheques.locked", nil).Inc(1)
+			swapPeer.logger.Warn("chequebook owner key is locked, not sending cheque")
+			return nil
+		}
+		return err
 	}
 	return nil
 }
@@ -357,6 +554,8 @@ func (s *Swap) handleMsg(p *Peer) func(ctx context.Context, msg interface{}) err
 			go s.handleEmitChequeMsg(ctx, p, msg)
 		case *ConfirmChequeMsg:
 			go s.handleConfirmChequeMsg(ctx, p, msg)
+		case *PaymentRequestMsg:
+			go s.handlePaymentRequestMsg(ctx, p, msg)
 		}
 		return nil
 	}
@@ -373,6 +572,11 @@ func (s *Swap) handleEmitChequeMsg(ctx context.Context, p *Peer, msg *EmitCheque
 	cheque := msg.Cheque
 	p.logger.Info("received cheque from peer", "honey", cheque.Honey)
 
+	// no peer owes a pay-only node, which has no chequebook to receive into
+	if s.params.PayOnly {
+		return ErrPayOnly
+	}
+
 	if p.getLastReceivedCheque() != nil && cheque.Equal(p.getLastReceivedCheque()) {
 		p.logger.Warn("cheque sent by peer has already been received in the past", "cumulativePayout", cheque.CumulativePayout)
 		return p.Send(ctx, &ConfirmChequeMsg{
@@ -380,23 +584,28 @@ func (s *Swap) handleEmitChequeMsg(ctx context.Context, p *Peer, msg *EmitCheque
 		})
 	}
 
-	_, err := s.processAndVerifyCheque(cheque, p)
+	amount, honey, err := s.processAndVerifyCheque(cheque, p)
 	if err != nil {
 		log.Error("error processing and verifying received cheque", "err", err)
 		return err
 	}
 
+	if err := p.addChequeRecord(ChequeReceived, cheque, amount); err != nil {
+		p.logger.Warn("error while saving cheque record", "err", err)
+	}
+
 	p.logger.Debug("processed and verified received cheque", "beneficiary", cheque.Beneficiary, "cumulative payout", cheque.CumulativePayout)
 
-	// reset balance by amount
+	// reset balance by the honey the amount pays for
 	// as this is done by the creditor, receiving the cheque, the amount should be negative,
 	// so that updateBalance will calculate balance + amount which result in reducing the peer's balance
-	honeyAmount := int64(cheque.Honey)
-	err = p.updateBalance(-honeyAmount)
+	honeyAmount := int64(honey)
+	err = p.updateBalance(-honeyAmount, auditChequeReceived, "")
 	if err != nil {
 		log.Error("error updating balance", "err", err)
 		return err
 	}
+	p.resetPaymentRequests()
 
 	metrics.GetOrRegisterCounter("swap.cheques.received.num", nil).Inc(1)
 	metrics.GetOrRegisterCounter("swap.cheques.received.honey", nil).Inc(honeyAmount)
@@ -408,30 +617,64 @@ func (s *Swap) handleEmitChequeMsg(ctx context.Context, p *Peer, msg *EmitCheque
 		return err
 	}
 
+	// cheques are queued if they are cashed in batches or cashing them may be deferred
+	if s.params.CashingInterval > 0 || s.params.CashoutStrategy.enabled() {
+		return s.queueCheque(ctx, cheque)
+	}
+	return s.cashChequeIfProfitable(ctx, cheque)
+}
+
+// cashChequeIfProfitable cashes the cheque if the amount it pays out is worth
+// twice the transaction costs
+func (s *Swap) cashChequeIfProfitable(ctx context.Context, cheque *Cheque) error {
+	otherSwap, opts, err := s.cashingTransaction(ctx, cheque, 0)
+	if err != nil || otherSwap == nil {
+		return err
+	}
+	// cash cheque in async, otherwise this blocks here until the TX is mined
+	go defaultCashCheque(s, otherSwap, opts, cheque)
+	return nil
+}
+
+// cashingTransaction returns the chequebook and the transaction options to
+// cash the cheque with if the amount it pays out is worth twice the
+// transaction costs and the cashout strategy allows cashing it after delay,
+// or a nil chequebook if the cheque is not to be cashed now
+func (s *Swap) cashingTransaction(ctx context.Context, cheque *Cheque, delay time.Duration) (contract.Contract, *bind.TransactOpts, error) {
 	otherSwap, err := contract.InstanceAt(cheque.Contract, s.backend)
 	if err != nil {
 		log.Error("error getting contract", "err", err)
-		return err
+		return nil, nil, err
 	}
 
 	gasPrice, err := s.backend.SuggestGasPrice(context.TODO())
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	transactionCosts := gasPrice.Uint64() * 50000 // cashing a cheque is approximately 50000 gas
 	paidOut, err := otherSwap.PaidOut(nil, cheque.Beneficiary)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
+	amount := cheque.CumulativePayout - paidOut.Uint64()
 	// do a payout transaction if we get 2 times the gas costs
-	if (cheque.CumulativePayout - paidOut.Uint64()) > 2*transactionCosts {
-		opts := bind.NewKeyedTransactor(s.owner.privateKey)
-		opts.Context = ctx
-		// cash cheque in async, otherwise this blocks here until the TX is mined
-		go defaultCashCheque(s, otherSwap, opts, cheque)
+	if amount <= 2*transactionCosts {
+		return nil, nil, nil
 	}
-
-	return err
+	if !s.params.CashoutStrategy.cash(amount, gasPrice.Uint64(), delay) {
+		swapLog.Debug("cashout strategy defers cashing cheque", "contract", cheque.Contract, "amount", amount, "gasPrice", gasPrice)
+		return nil, nil, nil
+	}
+	opts, err := s.owner.transactor()
+	if err == ErrOwnerLocked {
+		swapLog.Warn("chequebook owner key is locked, not cashing cheque", "contract", cheque.Contract, "cumulativePayout", cheque.CumulativePayout)
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	opts.Context = ctx
+	return otherSwap, opts, nil
 }
 
 func (s *Swap) handleConfirmChequeMsg(ctx context.Context, p *Peer, msg *ConfirmChequeMsg) {
@@ -463,15 +706,15 @@ func (s *Swap) handleConfirmChequeMsg(ctx context.Context, p *Peer, msg *Confirm
 }
 
 // cashCheque should be called async as it blocks until the transaction(s) are mined
-// The function cashes the cheque by sending it to the blockchain
-func cashCheque(s *Swap, otherSwap contract.Contract, opts *bind.TransactOpts, cheque *Cheque) {
+// The function cashes the cheque by sending it to the blockchain, it returns
+// an error if the transaction failed or reverted
+func cashCheque(s *Swap, otherSwap contract.Contract, opts *bind.TransactOpts, cheque *Cheque) error {
 	// blocks here, as we are waiting for the transaction to be mined
 	result, receipt, err := otherSwap.CashChequeBeneficiary(opts, s.GetParams().ContractAddress, big.NewInt(int64(cheque.CumulativePayout)), cheque.Signature)
 	if err != nil {
-		// TODO: do something with the error
-		// and we actually need to log this error as we are in an async routine; nobody is handling this error for now
+		// we actually need to log this error as we are in an async routine
 		swapLog.Error("error cashing cheque", "err", err)
-		return
+		return err
 	}
 
 	metrics.GetOrRegisterCounter("swap.cheques.cashed.honey", nil).Inc(result.TotalPayout.Int64())
@@ -479,31 +722,33 @@ func cashCheque(s *Swap, otherSwap contract.Contract, opts *bind.TransactOpts, c
 	if result.Bounced {
 		metrics.GetOrRegisterCounter("swap.cheques.cashed.bounced", nil).Inc(1)
 		swapLog.Warn("cheque bounced", "tx", receipt.TxHash)
-		return
+		return nil
 		// TODO: do something here
 	}
 
 	swapLog.Debug("cash tx mined", "receipt", receipt)
+	return nil
 }
 
 // processAndVerifyCheque verifies the cheque and compares it with the last received cheque
 // if the cheque is valid it will also be saved as the new last cheque
-func (s *Swap) processAndVerifyCheque(cheque *Cheque, p *Peer) (uint64, error) {
+// returns the amount received and the honey it pays for at the local price
+func (s *Swap) processAndVerifyCheque(cheque *Cheque, p *Peer) (uint64, uint64, error) {
 	if err := cheque.verifyChequeProperties(p, s.owner.address); err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
 	lastCheque := p.getLastReceivedCheque()
 
 	// TODO: there should probably be a lock here?
-	expectedAmount, err := s.honeyPriceOracle.GetPrice(cheque.Honey)
+	expectedAmount, err := s.priceOracle(p.asset).GetPrice(cheque.Honey)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
-	actualAmount, err := cheque.verifyChequeAgainstLast(lastCheque, expectedAmount)
+	actualAmount, err := cheque.verifyChequeAgainstLast(lastCheque, expectedAmount, s.params.PriceTolerance)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
 	if err := p.setLastReceivedCheque(cheque); err != nil {
@@ -511,39 +756,37 @@ func (s *Swap) processAndVerifyCheque(cheque *Cheque, p *Peer) (uint64, error) {
 		// TODO: what do we do here? Related issue: https://github.com/ethersphere/swarm/issues/1515
 	}
 
-	return actualAmount, nil
+	return actualAmount, receivedHoney(cheque.Honey, actualAmount, expectedAmount), nil
 }
 
 // loadLastReceivedCheque loads the last received cheque for the peer from the store
 // and returns nil when there never was a cheque saved
 func (s *Swap) loadLastReceivedCheque(p enode.ID) (cheque *Cheque, err error) {
-	err = s.store.Get(receivedChequeKey(p), &cheque)
-	if err == state.ErrNotFound {
-		return nil, nil
-	}
-	if err != nil {
-		return nil, err
-	}
-	return cheque, nil
+	return s.loadCheque(receivedChequeKey(p))
 }
 
 // loadLastSentCheque loads the last sent cheque for the peer from the store
 // and returns nil when there never was a cheque saved
 func (s *Swap) loadLastSentCheque(p enode.ID) (cheque *Cheque, err error) {
-	err = s.store.Get(sentChequeKey(p), &cheque)
-	if err == state.ErrNotFound {
-		return nil, nil
-	}
-	if err != nil {
-		return nil, err
-	}
-	return cheque, nil
+	return s.loadCheque(sentChequeKey(p))
 }
 
 // loadPendingCheque loads the current pending cheque for the peer from the store
 // and returns nil when there never was a pending cheque saved
 func (s *Swap) loadPendingCheque(p enode.ID) (cheque *Cheque, err error) {
-	err = s.store.Get(pendingChequeKey(p), &cheque)
+	return s.loadCheque(pendingChequeKey(p))
+}
+
+// loadBalance loads the current balance for the peer from the store
+// and returns 0 if there was no prior balance saved
+func (s *Swap) loadBalance(p enode.ID) (balance int64, err error) {
+	return s.loadBalanceAt(balanceKey(p))
+}
+
+// loadCheque loads the cheque at the store key
+// and returns nil when there never was a cheque saved
+func (s *Swap) loadCheque(key string) (cheque *Cheque, err error) {
+	err = s.store.Get(key, &cheque)
 	if err == state.ErrNotFound {
 		return nil, nil
 	}
@@ -553,10 +796,10 @@ func (s *Swap) loadPendingCheque(p enode.ID) (cheque *Cheque, err error) {
 	return cheque, nil
 }
 
-// loadBalance loads the current balance for the peer from the store
+// loadBalanceAt loads the balance at the store key
 // and returns 0 if there was no prior balance saved
-func (s *Swap) loadBalance(p enode.ID) (balance int64, err error) {
-	err = s.store.Get(balanceKey(p), &balance)
+func (s *Swap) loadBalanceAt(key string) (balance int64, err error) {
+	err = s.store.Get(key, &balance)
 	if err == state.ErrNotFound {
 		return 0, nil
 	}
@@ -588,6 +831,10 @@ func (s *Swap) saveBalance(p enode.ID, balance int64) error {
 
 // Close cleans up swap
 func (s *Swap) Close() error {
+	s.quitOnce.Do(func() { close(s.quit) })
+	if err := s.audit.close(); err != nil {
+		swapLog.Error("error closing balance audit log", "err", err)
+	}
 	return s.store.Close()
 }
 
@@ -596,6 +843,21 @@ func (s *Swap) GetParams() *contract.Params {
 	return s.contract.ContractParams()
 }
 
+// RetrievePricing returns the retrieve request pricing of the local node
+func (s *Swap) RetrievePricing() RetrievePricing {
+	return s.params.RetrievePricing
+}
+
+// PeerRetrievePricing returns the retrieve request pricing announced by the peer
+// in the swap handshake, or false if the peer is not a swap peer
+func (s *Swap) PeerRetrievePricing(id enode.ID) (RetrievePricing, bool) {
+	swapPeer := s.getPeer(id)
+	if swapPeer == nil {
+		return RetrievePricing{}, false
+	}
+	return swapPeer.retrievePricing, true
+}
+
 // getContractOwner retrieve the owner of the chequebook at address from the blockchain
 func (s *Swap) getContractOwner(ctx context.Context, address common.Address) (common.Address, error) {
 	contr, err := contract.InstanceAt(address, s.backend)
@@ -680,7 +942,10 @@ func (s *Swap) bindToContractAt(address common.Address) (contract.Contract, erro
 
 // Deploy deploys the Swap contract
 func (s *Swap) Deploy(ctx context.Context) (contract.Contract, error) {
-	opts := bind.NewKeyedTransactor(s.owner.privateKey)
+	opts, err := s.owner.transactor()
+	if err != nil {
+		return nil, err
+	}
 	opts.Context = ctx
 	swapLog.Info("Deploying new swap", "owner", opts.From.Hex())
 	chequebook, err := s.chequebookFactory.DeploySimpleSwap(opts, s.owner.address, big.NewInt(int64(defaultHarddepositTimeoutDuration)))
@@ -692,7 +957,10 @@ func (s *Swap) Deploy(ctx context.Context) (contract.Contract, error) {
 
 // Deposit deposits ERC20 into the chequebook contract
 func (s *Swap) Deposit(ctx context.Context, amount *big.Int) error {
-	opts := bind.NewKeyedTransactor(s.owner.privateKey)
+	opts, err := s.owner.transactor()
+	if err != nil {
+		return err
+	}
 	opts.Context = ctx
 	swapLog.Info("Depositing ERC20 into chequebook", "amount", amount)
 	rec, err := s.contract.Deposit(opts, amount)
@@ -27,6 +27,7 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"math/big"
 	mrand "math/rand"
 	"os"
@@ -34,6 +35,7 @@ import (
 	"reflect"
 	"runtime"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -42,6 +44,7 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -209,7 +212,7 @@ func TestStoreBalances(t *testing.T) {
 	defer clean()
 
 	// modify balances both in memory and in store
-	testPeer, err := s.addPeer(newDummyPeer().Peer, common.Address{}, common.Address{})
+	testPeer, err := s.addPeer(newDummyPeer().Peer, common.Address{}, common.Address{}, DefaultRetrievePricing)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -222,7 +225,7 @@ func TestStoreBalances(t *testing.T) {
 	comparePeerBalance(t, s, testPeerID, peerBalance)
 
 	// update balances for second peer
-	testPeer2, err := s.addPeer(newDummyPeer().Peer, common.Address{}, common.Address{})
+	testPeer2, err := s.addPeer(newDummyPeer().Peer, common.Address{}, common.Address{}, DefaultRetrievePricing)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -237,6 +240,112 @@ func TestStoreBalances(t *testing.T) {
 	comparePeerBalance(t, s, testPeer2ID, peer2Balance)
 }
 
+// TestRestoreBalances tests that balances survive a restart of the node
+// and are restored when the peers connect again
+func TestRestoreBalances(t *testing.T) {
+	testBackend := newTestBackend(t)
+	defer testBackend.Close()
+	s, dir := newBaseTestSwap(t, ownerKey, testBackend)
+	defer os.RemoveAll(dir)
+
+	protoPeer := newDummyPeer().Peer
+	if _, err := s.addPeer(protoPeer, common.Address{}, common.Address{}, DefaultRetrievePricing); err != nil {
+		t.Fatal(err)
+	}
+	for _, amount := range []int64{100, -30, 7} {
+		if err := s.Add(amount, protoPeer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	stateStore, err := state.NewDBStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = newSwapInstance(stateStore, s.owner, testBackend, 10, s.params, s.chequebookFactory)
+	defer s.Close()
+
+	// the balance is known before the peer connects
+	balance, err := s.PeerBalance(protoPeer.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance != 77 {
+		t.Fatalf("got balance %d after restart, want 77", balance)
+	}
+
+	swapPeer, err := s.addPeer(protoPeer, common.Address{}, common.Address{}, DefaultRetrievePricing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance := swapPeer.getBalance(); balance != 77 {
+		t.Fatalf("got balance %d of the connected peer, want 77", balance)
+	}
+	if err := s.Add(3, protoPeer); err != nil {
+		t.Fatal(err)
+	}
+	comparePeerBalance(t, s, protoPeer.ID(), 80)
+}
+
+// TestConcurrentBalances tests that accounting with many peers concurrently
+// with balance queries keeps the balances of all peers correct
+func TestConcurrentBalances(t *testing.T) {
+	s, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+
+	var peers []*protocols.Peer
+	for i := 0; i < 10; i++ {
+		p := newDummyPeer().Peer
+		if _, err := s.addPeer(p, common.Address{}, common.Address{}, DefaultRetrievePricing); err != nil {
+			t.Fatal(err)
+		}
+		peers = append(peers, p)
+	}
+
+	var wg sync.WaitGroup
+	for _, p := range peers {
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func(p *protocols.Peer) {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					if err := s.Add(1, p); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}(p)
+		}
+		wg.Add(1)
+		go func(p *protocols.Peer) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if _, err := s.Balances(); err != nil {
+					t.Error(err)
+				}
+				if _, err := s.PeerBalance(p.ID()); err != nil {
+					t.Error(err)
+				}
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	balances, err := s.Balances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range peers {
+		if balances[p.ID()] != 100 {
+			t.Errorf("got balance %d for peer %s, want 100", balances[p.ID()], p.ID())
+		}
+		comparePeerBalance(t, s, p.ID(), 100)
+	}
+}
+
 func comparePeerBalance(t *testing.T, s *Swap, peer enode.ID, expectedPeerBalance int64) {
 	t.Helper()
 	var peerBalance int64
@@ -258,7 +367,7 @@ func TestRepeatedBookings(t *testing.T) {
 	var bookings []booking
 
 	// credits to peer 1
-	testPeer, err := swap.addPeer(newDummyPeer().Peer, common.Address{}, common.Address{})
+	testPeer, err := swap.addPeer(newDummyPeer().Peer, common.Address{}, common.Address{}, DefaultRetrievePricing)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -267,7 +376,7 @@ func TestRepeatedBookings(t *testing.T) {
 	testPeerBookings(t, swap, &bookings, bookingAmount, bookingQuantity, testPeer.Peer)
 
 	// debits to peer 2
-	testPeer2, err := swap.addPeer(newDummyPeer().Peer, common.Address{}, common.Address{})
+	testPeer2, err := swap.addPeer(newDummyPeer().Peer, common.Address{}, common.Address{}, DefaultRetrievePricing)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -611,7 +720,7 @@ func TestDisconnectThreshold(t *testing.T) {
 	testDeploy(context.Background(), swap, big.NewInt(0))
 
 	testPeer := newDummyPeer()
-	swap.addPeer(testPeer.Peer, swap.owner.address, swap.GetParams().ContractAddress)
+	swap.addPeer(testPeer.Peer, swap.owner.address, swap.GetParams().ContractAddress, DefaultRetrievePricing)
 
 	// leave balance exactly at disconnect threshold
 	swap.Add(int64(DefaultDisconnectThreshold), testPeer.Peer)
@@ -630,13 +739,65 @@ func TestDisconnectThreshold(t *testing.T) {
 	}
 }
 
+// TestAddOverflow tests that amounts which would overflow the balance are rejected
+func TestAddOverflow(t *testing.T) {
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	testDeploy(context.Background(), swap, big.NewInt(0))
+
+	testPeer := newDummyPeer()
+	swap.addPeer(testPeer.Peer, swap.owner.address, swap.GetParams().ContractAddress, DefaultRetrievePricing)
+
+	if err := swap.SetThresholds(math.MaxInt64-1, math.MaxInt64); err != nil {
+		t.Fatal(err)
+	}
+	if err := swap.Add(math.MaxInt64-10, testPeer.Peer); err != nil {
+		t.Fatal(err)
+	}
+	err := swap.Add(20, testPeer.Peer)
+	if err == nil || !strings.Contains(err.Error(), "overflow") {
+		t.Fatalf("expected the balance overflow to be rejected, got %v", err)
+	}
+	balance, err := swap.PeerBalance(testPeer.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance != math.MaxInt64-10 {
+		t.Fatalf("expected balance %d to be unchanged, got %d", int64(math.MaxInt64-10), balance)
+	}
+}
+
+// TestSetThresholds tests that thresholds changed on a running node apply to the next balance change
+func TestSetThresholds(t *testing.T) {
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	testDeploy(context.Background(), swap, big.NewInt(0))
+
+	testPeer := newDummyPeer()
+	swap.addPeer(testPeer.Peer, swap.owner.address, swap.GetParams().ContractAddress, DefaultRetrievePricing)
+
+	if err := swap.SetThresholds(100, 100); err == nil {
+		t.Fatal("expected disconnect threshold at payment threshold to be rejected")
+	}
+	if err := swap.SetThresholds(100, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if err := swap.Add(1000, testPeer.Peer); err != nil {
+		t.Fatal(err)
+	}
+	err := swap.Add(1, testPeer.Peer)
+	if err == nil || !strings.Contains(err.Error(), "disconnect threshold 1000") {
+		t.Fatalf("expected the lowered disconnect threshold to be reached, got %v", err)
+	}
+}
+
 //TestPaymentThreshold tests that the payment threshold is reached when subtracting the DefaultPaymentThreshold amount from the peers balance
 func TestPaymentThreshold(t *testing.T) {
 	swap, clean := newTestSwap(t, ownerKey, nil)
 	defer clean()
 	testDeploy(context.Background(), swap, big.NewInt(int64(DefaultPaymentThreshold)))
 	testPeer := newDummyPeerWithSpec(Spec)
-	swap.addPeer(testPeer.Peer, swap.owner.address, swap.GetParams().ContractAddress)
+	swap.addPeer(testPeer.Peer, swap.owner.address, swap.GetParams().ContractAddress, DefaultRetrievePricing)
 	if err := swap.Add(-int64(DefaultPaymentThreshold), testPeer.Peer); err != nil {
 		t.Fatal()
 	}
@@ -648,6 +809,95 @@ func TestPaymentThreshold(t *testing.T) {
 	}
 }
 
+// TestChequeStats tests that debt is attributed to services and time windows
+// and that the breakdown is stored for every emitted cheque
+func TestChequeStats(t *testing.T) {
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	testDeploy(context.Background(), swap, big.NewInt(int64(DefaultPaymentThreshold)*2))
+	testPeer := newDummyPeerWithSpec(Spec)
+	swap.addPeer(testPeer.Peer, swap.owner.address, swap.GetParams().ContractAddress, DefaultRetrievePricing)
+
+	now := time.Date(2019, 12, 1, 10, 30, 0, 0, time.UTC)
+	sim := new(mclock.Simulated)
+	swap.clock = network.NewClock(sim, now)
+
+	threshold := int64(DefaultPaymentThreshold)
+	add := func(amount int64, service string) {
+		t.Helper()
+		if err := swap.AddForService(amount, testPeer.Peer, service); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add(-300, "bzz-retrieve")
+	// credits are not debt
+	add(100, "bzz-retrieve")
+	sim.Run(time.Hour)
+	now = now.Add(time.Hour)
+	add(-200, "bzz-stream")
+	// triggers the cheque
+	add(-threshold, "bzz-retrieve")
+
+	stats, err := swap.PeerChequeStats(testPeer.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("got %v cheque stats, want 1", len(stats))
+	}
+	if stats[0].Honey != uint64(threshold+400) {
+		t.Errorf("got cheque honey %v, want %v", stats[0].Honey, threshold+400)
+	}
+	if !stats[0].Time.Equal(now) {
+		t.Errorf("got cheque time %v, want %v", stats[0].Time, now)
+	}
+	window := time.Date(2019, 12, 1, 10, 0, 0, 0, time.UTC)
+	wantDebts := []DebtStats{
+		{Service: "bzz-retrieve", Window: window, Honey: 300},
+		{Service: "bzz-retrieve", Window: window.Add(time.Hour), Honey: uint64(threshold)},
+		{Service: "bzz-stream", Window: window.Add(time.Hour), Honey: 200},
+	}
+	checkDebtStats(t, stats[0].Debts, wantDebts)
+
+	// confirm the cheque, so that the next one is not a resend
+	swapPeer := swap.getPeer(testPeer.ID())
+	swapPeer.lock.Lock()
+	if err := swapPeer.setLastSentCheque(swapPeer.getPendingCheque()); err != nil {
+		t.Fatal(err)
+	}
+	if err := swapPeer.setPendingCheque(nil); err != nil {
+		t.Fatal(err)
+	}
+	swapPeer.lock.Unlock()
+
+	// only debt accrued after the last cheque is attributed to the next one
+	add(-threshold, "")
+	all, err := swap.ChequeStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats = all[testPeer.ID()]
+	if len(stats) != 2 {
+		t.Fatalf("got %v cheque stats, want 2", len(stats))
+	}
+	checkDebtStats(t, stats[0].Debts, wantDebts)
+	checkDebtStats(t, stats[1].Debts, []DebtStats{
+		{Service: "", Window: window.Add(time.Hour), Honey: uint64(threshold)},
+	})
+}
+
+func checkDebtStats(t *testing.T, got, want []DebtStats) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %v debts, want %v", len(got), len(want))
+	}
+	for i := range got {
+		if got[i].Service != want[i].Service || !got[i].Window.Equal(want[i].Window) || got[i].Honey != want[i].Honey {
+			t.Errorf("debt %v: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
 // TestResetBalance tests that balances are correctly reset
 // The test deploys creates swap instances for each node,
 // deploys simulated contracts, sets the balance of each
@@ -681,11 +931,11 @@ func TestResetBalance(t *testing.T) {
 	// so creditor is the model of the remote mode for the debitor! (and vice versa)
 	cPeer := newDummyPeerWithSpec(Spec)
 	dPeer := newDummyPeerWithSpec(Spec)
-	creditor, err := debitorSwap.addPeer(cPeer.Peer, creditorSwap.owner.address, debitorSwap.GetParams().ContractAddress)
+	creditor, err := debitorSwap.addPeer(cPeer.Peer, creditorSwap.owner.address, debitorSwap.GetParams().ContractAddress, DefaultRetrievePricing)
 	if err != nil {
 		t.Fatal(err)
 	}
-	debitor, err := creditorSwap.addPeer(dPeer.Peer, debitorSwap.owner.address, debitorSwap.GetParams().ContractAddress)
+	debitor, err := creditorSwap.addPeer(dPeer.Peer, debitorSwap.owner.address, debitorSwap.GetParams().ContractAddress, DefaultRetrievePricing)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -819,7 +1069,7 @@ func TestRestoreBalanceFromStateStore(t *testing.T) {
 	swap, testDir := newBaseTestSwap(t, ownerKey, testBackend)
 	defer os.RemoveAll(testDir)
 
-	testPeer, err := swap.addPeer(newDummyPeer().Peer, common.Address{}, common.Address{})
+	testPeer, err := swap.addPeer(newDummyPeer().Peer, common.Address{}, common.Address{}, DefaultRetrievePricing)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -852,14 +1102,15 @@ func TestRestoreBalanceFromStateStore(t *testing.T) {
 // During tests, because the cashing in of cheques is async, we should wait for the function to be returned
 // Otherwise if we call `handleEmitChequeMsg` manually, it will return before the TX has been committed to the `SimulatedBackend`,
 // causing subsequent TX to possibly fail due to nonce mismatch
-func testCashCheque(s *Swap, otherSwap cswap.Contract, opts *bind.TransactOpts, cheque *Cheque) {
-	cashCheque(s, otherSwap, opts, cheque)
+func testCashCheque(s *Swap, otherSwap cswap.Contract, opts *bind.TransactOpts, cheque *Cheque) error {
+	err := cashCheque(s, otherSwap, opts, cheque)
 	// send to the channel, signals to clients that this function actually finished
 	if stb, ok := s.backend.(*swapTestBackend); ok {
 		if stb.cashDone != nil {
 			stb.cashDone <- struct{}{}
 		}
 	}
+	return err
 }
 
 // newDefaultParams creates a set of default params for tests
@@ -875,6 +1126,7 @@ func newDefaultParams(t *testing.T) *Params {
 		LogPath:             "",
 		PaymentThreshold:    int64(DefaultPaymentThreshold),
 		DisconnectThreshold: int64(DefaultDisconnectThreshold),
+		RetrievePricing:     DefaultRetrievePricing,
 	}
 }
 
@@ -1312,7 +1564,7 @@ func newTestSwapAndPeer(t *testing.T, key *ecdsa.PrivateKey) (*Swap, *Peer, func
 	swap, clean := newTestSwap(t, key, nil)
 	// owner address is the beneficiary (counterparty) for the peer
 	// that's because we expect cheques we receive to be signed by the address we would issue cheques to
-	peer, err := swap.addPeer(newDummyPeer().Peer, ownerAddress, testChequeContract)
+	peer, err := swap.addPeer(newDummyPeer().Peer, ownerAddress, testChequeContract, DefaultRetrievePricing)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1342,7 +1594,7 @@ func TestPeerSetAndGetLastReceivedCheque(t *testing.T) {
 	}
 
 	// create a new swap peer for the same underlying peer to force a database load
-	samePeer, err := swap.addPeer(peer.Peer, common.Address{}, common.Address{})
+	samePeer, err := swap.addPeer(peer.Peer, common.Address{}, common.Address{}, DefaultRetrievePricing)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1407,7 +1659,7 @@ func TestPeerVerifyChequeAgainstLast(t *testing.T) {
 
 	newCheque.CumulativePayout = oldCheque.CumulativePayout + increase
 
-	actualAmount, err := newCheque.verifyChequeAgainstLast(oldCheque, increase)
+	actualAmount, err := newCheque.verifyChequeAgainstLast(oldCheque, increase, 0)
 	if err != nil {
 		t.Fatalf("failed to verify cheque compared to old cheque: %v", err)
 	}
@@ -1425,7 +1677,7 @@ func TestPeerVerifyChequeAgainstLastInvalid(t *testing.T) {
 	oldCheque := newTestCheque()
 	newCheque := newTestCheque()
 
-	if _, err := newCheque.verifyChequeAgainstLast(oldCheque, increase); err == nil {
+	if _, err := newCheque.verifyChequeAgainstLast(oldCheque, increase, 0); err == nil {
 		t.Fatal("accepted a cheque with same amount")
 	}
 
@@ -1434,7 +1686,7 @@ func TestPeerVerifyChequeAgainstLastInvalid(t *testing.T) {
 	newCheque = newTestCheque()
 	newCheque.CumulativePayout = oldCheque.CumulativePayout + increase + 5
 
-	if _, err := newCheque.verifyChequeAgainstLast(oldCheque, increase); err == nil {
+	if _, err := newCheque.verifyChequeAgainstLast(oldCheque, increase, 0); err == nil {
 		t.Fatal("accepted a cheque with unexpected amount")
 	}
 }
@@ -1448,7 +1700,7 @@ func TestPeerProcessAndVerifyCheque(t *testing.T) {
 	cheque := newTestCheque()
 	cheque.Signature, _ = cheque.Sign(ownerKey)
 
-	actualAmount, err := swap.processAndVerifyCheque(cheque, peer)
+	actualAmount, honey, err := swap.processAndVerifyCheque(cheque, peer)
 	if err != nil {
 		t.Fatalf("failed to process cheque: %s", err)
 	}
@@ -1456,6 +1708,9 @@ func TestPeerProcessAndVerifyCheque(t *testing.T) {
 	if actualAmount != cheque.CumulativePayout {
 		t.Fatalf("computed wrong actual amount: was %d, expected: %d", actualAmount, cheque.CumulativePayout)
 	}
+	if honey != cheque.Honey {
+		t.Fatalf("credited wrong honey: was %d, expected: %d", honey, cheque.Honey)
+	}
 
 	// verify that it was indeed saved
 	if peer.getLastReceivedCheque().CumulativePayout != cheque.CumulativePayout {
@@ -1468,7 +1723,7 @@ func TestPeerProcessAndVerifyCheque(t *testing.T) {
 	otherCheque.Honey = 10
 	otherCheque.Signature, _ = otherCheque.Sign(ownerKey)
 
-	if _, err := swap.processAndVerifyCheque(otherCheque, peer); err != nil {
+	if _, _, err := swap.processAndVerifyCheque(otherCheque, peer); err != nil {
 		t.Fatalf("failed to process cheque: %s", err)
 	}
 
@@ -1491,7 +1746,7 @@ func TestPeerProcessAndVerifyChequeInvalid(t *testing.T) {
 	cheque.Beneficiary = ownerAddress
 	cheque.Signature, _ = cheque.Sign(ownerKey)
 
-	if _, err := swap.processAndVerifyCheque(cheque, peer); err == nil {
+	if _, _, err := swap.processAndVerifyCheque(cheque, peer); err == nil {
 		t.Fatal("accecpted an invalid cheque as first cheque")
 	}
 
@@ -1499,7 +1754,7 @@ func TestPeerProcessAndVerifyChequeInvalid(t *testing.T) {
 	cheque = newTestCheque()
 	cheque.Signature, _ = cheque.Sign(ownerKey)
 
-	if _, err := swap.processAndVerifyCheque(cheque, peer); err != nil {
+	if _, _, err := swap.processAndVerifyCheque(cheque, peer); err != nil {
 		t.Fatalf("failed to process cheque: %s", err)
 	}
 
@@ -1513,7 +1768,7 @@ func TestPeerProcessAndVerifyChequeInvalid(t *testing.T) {
 	otherCheque.Honey = 10
 	otherCheque.Signature, _ = otherCheque.Sign(ownerKey)
 
-	if _, err := swap.processAndVerifyCheque(otherCheque, peer); err == nil {
+	if _, _, err := swap.processAndVerifyCheque(otherCheque, peer); err == nil {
 		t.Fatal("accepted a cheque with lower amount")
 	}
 
@@ -1568,11 +1823,11 @@ func TestSwapLogToFile(t *testing.T) {
 	// so creditor is the model of the remote mode for the debitor! (and vice versa)
 	cPeer := newDummyPeerWithSpec(Spec)
 	dPeer := newDummyPeerWithSpec(Spec)
-	creditor, err := debitorSwap.addPeer(cPeer.Peer, creditorSwap.owner.address, debitorSwap.GetParams().ContractAddress)
+	creditor, err := debitorSwap.addPeer(cPeer.Peer, creditorSwap.owner.address, debitorSwap.GetParams().ContractAddress, DefaultRetrievePricing)
 	if err != nil {
 		t.Fatal(err)
 	}
-	debitor, err := creditorSwap.addPeer(dPeer.Peer, debitorSwap.owner.address, debitorSwap.GetParams().ContractAddress)
+	debitor, err := creditorSwap.addPeer(dPeer.Peer, debitorSwap.owner.address, debitorSwap.GetParams().ContractAddress, DefaultRetrievePricing)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1647,7 +1902,7 @@ func TestAvailableBalance(t *testing.T) {
 		t.Fatal(err)
 	}
 	// create a peer
-	peer, err := swap.addPeer(newDummyPeerWithSpec(Spec).Peer, swap.owner.address, swap.GetParams().ContractAddress)
+	peer, err := swap.addPeer(newDummyPeerWithSpec(Spec).Peer, swap.owner.address, swap.GetParams().ContractAddress, DefaultRetrievePricing)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1714,3 +1969,202 @@ func (d *dummyMsgRW) ReadMsg() (p2p.Msg, error) {
 func (d *dummyMsgRW) WriteMsg(msg p2p.Msg) error {
 	return nil
 }
+
+// TestExemptPeers tests that peers exempt by their node ID or overlay address
+// are not accounted with, even if they are not swap enabled
+func TestExemptPeers(t *testing.T) {
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	testDeploy(context.Background(), swap, big.NewInt(0))
+
+	testPeer := newDummyPeer()
+	swap.addPeer(testPeer.Peer, swap.owner.address, swap.GetParams().ContractAddress, DefaultRetrievePricing)
+	if err := swap.AddExemptPeer(testPeer.ID().String()); err != nil {
+		t.Fatal(err)
+	}
+	// a debt over the payment threshold would send a cheque
+	if err := swap.Add(-2*int64(DefaultPaymentThreshold), testPeer.Peer); err != nil {
+		t.Fatal(err)
+	}
+	balance, err := swap.PeerBalance(testPeer.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance != 0 {
+		t.Fatalf("expected no balance with an exempt peer, got %d", balance)
+	}
+	cheques, err := swap.PeerCheques(testPeer.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cheques.PendingCheque != nil {
+		t.Fatalf("expected no cheque for an exempt peer, got %v", cheques.PendingCheque)
+	}
+
+	if ok, err := swap.RemoveExemptPeer(testPeer.ID().String()); err != nil || !ok {
+		t.Fatalf("expected the exemption to be removed, got %t %v", ok, err)
+	}
+	if err := swap.Add(10, testPeer.Peer); err != nil {
+		t.Fatal(err)
+	}
+	if balance, _ := swap.PeerBalance(testPeer.ID()); balance != 10 {
+		t.Fatalf("expected balance 10, got %d", balance)
+	}
+
+	// a peer which is not swap enabled is exempt by its overlay address
+	workerPeer := newDummyPeer()
+	overlay := network.RandomBzzAddr().Over()
+	swap.SetOverlayLookup(func(id enode.ID) []byte {
+		if id == workerPeer.ID() {
+			return overlay
+		}
+		return nil
+	})
+	if err := swap.Add(10, workerPeer.Peer); err == nil {
+		t.Fatal("expected accounting with a peer which is not swap enabled to fail")
+	}
+	if err := swap.AddExemptPeer(hex.EncodeToString(overlay)); err != nil {
+		t.Fatal(err)
+	}
+	if err := swap.Add(10, workerPeer.Peer); err != nil {
+		t.Fatal(err)
+	}
+	if exempt := swap.ExemptPeers(); len(exempt) != 1 || exempt[0] != hex.EncodeToString(overlay) {
+		t.Fatalf("expected exempt peers [%x], got %v", overlay, exempt)
+	}
+
+	if err := swap.AddExemptPeer("not a peer"); err == nil {
+		t.Fatal("expected invalid exempt peer to be rejected")
+	}
+}
+
+// TestGraceDecay tests that balances within the grace allowance agreed with
+// the peer decay towards zero over time in both directions, while larger
+// balances do not
+func TestGraceDecay(t *testing.T) {
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	testDeploy(context.Background(), swap, big.NewInt(int64(DefaultPaymentThreshold)*2))
+
+	sim := new(mclock.Simulated)
+	swap.clock = network.NewClock(sim, time.Now())
+	swap.params.GraceAllowance = 1000
+	swap.params.GraceDecayRate = 10
+
+	testPeer := newDummyPeerWithSpec(Spec)
+	swapPeer, err := swap.addPeer(testPeer.Peer, swap.owner.address, swap.GetParams().ContractAddress, DefaultRetrievePricing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the peer allows a larger balance, the lower allowance of the node applies
+	swapPeer.grace = agreeGrace(swap.graceTerms(), GraceTerms{Allowance: 1500, DecayRate: 20})
+	if swapPeer.grace != (GraceTerms{Allowance: 1000, DecayRate: 10}) {
+		t.Fatalf("got agreed grace terms %+v", swapPeer.grace)
+	}
+
+	balance := func() int64 {
+		t.Helper()
+		b, err := swap.PeerBalance(testPeer.ID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	if err := swap.Add(500, testPeer.Peer); err != nil {
+		t.Fatal(err)
+	}
+	sim.Run(20 * time.Second)
+	if b := balance(); b != 300 {
+		t.Fatalf("expected balance 300 after decaying for 20s, got %d", b)
+	}
+	// the decay is applied before the next balance change
+	if err := swap.Add(-400, testPeer.Peer); err != nil {
+		t.Fatal(err)
+	}
+	if b := balance(); b != -100 {
+		t.Fatalf("expected balance -100, got %d", b)
+	}
+	// debts owed to the peer decay as well, as the peer decays them too
+	sim.Run(5 * time.Second)
+	if b := balance(); b != -50 {
+		t.Fatalf("expected balance -50 after decaying for 5s, got %d", b)
+	}
+	// debts do not decay past zero
+	sim.Run(time.Minute)
+	if b := balance(); b != 0 {
+		t.Fatalf("expected balance 0, got %d", b)
+	}
+	if err := swap.Add(200, testPeer.Peer); err != nil {
+		t.Fatal(err)
+	}
+	sim.Run(time.Minute)
+	if b := balance(); b != 0 {
+		t.Fatalf("expected balance 0, got %d", b)
+	}
+
+	// the decay restarts with balance changes of cheques and reconciliation
+	if err := swap.Add(500, testPeer.Peer); err != nil {
+		t.Fatal(err)
+	}
+	sim.Run(20 * time.Second)
+	swapPeer.lock.Lock()
+	err = swapPeer.updateBalance(-100, auditChequeReceived, "")
+	swapPeer.lock.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := balance(); b != 200 {
+		t.Fatalf("expected balance 200 after a cheque, got %d", b)
+	}
+	sim.Run(10 * time.Second)
+	swapPeer.lock.Lock()
+	err = swapPeer.setBalance(500)
+	swapPeer.lock.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := balance(); b != 500 {
+		t.Fatalf("expected balance 500 after reconciliation, got %d", b)
+	}
+	sim.Run(10 * time.Second)
+	if b := balance(); b != 400 {
+		t.Fatalf("expected balance 400, got %d", b)
+	}
+
+	// debts beyond the allowance do not decay
+	if err := swap.Add(1600, testPeer.Peer); err != nil {
+		t.Fatal(err)
+	}
+	sim.Run(time.Minute)
+	if err := swap.Add(1, testPeer.Peer); err != nil {
+		t.Fatal(err)
+	}
+	if b := balance(); b != 2001 {
+		t.Fatalf("expected balance 2001, got %d", b)
+	}
+	if err := swap.Add(-4002, testPeer.Peer); err != nil {
+		t.Fatal(err)
+	}
+	sim.Run(time.Minute)
+	if b := balance(); b != -2001 {
+		t.Fatalf("expected balance -2001, got %d", b)
+	}
+
+	// without a decay rate agreed with the peer balances do not decay
+	swapPeer.lock.Lock()
+	swapPeer.grace = agreeGrace(swap.graceTerms(), GraceTerms{Allowance: 1000})
+	err = swapPeer.setBalance(500)
+	swapPeer.lock.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim.Run(time.Minute)
+	if b := balance(); b != 500 {
+		t.Fatalf("expected balance 500, got %d", b)
+	}
+
+	if err := swap.SetThresholds(1000, 2000); err == nil {
+		t.Fatal("expected a payment threshold within the grace allowance to be rejected")
+	}
+}
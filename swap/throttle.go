@@ -0,0 +1,72 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// DefaultThrottleMaxDelay is the delay of serving a peer at the disconnect threshold
+const DefaultThrottleMaxDelay = 2 * time.Second
+
+// validateThrottle checks that the throttling band starts below the disconnect threshold
+func validateThrottle(fraction float64, maxDelay time.Duration) error {
+	if fraction < 0 || fraction >= 1 {
+		return fmt.Errorf("throttle fraction %v must be at least 0 and lower than 1", fraction)
+	}
+	if maxDelay < 0 {
+		return fmt.Errorf("throttle delay %v must not be negative", maxDelay)
+	}
+	return nil
+}
+
+// Throttle returns how long serving the peer should be delayed. Peers whose
+// debt is over the throttle fraction of the disconnect threshold are served
+// with a delay growing linearly with their debt up to the maximal delay at
+// the disconnect threshold, so that they pay before they are disconnected.
+// Swap implements the protocols.Throttler interface
+func (s *Swap) Throttle(peer enode.ID) time.Duration {
+	if s.params.ThrottleFraction <= 0 {
+		return 0
+	}
+	swapPeer := s.getPeer(peer)
+	if swapPeer == nil || swapPeer.asset == "" {
+		return 0
+	}
+	swapPeer.lock.RLock()
+	balance := swapPeer.decayedBalance(s.clock.Time())
+	swapPeer.lock.RUnlock()
+
+	_, disconnectThreshold := s.thresholds()
+	start := int64(float64(disconnectThreshold) * s.params.ThrottleFraction)
+	if balance <= start {
+		return 0
+	}
+	maxDelay := s.params.ThrottleMaxDelay
+	if maxDelay == 0 {
+		maxDelay = DefaultThrottleMaxDelay
+	}
+	metrics.GetOrRegisterCounter("swap.throttled", nil).Inc(1)
+	if balance >= disconnectThreshold {
+		return maxDelay
+	}
+	return time.Duration(float64(maxDelay) * float64(balance-start) / float64(disconnectThreshold-start))
+}
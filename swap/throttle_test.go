@@ -0,0 +1,72 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethersphere/swarm/p2p/protocols"
+)
+
+var _ protocols.Throttler = &Swap{}
+
+// TestThrottle tests that serving a peer is delayed in proportion to its debt
+// within the throttling band below the disconnect threshold
+func TestThrottle(t *testing.T) {
+	swap, clean := newTestSwap(t, ownerKey, nil)
+	defer clean()
+	testDeploy(context.Background(), swap, big.NewInt(0))
+
+	testPeer := newDummyPeer()
+	swapPeer, err := swap.addPeer(testPeer.Peer, swap.owner.address, swap.GetParams().ContractAddress, DefaultRetrievePricing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	disconnectThreshold := int64(DefaultDisconnectThreshold)
+	setBalance(t, swapPeer, disconnectThreshold-1)
+	if delay := swap.Throttle(testPeer.ID()); delay != 0 {
+		t.Fatalf("expected no delay without throttling, got %v", delay)
+	}
+
+	swap.params.ThrottleFraction = 0.5
+	swap.params.ThrottleMaxDelay = time.Second
+	for _, tc := range []struct {
+		balance int64
+		delay   time.Duration
+	}{
+		{-disconnectThreshold, 0},
+		{disconnectThreshold / 2, 0},
+		{disconnectThreshold * 3 / 4, 500 * time.Millisecond},
+		{disconnectThreshold, time.Second},
+		{2 * disconnectThreshold, time.Second},
+	} {
+		setBalance(t, swapPeer, tc.balance)
+		if delay := swap.Throttle(testPeer.ID()); delay != tc.delay {
+			t.Errorf("expected a delay of %v at balance %d, got %v", tc.delay, tc.balance, delay)
+		}
+	}
+
+	if delay := swap.Throttle(newDummyPeer().ID()); delay != 0 {
+		t.Fatalf("expected no delay for a peer which is not a swap peer, got %v", delay)
+	}
+	if err := validateThrottle(1, time.Second); err == nil {
+		t.Fatal("expected an error for a throttling band starting at the disconnect threshold")
+	}
+}
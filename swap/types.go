@@ -36,10 +36,33 @@ type Cheque struct {
 
 // HandshakeMsg is exchanged on peer handshake
 type HandshakeMsg struct {
+	ChainID         uint64            // chain id of the blockchain the peer is connected to
+	ContractAddress common.Address    // chequebook contract address of the peer
+	RetrievePricing RetrievePricing   // retrieve request pricing of the peer
+	Assets          []AssetChequebook // settlement assets of the peer in order of preference
+	Accounts        []AccountState    // state of the accounts of the peer with the receiver in its settlement assets
+	PayOnly         bool              // the peer pays for services but extends no credit
+	InterestTerms   InterestTerms     // interest the peer charges on debts owed to it
+	GraceTerms      GraceTerms        // grace allowance and decay rate the peer applies to small balances
+}
+
+// legacyHandshakeMsg is exchanged on peer handshake in the first version of the protocol
+type legacyHandshakeMsg struct {
 	ChainID         uint64         // chain id of the blockchain the peer is connected to
 	ContractAddress common.Address // chequebook contract address of the peer
 }
 
+// upgrade returns the handshake of the current protocol version
+// announcing what a peer speaking the first version supports
+func (m *legacyHandshakeMsg) upgrade() *HandshakeMsg {
+	return &HandshakeMsg{
+		ChainID:         m.ChainID,
+		ContractAddress: m.ContractAddress,
+		RetrievePricing: legacyRetrievePricing,
+		Assets:          []AssetChequebook{{Asset: AssetETH, Contract: m.ContractAddress}},
+	}
+}
+
 // EmitChequeMsg is sent from the debitor to the creditor with the actual cheque
 type EmitChequeMsg struct {
 	Cheque *Cheque
@@ -49,3 +72,9 @@ type EmitChequeMsg struct {
 type ConfirmChequeMsg struct {
 	Cheque *Cheque
 }
+
+// PaymentRequestMsg is sent from the creditor to the debitor to ask for a cheque
+// when the debt of the debitor passes the payment request threshold
+type PaymentRequestMsg struct {
+	Honey uint64 // honey the debitor owes from the point of view of the creditor
+}
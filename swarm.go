@@ -29,6 +29,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 
@@ -37,6 +38,7 @@ import (
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethersphere/swarm/api"
@@ -51,8 +53,10 @@ import (
 	"github.com/ethersphere/swarm/network/stream"
 	"github.com/ethersphere/swarm/p2p/protocols"
 	"github.com/ethersphere/swarm/pss"
+	"github.com/ethersphere/swarm/pss/bridge"
 	pssmessage "github.com/ethersphere/swarm/pss/message"
 	"github.com/ethersphere/swarm/pushsync"
+	"github.com/ethersphere/swarm/standby"
 	"github.com/ethersphere/swarm/state"
 	"github.com/ethersphere/swarm/storage"
 	"github.com/ethersphere/swarm/storage/feed"
@@ -85,17 +89,31 @@ type Swarm struct {
 	bzzEth            *bzzeth.BzzEth
 	privateKey        *ecdsa.PrivateKey
 	netStore          *storage.NetStore
+	localStore        *localstore.DB
 	sfs               *fuse.SwarmFS // need this to cleanup all the active mounts on node exit
 	ps                *pss.Pss
+	pssBridge         *bridge.Bridge             // relays pss messages to an external HTTP service, nil unless configured
+	wsUnderlay        *network.WebSocketListener // accepts peers over websockets, nil unless configured
+	timestamper       *api.Timestamper           // anchors the root hashes of uploaded content, nil unless configured
 	pushSync          *pushsync.Pusher
 	storer            *pushsync.Storer
 	swap              *swap.Swap
 	stateStore        *state.DBStore
 	tags              *chunk.Tags
+	chunkEvents       *chunk.Events // chunk lifecycle events, nil unless enabled in config
 	accountingMetrics *protocols.AccountingMetrics
+	bandwidth         *protocols.BandwidthAccounting
+	scheduler         *protocols.BandwidthScheduler
+	handoff           func()           // pushes chunks to the neighbourhood on shutdown, nil if disabled
+	standby           *standby.Standby // mirrors the localstore to or from a warm standby, nil unless configured
 	cleanupFuncs      []func() error
 	pinAPI            *pin.API // API object implements all pinning related commands
 	inspector         *api.Inspector
+	healthCheck       *api.HealthCheck // checks whether uploaded content can propagate to the network
+	gcAPI             *api.GCAPI
+	usageAPI          *api.UsageAPI
+	reloadMu          sync.Mutex                  // serialises configuration reloads
+	configLoader      func() (*api.Config, error) // reads the configuration on reload, nil if not supported
 
 	tracerClose io.Closer
 }
@@ -119,13 +137,31 @@ func NewSwarm(config *api.Config, mockStore *mock.NodeStore) (self *Swarm, err e
 	}
 	log.Debug("Setting up Swarm service components")
 
+	if err := applyLogConfig(config); err != nil {
+		return nil, err
+	}
+
+	peerFilter, err := network.NewPeerFilter(config.AllowPeers, config.DenyPeers)
+	if err != nil {
+		return nil, err
+	}
+
 	bzzconfig := &network.BzzConfig{
-		NetworkID:    config.NetworkID,
-		Address:      network.NewBzzAddr(common.FromHex(config.BzzKey), []byte(config.Enode.URLv4())),
-		HiveParams:   config.HiveParams,
-		LightNode:    config.LightNodeEnabled,
-		BootnodeMode: config.BootnodeMode,
-		SyncEnabled:  config.SyncEnabled,
+		NetworkID:      config.NetworkID,
+		ForkID:         config.NetworkForkID,
+		Address:        network.NewBzzAddr(common.FromHex(config.BzzKey), []byte(config.Enode.URLv4())),
+		HiveParams:     config.HiveParams,
+		LightNode:      config.LightNodeEnabled,
+		BootnodeMode:   config.BootnodeMode,
+		SyncEnabled:    config.SyncEnabled,
+		PeerFilter:     peerFilter,
+		ClockTolerance: config.ClockTolerance,
+
+		HandshakeRateLimit:        int(config.HandshakeRateLimit),
+		HandshakePuzzleDifficulty: uint8(config.HandshakePuzzle),
+	}
+	if config.NetworkKey != "" {
+		bzzconfig.NetworkKey = network.NetworkKey(config.NetworkKey)
 	}
 
 	// Swap initialization
@@ -134,11 +170,49 @@ func NewSwarm(config *api.Config, mockStore *mock.NodeStore) (self *Swarm, err e
 		if self.config.NetworkID != swap.AllowedNetworkID {
 			return nil, fmt.Errorf("swap can only be enabled under BZZ Network ID %d, found Network ID %d instead", swap.AllowedNetworkID, self.config.NetworkID)
 		}
+		exemptPeers, err := swap.NewExemptPeers(self.config.SwapExemptPeers)
+		if err != nil {
+			return nil, err
+		}
+		assets, tokenChequebooks, err := swap.ParseAssets(self.config.SwapAssets, self.config.SwapTokenChequebooks)
+		if err != nil {
+			return nil, err
+		}
 		swapParams := &swap.Params{
-			BaseAddrs:           bzzconfig.Address,
-			LogPath:             self.config.SwapLogPath,
-			DisconnectThreshold: int64(self.config.SwapDisconnectThreshold),
-			PaymentThreshold:    int64(self.config.SwapPaymentThreshold),
+			BaseAddrs:               bzzconfig.Address,
+			LogPath:                 self.config.SwapLogPath,
+			DisconnectThreshold:     int64(self.config.SwapDisconnectThreshold),
+			PaymentThreshold:        int64(self.config.SwapPaymentThreshold),
+			RetrievePricing:         swap.DefaultRetrievePricing,
+			EncryptStore:            self.config.EncryptStateStore,
+			ExemptPeers:             exemptPeers,
+			GraceAllowance:          int64(self.config.SwapGraceAllowance),
+			GraceDecayRate:          int64(self.config.SwapGraceDecayRate),
+			PriceOracleSource:       self.config.SwapPriceOracle,
+			HoneyPrice:              self.config.SwapHoneyPrice,
+			PriceTolerance:          self.config.SwapPriceTolerance,
+			Assets:                  assets,
+			TokenChequebooks:        tokenChequebooks,
+			BalanceLogPath:          self.config.SwapBalanceLog,
+			BalanceLogMaxSize:       int64(self.config.SwapBalanceLogSize),
+			BalanceLogMaxFiles:      int(self.config.SwapBalanceLogFiles),
+			ThrottleFraction:        self.config.SwapThrottleFraction,
+			ThrottleMaxDelay:        self.config.SwapThrottleMaxDelay,
+			ReconcileTolerance:      int64(self.config.SwapReconcileTolerance),
+			PaymentRequestThreshold: int64(self.config.SwapPaymentRequestThreshold),
+			Keystore:                self.config.Keystore(),
+			OwnerLocked:             self.config.SwapOwnerLocked,
+			InterestDebtLevel:       int64(self.config.SwapInterestDebtLevel),
+			InterestGracePeriod:     self.config.SwapInterestGracePeriod,
+			InterestRate:            self.config.SwapInterestRate,
+			PayOnly:                 self.config.SwapPayOnly,
+			CashingInterval:         self.config.SwapCashingInterval,
+			CashingThreshold:        self.config.SwapCashingThreshold,
+			CashoutStrategy: swap.CashoutStrategy{
+				MinAmount:   self.config.SwapCashoutMinAmount,
+				MaxDelay:    self.config.SwapCashoutMaxDelay,
+				MaxGasPrice: self.config.SwapCashoutMaxGasPrice,
+			},
 		}
 
 		// create the accounting objects
@@ -165,10 +239,18 @@ func NewSwarm(config *api.Config, mockStore *mock.NodeStore) (self *Swarm, err e
 		config.HiveParams.DisableAutoConnect = true
 	}
 
-	self.stateStore, err = state.NewDBStore(filepath.Join(config.Path, "state-store.db"))
+	stateStorePath := filepath.Join(config.Path, "state-store.db")
+	if config.EncryptStateStore {
+		self.stateStore, err = state.NewEncryptedDBStore(stateStorePath, state.EncryptionKey(self.privateKey, "state-store"))
+	} else {
+		self.stateStore, err = state.NewDBStore(stateStorePath)
+	}
 	if err != nil {
 		return
 	}
+	if err = state.Migrate(self.stateStore, network.KademliaSchema, stream.IntervalsSchema); err != nil {
+		return
+	}
 
 	// set up high level api
 	var resolver *api.MultiResolver
@@ -204,8 +286,13 @@ func NewSwarm(config *api.Config, mockStore *mock.NodeStore) (self *Swarm, err e
 		return nil, errors.New("Legacy database format detected! Please read the migration announcement at: https://github.com/ethersphere/swarm/blob/master/docs/Migration-v0.3-to-v0.4.md")
 	}
 
+	self.bandwidth = protocols.NewBandwidthAccounting(config.BandwidthDailyCap)
+
 	var feedsHandler *feed.Handler
-	fhParams := &feed.HandlerParams{}
+	fhParams := &feed.HandlerParams{
+		DeltaSnapshotInterval: config.FeedDeltaInterval,
+		ClockTolerance:        config.ClockTolerance,
+	}
 
 	feedsHandler = feed.NewHandler(fhParams)
 	self.tags = chunk.NewTags()
@@ -220,20 +307,34 @@ func NewSwarm(config *api.Config, mockStore *mock.NodeStore) (self *Swarm, err e
 		log.Info("loaded saved tags successfully from state store")
 	}
 
+	kadParams := network.NewKadParams()
+	kadParams.UptimeBias = config.PeerUptimeBias
 	to := network.NewKademlia(
 		common.FromHex(config.BzzKey),
-		network.NewKadParams(),
+		kadParams,
 	)
 
+	if config.ChunkEventsEnabled {
+		self.chunkEvents = chunk.NewEvents()
+	}
+
 	localStore, err := localstore.New(config.ChunkDbPath, config.BaseKey, &localstore.Options{
-		MockStore:    mockStore,
-		Capacity:     config.DbCapacity,
-		Tags:         self.tags,
-		PutToGCCheck: to.IsWithinDepth,
+		MockStore:      mockStore,
+		Capacity:       config.DbCapacity,
+		Engine:         config.DbEngine,
+		ReadOnly:       config.DbReadOnly,
+		SyncBatchSize:  config.SyncBatchSize,
+		SyncBatchDelay: config.SyncBatchDelay,
+		ClockTolerance: config.ClockTolerance,
+		Tags:           self.tags,
+		Events:         self.chunkEvents,
+		CacheCapacity:  config.CacheCapacity,
+		PutToGCCheck:   to.IsWithinDepth,
 	})
 	if err != nil {
 		return nil, err
 	}
+	self.localStore = localStore
 	lstore := chunk.NewValidatorStore(
 		localStore,
 		storage.NewContentAddressValidator(storage.MakeHashFunc(storage.DefaultHash)),
@@ -242,7 +343,18 @@ func NewSwarm(config *api.Config, mockStore *mock.NodeStore) (self *Swarm, err e
 
 	self.netStore = storage.NewNetStore(lstore, bzzconfig.Address)
 	self.retrieval = retrieval.New(to, self.netStore, bzzconfig.Address, self.swap)
+	if config.ObfuscateRetrieval {
+		self.retrieval.EnableObfuscation(config.Obfuscation)
+	}
+	if config.RetryCorruptChunks {
+		self.retrieval.EnableCorruptionRetry()
+	}
+	if config.ProvenanceWindow > 0 {
+		self.retrieval.EnableProvenance(config.ProvenanceWindow)
+	}
+	self.retrieval.SetWorkers(config.RetrievalWorkers)
 	self.netStore.RemoteGet = self.retrieval.RequestFromPeers
+	self.netStore.SearchTimeout = self.retrieval.SearchTimeout
 
 	feedsHandler.SetStore(self.netStore)
 
@@ -251,17 +363,39 @@ func NewSwarm(config *api.Config, mockStore *mock.NodeStore) (self *Swarm, err e
 		syncing = false
 	}
 
-	syncProvider := stream.NewSyncProvider(self.netStore, to, bzzconfig.Address, syncing, false)
+	syncProvider := stream.NewSyncProvider(self.netStore, to, bzzconfig.Address, syncing, config.SyncOnlyWithinDepth)
 	self.streamer = stream.New(self.stateStore, bzzconfig.Address, syncProvider)
+	self.streamer.SetWorkers(config.SyncWorkers)
+	self.streamer.SetFragmentSize(config.SyncFragmentSize)
 
 	// Swarm Hash Merklised Chunking for Arbitrary-length Document/File storage
 	lnetStore := storage.NewLNetStore(self.netStore)
 	self.fileStore = storage.NewFileStore(lnetStore, localStore, self.config.FileStoreParams, self.tags)
 
 	log.Debug("Setup local storage")
-	self.bzz = network.NewBzz(bzzconfig, to, self.stateStore, stream.Spec, self.retrieval.Spec(), self.streamer.Run, self.retrieval.Run)
+	self.bzz = network.NewBzz(bzzconfig, to, self.stateStore, self.streamer.Spec(), self.retrieval.Spec(), self.streamer.Run, self.retrieval.Run)
+	self.bzz.SetLegacyStreamer(stream.LegacySpec, self.streamer.RunLegacy)
+	if self.swap != nil {
+		self.bzz.Hive.SetPrices(network.ServicePrices{Retrieve: self.swap.RetrievePricing().Base})
+		// peers of the previous release charge the fixed retrieve request price
+		self.bzz.Hive.SetDefaultPrices(network.ServicePrices{Retrieve: swap.RetrieveRequestPrice})
+		self.streamer.SetThrottler(self.swap)
+		// peers can be exempt from accounting by their overlay address
+		self.swap.SetOverlayLookup(func(id enode.ID) []byte {
+			if p := self.bzz.Hive.Peer(id); p != nil {
+				return p.Over()
+			}
+			return nil
+		})
+	}
 	self.bzzEth = bzzeth.New(self.netStore, to)
 
+	self.scheduler = protocols.NewBandwidthScheduler(config.BandwidthUpstream, map[string]uint64{
+		stream.Spec.Name:           config.BandwidthSync,
+		self.retrieval.Spec().Name: config.BandwidthRetrieval,
+		pss.ProtocolName:           config.BandwidthPss,
+	})
+
 	// Pss = postal service over swarm (devp2p over bzz)
 	self.ps, err = pss.New(to, config.Pss)
 	if err != nil {
@@ -270,23 +404,68 @@ func NewSwarm(config *api.Config, mockStore *mock.NodeStore) (self *Swarm, err e
 	if pss.IsActiveHandshake {
 		pss.SetHandshakeController(self.ps, pss.NewHandshakeParams())
 	}
+	if config.PssBridge != nil && config.PssBridge.Enabled() {
+		self.pssBridge, err = bridge.New(self.ps, config.PssBridge)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	if config.PushSyncEnabled {
 		// expire time for push-sync messages should be lower than regular chat-like messages to avoid network flooding
 		pubsub := pss.NewPubSub(self.ps, 20*time.Second)
-		self.pushSync = pushsync.NewPusher(localStore, pubsub, self.tags)
+		self.pushSync = pushsync.NewPusher(localStore, pubsub, self.tags, self.chunkEvents)
 		self.storer = pushsync.NewStorer(self.netStore, pubsub)
 	}
 
+	if config.HandoffOnShutdown {
+		self.handoff = func() {
+			ctx, cancel := context.WithTimeout(context.Background(), config.HandoffTimeout)
+			defer cancel()
+			pubsub := pss.NewPubSub(self.ps, 20*time.Second)
+			depth := to.NeighbourhoodDepth()
+			log.Info("handing off chunks to the neighbourhood", "depth", depth, "timeout", config.HandoffTimeout)
+			if _, err := pushsync.Handoff(ctx, localStore, pubsub, uint8(depth)); err != nil {
+				log.Warn("chunk handoff incomplete", "err", err)
+			}
+		}
+	}
+
 	self.api = api.NewAPI(self.fileStore, self.dns, self.rns, feedsHandler, self.privateKey, self.tags)
 
+	if config.TimestampInterval > 0 {
+		self.timestamper, err = newTimestamper(config, self.privateKey, self.stateStore)
+		if err != nil {
+			return nil, err
+		}
+		self.api.Timestamper = self.timestamper
+	}
+
 	if config.EnablePinning {
 		// Instantiate the pinAPI object with the already opened localstore
 		self.pinAPI = pin.NewAPI(localStore, self.stateStore, self.config.FileStoreParams, self.tags, self.api)
 	}
+
+	standbyConfig, err := standby.NewConfig(config.StandbyPrimary, config.StandbyPeers)
+	if err != nil {
+		return nil, err
+	}
+	if standbyConfig.Enabled() {
+		var pinner standby.Pinner
+		if self.pinAPI != nil {
+			pinner = self.pinAPI
+		}
+		self.standby, err = standby.New(localStore, pinner, self.stateStore, standbyConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
 	self.sfs = fuse.NewSwarmFS(self.api)
 	log.Debug("Initialized FUSE filesystem")
 	self.inspector = api.NewInspector(self.api, self.bzz.Hive, self.netStore, self.streamer, localStore)
+	self.healthCheck = api.NewHealthCheck(to, localStore, self.streamer, config.UploadMaxSyncLag)
+	self.gcAPI = api.NewGCAPI(localStore)
+	self.usageAPI = api.NewUsageAPI(localStore)
 
 	return self, nil
 }
@@ -317,6 +496,54 @@ func parseResolverAPIAddress(s string) (tld, endpoint string, addr common.Addres
 	return
 }
 
+// newTimestamper creates a timestamper anchoring the root hashes of uploaded content
+// with transactions paid for by the bzz account, sent to the configured Ethereum API endpoint
+func newTimestamper(config *api.Config, privkey *ecdsa.PrivateKey, store state.Store) (*api.Timestamper, error) {
+	log.Info("connecting to timestamp anchoring backend", "url", config.TimestampBackend)
+	client, err := ethclient.Dial(config.TimestampBackend)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to timestamp backend %s: %v", config.TimestampBackend, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting the chain ID of timestamp backend %s: %v", config.TimestampBackend, err)
+	}
+	return api.NewTimestamper(api.NewTxAnchorer(client, privkey, chainID), store, config.TimestampInterval)
+}
+
+// underlays returns the alternative underlays the node advertises
+func underlays(config *api.Config) (underlays []network.Underlay) {
+	for _, s := range config.Underlays {
+		u, err := network.ParseUnderlay(s)
+		if err != nil {
+			log.Warn("not advertising underlay", "err", err)
+			continue
+		}
+		underlays = append(underlays, u)
+	}
+	return underlays
+}
+
+// uploadPolicies returns the policies uploads to the http api are checked against
+func uploadPolicies(config *api.Config, health *api.HealthCheck) (policies []httpapi.UploadPolicy) {
+	// unhealthy nodes refuse uploads before they are inspected
+	if config.UploadHealthy {
+		policies = append(policies, httpapi.RequireHealthy(health.Check))
+	}
+	if config.UploadMaxSize > 0 {
+		policies = append(policies, httpapi.MaxUploadSize(config.UploadMaxSize))
+	}
+	if len(config.UploadContentTypes) > 0 {
+		policies = append(policies, httpapi.AllowContentTypes(config.UploadContentTypes...))
+	}
+	if config.UploadScanURL != "" {
+		policies = append(policies, httpapi.ScanUploads(config.UploadScanURL, &http.Client{Timeout: 30 * time.Second}, config.UploadMaxSize))
+	}
+	return policies
+}
+
 // ensClient provides functionality for api.ResolveValidator
 type ensClient struct {
 	*ens.ENS
@@ -406,7 +633,7 @@ func (s *Swarm) Start(srv *p2p.Server) error {
 	s.tracerClose = tracing.Closer
 
 	// update uaddr to correct enode
-	newaddr := s.bzz.UpdateLocalAddr([]byte(srv.Self().URLv4()))
+	newaddr := s.bzz.UpdateLocalAddr(network.EncodeUnderlays([]byte(srv.Self().URLv4()), underlays(s.config)))
 	log.Info("Updated bzz local addr", "oaddr", fmt.Sprintf("%x", newaddr.OAddr), "uaddr", fmt.Sprintf("%s", newaddr.UAddr))
 
 	log.Info("Starting bzz service")
@@ -418,6 +645,13 @@ func (s *Swarm) Start(srv *p2p.Server) error {
 	}
 	log.Info("Swarm network started", "bzzaddr", fmt.Sprintf("%x", s.bzz.Hive.BaseAddr()))
 
+	if s.config.WSUnderlayAddr != "" {
+		s.wsUnderlay, err = network.ListenWebSocket(s.config.WSUnderlayAddr, s.config.WSUnderlayCert, s.config.WSUnderlayKey, srv)
+		if err != nil {
+			return fmt.Errorf("websocket underlay: %v", err)
+		}
+	}
+
 	err = s.bzzEth.Start(srv)
 	if err != nil {
 		return err
@@ -426,10 +660,28 @@ func (s *Swarm) Start(srv *p2p.Server) error {
 	if s.ps != nil {
 		s.ps.Start(srv)
 	}
+	if s.standby != nil {
+		if err := s.standby.Start(srv); err != nil {
+			return err
+		}
+	}
+	if s.pssBridge != nil {
+		if err := s.pssBridge.Start(); err != nil {
+			return err
+		}
+	}
+	if s.timestamper != nil {
+		s.timestamper.Start()
+	}
+	if s.config.NTPServer != "" {
+		// the check warns if the host clock is off
+		go s.bzz.ClockSkew().CheckNTP(s.config.NTPServer)
+	}
 	// start swarm http proxy server
 	if s.config.Port != "" {
 		addr := net.JoinHostPort(s.config.ListenAddr, s.config.Port)
 		server := httpapi.NewServer(s.api, s.pinAPI, s.config.Cors)
+		server.SetUploadPolicies(uploadPolicies(s.config, s.healthCheck)...)
 
 		if s.config.Cors != "" {
 			log.Info("Swarm HTTP proxy CORS headers", "allowedOrigins", s.config.Cors)
@@ -489,10 +741,35 @@ func (s *Swarm) Stop() error {
 		}
 	}
 
+	if s.handoff != nil {
+		s.handoff()
+	}
+
 	if s.pushSync != nil {
 		s.pushSync.Close()
 	}
+	if s.standby != nil {
+		if err := s.standby.Stop(); err != nil {
+			log.Error("standby stop", "err", err)
+		}
+	}
+
+	if s.wsUnderlay != nil {
+		if err := s.wsUnderlay.Close(); err != nil {
+			log.Error("websocket underlay stop", "err", err)
+		}
+	}
 
+	if s.pssBridge != nil {
+		if err := s.pssBridge.Stop(); err != nil {
+			log.Error("pss bridge stop", "err", err)
+		}
+	}
+	if s.timestamper != nil {
+		if err := s.timestamper.Stop(); err != nil {
+			log.Error("timestamper stop", "err", err)
+		}
+	}
 	if s.ps != nil {
 		s.ps.Stop()
 	}
@@ -561,6 +838,12 @@ func (s *Swarm) Protocols() (protos []p2p.Protocol) {
 		if s.swap != nil {
 			protos = append(protos, s.swap.Protocols()...)
 		}
+		if s.standby != nil {
+			protos = append(protos, s.standby.Protocols()...)
+		}
+	}
+	for i := range protos {
+		protos[i] = s.scheduler.Protocol(s.bandwidth.Protocol(protos[i]))
 	}
 	return
 }
@@ -583,6 +866,18 @@ func (s *Swarm) APIs() []rpc.API {
 			Service:   s.inspector,
 			Public:    false,
 		},
+		{
+			Namespace: "bzz",
+			Version:   "4.0",
+			Service:   s.healthCheck,
+			Public:    false,
+		},
+		{
+			Namespace: "bzz",
+			Version:   "4.0",
+			Service:   &ConfigAPI{s},
+			Public:    false,
+		},
 		{
 			Namespace: "swarmfs",
 			Version:   fuse.SwarmFSVersion,
@@ -595,9 +890,28 @@ func (s *Swarm) APIs() []rpc.API {
 			Service:   protocols.NewAccountingApi(s.accountingMetrics),
 			Public:    false,
 		},
+		{
+			Namespace: "bandwidth",
+			Version:   "1.0",
+			Service:   protocols.NewBandwidthAPI(s.bandwidth),
+			Public:    false,
+		},
+		{
+			Namespace: "debug",
+			Version:   "1.0",
+			Service:   s.gcAPI,
+			Public:    false,
+		},
+		{
+			Namespace: "usage",
+			Version:   "1.0",
+			Service:   s.usageAPI,
+			Public:    false,
+		},
 	}
 
 	apis = append(apis, s.bzz.APIs()...)
+	apis = append(apis, s.retrieval.APIs()...)
 
 	// this is a workaround disabling syncing altogether from a node but
 	// must be changed when multiple stream implementations are at hand
@@ -614,6 +928,42 @@ func (s *Swarm) APIs() []rpc.API {
 		apis = append(apis, s.swap.APIs()...)
 	}
 
+	if publisher, ok := s.dns.(api.Publisher); ok {
+		apis = append(apis, rpc.API{
+			Namespace: "ens",
+			Version:   "1.0",
+			Service:   api.NewPublishAPI(publisher),
+			Public:    false,
+		})
+	}
+
+	if s.pinAPI != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "pin",
+			Version:   pin.Version,
+			Service:   pin.NewRPC(s.pinAPI),
+			Public:    false,
+		})
+	}
+
+	if s.timestamper != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "timestamp",
+			Version:   "1.0",
+			Service:   api.NewTimestampAPI(s.timestamper),
+			Public:    false,
+		})
+	}
+
+	if s.chunkEvents != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "bzz",
+			Version:   "4.0",
+			Service:   api.NewChunkEventsAPI(s.chunkEvents),
+			Public:    false,
+		})
+	}
+
 	return apis
 }
 